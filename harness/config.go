@@ -0,0 +1,27 @@
+package main
+
+import (
+	"time"
+)
+
+type config struct {
+	Brokers    []string `env:"HARNESS_BROKERS" envDefault:"localhost:9093"`
+	Libraries  []string `env:"HARNESS_LIBRARIES" envDefault:"segmentio,sarama,confluent"`
+	ReportFile string   `env:"HARNESS_REPORT_FILE" envDefault:"harness-report.json"`
+	LogLevel   string   `env:"HARNESS_LOG_LEVEL" envDefault:"info"`
+	// RestartCommand is run by sh to restart the broker, e.g. "docker restart kafka"
+	RestartCommand        string        `env:"HARNESS_RESTART_COMMAND"`
+	Topic                 string        `env:"HARNESS_TOPIC" envDefault:"harness"`
+	Producers             int           `env:"HARNESS_PRODUCERS" envDefault:"2"`
+	MessagesPerProducer   int           `env:"HARNESS_MESSAGES_PER_PRODUCER" envDefault:"1000"`
+	ProduceInterval       time.Duration `env:"HARNESS_PRODUCE_INTERVAL" envDefault:"10ms"`
+	Consumers             int           `env:"HARNESS_CONSUMERS" envDefault:"3"`
+	ConsumerJoinInterval  time.Duration `env:"HARNESS_CONSUMER_JOIN_INTERVAL" envDefault:"3s"`
+	ConsumersLeaving      int           `env:"HARNESS_CONSUMERS_LEAVING" envDefault:"1"`
+	ConsumerLeaveInterval time.Duration `env:"HARNESS_CONSUMER_LEAVE_INTERVAL" envDefault:"3s"`
+	BrokerRestarts        int           `env:"HARNESS_BROKER_RESTARTS" envDefault:"0"`
+	BrokerRestartInterval time.Duration `env:"HARNESS_BROKER_RESTART_INTERVAL" envDefault:"10s"`
+	Idempotence           bool          `env:"HARNESS_IDEMPOTENCE" envDefault:"false"`
+	Transactions          bool          `env:"HARNESS_TRANSACTIONS" envDefault:"false"`
+	DrainTimeout          time.Duration `env:"HARNESS_DRAIN_TIMEOUT" envDefault:"1m"`
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/harness"
+	"kafka-polygon/pkg/broker/provider/confluent"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/provider/sarama"
+	"kafka-polygon/pkg/cerror"
+	pkgcobra "kafka-polygon/pkg/cmd/cobra"
+	pkgenv "kafka-polygon/pkg/env"
+	"kafka-polygon/pkg/log"
+	"os"
+	"os/exec"
+)
+
+func main() {
+	var envFile string
+
+	rootCmd := pkgcobra.CmdRoot(&envFile)
+	pkgcobra.LoadFlagEnv(rootCmd.PersistentFlags(), &envFile)
+	rootCmd.AddCommand(pkgcobra.CmdHarness(run))
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	var cfg config
+	if err := pkgenv.ParseCfg(&cfg); err != nil {
+		return err
+	}
+
+	log.SetGlobalLogLevel(cfg.LogLevel)
+
+	libs, err := libraries(ctx, &cfg)
+	if err != nil {
+		return err
+	}
+
+	rep := harness.Run(ctx, scenario(&cfg), libs...)
+
+	f, err := os.Create(cfg.ReportFile)
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "create report file %s. %s", cfg.ReportFile, err.Error()).LogError()
+	}
+	defer f.Close()
+
+	if err := rep.WriteJSON(f); err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "write report. %s", err.Error()).LogError()
+	}
+
+	log.InfoF(ctx, "report is written to %s", cfg.ReportFile)
+
+	return nil
+}
+
+func scenario(cfg *config) harness.Scenario {
+	s := harness.Scenario{
+		Topic:                 cfg.Topic,
+		Producers:             cfg.Producers,
+		MessagesPerProducer:   cfg.MessagesPerProducer,
+		ProduceInterval:       cfg.ProduceInterval,
+		Consumers:             cfg.Consumers,
+		ConsumerJoinInterval:  cfg.ConsumerJoinInterval,
+		ConsumersLeaving:      cfg.ConsumersLeaving,
+		ConsumerLeaveInterval: cfg.ConsumerLeaveInterval,
+		BrokerRestarts:        cfg.BrokerRestarts,
+		BrokerRestartInterval: cfg.BrokerRestartInterval,
+		Idempotence:           cfg.Idempotence,
+		Transactions:          cfg.Transactions,
+		DrainTimeout:          cfg.DrainTimeout,
+	}
+
+	if cfg.RestartCommand != "" {
+		s.RestartBroker = func(ctx context.Context) error {
+			out, err := exec.CommandContext(ctx, "sh", "-c", cfg.RestartCommand).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("%w: %s", err, out)
+			}
+
+			return nil
+		}
+	}
+
+	return s
+}
+
+func libraries(ctx context.Context, cfg *config) ([]harness.Library, error) {
+	libs := make([]harness.Library, 0, len(cfg.Libraries))
+
+	for _, name := range cfg.Libraries {
+		switch name {
+		case harness.LibrarySegmentio:
+			libs = append(libs, harness.Segmentio(kafka.Config{
+				Brokers:                cfg.Brokers,
+				AllowAutoTopicCreation: true,
+				Producer:               kafka.Producer{RequiredAcks: "all"},
+			}))
+		case sarama.BrokerSaramaProvider:
+			libs = append(libs, harness.Sarama(sarama.Config{
+				Brokers:     cfg.Brokers,
+				Producer:    sarama.Producer{RequiredAcks: "all"},
+				Transaction: sarama.Transaction{IDPrefix: "harness"},
+			}))
+		case confluent.BrokerConfluentProvider:
+			libs = append(libs, harness.Confluent(confluent.Config{
+				Brokers:  cfg.Brokers,
+				Producer: confluent.Producer{RequiredAcks: "all"},
+			}))
+		default:
+			return nil, cerror.NewF(ctx, cerror.KindInternal, "unknown library %s", name).LogError()
+		}
+	}
+
+	return libs, nil
+}
@@ -13,7 +13,9 @@ import (
 )
 
 var (
-	errNotEmptyTopicName = errors.New("not empty topic name")
+	errNotEmptyTopicName        = errors.New("not empty topic name")
+	errPauseNotSupported        = errors.New("pause is not supported by the provider")
+	errSubscriptionNotSupported = errors.New("subscription is not supported by the provider")
 )
 
 type QueueBroker interface {
@@ -22,8 +24,16 @@ type QueueBroker interface {
 	SetTracing(t tracing.Tracer)
 	GetIsTopicExists(ctx context.Context, topic string) (bool, error)
 	Send(ctx context.Context, topic string, e event.BaseEvent) error
+	// SendBatch returns *provider.BatchError if some events are not published
+	SendBatch(ctx context.Context, topic string, events []event.BaseEvent) error
 	Watch(ctx context.Context, topic string, fn provider.HandlerFn)
-	Stop()
+	// WatchSubscription returns an error if the provider doesn't implement provider.SubscriptionSyncer
+	WatchSubscription(ctx context.Context, fn provider.HandlerFn) error
+	// Pause returns an error if the provider doesn't implement provider.Pauser
+	Pause(ctx context.Context, topic string) error
+	Resume(ctx context.Context, topic string) error
+	// Stop returns an error if the provider isn't drained before ctx is done
+	Stop(ctx context.Context) error
 	Name() string
 }
 
@@ -67,13 +77,90 @@ func (b *Broker) Send(ctx context.Context, topic string, e event.BaseEvent) erro
 	return b.provider.Publish(ctx, topic, e)
 }
 
+// SendBatch publishes the events in one call if the provider supports batches, otherwise one by one
+func (b *Broker) SendBatch(ctx context.Context, topic string, events []event.BaseEvent) error {
+	log.DebugF(ctx, "[queueBroker] send batch: %s %s %d", b.provider.GetType(), topic, len(events))
+
+	for _, e := range events {
+		e.WithHeader(ctx)
+		e.WithMeta(b.metadata)
+	}
+
+	if bp, ok := b.provider.(provider.BatchPublisher); ok {
+		return bp.PublishBatch(ctx, topic, events)
+	}
+
+	return provider.PublishEach(ctx, topic, events, b.provider.Publish)
+}
+
+// Watch handles messages of the topic by fn. Use provider.Router as fn to handle several event types of the topic
 func (b *Broker) Watch(ctx context.Context, topic string, fn provider.HandlerFn) {
 	log.DebugF(ctx, "[queueBroker] sync messages %s", b.provider.GetType())
 	b.provider.Sync(ctx, topic, fn)
 }
 
-func (b *Broker) Stop() {
+// WatchSubscription handles messages of the topics configured in the provider by fn,
+// e.g. of kafka.Consumer.ListenTopics and ListenPattern. Use provider.Router as fn to route them by event type.
+func (b *Broker) WatchSubscription(ctx context.Context, fn provider.HandlerFn) error {
+	s, ok := b.provider.(provider.SubscriptionSyncer)
+	if !ok {
+		return cerror.NewF(ctx, cerror.KindInternal, "%s %s", errSubscriptionNotSupported.Error(), b.provider.GetType()).
+			LogError()
+	}
+
+	log.DebugF(ctx, "[queueBroker] sync subscription %s", b.provider.GetType())
+
+	return s.SyncSubscription(ctx, fn)
+}
+
+// Pause stops handling messages of the topic without tearing down its consumer,
+// e.g. while a dependency of handlers is down. Messages being handled are finished.
+func (b *Broker) Pause(ctx context.Context, topic string) error {
+	p, err := b.pauser(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.Pause(ctx, topic)
+
+	return nil
+}
+
+// Resume continues handling messages of the topic paused by Pause
+func (b *Broker) Resume(ctx context.Context, topic string) error {
+	p, err := b.pauser(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.Resume(ctx, topic)
+
+	return nil
+}
+
+func (b *Broker) pauser(ctx context.Context) (provider.Pauser, error) {
+	p, ok := b.provider.(provider.Pauser)
+	if !ok {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "%s %s", errPauseNotSupported.Error(), b.provider.GetType()).
+			LogError()
+	}
+
+	return p, nil
+}
+
+// Stop stops the provider. A provider implementing provider.Shutdowner stops fetching messages,
+// finishes and commits in-flight ones and reports those that aren't drained before ctx is done,
+// so a terminated service doesn't cause redelivery. Other providers are stopped without a deadline.
+func (b *Broker) Stop(ctx context.Context) error {
+	log.DebugF(ctx, "[queueBroker] stop %s", b.provider.GetType())
+
+	if s, ok := b.provider.(provider.Shutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+
 	b.provider.Stop()
+
+	return nil
 }
 
 func (b *Broker) Name() string {
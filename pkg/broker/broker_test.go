@@ -130,6 +130,65 @@ func TestBrokerKafkaSendError(t *testing.T) {
 	assert.Equal(t, bgCtx.Value(consts.HeaderXRequestID), e.Header.RequestID)
 }
 
+type MockedBatchProvider struct {
+	MockedProvider
+}
+
+func (mp *MockedBatchProvider) PublishBatch(ctx context.Context, topic string, events []event.BaseEvent) error {
+	args := mp.Called(ctx, topic, events)
+	return args.Error(0)
+}
+
+func TestBrokerSendBatch(t *testing.T) {
+	t.Parallel()
+
+	expMetadata := metadata.Meta{
+		Version: "0.0.1",
+	}
+
+	e1 := &event.WorkflowData{ID: "test-id-1"}
+	e2 := &event.WorkflowData{ID: "test-id-2"}
+
+	kafkaProvider := new(MockedProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+	kafkaProvider.On("Publish", bgCtx, "test-topic", e1).Return(nil)
+	kafkaProvider.On("Publish", bgCtx, "test-topic", e2).Return(errProviderSend)
+
+	bq := broker.New(kafkaProvider)
+	bq.SetMeta(expMetadata)
+	err := bq.SendBatch(bgCtx, "test-topic", []event.BaseEvent{e1, e2})
+	require.Error(t, err)
+
+	var be *provider.BatchError
+	require.ErrorAs(t, err, &be)
+	assert.Equal(t, []error{nil, errProviderSend}, be.Errs)
+	kafkaProvider.AssertNumberOfCalls(t, "Publish", 2)
+
+	for _, e := range []*event.WorkflowData{e1, e2} {
+		assert.Equal(t, bgCtx.Value(consts.HeaderXRequestID), e.Header.RequestID)
+		assert.Equal(t, expMetadata, e.GetMeta())
+	}
+}
+
+func TestBrokerSendBatchPublisher(t *testing.T) {
+	t.Parallel()
+
+	events := []event.BaseEvent{
+		&event.WorkflowData{ID: "test-id-1"},
+		&event.WorkflowData{ID: "test-id-2"},
+	}
+
+	kafkaProvider := new(MockedBatchProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+	kafkaProvider.On("PublishBatch", bgCtx, "test-topic", events).Return(nil)
+
+	bq := broker.New(kafkaProvider)
+	err := bq.SendBatch(bgCtx, "test-topic", events)
+	require.NoError(t, err)
+	kafkaProvider.AssertExpectations(t)
+	kafkaProvider.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestBrokerKafkaIsTopicExists(t *testing.T) {
 	t.Parallel()
 
@@ -153,3 +212,112 @@ func TestBrokerName(t *testing.T) {
 	bq := broker.New(kafkaProvider)
 	assert.Equal(t, kafka.BrokerKafkaProvider, bq.Name())
 }
+
+type MockedShutdownProvider struct {
+	MockedProvider
+}
+
+func (mp *MockedShutdownProvider) Shutdown(ctx context.Context) error {
+	args := mp.Called(ctx)
+	return args.Error(0)
+}
+
+func TestBrokerStop(t *testing.T) {
+	t.Parallel()
+
+	kafkaProvider := new(MockedProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+	kafkaProvider.On("Stop").Return()
+
+	bq := broker.New(kafkaProvider)
+	require.NoError(t, bq.Stop(bgCtx))
+	kafkaProvider.AssertCalled(t, "Stop")
+}
+
+func TestBrokerStopShutdowner(t *testing.T) {
+	t.Parallel()
+
+	errNotDrained := errors.New("not drained")
+
+	kafkaProvider := new(MockedShutdownProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+	kafkaProvider.On("Shutdown", bgCtx).Return(errNotDrained)
+
+	bq := broker.New(kafkaProvider)
+	require.ErrorIs(t, bq.Stop(bgCtx), errNotDrained)
+	kafkaProvider.AssertNotCalled(t, "Stop")
+}
+
+type MockedPauseProvider struct {
+	MockedProvider
+}
+
+func (mp *MockedPauseProvider) Pause(ctx context.Context, topic string) {
+	_ = mp.Called(ctx, topic)
+}
+
+func (mp *MockedPauseProvider) Resume(ctx context.Context, topic string) {
+	_ = mp.Called(ctx, topic)
+}
+
+func (mp *MockedPauseProvider) Paused(topic string) bool {
+	args := mp.Called(topic)
+	return args.Bool(0)
+}
+
+func TestBrokerPauseResume(t *testing.T) {
+	t.Parallel()
+
+	kafkaProvider := new(MockedPauseProvider)
+	kafkaProvider.On("Pause", bgCtx, "test-topic").Return()
+	kafkaProvider.On("Resume", bgCtx, "test-topic").Return()
+
+	bq := broker.New(kafkaProvider)
+	require.NoError(t, bq.Pause(bgCtx, "test-topic"))
+	require.NoError(t, bq.Resume(bgCtx, "test-topic"))
+	kafkaProvider.AssertExpectations(t)
+}
+
+func TestBrokerPauseNotSupported(t *testing.T) {
+	t.Parallel()
+
+	kafkaProvider := new(MockedProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+
+	bq := broker.New(kafkaProvider)
+	require.Error(t, bq.Pause(bgCtx, "test-topic"))
+	require.Error(t, bq.Resume(bgCtx, "test-topic"))
+}
+
+type MockedSubscriptionProvider struct {
+	MockedProvider
+}
+
+func (mp *MockedSubscriptionProvider) SyncSubscription(ctx context.Context, fn provider.HandlerFn) error {
+	args := mp.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func TestBrokerWatchSubscription(t *testing.T) {
+	t.Parallel()
+
+	fn := provider.NewRouter()
+
+	kafkaProvider := new(MockedSubscriptionProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+	kafkaProvider.On("SyncSubscription", bgCtx, fn).Return(nil)
+
+	bq := broker.New(kafkaProvider)
+	require.NoError(t, bq.WatchSubscription(bgCtx, fn))
+	kafkaProvider.AssertExpectations(t)
+}
+
+func TestBrokerWatchSubscriptionNotSupported(t *testing.T) {
+	t.Parallel()
+
+	kafkaProvider := new(MockedProvider)
+	kafkaProvider.On("GetType").Return(kafka.BrokerKafkaProvider)
+
+	bq := broker.New(kafkaProvider)
+	require.Error(t, bq.WatchSubscription(bgCtx, provider.NewRouter()))
+}
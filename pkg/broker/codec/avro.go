@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+	"sync"
+)
+
+// Avro encodes events by Avro schemas of the registry. An event is encoded through its JSON representation
+// by the latest schema of the subject and decoded by the schema of the record, so the JSON fields of the event
+// must match the schema fields. Fields unknown to the event are dropped when it's decoded and missing ones are left empty,
+// so compatible changes of the schema don't break producers and consumers of other versions.
+type Avro struct {
+	schemas *schemaCache
+	mx      sync.Mutex
+	parsed  map[int]*avroSchema
+}
+
+func NewAvro(reg Registry) *Avro {
+	return &Avro{
+		schemas: newSchemaCache(reg, 0),
+		parsed:  make(map[int]*avroSchema),
+	}
+}
+
+func (c *Avro) Name() string {
+	return NameAvro
+}
+
+func (c *Avro) Encode(ctx context.Context, topic string, e event.BaseEvent) ([]byte, error) {
+	s, err := c.schemas.latestOf(ctx, Subject(topic), SchemaTypeAvro)
+	if err != nil {
+		return nil, err
+	}
+
+	as, err := c.parse(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(e.ToByte()))
+	dec.UseNumber()
+
+	if err := dec.Decode(&v); err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "[avro] encode event %s. %s", e.GetID(), err.Error()).LogError()
+	}
+
+	b, err := as.encode(appendHeader(nil, s.ID), v)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation,
+			"[avro] encode event %s by schema %d. %s", e.GetID(), s.ID, err.Error()).LogError()
+	}
+
+	return b, nil
+}
+
+func (c *Avro) Decode(ctx context.Context, msg event.Message, e event.BaseEvent) error {
+	id, payload, err := parseHeader(msg.Value)
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "[avro] decode record. %s", err.Error()).LogError()
+	}
+
+	s, err := c.schemas.byIDOf(ctx, id, SchemaTypeAvro)
+	if err != nil {
+		return err
+	}
+
+	as, err := c.parse(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	v, err := as.decode(payload)
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation,
+			"[avro] decode record by schema %d. %s", id, err.Error()).LogError()
+	}
+
+	if msg.Value, err = json.Marshal(v); err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "[avro] decode record. %s", err.Error()).LogError()
+	}
+
+	if err := e.Unmarshal(msg); err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "[avro] decode record. %s", err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// parse returns the parsed schema. Schemas are parsed once by ID.
+func (c *Avro) parse(ctx context.Context, s Schema) (*avroSchema, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if as, ok := c.parsed[s.ID]; ok {
+		return as, nil
+	}
+
+	as, err := parseAvroSchema(s.Schema)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "[avro] schema %d. %s", s.ID, err.Error()).LogError()
+	}
+
+	c.parsed[s.ID] = as
+
+	return as, nil
+}
@@ -0,0 +1,94 @@
+package codec_test
+
+import (
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestAvro(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	id, err := reg.Register(bgCtx, codec.Subject(testTopic), codec.Schema{Type: codec.SchemaTypeAvro, Schema: workflowSchemaV1})
+	require.NoError(t, err)
+
+	c := codec.NewAvro(reg)
+	e := newWorkflowEvent()
+
+	b, err := c.Encode(bgCtx, testTopic, e)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, byte(id)}, b[:5])
+	assert.True(t, codec.IsWireFormat(b))
+
+	var decoded event.WorkflowData
+	require.NoError(t, c.Decode(bgCtx, event.Message{Value: b}, &decoded))
+
+	assert.Equal(t, e.ID, decoded.ID)
+	assert.Equal(t, e.Header, decoded.Header)
+	assert.Equal(t, e.Metadata, decoded.Metadata)
+	assert.Equal(t, e.Workflow.Step, decoded.Workflow.Step)
+	assert.JSONEq(t, string(e.Workflow.StepPayload), string(decoded.Workflow.StepPayload))
+}
+
+func TestAvroSchemaEvolution(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	subject := codec.Subject(testTopic)
+
+	_, err := reg.Register(bgCtx, subject, codec.Schema{Schema: workflowSchemaV1})
+	require.NoError(t, err)
+
+	v1, err := codec.NewAvro(reg).Encode(bgCtx, testTopic, newWorkflowEvent())
+	require.NoError(t, err)
+
+	// v2 adds a field with a default, which the event doesn't have
+	v2Schema := strings.Replace(workflowSchemaV1, `{"name": "Debug", "type": "boolean"},`,
+		`{"name": "Debug", "type": "boolean"}, {"name": "priority", "type": "int", "default": 1},`, 1)
+	v2ID, err := reg.Register(bgCtx, subject, codec.Schema{Schema: v2Schema})
+	require.NoError(t, err)
+
+	c := codec.NewAvro(reg)
+
+	v2, err := c.Encode(bgCtx, testTopic, newWorkflowEvent())
+	require.NoError(t, err)
+	assert.Equal(t, byte(v2ID), v2[4])
+	assert.Greater(t, len(v2), len(v1))
+
+	// records of both versions are decoded by their own schemas
+	for _, b := range [][]byte{v1, v2} {
+		var decoded event.WorkflowData
+		require.NoError(t, c.Decode(bgCtx, event.Message{Value: b}, &decoded))
+		assert.Equal(t, "event-id", decoded.ID)
+	}
+}
+
+func TestAvroErrors(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	c := codec.NewAvro(reg)
+
+	_, err := c.Encode(bgCtx, testTopic, newWorkflowEvent())
+	assert.Equal(t, cerror.KindNotExist, err.(*cerror.CError).Kind())
+
+	_, err = reg.Register(bgCtx, codec.Subject(testTopic), codec.Schema{Type: codec.SchemaTypeJSON, Schema: "{}"})
+	require.NoError(t, err)
+
+	_, err = c.Encode(bgCtx, testTopic, newWorkflowEvent())
+	assert.Equal(t, cerror.KindBadValidation, err.(*cerror.CError).Kind())
+
+	var decoded event.WorkflowData
+
+	err = c.Decode(bgCtx, event.Message{Value: []byte(`{"id":"event-id"}`)}, &decoded)
+	assert.Equal(t, cerror.KindBadValidation, err.(*cerror.CError).Kind())
+
+	err = c.Decode(bgCtx, event.Message{Value: []byte{0, 0, 0, 0, 42, 2}}, &decoded)
+	assert.Equal(t, cerror.KindNotExist, err.(*cerror.CError).Kind())
+}
@@ -0,0 +1,452 @@
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro"
+)
+
+const (
+	// logicalJSON is a custom logical type of strings holding JSON, e.g. json.RawMessage fields.
+	// Other Avro implementations ignore it and read such fields as strings.
+	// It's supported for record fields of the string type or of unions with it.
+	logicalJSON = "json"
+	// propLogicalType is a field property marking fields of the json logical type.
+	// The parser keeps properties of fields only, so the logical type is copied to the field before parsing.
+	propLogicalType = "logicalType"
+)
+
+// avroSchema is a parsed Avro schema. Events are encoded through their JSON representation,
+// so generic JSON values are converted to the values of the schema types and back.
+type avroSchema struct {
+	schema avro.Schema
+}
+
+// parseAvroSchema parses the schema in the JSON form
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, err
+	}
+
+	markJSONFields(raw)
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// a cache per schema, so names of other schemas aren't resolved
+	s, err := avro.ParseWithCache(string(b), "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &avroSchema{schema: s}, nil
+}
+
+// markJSONFields copies the json logical type of field types to the fields
+func markJSONFields(raw interface{}) {
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, item := range v {
+			markJSONFields(item)
+		}
+	case map[string]interface{}:
+		fields, _ := v["fields"].([]interface{})
+		for _, f := range fields {
+			if field, ok := f.(map[string]interface{}); ok && isJSONType(field["type"]) {
+				field[propLogicalType] = logicalJSON
+			}
+		}
+
+		for _, key := range []string{"type", "fields", "items", "values"} {
+			markJSONFields(v[key])
+		}
+	}
+}
+
+func isJSONType(raw interface{}) bool {
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if isJSONType(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		return v["type"] == string(avro.String) && v[propLogicalType] == logicalJSON
+	}
+
+	return false
+}
+
+// encode appends the binary encoding of the JSON value
+func (s *avroSchema) encode(dst []byte, v interface{}) ([]byte, error) {
+	native, err := toAvro(s.schema, v, false)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := avro.Marshal(s.schema, native)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dst, b...), nil
+}
+
+// decode decodes the binary encoding into a JSON value
+func (s *avroSchema) decode(data []byte) (interface{}, error) {
+	// the reader reports a short record as io.EOF, which avro.Unmarshal ignores
+	r := avro.NewReader(nil, 0).Reset(data)
+
+	native := r.ReadNext(s.schema)
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	return fromAvro(s.schema, native, false)
+}
+
+// toAvro converts a JSON value to the value of the schema type.
+// isJSON is set for values of the json logical type.
+//
+//nolint:gocyclo
+func toAvro(schema avro.Schema, v interface{}, isJSON bool) (interface{}, error) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return toAvro(s.Schema(), v, isJSON)
+	case *avro.UnionSchema:
+		return toAvroUnion(s, v, isJSON)
+	case *avro.RecordSchema:
+		return toAvroRecord(s, v)
+	case *avro.ArraySchema:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, typeError(schema, v)
+		}
+
+		arr := make([]interface{}, len(items))
+
+		for i, item := range items {
+			var err error
+			if arr[i], err = toAvro(s.Items(), item, false); err != nil {
+				return nil, err
+			}
+		}
+
+		return arr, nil
+	case *avro.MapSchema:
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, typeError(schema, v)
+		}
+
+		m := make(map[string]interface{}, len(values))
+
+		for k, val := range values {
+			var err error
+			if m[k], err = toAvro(s.Values(), val, false); err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+	case *avro.FixedSchema:
+		b, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b) != s.Size() {
+			return nil, fmt.Errorf("avro fixed %s of %d bytes expected, got %d", s.FullName(), s.Size(), len(b))
+		}
+
+		// fixed values are encoded from byte arrays of the fixed size
+		arr := reflect.New(reflect.ArrayOf(s.Size(), reflect.TypeOf(byte(0)))).Elem()
+		reflect.Copy(arr, reflect.ValueOf(b))
+
+		return arr.Interface(), nil
+	}
+
+	return toAvroPrimitive(schema, v, isJSON)
+}
+
+//nolint:gocyclo
+func toAvroPrimitive(schema avro.Schema, v interface{}, isJSON bool) (interface{}, error) {
+	if isJSON && schema.Type() == avro.String {
+		b, err := json.Marshal(v)
+
+		return string(b), err
+	}
+
+	switch val := v.(type) {
+	case nil:
+		if schema.Type() == avro.Null {
+			return nil, nil
+		}
+	case bool:
+		if schema.Type() == avro.Boolean {
+			return val, nil
+		}
+	case json.Number:
+		switch schema.Type() {
+		case avro.Int:
+			n, err := val.Int64()
+			return int(n), err
+		case avro.Long:
+			return val.Int64()
+		case avro.Float:
+			f, err := val.Float64()
+			return float32(f), err
+		case avro.Double:
+			return val.Float64()
+		}
+	case string:
+		switch schema.Type() {
+		case avro.String:
+			return val, nil
+		case avro.Bytes:
+			return toBytes(val)
+		case avro.Long:
+			if isTimestamp(schema) {
+				return time.Parse(time.RFC3339Nano, val)
+			}
+		case avro.Enum:
+			if hasSymbol(schema.(*avro.EnumSchema), val) {
+				return val, nil
+			}
+
+			return nil, fmt.Errorf("unknown symbol %s of avro enum %s", val, schema.(*avro.EnumSchema).FullName())
+		}
+	}
+
+	return nil, typeError(schema, v)
+}
+
+func toAvroRecord(s *avro.RecordSchema, v interface{}) (interface{}, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, typeError(s, v)
+	}
+
+	rec := make(map[string]interface{}, len(s.Fields()))
+
+	for _, f := range s.Fields() {
+		val, ok := obj[f.Name()]
+		if !ok {
+			// missing fields get defaults of the schema
+			continue
+		}
+
+		native, err := toAvro(f.Type(), val, isJSONField(f))
+		if err != nil {
+			return nil, fmt.Errorf("field %s of avro record %s. %w", f.Name(), s.FullName(), err)
+		}
+
+		rec[f.Name()] = native
+	}
+
+	return rec, nil
+}
+
+// toAvroUnion returns the value of the first branch of the union matching the JSON value
+// in the form of a map from the branch name to the value
+func toAvroUnion(s *avro.UnionSchema, v interface{}, isJSON bool) (interface{}, error) {
+	if v == nil && s.Nullable() {
+		return nil, nil
+	}
+
+	for _, b := range s.Types() {
+		if !matches(b, v, isJSON) {
+			continue
+		}
+
+		native, err := toAvro(b, v, isJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{branchName(b): native}, nil
+	}
+
+	return nil, typeError(s, v)
+}
+
+// fromAvro converts the value of the schema type to a JSON value
+func fromAvro(schema avro.Schema, v interface{}, isJSON bool) (interface{}, error) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return fromAvro(s.Schema(), v, isJSON)
+	case *avro.UnionSchema:
+		branches, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+
+		for _, b := range s.Types() {
+			if val, ok := branches[branchName(b)]; ok {
+				return fromAvro(b, val, isJSON)
+			}
+		}
+
+		return nil, fmt.Errorf("unknown branch of avro union %v", v)
+	case *avro.RecordSchema:
+		obj, _ := v.(map[string]interface{})
+
+		for _, f := range s.Fields() {
+			val, err := fromAvro(f.Type(), obj[f.Name()], isJSONField(f))
+			if err != nil {
+				return nil, err
+			}
+
+			obj[f.Name()] = val
+		}
+
+		return obj, nil
+	case *avro.ArraySchema:
+		items, _ := v.([]interface{})
+
+		for i, item := range items {
+			val, err := fromAvro(s.Items(), item, false)
+			if err != nil {
+				return nil, err
+			}
+
+			items[i] = val
+		}
+
+		return items, nil
+	case *avro.MapSchema:
+		values, _ := v.(map[string]interface{})
+
+		for k, item := range values {
+			val, err := fromAvro(s.Values(), item, false)
+			if err != nil {
+				return nil, err
+			}
+
+			values[k] = val
+		}
+
+		return values, nil
+	}
+
+	if str, ok := v.(string); ok && isJSON {
+		return json.RawMessage(str), nil
+	}
+
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano), nil
+	}
+
+	return v, nil
+}
+
+// matches reports whether the JSON value may be encoded by the branch of a union
+//
+//nolint:gocyclo
+func matches(schema avro.Schema, v interface{}, isJSON bool) bool {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+
+	typ := schema.Type()
+	if isJSON && typ == avro.String {
+		return v != nil
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return typ == avro.Null
+	case bool:
+		return typ == avro.Boolean
+	case json.Number:
+		switch typ {
+		case avro.Int, avro.Long:
+			_, err := val.Int64()
+			return err == nil
+		case avro.Float, avro.Double:
+			return true
+		}
+	case string:
+		switch typ {
+		case avro.String, avro.Bytes, avro.Fixed:
+			return true
+		case avro.Long:
+			return isTimestamp(schema)
+		case avro.Enum:
+			return hasSymbol(schema.(*avro.EnumSchema), val)
+		}
+	case []interface{}:
+		return typ == avro.Array
+	case map[string]interface{}:
+		return typ == avro.Record || typ == avro.Map
+	}
+
+	return false
+}
+
+// branchName returns the name of the union branch the way the avro package names union values
+func branchName(schema avro.Schema) string {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+
+	if n, ok := schema.(avro.NamedSchema); ok {
+		return n.FullName()
+	}
+
+	if ls := logicalOf(schema); ls != nil {
+		return string(schema.Type()) + "." + string(ls.Type())
+	}
+
+	return string(schema.Type())
+}
+
+func logicalOf(schema avro.Schema) avro.LogicalSchema {
+	if lts, ok := schema.(avro.LogicalTypeSchema); ok {
+		return lts.Logical()
+	}
+
+	return nil
+}
+
+func isTimestamp(schema avro.Schema) bool {
+	ls := logicalOf(schema)
+
+	return ls != nil && (ls.Type() == avro.TimestampMillis || ls.Type() == avro.TimestampMicros)
+}
+
+func isJSONField(f *avro.Field) bool {
+	return f.Prop(propLogicalType) == logicalJSON
+}
+
+func hasSymbol(s *avro.EnumSchema, symbol string) bool {
+	for _, sym := range s.Symbols() {
+		if sym == symbol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toBytes decodes base64 strings as encoding/json encodes byte slices
+func toBytes(v interface{}) ([]byte, error) {
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("avro bytes expected, got %T", v)
+	}
+
+	return base64.StdEncoding.DecodeString(str)
+}
+
+func typeError(schema avro.Schema, v interface{}) error {
+	return fmt.Errorf("avro %s expected, got %T", schema.Type(), v)
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func jsonValue(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&v))
+
+	return v
+}
+
+func TestAvroSchemaTypes(t *testing.T) {
+	t.Parallel()
+
+	schema := `{"type": "record", "name": "All", "namespace": "test", "fields": [
+		{"name": "bool", "type": "boolean"},
+		{"name": "int", "type": "int"},
+		{"name": "long", "type": "long"},
+		{"name": "float", "type": "float"},
+		{"name": "double", "type": "double"},
+		{"name": "bytes", "type": "bytes"},
+		{"name": "string", "type": "string"},
+		{"name": "enum", "type": {"type": "enum", "name": "Color", "symbols": ["RED", "GREEN"]}},
+		{"name": "array", "type": {"type": "array", "items": "long"}},
+		{"name": "map", "type": {"type": "map", "values": "string"}},
+		{"name": "fixed", "type": {"type": "fixed", "name": "Pair", "size": 2}},
+		{"name": "union", "type": ["null", "string", "Color"]},
+		{"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "json", "type": {"type": "string", "logicalType": "json"}},
+		{"name": "opt_json", "type": ["null", {"type": "string", "logicalType": "json"}], "default": null},
+		{"name": "next", "type": ["null", "All"], "default": null},
+		{"name": "defaulted", "type": "string", "default": "def"}
+	]}`
+
+	as, err := parseAvroSchema(schema)
+	require.NoError(t, err)
+
+	in := jsonValue(t, `{
+		"bool": true, "int": -3, "long": 1234567890123, "float": 1.5, "double": -2.25,
+		"bytes": "AQID", "string": "str", "enum": "GREEN", "array": [1, 2, 3], "map": {"k": "v"},
+		"fixed": "AQI=", "union": "GREEN", "time": "2023-01-24T12:50:09.123Z", "json": {"a": [1]},
+		"opt_json": [true], "next": {"bool": false, "int": 0, "long": 0, "float": 0, "double": 0, "bytes": "",
+			"string": "", "enum": "RED", "array": [], "map": {}, "fixed": "AAA=", "union": null,
+			"time": "1970-01-01T00:00:00Z", "json": null}
+	}`)
+
+	b, err := as.encode(nil, in)
+	require.NoError(t, err)
+
+	out, err := as.decode(b)
+	require.NoError(t, err)
+
+	outJSON, err := json.Marshal(out)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"bool": true, "int": -3, "long": 1234567890123, "float": 1.5, "double": -2.25,
+		"bytes": "AQID", "string": "str", "enum": "GREEN", "array": [1, 2, 3], "map": {"k": "v"},
+		"fixed": "AQI=", "union": "GREEN", "time": "2023-01-24T12:50:09.123Z", "json": {"a": [1]},
+		"opt_json": [true], "next": {"bool": false, "int": 0, "long": 0, "float": 0, "double": 0, "bytes": "",
+			"string": "", "enum": "RED", "array": [], "map": {}, "fixed": "AAA=", "union": null,
+			"time": "1970-01-01T00:00:00Z", "json": null, "opt_json": null, "next": null, "defaulted": "def"},
+		"defaulted": "def"
+	}`, string(outJSON))
+}
+
+func TestAvroSchemaEncoding(t *testing.T) {
+	t.Parallel()
+
+	as, err := parseAvroSchema(`{"type": "record", "name": "R", "fields": [
+		{"name": "a", "type": "long"}, {"name": "b", "type": "string"}, {"name": "c", "type": ["null", "int"]}]}`)
+	require.NoError(t, err)
+
+	// values of the Avro specification: zigzag varints and length prefixed strings
+	b, err := as.encode(nil, jsonValue(t, `{"a": -64, "b": "foo", "c": 1}`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x7f, 0x06, 'f', 'o', 'o', 0x02, 0x02}, b)
+
+	_, err = as.decode(b[:3])
+	assert.Error(t, err)
+
+	_, err = as.encode(nil, jsonValue(t, `{"a": 1}`))
+	assert.EqualError(t, err, "avro: missing required field b")
+
+	_, err = as.encode(nil, jsonValue(t, `{"a": "1", "b": ""}`))
+	assert.Error(t, err)
+
+	_, err = parseAvroSchema(`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "Unknown"}]}`)
+	assert.EqualError(t, err, "avro: unknown type: Unknown")
+}
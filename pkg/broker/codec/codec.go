@@ -0,0 +1,73 @@
+// Package codec serializes broker events. Codecs with a schema registry write the Confluent wire format:
+// a zero magic byte, a 4-byte big-endian schema ID and the payload, so records are readable by other
+// Confluent compatible clients and the schema of each record can be checked for compatibility in the registry.
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+)
+
+const (
+	NameJSON     = "json"
+	NameAvro     = "avro"
+	NameProtobuf = "protobuf"
+
+	magicByte  = byte(0)
+	headerSize = 5
+
+	valueSubjectSuffix = "-value"
+)
+
+var errShortRecord = errors.New("record is shorter than wire format header")
+
+// Codec encodes events into record values and decodes them back
+type Codec interface {
+	Name() string
+	// Encode encodes the event published to the topic
+	Encode(ctx context.Context, topic string, e event.BaseEvent) ([]byte, error)
+	// Decode decodes the record into the event
+	Decode(ctx context.Context, msg event.Message, e event.BaseEvent) error
+}
+
+// Subject returns a registry subject of values of the topic by the topic name strategy, e.g. orders-value
+func Subject(topic string) string {
+	return topic + valueSubjectSuffix
+}
+
+// ProtoMessage is an event serialized by protocol buffers.
+// Events of the event package implement it by their protobuf schemas, e.g. event.WorkflowProtoSchema,
+// which are to be registered for the subjects of topics. Other events usually delegate the methods
+// to Marshal and Unmarshal of messages generated by gogo/protobuf or to proto.Marshal and proto.Unmarshal.
+type ProtoMessage interface {
+	MarshalProto() ([]byte, error)
+	UnmarshalProto(data []byte) error
+}
+
+// IsWireFormat returns true if data starts with the wire format header
+func IsWireFormat(data []byte) bool {
+	return len(data) >= headerSize && data[0] == magicByte
+}
+
+// appendHeader appends the wire format header with the schema ID
+func appendHeader(dst []byte, schemaID int) []byte {
+	dst = append(dst, magicByte)
+
+	return binary.BigEndian.AppendUint32(dst, uint32(schemaID))
+}
+
+// parseHeader returns the schema ID and the payload of a record in the wire format
+func parseHeader(data []byte) (int, []byte, error) {
+	if len(data) < headerSize {
+		return 0, nil, errShortRecord
+	}
+
+	if data[0] != magicByte {
+		return 0, nil, fmt.Errorf("unknown magic byte %d", data[0])
+	}
+
+	return int(binary.BigEndian.Uint32(data[1:headerSize])), data[headerSize:], nil
+}
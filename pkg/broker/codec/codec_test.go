@@ -0,0 +1,64 @@
+package codec_test
+
+import (
+	"context"
+	"encoding/json"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cmd/metadata"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTopic = "workflow"
+
+var bgCtx = context.Background()
+
+const workflowSchemaV1 = `{
+  "type": "record",
+  "name": "WorkflowData",
+  "namespace": "kafka.polygon",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "header", "type": {"type": "record", "name": "Header", "fields": [
+      {"name": "request_id", "type": "string"}
+    ]}},
+    {"name": "workflow", "type": {"type": "record", "name": "Workflow", "fields": [
+      {"name": "id", "type": "string"},
+      {"name": "schema", "type": "string"},
+      {"name": "step", "type": "string"},
+      {"name": "step_payload", "type": {"type": "string", "logicalType": "json"}}
+    ]}},
+    {"name": "Debug", "type": "boolean"},
+    {"name": "metadata", "type": {"type": "record", "name": "Meta", "fields": [
+      {"name": "version", "type": "string"},
+      {"name": "module", "type": "string"},
+      {"name": "build_date", "type": "string"}
+    ]}}
+  ]
+}`
+
+func newFileRegistry(t *testing.T) *codec.FileRegistry {
+	t.Helper()
+
+	reg, err := codec.NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	require.NoError(t, err)
+
+	return reg
+}
+
+func newWorkflowEvent() *event.WorkflowData {
+	return &event.WorkflowData{
+		ID:     "event-id",
+		Header: event.Header{RequestID: "request-id"},
+		Workflow: event.Workflow{
+			ID:          "workflow-id",
+			Schema:      "schema",
+			Step:        "step",
+			StepPayload: json.RawMessage(`{"amount":10,"tags":["a","b"]}`),
+		},
+		Metadata: metadata.Meta{Module: "workflow", Version: "4d7f728"},
+	}
+}
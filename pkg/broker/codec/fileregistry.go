@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-polygon/pkg/cerror"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileRegistry is a registry stored in a local JSON file. It stands in for the schema registry
+// in tests and local runs, so services exchange schemas without the registry server.
+type FileRegistry struct {
+	path    string
+	mx      sync.Mutex
+	schemas []Schema
+}
+
+type fileRegistryData struct {
+	Schemas []Schema `json:"schemas"`
+}
+
+// NewFileRegistry loads schemas from the file. The file is created on the first registration if it doesn't exist.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	fr := &FileRegistry{path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fr, nil
+	}
+
+	if err != nil {
+		return nil, cerror.NewF(context.Background(), cerror.KindInternal,
+			"[file registry] read %s. %s", path, err.Error()).LogError()
+	}
+
+	var data fileRegistryData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, cerror.NewF(context.Background(), cerror.KindInternal,
+			"[file registry] parse %s. %s", path, err.Error()).LogError()
+	}
+
+	for _, s := range data.Schemas {
+		fr.schemas = append(fr.schemas, s.withDefaultType())
+	}
+
+	return fr, nil
+}
+
+func (fr *FileRegistry) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	fr.mx.Lock()
+	defer fr.mx.Unlock()
+
+	s = s.withDefaultType()
+	maxID, version := 0, 1
+
+	for _, rs := range fr.schemas {
+		if rs.ID > maxID {
+			maxID = rs.ID
+		}
+
+		if rs.Subject != subject {
+			continue
+		}
+
+		if rs.Schema == s.Schema && rs.Type == s.Type {
+			return rs.ID, nil
+		}
+
+		if rs.Version >= version {
+			version = rs.Version + 1
+		}
+	}
+
+	s.ID = maxID + 1
+	s.Subject = subject
+	s.Version = version
+
+	schemas := append(fr.schemas[:len(fr.schemas):len(fr.schemas)], s)
+	if err := fr.save(ctx, schemas); err != nil {
+		return 0, err
+	}
+
+	fr.schemas = schemas
+
+	return s.ID, nil
+}
+
+func (fr *FileRegistry) Latest(ctx context.Context, subject string) (Schema, error) {
+	fr.mx.Lock()
+	defer fr.mx.Unlock()
+
+	var (
+		latest Schema
+		found  bool
+	)
+
+	for _, s := range fr.schemas {
+		if s.Subject == subject && s.Version > latest.Version {
+			latest, found = s, true
+		}
+	}
+
+	if !found {
+		return Schema{}, cerror.NewF(ctx, cerror.KindNotExist, "[file registry] subject %s not found", subject).LogError()
+	}
+
+	return latest, nil
+}
+
+func (fr *FileRegistry) ByID(ctx context.Context, id int) (Schema, error) {
+	fr.mx.Lock()
+	defer fr.mx.Unlock()
+
+	for _, s := range fr.schemas {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+
+	return Schema{}, cerror.NewF(ctx, cerror.KindNotExist, "[file registry] schema %d not found", id).LogError()
+}
+
+// save writes the schemas to a temporary file and renames it, so readers never see a partial file
+func (fr *FileRegistry) save(ctx context.Context, schemas []Schema) error {
+	b, err := json.MarshalIndent(fileRegistryData{Schemas: schemas}, "", "  ")
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fr.path), filepath.Base(fr.path)+".*")
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "[file registry] save %s. %s", fr.path, err.Error()).LogError()
+	}
+
+	_, err = tmp.Write(b)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), fr.path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return cerror.NewF(ctx, cerror.KindInternal, "[file registry] save %s. %s", fr.path, err.Error()).LogError()
+	}
+
+	return nil
+}
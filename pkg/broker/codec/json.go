@@ -0,0 +1,69 @@
+package codec
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+)
+
+// JSON encodes events by their own JSON serialization.
+// With a registry values are prefixed by the wire format header with the latest JSON schema of the subject.
+// Values with and without the header are decoded, so consumers can be switched before producers.
+type JSON struct {
+	schemas *schemaCache
+}
+
+// NewJSON creates a JSON codec. The registry is optional.
+func NewJSON(reg Registry) *JSON {
+	c := &JSON{}
+	if reg != nil {
+		c.schemas = newSchemaCache(reg, 0)
+	}
+
+	return c
+}
+
+func (c *JSON) Name() string {
+	return NameJSON
+}
+
+func (c *JSON) Encode(ctx context.Context, topic string, e event.BaseEvent) ([]byte, error) {
+	b := e.ToByte()
+	if b == nil {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "[json] encode event %s", e.GetID()).LogError()
+	}
+
+	if c.schemas == nil {
+		return b, nil
+	}
+
+	s, err := c.schemas.latestOf(ctx, Subject(topic), SchemaTypeJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(appendHeader(make([]byte, 0, headerSize+len(b)), s.ID), b...), nil
+}
+
+func (c *JSON) Decode(ctx context.Context, msg event.Message, e event.BaseEvent) error {
+	if IsWireFormat(msg.Value) {
+		id, payload, err := parseHeader(msg.Value)
+		if err != nil {
+			return cerror.NewF(ctx, cerror.KindBadValidation, "[json] decode record. %s", err.Error()).LogError()
+		}
+
+		if c.schemas != nil {
+			if _, err := c.schemas.byIDOf(ctx, id, SchemaTypeJSON); err != nil {
+				return err
+			}
+		}
+
+		msg.Value = payload
+	}
+
+	if err := e.Unmarshal(msg); err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "[json] decode record. %s", err.Error()).LogError()
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package codec_test
+
+import (
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	c := codec.NewJSON(nil)
+	e := newWorkflowEvent()
+
+	b, err := c.Encode(bgCtx, testTopic, e)
+	require.NoError(t, err)
+	assert.Equal(t, e.ToByte(), b)
+
+	var decoded event.WorkflowData
+	require.NoError(t, c.Decode(bgCtx, event.Message{Value: b}, &decoded))
+	assert.Equal(t, e.ToByte(), decoded.ToByte())
+}
+
+func TestJSONWithRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	id, err := reg.Register(bgCtx, codec.Subject(testTopic), codec.Schema{Type: codec.SchemaTypeJSON, Schema: `{"type":"object"}`})
+	require.NoError(t, err)
+
+	c := codec.NewJSON(reg)
+	e := newWorkflowEvent()
+
+	b, err := c.Encode(bgCtx, testTopic, e)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0, 0, 0, 0, byte(id)}, e.ToByte()...), b)
+
+	// records with and without the header are decoded
+	for _, value := range [][]byte{b, e.ToByte()} {
+		var decoded event.WorkflowData
+		require.NoError(t, c.Decode(bgCtx, event.Message{Value: value}, &decoded))
+		assert.Equal(t, e.ToByte(), decoded.ToByte())
+	}
+}
@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+)
+
+var errInvalidMessageIndexes = errors.New("invalid message indexes")
+
+// Protobuf encodes events implementing ProtoMessage. Values are prefixed by the wire format header
+// with the latest protobuf schema of the subject and the message indexes of the event in the schema.
+// Events are expected to be the first message of their schemas, which is written as a single zero byte.
+type Protobuf struct {
+	schemas *schemaCache
+}
+
+func NewProtobuf(reg Registry) *Protobuf {
+	return &Protobuf{schemas: newSchemaCache(reg, 0)}
+}
+
+func (c *Protobuf) Name() string {
+	return NameProtobuf
+}
+
+func (c *Protobuf) Encode(ctx context.Context, topic string, e event.BaseEvent) ([]byte, error) {
+	pm, ok := e.(ProtoMessage)
+	if !ok {
+		return nil, cerror.NewF(ctx, cerror.KindInternal,
+			"[protobuf] event %T isn't a protobuf message", e).LogError()
+	}
+
+	s, err := c.schemas.latestOf(ctx, Subject(topic), SchemaTypeProtobuf)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := pm.MarshalProto()
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindInternal,
+			"[protobuf] encode event %s. %s", e.GetID(), err.Error()).LogError()
+	}
+
+	b := appendHeader(make([]byte, 0, headerSize+1+len(payload)), s.ID)
+	// message indexes [0] of the first message
+	b = append(b, 0)
+
+	return append(b, payload...), nil
+}
+
+func (c *Protobuf) Decode(ctx context.Context, msg event.Message, e event.BaseEvent) error {
+	pm, ok := e.(ProtoMessage)
+	if !ok {
+		return cerror.NewF(ctx, cerror.KindInternal, "[protobuf] event %T isn't a protobuf message", e).LogError()
+	}
+
+	id, payload, err := parseHeader(msg.Value)
+	if err == nil {
+		payload, err = skipMessageIndexes(payload)
+	}
+
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "[protobuf] decode record. %s", err.Error()).LogError()
+	}
+
+	if _, err := c.schemas.byIDOf(ctx, id, SchemaTypeProtobuf); err != nil {
+		return err
+	}
+
+	if err := pm.UnmarshalProto(payload); err != nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation,
+			"[protobuf] decode record by schema %d. %s", id, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// skipMessageIndexes skips the count and the zigzag varint indexes of the message in the schema
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	cnt, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errInvalidMessageIndexes
+	}
+
+	data = data[n:]
+
+	for ; cnt > 0; cnt-- {
+		if _, n = binary.Varint(data); n <= 0 {
+			return nil, errInvalidMessageIndexes
+		}
+
+		data = data[n:]
+	}
+
+	return data, nil
+}
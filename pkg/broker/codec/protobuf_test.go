@@ -0,0 +1,95 @@
+package codec_test
+
+import (
+	"errors"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+var (
+	_ codec.ProtoMessage = (*event.WorkflowData)(nil)
+	_ codec.ProtoMessage = (*event.DebeziumData)(nil)
+	_ codec.ProtoMessage = (*event.MinioData)(nil)
+	_ codec.ProtoMessage = (*event.NotificationData)(nil)
+	_ codec.ProtoMessage = (*event.ConnectorData)(nil)
+)
+
+// jsonEvent hides protobuf methods of the event
+type jsonEvent struct {
+	event.BaseEvent
+}
+
+// protoEvent is serialized as a message with the only string field id = 1
+type protoEvent struct {
+	event.WorkflowData
+}
+
+func (pe *protoEvent) MarshalProto() ([]byte, error) {
+	return append([]byte{0x0a, byte(len(pe.ID))}, pe.ID...), nil
+}
+
+func (pe *protoEvent) UnmarshalProto(data []byte) error {
+	if len(data) < 2 || data[0] != 0x0a || int(data[1]) != len(data)-2 {
+		return errors.New("invalid message")
+	}
+
+	pe.ID = string(data[2:])
+
+	return nil
+}
+
+func TestProtobuf(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	id, err := reg.Register(bgCtx, codec.Subject(testTopic), codec.Schema{
+		Type:   codec.SchemaTypeProtobuf,
+		Schema: `syntax = "proto3"; message Event { string id = 1; }`,
+	})
+	require.NoError(t, err)
+
+	c := codec.NewProtobuf(reg)
+
+	b, err := c.Encode(bgCtx, testTopic, &protoEvent{event.WorkflowData{ID: "abc"}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, byte(id), 0, 0x0a, 3, 'a', 'b', 'c'}, b)
+
+	var decoded protoEvent
+	require.NoError(t, c.Decode(bgCtx, event.Message{Value: b}, &decoded))
+	assert.Equal(t, "abc", decoded.ID)
+
+	// message indexes [1, 0] of a nested message are skipped
+	nested := []byte{0, 0, 0, 0, byte(id), 4, 2, 0, 0x0a, 1, 'x'}
+	require.NoError(t, c.Decode(bgCtx, event.Message{Value: nested}, &decoded))
+	assert.Equal(t, "x", decoded.ID)
+
+	_, err = c.Encode(bgCtx, testTopic, &jsonEvent{&event.WorkflowData{ID: "abc"}})
+	assert.Equal(t, cerror.KindInternal, err.(*cerror.CError).Kind())
+}
+
+func TestProtobufWorkflowEvent(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+	_, err := reg.Register(bgCtx, codec.Subject(testTopic), codec.Schema{
+		Type:   codec.SchemaTypeProtobuf,
+		Schema: event.WorkflowProtoSchema,
+	})
+	require.NoError(t, err)
+
+	c := codec.NewProtobuf(reg)
+	e := newWorkflowEvent()
+
+	b, err := c.Encode(bgCtx, testTopic, e)
+	require.NoError(t, err)
+	assert.True(t, codec.IsWireFormat(b))
+
+	var decoded event.WorkflowData
+	require.NoError(t, c.Decode(bgCtx, event.Message{Value: b}, &decoded))
+	assert.Equal(t, e, &decoded)
+}
@@ -0,0 +1,277 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-polygon/pkg/cerror"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SchemaType string
+
+const (
+	SchemaTypeAvro     SchemaType = "AVRO"
+	SchemaTypeProtobuf SchemaType = "PROTOBUF"
+	SchemaTypeJSON     SchemaType = "JSON"
+
+	registryContentType    = "application/vnd.schemaregistry.v1+json"
+	defaultRegistryTimeout = 10 * time.Second
+	defaultSchemaTTL       = 5 * time.Minute
+)
+
+// Schema is a schema registered under a subject
+type Schema struct {
+	ID      int        `json:"id"`
+	Subject string     `json:"subject,omitempty"`
+	Version int        `json:"version,omitempty"`
+	Type    SchemaType `json:"schemaType,omitempty"`
+	Schema  string     `json:"schema"`
+}
+
+// Registry stores schemas of subjects
+type Registry interface {
+	// Register registers the schema under the subject and returns its ID.
+	// The ID of an already registered schema is returned if the schema is registered again.
+	Register(ctx context.Context, subject string, s Schema) (int, error)
+	// Latest returns the latest version of the subject's schema
+	Latest(ctx context.Context, subject string) (Schema, error)
+	// ByID returns the schema by its ID
+	ByID(ctx context.Context, id int) (Schema, error)
+}
+
+type RegistryConfig struct {
+	URL      string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// RegistryClient is a client of the Confluent schema registry REST API
+type RegistryClient struct {
+	cfg RegistryConfig
+	hc  *http.Client
+}
+
+func NewRegistryClient(cfg RegistryConfig) *RegistryClient {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultRegistryTimeout
+	}
+
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	return &RegistryClient{
+		cfg: cfg,
+		hc:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (rc *RegistryClient) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	req := registerRequest{Schema: s.Schema}
+	if s.Type != SchemaTypeAvro {
+		req.Type = s.Type
+	}
+
+	var res Schema
+	if err := rc.do(ctx, http.MethodPost, fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject)), req, &res); err != nil {
+		return 0, err
+	}
+
+	return res.ID, nil
+}
+
+func (rc *RegistryClient) Latest(ctx context.Context, subject string) (Schema, error) {
+	var res Schema
+	if err := rc.do(ctx, http.MethodGet, fmt.Sprintf("/subjects/%s/versions/latest", url.PathEscape(subject)), nil, &res); err != nil {
+		return Schema{}, err
+	}
+
+	return res.withDefaultType(), nil
+}
+
+func (rc *RegistryClient) ByID(ctx context.Context, id int) (Schema, error) {
+	var res Schema
+	if err := rc.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &res); err != nil {
+		return Schema{}, err
+	}
+
+	res.ID = id
+
+	return res.withDefaultType(), nil
+}
+
+type registerRequest struct {
+	Schema string     `json:"schema"`
+	Type   SchemaType `json:"schemaType,omitempty"`
+}
+
+// registryError is an error response of the registry
+type registryError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (rc *RegistryClient) do(ctx context.Context, method, path string, body, dst interface{}) error {
+	var reqBody io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return cerror.New(ctx, cerror.KindInternal, err).LogError()
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rc.cfg.URL+path, reqBody)
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	req.Header.Set("Accept", registryContentType)
+
+	if body != nil {
+		req.Header.Set("Content-Type", registryContentType)
+	}
+
+	if rc.cfg.Username != "" {
+		req.SetBasicAuth(rc.cfg.Username, rc.cfg.Password)
+	}
+
+	resp, err := rc.hc.Do(req)
+	if err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "[schema registry] %s %s. %s", method, path, err.Error()).LogError()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var re registryError
+		_ = json.NewDecoder(resp.Body).Decode(&re)
+
+		return cerror.NewF(ctx, cerror.KindFromHTTPCode(resp.StatusCode),
+			"[schema registry] %s %s. code: %d. %s", method, path, re.Code, re.Message).LogError()
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal, "[schema registry] decode response of %s %s. %s",
+			method, path, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// withDefaultType sets AVRO type to a schema without type as the registry does
+func (s Schema) withDefaultType() Schema {
+	if s.Type == "" {
+		s.Type = SchemaTypeAvro
+	}
+
+	return s
+}
+
+// schemaCache caches schemas of a registry for codecs.
+// Schemas by ID never change, the latest schemas of subjects are requested again after the TTL.
+type schemaCache struct {
+	reg    Registry
+	ttl    time.Duration
+	mx     sync.Mutex
+	byID   map[int]Schema
+	latest map[string]cachedSchema
+}
+
+type cachedSchema struct {
+	s       Schema
+	expires time.Time
+}
+
+func newSchemaCache(reg Registry, ttl time.Duration) *schemaCache {
+	if ttl == 0 {
+		ttl = defaultSchemaTTL
+	}
+
+	return &schemaCache{
+		reg:    reg,
+		ttl:    ttl,
+		byID:   make(map[int]Schema),
+		latest: make(map[string]cachedSchema),
+	}
+}
+
+func (sc *schemaCache) Latest(ctx context.Context, subject string) (Schema, error) {
+	sc.mx.Lock()
+	cached, ok := sc.latest[subject]
+	sc.mx.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.s, nil
+	}
+
+	s, err := sc.reg.Latest(ctx, subject)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	sc.mx.Lock()
+	sc.latest[subject] = cachedSchema{s: s, expires: time.Now().Add(sc.ttl)}
+	sc.byID[s.ID] = s
+	sc.mx.Unlock()
+
+	return s, nil
+}
+
+func (sc *schemaCache) ByID(ctx context.Context, id int) (Schema, error) {
+	sc.mx.Lock()
+	s, ok := sc.byID[id]
+	sc.mx.Unlock()
+
+	if ok {
+		return s, nil
+	}
+
+	s, err := sc.reg.ByID(ctx, id)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	sc.mx.Lock()
+	sc.byID[id] = s
+	sc.mx.Unlock()
+
+	return s, nil
+}
+
+// latestOf returns the latest schema of the subject checking its type
+func (sc *schemaCache) latestOf(ctx context.Context, subject string, typ SchemaType) (Schema, error) {
+	s, err := sc.Latest(ctx, subject)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	if s.Type != typ {
+		return Schema{}, cerror.NewF(ctx, cerror.KindBadValidation,
+			"schema %d of subject %s has type %s, %s expected", s.ID, subject, s.Type, typ).LogError()
+	}
+
+	return s, nil
+}
+
+// byIDOf returns the schema by ID checking its type
+func (sc *schemaCache) byIDOf(ctx context.Context, id int, typ SchemaType) (Schema, error) {
+	s, err := sc.ByID(ctx, id)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	if s.Type != typ {
+		return Schema{}, cerror.NewF(ctx, cerror.KindBadValidation,
+			"schema %d has type %s, %s expected", id, s.Type, typ).LogError()
+	}
+
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package codec_test
+
+import (
+	"encoding/json"
+	"io"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/cerror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestRegistryClient(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+		assert.Equal(t, "application/vnd.schemaregistry.v1+json", r.Header.Get("Accept"))
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /subjects/orders-value/versions":
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"schemaType":"PROTOBUF","schema":"message A {}"}`, string(body))
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case "GET /subjects/orders-value/versions/latest":
+			_, _ = w.Write([]byte(`{"subject":"orders-value","version":2,"id":7,"schema":"\"string\""}`))
+		case "GET /schemas/ids/7":
+			_, _ = w.Write([]byte(`{"schemaType":"JSON","schema":"{}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 40401, "message": "Subject not found."})
+		}
+	}))
+	defer srv.Close()
+
+	rc := codec.NewRegistryClient(codec.RegistryConfig{URL: srv.URL + "/", Username: "user", Password: "pass"})
+
+	id, err := rc.Register(bgCtx, "orders-value", codec.Schema{Type: codec.SchemaTypeProtobuf, Schema: "message A {}"})
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	latest, err := rc.Latest(bgCtx, "orders-value")
+	require.NoError(t, err)
+	assert.Equal(t, codec.Schema{ID: 7, Subject: "orders-value", Version: 2, Type: codec.SchemaTypeAvro, Schema: `"string"`}, latest)
+
+	byID, err := rc.ByID(bgCtx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, codec.Schema{ID: 7, Type: codec.SchemaTypeJSON, Schema: "{}"}, byID)
+
+	_, err = rc.Latest(bgCtx, "unknown-value")
+	require.Error(t, err)
+	assert.Equal(t, cerror.KindNotExist, err.(*cerror.CError).Kind())
+	assert.Contains(t, err.Error(), "Subject not found.")
+}
+
+func TestFileRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := newFileRegistry(t)
+
+	id1, err := reg.Register(bgCtx, "a-value", codec.Schema{Schema: `"string"`})
+	require.NoError(t, err)
+
+	id2, err := reg.Register(bgCtx, "b-value", codec.Schema{Type: codec.SchemaTypeJSON, Schema: `{}`})
+	require.NoError(t, err)
+
+	id3, err := reg.Register(bgCtx, "a-value", codec.Schema{Schema: `"long"`})
+	require.NoError(t, err)
+
+	again, err := reg.Register(bgCtx, "a-value", codec.Schema{Schema: `"string"`})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 2, 3, 1}, []int{id1, id2, id3, again})
+
+	latest, err := reg.Latest(bgCtx, "a-value")
+	require.NoError(t, err)
+	assert.Equal(t, codec.Schema{ID: 3, Subject: "a-value", Version: 2, Type: codec.SchemaTypeAvro, Schema: `"long"`}, latest)
+
+	_, err = reg.Latest(bgCtx, "c-value")
+	assert.Equal(t, cerror.KindNotExist, err.(*cerror.CError).Kind())
+
+	_, err = reg.ByID(bgCtx, 4)
+	assert.Equal(t, cerror.KindNotExist, err.(*cerror.CError).Kind())
+}
+
+func TestFileRegistryReopen(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/registry.json"
+
+	reg, err := codec.NewFileRegistry(path)
+	require.NoError(t, err)
+
+	id, err := reg.Register(bgCtx, "a-value", codec.Schema{Type: codec.SchemaTypeJSON, Schema: `{}`})
+	require.NoError(t, err)
+
+	reopened, err := codec.NewFileRegistry(path)
+	require.NoError(t, err)
+
+	s, err := reopened.ByID(bgCtx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "a-value", s.Subject)
+	assert.Equal(t, codec.SchemaTypeJSON, s.Type)
+}
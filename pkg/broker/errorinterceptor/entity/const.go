@@ -2,6 +2,7 @@ package entity
 
 const (
 	StatusNew                 = "new"
+	StatusResending           = "resending"
 	StatusResendedWithSuccess = "resended_with_success"
 	StatusResendedWithError   = "resended_with_error"
 )
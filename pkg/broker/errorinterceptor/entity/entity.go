@@ -6,11 +6,33 @@ import (
 )
 
 type FailedBrokerEvent struct {
-	ID        string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Status    string
-	Topic     string
-	Data      json.RawMessage
-	Error     string
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Status    string          `json:"status"`
+	Topic     string          `json:"topic"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+}
+
+// SearchFailedBrokerEventParams is a filter of failed events. Nil fields are not applied.
+type SearchFailedBrokerEventParams struct {
+	Topic  *string `form:"topic" json:"topic"`
+	Status *string `form:"status" json:"status"`
+	// CreatedFrom and CreatedTo limit the creation time, both bounds are inclusive
+	CreatedFrom *time.Time `form:"created_from" json:"created_from"`
+	CreatedTo   *time.Time `form:"created_to" json:"created_to"`
+	// Error is a case-insensitive part of the error text
+	Error *string `form:"error" json:"error"`
+	*Paging
+}
+
+type SearchFailedBrokerEventResult struct {
+	Events []*FailedBrokerEvent
+	Paging Paging
+}
+
+type Paging struct {
+	Limit  int `form:"limit" json:"limit"`
+	Offset int `form:"offset" json:"offset"`
 }
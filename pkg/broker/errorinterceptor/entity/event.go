@@ -3,6 +3,7 @@ package entity
 import (
 	"context"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/cmd/metadata"
 )
 
@@ -20,6 +21,11 @@ func (e *ErrorEvent) GetID() string {
 	return e.OriginalEvent.GetID()
 }
 
+// EventType returns the type of the original event, so a wrapped handler is routed by it
+func (e *ErrorEvent) EventType() string {
+	return provider.EventType(e.OriginalEvent)
+}
+
 func (e *ErrorEvent) GetDebug() bool {
 	return e.OriginalEvent.GetDebug()
 }
@@ -47,3 +53,39 @@ func (e *ErrorEvent) GetMeta() metadata.Meta {
 func (e *ErrorEvent) WithMeta(ctx metadata.Meta) {
 	e.OriginalEvent.WithMeta(ctx)
 }
+
+// ResendEvent republishes data of a failed event as is.
+// Header and metadata of the original event are already in its data, so they are not overwritten.
+type ResendEvent struct {
+	ID   string
+	Data []byte
+}
+
+func (e *ResendEvent) GetID() string {
+	return e.ID
+}
+
+func (e *ResendEvent) GetDebug() bool {
+	return false
+}
+
+func (e *ResendEvent) WithHeader(_ context.Context) {}
+
+func (e *ResendEvent) GetHeader() event.Header {
+	return event.Header{}
+}
+
+func (e *ResendEvent) ToByte() []byte {
+	return e.Data
+}
+
+func (e *ResendEvent) Unmarshal(msg event.Message) error {
+	e.Data = msg.Value
+	return nil
+}
+
+func (e *ResendEvent) GetMeta() metadata.Meta {
+	return metadata.Meta{}
+}
+
+func (e *ResendEvent) WithMeta(_ metadata.Meta) {}
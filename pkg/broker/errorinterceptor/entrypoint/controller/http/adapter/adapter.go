@@ -0,0 +1,14 @@
+package adapter
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+)
+
+// UseCase is a common business logic abstraction that is required for all adapters
+type UseCase interface {
+	SearchEvents(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	GetEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	ResendEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+}
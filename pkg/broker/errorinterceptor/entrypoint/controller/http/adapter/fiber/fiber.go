@@ -0,0 +1,162 @@
+package fiber
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http/adapter"
+	"kafka-polygon/pkg/cerror"
+	pkgHTTPFiber "kafka-polygon/pkg/http/fiber"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Adapter struct {
+	srv *pkgHTTPFiber.Server
+	uc  adapter.UseCase
+}
+
+var _ http.ServerAdapter = (*Adapter)(nil)
+
+func NewAdapter(srv *pkgHTTPFiber.Server, uc adapter.UseCase) *Adapter {
+	return &Adapter{srv: srv, uc: uc}
+}
+
+func (a *Adapter) RegisterFailedEventRoutes(ctx context.Context, opts http.Option) error {
+	prefixRouter := a.srv.Fiber().Group(opts.GetPrefix())
+
+	prefixRouter.Add(http.MethodSearchEvents, http.RouteSearchEvents, func(c *fiber.Ctx) error {
+		return withError(c, a.SearchEvents(c))
+	})
+
+	prefixRouter.Add(http.MethodGetEvent, http.RouteGetEvent, func(c *fiber.Ctx) error {
+		return withError(c, a.GetEvent(c))
+	})
+
+	prefixRouter.Add(http.MethodResendEvent, http.RouteResendEvent, func(c *fiber.Ctx) error {
+		return withError(c, a.ResendEvent(c))
+	})
+
+	return nil
+}
+
+func (a *Adapter) SearchEvents(c *fiber.Ctx) error {
+	params, err := searchParams(c)
+	if err != nil {
+		return err
+	}
+
+	searchResult, err := a.uc.SearchEvents(c.Context(), params)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.SuccessStatusSearchEvents).JSON(&http.SearchResponse{
+		Data:   searchResult.Events,
+		Paging: searchResult.Paging,
+	})
+}
+
+func (a *Adapter) GetEvent(c *fiber.Ctx) error {
+	e, err := a.uc.GetEvent(c.Context(), c.Params(http.QueryParamID))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.SuccessStatusGetEvent).JSON(e)
+}
+
+func (a *Adapter) ResendEvent(c *fiber.Ctx) error {
+	e, err := a.uc.ResendEvent(c.Context(), c.Params(http.QueryParamID))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.SuccessStatusResendEvent).JSON(e)
+}
+
+// withError writes the error response of the handler error
+func withError(c *fiber.Ctx, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	cerror.LogHTTPHandlerErrorCtx(c.Context(), err)
+
+	return c.Status(cerror.ErrKind(err).HTTPCode()).JSON(cerror.BuildErrorResponse(err))
+}
+
+func searchParams(c *fiber.Ctx) (entity.SearchFailedBrokerEventParams, error) {
+	var params entity.SearchFailedBrokerEventParams
+
+	params.Topic = queryString(c, http.QueryParamTopic)
+	params.Status = queryString(c, http.QueryParamStatus)
+	params.Error = queryString(c, http.QueryParamError)
+
+	var err error
+
+	if params.CreatedFrom, err = queryTime(c, http.QueryParamCreatedFrom); err != nil {
+		return params, err
+	}
+
+	if params.CreatedTo, err = queryTime(c, http.QueryParamCreatedTo); err != nil {
+		return params, err
+	}
+
+	limit, okLimit, err := queryInt(c, http.QueryParamLimit)
+	if err != nil {
+		return params, err
+	}
+
+	offset, okOffset, err := queryInt(c, http.QueryParamOffset)
+	if err != nil {
+		return params, err
+	}
+
+	if okLimit || okOffset {
+		params.Paging = &entity.Paging{Limit: limit, Offset: offset}
+	}
+
+	return params, nil
+}
+
+func queryString(c *fiber.Ctx, key string) *string {
+	v := c.Query(key)
+	if v == "" {
+		return nil
+	}
+
+	return &v
+}
+
+func queryTime(c *fiber.Ctx, key string) (*time.Time, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, cerror.NewF(c.Context(), cerror.KindBadParams,
+			"invalid %s: %s", key, err.Error()).LogError()
+	}
+
+	return &t, nil
+}
+
+func queryInt(c *fiber.Ctx, key string) (int, bool, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, false, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false, cerror.NewF(c.Context(), cerror.KindBadParams,
+			"invalid %s: %s", key, err.Error()).LogError()
+	}
+
+	return i, true, nil
+}
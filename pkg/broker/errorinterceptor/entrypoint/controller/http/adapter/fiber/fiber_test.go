@@ -0,0 +1,145 @@
+package fiber_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	controllerHTTP "kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	adapterFiber "kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http/adapter/fiber"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/env"
+	httpFiber "kafka-polygon/pkg/http/fiber"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+var (
+	_bgCtx = context.Background()
+)
+
+type mockUseCase struct {
+	searchEventsFunc func(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	getEventFunc    func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	resendEventFunc func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+}
+
+func (m *mockUseCase) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+	return m.searchEventsFunc(ctx, params)
+}
+
+func (m *mockUseCase) GetEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.getEventFunc(ctx, id)
+}
+
+func (m *mockUseCase) ResendEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.resendEventFunc(ctx, id)
+}
+
+func TestSearchEvents(t *testing.T) {
+	status := entity.StatusNew
+	createdTo := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	expResult := &entity.SearchFailedBrokerEventResult{
+		Events: []*entity.FailedBrokerEvent{{ID: "123", Status: status, Data: []byte("{}")}},
+		Paging: entity.Paging{Limit: 5},
+	}
+
+	uc := &mockUseCase{
+		searchEventsFunc: func(
+			ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+			assert.Equal(t, &status, params.Status)
+			assert.True(t, createdTo.Equal(*params.CreatedTo))
+			assert.Nil(t, params.Topic)
+			assert.Nil(t, params.CreatedFrom)
+			assert.Equal(t, &entity.Paging{Limit: 5}, params.Paging)
+
+			return expResult, nil
+		},
+	}
+
+	var body controllerHTTP.SearchResponse
+
+	code := makeReq(t, newServer(uc), http.MethodGet,
+		fmt.Sprintf("/failed-broker-events/?status=%s&created_to=%s&limit=5",
+			status, createdTo.Format(time.RFC3339)), &body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expResult.Events, body.Data)
+	assert.Equal(t, expResult.Paging, body.Paging)
+
+	code = makeReq(t, newServer(uc), http.MethodGet, "/failed-broker-events/?created_from=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetEvent(t *testing.T) {
+	expEvent := &entity.FailedBrokerEvent{ID: "123", Topic: "topic", Data: []byte("{}")}
+
+	uc := &mockUseCase{
+		getEventFunc: func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+			if id != expEvent.ID {
+				return nil, cerror.NewF(ctx, cerror.KindNotExist, "failed broker event %s not found", id)
+			}
+
+			return expEvent, nil
+		},
+	}
+
+	var body entity.FailedBrokerEvent
+
+	code := makeReq(t, newServer(uc), http.MethodGet, "/failed-broker-events/123", &body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expEvent, &body)
+
+	code = makeReq(t, newServer(uc), http.MethodGet, "/failed-broker-events/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestResendEvent(t *testing.T) {
+	expEvent := &entity.FailedBrokerEvent{ID: "123", Status: entity.StatusResendedWithSuccess, Data: []byte("{}")}
+	isCalled := false
+
+	uc := &mockUseCase{
+		resendEventFunc: func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+			isCalled = true
+			assert.Equal(t, expEvent.ID, id)
+
+			return expEvent, nil
+		},
+	}
+
+	var body entity.FailedBrokerEvent
+
+	code := makeReq(t, newServer(uc), http.MethodPost, "/failed-broker-events/resend/123", &body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, expEvent, &body)
+	assert.True(t, isCalled)
+}
+
+func makeReq(t *testing.T, s *httpFiber.Server, method, route string, dst interface{}) int {
+	t.Helper()
+
+	resp, err := s.Fiber().Test(httptest.NewRequest(method, route, nil))
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	if dst != nil {
+		b, _ := io.ReadAll(resp.Body)
+		assert.NoError(t, json.Unmarshal(b, dst))
+	}
+
+	return resp.StatusCode
+}
+
+func newServer(uc *mockUseCase) *httpFiber.Server {
+	s := httpFiber.NewServer(&httpFiber.ServerConfig{Server: env.HTTPServer{Port: "3000"}})
+	_ = controllerHTTP.RegisterFailedEventRoutes(_bgCtx, adapterFiber.NewAdapter(s, uc))
+
+	return s
+}
@@ -0,0 +1,92 @@
+package gin
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http/adapter"
+	"kafka-polygon/pkg/cerror"
+	pkgHTTPGin "kafka-polygon/pkg/http/gin"
+	"kafka-polygon/pkg/http/gin/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Adapter struct {
+	srv *pkgHTTPGin.Server
+	uc  adapter.UseCase
+}
+
+var _ http.ServerAdapter = (*Adapter)(nil)
+
+func NewAdapter(srv *pkgHTTPGin.Server, uc adapter.UseCase) *Adapter {
+	return &Adapter{srv: srv, uc: uc}
+}
+
+func (a *Adapter) RegisterFailedEventRoutes(ctx context.Context, opts http.Option) error {
+	prefixRouter := a.srv.Gin().RouterGroup.Group(opts.GetPrefix())
+
+	prefixRouter.Handle(http.MethodSearchEvents, http.RouteSearchEvents, func(c *gin.Context) {
+		if err := a.SearchEvents(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	prefixRouter.Handle(http.MethodGetEvent, http.RouteGetEvent, func(c *gin.Context) {
+		if err := a.GetEvent(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	prefixRouter.Handle(http.MethodResendEvent, http.RouteResendEvent, func(c *gin.Context) {
+		if err := a.ResendEvent(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	return nil
+}
+
+func (a *Adapter) SearchEvents(ctx *gin.Context) error {
+	var params entity.SearchFailedBrokerEventParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		return cerror.New(ctx, cerror.KindBadParams, err).LogError()
+	}
+
+	searchResult, err := a.uc.SearchEvents(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusSearchEvents, &http.SearchResponse{
+		Data:   searchResult.Events,
+		Paging: searchResult.Paging,
+	})
+
+	return ctx.Err()
+}
+
+func (a *Adapter) GetEvent(ctx *gin.Context) error {
+	e, err := a.uc.GetEvent(ctx, ctx.Param(http.QueryParamID))
+	if err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusGetEvent, e)
+
+	return ctx.Err()
+}
+
+func (a *Adapter) ResendEvent(ctx *gin.Context) error {
+	e, err := a.uc.ResendEvent(ctx, ctx.Param(http.QueryParamID))
+	if err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusResendEvent, e)
+
+	return ctx.Err()
+}
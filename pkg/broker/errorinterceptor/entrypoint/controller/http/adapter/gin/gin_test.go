@@ -0,0 +1,225 @@
+package gin_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	controllerHTTP "kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	adapterGin "kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http/adapter/gin"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/env"
+	pkgGin "kafka-polygon/pkg/http/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+var (
+	_bgCtx = context.Background()
+)
+
+type mockUseCase struct {
+	searchEventsFunc func(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	getEventFunc    func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	resendEventFunc func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+}
+
+func (m *mockUseCase) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+	return m.searchEventsFunc(ctx, params)
+}
+
+func (m *mockUseCase) GetEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.getEventFunc(ctx, id)
+}
+
+func (m *mockUseCase) ResendEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.resendEventFunc(ctx, id)
+}
+
+func TestSearchEvents(t *testing.T) {
+	topic := "topic"
+	errText := "timeout"
+	createdFrom := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	expSearchParams := entity.SearchFailedBrokerEventParams{
+		Topic:       &topic,
+		Error:       &errText,
+		CreatedFrom: &createdFrom,
+		Paging: &entity.Paging{
+			Limit:  1,
+			Offset: 1,
+		},
+	}
+
+	expSearchEventsFuncResult := &entity.SearchFailedBrokerEventResult{
+		Events: []*entity.FailedBrokerEvent{{
+			ID:    "123",
+			Topic: topic,
+			Data:  []byte("{}"),
+		}},
+		Paging: entity.Paging{
+			Limit:  expSearchParams.Limit,
+			Offset: expSearchParams.Offset,
+		},
+	}
+
+	uc := &mockUseCase{
+		searchEventsFunc: func(
+			ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+			assert.Equal(t, expSearchParams.Topic, params.Topic)
+			assert.Equal(t, expSearchParams.Error, params.Error)
+			assert.True(t, createdFrom.Equal(*params.CreatedFrom))
+			assert.Nil(t, params.Status)
+			assert.Nil(t, params.CreatedTo)
+			assert.Equal(t, expSearchParams.Paging, params.Paging)
+
+			return expSearchEventsFuncResult, nil
+		},
+	}
+
+	tm := &testModel{
+		method: http.MethodGet,
+		route: fmt.Sprintf("/failed-broker-events/?topic=%s&error=%s&created_from=%s&limit=%d&offset=%d",
+			topic, errText, createdFrom.Format(time.RFC3339), expSearchParams.Limit, expSearchParams.Offset),
+		req:          nil,
+		dst:          new(controllerHTTP.SearchResponse),
+		expectedCode: http.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*controllerHTTP.SearchResponse)
+			assert.True(t, ok)
+			assert.Equal(t, expSearchEventsFuncResult.Events, body.Data)
+			assert.Equal(t, expSearchEventsFuncResult.Paging, body.Paging)
+		}}
+
+	testByModel(t, newServer(uc), tm)
+}
+
+func TestGetEvent(t *testing.T) {
+	expEvent := &entity.FailedBrokerEvent{ID: "123", Topic: "topic", Data: []byte("{}")}
+
+	uc := &mockUseCase{
+		getEventFunc: func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+			if id != expEvent.ID {
+				return nil, cerror.NewF(ctx, cerror.KindNotExist, "failed broker event %s not found", id)
+			}
+
+			return expEvent, nil
+		},
+	}
+
+	tm := &testModel{
+		method:       http.MethodGet,
+		route:        fmt.Sprintf("/failed-broker-events/%s", expEvent.ID),
+		req:          nil,
+		dst:          new(entity.FailedBrokerEvent),
+		expectedCode: http.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			assert.Equal(t, expEvent, resp)
+		}}
+
+	testByModel(t, newServer(uc), tm)
+
+	tm = &testModel{
+		method:       http.MethodGet,
+		route:        "/failed-broker-events/unknown",
+		expectedCode: http.StatusNotFound,
+	}
+
+	testByModel(t, newServer(uc), tm)
+}
+
+func TestResendEvent(t *testing.T) {
+	expEvent := &entity.FailedBrokerEvent{
+		ID:     "123",
+		Topic:  "topic",
+		Status: entity.StatusResendedWithSuccess,
+		Data:   []byte("{}"),
+	}
+	isCalled := false
+
+	uc := &mockUseCase{
+		resendEventFunc: func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+			isCalled = true
+			assert.Equal(t, expEvent.ID, id)
+
+			return expEvent, nil
+		},
+	}
+
+	tm := &testModel{
+		method:       http.MethodPost,
+		route:        fmt.Sprintf("/failed-broker-events/resend/%s", expEvent.ID),
+		req:          nil,
+		dst:          new(entity.FailedBrokerEvent),
+		expectedCode: http.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			assert.Equal(t, expEvent, resp)
+		},
+	}
+
+	testByModel(t, newServer(uc), tm)
+
+	assert.True(t, isCalled)
+}
+
+type testModel struct {
+	method       string
+	route        string
+	req          interface{}
+	dst          interface{}
+	expectedCode int
+	assertFn     func(code int, respBody interface{})
+}
+
+func testByModel(t *testing.T, s *pkgGin.Server, m *testModel) {
+	t.Helper()
+
+	code, err := makeReq(s, m.method, m.route, m.req, m.dst)
+	assert.NoError(t, err)
+	assert.Equal(t, m.expectedCode, code)
+
+	if m.assertFn != nil {
+		m.assertFn(code, m.dst)
+	}
+}
+
+func makeReq(s *pkgGin.Server, method, route string, body, dst interface{}) (status int, err error) {
+	w := httptest.NewRecorder()
+	buf := new(bytes.Buffer)
+
+	if body != nil {
+		b, _ := json.Marshal(body)
+		_, _ = buf.Write(b)
+	}
+
+	req := httptest.NewRequest(method, route, buf)
+	req.Header.Set("Content-Type", "application/json")
+
+	s.Gin().ServeHTTP(w, req)
+
+	if w != nil {
+		defer w.Flush()
+	}
+
+	if dst != nil {
+		b, _ := io.ReadAll(w.Body)
+		_ = json.Unmarshal(b, dst)
+	}
+
+	return w.Code, nil
+}
+
+func newServer(uc *mockUseCase) *pkgGin.Server {
+	s := pkgGin.NewServer(&pkgGin.ServerConfig{Server: env.HTTPServer{Port: "3000"}}).WithDefaultKit()
+	s.Gin().UnescapePathValues = true
+	_ = controllerHTTP.RegisterFailedEventRoutes(_bgCtx, adapterGin.NewAdapter(s, uc))
+
+	return s
+}
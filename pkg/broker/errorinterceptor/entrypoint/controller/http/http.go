@@ -0,0 +1,45 @@
+package http
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	"net/http"
+)
+
+const (
+	RouteSearchEvents = "/"
+	RouteGetEvent     = "/:id"
+	RouteResendEvent  = "/resend/:id"
+
+	MethodSearchEvents = http.MethodGet
+	MethodGetEvent     = http.MethodGet
+	MethodResendEvent  = http.MethodPost
+
+	QueryParamID          = "id"
+	QueryParamTopic       = "topic"
+	QueryParamStatus      = "status"
+	QueryParamCreatedFrom = "created_from"
+	QueryParamCreatedTo   = "created_to"
+	QueryParamError       = "error"
+	QueryParamLimit       = "limit"
+	QueryParamOffset      = "offset"
+
+	SuccessStatusSearchEvents = http.StatusOK
+	SuccessStatusGetEvent     = http.StatusOK
+	SuccessStatusResendEvent  = http.StatusOK
+)
+
+type SearchResponse struct {
+	Data   []*entity.FailedBrokerEvent `json:"data"`
+	Paging entity.Paging               `json:"paging"`
+}
+
+type ServerAdapter interface {
+	RegisterFailedEventRoutes(ctx context.Context, opts Option) error
+}
+
+// RegisterFailedEventRoutes registers routes of failed broker events using a given server adapter.
+// Use one from /adapter folder or provide your own implementation.
+func RegisterFailedEventRoutes(ctx context.Context, serverAdapter ServerAdapter, opts ...OptionApply) error {
+	return serverAdapter.RegisterFailedEventRoutes(ctx, GetOptions(opts...))
+}
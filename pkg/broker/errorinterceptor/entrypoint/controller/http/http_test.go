@@ -0,0 +1,33 @@
+package http_test
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+var (
+	_bgCtx = context.Background()
+)
+
+type mockAdapter struct {
+	registerFailedEventRoutesFunc func(ctx context.Context, opts http.Option) error
+}
+
+func (m *mockAdapter) RegisterFailedEventRoutes(ctx context.Context, opts http.Option) error {
+	return m.registerFailedEventRoutesFunc(ctx, opts)
+}
+
+func TestRegisterFailedEventRoutes(t *testing.T) {
+	option := http.WithPrefix("123")
+	adapter := &mockAdapter{
+		registerFailedEventRoutesFunc: func(ctx context.Context, opts http.Option) error {
+			assert.Equal(t, http.GetOptions(option), opts)
+
+			return nil
+		},
+	}
+	_ = http.RegisterFailedEventRoutes(_bgCtx, adapter, option)
+}
@@ -0,0 +1,18 @@
+package http_test
+
+import (
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/controller/http"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := http.GetOptions()
+	assert.Equal(t, http.DefaultFailedEventRoutePrefix, opts.GetPrefix())
+
+	opts = http.GetOptions(http.WithPrefix("/test"))
+	assert.Equal(t, "/test", opts.GetPrefix())
+}
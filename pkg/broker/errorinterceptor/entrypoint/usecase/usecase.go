@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	"kafka-polygon/pkg/cerror"
+	"time"
+)
+
+// resendTimeout is a time after which an event left resending, e.g. by a crashed instance, can be resent again
+const resendTimeout = time.Minute
+
+type Store interface {
+	GetEventByID(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	SearchEvents(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	UpdateEventStatus(ctx context.Context, id, status string) error
+	// ClaimEventResend atomically sets entity.StatusResending to the event unless it's resent with success
+	// or is being resent since claimedAfter. The returned bool reports whether the event is claimed
+	ClaimEventResend(ctx context.Context, id string, claimedAfter time.Time) (bool, error)
+}
+
+type UseCase struct {
+	store  Store
+	broker broker.QueueBroker
+}
+
+func New(s Store, qb broker.QueueBroker) *UseCase {
+	return &UseCase{store: s, broker: qb}
+}
+
+// SearchEvents searches failed events by a given parameters
+func (uc *UseCase) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+	return uc.store.SearchEvents(ctx, params)
+}
+
+// GetEvent returns a failed event by id
+func (uc *UseCase) GetEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return uc.store.GetEventByID(ctx, id)
+}
+
+// ResendEvent republishes data of the failed event to its original topic and updates the event status.
+// An event that is already resent with success is not sent again. The event is claimed before it's sent,
+// so concurrent requests don't resend it twice.
+// The send error is returned after the event is marked as resended with error.
+func (uc *UseCase) ResendEvent(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	e, err := uc.store.GetEventByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Status == entity.StatusResendedWithSuccess {
+		return nil, cerror.NewF(ctx, cerror.KindConflict,
+			"failed broker event %s is already resent", id).LogError()
+	}
+
+	claimed, err := uc.store.ClaimEventResend(ctx, id, time.Now().UTC().Add(-resendTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	if !claimed {
+		return nil, cerror.NewF(ctx, cerror.KindConflict,
+			"failed broker event %s is already resent or being resent", id).LogError()
+	}
+
+	sendErr := uc.broker.Send(ctx, e.Topic, &entity.ResendEvent{ID: e.ID, Data: e.Data})
+
+	e.Status = entity.StatusResendedWithSuccess
+	if sendErr != nil {
+		e.Status = entity.StatusResendedWithError
+	}
+
+	if err := uc.store.UpdateEventStatus(ctx, e.ID, e.Status); err != nil {
+		return nil, err
+	}
+
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	return e, nil
+}
@@ -0,0 +1,158 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker"
+	"kafka-polygon/pkg/broker/errorinterceptor/entity"
+	"kafka-polygon/pkg/broker/errorinterceptor/entrypoint/usecase"
+	"kafka-polygon/pkg/broker/event"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+var (
+	_bgCtx = context.Background()
+)
+
+type mockStore struct {
+	getEventByIDFunc func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	searchEventsFunc func(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	updateEventStatusFunc func(ctx context.Context, id, status string) error
+	claimEventResendFunc  func(ctx context.Context, id string, claimedAfter time.Time) (bool, error)
+}
+
+func (m *mockStore) GetEventByID(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.getEventByIDFunc(ctx, id)
+}
+
+func (m *mockStore) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+	return m.searchEventsFunc(ctx, params)
+}
+
+func (m *mockStore) UpdateEventStatus(ctx context.Context, id, status string) error {
+	return m.updateEventStatusFunc(ctx, id, status)
+}
+
+func (m *mockStore) ClaimEventResend(ctx context.Context, id string, claimedAfter time.Time) (bool, error) {
+	return m.claimEventResendFunc(ctx, id, claimedAfter)
+}
+
+type mockBroker struct {
+	broker.QueueBroker
+	sendFunc func(ctx context.Context, topic string, e event.BaseEvent) error
+}
+
+func (m *mockBroker) Send(ctx context.Context, topic string, e event.BaseEvent) error {
+	return m.sendFunc(ctx, topic, e)
+}
+
+func TestSearchEvents(t *testing.T) {
+	topic := "topic"
+	searchParams := entity.SearchFailedBrokerEventParams{Topic: &topic}
+	expRes := &entity.SearchFailedBrokerEventResult{Events: make([]*entity.FailedBrokerEvent, 1)}
+	expErr := fmt.Errorf("err")
+
+	uc := usecase.New(&mockStore{
+		searchEventsFunc: func(
+			ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+			assert.Equal(t, searchParams, params)
+
+			return expRes, expErr
+		},
+	}, nil)
+	actRes, actErr := uc.SearchEvents(_bgCtx, searchParams)
+
+	assert.Equal(t, expRes, actRes)
+	assert.Equal(t, expErr, actErr)
+}
+
+func TestResendEvent(t *testing.T) {
+	failed := &entity.FailedBrokerEvent{
+		ID:     "123",
+		Topic:  "topic",
+		Status: entity.StatusNew,
+		Data:   []byte(`{"id":"123"}`),
+		Error:  "error",
+	}
+
+	var statuses []string
+
+	s := &mockStore{
+		getEventByIDFunc: func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+			assert.Equal(t, failed.ID, id)
+
+			ev := *failed
+
+			return &ev, nil
+		},
+		updateEventStatusFunc: func(ctx context.Context, id, status string) error {
+			assert.Equal(t, failed.ID, id)
+
+			statuses = append(statuses, status)
+
+			return nil
+		},
+		claimEventResendFunc: func(ctx context.Context, id string, claimedAfter time.Time) (bool, error) {
+			assert.Equal(t, failed.ID, id)
+			assert.True(t, claimedAfter.Before(time.Now()))
+
+			return true, nil
+		},
+	}
+
+	var sent []event.BaseEvent
+
+	qb := &mockBroker{
+		sendFunc: func(ctx context.Context, topic string, e event.BaseEvent) error {
+			assert.Equal(t, failed.Topic, topic)
+
+			sent = append(sent, e)
+
+			return nil
+		},
+	}
+
+	uc := usecase.New(s, qb)
+
+	actual, err := uc.ResendEvent(_bgCtx, failed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusResendedWithSuccess, actual.Status)
+	assert.Equal(t, []string{entity.StatusResendedWithSuccess}, statuses)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, failed.ID, sent[0].GetID())
+	assert.Equal(t, []byte(failed.Data), sent[0].ToByte())
+
+	// send error is returned after the status is saved
+	expErr := fmt.Errorf("send error")
+	qb.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		return expErr
+	}
+
+	_, err = uc.ResendEvent(_bgCtx, failed.ID)
+	assert.Equal(t, expErr, err)
+	assert.Equal(t, []string{entity.StatusResendedWithSuccess, entity.StatusResendedWithError}, statuses)
+
+	// the event claimed by a concurrent request isn't sent
+	s.claimEventResendFunc = func(ctx context.Context, id string, claimedAfter time.Time) (bool, error) {
+		return false, nil
+	}
+
+	_, err = uc.ResendEvent(_bgCtx, failed.ID)
+	assert.Error(t, err)
+	assert.Equal(t, "failed broker event 123 is already resent or being resent", err.Error())
+	assert.Len(t, sent, 1)
+	assert.Len(t, statuses, 2)
+
+	// successfully resent event isn't sent again
+	failed.Status = entity.StatusResendedWithSuccess
+
+	_, err = uc.ResendEvent(_bgCtx, failed.ID)
+	assert.Error(t, err)
+	assert.Equal(t, "failed broker event 123 is already resent", err.Error())
+	assert.Len(t, sent, 1)
+}
@@ -10,6 +10,10 @@ import (
 
 type Store interface {
 	SaveEvent(ctx context.Context, e *entity.FailedBrokerEvent) (*entity.FailedBrokerEvent, error)
+	GetEventByID(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	SearchEvents(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	UpdateEventStatus(ctx context.Context, id, status string) error
 }
 
 type ErrorInterceptor struct {
@@ -57,9 +61,5 @@ func (eh ErrorHandler) GetEventData(_ context.Context) event.BaseEvent {
 }
 
 func (eh ErrorHandler) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
-	if ed, ok := e.(*entity.ErrorEvent); ok {
-		return eh.errorStoreHandlerFn(reqCtx, ed, eventData)
-	}
-
-	return nil
+	return provider.CallTyped(reqCtx, e, eventData, eh.errorStoreHandlerFn)
 }
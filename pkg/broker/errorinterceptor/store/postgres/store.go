@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"kafka-polygon/pkg/broker/errorinterceptor/entity"
 	"kafka-polygon/pkg/cerror"
@@ -12,6 +14,11 @@ import (
 	"github.com/uptrace/bun"
 )
 
+const (
+	defLimit  = 10
+	defOffset = 0
+)
+
 type Store struct {
 	db *bun.DB
 }
@@ -64,28 +71,140 @@ func (r *Store) SaveEvent(ctx context.Context, e *entity.FailedBrokerEvent) (*en
 	}, nil
 }
 
-func (r *Store) SearchEvents(ctx context.Context) ([]*entity.FailedBrokerEvent, error) {
+func (r *Store) GetEventByID(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	dst := &failedBrokerEvent{}
+
+	err := r.db.NewSelect().Model(dst).Where("id=?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, cerror.NewF(ctx, cerror.KindNotExist, "failed broker event %s not found", id).LogError()
+		}
+
+		return nil, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"get failed broker event by id --> %+v", err).LogError()
+	}
+
+	return dst.toEntity(), nil
+}
+
+func (r *Store) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
 	events := make([]*failedBrokerEvent, 0)
-	err := r.db.NewSelect().Model(&events).Order("created_at").Scan(ctx)
+	q := r.db.NewSelect().Model(&events)
 
+	if params.Topic != nil {
+		q.Where("topic=?", *params.Topic)
+	}
+
+	if params.Status != nil {
+		q.Where("status=?", *params.Status)
+	}
+
+	if params.CreatedFrom != nil {
+		q.Where("created_at>=?", params.CreatedFrom.UTC())
+	}
+
+	if params.CreatedTo != nil {
+		q.Where("created_at<=?", params.CreatedTo.UTC())
+	}
+
+	if params.Error != nil {
+		q.Where("error ILIKE ?", "%"+escapeLike(*params.Error)+"%")
+	}
+
+	p := entity.Paging{
+		Limit:  defLimit,
+		Offset: defOffset,
+	}
+	if params.Paging != nil {
+		p.Offset = params.Paging.Offset
+		p.Limit = params.Paging.Limit
+	}
+
+	err := q.Offset(p.Offset).Limit(p.Limit).Order("created_at").Scan(ctx)
 	if err != nil {
 		return nil, cerror.NewF(ctx,
 			cerror.DBToKind(err),
-			"get all failed broker events --> %+v", err).LogError()
+			"search failed broker events --> %+v", err).LogError()
 	}
 
 	result := make([]*entity.FailedBrokerEvent, len(events))
 
 	for i := range events {
-		result[i] = &entity.FailedBrokerEvent{
-			ID:        events[i].ID,
-			CreatedAt: events[i].CreatedAt,
-			Data:      events[i].Data,
-			Error:     events[i].Error,
-		}
+		result[i] = events[i].toEntity()
+	}
+
+	return &entity.SearchFailedBrokerEventResult{
+		Events: result,
+		Paging: p,
+	}, nil
+}
+
+func (r *Store) UpdateEventStatus(ctx context.Context, id, status string) error {
+	res, err := r.db.NewUpdate().
+		Model((*failedBrokerEvent)(nil)).
+		Set("status=?", status).
+		Set("updated_at=?", time.Now().UTC()).
+		Where("id=?", id).
+		Exec(ctx)
+	if err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"update failed broker event status --> %+v", err).LogError()
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return cerror.NewF(ctx, cerror.KindNotExist, "failed broker event %s not found", id).LogError()
 	}
 
-	return result, nil
+	return nil
+}
+
+// ClaimEventResend sets the resending status to the event unless it's resent with success
+// or is being resent since claimedAfter, in a single update
+func (r *Store) ClaimEventResend(ctx context.Context, id string, claimedAfter time.Time) (bool, error) {
+	res, err := r.db.NewUpdate().
+		Model((*failedBrokerEvent)(nil)).
+		Set("status=?", entity.StatusResending).
+		Set("updated_at=?", time.Now().UTC()).
+		Where("id=?", id).
+		Where("status<>?", entity.StatusResendedWithSuccess).
+		WhereGroup(" AND ", func(q *bun.UpdateQuery) *bun.UpdateQuery {
+			return q.Where("status<>?", entity.StatusResending).WhereOr("updated_at<?", claimedAfter.UTC())
+		}).
+		Exec(ctx)
+	if err != nil {
+		return false, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"claim failed broker event resend --> %+v", err).LogError()
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"claim failed broker event resend rows affected --> %+v", err).LogError()
+	}
+
+	return n == 1, nil
+}
+
+func (e *failedBrokerEvent) toEntity() *entity.FailedBrokerEvent {
+	return &entity.FailedBrokerEvent{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+		Status:    e.Status,
+		Topic:     e.Topic,
+		Data:      e.Data,
+		Error:     e.Error,
+	}
+}
+
+// escapeLike escapes wildcards of LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 func onConflictUpdateValuesFromColumns(columns []string) string {
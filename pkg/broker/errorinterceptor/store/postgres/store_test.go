@@ -13,6 +13,7 @@ import (
 	"kafka-polygon/pkg/log"
 	"kafka-polygon/pkg/testutil"
 	"testing"
+	"time"
 
 	bindata "github.com/golang-migrate/migrate/v4/source/go_bindata"
 	"github.com/jmoiron/sqlx"
@@ -111,9 +112,128 @@ func (ts *repoTestSuite) TestSearchEvent() {
 	_, err := ts.repo.SaveEvent(_bgCtx, e)
 	ts.NoError(err)
 
-	events, err := ts.repo.SearchEvents(_bgCtx)
+	res, err := ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{})
 	ts.NoError(err)
-	ts.Equal(1, len(events))
+	ts.Equal(1, len(res.Events))
+	ts.Equal(e.Topic, res.Events[0].Topic)
+	ts.Equal(entity.Paging{Limit: 10}, res.Paging)
+}
+
+func (ts *repoTestSuite) TestSearchEventsByParams() {
+	for _, e := range []*entity.FailedBrokerEvent{
+		{ID: "id1", Topic: "topic1", Status: entity.StatusNew, Data: []byte("{}"), Error: "Connection refused"},
+		{ID: "id2", Topic: "topic1", Status: entity.StatusResendedWithError, Data: []byte("{}"), Error: "timeout"},
+		{ID: "id3", Topic: "topic2", Status: entity.StatusNew, Data: []byte("{}"), Error: "50% done"},
+	} {
+		_, err := ts.repo.SaveEvent(_bgCtx, e)
+		ts.NoError(err)
+	}
+
+	topic := "topic1"
+	res, err := ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{Topic: &topic})
+	ts.NoError(err)
+	ts.Equal(2, len(res.Events))
+
+	status := entity.StatusNew
+	res, err = ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{Topic: &topic, Status: &status})
+	ts.NoError(err)
+	ts.Equal(1, len(res.Events))
+	ts.Equal("id1", res.Events[0].ID)
+
+	errText := "REFUSED"
+	res, err = ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{Error: &errText})
+	ts.NoError(err)
+	ts.Equal(1, len(res.Events))
+	ts.Equal("id1", res.Events[0].ID)
+
+	errText = "%"
+	res, err = ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{Error: &errText})
+	ts.NoError(err)
+	ts.Equal(1, len(res.Events))
+	ts.Equal("id3", res.Events[0].ID)
+
+	from := time.Now().Add(time.Hour)
+	res, err = ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{CreatedFrom: &from})
+	ts.NoError(err)
+	ts.Equal(0, len(res.Events))
+
+	to := time.Now().Add(time.Hour)
+	res, err = ts.repo.SearchEvents(_bgCtx, entity.SearchFailedBrokerEventParams{
+		CreatedTo: &to,
+		Paging:    &entity.Paging{Limit: 2, Offset: 1},
+	})
+	ts.NoError(err)
+	ts.Equal(2, len(res.Events))
+}
+
+func (ts *repoTestSuite) TestGetAndUpdateEventStatus() {
+	e := &entity.FailedBrokerEvent{
+		ID:     "id",
+		Topic:  "topic",
+		Status: entity.StatusNew,
+		Data:   []byte("{}"),
+		Error:  "error",
+	}
+	_, err := ts.repo.SaveEvent(_bgCtx, e)
+	ts.NoError(err)
+
+	err = ts.repo.UpdateEventStatus(_bgCtx, e.ID, entity.StatusResendedWithSuccess)
+	ts.NoError(err)
+
+	actual, err := ts.repo.GetEventByID(_bgCtx, e.ID)
+	ts.NoError(err)
+	ts.Equal(entity.StatusResendedWithSuccess, actual.Status)
+	ts.Equal(e.Topic, actual.Topic)
+	ts.Equal(e.Error, actual.Error)
+
+	_, err = ts.repo.GetEventByID(_bgCtx, "unknown")
+	ts.Error(err)
+	ts.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	err = ts.repo.UpdateEventStatus(_bgCtx, "unknown", entity.StatusResendedWithSuccess)
+	ts.Error(err)
+	ts.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+}
+
+func (ts *repoTestSuite) TestClaimEventResend() {
+	e := &entity.FailedBrokerEvent{
+		ID:     "claim-id",
+		Topic:  "topic",
+		Status: entity.StatusNew,
+		Data:   []byte("{}"),
+		Error:  "error",
+	}
+	_, err := ts.repo.SaveEvent(_bgCtx, e)
+	ts.NoError(err)
+
+	claimed, err := ts.repo.ClaimEventResend(_bgCtx, e.ID, time.Now().Add(-time.Minute))
+	ts.NoError(err)
+	ts.True(claimed)
+
+	actual, err := ts.repo.GetEventByID(_bgCtx, e.ID)
+	ts.NoError(err)
+	ts.Equal(entity.StatusResending, actual.Status)
+
+	// the event being resent isn't claimed by a concurrent request
+	claimed, err = ts.repo.ClaimEventResend(_bgCtx, e.ID, time.Now().Add(-time.Minute))
+	ts.NoError(err)
+	ts.False(claimed)
+
+	// the resend left by a crashed instance is claimed again
+	claimed, err = ts.repo.ClaimEventResend(_bgCtx, e.ID, time.Now().Add(time.Minute))
+	ts.NoError(err)
+	ts.True(claimed)
+
+	err = ts.repo.UpdateEventStatus(_bgCtx, e.ID, entity.StatusResendedWithSuccess)
+	ts.NoError(err)
+
+	claimed, err = ts.repo.ClaimEventResend(_bgCtx, e.ID, time.Now().Add(time.Minute))
+	ts.NoError(err)
+	ts.False(claimed)
+
+	claimed, err = ts.repo.ClaimEventResend(_bgCtx, "unknown", time.Now())
+	ts.NoError(err)
+	ts.False(claimed)
 }
 
 func (ts *repoTestSuite) deleteAllRecordsByTableName(tableName string) {
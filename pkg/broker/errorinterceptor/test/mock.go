@@ -9,7 +9,11 @@ import (
 )
 
 type mockStoreProvider struct {
-	saveEventFunc func(ctx context.Context, e *entity.FailedBrokerEvent) (*entity.FailedBrokerEvent, error)
+	saveEventFunc    func(ctx context.Context, e *entity.FailedBrokerEvent) (*entity.FailedBrokerEvent, error)
+	getEventByIDFunc func(ctx context.Context, id string) (*entity.FailedBrokerEvent, error)
+	searchEventsFunc func(
+		ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error)
+	updateEventStatusFunc func(ctx context.Context, id, status string) error
 }
 
 func (m *mockStoreProvider) SaveEvent(ctx context.Context, e *entity.FailedBrokerEvent) (
@@ -17,6 +21,19 @@ func (m *mockStoreProvider) SaveEvent(ctx context.Context, e *entity.FailedBroke
 	return m.saveEventFunc(ctx, e)
 }
 
+func (m *mockStoreProvider) GetEventByID(ctx context.Context, id string) (*entity.FailedBrokerEvent, error) {
+	return m.getEventByIDFunc(ctx, id)
+}
+
+func (m *mockStoreProvider) SearchEvents(
+	ctx context.Context, params entity.SearchFailedBrokerEventParams) (*entity.SearchFailedBrokerEventResult, error) {
+	return m.searchEventsFunc(ctx, params)
+}
+
+func (m *mockStoreProvider) UpdateEventStatus(ctx context.Context, id, status string) error {
+	return m.updateEventStatusFunc(ctx, id, status)
+}
+
 type mockOriginalFn struct {
 	getEventDataFunc func(_ context.Context) event.BaseEvent
 	callFnFunc       func(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error
@@ -5,6 +5,15 @@ import (
 	"kafka-polygon/pkg/cmd/metadata"
 )
 
+// Types of the events written to the event type header of records, see provider.EventTyper
+const (
+	TypeConnector    = "ConnectorData"
+	TypeDebezium     = "DebeziumData"
+	TypeMinio        = "MinioData"
+	TypeNotification = "NotificationData"
+	TypeWorkflow     = "WorkflowData"
+)
+
 type Message struct {
 	Key   *[]byte
 	Value []byte
@@ -3,8 +3,11 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/event/eventpb"
 	"kafka-polygon/pkg/cmd/metadata"
 	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
 type ConnectorEvent interface {
@@ -43,6 +46,11 @@ func (cd *ConnectorData) GetInstance() interface{} {
 	return cd.Instance
 }
 
+// EventType of ConnectorData
+func (cd *ConnectorData) EventType() string {
+	return TypeConnector
+}
+
 func (cd *ConnectorData) GetID() string {
 	return cd.ID
 }
@@ -76,3 +84,66 @@ func (cd *ConnectorData) GetMeta() metadata.Meta {
 func (cd *ConnectorData) WithMeta(meta metadata.Meta) {
 	cd.Metadata = meta
 }
+
+// ConnectorProtoSchema is the protobuf schema of ConnectorData to register for topics encoded by protobuf
+var ConnectorProtoSchema = eventpb.ConnectorSchema
+
+// MarshalProto encodes ConnectorData by ConnectorProtoSchema
+func (cd *ConnectorData) MarshalProto() ([]byte, error) {
+	m := &eventpb.ConnectorEvent{
+		Id:           cd.ID,
+		Type:         cd.Type,
+		Notification: cd.Notification,
+		Header:       &eventpb.ConnectorEvent_Header{RequestId: cd.Header.RequestID},
+		Debug:        cd.Debug,
+		Metadata: &eventpb.ConnectorEvent_Metadata{
+			Version:   cd.Metadata.Version,
+			Module:    cd.Metadata.Module,
+			BuildDate: cd.Metadata.BuildDate,
+		},
+	}
+
+	if cd.Instance != nil {
+		instance, err := json.Marshal(cd.Instance)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Instance = instance
+	}
+
+	if !cd.Time.IsZero() {
+		m.Time = &eventpb.ConnectorEvent_Timestamp{Seconds: cd.Time.Unix(), Nanos: int32(cd.Time.Nanosecond())}
+	}
+
+	return proto.Marshal(m)
+}
+
+// UnmarshalProto decodes ConnectorData by ConnectorProtoSchema
+func (cd *ConnectorData) UnmarshalProto(data []byte) error {
+	var m eventpb.ConnectorEvent
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	cd.ID = m.GetId()
+	cd.Type = m.GetType()
+	cd.Notification = m.GetNotification()
+	cd.Header.RequestID = m.GetHeader().GetRequestId()
+	cd.Debug = m.GetDebug()
+	cd.Metadata = metadata.Meta{
+		Version:   m.GetMetadata().GetVersion(),
+		Module:    m.GetMetadata().GetModule(),
+		BuildDate: m.GetMetadata().GetBuildDate(),
+	}
+
+	if m.Time != nil {
+		cd.Time = time.Unix(m.Time.GetSeconds(), int64(m.Time.GetNanos())).UTC()
+	}
+
+	if len(m.GetInstance()) > 0 {
+		return json.Unmarshal(m.GetInstance(), &cd.Instance)
+	}
+
+	return nil
+}
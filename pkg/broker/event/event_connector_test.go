@@ -102,3 +102,25 @@ func TestConnectorEventError(t *testing.T) {
 	err := e.Unmarshal(msg)
 	require.Error(t, err)
 }
+
+func TestConnectorEventProto(t *testing.T) {
+	t.Parallel()
+
+	e := event.ConnectorData{
+		ID:           "test-id",
+		Type:         "test-type",
+		Notification: "test-notification",
+		Instance:     map[string]interface{}{"key": "val", "num": float64(1)},
+		Header:       expHeader,
+		Time:         time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC),
+		Debug:        true,
+		Metadata:     expMeta,
+	}
+
+	b, err := e.MarshalProto()
+	require.NoError(t, err)
+
+	var decoded event.ConnectorData
+	require.NoError(t, decoded.UnmarshalProto(b))
+	assert.Equal(t, e, decoded)
+}
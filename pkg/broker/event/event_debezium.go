@@ -3,8 +3,11 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/event/eventpb"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/cmd/metadata"
+
+	"google.golang.org/protobuf/proto"
 )
 
 type DebeziumEvent interface {
@@ -55,6 +58,11 @@ func (dd *DebeziumData) WithContext(ctx context.Context) {
 	dd.ctx = ctx
 }
 
+// EventType of DebeziumData
+func (dd *DebeziumData) EventType() string {
+	return TypeDebezium
+}
+
 // GetID of DebeziumData
 func (dd *DebeziumData) GetID() string {
 	if dd.Key != nil {
@@ -117,3 +125,85 @@ func (dd *DebeziumData) GetMeta() metadata.Meta {
 func (dd *DebeziumData) WithMeta(meta metadata.Meta) {
 	dd.Metadata = meta
 }
+
+// DebeziumProtoSchema is the protobuf schema of DebeziumData to register for topics encoded by protobuf
+var DebeziumProtoSchema = eventpb.DebeziumSchema
+
+// MarshalProto encodes DebeziumData by DebeziumProtoSchema
+func (dd *DebeziumData) MarshalProto() ([]byte, error) {
+	m := &eventpb.DebeziumEvent{
+		Id:     dd.GetID(),
+		Header: &eventpb.DebeziumEvent_Header{RequestId: dd.Header.RequestID},
+		Debug:  dd.Debug,
+		Metadata: &eventpb.DebeziumEvent_Metadata{
+			Version:   dd.Metadata.Version,
+			Module:    dd.Metadata.Module,
+			BuildDate: dd.Metadata.BuildDate,
+		},
+	}
+
+	if dd.Payload != nil {
+		m.Payload = &eventpb.DebeziumPayload{
+			Table: dd.Payload.Source.Table,
+			Op:    dd.Payload.Op,
+		}
+
+		var err error
+
+		if dd.Payload.BeforeValues != nil {
+			if m.Payload.Before, err = json.Marshal(dd.Payload.BeforeValues); err != nil {
+				return nil, err
+			}
+		}
+
+		if dd.Payload.AfterValues != nil {
+			if m.Payload.After, err = json.Marshal(dd.Payload.AfterValues); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return proto.Marshal(m)
+}
+
+// UnmarshalProto decodes DebeziumData by DebeziumProtoSchema
+func (dd *DebeziumData) UnmarshalProto(data []byte) error {
+	var m eventpb.DebeziumEvent
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if dd.Key == nil {
+		dd.Key = &KeyData{}
+	}
+
+	dd.Key.Payload.ID = m.GetId()
+	dd.Header.RequestID = m.GetHeader().GetRequestId()
+	dd.Debug = m.GetDebug()
+	dd.Metadata = metadata.Meta{
+		Version:   m.GetMetadata().GetVersion(),
+		Module:    m.GetMetadata().GetModule(),
+		BuildDate: m.GetMetadata().GetBuildDate(),
+	}
+
+	if m.Payload == nil {
+		return nil
+	}
+
+	dd.Payload = &DebeziumPayload{
+		Source: DebeziumSource{Table: m.Payload.GetTable()},
+		Op:     m.Payload.GetOp(),
+	}
+
+	if len(m.Payload.GetBefore()) > 0 {
+		if err := json.Unmarshal(m.Payload.GetBefore(), &dd.Payload.BeforeValues); err != nil {
+			return err
+		}
+	}
+
+	if len(m.Payload.GetAfter()) > 0 {
+		return json.Unmarshal(m.Payload.GetAfter(), &dd.Payload.AfterValues)
+	}
+
+	return nil
+}
@@ -106,3 +106,26 @@ func TestDebeziumDataError(t *testing.T) {
 	err := e.Unmarshal(msg)
 	require.Error(t, err)
 }
+
+func TestDebeziumDataProto(t *testing.T) {
+	t.Parallel()
+
+	e := event.DebeziumData{
+		Key:      &key,
+		Payload:  &payload,
+		Header:   expHeader,
+		Debug:    true,
+		Metadata: expMeta,
+	}
+
+	b, err := e.MarshalProto()
+	require.NoError(t, err)
+
+	decoded := event.NewDebeziumData().(*event.DebeziumData)
+	require.NoError(t, decoded.UnmarshalProto(b))
+	assert.Equal(t, e.GetID(), decoded.GetID())
+	assert.Equal(t, &payload, decoded.GetPayload())
+	assert.Equal(t, expHeader, decoded.GetHeader())
+	assert.Equal(t, true, decoded.GetDebug())
+	assert.Equal(t, expMeta, decoded.GetMeta())
+}
@@ -3,10 +3,12 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/event/eventpb"
 	"kafka-polygon/pkg/cmd/metadata"
 	"time"
 
 	"github.com/minio/minio-go/v7/pkg/notification"
+	"google.golang.org/protobuf/proto"
 )
 
 type MinioEvent interface {
@@ -96,6 +98,11 @@ func (md *MinioData) GetEventName() string {
 	return md.EventName
 }
 
+// EventType of MinioData
+func (md *MinioData) EventType() string {
+	return TypeMinio
+}
+
 // GetID of MinioData
 func (md *MinioData) GetID() string {
 	return md.Key
@@ -136,3 +143,154 @@ func (md *MinioData) GetMeta() metadata.Meta {
 func (md *MinioData) WithMeta(meta metadata.Meta) {
 	md.Metadata = meta
 }
+
+// MinioProtoSchema is the protobuf schema of MinioData to register for topics encoded by protobuf
+var MinioProtoSchema = eventpb.MinioSchema
+
+// MarshalProto encodes MinioData by MinioProtoSchema
+func (md *MinioData) MarshalProto() ([]byte, error) {
+	m := &eventpb.MinioEvent{
+		EventName: md.EventName,
+		Key:       md.Key,
+		Records:   make([]*eventpb.Record, 0, len(md.Records)),
+		Header:    &eventpb.MinioEvent_Header{RequestId: md.Header.RequestID},
+		Debug:     md.Debug,
+		Metadata: &eventpb.MinioEvent_Metadata{
+			Version:   md.Metadata.Version,
+			Module:    md.Metadata.Module,
+			BuildDate: md.Metadata.BuildDate,
+		},
+	}
+
+	for _, r := range md.Records {
+		if r == nil {
+			r = &Record{}
+		}
+
+		m.Records = append(m.Records, r.toProto())
+	}
+
+	return proto.Marshal(m)
+}
+
+// UnmarshalProto decodes MinioData by MinioProtoSchema
+func (md *MinioData) UnmarshalProto(data []byte) error {
+	var m eventpb.MinioEvent
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	md.EventName = m.GetEventName()
+	md.Key = m.GetKey()
+	md.Header.RequestID = m.GetHeader().GetRequestId()
+	md.Debug = m.GetDebug()
+	md.Metadata = metadata.Meta{
+		Version:   m.GetMetadata().GetVersion(),
+		Module:    m.GetMetadata().GetModule(),
+		BuildDate: m.GetMetadata().GetBuildDate(),
+	}
+
+	for _, pr := range m.GetRecords() {
+		md.Records = append(md.Records, recordFromProto(pr))
+	}
+
+	return nil
+}
+
+func (r *Record) toProto() *eventpb.Record {
+	pr := &eventpb.Record{
+		EventVersion: r.EventVersion,
+		EventSource:  r.EventSource,
+		AwsRegion:    r.AwsRegion,
+		EventName:    string(r.EventName),
+		UserIdentity: r.UserIdentity.PrincipalID,
+		RequestParameters: &eventpb.RequestParameters{
+			PrincipalId:     r.RequestParameters.PrincipalID,
+			Region:          r.RequestParameters.Region,
+			SourceIpAddress: r.RequestParameters.SourceIPAddress,
+		},
+		ResponseElements: &eventpb.ResponseElements{
+			ContentLength:        r.ResponseElements.ContentLength,
+			XAmzRequestId:        r.ResponseElements.XAmzRequestID,
+			XMinioDeploymentId:   r.ResponseElements.XMinioDeploymentID,
+			XMinioOriginEndpoint: r.ResponseElements.XMinioOriginEndpoint,
+		},
+		S3: &eventpb.S3{
+			S3SchemaVersion: r.S3.S3SchemaVersion,
+			ConfigurationId: r.S3.ConfigurationID,
+			Bucket: &eventpb.Bucket{
+				Name:          r.S3.Bucket.Name,
+				OwnerIdentity: r.S3.Bucket.OwnerIdentity.PrincipalID,
+				Arn:           r.S3.Bucket.Arn,
+			},
+			Object: &eventpb.Object{
+				Key:          r.S3.Object.Key,
+				Size:         int64(r.S3.Object.Size),
+				ETag:         r.S3.Object.ETag,
+				ContentType:  r.S3.Object.ContentType,
+				UserMetadata: r.S3.Object.UserMetadata,
+				Sequencer:    r.S3.Object.Sequencer,
+			},
+		},
+		Source: &eventpb.Source{
+			Host:      r.Source.Host,
+			Port:      r.Source.Port,
+			UserAgent: r.Source.UserAgent,
+		},
+	}
+
+	if !r.EventTime.IsZero() {
+		pr.EventTime = &eventpb.MinioEvent_Timestamp{Seconds: r.EventTime.Unix(), Nanos: int32(r.EventTime.Nanosecond())}
+	}
+
+	return pr
+}
+
+func recordFromProto(pr *eventpb.Record) *Record {
+	r := &Record{
+		EventVersion: pr.GetEventVersion(),
+		EventSource:  pr.GetEventSource(),
+		AwsRegion:    pr.GetAwsRegion(),
+		EventName:    notification.EventType(pr.GetEventName()),
+		UserIdentity: PrincipalID{PrincipalID: pr.GetUserIdentity()},
+		RequestParameters: RequestParameters{
+			PrincipalID:     pr.GetRequestParameters().GetPrincipalId(),
+			Region:          pr.GetRequestParameters().GetRegion(),
+			SourceIPAddress: pr.GetRequestParameters().GetSourceIpAddress(),
+		},
+		ResponseElements: ResponseElements{
+			ContentLength:        pr.GetResponseElements().GetContentLength(),
+			XAmzRequestID:        pr.GetResponseElements().GetXAmzRequestId(),
+			XMinioDeploymentID:   pr.GetResponseElements().GetXMinioDeploymentId(),
+			XMinioOriginEndpoint: pr.GetResponseElements().GetXMinioOriginEndpoint(),
+		},
+		S3: ObjS3{
+			S3SchemaVersion: pr.GetS3().GetS3SchemaVersion(),
+			ConfigurationID: pr.GetS3().GetConfigurationId(),
+			Bucket: Bucket{
+				Name:          pr.GetS3().GetBucket().GetName(),
+				OwnerIdentity: PrincipalID{PrincipalID: pr.GetS3().GetBucket().GetOwnerIdentity()},
+				Arn:           pr.GetS3().GetBucket().GetArn(),
+			},
+			Object: Object{
+				Key:          pr.GetS3().GetObject().GetKey(),
+				Size:         int(pr.GetS3().GetObject().GetSize()),
+				ETag:         pr.GetS3().GetObject().GetETag(),
+				ContentType:  pr.GetS3().GetObject().GetContentType(),
+				UserMetadata: pr.GetS3().GetObject().GetUserMetadata(),
+				Sequencer:    pr.GetS3().GetObject().GetSequencer(),
+			},
+		},
+		Source: Source{
+			Host:      pr.GetSource().GetHost(),
+			Port:      pr.GetSource().GetPort(),
+			UserAgent: pr.GetSource().GetUserAgent(),
+		},
+	}
+
+	if pr.EventTime != nil {
+		r.EventTime = time.Unix(pr.EventTime.GetSeconds(), int64(pr.EventTime.GetNanos())).UTC()
+	}
+
+	return r
+}
@@ -137,3 +137,28 @@ func TestMinioEventError(t *testing.T) {
 	err := e.Unmarshal(msg)
 	require.Error(t, err)
 }
+
+func TestMinioEventProto(t *testing.T) {
+	t.Parallel()
+
+	e := event.MinioData{
+		EventName: "test-event-name",
+		Key:       "test-key",
+		Records:   records,
+		Header:    expHeader,
+		Debug:     true,
+		Metadata:  expMeta,
+	}
+
+	b, err := e.MarshalProto()
+	require.NoError(t, err)
+
+	var decoded event.MinioData
+	require.NoError(t, decoded.UnmarshalProto(b))
+	require.Len(t, decoded.Records, 1)
+	assert.True(t, records[0].EventTime.Equal(decoded.Records[0].EventTime))
+
+	// the time is decoded in UTC
+	decoded.Records[0].EventTime = records[0].EventTime
+	assert.Equal(t, e, decoded)
+}
@@ -3,8 +3,11 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/event/eventpb"
 	"kafka-polygon/pkg/cmd/metadata"
 	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
 type NotificationEvent interface {
@@ -29,6 +32,11 @@ type NotificationData struct {
 	Metadata metadata.Meta `json:"metadata"`
 }
 
+// EventType of NotificationData
+func (nd *NotificationData) EventType() string {
+	return TypeNotification
+}
+
 // GetID of NotificationData
 func (nd *NotificationData) GetID() string {
 	return nd.ID
@@ -89,3 +97,55 @@ func (nd *NotificationData) GetMeta() metadata.Meta {
 func (nd *NotificationData) WithMeta(meta metadata.Meta) {
 	nd.Metadata = meta
 }
+
+// NotificationProtoSchema is the protobuf schema of NotificationData to register for topics encoded by protobuf
+var NotificationProtoSchema = eventpb.NotificationSchema
+
+// MarshalProto encodes NotificationData by NotificationProtoSchema
+func (nd *NotificationData) MarshalProto() ([]byte, error) {
+	m := &eventpb.NotificationEvent{
+		Id:         nd.ID,
+		WorkflowId: nd.WorkflowID,
+		Channel:    nd.Channel,
+		Provider:   nd.Provider,
+		Header:     &eventpb.NotificationEvent_Header{RequestId: nd.Header.RequestID},
+		Debug:      nd.Debug,
+		Metadata: &eventpb.NotificationEvent_Metadata{
+			Version:   nd.Metadata.Version,
+			Module:    nd.Metadata.Module,
+			BuildDate: nd.Metadata.BuildDate,
+		},
+	}
+
+	if !nd.Time.IsZero() {
+		m.Time = &eventpb.NotificationEvent_Timestamp{Seconds: nd.Time.Unix(), Nanos: int32(nd.Time.Nanosecond())}
+	}
+
+	return proto.Marshal(m)
+}
+
+// UnmarshalProto decodes NotificationData by NotificationProtoSchema
+func (nd *NotificationData) UnmarshalProto(data []byte) error {
+	var m eventpb.NotificationEvent
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	nd.ID = m.GetId()
+	nd.WorkflowID = m.GetWorkflowId()
+	nd.Channel = m.GetChannel()
+	nd.Provider = m.GetProvider()
+	nd.Header.RequestID = m.GetHeader().GetRequestId()
+	nd.Debug = m.GetDebug()
+	nd.Metadata = metadata.Meta{
+		Version:   m.GetMetadata().GetVersion(),
+		Module:    m.GetMetadata().GetModule(),
+		BuildDate: m.GetMetadata().GetBuildDate(),
+	}
+
+	if m.Time != nil {
+		nd.Time = time.Unix(m.Time.GetSeconds(), int64(m.Time.GetNanos())).UTC()
+	}
+
+	return nil
+}
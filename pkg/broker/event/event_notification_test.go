@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"kafka-polygon/pkg/broker/event"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/tj/assert"
@@ -95,3 +96,25 @@ func TestNotificationEventError(t *testing.T) {
 	err := e.Unmarshal(msg)
 	require.Error(t, err)
 }
+
+func TestNotificationEventProto(t *testing.T) {
+	t.Parallel()
+
+	e := event.NotificationData{
+		ID:         "test-id",
+		WorkflowID: "test-workflow-id",
+		Channel:    "test-channel",
+		Provider:   "test-provider",
+		Header:     expHeader,
+		Time:       time.Date(2023, 5, 1, 12, 30, 0, 123, time.UTC),
+		Debug:      true,
+		Metadata:   expMeta,
+	}
+
+	b, err := e.MarshalProto()
+	require.NoError(t, err)
+
+	var decoded event.NotificationData
+	require.NoError(t, decoded.UnmarshalProto(b))
+	assert.Equal(t, e, decoded)
+}
@@ -3,7 +3,10 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/event/eventpb"
 	"kafka-polygon/pkg/cmd/metadata"
+
+	"google.golang.org/protobuf/proto"
 )
 
 // WorkflowEvent workflow event abstraction
@@ -30,12 +33,28 @@ type Workflow struct {
 	// that will be passed to workflow's step handler.
 	// So it makes sense to passthrough it to the handler as a raw message.
 	StepPayload json.RawMessage `json:"step_payload"`
+	// Compensation is set for events undoing the step of a failed workflow
+	// instead of running it.
+	Compensation bool `json:"compensation,omitempty"`
+	// Attempt is a number of the run of the step, zero is the same as the first one.
+	// It's set for runs of the step scheduled after its failures.
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// EventType of WorkflowData
+func (w *WorkflowData) EventType() string {
+	return TypeWorkflow
 }
 
 func (w *WorkflowData) GetID() string {
 	return w.ID
 }
 
+// EventKey returns the workflow ID, so events of the workflow are kept in order by the broker
+func (w *WorkflowData) EventKey() string {
+	return w.Workflow.ID
+}
+
 func (w *WorkflowData) GetWorkflow() Workflow {
 	return w.Workflow
 }
@@ -72,3 +91,55 @@ func (w *WorkflowData) GetMeta() metadata.Meta {
 func (w *WorkflowData) WithMeta(meta metadata.Meta) {
 	w.Metadata = meta
 }
+
+// WorkflowProtoSchema is the protobuf schema of WorkflowData to register for topics encoded by protobuf
+var WorkflowProtoSchema = eventpb.WorkflowSchema
+
+// MarshalProto encodes WorkflowData by WorkflowProtoSchema
+func (w *WorkflowData) MarshalProto() ([]byte, error) {
+	return proto.Marshal(&eventpb.WorkflowEvent{
+		Id:     w.ID,
+		Header: &eventpb.WorkflowEvent_Header{RequestId: w.Header.RequestID},
+		Workflow: &eventpb.Workflow{
+			Id:           w.Workflow.ID,
+			Schema:       w.Workflow.Schema,
+			Step:         w.Workflow.Step,
+			StepPayload:  w.Workflow.StepPayload,
+			Compensation: w.Workflow.Compensation,
+			Attempt:      int64(w.Workflow.Attempt),
+		},
+		Debug: w.Debug,
+		Metadata: &eventpb.WorkflowEvent_Metadata{
+			Version:   w.Metadata.Version,
+			Module:    w.Metadata.Module,
+			BuildDate: w.Metadata.BuildDate,
+		},
+	})
+}
+
+// UnmarshalProto decodes WorkflowData by WorkflowProtoSchema
+func (w *WorkflowData) UnmarshalProto(data []byte) error {
+	var m eventpb.WorkflowEvent
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	w.ID = m.GetId()
+	w.Header.RequestID = m.GetHeader().GetRequestId()
+	w.Workflow = Workflow{
+		ID:           m.GetWorkflow().GetId(),
+		Schema:       m.GetWorkflow().GetSchema(),
+		Step:         m.GetWorkflow().GetStep(),
+		StepPayload:  m.GetWorkflow().GetStepPayload(),
+		Compensation: m.GetWorkflow().GetCompensation(),
+		Attempt:      int(m.GetWorkflow().GetAttempt()),
+	}
+	w.Debug = m.GetDebug()
+	w.Metadata = metadata.Meta{
+		Version:   m.GetMetadata().GetVersion(),
+		Module:    m.GetMetadata().GetModule(),
+		BuildDate: m.GetMetadata().GetBuildDate(),
+	}
+
+	return nil
+}
@@ -118,3 +118,38 @@ func TestWorkflowEventError(t *testing.T) {
 	err := e.Unmarshal(msg)
 	require.Error(t, err)
 }
+
+func TestWorkflowEventProto(t *testing.T) {
+	t.Parallel()
+
+	e := event.WorkflowData{
+		ID:     "test-id",
+		Header: expHeader,
+		Workflow: event.Workflow{
+			ID:           "test-wf-id",
+			Schema:       "test-type",
+			Step:         "test-task",
+			StepPayload:  json.RawMessage(`{"key":"val"}`),
+			Compensation: true,
+			Attempt:      2,
+		},
+		Debug:    true,
+		Metadata: expMeta,
+	}
+
+	b, err := e.MarshalProto()
+	require.NoError(t, err)
+
+	var decoded event.WorkflowData
+	require.NoError(t, decoded.UnmarshalProto(b))
+	assert.Equal(t, e, decoded)
+
+	// fields unknown to the event are skipped
+	b = append(b, 0x50, 1, 0x5a, 1, 'x')
+
+	decoded = event.WorkflowData{}
+	require.NoError(t, decoded.UnmarshalProto(b))
+	assert.Equal(t, e, decoded)
+
+	require.Error(t, decoded.UnmarshalProto([]byte{0x0a, 5, 'a'}))
+}
@@ -0,0 +1,442 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: eventpb/connector.proto
+
+package eventpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ConnectorEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type         string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Notification string `protobuf:"bytes,3,opt,name=notification,proto3" json:"notification,omitempty"`
+	// instance is set by connectors of different types, so it's kept as JSON
+	Instance []byte                    `protobuf:"bytes,4,opt,name=instance,proto3" json:"instance,omitempty"`
+	Header   *ConnectorEvent_Header    `protobuf:"bytes,5,opt,name=header,proto3" json:"header,omitempty"`
+	Time     *ConnectorEvent_Timestamp `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	Debug    bool                      `protobuf:"varint,7,opt,name=debug,proto3" json:"debug,omitempty"`
+	Metadata *ConnectorEvent_Metadata  `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (x *ConnectorEvent) Reset() {
+	*x = ConnectorEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_connector_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConnectorEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectorEvent) ProtoMessage() {}
+
+func (x *ConnectorEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_connector_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectorEvent.ProtoReflect.Descriptor instead.
+func (*ConnectorEvent) Descriptor() ([]byte, []int) {
+	return file_eventpb_connector_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ConnectorEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ConnectorEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ConnectorEvent) GetNotification() string {
+	if x != nil {
+		return x.Notification
+	}
+	return ""
+}
+
+func (x *ConnectorEvent) GetInstance() []byte {
+	if x != nil {
+		return x.Instance
+	}
+	return nil
+}
+
+func (x *ConnectorEvent) GetHeader() *ConnectorEvent_Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *ConnectorEvent) GetTime() *ConnectorEvent_Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ConnectorEvent) GetDebug() bool {
+	if x != nil {
+		return x.Debug
+	}
+	return false
+}
+
+func (x *ConnectorEvent) GetMetadata() *ConnectorEvent_Metadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type ConnectorEvent_Header struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+}
+
+func (x *ConnectorEvent_Header) Reset() {
+	*x = ConnectorEvent_Header{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_connector_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConnectorEvent_Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectorEvent_Header) ProtoMessage() {}
+
+func (x *ConnectorEvent_Header) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_connector_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectorEvent_Header.ProtoReflect.Descriptor instead.
+func (*ConnectorEvent_Header) Descriptor() ([]byte, []int) {
+	return file_eventpb_connector_proto_rawDescGZIP(), []int{0, 0}
+}
+
+func (x *ConnectorEvent_Header) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type ConnectorEvent_Metadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version   string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Module    string `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
+	BuildDate string `protobuf:"bytes,3,opt,name=build_date,json=buildDate,proto3" json:"build_date,omitempty"`
+}
+
+func (x *ConnectorEvent_Metadata) Reset() {
+	*x = ConnectorEvent_Metadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_connector_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConnectorEvent_Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectorEvent_Metadata) ProtoMessage() {}
+
+func (x *ConnectorEvent_Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_connector_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectorEvent_Metadata.ProtoReflect.Descriptor instead.
+func (*ConnectorEvent_Metadata) Descriptor() ([]byte, []int) {
+	return file_eventpb_connector_proto_rawDescGZIP(), []int{0, 1}
+}
+
+func (x *ConnectorEvent_Metadata) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ConnectorEvent_Metadata) GetModule() string {
+	if x != nil {
+		return x.Module
+	}
+	return ""
+}
+
+func (x *ConnectorEvent_Metadata) GetBuildDate() string {
+	if x != nil {
+		return x.BuildDate
+	}
+	return ""
+}
+
+// Timestamp has the same fields as google.protobuf.Timestamp
+type ConnectorEvent_Timestamp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3" json:"nanos,omitempty"`
+}
+
+func (x *ConnectorEvent_Timestamp) Reset() {
+	*x = ConnectorEvent_Timestamp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_connector_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConnectorEvent_Timestamp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectorEvent_Timestamp) ProtoMessage() {}
+
+func (x *ConnectorEvent_Timestamp) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_connector_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectorEvent_Timestamp.ProtoReflect.Descriptor instead.
+func (*ConnectorEvent_Timestamp) Descriptor() ([]byte, []int) {
+	return file_eventpb_connector_proto_rawDescGZIP(), []int{0, 2}
+}
+
+func (x *ConnectorEvent_Timestamp) GetSeconds() int64 {
+	if x != nil {
+		return x.Seconds
+	}
+	return 0
+}
+
+func (x *ConnectorEvent_Timestamp) GetNanos() int32 {
+	if x != nil {
+		return x.Nanos
+	}
+	return 0
+}
+
+var File_eventpb_connector_proto protoreflect.FileDescriptor
+
+var file_eventpb_connector_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x2f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x6b, 0x61, 0x66, 0x6b, 0x61,
+	0x2e, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x9e,
+	0x04, 0x0a, 0x0e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x6e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6e, 0x6f, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x69, 0x6e, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x42, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2a, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e, 0x70, 0x6f,
+	0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x41, 0x0a, 0x04, 0x74, 0x69, 0x6d,
+	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e,
+	0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x64, 0x65, 0x62, 0x75, 0x67, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x64, 0x65, 0x62,
+	0x75, 0x67, 0x12, 0x48, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e, 0x70, 0x6f, 0x6c,
+	0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x65,
+	0x63, 0x74, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x27, 0x0a, 0x06,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x49, 0x64, 0x1a, 0x5b, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x6f, 0x64,
+	0x75, 0x6c, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x64, 0x61, 0x74,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x44, 0x61,
+	0x74, 0x65, 0x1a, 0x3b, 0x0a, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12,
+	0x18, 0x0a, 0x07, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x61, 0x6e,
+	0x6f, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x42,
+	0x28, 0x5a, 0x26, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2d, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e,
+	0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x62, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x2f, 0x65, 0x76, 0x65, 0x6e,
+	0x74, 0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_eventpb_connector_proto_rawDescOnce sync.Once
+	file_eventpb_connector_proto_rawDescData = file_eventpb_connector_proto_rawDesc
+)
+
+func file_eventpb_connector_proto_rawDescGZIP() []byte {
+	file_eventpb_connector_proto_rawDescOnce.Do(func() {
+		file_eventpb_connector_proto_rawDescData = protoimpl.X.CompressGZIP(file_eventpb_connector_proto_rawDescData)
+	})
+	return file_eventpb_connector_proto_rawDescData
+}
+
+var file_eventpb_connector_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_eventpb_connector_proto_goTypes = []any{
+	(*ConnectorEvent)(nil),           // 0: kafka.polygon.event.ConnectorEvent
+	(*ConnectorEvent_Header)(nil),    // 1: kafka.polygon.event.ConnectorEvent.Header
+	(*ConnectorEvent_Metadata)(nil),  // 2: kafka.polygon.event.ConnectorEvent.Metadata
+	(*ConnectorEvent_Timestamp)(nil), // 3: kafka.polygon.event.ConnectorEvent.Timestamp
+}
+var file_eventpb_connector_proto_depIdxs = []int32{
+	1, // 0: kafka.polygon.event.ConnectorEvent.header:type_name -> kafka.polygon.event.ConnectorEvent.Header
+	3, // 1: kafka.polygon.event.ConnectorEvent.time:type_name -> kafka.polygon.event.ConnectorEvent.Timestamp
+	2, // 2: kafka.polygon.event.ConnectorEvent.metadata:type_name -> kafka.polygon.event.ConnectorEvent.Metadata
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_eventpb_connector_proto_init() }
+func file_eventpb_connector_proto_init() {
+	if File_eventpb_connector_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_eventpb_connector_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ConnectorEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_connector_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ConnectorEvent_Header); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_connector_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ConnectorEvent_Metadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_connector_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*ConnectorEvent_Timestamp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_eventpb_connector_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_eventpb_connector_proto_goTypes,
+		DependencyIndexes: file_eventpb_connector_proto_depIdxs,
+		MessageInfos:      file_eventpb_connector_proto_msgTypes,
+	}.Build()
+	File_eventpb_connector_proto = out.File
+	file_eventpb_connector_proto_rawDesc = nil
+	file_eventpb_connector_proto_goTypes = nil
+	file_eventpb_connector_proto_depIdxs = nil
+}
@@ -0,0 +1,429 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: eventpb/debezium.proto
+
+package eventpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DebeziumEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       string                  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Payload  *DebeziumPayload        `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Header   *DebeziumEvent_Header   `protobuf:"bytes,3,opt,name=header,proto3" json:"header,omitempty"`
+	Debug    bool                    `protobuf:"varint,4,opt,name=debug,proto3" json:"debug,omitempty"`
+	Metadata *DebeziumEvent_Metadata `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (x *DebeziumEvent) Reset() {
+	*x = DebeziumEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_debezium_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebeziumEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebeziumEvent) ProtoMessage() {}
+
+func (x *DebeziumEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_debezium_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebeziumEvent.ProtoReflect.Descriptor instead.
+func (*DebeziumEvent) Descriptor() ([]byte, []int) {
+	return file_eventpb_debezium_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DebeziumEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DebeziumEvent) GetPayload() *DebeziumPayload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *DebeziumEvent) GetHeader() *DebeziumEvent_Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *DebeziumEvent) GetDebug() bool {
+	if x != nil {
+		return x.Debug
+	}
+	return false
+}
+
+func (x *DebeziumEvent) GetMetadata() *DebeziumEvent_Metadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// Values of rows have columns of any tables, so they're kept as JSON objects.
+type DebeziumPayload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Table  string `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
+	Before []byte `protobuf:"bytes,2,opt,name=before,proto3" json:"before,omitempty"`
+	After  []byte `protobuf:"bytes,3,opt,name=after,proto3" json:"after,omitempty"`
+	Op     string `protobuf:"bytes,4,opt,name=op,proto3" json:"op,omitempty"`
+}
+
+func (x *DebeziumPayload) Reset() {
+	*x = DebeziumPayload{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_debezium_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebeziumPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebeziumPayload) ProtoMessage() {}
+
+func (x *DebeziumPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_debezium_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebeziumPayload.ProtoReflect.Descriptor instead.
+func (*DebeziumPayload) Descriptor() ([]byte, []int) {
+	return file_eventpb_debezium_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DebeziumPayload) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *DebeziumPayload) GetBefore() []byte {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *DebeziumPayload) GetAfter() []byte {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+func (x *DebeziumPayload) GetOp() string {
+	if x != nil {
+		return x.Op
+	}
+	return ""
+}
+
+type DebeziumEvent_Header struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+}
+
+func (x *DebeziumEvent_Header) Reset() {
+	*x = DebeziumEvent_Header{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_debezium_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebeziumEvent_Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebeziumEvent_Header) ProtoMessage() {}
+
+func (x *DebeziumEvent_Header) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_debezium_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebeziumEvent_Header.ProtoReflect.Descriptor instead.
+func (*DebeziumEvent_Header) Descriptor() ([]byte, []int) {
+	return file_eventpb_debezium_proto_rawDescGZIP(), []int{0, 0}
+}
+
+func (x *DebeziumEvent_Header) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type DebeziumEvent_Metadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version   string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Module    string `protobuf:"bytes,2,opt,name=module,proto3" json:"module,omitempty"`
+	BuildDate string `protobuf:"bytes,3,opt,name=build_date,json=buildDate,proto3" json:"build_date,omitempty"`
+}
+
+func (x *DebeziumEvent_Metadata) Reset() {
+	*x = DebeziumEvent_Metadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_eventpb_debezium_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DebeziumEvent_Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DebeziumEvent_Metadata) ProtoMessage() {}
+
+func (x *DebeziumEvent_Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_eventpb_debezium_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DebeziumEvent_Metadata.ProtoReflect.Descriptor instead.
+func (*DebeziumEvent_Metadata) Descriptor() ([]byte, []int) {
+	return file_eventpb_debezium_proto_rawDescGZIP(), []int{0, 1}
+}
+
+func (x *DebeziumEvent_Metadata) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *DebeziumEvent_Metadata) GetModule() string {
+	if x != nil {
+		return x.Module
+	}
+	return ""
+}
+
+func (x *DebeziumEvent_Metadata) GetBuildDate() string {
+	if x != nil {
+		return x.BuildDate
+	}
+	return ""
+}
+
+var File_eventpb_debezium_proto protoreflect.FileDescriptor
+
+var file_eventpb_debezium_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x2f, 0x64, 0x65, 0x62, 0x65, 0x7a, 0x69,
+	0x75, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e,
+	0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x87, 0x03,
+	0x0a, 0x0d, 0x44, 0x65, 0x62, 0x65, 0x7a, 0x69, 0x75, 0x6d, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x3e, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x24, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e,
+	0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x44, 0x65, 0x62, 0x65, 0x7a, 0x69, 0x75, 0x6d, 0x50,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12,
+	0x41, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x29, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2e, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e,
+	0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x44, 0x65, 0x62, 0x65, 0x7a, 0x69, 0x75, 0x6d, 0x45, 0x76,
+	0x65, 0x6e, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x62, 0x75, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x05, 0x64, 0x65, 0x62, 0x75, 0x67, 0x12, 0x47, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x6b, 0x61, 0x66,
+	0x6b, 0x61, 0x2e, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x2e, 0x44, 0x65, 0x62, 0x65, 0x7a, 0x69, 0x75, 0x6d, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x1a, 0x27, 0x0a, 0x06, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x72,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x1a, 0x5b, 0x0a, 0x08, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x75, 0x69, 0x6c,
+	0x64, 0x5f, 0x64, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x62, 0x75,
+	0x69, 0x6c, 0x64, 0x44, 0x61, 0x74, 0x65, 0x22, 0x65, 0x0a, 0x0f, 0x44, 0x65, 0x62, 0x65, 0x7a,
+	0x69, 0x75, 0x6d, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61,
+	0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x61, 0x62, 0x6c, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x66, 0x74, 0x65,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x61, 0x66, 0x74, 0x65, 0x72, 0x12, 0x0e,
+	0x0a, 0x02, 0x6f, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x6f, 0x70, 0x42, 0x28,
+	0x5a, 0x26, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x2d, 0x70, 0x6f, 0x6c, 0x79, 0x67, 0x6f, 0x6e, 0x2f,
+	0x70, 0x6b, 0x67, 0x2f, 0x62, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_eventpb_debezium_proto_rawDescOnce sync.Once
+	file_eventpb_debezium_proto_rawDescData = file_eventpb_debezium_proto_rawDesc
+)
+
+func file_eventpb_debezium_proto_rawDescGZIP() []byte {
+	file_eventpb_debezium_proto_rawDescOnce.Do(func() {
+		file_eventpb_debezium_proto_rawDescData = protoimpl.X.CompressGZIP(file_eventpb_debezium_proto_rawDescData)
+	})
+	return file_eventpb_debezium_proto_rawDescData
+}
+
+var file_eventpb_debezium_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_eventpb_debezium_proto_goTypes = []any{
+	(*DebeziumEvent)(nil),          // 0: kafka.polygon.event.DebeziumEvent
+	(*DebeziumPayload)(nil),        // 1: kafka.polygon.event.DebeziumPayload
+	(*DebeziumEvent_Header)(nil),   // 2: kafka.polygon.event.DebeziumEvent.Header
+	(*DebeziumEvent_Metadata)(nil), // 3: kafka.polygon.event.DebeziumEvent.Metadata
+}
+var file_eventpb_debezium_proto_depIdxs = []int32{
+	1, // 0: kafka.polygon.event.DebeziumEvent.payload:type_name -> kafka.polygon.event.DebeziumPayload
+	2, // 1: kafka.polygon.event.DebeziumEvent.header:type_name -> kafka.polygon.event.DebeziumEvent.Header
+	3, // 2: kafka.polygon.event.DebeziumEvent.metadata:type_name -> kafka.polygon.event.DebeziumEvent.Metadata
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_eventpb_debezium_proto_init() }
+func file_eventpb_debezium_proto_init() {
+	if File_eventpb_debezium_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_eventpb_debezium_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*DebeziumEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_debezium_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*DebeziumPayload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_debezium_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*DebeziumEvent_Header); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_eventpb_debezium_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*DebeziumEvent_Metadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_eventpb_debezium_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_eventpb_debezium_proto_goTypes,
+		DependencyIndexes: file_eventpb_debezium_proto_depIdxs,
+		MessageInfos:      file_eventpb_debezium_proto_msgTypes,
+	}.Build()
+	File_eventpb_debezium_proto = out.File
+	file_eventpb_debezium_proto_rawDesc = nil
+	file_eventpb_debezium_proto_goTypes = nil
+	file_eventpb_debezium_proto_depIdxs = nil
+}
@@ -0,0 +1,23 @@
+// Package eventpb holds protobuf messages of the events generated from the .proto files of the package
+package eventpb
+
+import (
+	// embeds the schemas
+	_ "embed"
+)
+
+//go:generate protoc --proto_path=.. --go_out=.. --go_opt=paths=source_relative eventpb/connector.proto eventpb/debezium.proto eventpb/minio.proto eventpb/notification.proto eventpb/workflow.proto
+
+// Schemas of the events to register for topics encoded by protobuf. The event is the first message of its schema.
+var (
+	//go:embed connector.proto
+	ConnectorSchema string
+	//go:embed debezium.proto
+	DebeziumSchema string
+	//go:embed minio.proto
+	MinioSchema string
+	//go:embed notification.proto
+	NotificationSchema string
+	//go:embed workflow.proto
+	WorkflowSchema string
+)
@@ -0,0 +1,388 @@
+// Package harness measures duplicates, losses, reorderings and latency of kafka client libraries.
+// A scenario is run against each library with the same producers, consumers joining and leaving the group
+// and broker restarts, so the results of the libraries can be compared in one report.
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"strconv"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	defaultTopic                 = "harness"
+	defaultProducers             = 1
+	defaultMessagesPerProducer   = 100
+	defaultConsumers             = 1
+	defaultConsumerJoinInterval  = 5 * time.Second
+	defaultConsumerLeaveInterval = 5 * time.Second
+	defaultBrokerRestartInterval = 10 * time.Second
+	defaultDrainTimeout          = time.Minute
+	drainCheckInterval           = 100 * time.Millisecond
+
+	eventSchema = "harness"
+)
+
+// ErrUnsupported is returned by Library.NewProvider if the library can't run the scenario
+var ErrUnsupported = errors.New("unsupported by the library")
+
+// Library creates providers of a kafka client library
+type Library interface {
+	Name() string
+	// NewProvider returns a provider consuming the topic in the group.
+	// Idempotence and transactions of the scenario are applied to the producer.
+	NewProvider(s Scenario, groupID string) (provider.Provider, error)
+}
+
+// Scenario describes the load run against each library.
+// Consumers join the group one by one, then ConsumersLeaving of them leave it one by one,
+// while producers publish their messages and the broker is restarted.
+type Scenario struct {
+	// Topic is a prefix of topics. Each library consumes its own topic <Topic>-<library>
+	Topic               string `json:"topic"`
+	Producers           int    `json:"producers"`
+	MessagesPerProducer int    `json:"messages_per_producer"`
+	// ProduceInterval is a delay between messages of a producer
+	ProduceInterval      time.Duration `json:"produce_interval"`
+	Consumers            int           `json:"consumers"`
+	ConsumerJoinInterval time.Duration `json:"consumer_join_interval"`
+	// ConsumersLeaving is a count of consumers leaving the group. At least one consumer stays in it
+	ConsumersLeaving      int           `json:"consumers_leaving"`
+	ConsumerLeaveInterval time.Duration `json:"consumer_leave_interval"`
+	BrokerRestarts        int           `json:"broker_restarts"`
+	BrokerRestartInterval time.Duration `json:"broker_restart_interval"`
+	// RestartBroker is called to restart the broker. Restarts are skipped if it's nil
+	RestartBroker func(ctx context.Context) error `json:"-"`
+	Idempotence   bool                            `json:"idempotence"`
+	Transactions  bool                            `json:"transactions"`
+	// DrainTimeout limits waiting for messages after the producers finished
+	DrainTimeout time.Duration `json:"drain_timeout"`
+}
+
+func (s *Scenario) initDefault() {
+	if s.Topic == "" {
+		s.Topic = defaultTopic
+	}
+
+	if s.Producers == 0 {
+		s.Producers = defaultProducers
+	}
+
+	if s.MessagesPerProducer == 0 {
+		s.MessagesPerProducer = defaultMessagesPerProducer
+	}
+
+	if s.Consumers == 0 {
+		s.Consumers = defaultConsumers
+	}
+
+	if s.ConsumersLeaving >= s.Consumers {
+		s.ConsumersLeaving = s.Consumers - 1
+	}
+
+	if s.ConsumerJoinInterval == 0 {
+		s.ConsumerJoinInterval = defaultConsumerJoinInterval
+	}
+
+	if s.ConsumerLeaveInterval == 0 {
+		s.ConsumerLeaveInterval = defaultConsumerLeaveInterval
+	}
+
+	if s.BrokerRestartInterval == 0 {
+		s.BrokerRestartInterval = defaultBrokerRestartInterval
+	}
+
+	if s.DrainTimeout == 0 {
+		s.DrainTimeout = defaultDrainTimeout
+	}
+}
+
+// Run runs the scenario against the libraries one by one
+func Run(ctx context.Context, s Scenario, libs ...Library) *Report {
+	s.initDefault()
+
+	rep := &Report{
+		Scenario:  s,
+		StartedAt: time.Now().UTC(),
+	}
+
+	for _, lib := range libs {
+		rep.Libraries = append(rep.Libraries, runLibrary(ctx, s, lib))
+	}
+
+	rep.FinishedAt = time.Now().UTC()
+
+	return rep
+}
+
+// probe is a payload of a published event used to match deliveries with publications
+type probe struct {
+	Producer int       `json:"producer"`
+	Seq      int       `json:"seq"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+type consumer struct {
+	p      provider.Provider
+	cancel context.CancelFunc
+}
+
+func (c *consumer) stop() {
+	c.cancel()
+	c.p.Stop()
+}
+
+// libraryRun is a run of the scenario against a library
+type libraryRun struct {
+	s         Scenario
+	lib       Library
+	runID     string
+	topic     string
+	groupID   string
+	rec       *recorder
+	mx        sync.Mutex
+	consumers []*consumer
+	errs      []string
+}
+
+func runLibrary(ctx context.Context, s Scenario, lib Library) LibraryReport {
+	runID := uuid.NewV4().String()
+	lr := &libraryRun{
+		s:       s,
+		lib:     lib,
+		runID:   runID,
+		topic:   fmt.Sprintf("%s-%s", s.Topic, lib.Name()),
+		groupID: fmt.Sprintf("%s-%s-%s", s.Topic, lib.Name(), runID),
+		rec:     newRecorder(runID),
+	}
+
+	res := LibraryReport{
+		Library: lib.Name(),
+		Topic:   lr.topic,
+		GroupID: lr.groupID,
+	}
+
+	log.InfoF(ctx, "[harness] run scenario against %s. topic: %s. group: %s", lib.Name(), lr.topic, lr.groupID)
+
+	// the first consumer is created before producers start to find out if the library supports the scenario
+	first, err := lr.startConsumer(ctx)
+	if err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			res.Skipped = err.Error()
+		} else {
+			res.Errors = append(res.Errors, err.Error())
+		}
+
+		return res
+	}
+
+	startedAt := time.Now()
+	lr.consumers = append(lr.consumers, first)
+
+	var wg sync.WaitGroup
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		lr.runConsumers(runCtx)
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		res.BrokerRestarts = lr.restartBroker(runCtx)
+	}()
+
+	lr.produce(ctx)
+	lr.drain(ctx)
+
+	cancel()
+	wg.Wait()
+
+	for _, c := range lr.consumers {
+		c.stop()
+	}
+
+	lr.rec.fill(&res)
+	res.Errors = append(res.Errors, lr.errs...)
+	res.Duration = time.Since(startedAt).String()
+
+	return res
+}
+
+func (lr *libraryRun) startConsumer(ctx context.Context) (*consumer, error) {
+	p, err := lr.lib.NewProvider(lr.s, lr.groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	p.Sync(cctx, lr.topic, lr.rec.handler())
+
+	return &consumer{p: p, cancel: cancel}, nil
+}
+
+// runConsumers starts the rest of consumers and then stops the leaving ones
+func (lr *libraryRun) runConsumers(ctx context.Context) {
+	for i := 1; i < lr.s.Consumers; i++ {
+		if !sleep(ctx, lr.s.ConsumerJoinInterval) {
+			return
+		}
+
+		c, err := lr.startConsumer(ctx)
+		if err != nil {
+			lr.addError(err)
+
+			return
+		}
+
+		lr.mx.Lock()
+		lr.consumers = append(lr.consumers, c)
+		lr.mx.Unlock()
+	}
+
+	for i := 0; i < lr.s.ConsumersLeaving; i++ {
+		if !sleep(ctx, lr.s.ConsumerLeaveInterval) {
+			return
+		}
+
+		lr.mx.Lock()
+		c := lr.consumers[0]
+		lr.consumers = lr.consumers[1:]
+		lr.mx.Unlock()
+
+		c.stop()
+	}
+}
+
+func (lr *libraryRun) restartBroker(ctx context.Context) int {
+	if lr.s.RestartBroker == nil {
+		return 0
+	}
+
+	restarts := 0
+
+	for i := 0; i < lr.s.BrokerRestarts; i++ {
+		if !sleep(ctx, lr.s.BrokerRestartInterval) {
+			break
+		}
+
+		if err := lr.s.RestartBroker(ctx); err != nil {
+			lr.addError(cerror.NewF(ctx, cerror.KindInternal, "[harness] restart broker. %s", err.Error()).LogError())
+
+			continue
+		}
+
+		restarts++
+	}
+
+	return restarts
+}
+
+func (lr *libraryRun) produce(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < lr.s.Producers; i++ {
+		p, err := lr.lib.NewProvider(lr.s, lr.groupID)
+		if err != nil {
+			lr.addError(err)
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(idx int, p provider.Provider) {
+			defer wg.Done()
+			defer p.Stop()
+
+			for seq := 0; seq < lr.s.MessagesPerProducer; seq++ {
+				if seq > 0 && !sleep(ctx, lr.s.ProduceInterval) {
+					return
+				}
+
+				pr := probe{Producer: idx, Seq: seq, SentAt: time.Now().UTC()}
+				e := lr.newEvent(pr)
+
+				if err := p.Publish(ctx, lr.topic, e); err != nil {
+					lr.rec.sendFailed()
+
+					continue
+				}
+
+				lr.rec.sent(e.GetID(), pr)
+			}
+		}(i, p)
+	}
+
+	wg.Wait()
+}
+
+// drain waits until all published messages are received or the drain timeout is over
+func (lr *libraryRun) drain(ctx context.Context) {
+	deadline := time.Now().Add(lr.s.DrainTimeout)
+
+	for !lr.rec.allReceived() && time.Now().Before(deadline) {
+		if !sleep(ctx, drainCheckInterval) {
+			return
+		}
+	}
+
+	// duplicates delivered right after the last message are counted too
+	sleep(ctx, drainCheckInterval)
+}
+
+func (lr *libraryRun) newEvent(pr probe) *event.WorkflowData {
+	payload, _ := json.Marshal(pr)
+
+	return &event.WorkflowData{
+		ID: fmt.Sprintf("%s-%d-%d", lr.runID, pr.Producer, pr.Seq),
+		Workflow: event.Workflow{
+			ID:          lr.runID,
+			Schema:      eventSchema,
+			Step:        strconv.Itoa(pr.Producer),
+			StepPayload: payload,
+		},
+	}
+}
+
+func (lr *libraryRun) addError(err error) {
+	lr.mx.Lock()
+	defer lr.mx.Unlock()
+
+	lr.errs = append(lr.errs, err.Error())
+}
+
+// sleep returns false if the context is done earlier
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
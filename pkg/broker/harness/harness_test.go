@@ -0,0 +1,118 @@
+package harness_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/harness"
+	"kafka-polygon/pkg/broker/provider"
+	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+const fakeLibrary = "fake"
+
+var bgCtx = context.Background()
+
+func fakeLibraryOf(fb *pKafka.FakeBroker) harness.Library {
+	return harness.NewLibrary(fakeLibrary, func(_ harness.Scenario, groupID string) (provider.Provider, error) {
+		cfg := &pKafka.Config{
+			AllowAutoTopicCreation: true,
+			Consumer: pKafka.Consumer{
+				GroupID:          groupID,
+				RebalanceTimeout: 20 * time.Millisecond,
+			},
+		}
+
+		p := pKafka.NewKafkaProvider(cfg)
+		p.SetClient(fb.NewClient(cfg))
+
+		return p, nil
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(2)
+	fb.CreateTopic("harness-fake", 2)
+
+	restarts := 0
+	s := harness.Scenario{
+		Producers:             2,
+		MessagesPerProducer:   20,
+		ProduceInterval:       time.Millisecond,
+		Consumers:             3,
+		ConsumerJoinInterval:  5 * time.Millisecond,
+		ConsumersLeaving:      5,
+		ConsumerLeaveInterval: 5 * time.Millisecond,
+		BrokerRestarts:        1,
+		BrokerRestartInterval: time.Millisecond,
+		RestartBroker: func(_ context.Context) error {
+			restarts++
+
+			return nil
+		},
+		DrainTimeout: 5 * time.Second,
+	}
+
+	rep := harness.Run(bgCtx, s, fakeLibraryOf(fb))
+
+	assert.Equal(t, 2, rep.Scenario.ConsumersLeaving)
+	require.Len(t, rep.Libraries, 1)
+
+	res := rep.Libraries[0]
+	assert.Equal(t, fakeLibrary, res.Library)
+	assert.Equal(t, "harness-fake", res.Topic)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, 40, res.Sent)
+	assert.Equal(t, 40, res.Unique)
+	assert.Equal(t, 0, res.Lost)
+	assert.Equal(t, res.Received-res.Unique, res.Duplicates)
+	assert.Equal(t, 1, res.BrokerRestarts)
+	assert.Equal(t, 1, restarts)
+	assert.True(t, res.Latency.Max >= res.Latency.P50)
+
+	var buf bytes.Buffer
+	require.NoError(t, rep.WriteJSON(&buf))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded["libraries"], 1)
+}
+
+func TestRunUnsupportedLibrary(t *testing.T) {
+	t.Parallel()
+
+	unsupported := harness.NewLibrary("unsupported", func(_ harness.Scenario, _ string) (provider.Provider, error) {
+		return nil, fmt.Errorf("transactions are %w", harness.ErrUnsupported)
+	})
+	failing := harness.NewLibrary("failing", func(_ harness.Scenario, _ string) (provider.Provider, error) {
+		return nil, errors.New("no brokers")
+	})
+
+	rep := harness.Run(bgCtx, harness.Scenario{Transactions: true}, unsupported, failing)
+
+	require.Len(t, rep.Libraries, 2)
+	assert.Equal(t, "transactions are unsupported by the library", rep.Libraries[0].Skipped)
+	assert.Empty(t, rep.Libraries[0].Errors)
+	assert.Empty(t, rep.Libraries[1].Skipped)
+	assert.Equal(t, []string{"no brokers"}, rep.Libraries[1].Errors)
+}
+
+func TestSegmentioUnsupportedIdempotence(t *testing.T) {
+	t.Parallel()
+
+	_, err := harness.Segmentio(pKafka.Config{}).NewProvider(harness.Scenario{Idempotence: true}, "group")
+	assert.True(t, errors.Is(err, harness.ErrUnsupported))
+
+	p, err := harness.Segmentio(pKafka.Config{}).NewProvider(harness.Scenario{}, "group")
+	require.NoError(t, err)
+	assert.Equal(t, pKafka.BrokerKafkaProvider, p.GetType())
+}
@@ -0,0 +1,74 @@
+package harness
+
+import (
+	"fmt"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/confluent"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/provider/sarama"
+)
+
+const LibrarySegmentio = "segmentio"
+
+type library struct {
+	name        string
+	newProvider func(s Scenario, groupID string) (provider.Provider, error)
+}
+
+func (l *library) Name() string {
+	return l.name
+}
+
+func (l *library) NewProvider(s Scenario, groupID string) (provider.Provider, error) {
+	return l.newProvider(s, groupID)
+}
+
+// NewLibrary creates a library from a provider factory
+func NewLibrary(name string, newProvider func(s Scenario, groupID string) (provider.Provider, error)) Library {
+	return &library{name: name, newProvider: newProvider}
+}
+
+// Segmentio is a library of the segmentio provider.
+// The provider has neither an idempotent nor a transactional producer.
+func Segmentio(cfg kafka.Config) Library {
+	return NewLibrary(LibrarySegmentio, func(s Scenario, groupID string) (provider.Provider, error) {
+		if s.Idempotence || s.Transactions {
+			return nil, fmt.Errorf("idempotent and transactional producers are %w", ErrUnsupported)
+		}
+
+		c := cfg
+		c.Consumer.GroupID = groupID
+
+		return kafka.NewKafkaProvider(&c), nil
+	})
+}
+
+// Sarama is a library of the sarama providers. Transactions are run by the transactional provider.
+func Sarama(cfg sarama.Config) Library {
+	return NewLibrary(sarama.BrokerSaramaProvider, func(s Scenario, groupID string) (provider.Provider, error) {
+		c := cfg
+		c.Consumer.GroupID = groupID
+		c.Producer.Idempotent = s.Idempotence
+
+		if s.Transactions {
+			return sarama.NewTxProvider(&c), nil
+		}
+
+		return sarama.NewProviderWithConfig(&c), nil
+	})
+}
+
+// Confluent is a library of the confluent provider. It has no transactional producer.
+func Confluent(cfg confluent.Config) Library {
+	return NewLibrary(confluent.BrokerConfluentProvider, func(s Scenario, groupID string) (provider.Provider, error) {
+		if s.Transactions {
+			return nil, fmt.Errorf("transactional producer is %w", ErrUnsupported)
+		}
+
+		c := cfg
+		c.Consumer.GroupID = groupID
+		c.Producer.Idempotent = s.Idempotence
+
+		return confluent.NewProviderWithConfig(&c), nil
+	})
+}
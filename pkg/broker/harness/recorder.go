@@ -0,0 +1,169 @@
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/store"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recorder matches deliveries of a run with its publications
+type recorder struct {
+	mx         sync.Mutex
+	runID      string
+	sentProbes map[string]probe
+	sendErrors int
+	deliveries map[string]int
+	// maxSeq is the greatest sequence number delivered from each producer
+	maxSeq    map[int]int
+	reordered int
+	received  int
+	latencies []time.Duration
+}
+
+func newRecorder(runID string) *recorder {
+	return &recorder{
+		runID:      runID,
+		sentProbes: make(map[string]probe),
+		deliveries: make(map[string]int),
+		maxSeq:     make(map[int]int),
+	}
+}
+
+// handler returns a handler recording deliveries
+func (r *recorder) handler() provider.HandlerWorkflow {
+	return func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) error {
+		r.delivered(e, time.Now().UTC())
+
+		return nil
+	}
+}
+
+// sent records a publication acknowledged by the broker
+func (r *recorder) sent(id string, pr probe) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.sentProbes[id] = pr
+}
+
+func (r *recorder) sendFailed() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.sendErrors++
+}
+
+// delivered records a delivery. Events of other runs left in the topic are ignored.
+// A first delivery is reordered if a later message of the same producer was delivered before it.
+func (r *recorder) delivered(e event.WorkflowEvent, at time.Time) {
+	w := e.GetWorkflow()
+	if w.ID != r.runID {
+		return
+	}
+
+	var pr probe
+	if err := json.Unmarshal(w.StepPayload, &pr); err != nil {
+		return
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.received++
+	r.deliveries[e.GetID()]++
+
+	if r.deliveries[e.GetID()] > 1 {
+		return
+	}
+
+	r.latencies = append(r.latencies, at.Sub(pr.SentAt))
+
+	if maxSeq, ok := r.maxSeq[pr.Producer]; ok && pr.Seq < maxSeq {
+		r.reordered++
+
+		return
+	}
+
+	r.maxSeq[pr.Producer] = pr.Seq
+}
+
+// allReceived returns true if each acknowledged publication is delivered
+func (r *recorder) allReceived() bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for id := range r.sentProbes {
+		if r.deliveries[id] == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *recorder) fill(res *LibraryReport) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	res.Sent = len(r.sentProbes)
+	res.SendErrors = r.sendErrors
+	res.Received = r.received
+	res.Unique = len(r.deliveries)
+	res.Duplicates = r.received - len(r.deliveries)
+	res.Reordered = r.reordered
+
+	for id, cnt := range r.deliveries {
+		if cnt > 1 {
+			res.DuplicatedEvents++
+		}
+
+		if _, ok := r.sentProbes[id]; !ok {
+			// the publication failed on the client side, but the broker wrote the message
+			res.UnackedReceived++
+		}
+	}
+
+	for id := range r.sentProbes {
+		if r.deliveries[id] == 0 {
+			res.Lost++
+		}
+	}
+
+	res.Latency = newLatency(r.latencies)
+}
+
+func newLatency(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return Latency{
+		P50: toMs(percentile(sorted, 50)),
+		P95: toMs(percentile(sorted, 95)),
+		P99: toMs(percentile(sorted, 99)),
+		Max: toMs(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
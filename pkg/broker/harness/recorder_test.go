@@ -0,0 +1,55 @@
+package harness
+
+import (
+	"encoding/json"
+	"kafka-polygon/pkg/broker/event"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func testEvent(runID string, pr probe) *event.WorkflowData {
+	payload, _ := json.Marshal(pr)
+
+	return &event.WorkflowData{
+		ID:       runID + "-" + string(rune('a'+pr.Seq)),
+		Workflow: event.Workflow{ID: runID, StepPayload: payload},
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	r := newRecorder("run")
+
+	probes := make([]probe, 4)
+	for i := range probes {
+		probes[i] = probe{Seq: i, SentAt: now.Add(-time.Duration(i+1) * time.Millisecond)}
+		r.sent(testEvent("run", probes[i]).GetID(), probes[i])
+	}
+
+	r.sendFailed()
+
+	r.delivered(testEvent("run", probes[0]), now)
+	r.delivered(testEvent("run", probes[2]), now)
+	r.delivered(testEvent("run", probes[1]), now)
+	r.delivered(testEvent("run", probes[2]), now)
+	r.delivered(testEvent("other-run", probes[3]), now)
+
+	assert.False(t, r.allReceived())
+
+	var res LibraryReport
+	r.fill(&res)
+
+	assert.Equal(t, 4, res.Sent)
+	assert.Equal(t, 1, res.SendErrors)
+	assert.Equal(t, 4, res.Received)
+	assert.Equal(t, 3, res.Unique)
+	assert.Equal(t, 1, res.Duplicates)
+	assert.Equal(t, 1, res.DuplicatedEvents)
+	assert.Equal(t, 1, res.Lost)
+	assert.Equal(t, 1, res.Reordered)
+	assert.Equal(t, Latency{P50: 2, P95: 3, P99: 3, Max: 3}, res.Latency)
+}
@@ -0,0 +1,63 @@
+package harness
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Report is a result of a scenario run against the libraries
+type Report struct {
+	Scenario   Scenario        `json:"scenario"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Libraries  []LibraryReport `json:"libraries"`
+}
+
+// WriteJSON writes the indented report
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// LibraryReport is a result of the scenario run against a library
+type LibraryReport struct {
+	Library string `json:"library"`
+	// Skipped is a reason why the library didn't run the scenario
+	Skipped string   `json:"skipped,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Topic   string   `json:"topic"`
+	GroupID string   `json:"group_id"`
+	// Sent is a count of publications acknowledged by the broker
+	Sent       int `json:"sent"`
+	SendErrors int `json:"send_errors"`
+	// Received is a count of all deliveries including duplicates
+	Received int `json:"received"`
+	// Unique is a count of delivered events
+	Unique int `json:"unique"`
+	// Duplicates is a count of repeated deliveries
+	Duplicates int `json:"duplicates"`
+	// DuplicatedEvents is a count of events delivered more than once
+	DuplicatedEvents int `json:"duplicated_events"`
+	// UnackedReceived is a count of delivered events which publications returned an error
+	UnackedReceived int `json:"unacked_received"`
+	// Lost is a count of acknowledged publications which were never delivered
+	Lost int `json:"lost"`
+	// Reordered is a count of events delivered after a later event of the same producer.
+	// Events are keyed by ID, so events of a producer are spread over partitions of the topic
+	// and only a single partition topic guarantees the order.
+	Reordered      int     `json:"reordered"`
+	Latency        Latency `json:"latency_ms"`
+	BrokerRestarts int     `json:"broker_restarts"`
+	Duration       string  `json:"duration"`
+}
+
+// Latency of first deliveries since publications in milliseconds
+type Latency struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
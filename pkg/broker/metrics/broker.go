@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Metrics of the broker registered in DefaultRegistry
+var (
+	MessagesPublished = DefaultRegistry.NewCounter("broker_messages_published_total",
+		"Count of events published to the topic.", "provider", "topic")
+	PublishErrors = DefaultRegistry.NewCounter("broker_publish_errors_total",
+		"Count of events failed to be published to the topic.", "provider", "topic")
+	MessagesConsumed = DefaultRegistry.NewCounter("broker_messages_consumed_total",
+		"Count of messages of the topic passed to handlers.", "provider", "topic")
+	HandlerDuration = DefaultRegistry.NewHistogram("broker_handler_duration_seconds",
+		"Latency of handlers of messages of the topic.", DefBuckets, "provider", "topic")
+	HandlerErrors = DefaultRegistry.NewCounter("broker_handler_errors_total",
+		"Count of messages of the topic failed in handlers.", "provider", "topic")
+	DuplicatesSkipped = DefaultRegistry.NewCounter("broker_duplicates_skipped_total",
+		"Count of events of the topic skipped because they are already handled.", "topic")
+	CommitFailures = DefaultRegistry.NewCounter("broker_commit_failures_total",
+		"Count of offsets of the topic failed to be committed.", "provider", "topic")
+	ListenerRestarts = DefaultRegistry.NewCounter("broker_listener_restarts_total",
+		"Count of restarts of stopped listeners of the topics.", "provider", "topic")
+	ConsumerLag = DefaultRegistry.NewGauge("broker_consumer_lag",
+		"Count of messages of the partition behind the committed offset of the consumer group.",
+		"group", "topic", "partition")
+)
+
+// ObservePublish counts n events published by the provider to the topic, all of them failed if err isn't nil
+func ObservePublish(provider, topic string, n int, err error) {
+	if err != nil {
+		PublishErrors.Add(float64(n), provider, topic)
+
+		return
+	}
+
+	MessagesPublished.Add(float64(n), provider, topic)
+}
+
+// ObserveHandle counts a message of the topic handled since start and its error
+func ObserveHandle(provider, topic string, start time.Time, err error) {
+	MessagesConsumed.Inc(provider, topic)
+	HandlerDuration.ObserveSince(start, provider, topic)
+
+	if err != nil {
+		HandlerErrors.Inc(provider, topic)
+	}
+}
+
+// SetConsumerLag sets the lag of the partition as the difference between the high watermark and the committed offset
+func SetConsumerLag(group, topic string, partition int, highWaterMark, committed int64) {
+	lag := highWaterMark - committed
+	if lag < 0 {
+		lag = 0
+	}
+
+	ConsumerLag.Set(float64(lag), group, topic, strconv.Itoa(partition))
+}
+
+// DeleteConsumerLag removes the lag of the partition revoked from the consumer
+func DeleteConsumerLag(group, topic string, partition int) {
+	ConsumerLag.Delete(group, topic, strconv.Itoa(partition))
+}
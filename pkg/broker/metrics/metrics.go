@@ -0,0 +1,286 @@
+// Package metrics collects metrics of the broker and exposes them in the Prometheus text format.
+// Counters, gauges and histograms are created in a Registry with the names of their labels
+// and are updated with the values of the labels. Metrics of providers, consumers and the workflow
+// orchestrator are registered in DefaultRegistry, which is served by the /metrics route of the http servers.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultRegistry is the registry of the broker metrics
+var DefaultRegistry = NewRegistry()
+
+// metric writes its series in the Prometheus text format
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+// Registry is a set of metrics written together
+type Registry struct {
+	mx      sync.RWMutex
+	metrics map[string]*described
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*described)}
+}
+
+// NewCounter registers a counter. It panics if a metric with the name is already registered.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, labels, func() float64 { return 0 })}
+	r.register(name, help, typeCounter, c)
+
+	return c
+}
+
+// NewGauge registers a gauge. It panics if a metric with the name is already registered.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, labels, func() float64 { return 0 })}
+	r.register(name, help, typeGauge, g)
+
+	return g
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets.
+// DefBuckets are used if no buckets are given. It panics if a metric with the name is already registered.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		buckets: buckets,
+		vec: newVec(name, labels, func() histogramValue {
+			return histogramValue{counts: make([]uint64, len(buckets))}
+		}),
+	}
+	r.register(name, help, typeHistogram, h)
+
+	return h
+}
+
+func (r *Registry) register(name, help, typ string, m metric) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+
+	r.metrics[name] = &described{name: name, help: help, typ: typ, metric: m}
+}
+
+// WriteTo writes all metrics sorted by name in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mx.RLock()
+
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+
+	for _, name := range names {
+		r.metrics[name].write(&buf)
+	}
+
+	r.mx.RUnlock()
+
+	return buf.WriteTo(w)
+}
+
+// Handler returns a handler serving the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Handler returns a handler serving the metrics of DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// described writes HELP and TYPE lines before the series of the metric
+type described struct {
+	name, help, typ string
+	metric          metric
+}
+
+func (d *described) write(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", d.name, d.typ)
+	d.metric.write(buf)
+}
+
+// vec keeps a value of each set of label values
+type vec[T any] struct {
+	mx       sync.Mutex
+	name     string
+	labels   []string
+	series   map[string]*series[T]
+	newValue func() T
+}
+
+type series[T any] struct {
+	labelValues []string
+	value       T
+}
+
+func newVec[T any](name string, labels []string, newValue func() T) *vec[T] {
+	return &vec[T]{
+		name:     name,
+		labels:   labels,
+		series:   make(map[string]*series[T]),
+		newValue: newValue,
+	}
+}
+
+// update calls fn with the value of the label values. It panics if the count of the values differs from the labels.
+func (v *vec[T]) update(labelValues []string, fn func(value *T)) {
+	v.checkLabelValues(labelValues)
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{labelValues: append([]string(nil), labelValues...), value: v.newValue()}
+		v.series[key] = s
+	}
+
+	fn(&s.value)
+}
+
+func (v *vec[T]) get(labelValues []string) (T, bool) {
+	v.checkLabelValues(labelValues)
+
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	s, ok := v.series[strings.Join(labelValues, "\xff")]
+	if !ok {
+		var zero T
+
+		return zero, false
+	}
+
+	return s.value, true
+}
+
+func (v *vec[T]) delete(labelValues []string) {
+	v.checkLabelValues(labelValues)
+
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	delete(v.series, strings.Join(labelValues, "\xff"))
+}
+
+// each calls fn for the series sorted by label values
+func (v *vec[T]) each(fn func(labelValues []string, value T)) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fn(v.series[k].labelValues, v.series[k].value)
+	}
+}
+
+func (v *vec[T]) checkLabelValues(labelValues []string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", v.name, v.labels, labelValues))
+	}
+}
+
+// writeSample writes a line of the series with the extra label if its name isn't empty
+func writeSample(buf *bytes.Buffer, name string, labels, labelValues []string, extraLabel, extraValue string, value float64) {
+	buf.WriteString(name)
+
+	if len(labels) > 0 || extraLabel != "" {
+		buf.WriteByte('{')
+
+		for i, l := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			fmt.Fprintf(buf, "%s=\"%s\"", l, escapeLabelValue(labelValues[i]))
+		}
+
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				buf.WriteByte(',')
+			}
+
+			fmt.Fprintf(buf, "%s=\"%s\"", extraLabel, extraValue)
+		}
+
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -0,0 +1,117 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"kafka-polygon/pkg/broker/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+
+	c := reg.NewCounter("test_messages_total", "Count of messages.", "topic")
+	c.Inc("topic-b")
+	c.Add(2, "topic-a")
+	c.Add(-1, "topic-a")
+
+	g := reg.NewGauge("test_lag", "Lag of\nthe partition.", "topic", "partition")
+	g.Set(5, `quoted "topic"`, "0")
+	g.Set(7, "topic-a", "1")
+	g.Delete("topic-a", "1")
+
+	h := reg.NewHistogram("test_duration_seconds", "Duration.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	var buf bytes.Buffer
+
+	_, err := reg.WriteTo(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 3.55
+test_duration_seconds_count 3
+# HELP test_lag Lag of\nthe partition.
+# TYPE test_lag gauge
+test_lag{topic="quoted \"topic\"",partition="0"} 5
+# HELP test_messages_total Count of messages.
+# TYPE test_messages_total counter
+test_messages_total{topic="topic-a"} 2
+test_messages_total{topic="topic-b"} 1
+`, buf.String())
+
+	assert.Equal(t, float64(2), c.Value("topic-a"))
+	assert.Equal(t, float64(0), g.Value("topic-a", "1"))
+	assert.Equal(t, uint64(3), h.Count())
+}
+
+func TestRegistryPanics(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	c := reg.NewCounter("test_total", "Test.", "topic")
+
+	assert.Panics(t, func() {
+		reg.NewGauge("test_total", "Test.")
+	})
+
+	assert.Panics(t, func() {
+		c.Inc("topic", "partition")
+	})
+}
+
+func TestRegistryHandler(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.NewCounter("test_total", "Test.").Inc()
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "# HELP test_total Test.\n# TYPE test_total counter\ntest_total 1\n", w.Body.String())
+}
+
+func TestBrokerMetrics(t *testing.T) {
+	t.Parallel()
+
+	published := metrics.MessagesPublished.Value("test", "publish-topic")
+	failed := metrics.PublishErrors.Value("test", "publish-topic")
+
+	metrics.ObservePublish("test", "publish-topic", 2, nil)
+	metrics.ObservePublish("test", "publish-topic", 1, errors.New("publish error"))
+
+	assert.Equal(t, published+2, metrics.MessagesPublished.Value("test", "publish-topic"))
+	assert.Equal(t, failed+1, metrics.PublishErrors.Value("test", "publish-topic"))
+
+	metrics.SetConsumerLag("group", "lag-topic", 1, 10, 4)
+	assert.Equal(t, float64(6), metrics.ConsumerLag.Value("group", "lag-topic", "1"))
+
+	var buf bytes.Buffer
+
+	_, err := metrics.DefaultRegistry.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `broker_consumer_lag{group="group",topic="lag-topic",partition="1"} 6`)
+
+	metrics.DeleteConsumerLag("group", "lag-topic", 1)
+
+	buf.Reset()
+	_, err = metrics.DefaultRegistry.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), `topic="lag-topic"`)
+}
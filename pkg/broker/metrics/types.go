@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bytes"
+	"time"
+)
+
+// DefBuckets are default buckets of histograms in seconds, suitable for latencies of handlers and requests
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is a value that only increases, e.g. a count of handled messages
+type Counter struct {
+	vec *vec[float64]
+}
+
+// Inc increments the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter of the label values. Negative values are ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	c.vec.update(labelValues, func(value *float64) {
+		*value += v
+	})
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	v, _ := c.vec.get(labelValues)
+
+	return v
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.vec.each(func(labelValues []string, value float64) {
+		writeSample(buf, c.vec.name, c.vec.labels, labelValues, "", "", value)
+	})
+}
+
+// Gauge is a value that goes up and down, e.g. a consumer lag
+type Gauge struct {
+	vec *vec[float64]
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.vec.update(labelValues, func(value *float64) {
+		*value = v
+	})
+}
+
+// Add adds v to the gauge of the label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.vec.update(labelValues, func(value *float64) {
+		*value += v
+	})
+}
+
+// Delete removes the gauge of the label values, so it isn't exposed anymore
+func (g *Gauge) Delete(labelValues ...string) {
+	g.vec.delete(labelValues)
+}
+
+// Value returns the gauge of the label values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	v, _ := g.vec.get(labelValues)
+
+	return v
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.vec.each(func(labelValues []string, value float64) {
+		writeSample(buf, g.vec.name, g.vec.labels, labelValues, "", "", value)
+	})
+}
+
+// Histogram counts observed values in buckets, e.g. latencies of handlers
+type Histogram struct {
+	buckets []float64
+	vec     *vec[histogramValue]
+}
+
+type histogramValue struct {
+	// counts are counts of values of each bucket, not cumulative
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Observe adds the value to the histogram of the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.vec.update(labelValues, func(value *histogramValue) {
+		for i, upper := range h.buckets {
+			if v <= upper {
+				value.counts[i]++
+
+				break
+			}
+		}
+
+		value.sum += v
+		value.count++
+	})
+}
+
+// ObserveSince adds seconds elapsed since start to the histogram of the label values
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the count of values observed by the histogram of the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	v, _ := h.vec.get(labelValues)
+
+	return v.count
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.vec.each(func(labelValues []string, value histogramValue) {
+		var cumulative uint64
+
+		for i, upper := range h.buckets {
+			cumulative += value.counts[i]
+			writeSample(buf, h.vec.name+"_bucket", h.vec.labels, labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+
+		writeSample(buf, h.vec.name+"_bucket", h.vec.labels, labelValues, "le", "+Inf", float64(value.count))
+		writeSample(buf, h.vec.name+"_sum", h.vec.labels, labelValues, "", "", value.sum)
+		writeSample(buf, h.vec.name+"_count", h.vec.labels, labelValues, "", "", float64(value.count))
+	})
+}
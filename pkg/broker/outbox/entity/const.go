@@ -0,0 +1,7 @@
+package entity
+
+const (
+	StatusNew    = "new"
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"kafka-polygon/pkg/cmd/metadata"
+	"time"
+)
+
+// Message is an event saved to the outbox to be published by the relay.
+// Key is a message key of the event, EventID, EventType and Metadata are the ones of the saved event.
+// ProtoData is the protobuf encoding of events implementing codec.ProtoMessage, for providers encoding by protobuf.
+type Message struct {
+	ID            int64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Topic         string
+	Key           string
+	EventID       string
+	EventType     string
+	RequestID     string
+	Metadata      metadata.Meta
+	Data          []byte
+	ProtoData     []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	Error         *string
+	SentAt        *time.Time
+}
@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cmd/metadata"
+)
+
+// OutboxEvent is an event restored from an outbox message.
+// Header and metadata were applied before the event was saved,
+// so its data is published as is with the key, type and metadata of the saved event.
+type OutboxEvent struct {
+	msg *Message
+}
+
+func NewOutboxEvent(m *Message) *OutboxEvent {
+	return &OutboxEvent{
+		msg: m,
+	}
+}
+
+func (e *OutboxEvent) GetID() string {
+	return e.msg.EventID
+}
+
+// EventKey returns the message key of the saved event
+func (e *OutboxEvent) EventKey() string {
+	return e.msg.Key
+}
+
+// EventType returns the type of the saved event
+func (e *OutboxEvent) EventType() string {
+	return e.msg.EventType
+}
+
+func (e *OutboxEvent) GetDebug() bool {
+	return false
+}
+
+func (e *OutboxEvent) WithHeader(_ context.Context) {}
+
+func (e *OutboxEvent) GetHeader() event.Header {
+	return event.Header{RequestID: e.msg.RequestID}
+}
+
+func (e *OutboxEvent) ToByte() []byte {
+	return e.msg.Data
+}
+
+func (e *OutboxEvent) Unmarshal(msg event.Message) error {
+	e.msg.Data = msg.Value
+	return nil
+}
+
+// MarshalProto returns the protobuf encoding of the saved event
+func (e *OutboxEvent) MarshalProto() ([]byte, error) {
+	if e.msg.ProtoData == nil {
+		return nil, fmt.Errorf("saved event %s of type %s isn't a protobuf message", e.msg.EventID, e.msg.EventType)
+	}
+
+	return e.msg.ProtoData, nil
+}
+
+func (e *OutboxEvent) UnmarshalProto(data []byte) error {
+	e.msg.ProtoData = data
+	return nil
+}
+
+func (e *OutboxEvent) GetMeta() metadata.Meta {
+	return e.msg.Metadata
+}
+
+func (e *OutboxEvent) WithMeta(_ metadata.Meta) {}
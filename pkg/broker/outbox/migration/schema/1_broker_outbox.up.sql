@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS public.broker_outbox (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	updated_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	topic VARCHAR(255) NOT NULL,
+	msg_key VARCHAR(255) NOT NULL,
+	event_id VARCHAR(255) NOT NULL,
+	event_type VARCHAR(255) NOT NULL,
+	request_id VARCHAR(100) NULL,
+	metadata JSONB NOT NULL DEFAULT '{}',
+	data BYTEA NOT NULL,
+	proto_data BYTEA NULL,
+	status VARCHAR(50) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	error TEXT NULL,
+	sent_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS broker_outbox_status_id_idx ON public.broker_outbox (status, id);
+CREATE INDEX IF NOT EXISTS broker_outbox_topic_msg_key_idx ON public.broker_outbox (topic, msg_key, id) WHERE status = 'new';
@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/outbox/entity"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/log"
+	"time"
+)
+
+var (
+	errEmptyTopicName = errors.New("empty topic name")
+)
+
+// Store represents a storage with outbox messages
+type Store interface {
+	// SaveMessage saves a new message. If ctx carries a transaction, the message is saved within it
+	SaveMessage(ctx context.Context, m *entity.Message) error
+	// RunExclusive calls fn if no other relay is running it at the moment. fn isn't run in a transaction,
+	// so each write of fn is saved right away. The returned bool reports whether fn was called
+	RunExclusive(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+	// GetPendingMessages returns messages with entity.StatusNew due to be published by the given time
+	// in the order they were saved. A message isn't returned while an earlier pending message
+	// with the same topic and key isn't due, so messages of the key are published in order
+	GetPendingMessages(ctx context.Context, due time.Time, limit int) ([]*entity.Message, error)
+	// UpdateMessage saves delivery state of the message: status, attempts, next attempt time, error and sent time
+	UpdateMessage(ctx context.Context, m *entity.Message) error
+	// DeleteSentMessages deletes messages sent before the given time
+	DeleteSentMessages(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Broker decorates broker.QueueBroker to save sent events to the outbox instead of publishing them.
+// Saved events are published by Relay. If ctx passed to Send carries the caller's transaction,
+// the event is saved atomically with the caller's writes.
+type Broker struct {
+	broker.QueueBroker
+	store    Store
+	metadata metadata.Meta
+}
+
+var _ broker.QueueBroker = (*Broker)(nil)
+
+func New(qb broker.QueueBroker, s Store) *Broker {
+	return &Broker{
+		QueueBroker: qb,
+		store:       s,
+		metadata:    metadata.New(),
+	}
+}
+
+// Transactional reports that Send saves events within the transaction carried by ctx
+func (b *Broker) Transactional() bool {
+	return true
+}
+
+func (b *Broker) SetMeta(meta metadata.Meta) {
+	b.metadata = meta
+	b.QueueBroker.SetMeta(meta)
+}
+
+func (b *Broker) Send(ctx context.Context, topic string, e event.BaseEvent) error {
+	if topic == "" {
+		return cerror.New(ctx, cerror.KindInternal, errEmptyTopicName).LogError()
+	}
+
+	log.DebugF(ctx, "[outboxBroker] save: %s %s", topic, e.GetID())
+	e.WithHeader(ctx)
+	e.WithMeta(b.metadata)
+
+	m := &entity.Message{
+		Topic:     topic,
+		Key:       provider.EventKey(e),
+		EventID:   e.GetID(),
+		EventType: provider.EventType(e),
+		RequestID: e.GetHeader().RequestID,
+		Metadata:  e.GetMeta(),
+		Data:      e.ToByte(),
+		Status:    entity.StatusNew,
+	}
+
+	// the encoding is saved for providers encoding by protobuf, since the event isn't restored by the relay
+	if pm, ok := e.(codec.ProtoMessage); ok {
+		var err error
+		if m.ProtoData, err = pm.MarshalProto(); err != nil {
+			return cerror.NewF(ctx, cerror.KindInternal,
+				"[outboxBroker] encode event %s by protobuf. %s", e.GetID(), err.Error()).LogError()
+		}
+	}
+
+	return b.store.SaveMessage(ctx, m)
+}
+
+// SendBatch saves each event to the outbox. A failed event doesn't stop saving of the next ones.
+func (b *Broker) SendBatch(ctx context.Context, topic string, events []event.BaseEvent) error {
+	return provider.PublishEach(ctx, topic, events, b.Send)
+}
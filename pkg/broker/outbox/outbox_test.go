@@ -0,0 +1,427 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/outbox"
+	"kafka-polygon/pkg/broker/outbox/entity"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/tracing"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+var (
+	bgCtx      = context.WithValue(context.Background(), consts.HeaderXRequestID, "test-x-request-id") //nolint:staticcheck
+	errPublish = errors.New("publish error")
+)
+
+type memStore struct {
+	mx      sync.Mutex
+	lastID  int64
+	msgs    []*entity.Message
+	saveCtx context.Context
+}
+
+func (ms *memStore) SaveMessage(ctx context.Context, m *entity.Message) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.lastID++
+	m.ID = ms.lastID
+	m.CreatedAt = time.Now().UTC()
+	m.NextAttemptAt = m.CreatedAt
+	ms.msgs = append(ms.msgs, m)
+	ms.saveCtx = ctx
+
+	return nil
+}
+
+func (ms *memStore) RunExclusive(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	return true, fn(ctx)
+}
+
+func (ms *memStore) GetPendingMessages(_ context.Context, due time.Time, limit int) ([]*entity.Message, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	res := make([]*entity.Message, 0)
+	notDue := make(map[string]bool)
+
+	for _, m := range ms.msgs {
+		if m.Status != entity.StatusNew {
+			continue
+		}
+
+		key := m.Topic + "/" + m.Key
+		if m.NextAttemptAt.After(due) {
+			notDue[key] = true
+		}
+
+		if !notDue[key] && len(res) < limit {
+			cp := *m
+			res = append(res, &cp)
+		}
+	}
+
+	return res, nil
+}
+
+func (ms *memStore) UpdateMessage(_ context.Context, m *entity.Message) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	for i := range ms.msgs {
+		if ms.msgs[i].ID == m.ID {
+			cp := *m
+			ms.msgs[i] = &cp
+		}
+	}
+
+	return nil
+}
+
+func (ms *memStore) DeleteSentMessages(_ context.Context, before time.Time) (int64, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	var n int64
+
+	rest := make([]*entity.Message, 0, len(ms.msgs))
+
+	for _, m := range ms.msgs {
+		if m.Status == entity.StatusSent && m.SentAt.Before(before) {
+			n++
+			continue
+		}
+
+		rest = append(rest, m)
+	}
+
+	ms.msgs = rest
+
+	return n, nil
+}
+
+func (ms *memStore) status(id int64) string {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	for _, m := range ms.msgs {
+		if m.ID == id {
+			return m.Status
+		}
+	}
+
+	return ""
+}
+
+type published struct {
+	topic     string
+	key       string
+	reqID     string
+	eventID   string
+	eventType string
+	meta      metadata.Meta
+}
+
+type mockProvider struct {
+	mx        sync.Mutex
+	failKeys  map[string]bool
+	published []published
+}
+
+func (mp *mockProvider) GetType() string {
+	return "mock"
+}
+
+func (mp *mockProvider) SetEnabled(_ bool) {}
+
+func (mp *mockProvider) SetStore(_ store.Store) {}
+
+func (mp *mockProvider) SetTracing(_ tracing.Tracer) {}
+
+func (mp *mockProvider) Sync(_ context.Context, _ string, _ provider.HandlerFn) {}
+
+func (mp *mockProvider) Stop() {}
+
+func (mp *mockProvider) GetIsTopicExists(_ context.Context, _ string) (bool, error) {
+	return true, nil
+}
+
+func (mp *mockProvider) Publish(ctx context.Context, topic string, e event.BaseEvent) error {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+
+	key := provider.EventKey(e)
+	if mp.failKeys[key] {
+		return errPublish
+	}
+
+	reqID, _ := ctx.Value(consts.HeaderXRequestID).(string)
+	mp.published = append(mp.published, published{
+		topic:     topic,
+		key:       key,
+		reqID:     reqID,
+		eventID:   e.GetID(),
+		eventType: provider.EventType(e),
+		meta:      e.GetMeta(),
+	})
+
+	return nil
+}
+
+func (mp *mockProvider) setFail(key string, fail bool) {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+
+	mp.failKeys[key] = fail
+}
+
+type mockQueueBroker struct {
+	broker.QueueBroker
+	meta metadata.Meta
+}
+
+func (m *mockQueueBroker) SetMeta(meta metadata.Meta) {
+	m.meta = meta
+}
+
+func workflowEvent(id, workflowID string) *event.WorkflowData {
+	return &event.WorkflowData{ID: id, Workflow: event.Workflow{ID: workflowID}}
+}
+
+func TestBrokerSend(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	qb := &mockQueueBroker{}
+	b := outbox.New(qb, ms)
+
+	expMeta := metadata.Meta{Version: "0.0.1"}
+	b.SetMeta(expMeta)
+	assert.Equal(t, expMeta, qb.meta)
+
+	e := &event.WorkflowData{ID: "test-id", Workflow: event.Workflow{ID: "wf-id"}}
+	err := b.Send(bgCtx, "topic", e)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-x-request-id", e.Header.RequestID)
+	assert.Equal(t, expMeta, e.Metadata)
+
+	assert.Len(t, ms.msgs, 1)
+	assert.Equal(t, bgCtx, ms.saveCtx)
+	assert.Equal(t, "topic", ms.msgs[0].Topic)
+	// the message key is the workflow ID to keep events of the workflow in order
+	assert.Equal(t, "wf-id", ms.msgs[0].Key)
+	assert.Equal(t, "test-id", ms.msgs[0].EventID)
+	assert.Equal(t, "WorkflowData", ms.msgs[0].EventType)
+	assert.Equal(t, expMeta, ms.msgs[0].Metadata)
+	assert.Equal(t, "test-x-request-id", ms.msgs[0].RequestID)
+	assert.Equal(t, e.ToByte(), ms.msgs[0].Data)
+	assert.Equal(t, entity.StatusNew, ms.msgs[0].Status)
+
+	err = b.Send(bgCtx, "", e)
+	assert.Error(t, err)
+	assert.Len(t, ms.msgs, 1)
+}
+
+func TestBrokerSendProto(t *testing.T) {
+	t.Parallel()
+
+	reg, err := codec.NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	assert.NoError(t, err)
+
+	_, err = reg.Register(bgCtx, codec.Subject("topic"),
+		codec.Schema{Type: codec.SchemaTypeProtobuf, Schema: event.WorkflowProtoSchema})
+	assert.NoError(t, err)
+
+	ms := &memStore{}
+	b := outbox.New(&mockQueueBroker{}, ms)
+
+	e := workflowEvent("test-id", "wf-id")
+	assert.NoError(t, b.Send(bgCtx, "topic", e))
+
+	// the relay publishes the saved protobuf encoding of the event
+	c := codec.NewProtobuf(reg)
+	value, err := c.Encode(bgCtx, "topic", entity.NewOutboxEvent(ms.msgs[0]))
+	assert.NoError(t, err)
+
+	var decoded event.WorkflowData
+	assert.NoError(t, c.Decode(bgCtx, event.Message{Value: value}, &decoded))
+	assert.Equal(t, *e, decoded)
+
+	_, err = c.Encode(bgCtx, "topic", entity.NewOutboxEvent(&entity.Message{EventID: "test-id"}))
+	assert.Error(t, err)
+}
+
+func TestBrokerSendBatch(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	b := outbox.New(&mockQueueBroker{}, ms)
+
+	events := []event.BaseEvent{
+		&event.WorkflowData{ID: "test-id-1"},
+		&event.WorkflowData{ID: "test-id-2"},
+	}
+
+	err := b.SendBatch(bgCtx, "topic", events)
+	assert.NoError(t, err)
+	assert.Len(t, ms.msgs, 2)
+	assert.Equal(t, "test-id-1", ms.msgs[0].EventID)
+	assert.Equal(t, "test-id-2", ms.msgs[1].EventID)
+	assert.Equal(t, "test-x-request-id", ms.msgs[1].RequestID)
+
+	err = b.SendBatch(bgCtx, "", events)
+
+	var be *provider.BatchError
+	assert.True(t, errors.As(err, &be))
+	assert.Equal(t, 2, be.Failed())
+	assert.Len(t, ms.msgs, 2)
+}
+
+func TestRelayPublishOrderPerKey(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	mp := &mockProvider{failKeys: map[string]bool{"key-1": true}}
+	b := outbox.New(&mockQueueBroker{}, ms)
+	r := outbox.NewRelay(mp, ms, outbox.RelaySettings{
+		RetryBackoff:    time.Millisecond,
+		RetryBackoffMax: time.Millisecond,
+	})
+
+	for i, key := range []string{"key-1", "key-2", "key-1"} {
+		assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent(fmt.Sprintf("id-%d", i), key)))
+	}
+
+	sent, err := r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []published{{
+		topic:     "topic",
+		key:       "key-2",
+		reqID:     "test-x-request-id",
+		eventID:   "id-1",
+		eventType: "WorkflowData",
+		meta:      metadata.New(),
+	}}, mp.published)
+	// the second key-1 message waits for the first one
+	assert.Equal(t, entity.StatusNew, ms.status(1))
+	assert.Equal(t, 1, ms.msgs[0].Attempts)
+	assert.Equal(t, errPublish.Error(), *ms.msgs[0].Error)
+	assert.Equal(t, entity.StatusNew, ms.status(3))
+	assert.Equal(t, 0, ms.msgs[2].Attempts)
+
+	mp.setFail("key-1", false)
+	time.Sleep(5 * time.Millisecond)
+
+	sent, err = r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"key-2", "key-1", "key-1"},
+		[]string{mp.published[0].key, mp.published[1].key, mp.published[2].key})
+	assert.Equal(t, entity.StatusSent, ms.status(1))
+	assert.Equal(t, entity.StatusSent, ms.status(3))
+}
+
+func TestRelaySkipNotDue(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	mp := &mockProvider{failKeys: map[string]bool{"key-1": true}}
+	b := outbox.New(&mockQueueBroker{}, ms)
+	r := outbox.NewRelay(mp, ms, outbox.RelaySettings{
+		BatchSize:    2,
+		RetryBackoff: time.Minute,
+	})
+
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-1", "key-1")))
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-2", "key-1")))
+
+	_, err := r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+
+	mp.setFail("key-1", false)
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-3", "key-2")))
+
+	// messages of key-1 backing off don't fill the batch
+	sent, err := r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "id-3", mp.published[0].eventID)
+	assert.Equal(t, entity.StatusNew, ms.status(1))
+	assert.Equal(t, entity.StatusNew, ms.status(2))
+}
+
+func TestRelayMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	mp := &mockProvider{failKeys: map[string]bool{"key-1": true}}
+	b := outbox.New(&mockQueueBroker{}, ms)
+	r := outbox.NewRelay(mp, ms, outbox.RelaySettings{
+		MaxAttempts:     2,
+		RetryBackoff:    time.Millisecond,
+		RetryBackoffMax: time.Millisecond,
+	})
+
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-1", "key-1")))
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-2", "key-1")))
+
+	mp.setFail("key-1", true)
+
+	_, err := r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusNew, ms.status(1))
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusFailed, ms.status(1))
+
+	// the failed message doesn't block subsequent messages anymore
+	mp.setFail("key-1", false)
+	time.Sleep(5 * time.Millisecond)
+
+	sent, err := r.PublishPending(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, entity.StatusSent, ms.status(2))
+}
+
+func TestRelayRunAndCleanup(t *testing.T) {
+	t.Parallel()
+
+	ms := &memStore{}
+	mp := &mockProvider{failKeys: map[string]bool{}}
+	b := outbox.New(&mockQueueBroker{}, ms)
+	r := outbox.NewRelay(mp, ms, outbox.RelaySettings{
+		PollInterval: 5 * time.Millisecond,
+		Retention:    time.Millisecond,
+	})
+
+	assert.NoError(t, b.Send(bgCtx, "topic", workflowEvent("id-1", "key-1")))
+
+	r.Run(bgCtx)
+	time.Sleep(50 * time.Millisecond)
+	r.Stop()
+
+	assert.Equal(t, entity.StatusSent, ms.status(1))
+
+	n, err := r.Cleanup(bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Empty(t, ms.msgs)
+}
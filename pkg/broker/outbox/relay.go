@@ -0,0 +1,256 @@
+package outbox
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/outbox/entity"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval    = 1 * time.Second
+	defaultBatchSize       = 100
+	defaultMaxAttempts     = 10
+	defaultRetryBackoff    = 1 * time.Second
+	defaultRetryBackoffMax = 1 * time.Minute
+	defaultCleanupInterval = 1 * time.Hour
+	defaultRetention       = 24 * time.Hour
+)
+
+type RelaySettings struct {
+	// PollInterval is a pause between checks for pending messages
+	PollInterval time.Duration
+	// BatchSize is a maximum number of messages read from the store at once
+	BatchSize int
+	// MaxAttempts is a number of publish attempts after which the message gets entity.StatusFailed
+	MaxAttempts int
+	// RetryBackoff is a delay before the first retry. It is doubled for every next retry
+	RetryBackoff time.Duration
+	// RetryBackoffMax is a maximum delay between retries
+	RetryBackoffMax time.Duration
+	// CleanupInterval is a pause between deletions of sent messages
+	CleanupInterval time.Duration
+	// Retention is a time during which sent messages are kept in the store
+	Retention time.Duration
+}
+
+func (rs *RelaySettings) initDefault() {
+	if rs.PollInterval.Milliseconds() == 0 {
+		rs.PollInterval = defaultPollInterval
+	}
+
+	if rs.BatchSize == 0 {
+		rs.BatchSize = defaultBatchSize
+	}
+
+	if rs.MaxAttempts == 0 {
+		rs.MaxAttempts = defaultMaxAttempts
+	}
+
+	if rs.RetryBackoff.Milliseconds() == 0 {
+		rs.RetryBackoff = defaultRetryBackoff
+	}
+
+	if rs.RetryBackoffMax.Milliseconds() == 0 {
+		rs.RetryBackoffMax = defaultRetryBackoffMax
+	}
+
+	if rs.CleanupInterval.Milliseconds() == 0 {
+		rs.CleanupInterval = defaultCleanupInterval
+	}
+
+	if rs.Retention.Milliseconds() == 0 {
+		rs.Retention = defaultRetention
+	}
+}
+
+// Relay publishes outbox messages through the provider.
+// Messages with the same topic and key are published in the order they were saved:
+// while a message waits for a retry, subsequent messages with its key are held back.
+// Only one relay publishes messages at a time (see Store.RunExclusive).
+type Relay struct {
+	provider provider.Provider
+	store    Store
+	settings RelaySettings
+	mx       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewRelay(p provider.Provider, s Store, rs RelaySettings) *Relay {
+	rs.initDefault()
+
+	return &Relay{
+		provider: p,
+		store:    s,
+		settings: rs,
+	}
+}
+
+// Run starts publishing in background until Stop is called or ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go r.loop(ctx, r.done)
+}
+
+// Stop stops publishing and waits for the current batch to be processed
+func (r *Relay) Stop() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+	r.cancel = nil
+}
+
+func (r *Relay) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	pollTicker := time.NewTicker(r.settings.PollInterval)
+	defer pollTicker.Stop()
+
+	cleanupTicker := time.NewTicker(r.settings.CleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTicker.C:
+			// keep publishing while batches are full
+			for ctx.Err() == nil {
+				n, err := r.PublishPending(ctx)
+				if err != nil || n < r.settings.BatchSize {
+					break
+				}
+			}
+		case <-cleanupTicker.C:
+			_, _ = r.Cleanup(ctx)
+		}
+	}
+}
+
+// PublishPending publishes one batch of pending messages.
+// The delivery state of each message is saved right after it's published,
+// so a failure in the middle of the batch doesn't republish messages published before it.
+// It returns the number of successfully published messages.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	var sent int
+
+	_, err := r.store.RunExclusive(ctx, func(ctx context.Context) error {
+		msgs, err := r.store.GetPendingMessages(ctx, time.Now().UTC(), r.settings.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		// a message failed in this batch holds back the next messages with its key
+		blocked := make(map[string]struct{})
+
+		for _, m := range msgs {
+			key := m.Topic + "/" + m.Key
+			if _, ok := blocked[key]; ok {
+				continue
+			}
+
+			publishErr := r.publish(ctx, m)
+
+			if err := r.store.UpdateMessage(ctx, m); err != nil {
+				return err
+			}
+
+			if publishErr != nil {
+				if m.Status == entity.StatusNew {
+					blocked[key] = struct{}{}
+				}
+
+				continue
+			}
+
+			sent++
+		}
+
+		return nil
+	})
+
+	return sent, err
+}
+
+// Cleanup deletes messages sent earlier than the retention period
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	n, err := r.store.DeleteSentMessages(ctx, time.Now().UTC().Add(-r.settings.Retention))
+	if err != nil {
+		return 0, err
+	}
+
+	log.DebugF(ctx, "[outboxRelay] deleted %d sent messages", n)
+
+	return n, nil
+}
+
+// publish publishes the message and sets its delivery state
+func (r *Relay) publish(ctx context.Context, m *entity.Message) error {
+	msgCtx := ctx
+	if m.RequestID != "" {
+		msgCtx = context.WithValue(ctx, consts.HeaderXRequestID, m.RequestID) //nolint:staticcheck
+	}
+
+	err := r.provider.Publish(msgCtx, m.Topic, entity.NewOutboxEvent(m))
+	now := time.Now().UTC()
+	m.Attempts++
+
+	if err == nil {
+		m.Status = entity.StatusSent
+		m.SentAt = &now
+		m.Error = nil
+
+		return nil
+	}
+
+	errMsg := err.Error()
+	m.Error = &errMsg
+
+	if m.Attempts >= r.settings.MaxAttempts {
+		m.Status = entity.StatusFailed
+
+		return cerror.NewF(msgCtx, cerror.KindInternal,
+			"outbox message was not sent after %d attempts. id=%d topic=%s key=%s. error=%s",
+			m.Attempts, m.ID, m.Topic, m.Key, errMsg).LogError()
+	}
+
+	m.NextAttemptAt = now.Add(r.backoff(m.Attempts))
+
+	return cerror.NewF(msgCtx, cerror.KindInternal,
+		"outbox message was not sent. id=%d topic=%s key=%s attempt=%d. error=%s",
+		m.ID, m.Topic, m.Key, m.Attempts, errMsg).LogWarn()
+}
+
+// backoff returns a delay before the next attempt
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.settings.RetryBackoff
+	for i := 1; i < attempts && d < r.settings.RetryBackoffMax; i++ {
+		d *= 2
+	}
+
+	if d > r.settings.RetryBackoffMax {
+		d = r.settings.RetryBackoffMax
+	}
+
+	return d
+}
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"kafka-polygon/pkg/broker/outbox"
+	"kafka-polygon/pkg/broker/outbox/entity"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/cmd/metadata"
+	pgBun "kafka-polygon/pkg/db/postgres/bun"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// relayLockID is a key of the advisory lock which allows only one relay to publish messages at a time
+const relayLockID int64 = 2_718_281_828
+
+type Store struct {
+	db *bun.DB
+}
+
+var _ outbox.Store = (*Store)(nil)
+
+func NewStore(db *bun.DB) *Store {
+	return &Store{db: db}
+}
+
+type brokerOutbox struct {
+	bun.BaseModel `bun:"table:broker_outbox"`
+	ID            int64         `bun:"id,pk,autoincrement"`
+	CreatedAt     time.Time     `bun:"created_at"`
+	UpdatedAt     time.Time     `bun:"updated_at"`
+	Topic         string        `bun:"topic"`
+	Key           string        `bun:"msg_key"`
+	EventID       string        `bun:"event_id"`
+	EventType     string        `bun:"event_type"`
+	RequestID     *string       `bun:"request_id"`
+	Metadata      metadata.Meta `bun:"metadata,type:jsonb"`
+	Data          []byte        `bun:"data,type:bytea"`
+	ProtoData     []byte        `bun:"proto_data,type:bytea"`
+	Status        string        `bun:"status"`
+	Attempts      int           `bun:"attempts"`
+	NextAttemptAt time.Time     `bun:"next_attempt_at"`
+	Error         *string       `bun:"error"`
+	SentAt        *time.Time    `bun:"sent_at"`
+}
+
+func (bo *brokerOutbox) toEntity() *entity.Message {
+	m := &entity.Message{
+		ID:            bo.ID,
+		CreatedAt:     bo.CreatedAt,
+		UpdatedAt:     bo.UpdatedAt,
+		Topic:         bo.Topic,
+		Key:           bo.Key,
+		EventID:       bo.EventID,
+		EventType:     bo.EventType,
+		Metadata:      bo.Metadata,
+		Data:          bo.Data,
+		ProtoData:     bo.ProtoData,
+		Status:        bo.Status,
+		Attempts:      bo.Attempts,
+		NextAttemptAt: bo.NextAttemptAt,
+		Error:         bo.Error,
+		SentAt:        bo.SentAt,
+	}
+
+	if bo.RequestID != nil {
+		m.RequestID = *bo.RequestID
+	}
+
+	return m
+}
+
+// SaveMessage saves a new message. If ctx carries a transaction, the message is saved within it.
+func (s *Store) SaveMessage(ctx context.Context, m *entity.Message) error {
+	now := time.Now().UTC()
+	dst := &brokerOutbox{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Topic:         m.Topic,
+		Key:           m.Key,
+		EventID:       m.EventID,
+		EventType:     m.EventType,
+		Metadata:      m.Metadata,
+		Data:          m.Data,
+		ProtoData:     m.ProtoData,
+		Status:        m.Status,
+		NextAttemptAt: now,
+	}
+
+	if m.RequestID != "" {
+		dst.RequestID = &m.RequestID
+	}
+
+	if _, err := pgBun.IDBFromContext(ctx, s.db).NewInsert().Model(dst).Returning("id").Exec(ctx); err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"save broker outbox message for topic %s --> %+v", m.Topic, err).LogError()
+	}
+
+	m.ID = dst.ID
+	m.CreatedAt = dst.CreatedAt
+	m.UpdatedAt = dst.UpdatedAt
+	m.NextAttemptAt = dst.NextAttemptAt
+
+	return nil
+}
+
+// RunExclusive calls fn holding the relay advisory lock on a dedicated connection.
+// If the lock is held by another relay fn isn't called. fn isn't run in a transaction,
+// so the delivery state of each published message is committed right away.
+func (s *Store) RunExclusive(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"get connection for broker outbox relay lock --> %+v", err).LogError()
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	var locked bool
+
+	if err := conn.NewSelect().ColumnExpr("pg_try_advisory_lock(?)", relayLockID).Scan(ctx, &locked); err != nil {
+		return false, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"acquire broker outbox relay lock --> %+v", err).LogError()
+	}
+
+	if !locked {
+		return false, nil
+	}
+
+	defer s.unlockRelay(ctx, conn)
+
+	return true, fn(ctx)
+}
+
+// unlockRelay releases the relay advisory lock. The lock is held by the connection,
+// so the connection is discarded instead of being returned to the pool if the lock isn't released.
+func (s *Store) unlockRelay(ctx context.Context, conn bun.Conn) {
+	var unlocked bool
+
+	// ctx may be already done when the relay is stopped
+	err := conn.NewSelect().ColumnExpr("pg_advisory_unlock(?)", relayLockID).Scan(context.Background(), &unlocked)
+	if err == nil && unlocked {
+		return
+	}
+
+	_ = cerror.NewF(ctx, cerror.KindInternal,
+		"release broker outbox relay lock --> %+v", err).LogError()
+
+	_ = conn.Raw(func(_ interface{}) error {
+		return driver.ErrBadConn
+	})
+}
+
+// GetPendingMessages returns new messages due by the given time. Messages backing off before a retry
+// are filtered out together with the later messages of their keys, so they don't fill the batch.
+func (s *Store) GetPendingMessages(ctx context.Context, due time.Time, limit int) ([]*entity.Message, error) {
+	rows := make([]*brokerOutbox, 0)
+
+	err := pgBun.IDBFromContext(ctx, s.db).NewSelect().
+		Model(&rows).
+		Where("?TableAlias.status=?", entity.StatusNew).
+		Where("?TableAlias.next_attempt_at<=?", due).
+		Where(`NOT EXISTS (SELECT 1 FROM broker_outbox AS prev
+			WHERE prev.topic=?TableAlias.topic AND prev.msg_key=?TableAlias.msg_key
+			AND prev.id<?TableAlias.id AND prev.status=? AND prev.next_attempt_at>?)`, entity.StatusNew, due).
+		Order("id").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"get pending broker outbox messages --> %+v", err).LogError()
+	}
+
+	result := make([]*entity.Message, len(rows))
+	for i := range rows {
+		result[i] = rows[i].toEntity()
+	}
+
+	return result, nil
+}
+
+func (s *Store) UpdateMessage(ctx context.Context, m *entity.Message) error {
+	m.UpdatedAt = time.Now().UTC()
+
+	if _, err := pgBun.IDBFromContext(ctx, s.db).
+		NewUpdate().
+		Model(&brokerOutbox{
+			ID:            m.ID,
+			UpdatedAt:     m.UpdatedAt,
+			Status:        m.Status,
+			Attempts:      m.Attempts,
+			NextAttemptAt: m.NextAttemptAt,
+			Error:         m.Error,
+			SentAt:        m.SentAt,
+		}).
+		Column("updated_at", "status", "attempts", "next_attempt_at", "error", "sent_at").
+		WherePK().
+		Exec(ctx); err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"update broker outbox message with id: %d --> %+v", m.ID, err).LogError()
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteSentMessages(ctx context.Context, before time.Time) (int64, error) {
+	res, err := pgBun.IDBFromContext(ctx, s.db).
+		NewDelete().
+		Model((*brokerOutbox)(nil)).
+		Where("status=?", entity.StatusSent).
+		Where("sent_at<?", before).
+		Exec(ctx)
+	if err != nil {
+		return 0, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"delete sent broker outbox messages --> %+v", err).LogError()
+	}
+
+	n, _ := res.RowsAffected()
+
+	return n, nil
+}
@@ -0,0 +1,230 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/outbox/entity"
+	"kafka-polygon/pkg/broker/outbox/migration/schema"
+	pgStore "kafka-polygon/pkg/broker/outbox/store/postgres"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/db/postgres"
+	"kafka-polygon/pkg/db/postgres/bun"
+	"kafka-polygon/pkg/env"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/testutil"
+	"testing"
+	"time"
+
+	bindata "github.com/golang-migrate/migrate/v4/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	migrationVersion uint = 1
+)
+
+var (
+	_bgCtx  = context.Background()
+	errStop = errors.New("stop")
+)
+
+type storeTestSuite struct {
+	suite.Suite
+	pgCont *testutil.DockerPGContainer
+	db     *sqlx.DB
+	store  *pgStore.Store
+	pgConn *bun.Connection
+	pgCfg  *env.Postgres
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(storeTestSuite))
+}
+
+func (ts *storeTestSuite) SetupSuite() {
+	dbName := "broker_outbox_test"
+	pgCont := testutil.NewDockerUtilInstance().
+		InitPG().
+		CreatePostgresContainerDatabase(dbName).
+		ConnectPostgresDB(dbName).
+		RunDBMigrations(_bgCtx, dbName, testutil.DockerMigrateConfig{
+			Name:     postgres.MigrateNameBrokerOutbox,
+			Version:  migrationVersion,
+			Resource: bindata.Resource(schema.AssetNames(), schema.Asset),
+		})
+	ts.pgCont = pgCont
+	ts.db = pgCont.GetDBInfoByName(dbName).DBClient
+
+	postgresCfg := pgCont.GetPostgresEnvConfig()
+	postgresCfg.DBName = dbName
+	ts.pgCfg = &postgresCfg
+
+	ts.pgConn = bun.NewConnection(&postgresCfg)
+	err := ts.pgConn.Connect()
+
+	if err != nil {
+		_ = cerror.New(_bgCtx, cerror.KindInternal, err).LogError()
+		return
+	}
+
+	ts.store = pgStore.NewStore(ts.pgConn.DB())
+}
+
+func (ts *storeTestSuite) TearDownTest() {
+	ts.deleteAllRecordsByTableName("broker_outbox")
+}
+
+func (ts *storeTestSuite) TearDownSuite() {
+	_ = ts.pgConn.Close()
+	_ = ts.db.Close()
+	_ = ts.pgCont.CloseDBConnectionByName(ts.pgCfg.DBName)
+}
+
+func (ts *storeTestSuite) TestSaveAndUpdateMessage() {
+	m := &entity.Message{
+		Topic:     "topic",
+		Key:       "key",
+		EventID:   "event-id",
+		EventType: "WorkflowData",
+		RequestID: "request-id",
+		Metadata:  metadata.Meta{Module: "module", Version: "0.0.1"},
+		Data:      []byte("{}"),
+		Status:    entity.StatusNew,
+	}
+	ts.NoError(ts.store.SaveMessage(_bgCtx, m))
+	ts.NotZero(m.ID)
+
+	msgs, err := ts.store.GetPendingMessages(_bgCtx, time.Now().UTC(), 10)
+	ts.NoError(err)
+	ts.Len(msgs, 1)
+	ts.Equal(m.ID, msgs[0].ID)
+	ts.Equal("key", msgs[0].Key)
+	ts.Equal("event-id", msgs[0].EventID)
+	ts.Equal("WorkflowData", msgs[0].EventType)
+	ts.Equal("request-id", msgs[0].RequestID)
+	ts.Equal(m.Metadata, msgs[0].Metadata)
+	ts.Equal([]byte("{}"), msgs[0].Data)
+
+	now := time.Now().UTC()
+	m.Status = entity.StatusSent
+	m.Attempts = 1
+	m.SentAt = &now
+	ts.NoError(ts.store.UpdateMessage(_bgCtx, m))
+
+	msgs, err = ts.store.GetPendingMessages(_bgCtx, time.Now().UTC(), 10)
+	ts.NoError(err)
+	ts.Empty(msgs)
+
+	n, err := ts.store.DeleteSentMessages(_bgCtx, now.Add(time.Second))
+	ts.NoError(err)
+	ts.Equal(int64(1), n)
+}
+
+func (ts *storeTestSuite) TestGetPendingMessagesDue() {
+	msgs := make([]*entity.Message, 0, 3)
+
+	for _, key := range []string{"key-1", "key-1", "key-2"} {
+		m := &entity.Message{Topic: "topic-due", Key: key, Data: []byte("{}"), Status: entity.StatusNew}
+		ts.NoError(ts.store.SaveMessage(_bgCtx, m))
+		msgs = append(msgs, m)
+	}
+
+	// the first message of key-1 backs off before a retry
+	msgs[0].Attempts = 1
+	msgs[0].NextAttemptAt = time.Now().UTC().Add(time.Hour)
+	ts.NoError(ts.store.UpdateMessage(_bgCtx, msgs[0]))
+
+	pending, err := ts.store.GetPendingMessages(_bgCtx, time.Now().UTC(), 10)
+	ts.NoError(err)
+
+	// the next message of key-1 is held back until the first one is due
+	ids := make([]int64, 0, len(pending))
+	for _, m := range pending {
+		ids = append(ids, m.ID)
+	}
+
+	ts.NotContains(ids, msgs[0].ID)
+	ts.NotContains(ids, msgs[1].ID)
+	ts.Contains(ids, msgs[2].ID)
+
+	pending, err = ts.store.GetPendingMessages(_bgCtx, time.Now().UTC().Add(2*time.Hour), 10)
+	ts.NoError(err)
+
+	ids = ids[:0]
+	for _, m := range pending {
+		ids = append(ids, m.ID)
+	}
+
+	ts.Contains(ids, msgs[0].ID)
+	ts.Contains(ids, msgs[1].ID)
+
+	now := time.Now().UTC()
+	for _, m := range msgs {
+		m.Status = entity.StatusSent
+		m.SentAt = &now
+		ts.NoError(ts.store.UpdateMessage(_bgCtx, m))
+	}
+
+	_, err = ts.store.DeleteSentMessages(_bgCtx, now.Add(time.Second))
+	ts.NoError(err)
+}
+
+func (ts *storeTestSuite) TestSaveMessageInTx() {
+	tx, err := ts.pgConn.DB().BeginTx(_bgCtx, nil)
+	ts.NoError(err)
+
+	err = ts.store.SaveMessage(bun.ContextWithTx(_bgCtx, tx), &entity.Message{
+		Topic:  "topic",
+		Key:    "key",
+		Data:   []byte("{}"),
+		Status: entity.StatusNew,
+	})
+	ts.NoError(err)
+	ts.NoError(tx.Rollback())
+
+	msgs, err := ts.store.GetPendingMessages(_bgCtx, time.Now().UTC(), 10)
+	ts.NoError(err)
+	ts.Empty(msgs)
+}
+
+func (ts *storeTestSuite) TestRunExclusive() {
+	isCalled := false
+
+	locked, err := ts.store.RunExclusive(_bgCtx, func(ctx context.Context) error {
+		// messages are marked one by one, not in a transaction of the whole batch
+		_, ok := bun.TxFromContext(ctx)
+		ts.False(ok)
+
+		// the lock is held by the outer call
+		innerLocked, err := ts.store.RunExclusive(_bgCtx, func(_ context.Context) error {
+			isCalled = true
+			return nil
+		})
+		ts.NoError(err)
+		ts.False(innerLocked)
+
+		return errStop
+	})
+	ts.True(locked)
+	ts.ErrorIs(err, errStop)
+	ts.False(isCalled)
+
+	// the lock is released after the call
+	locked, err = ts.store.RunExclusive(_bgCtx, func(_ context.Context) error {
+		isCalled = true
+		return nil
+	})
+	ts.NoError(err)
+	ts.True(locked)
+	ts.True(isCalled)
+}
+
+func (ts *storeTestSuite) deleteAllRecordsByTableName(tableName string) {
+	if _, err := ts.db.Exec(fmt.Sprintf("DELETE FROM %v;", tableName)); err != nil {
+		_ = cerror.NewF(_bgCtx, cerror.KindInternal, "error delete from tables: %v", err).LogError()
+	}
+}
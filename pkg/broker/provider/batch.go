@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+)
+
+// BatchPublisher is implemented by providers that publish several events in one request.
+// Batches of other providers are published event by event.
+type BatchPublisher interface {
+	// PublishBatch returns *BatchError if some events are not published
+	PublishBatch(ctx context.Context, topic string, events []event.BaseEvent) error
+}
+
+// BatchError reports events of a batch that are not published.
+// Errs has an error for each event of the batch at the same index, nil for published events.
+type BatchError struct {
+	Errs []error
+}
+
+func NewBatchError(size int) *BatchError {
+	return &BatchError{Errs: make([]error, size)}
+}
+
+// Failed returns a count of not published events
+func (be *BatchError) Failed() int {
+	cnt := 0
+
+	for _, err := range be.Errs {
+		if err != nil {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+func (be *BatchError) Error() string {
+	for _, err := range be.Errs {
+		if err != nil {
+			return fmt.Sprintf("%d of %d events are not published. %s", be.Failed(), len(be.Errs), err.Error())
+		}
+	}
+
+	return fmt.Sprintf("0 of %d events are not published", len(be.Errs))
+}
+
+// ErrOrNil returns nil if all events are published
+func (be *BatchError) ErrOrNil() error {
+	if be.Failed() == 0 {
+		return nil
+	}
+
+	return be
+}
+
+// PublishEach publishes events one by one. A failed event doesn't stop publishing of the next ones.
+func PublishEach(ctx context.Context, topic string, events []event.BaseEvent,
+	publish func(ctx context.Context, topic string, e event.BaseEvent) error) error {
+	be := NewBatchError(len(events))
+
+	for i, e := range events {
+		be.Errs[i] = publish(ctx, topic, e)
+	}
+
+	return be.ErrOrNil()
+}
@@ -0,0 +1,47 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestPublishEach(t *testing.T) {
+	t.Parallel()
+
+	errPublish := errors.New("publish error")
+	events := []event.BaseEvent{
+		&event.WorkflowData{ID: "1"},
+		&event.WorkflowData{ID: "2"},
+		&event.WorkflowData{ID: "3"},
+	}
+
+	var published []string
+
+	publish := func(_ context.Context, topic string, e event.BaseEvent) error {
+		assert.Equal(t, "topic", topic)
+
+		if e.GetID() == "2" {
+			return errPublish
+		}
+
+		published = append(published, e.GetID())
+
+		return nil
+	}
+
+	err := provider.PublishEach(context.Background(), "topic", events, publish)
+
+	var be *provider.BatchError
+	assert.True(t, errors.As(err, &be))
+	assert.Equal(t, []error{nil, errPublish, nil}, be.Errs)
+	assert.Equal(t, 1, be.Failed())
+	assert.Equal(t, "1 of 3 events are not published. publish error", be.Error())
+	assert.Equal(t, []string{"1", "3"}, published)
+
+	assert.NoError(t, provider.PublishEach(context.Background(), "topic", events[:1], publish))
+}
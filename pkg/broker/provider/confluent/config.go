@@ -0,0 +1,288 @@
+package confluent
+
+import (
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"strings"
+	"time"
+
+	goConfluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	securityProtocolSCRAM    = "SCRAM"
+	securityProtocolPlain    = "PLAIN"
+	saslSCRAMAlgorithmSHA512 = "SCRAM-SHA-512"
+	saslSCRAMAlgorithmSHA256 = "SCRAM-SHA-256"
+
+	offsetEarliest = "earliest"
+
+	defaultRerunDelay = 30 * time.Second
+
+	consumerSessionTimeout    = 30 * time.Second
+	consumerHeartbeatInterval = 3 * time.Second
+	consumerPollTimeout       = 1 * time.Second
+
+	producerMaxRetry     = 10
+	producerRetryBackoff = 100 * time.Millisecond
+	producerTimeout      = 10 * time.Second
+	producerFlushTimeout = 10 * time.Second
+
+	metadataTimeout = 10 * time.Second
+)
+
+type Consumer struct {
+	GroupID string
+	// InitialOffset is used if the group has no committed offset: earliest or latest
+	InitialOffset     string
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+	CommitOnError     bool
+	// MinBytes, MaxBytes and MaxWait tune fetch requests. Librdkafka defaults are used for zero values
+	MinBytes int
+	MaxBytes int
+	MaxWait  time.Duration
+	// PollTimeout is a maximum time of a single poll. The listener checks for stop between polls
+	PollTimeout time.Duration
+}
+
+func (c *Consumer) initDefault() {
+	if c.InitialOffset == "" {
+		c.InitialOffset = offsetEarliest
+	}
+
+	if c.SessionTimeout.Seconds() == 0 {
+		c.SessionTimeout = consumerSessionTimeout
+	}
+
+	if c.HeartbeatInterval.Seconds() == 0 {
+		c.HeartbeatInterval = consumerHeartbeatInterval
+	}
+
+	if c.PollTimeout.Milliseconds() == 0 {
+		c.PollTimeout = consumerPollTimeout
+	}
+}
+
+type Producer struct {
+	MaxRetry     int
+	RetryBackoff time.Duration
+	// Timeout limits the time a message waits for delivery report including retries
+	Timeout time.Duration
+	// RequiredAcks is one of: none, one, all
+	RequiredAcks kafka.RequiredAcks
+	// FlushMessages and FlushFrequency tune batching. Librdkafka defaults are used for zero values
+	FlushMessages  int
+	FlushFrequency time.Duration
+	// Idempotent sets enable.idempotence of librdkafka and overrides RequiredAcks with all
+	Idempotent bool
+}
+
+func (p *Producer) initDefault() {
+	if p.MaxRetry == 0 {
+		p.MaxRetry = producerMaxRetry
+	}
+
+	if p.RetryBackoff.Milliseconds() == 0 {
+		p.RetryBackoff = producerRetryBackoff
+	}
+
+	if p.Timeout.Seconds() == 0 {
+		p.Timeout = producerTimeout
+	}
+}
+
+type Config struct {
+	Brokers                    []string
+	ClientID                   string
+	LoggerEnabled              bool
+	CommitOnErrorMessagesCount int
+	RerunDelay                 time.Duration
+	TLS                        kafka.TLS
+	SASL                       kafka.SASL
+	UseKeyDoubleQuote          bool
+	Consumer                   Consumer
+	Producer                   Producer
+	// EventLease is used to claim consumed events in the store before handling
+	EventLease provider.LeaseSettings
+	// Codec encodes published events and decodes consumed ones. Events encode themselves to JSON if it's nil
+	Codec codec.Codec
+}
+
+func (c *Config) defaults() {
+	c.Consumer.initDefault()
+	c.Producer.initDefault()
+
+	if c.RerunDelay.Seconds() == 0 {
+		c.RerunDelay = defaultRerunDelay
+	}
+}
+
+// configFromKafka maps the config of the segmentio provider onto the confluent config,
+// so the same config is accepted by both providers
+func configFromKafka(kc *kafka.Config) *Config {
+	if kc == nil {
+		return &Config{}
+	}
+
+	return &Config{
+		Brokers:                    kc.Brokers,
+		LoggerEnabled:              kc.LoggerEnabled,
+		CommitOnErrorMessagesCount: kc.CommitOnErrorMessagesCount,
+		RerunDelay:                 kc.RerunDelay,
+		TLS:                        kc.TLS,
+		SASL:                       kc.SASL,
+		UseKeyDoubleQuote:          kc.UseKeyDoubleQuote,
+		Consumer: Consumer{
+			GroupID:           kc.Consumer.GroupID,
+			SessionTimeout:    kc.Consumer.SessionTimeout,
+			HeartbeatInterval: kc.Consumer.HeartbeatInterval,
+			CommitOnError:     kc.Consumer.CommitOnError,
+			MinBytes:          kc.Consumer.MinBytes,
+			MaxBytes:          kc.Consumer.MaxBytes,
+			MaxWait:           kc.Consumer.MaxWait,
+		},
+		Producer: Producer{
+			MaxRetry:       kc.Producer.MaxRetry,
+			RetryBackoff:   kc.Producer.MaxAttemptsDelay,
+			Timeout:        kc.Producer.WriteTimeout,
+			RequiredAcks:   kc.Producer.RequiredAcks,
+			FlushMessages:  kc.Producer.WriterBatchSize,
+			FlushFrequency: kc.Producer.BatchTimeout,
+		},
+		EventLease: kc.EventLease,
+		Codec:      kc.Codec,
+	}
+}
+
+// consumerConfigMap returns librdkafka properties of a consumer.
+// Offsets are committed explicitly after the message is handled.
+func (c *Config) consumerConfigMap(logs chan goConfluent.LogEvent) *goConfluent.ConfigMap {
+	cm := c.commonConfigMap(logs)
+
+	_ = cm.SetKey("group.id", c.Consumer.GroupID)
+	_ = cm.SetKey("enable.auto.commit", false)
+	_ = cm.SetKey("auto.offset.reset", c.Consumer.InitialOffset)
+	_ = cm.SetKey("isolation.level", "read_committed")
+	_ = cm.SetKey("session.timeout.ms", int(c.Consumer.SessionTimeout.Milliseconds()))
+	_ = cm.SetKey("heartbeat.interval.ms", int(c.Consumer.HeartbeatInterval.Milliseconds()))
+
+	if c.Consumer.MinBytes > 0 {
+		_ = cm.SetKey("fetch.min.bytes", c.Consumer.MinBytes)
+	}
+
+	if c.Consumer.MaxBytes > 0 {
+		_ = cm.SetKey("fetch.max.bytes", c.Consumer.MaxBytes)
+	}
+
+	if c.Consumer.MaxWait > 0 {
+		_ = cm.SetKey("fetch.wait.max.ms", int(c.Consumer.MaxWait.Milliseconds()))
+	}
+
+	return cm
+}
+
+func (c *Config) producerConfigMap(logs chan goConfluent.LogEvent) *goConfluent.ConfigMap {
+	cm := c.commonConfigMap(logs)
+
+	acks := c.requiredAcks()
+	if c.Producer.Idempotent {
+		acks = "all"
+	}
+
+	_ = cm.SetKey("acks", acks)
+	_ = cm.SetKey("enable.idempotence", c.Producer.Idempotent)
+	_ = cm.SetKey("message.send.max.retries", c.Producer.MaxRetry)
+	_ = cm.SetKey("retry.backoff.ms", int(c.Producer.RetryBackoff.Milliseconds()))
+	_ = cm.SetKey("message.timeout.ms", int(c.Producer.Timeout.Milliseconds()))
+
+	if c.Producer.FlushMessages > 0 {
+		_ = cm.SetKey("batch.num.messages", c.Producer.FlushMessages)
+	}
+
+	if c.Producer.FlushFrequency > 0 {
+		_ = cm.SetKey("linger.ms", int(c.Producer.FlushFrequency.Milliseconds()))
+	}
+
+	return cm
+}
+
+func (c *Config) commonConfigMap(logs chan goConfluent.LogEvent) *goConfluent.ConfigMap {
+	cm := &goConfluent.ConfigMap{
+		"bootstrap.servers": strings.Join(c.Brokers, ","),
+	}
+
+	if c.ClientID != "" {
+		_ = cm.SetKey("client.id", c.ClientID)
+	}
+
+	if c.LoggerEnabled && logs != nil {
+		_ = cm.SetKey("go.logs.channel.enable", true)
+		_ = cm.SetKey("go.logs.channel", logs)
+	}
+
+	c.setSecurity(cm)
+
+	return cm
+}
+
+func (c *Config) requiredAcks() string {
+	switch c.Producer.RequiredAcks {
+	case "none":
+		return "0"
+	case "one":
+		return "1"
+	default:
+		return "all"
+	}
+}
+
+func (c *Config) setSecurity(cm *goConfluent.ConfigMap) {
+	mechanism := c.saslMechanism()
+
+	switch {
+	case c.TLS.Enabled && mechanism != "":
+		_ = cm.SetKey("security.protocol", "SASL_SSL")
+	case c.TLS.Enabled:
+		_ = cm.SetKey("security.protocol", "SSL")
+	case mechanism != "":
+		_ = cm.SetKey("security.protocol", "SASL_PLAINTEXT")
+	}
+
+	if c.TLS.Enabled {
+		if c.TLS.InsecureSkipVerify {
+			_ = cm.SetKey("enable.ssl.certificate.verification", false)
+		}
+
+		if c.TLS.ClientCertFile != "" && c.TLS.ClientKeyFile != "" {
+			_ = cm.SetKey("ssl.certificate.location", c.TLS.ClientCertFile)
+			_ = cm.SetKey("ssl.key.location", c.TLS.ClientKeyFile)
+		}
+
+		if c.TLS.RootCACertFile != "" {
+			_ = cm.SetKey("ssl.ca.location", c.TLS.RootCACertFile)
+		}
+	}
+
+	if mechanism != "" {
+		_ = cm.SetKey("sasl.mechanisms", mechanism)
+		_ = cm.SetKey("sasl.username", c.SASL.Username)
+		_ = cm.SetKey("sasl.password", c.SASL.Password)
+	}
+}
+
+func (c *Config) saslMechanism() string {
+	switch c.SASL.SecurityProtocol {
+	case securityProtocolPlain:
+		return securityProtocolPlain
+	case securityProtocolSCRAM:
+		if c.SASL.Algorithm == saslSCRAMAlgorithmSHA512 {
+			return saslSCRAMAlgorithmSHA512
+		}
+
+		return saslSCRAMAlgorithmSHA256
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,66 @@
+package confluent
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"sort"
+
+	goConfluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// newMessage returns the message of the event encoded by the codec of the config
+// with request ID, trace context and metadata headers
+func newMessage(ctx context.Context, cfg *Config, topic string, e event.BaseEvent) (*goConfluent.Message, error) {
+	key := provider.EventKey(e)
+	if cfg.UseKeyDoubleQuote {
+		key = fmt.Sprintf("%q", key)
+	}
+
+	value := e.ToByte()
+
+	if cfg.Codec != nil {
+		var err error
+
+		if value, err = cfg.Codec.Encode(ctx, topic, e); err != nil {
+			return nil, err
+		}
+	}
+
+	return &goConfluent.Message{
+		TopicPartition: goConfluent.TopicPartition{Topic: &topic, Partition: goConfluent.PartitionAny},
+		Key:            []byte(key),
+		Value:          value,
+		Headers:        recordHeaders(ctx, e),
+	}, nil
+}
+
+// recordHeaders returns request ID, trace context and metadata headers of the event sorted by key
+func recordHeaders(ctx context.Context, e event.BaseEvent) []goConfluent.Header {
+	hm := provider.RecordHeaders(ctx, e)
+
+	keys := make([]string, 0, len(hm))
+	for k := range hm {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	headers := make([]goConfluent.Header, len(keys))
+	for i, k := range keys {
+		headers[i] = goConfluent.Header{Key: k, Value: []byte(hm[k])}
+	}
+
+	return headers
+}
+
+// messageContext returns ctx with the topic of the consumed message and the values of its headers
+func messageContext(ctx context.Context, topic string, msg *goConfluent.Message) context.Context {
+	hm := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		hm[h.Key] = string(h.Value)
+	}
+
+	return provider.WithTopic(provider.ContextFromHeaders(ctx, hm), topic)
+}
@@ -0,0 +1,427 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/converto"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/log/logger"
+	"kafka-polygon/pkg/tracing"
+	"sync"
+	"time"
+
+	goConfluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	BrokerConfluentProvider = "confluent"
+	TraceConfluentProducer  = "_confluent_producer"
+	TraceConfluentConsumer  = "_confluent_consumer"
+
+	logsCapacity = 100
+)
+
+// KConsumer is a part of confluent consumer used by the provider
+type KConsumer interface {
+	Subscribe(topic string, rebalanceCb goConfluent.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*goConfluent.Message, error)
+	CommitMessage(m *goConfluent.Message) ([]goConfluent.TopicPartition, error)
+	Close() error
+}
+
+// KProducer is a part of confluent producer used by the provider
+type KProducer interface {
+	Produce(msg *goConfluent.Message, deliveryChan chan goConfluent.Event) error
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*goConfluent.Metadata, error)
+	Flush(timeoutMs int) int
+	Close()
+}
+
+// ConsumerFactory creates a consumer of the configured group
+type ConsumerFactory func(ctx context.Context) (KConsumer, error)
+
+// ProducerFactory creates a producer
+type ProducerFactory func(ctx context.Context) (KProducer, error)
+
+// Provider is an at-least-once provider based on confluent-kafka-go.
+// It accepts the config of the segmentio provider, so services switch libraries by changing the constructor.
+// The offset of a consumed message is committed after its handler succeeded.
+type Provider struct {
+	cfg                 *Config
+	enabled             bool
+	store               store.Store
+	trace               tracing.Tracer
+	newConsumer         ConsumerFactory
+	newProducer         ProducerFactory
+	mx                  sync.Mutex
+	producer            KProducer
+	logs                chan goConfluent.LogEvent
+	stopCh              chan struct{}
+	stopOnce            sync.Once
+	wg                  sync.WaitGroup
+	errCntMu            sync.Mutex
+	failedMessagesCount int
+}
+
+func NewProvider(kc *kafka.Config) *Provider {
+	return NewProviderWithConfig(configFromKafka(kc))
+}
+
+// NewProviderWithConfig creates a provider with confluent specific settings
+func NewProviderWithConfig(cfg *Config) *Provider {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	cfg.defaults()
+
+	p := &Provider{
+		cfg:     cfg,
+		enabled: true,
+		stopCh:  make(chan struct{}),
+	}
+	p.newConsumer = p.defaultConsumer
+	p.newProducer = p.defaultProducer
+
+	if cfg.LoggerEnabled {
+		p.logs = make(chan goConfluent.LogEvent, logsCapacity)
+
+		go p.writeLogs()
+	}
+
+	return p
+}
+
+func (p *Provider) SetConsumerFactory(f ConsumerFactory) {
+	p.newConsumer = f
+}
+
+// SetProducerFactory sets a producer factory. The producer is created on the first use
+func (p *Provider) SetProducerFactory(f ProducerFactory) {
+	p.newProducer = f
+}
+
+func (p *Provider) SetEnabled(enable bool) {
+	p.enabled = enable
+}
+
+func (p *Provider) SetStore(s store.Store) {
+	p.store = s
+}
+
+func (p *Provider) SetTracing(t tracing.Tracer) {
+	p.trace = t
+}
+
+func (p *Provider) GetType() string {
+	return BrokerConfluentProvider
+}
+
+func (p *Provider) GetIsTopicExists(ctx context.Context, topic string) (bool, error) {
+	producer, err := p.getProducer(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	md, err := producer.GetMetadata(nil, true, int(metadataTimeout.Milliseconds()))
+	if err != nil {
+		return false, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] GetIsTopicExists GetMetadata. %s", err.Error()).LogError()
+	}
+
+	_, ok := md.Topics[topic]
+
+	return ok, nil
+}
+
+func (p *Provider) Publish(ctx context.Context, topic string, e event.BaseEvent) error {
+	err := p.send(ctx, topic, e)
+
+	provider.TraceEvent(ctx, p.trace, TraceConfluentProducer, topic, e, err)
+	metrics.ObservePublish(BrokerConfluentProvider, topic, 1, err)
+
+	return err
+}
+
+func (p *Provider) Sync(ctx context.Context, topic string, fn provider.HandlerFn) {
+	if !p.enabled {
+		return
+	}
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		p.consume(ctx, topic, fn)
+	}()
+}
+
+func (p *Provider) Stop() {
+	if !p.enabled {
+		return
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		p.wg.Wait()
+
+		p.mx.Lock()
+		if p.producer != nil {
+			if n := p.producer.Flush(int(producerFlushTimeout.Milliseconds())); n > 0 {
+				_ = cerror.NewF(context.Background(), cerror.KindKafkaOther,
+					"[confluent] %d messages are not delivered on close", n).LogError()
+			}
+
+			p.producer.Close()
+			p.producer = nil
+		}
+		p.mx.Unlock()
+
+		if p.logs != nil {
+			close(p.logs)
+		}
+
+		if p.trace != nil {
+			_ = p.trace.Shutdown()
+		}
+	})
+}
+
+// consume reads the topic until the provider is stopped or ctx is done.
+// The consumer is recreated after RerunDelay if reading or handling fails.
+func (p *Provider) consume(ctx context.Context, topic string, fn provider.HandlerFn) {
+	for {
+		if !p.listen(ctx, topic, fn) {
+			log.DebugF(ctx, "[confluent] consumer for topic %s stopped", topic)
+			return
+		}
+
+		if !p.waitRerun(ctx) {
+			return
+		}
+
+		metrics.ListenerRestarts.Inc(BrokerConfluentProvider, topic)
+		log.DebugF(ctx, "[confluent] try re-run consumer by topic = %v", topic)
+	}
+}
+
+// listen reads messages with a new consumer. It returns false if the listener must not be re-run.
+func (p *Provider) listen(ctx context.Context, topic string, fn provider.HandlerFn) bool {
+	c, err := p.newConsumer(ctx)
+	if err != nil {
+		return true
+	}
+
+	defer func() {
+		if errCl := c.Close(); errCl != nil {
+			_ = cerror.NewF(ctx, cerror.KafkaToKind(errCl),
+				"[confluent] consumer close. topic: %s. %s", topic, errCl.Error()).LogError()
+		}
+	}()
+
+	if err := c.Subscribe(topic, nil); err != nil {
+		_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] subscribe topic: %s. %s", topic, err.Error()).LogError()
+
+		return true
+	}
+
+	log.DebugF(ctx, "[confluent] start listening kafka topic [%s]", topic)
+
+	for !p.isStopped(ctx) {
+		msg, err := c.ReadMessage(p.cfg.Consumer.PollTimeout)
+		if err != nil {
+			var kErr goConfluent.Error
+			// librdkafka recovers from non-fatal errors by itself
+			if errors.As(err, &kErr) && !kErr.IsFatal() {
+				if kErr.Code() != goConfluent.ErrTimedOut {
+					_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+						"[confluent] read message from topic: %s. %s", topic, err.Error()).LogWarn()
+				}
+
+				continue
+			}
+
+			_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+				"[confluent] consumer for topic = %s stopped. %s", topic, err.Error()).LogError()
+
+			return true
+		}
+
+		if err := p.processMessage(ctx, msg, fn); err != nil {
+			return true
+		}
+
+		if _, err := c.CommitMessage(msg); err != nil {
+			metrics.CommitFailures.Inc(BrokerConfluentProvider, topic)
+			_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+				"[confluent] failed to commit message. topic: %s. partition %d. offset: %v. key: %s. %s",
+				topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset, string(msg.Key), err.Error()).
+				LogError()
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// processMessage handles the message. Its offset can be committed if no error is returned.
+func (p *Provider) processMessage(ctx context.Context, msg *goConfluent.Message, fn provider.HandlerFn) error {
+	topic := converto.StringValue(msg.TopicPartition.Topic)
+
+	log.DebugF(ctx,
+		"[confluent] consume message from kafka topic: %s. partition: %d. offset: %v. key: %s.",
+		topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset, string(msg.Key))
+
+	start := time.Now()
+	ctx = messageContext(ctx, topic, msg)
+	e, err := provider.NewHandlerProcessing(p.store).SetLease(p.cfg.EventLease).SetCodec(p.cfg.Codec).
+		Run(ctx, fn, event.Message{
+			Key:   converto.BytePointer(msg.Key),
+			Value: msg.Value,
+		})
+
+	provider.TraceEvent(ctx, p.trace, TraceConfluentConsumer, topic, e, err)
+	metrics.ObserveHandle(BrokerConfluentProvider, topic, start, err)
+
+	if err == nil {
+		return nil
+	}
+
+	errCnt := p.incErrCnt()
+	if !p.cfg.Consumer.CommitOnError || p.cfg.CommitOnErrorMessagesCount < errCnt {
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] failed process message in handler for topic: %s. partition: %d. offset: %v. %s",
+			topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset, err.Error()).LogError()
+	}
+
+	log.DebugF(ctx,
+		"[confluent] message was processed with error and skipped. total count of skipped messages: %v. allowed count: %v. key = %s",
+		errCnt, p.cfg.CommitOnErrorMessagesCount, string(msg.Key))
+
+	return nil
+}
+
+// send produces the message and waits for its delivery report
+func (p *Provider) send(ctx context.Context, topic string, e event.BaseEvent) error {
+	producer, err := p.getProducer(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg, err := newMessage(ctx, p.cfg, topic, e)
+	if err != nil {
+		return err
+	}
+
+	delivery := make(chan goConfluent.Event, 1)
+
+	if err = producer.Produce(msg, delivery); err != nil {
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] sendMessage topic: %s. %s", topic, err.Error()).LogError()
+	}
+
+	select {
+	case ev := <-delivery:
+		m, ok := ev.(*goConfluent.Message)
+		if !ok {
+			return cerror.NewF(ctx, cerror.KindKafkaOther,
+				"[confluent] sendMessage topic: %s. unexpected delivery event %v", topic, ev).LogError()
+		}
+
+		if m.TopicPartition.Error != nil {
+			return cerror.NewF(ctx, cerror.KafkaToKind(m.TopicPartition.Error),
+				"[confluent] sendMessage topic: %s. %s", topic, m.TopicPartition.Error.Error()).LogError()
+		}
+
+		return nil
+	case <-ctx.Done():
+		return cerror.NewF(ctx, cerror.KafkaToKind(ctx.Err()),
+			"[confluent] sendMessage topic: %s. delivery report isn't received. %s", topic, ctx.Err().Error()).
+			LogError()
+	}
+}
+
+func (p *Provider) isStopped(ctx context.Context) bool {
+	select {
+	case <-p.stopCh:
+		return true
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Provider) waitRerun(ctx context.Context) bool {
+	select {
+	case <-time.After(p.cfg.RerunDelay):
+		return true
+	case <-p.stopCh:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *Provider) incErrCnt() int {
+	p.errCntMu.Lock()
+	defer p.errCntMu.Unlock()
+	p.failedMessagesCount++
+
+	return p.failedMessagesCount
+}
+
+func (p *Provider) getProducer(ctx context.Context) (KProducer, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.producer != nil {
+		return p.producer, nil
+	}
+
+	pr, err := p.newProducer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.producer = pr
+
+	return pr, nil
+}
+
+func (p *Provider) defaultConsumer(ctx context.Context) (KConsumer, error) {
+	c, err := goConfluent.NewConsumer(p.cfg.consumerConfigMap(p.logs))
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] new consumer %s. %s", p.cfg.Consumer.GroupID, err.Error()).LogError()
+	}
+
+	return c, nil
+}
+
+func (p *Provider) defaultProducer(ctx context.Context) (KProducer, error) {
+	pr, err := goConfluent.NewProducer(p.cfg.producerConfigMap(p.logs))
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[confluent] new producer. %s", err.Error()).LogError()
+	}
+
+	return pr, nil
+}
+
+// writeLogs forwards librdkafka logs until the provider is stopped
+func (p *Provider) writeLogs() {
+	for l := range p.logs {
+		log.Log(logger.NewEventF(context.Background(), logger.LevelDebug,
+			"[confluent] %s %s %s", l.Name, l.Tag, l.Message))
+	}
+}
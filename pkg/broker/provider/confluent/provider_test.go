@@ -0,0 +1,296 @@
+package confluent_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/confluent"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/http/consts"
+	"sync"
+	"testing"
+	"time"
+
+	goConfluent "github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/tj/assert"
+)
+
+var (
+	bgCtx      = context.Background()
+	errHandler = errors.New("handler error")
+	e          = event.WorkflowData{
+		ID: "test-id",
+		Workflow: event.Workflow{
+			ID:     "test-wf-id",
+			Schema: "test-type",
+			Step:   "test-task",
+		},
+	}
+)
+
+// partitionLog is a single partition topic shared by consumers of one group
+type partitionLog struct {
+	mx        sync.Mutex
+	topic     string
+	msgs      [][]byte
+	headers   [][]goConfluent.Header
+	committed goConfluent.Offset
+	consumers int
+}
+
+func (pl *partitionLog) newConsumer(_ context.Context) (confluent.KConsumer, error) {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+
+	pl.consumers++
+
+	return &fakeConsumer{log: pl, pos: pl.committed}, nil
+}
+
+func (pl *partitionLog) committedOffset() goConfluent.Offset {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+
+	return pl.committed
+}
+
+type fakeConsumer struct {
+	log *partitionLog
+	pos goConfluent.Offset
+}
+
+func (fc *fakeConsumer) Subscribe(_ string, _ goConfluent.RebalanceCb) error {
+	return nil
+}
+
+func (fc *fakeConsumer) ReadMessage(timeout time.Duration) (*goConfluent.Message, error) {
+	fc.log.mx.Lock()
+	defer fc.log.mx.Unlock()
+
+	if int(fc.pos) >= len(fc.log.msgs) {
+		time.Sleep(timeout)
+		return nil, goConfluent.NewError(goConfluent.ErrTimedOut, "timed out", false)
+	}
+
+	msg := &goConfluent.Message{
+		TopicPartition: goConfluent.TopicPartition{Topic: &fc.log.topic, Offset: fc.pos},
+		Value:          fc.log.msgs[fc.pos],
+	}
+
+	if int(fc.pos) < len(fc.log.headers) {
+		msg.Headers = fc.log.headers[fc.pos]
+	}
+
+	fc.pos++
+
+	return msg, nil
+}
+
+func (fc *fakeConsumer) CommitMessage(m *goConfluent.Message) ([]goConfluent.TopicPartition, error) {
+	fc.log.mx.Lock()
+	defer fc.log.mx.Unlock()
+
+	fc.log.committed = m.TopicPartition.Offset + 1
+
+	return nil, nil
+}
+
+func (fc *fakeConsumer) Close() error {
+	return nil
+}
+
+type fakeProducer struct {
+	mx       sync.Mutex
+	produced []*goConfluent.Message
+	err      error
+	closed   bool
+}
+
+func (fp *fakeProducer) Produce(msg *goConfluent.Message, deliveryChan chan goConfluent.Event) error {
+	fp.mx.Lock()
+	defer fp.mx.Unlock()
+
+	fp.produced = append(fp.produced, msg)
+
+	report := *msg
+	report.TopicPartition.Error = fp.err
+	deliveryChan <- &report
+
+	return nil
+}
+
+func (fp *fakeProducer) GetMetadata(_ *string, _ bool, _ int) (*goConfluent.Metadata, error) {
+	return &goConfluent.Metadata{Topics: map[string]goConfluent.TopicMetadata{"topic": {Topic: "topic"}}}, nil
+}
+
+func (fp *fakeProducer) Flush(_ int) int {
+	return 0
+}
+
+func (fp *fakeProducer) Close() {
+	fp.closed = true
+}
+
+func newTestProvider(pl *partitionLog, fp *fakeProducer) *confluent.Provider {
+	p := confluent.NewProviderWithConfig(&confluent.Config{
+		RerunDelay: 10 * time.Millisecond,
+		Consumer: confluent.Consumer{
+			GroupID:     "group",
+			PollTimeout: 5 * time.Millisecond,
+		},
+	})
+	p.SetConsumerFactory(pl.newConsumer)
+	p.SetProducerFactory(func(_ context.Context) (confluent.KProducer, error) {
+		return fp, nil
+	})
+
+	return p
+}
+
+func TestProviderType(t *testing.T) {
+	t.Parallel()
+
+	p := confluent.NewProvider(&kafka.Config{})
+	assert.Equal(t, confluent.BrokerConfluentProvider, p.GetType())
+}
+
+func TestProviderConsumeAndCommit(t *testing.T) {
+	t.Parallel()
+
+	second := e
+	second.ID = "test-id-2"
+
+	pl := &partitionLog{topic: "topic", msgs: [][]byte{e.ToByte(), second.ToByte()}}
+	p := newTestProvider(pl, &fakeProducer{})
+
+	handled := make(chan string, 2)
+	fn := provider.HandlerWorkflow(func(_ context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		handled <- we.GetID()
+		return nil
+	})
+
+	p.Sync(bgCtx, "topic", fn)
+
+	assert.Equal(t, "test-id", <-handled)
+	assert.Equal(t, "test-id-2", <-handled)
+
+	assert.Eventually(t, func() bool {
+		return pl.committedOffset() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	p.Stop()
+}
+
+func TestProviderRerunOnHandlerError(t *testing.T) {
+	t.Parallel()
+
+	pl := &partitionLog{topic: "topic", msgs: [][]byte{e.ToByte()}}
+	p := newTestProvider(pl, &fakeProducer{})
+
+	var (
+		mx    sync.Mutex
+		calls int
+	)
+
+	fn := provider.HandlerWorkflow(func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		mx.Lock()
+		defer mx.Unlock()
+
+		calls++
+		if calls == 1 {
+			return errHandler
+		}
+
+		return nil
+	})
+
+	p.Sync(bgCtx, "topic", fn)
+
+	// the failed message isn't committed and is consumed again by a new consumer
+	assert.Eventually(t, func() bool {
+		return pl.committedOffset() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	p.Stop()
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, pl.consumers)
+}
+
+func TestProviderPublish(t *testing.T) {
+	t.Parallel()
+
+	fp := &fakeProducer{}
+	p := newTestProvider(&partitionLog{}, fp)
+
+	assert.NoError(t, p.Publish(bgCtx, "topic", &e))
+	assert.Len(t, fp.produced, 1)
+	assert.Equal(t, "topic", *fp.produced[0].TopicPartition.Topic)
+	// the workflow ID is the message key
+	assert.Equal(t, []byte("test-wf-id"), fp.produced[0].Key)
+	assert.Equal(t, e.ToByte(), fp.produced[0].Value)
+
+	fp.err = errors.New("delivery failed")
+	assert.Error(t, p.Publish(bgCtx, "topic", &e))
+
+	exists, err := p.GetIsTopicExists(bgCtx, "topic")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = p.GetIsTopicExists(bgCtx, "unknown")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	p.Stop()
+	assert.True(t, fp.closed)
+}
+
+func TestProviderPropagatesHeaders(t *testing.T) {
+	t.Parallel()
+
+	fp := &fakeProducer{}
+	producer := newTestProvider(&partitionLog{}, fp)
+
+	pe := e
+	pe.Header.RequestID = "request-id"
+	pe.Metadata = metadata.Meta{Module: "module", Version: "0.0.1"}
+
+	assert.NoError(t, producer.Publish(bgCtx, "topic", &pe))
+	assert.Equal(t, []goConfluent.Header{
+		{Key: provider.HeaderEventType, Value: []byte("WorkflowData")},
+		{Key: provider.HeaderModule, Value: []byte("module")},
+		{Key: provider.HeaderRequestID, Value: []byte("request-id")},
+		{Key: provider.HeaderVersion, Value: []byte("0.0.1")},
+	}, fp.produced[0].Headers)
+
+	pl := &partitionLog{
+		topic:   "topic",
+		msgs:    [][]byte{fp.produced[0].Value},
+		headers: [][]goConfluent.Header{fp.produced[0].Headers},
+	}
+	consumer := newTestProvider(pl, &fakeProducer{})
+
+	handled := make(chan context.Context, 1)
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		handled <- ctx
+		return nil
+	})
+
+	consumer.Sync(bgCtx, "topic", fn)
+
+	ctx := <-handled
+	meta, ok := provider.ProducerMeta(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.Meta{Module: "module", Version: "0.0.1"}, meta)
+	assert.Equal(t, "request-id", ctx.Value(consts.HeaderXRequestID))
+	assert.Equal(t, "WorkflowData", provider.EventTypeFromContext(ctx))
+	assert.Equal(t, "topic", provider.TopicFromContext(ctx))
+
+	consumer.Stop()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"kafka-polygon/pkg/broker/event"
 	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
 )
 
 type HandlerFn interface {
@@ -11,6 +12,41 @@ type HandlerFn interface {
 	CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error
 }
 
+// Handle returns a handler of events of type T. The factory creates an empty event a message is decoded into,
+// so one generic handler replaces an adapter type per event.
+func Handle[T event.BaseEvent](factory func(ctx context.Context) T,
+	fn func(context.Context, T, store.EventProcessData) error) HandlerFn {
+	return &typedHandler[T]{factory: factory, fn: fn}
+}
+
+type typedHandler[T event.BaseEvent] struct {
+	factory func(ctx context.Context) T
+	fn      func(context.Context, T, store.EventProcessData) error
+}
+
+func (th *typedHandler[T]) GetEventData(ctx context.Context) event.BaseEvent {
+	return th.factory(ctx)
+}
+
+func (th *typedHandler[T]) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
+	return CallTyped(reqCtx, e, eventData, th.fn)
+}
+
+// CallTyped calls fn with the event of type T. An event of another type is a logged error,
+// as a handler must never report an event it hasn't handled as a success.
+func CallTyped[T any](ctx context.Context, e interface{}, eventData store.EventProcessData,
+	fn func(context.Context, T, store.EventProcessData) error) error {
+	te, ok := e.(T)
+	if !ok {
+		var want T
+
+		return cerror.NewF(ctx, cerror.KindInternal,
+			"handler of %T got event of type %T", &want, e).LogError()
+	}
+
+	return fn(ctx, te, eventData)
+}
+
 // HandlerWorkflow type of WorkflowEvent
 type HandlerWorkflow func(context.Context, event.WorkflowEvent, store.EventProcessData) error
 
@@ -19,11 +55,7 @@ func (hw HandlerWorkflow) GetEventData(_ context.Context) event.BaseEvent {
 }
 
 func (hw HandlerWorkflow) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
-	if ed, ok := e.(event.WorkflowEvent); ok {
-		return hw(reqCtx, ed, eventData)
-	}
-
-	return nil
+	return CallTyped(reqCtx, e, eventData, hw)
 }
 
 // HandlerDebezium type of DebeziumEvent
@@ -37,11 +69,7 @@ func (hd HandlerDebezium) GetEventData(ctx context.Context) event.BaseEvent {
 }
 
 func (hd HandlerDebezium) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
-	if ed, ok := e.(event.DebeziumEvent); ok {
-		return hd(reqCtx, ed, eventData)
-	}
-
-	return nil
+	return CallTyped(reqCtx, e, eventData, hd)
 }
 
 // HandlerMinio type of MinioEvent
@@ -52,11 +80,7 @@ func (hm HandlerMinio) GetEventData(_ context.Context) event.BaseEvent {
 }
 
 func (hm HandlerMinio) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
-	if ed, ok := e.(event.MinioEvent); ok {
-		return hm(reqCtx, ed, eventData)
-	}
-
-	return nil
+	return CallTyped(reqCtx, e, eventData, hm)
 }
 
 // HandlerNotification type of NotificationEvent
@@ -67,9 +91,5 @@ func (hn HandlerNotification) GetEventData(_ context.Context) event.BaseEvent {
 }
 
 func (hn HandlerNotification) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
-	if ed, ok := e.(event.NotificationEvent); ok {
-		return hn(reqCtx, ed, eventData)
-	}
-
-	return nil
+	return CallTyped(reqCtx, e, eventData, hn)
 }
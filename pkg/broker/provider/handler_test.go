@@ -257,3 +257,51 @@ func TestHandlerNotificationError(t *testing.T) {
 	require.Error(t, err)
 	assert.Equal(t, errEmpty, err)
 }
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	var got *event.WorkflowData
+
+	fn := provider.Handle(func(_ context.Context) *event.WorkflowData {
+		return &event.WorkflowData{}
+	}, func(_ context.Context, e *event.WorkflowData, _ store.EventProcessData) error {
+		got = e
+
+		return nil
+	})
+
+	assert.Equal(t, &event.WorkflowData{}, fn.GetEventData(bgCtx))
+
+	eW := &event.WorkflowData{ID: "tests-id", Header: expHeader}
+
+	require.NoError(t, fn.CallFn(bgCtx, eW, store.EventProcessData{}))
+	assert.Equal(t, eW, got)
+}
+
+func TestHandleTypeMismatch(t *testing.T) {
+	t.Parallel()
+
+	called := false
+
+	fn := provider.Handle(func(_ context.Context) *event.WorkflowData {
+		return &event.WorkflowData{}
+	}, func(_ context.Context, _ *event.WorkflowData, _ store.EventProcessData) error {
+		called = true
+
+		return nil
+	})
+
+	err := fn.CallFn(bgCtx, &event.NotificationData{}, store.EventProcessData{})
+	require.Error(t, err)
+	assert.Equal(t, cerror.KindInternal, err.(*cerror.CError).Kind())
+	assert.False(t, called)
+
+	err = provider.HandlerNotification(func(_ context.Context, _ event.NotificationEvent, _ store.EventProcessData) error {
+		called = true
+
+		return nil
+	}).CallFn(bgCtx, &event.MinioData{}, store.EventProcessData{})
+	require.Error(t, err)
+	assert.False(t, called)
+}
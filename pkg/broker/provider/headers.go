@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/http/consts"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Record headers written by producers next to the event body
+const (
+	HeaderRequestID = "x-request-id"
+	HeaderModule    = "x-module"
+	HeaderVersion   = "x-version"
+	HeaderEventType = "x-event-type"
+)
+
+type metaKey struct{}
+
+// RecordHeaders returns headers of the record of the event: its request ID and type (see EventTyper),
+// module and version of its producer and the trace context of ctx. The trace context is written as W3C traceparent
+// and baggage by the global propagator set in tracing.New, so it's empty if tracing isn't set up.
+func RecordHeaders(ctx context.Context, e event.BaseEvent) map[string]string {
+	headers := make(map[string]string)
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+
+	if eventType := EventType(e); eventType != "" {
+		headers[HeaderEventType] = eventType
+	}
+
+	requestID := e.GetHeader().RequestID
+	if requestID == "" {
+		requestID = requestIDFromContext(ctx)
+	}
+
+	if requestID != "" {
+		headers[HeaderRequestID] = requestID
+	}
+
+	meta := e.GetMeta()
+	if meta.Module != "" {
+		headers[HeaderModule] = meta.Module
+	}
+
+	if meta.Version != "" {
+		headers[HeaderVersion] = meta.Version
+	}
+
+	return headers
+}
+
+// ContextFromHeaders returns ctx with the request ID, the event type, the producer metadata and the remote trace context
+// read from the record headers, so spans started with the context are children of the producer's span.
+func ContextFromHeaders(ctx context.Context, headers map[string]string) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+
+	if requestID := headers[HeaderRequestID]; requestID != "" {
+		ctx = context.WithValue(ctx, consts.HeaderXRequestID, requestID) //nolint:staticcheck
+	}
+
+	if eventType := headers[HeaderEventType]; eventType != "" {
+		ctx = context.WithValue(ctx, eventTypeKey{}, eventType)
+	}
+
+	if headers[HeaderModule] != "" || headers[HeaderVersion] != "" {
+		ctx = context.WithValue(ctx, metaKey{}, metadata.Meta{
+			Module:  headers[HeaderModule],
+			Version: headers[HeaderVersion],
+		})
+	}
+
+	return ctx
+}
+
+// ProducerMeta returns module and version of the producer of the handled record
+func ProducerMeta(ctx context.Context) (metadata.Meta, bool) {
+	meta, ok := ctx.Value(metaKey{}).(metadata.Meta)
+
+	return meta, ok
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	reqID := ctx.Value(consts.HeaderXRequestID)
+	if reqID == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s", reqID)
+}
@@ -0,0 +1,99 @@
+package provider_test
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/tracing"
+	"testing"
+
+	"github.com/tj/assert"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sdkProvider struct {
+	tp *tracesdk.TracerProvider
+}
+
+func (sp *sdkProvider) Use(_ *tracing.JaegerOptions) {}
+
+func (sp *sdkProvider) HasSdkTracerProvider() bool {
+	return true
+}
+
+func (sp *sdkProvider) GetSdkTracerProvider() *tracesdk.TracerProvider {
+	return sp.tp
+}
+
+func TestRecordHeaders(t *testing.T) {
+	t.Parallel()
+
+	tr := tracing.New(&sdkProvider{tp: tracesdk.NewTracerProvider()})
+
+	ctx, endTrace := provider.StartEventTrace(_bgCtx, tr, "producer", "topic")
+	defer endTrace(nil, nil)
+
+	ctx = context.WithValue(ctx, consts.HeaderXRequestID, "ctx-request-id") //nolint:staticcheck
+
+	e := &event.WorkflowData{ID: "id"}
+	e.WithMeta(metadata.Meta{Module: "workflow", Version: "4d7f728"})
+
+	headers := provider.RecordHeaders(ctx, e)
+
+	assert.Equal(t, "ctx-request-id", headers[provider.HeaderRequestID])
+	assert.Equal(t, "workflow", headers[provider.HeaderModule])
+	assert.Equal(t, "4d7f728", headers[provider.HeaderVersion])
+	assert.Contains(t, headers["traceparent"], trace.SpanContextFromContext(ctx).TraceID().String())
+
+	e.Header.RequestID = "event-request-id"
+	assert.Equal(t, "event-request-id", provider.RecordHeaders(ctx, e)[provider.HeaderRequestID])
+
+	consumerCtx := provider.ContextFromHeaders(_bgCtx, headers)
+	sc := trace.SpanContextFromContext(consumerCtx)
+
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), sc.TraceID())
+	assert.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), sc.SpanID())
+	assert.Equal(t, "ctx-request-id", consumerCtx.Value(consts.HeaderXRequestID))
+
+	meta, ok := provider.ProducerMeta(consumerCtx)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.Meta{Module: "workflow", Version: "4d7f728"}, meta)
+}
+
+func TestRecordHeadersWithoutTrace(t *testing.T) {
+	t.Parallel()
+
+	headers := provider.RecordHeaders(_bgCtx, &event.WorkflowData{ID: "id"})
+	assert.Empty(t, headers[provider.HeaderRequestID])
+	assert.Empty(t, headers["traceparent"])
+
+	ctx := provider.ContextFromHeaders(_bgCtx, headers)
+	assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+
+	_, ok := provider.ProducerMeta(ctx)
+	assert.False(t, ok)
+}
+
+func TestHandlerProcessingRequestIDFromHeaders(t *testing.T) {
+	t.Parallel()
+
+	var reqID interface{}
+
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		reqID = ctx.Value(consts.HeaderXRequestID)
+
+		return nil
+	})
+
+	ctx := provider.ContextFromHeaders(_bgCtx, map[string]string{provider.HeaderRequestID: "header-request-id"})
+	msg := event.Message{Value: (&event.WorkflowData{ID: "id"}).ToByte()}
+
+	_, err := provider.NewHandlerProcessing(nil).Run(ctx, fn, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "header-request-id", reqID)
+}
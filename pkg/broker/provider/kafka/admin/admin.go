@@ -0,0 +1,62 @@
+// Package admin manages topics and consumer groups of the kafka cluster of the kafka provider.
+// It replaces kafka-topics and kafka-consumer-groups scripts: topics are created, described, altered and deleted,
+// consumer groups are inspected with their lag and their offsets are reset.
+// EnsureTopics is a declarative step services run at startup to create and update their topics.
+package admin
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/cerror"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// cluster is the part of the kafka-go client used by Admin. It's replaced in tests.
+type cluster interface {
+	Metadata(ctx context.Context, req *goKafka.MetadataRequest) (*goKafka.MetadataResponse, error)
+	CreateTopics(ctx context.Context, req *goKafka.CreateTopicsRequest) (*goKafka.CreateTopicsResponse, error)
+	CreatePartitions(ctx context.Context, req *goKafka.CreatePartitionsRequest) (*goKafka.CreatePartitionsResponse, error)
+	DeleteTopics(ctx context.Context, req *goKafka.DeleteTopicsRequest) (*goKafka.DeleteTopicsResponse, error)
+	DescribeConfigs(ctx context.Context, req *goKafka.DescribeConfigsRequest) (*goKafka.DescribeConfigsResponse, error)
+	IncrementalAlterConfigs(ctx context.Context,
+		req *goKafka.IncrementalAlterConfigsRequest) (*goKafka.IncrementalAlterConfigsResponse, error)
+	ListGroups(ctx context.Context, req *goKafka.ListGroupsRequest) (*goKafka.ListGroupsResponse, error)
+	DescribeGroups(ctx context.Context, req *goKafka.DescribeGroupsRequest) (*goKafka.DescribeGroupsResponse, error)
+	OffsetFetch(ctx context.Context, req *goKafka.OffsetFetchRequest) (*goKafka.OffsetFetchResponse, error)
+	OffsetCommit(ctx context.Context, req *goKafka.OffsetCommitRequest) (*goKafka.OffsetCommitResponse, error)
+	ListOffsets(ctx context.Context, req *goKafka.ListOffsetsRequest) (*goKafka.ListOffsetsResponse, error)
+}
+
+var _ cluster = (*goKafka.Client)(nil)
+
+type Admin struct {
+	cl cluster
+}
+
+// New creates an admin of the cluster of the config. Brokers, TLS and SASL settings of the config are used.
+func New(cfg *kafka.Config) *Admin {
+	if cfg == nil {
+		cfg = &kafka.Config{}
+	}
+
+	return &Admin{
+		cl: &goKafka.Client{
+			Addr:      goKafka.TCP(cfg.Brokers...),
+			Timeout:   defaultTimeout,
+			Transport: kafka.NewTransport(context.Background(), cfg),
+		},
+	}
+}
+
+// wrapError returns nil for a nil error and a logged error of the operation otherwise
+func wrapError(ctx context.Context, err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	return cerror.NewF(ctx, cerror.KafkaToKind(err), "[admin] "+format+". %s", append(args, err.Error())...).LogError()
+}
@@ -0,0 +1,460 @@
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+var bgCtx = context.Background()
+
+type fakeTopic struct {
+	partitions int
+	rf         int
+	configs    map[string]string
+}
+
+type fakeGroup struct {
+	state     string
+	members   []goKafka.DescribeGroupsResponseMember
+	committed map[topicPartition]int64
+}
+
+// fakeCluster is an in-memory cluster. Partitions of all topics have offsets [first, last)
+// and the offset at the timestamp is at.
+type fakeCluster struct {
+	topics  map[string]*fakeTopic
+	groups  map[string]*fakeGroup
+	first   int64
+	last    int64
+	at      int64
+	altered []goKafka.IncrementalAlterConfigsRequestResource
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{
+		topics: make(map[string]*fakeTopic),
+		groups: make(map[string]*fakeGroup),
+		first:  2,
+		last:   10,
+		at:     5,
+	}
+}
+
+func (fc *fakeCluster) Metadata(_ context.Context, req *goKafka.MetadataRequest) (*goKafka.MetadataResponse, error) {
+	names := req.Topics
+	if len(names) == 0 {
+		for name := range fc.topics {
+			names = append(names, name)
+		}
+	}
+
+	resp := &goKafka.MetadataResponse{}
+
+	for _, name := range names {
+		t, ok := fc.topics[name]
+		if !ok {
+			resp.Topics = append(resp.Topics, goKafka.Topic{Name: name, Error: goKafka.UnknownTopicOrPartition})
+
+			continue
+		}
+
+		topic := goKafka.Topic{Name: name}
+
+		for p := 0; p < t.partitions; p++ {
+			replicas := make([]goKafka.Broker, t.rf)
+			for r := range replicas {
+				replicas[r] = goKafka.Broker{ID: r + 1}
+			}
+
+			topic.Partitions = append(topic.Partitions, goKafka.Partition{
+				Topic: name, ID: p, Leader: replicas[0], Replicas: replicas, Isr: replicas,
+			})
+		}
+
+		resp.Topics = append(resp.Topics, topic)
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) CreateTopics(_ context.Context,
+	req *goKafka.CreateTopicsRequest) (*goKafka.CreateTopicsResponse, error) {
+	resp := &goKafka.CreateTopicsResponse{Errors: make(map[string]error)}
+
+	for _, tc := range req.Topics {
+		if _, ok := fc.topics[tc.Topic]; ok {
+			resp.Errors[tc.Topic] = goKafka.TopicAlreadyExists
+
+			continue
+		}
+
+		t := &fakeTopic{partitions: tc.NumPartitions, rf: tc.ReplicationFactor, configs: make(map[string]string)}
+		if t.partitions < 0 {
+			t.partitions = 1
+		}
+
+		if t.rf < 0 {
+			t.rf = 1
+		}
+
+		for _, e := range tc.ConfigEntries {
+			t.configs[e.ConfigName] = e.ConfigValue
+		}
+
+		fc.topics[tc.Topic] = t
+		resp.Errors[tc.Topic] = nil
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) CreatePartitions(_ context.Context,
+	req *goKafka.CreatePartitionsRequest) (*goKafka.CreatePartitionsResponse, error) {
+	resp := &goKafka.CreatePartitionsResponse{Errors: make(map[string]error)}
+
+	for _, tp := range req.Topics {
+		fc.topics[tp.Name].partitions = int(tp.Count)
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) DeleteTopics(_ context.Context,
+	req *goKafka.DeleteTopicsRequest) (*goKafka.DeleteTopicsResponse, error) {
+	resp := &goKafka.DeleteTopicsResponse{Errors: make(map[string]error)}
+
+	for _, name := range req.Topics {
+		if _, ok := fc.topics[name]; !ok {
+			resp.Errors[name] = goKafka.UnknownTopicOrPartition
+
+			continue
+		}
+
+		delete(fc.topics, name)
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) DescribeConfigs(_ context.Context,
+	req *goKafka.DescribeConfigsRequest) (*goKafka.DescribeConfigsResponse, error) {
+	resp := &goKafka.DescribeConfigsResponse{}
+
+	for _, r := range req.Resources {
+		res := goKafka.DescribeConfigResponseResource{
+			ResourceName: r.ResourceName,
+			ConfigEntries: []goKafka.DescribeConfigResponseConfigEntry{
+				{ConfigName: "cleanup.policy", ConfigValue: "delete", ConfigSource: 5},
+			},
+		}
+
+		t := fc.topics[r.ResourceName]
+		for _, name := range sortedKeys(t.configs) {
+			res.ConfigEntries = append(res.ConfigEntries, goKafka.DescribeConfigResponseConfigEntry{
+				ConfigName: name, ConfigValue: t.configs[name], ConfigSource: configSourceTopic,
+			})
+		}
+
+		resp.Resources = append(resp.Resources, res)
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) IncrementalAlterConfigs(_ context.Context,
+	req *goKafka.IncrementalAlterConfigsRequest) (*goKafka.IncrementalAlterConfigsResponse, error) {
+	resp := &goKafka.IncrementalAlterConfigsResponse{}
+
+	for _, r := range req.Resources {
+		fc.altered = append(fc.altered, r)
+		t := fc.topics[r.ResourceName]
+
+		for _, c := range r.Configs {
+			if c.ConfigOperation == goKafka.ConfigOperationDelete {
+				delete(t.configs, c.Name)
+			} else {
+				t.configs[c.Name] = c.Value
+			}
+		}
+
+		resp.Resources = append(resp.Resources, goKafka.IncrementalAlterConfigsResponseResource{
+			ResourceType: r.ResourceType, ResourceName: r.ResourceName,
+		})
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) ListGroups(_ context.Context, _ *goKafka.ListGroupsRequest) (*goKafka.ListGroupsResponse, error) {
+	resp := &goKafka.ListGroupsResponse{}
+	for id := range fc.groups {
+		resp.Groups = append(resp.Groups, goKafka.ListGroupsResponseGroup{GroupID: id})
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) DescribeGroups(_ context.Context,
+	req *goKafka.DescribeGroupsRequest) (*goKafka.DescribeGroupsResponse, error) {
+	resp := &goKafka.DescribeGroupsResponse{}
+
+	for _, id := range req.GroupIDs {
+		g, ok := fc.groups[id]
+		if !ok {
+			resp.Groups = append(resp.Groups, goKafka.DescribeGroupsResponseGroup{GroupID: id, GroupState: groupStateDead})
+
+			continue
+		}
+
+		resp.Groups = append(resp.Groups, goKafka.DescribeGroupsResponseGroup{
+			GroupID: id, GroupState: g.state, Members: g.members,
+		})
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) OffsetFetch(_ context.Context,
+	req *goKafka.OffsetFetchRequest) (*goKafka.OffsetFetchResponse, error) {
+	resp := &goKafka.OffsetFetchResponse{Topics: make(map[string][]goKafka.OffsetFetchPartition)}
+	g := fc.groups[req.GroupID]
+
+	for topic, ids := range req.Topics {
+		for _, id := range ids {
+			offset, ok := g.committed[topicPartition{topic: topic, partition: id}]
+			if !ok {
+				offset = -1
+			}
+
+			resp.Topics[topic] = append(resp.Topics[topic], goKafka.OffsetFetchPartition{
+				Partition: id, CommittedOffset: offset,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) OffsetCommit(_ context.Context,
+	req *goKafka.OffsetCommitRequest) (*goKafka.OffsetCommitResponse, error) {
+	g, ok := fc.groups[req.GroupID]
+	if !ok {
+		g = &fakeGroup{state: groupStateEmpty, committed: make(map[topicPartition]int64)}
+		fc.groups[req.GroupID] = g
+	}
+
+	resp := &goKafka.OffsetCommitResponse{Topics: make(map[string][]goKafka.OffsetCommitPartition)}
+
+	for topic, commits := range req.Topics {
+		for _, c := range commits {
+			g.committed[topicPartition{topic: topic, partition: c.Partition}] = c.Offset
+			resp.Topics[topic] = append(resp.Topics[topic], goKafka.OffsetCommitPartition{Partition: c.Partition})
+		}
+	}
+
+	return resp, nil
+}
+
+func (fc *fakeCluster) ListOffsets(_ context.Context,
+	req *goKafka.ListOffsetsRequest) (*goKafka.ListOffsetsResponse, error) {
+	resp := &goKafka.ListOffsetsResponse{Topics: make(map[string][]goKafka.PartitionOffsets)}
+
+	for topic, reqs := range req.Topics {
+		byPartition := make(map[int]*goKafka.PartitionOffsets)
+
+		for _, r := range reqs {
+			po, ok := byPartition[r.Partition]
+			if !ok {
+				po = &goKafka.PartitionOffsets{
+					Partition: r.Partition, FirstOffset: -1, LastOffset: -1, Offsets: make(map[int64]time.Time),
+				}
+				byPartition[r.Partition] = po
+			}
+
+			switch r.Timestamp {
+			case goKafka.FirstOffset:
+				po.FirstOffset = fc.first
+			case goKafka.LastOffset:
+				po.LastOffset = fc.last
+			default:
+				po.Offsets[fc.at] = time.UnixMilli(r.Timestamp)
+			}
+		}
+
+		for _, po := range byPartition {
+			resp.Topics[topic] = append(resp.Topics[topic], *po)
+		}
+	}
+
+	return resp, nil
+}
+
+func TestTopics(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster()
+	a := &Admin{cl: fc}
+
+	require.NoError(t, a.CreateTopics(bgCtx,
+		TopicSpec{Name: "orders", Partitions: 3, ReplicationFactor: 2, Configs: map[string]string{"retention.ms": "1000"}},
+		TopicSpec{Name: "payments"}))
+
+	err := a.CreateTopics(bgCtx, TopicSpec{Name: "orders"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "topic orders")
+
+	topics, err := a.ListTopics(bgCtx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders", "payments"}, topics)
+
+	infos, err := a.DescribeTopics(bgCtx, "orders")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "orders", infos[0].Name)
+	assert.Equal(t, 2, infos[0].ReplicationFactor)
+	assert.Len(t, infos[0].Partitions, 3)
+	assert.Equal(t, PartitionInfo{ID: 2, Leader: 1, Replicas: []int{1, 2}, ISR: []int{1, 2}, OfflineReplicas: []int{}},
+		infos[0].Partitions[2])
+	assert.Equal(t, map[string]string{"retention.ms": "1000", "cleanup.policy": "delete"}, infos[0].Configs)
+	assert.Equal(t, map[string]string{"retention.ms": "1000"}, infos[0].Overrides)
+
+	require.NoError(t, a.AlterTopicConfigs(bgCtx, "orders", map[string]string{"retention.ms": "", "segment.ms": "60000"}))
+	assert.Equal(t, map[string]string{"segment.ms": "60000"}, fc.topics["orders"].configs)
+
+	_, err = a.DescribeTopics(bgCtx, "unknown")
+	assert.Error(t, err)
+
+	require.NoError(t, a.DeleteTopics(bgCtx, "payments"))
+	assert.Error(t, a.DeleteTopics(bgCtx, "payments"))
+}
+
+func TestEnsureTopics(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster()
+	fc.topics["orders"] = &fakeTopic{partitions: 1, rf: 1, configs: map[string]string{"retention.ms": "1000"}}
+	fc.topics["payments"] = &fakeTopic{partitions: 3, rf: 1, configs: map[string]string{}}
+
+	a := &Admin{cl: fc}
+	specs := []TopicSpec{
+		{Name: "orders", Partitions: 3, Configs: map[string]string{"retention.ms": "2000", "cleanup.policy": "delete"}},
+		{Name: "payments", Partitions: 3, ReplicationFactor: 1},
+		{Name: "refunds", Partitions: 2, Configs: map[string]string{"cleanup.policy": "compact"}},
+	}
+
+	require.NoError(t, a.EnsureTopics(bgCtx, specs...))
+
+	assert.Equal(t, 3, fc.topics["orders"].partitions)
+	assert.Equal(t, map[string]string{"retention.ms": "2000"}, fc.topics["orders"].configs)
+	assert.Equal(t, 2, fc.topics["refunds"].partitions)
+	assert.Equal(t, map[string]string{"cleanup.policy": "compact"}, fc.topics["refunds"].configs)
+
+	// configs equal to the effective ones aren't altered
+	require.Len(t, fc.altered, 1)
+	assert.Equal(t, []goKafka.IncrementalAlterConfigsRequestConfig{
+		{Name: "retention.ms", Value: "2000", ConfigOperation: goKafka.ConfigOperationSet},
+	}, fc.altered[0].Configs)
+
+	// the second run is a no-op
+	require.NoError(t, a.EnsureTopics(bgCtx, specs...))
+	assert.Len(t, fc.altered, 1)
+
+	err := a.EnsureTopics(bgCtx,
+		TopicSpec{Name: "orders", Partitions: 1},
+		TopicSpec{Name: "payments", ReplicationFactor: 3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "topic orders has 3 partitions instead of 1")
+	assert.Contains(t, err.Error(), "topic payments has replication factor 1 instead of 3")
+}
+
+func TestDescribeGroup(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster()
+	fc.topics["orders"] = &fakeTopic{partitions: 3, rf: 1}
+	fc.topics["payments"] = &fakeTopic{partitions: 1, rf: 1}
+	fc.groups["group"] = &fakeGroup{
+		state: "Stable",
+		members: []goKafka.DescribeGroupsResponseMember{{
+			MemberID: "member-1",
+			ClientID: "client",
+			MemberAssignments: goKafka.DescribeGroupsResponseAssignments{
+				Topics: []goKafka.GroupMemberTopic{{Topic: "orders", Partitions: []int{0, 1}}},
+			},
+		}},
+		committed: map[topicPartition]int64{
+			{topic: "orders", partition: 0}: 7,
+			{topic: "orders", partition: 2}: 10,
+		},
+	}
+	fc.groups["other"] = &fakeGroup{state: groupStateEmpty}
+
+	a := &Admin{cl: fc}
+
+	groups, err := a.ListGroups(bgCtx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"group", "other"}, groups)
+
+	info, err := a.DescribeGroup(bgCtx, "group")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Stable", info.State)
+	assert.Equal(t, []MemberInfo{{
+		ID: "member-1", ClientID: "client", Assignments: map[string][]int{"orders": {0, 1}},
+	}}, info.Members)
+	assert.Equal(t, []PartitionLag{
+		{Topic: "orders", Partition: 0, Committed: 7, End: 10, Lag: 3, MemberID: "member-1"},
+		{Topic: "orders", Partition: 1, Committed: -1, End: 10, Lag: 8, MemberID: "member-1"},
+		{Topic: "orders", Partition: 2, Committed: 10, End: 10, Lag: 0},
+	}, info.Partitions)
+	assert.Equal(t, int64(11), info.Lag)
+}
+
+func TestResetOffsets(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster()
+	fc.topics["orders"] = &fakeTopic{partitions: 2, rf: 1}
+	fc.groups["group"] = &fakeGroup{state: groupStateEmpty, committed: make(map[topicPartition]int64)}
+
+	a := &Admin{cl: fc}
+
+	for _, tc := range []struct {
+		reset Reset
+		exp   map[int]int64
+	}{
+		{reset: Reset{To: ResetEarliest}, exp: map[int]int64{0: 2, 1: 2}},
+		{reset: Reset{To: ResetLatest, Partitions: []int{1}}, exp: map[int]int64{1: 10}},
+		{reset: Reset{To: ResetTimestamp, Timestamp: time.Now()}, exp: map[int]int64{0: 5, 1: 5}},
+		{reset: Reset{To: ResetOffset, Offset: 4}, exp: map[int]int64{0: 4, 1: 4}},
+		{reset: Reset{To: ResetOffset, Offset: 100}, exp: map[int]int64{0: 10, 1: 10}},
+	} {
+		offsets, err := a.ResetOffsets(bgCtx, "group", "orders", tc.reset)
+		require.NoError(t, err)
+		assert.Equal(t, tc.exp, offsets)
+
+		for p, offset := range tc.exp {
+			assert.Equal(t, offset, fc.groups["group"].committed[topicPartition{topic: "orders", partition: p}])
+		}
+	}
+
+	// offsets of a new group are committed too
+	_, err := a.ResetOffsets(bgCtx, "new-group", "orders", Reset{To: ResetLatest})
+	require.NoError(t, err)
+	assert.Len(t, fc.groups["new-group"].committed, 2)
+
+	_, err = a.ResetOffsets(bgCtx, "group", "orders", Reset{To: ResetEarliest, Partitions: []int{5}})
+	assert.Error(t, err)
+
+	_, err = a.ResetOffsets(bgCtx, "group", "orders", Reset{To: "unknown"})
+	assert.Error(t, err)
+
+	fc.groups["group"].state = "Stable"
+	_, err = a.ResetOffsets(bgCtx, "group", "orders", Reset{To: ResetEarliest})
+	assert.Error(t, err)
+}
@@ -0,0 +1,432 @@
+package admin
+
+import (
+	"context"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"sort"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	groupStateEmpty = "Empty"
+	groupStateDead  = "Dead"
+)
+
+// ResetTo is a position offsets of a group are reset to
+type ResetTo string
+
+const (
+	ResetEarliest  ResetTo = "earliest"
+	ResetLatest    ResetTo = "latest"
+	ResetTimestamp ResetTo = "timestamp"
+	ResetOffset    ResetTo = "offset"
+)
+
+// Reset describes a reset of offsets of a group for partitions of a topic
+type Reset struct {
+	To ResetTo
+	// Timestamp is used with ResetTimestamp. A partition is reset to its first message at or after the timestamp
+	// or to the latest offset if there is no such message.
+	Timestamp time.Time
+	// Offset is used with ResetOffset. It's limited by the earliest and latest offsets of each partition.
+	Offset int64
+	// Partitions are reset partitions. All partitions of the topic are reset if it's empty.
+	Partitions []int
+}
+
+type GroupInfo struct {
+	ID      string
+	State   string
+	Members []MemberInfo
+	// Partitions are partitions with committed offsets or assigned to members sorted by topic and partition
+	Partitions []PartitionLag
+	// Lag is a total lag of the partitions
+	Lag int64
+}
+
+type MemberInfo struct {
+	ID       string
+	ClientID string
+	Host     string
+	// Assignments are partitions assigned to the member by topic
+	Assignments map[string][]int
+}
+
+// PartitionLag is a lag of the group in the partition.
+// Committed is -1 if the group hasn't committed the partition yet, then the lag is counted from the earliest offset.
+type PartitionLag struct {
+	Topic     string
+	Partition int
+	Committed int64
+	End       int64
+	Lag       int64
+	MemberID  string
+}
+
+// ListGroups returns sorted IDs of the consumer groups of the cluster
+func (a *Admin) ListGroups(ctx context.Context) ([]string, error) {
+	resp, err := a.cl.ListGroups(ctx, &goKafka.ListGroupsRequest{})
+	if err != nil {
+		return nil, wrapError(ctx, err, "ListGroups")
+	}
+
+	if resp.Error != nil {
+		return nil, wrapError(ctx, resp.Error, "ListGroups")
+	}
+
+	groups := make([]string, 0, len(resp.Groups))
+	for _, g := range resp.Groups {
+		groups = append(groups, g.GroupID)
+	}
+
+	sort.Strings(groups)
+
+	return groups, nil
+}
+
+// DescribeGroup returns members of the group and its lag per partition
+func (a *Admin) DescribeGroup(ctx context.Context, groupID string) (*GroupInfo, error) {
+	info, err := a.describeGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := a.topicPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	committed, err := a.committedOffsets(ctx, groupID, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[topicPartition]string)
+
+	for _, m := range info.Members {
+		for topic, ids := range m.Assignments {
+			for _, id := range ids {
+				assigned[topicPartition{topic: topic, partition: id}] = m.ID
+			}
+		}
+	}
+
+	consumed := make(map[string][]int)
+
+	for _, topic := range sortedKeys(partitions) {
+		for _, id := range partitions[topic] {
+			tp := topicPartition{topic: topic, partition: id}
+
+			if _, ok := committed[tp]; ok {
+				consumed[topic] = append(consumed[topic], id)
+			} else if _, ok := assigned[tp]; ok {
+				consumed[topic] = append(consumed[topic], id)
+			}
+		}
+	}
+
+	if len(consumed) == 0 {
+		return info, nil
+	}
+
+	bounds, err := a.offsetBounds(ctx, consumed, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range sortedKeys(consumed) {
+		for _, id := range consumed[topic] {
+			tp := topicPartition{topic: topic, partition: id}
+			b := bounds[tp]
+
+			pl := PartitionLag{
+				Topic:     topic,
+				Partition: id,
+				Committed: -1,
+				End:       b.last,
+				MemberID:  assigned[tp],
+			}
+
+			from := b.first
+			if offset, ok := committed[tp]; ok {
+				pl.Committed = offset
+				from = offset
+			}
+
+			if pl.Lag = b.last - from; pl.Lag < 0 {
+				pl.Lag = 0
+			}
+
+			info.Lag += pl.Lag
+			info.Partitions = append(info.Partitions, pl)
+		}
+	}
+
+	return info, nil
+}
+
+// ResetOffsets commits offsets of the group for partitions of the topic by the reset and returns them by partition.
+// The group must have no active members as they would overwrite the offsets.
+func (a *Admin) ResetOffsets(ctx context.Context, groupID, topic string, r Reset) (map[int]int64, error) {
+	info, err := a.describeGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.State != groupStateEmpty && info.State != groupStateDead {
+		return nil, cerror.NewF(ctx, cerror.KindKafkaOther,
+			"[admin] ResetOffsets group %s has active members. state: %s", groupID, info.State).LogError()
+	}
+
+	all, err := a.topicPartitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := selectPartitions(ctx, topic, all[topic], r.Partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	var at time.Time
+	if r.To == ResetTimestamp {
+		at = r.Timestamp
+	}
+
+	bounds, err := a.offsetBounds(ctx, map[string][]int{topic: ids}, at)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int]int64, len(ids))
+	commits := make([]goKafka.OffsetCommit, 0, len(ids))
+
+	for _, id := range ids {
+		offset, err := r.offset(ctx, bounds[topicPartition{topic: topic, partition: id}])
+		if err != nil {
+			return nil, err
+		}
+
+		offsets[id] = offset
+		commits = append(commits, goKafka.OffsetCommit{Partition: id, Offset: offset})
+	}
+
+	resp, err := a.cl.OffsetCommit(ctx, &goKafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]goKafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return nil, wrapError(ctx, err, "ResetOffsets group: %s. topic: %s", groupID, topic)
+	}
+
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, wrapError(ctx, p.Error, "ResetOffsets group: %s. topic: %s. partition: %d", groupID, topic, p.Partition)
+		}
+	}
+
+	log.InfoF(ctx, "[admin] offsets of group %s for topic %s are reset to %s: %v", groupID, topic, r.To, offsets)
+
+	return offsets, nil
+}
+
+func (r Reset) offset(ctx context.Context, b offsetBounds) (int64, error) {
+	switch r.To {
+	case ResetEarliest:
+		return b.first, nil
+	case ResetLatest:
+		return b.last, nil
+	case ResetTimestamp:
+		if b.at < 0 {
+			return b.last, nil
+		}
+
+		return b.at, nil
+	case ResetOffset:
+		switch {
+		case r.Offset < b.first:
+			return b.first, nil
+		case r.Offset > b.last:
+			return b.last, nil
+		default:
+			return r.Offset, nil
+		}
+	}
+
+	return 0, cerror.NewF(ctx, cerror.KindKafkaOther, "[admin] unknown offset reset: %q", r.To).LogError()
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// offsetBounds are the earliest and the latest offsets of a partition and an offset at a timestamp
+type offsetBounds struct {
+	first int64
+	last  int64
+	at    int64
+}
+
+func (a *Admin) describeGroup(ctx context.Context, groupID string) (*GroupInfo, error) {
+	resp, err := a.cl.DescribeGroups(ctx, &goKafka.DescribeGroupsRequest{GroupIDs: []string{groupID}})
+	if err != nil {
+		return nil, wrapError(ctx, err, "DescribeGroup group: %s", groupID)
+	}
+
+	for _, g := range resp.Groups {
+		if g.GroupID != groupID {
+			continue
+		}
+
+		if g.Error != nil {
+			return nil, wrapError(ctx, g.Error, "DescribeGroup group: %s", groupID)
+		}
+
+		info := &GroupInfo{ID: g.GroupID, State: g.GroupState}
+
+		for _, m := range g.Members {
+			mi := MemberInfo{
+				ID:          m.MemberID,
+				ClientID:    m.ClientID,
+				Host:        m.ClientHost,
+				Assignments: make(map[string][]int),
+			}
+
+			for _, t := range m.MemberAssignments.Topics {
+				mi.Assignments[t.Topic] = append(mi.Assignments[t.Topic], t.Partitions...)
+			}
+
+			info.Members = append(info.Members, mi)
+		}
+
+		sort.Slice(info.Members, func(i, j int) bool {
+			return info.Members[i].ID < info.Members[j].ID
+		})
+
+		return info, nil
+	}
+
+	return &GroupInfo{ID: groupID, State: groupStateDead}, nil
+}
+
+// topicPartitions returns sorted partition IDs by topic. All topics are returned if none is given.
+func (a *Admin) topicPartitions(ctx context.Context, topics ...string) (map[string][]int, error) {
+	resp, err := a.cl.Metadata(ctx, &goKafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, wrapError(ctx, err, "read partitions of topics %v", topics)
+	}
+
+	partitions := make(map[string][]int, len(resp.Topics))
+
+	for _, t := range resp.Topics {
+		if t.Error != nil {
+			return nil, wrapError(ctx, t.Error, "read partitions of topic %s", t.Name)
+		}
+
+		ids := make([]int, len(t.Partitions))
+		for i, p := range t.Partitions {
+			ids[i] = p.ID
+		}
+
+		sort.Ints(ids)
+		partitions[t.Name] = ids
+	}
+
+	return partitions, nil
+}
+
+// committedOffsets returns offsets committed by the group. Partitions without commits are skipped.
+func (a *Admin) committedOffsets(ctx context.Context,
+	groupID string, partitions map[string][]int) (map[topicPartition]int64, error) {
+	committed := make(map[topicPartition]int64)
+
+	if len(partitions) == 0 {
+		return committed, nil
+	}
+
+	resp, err := a.cl.OffsetFetch(ctx, &goKafka.OffsetFetchRequest{GroupID: groupID, Topics: partitions})
+	if err != nil {
+		return nil, wrapError(ctx, err, "read offsets of group %s", groupID)
+	}
+
+	if resp.Error != nil {
+		return nil, wrapError(ctx, resp.Error, "read offsets of group %s", groupID)
+	}
+
+	for topic, ps := range resp.Topics {
+		for _, p := range ps {
+			if p.Error == nil && p.CommittedOffset >= 0 {
+				committed[topicPartition{topic: topic, partition: p.Partition}] = p.CommittedOffset
+			}
+		}
+	}
+
+	return committed, nil
+}
+
+// offsetBounds returns the earliest and the latest offsets of the partitions.
+// Offsets at the timestamp are returned too if it's set, -1 if there are no messages after it.
+func (a *Admin) offsetBounds(ctx context.Context,
+	partitions map[string][]int, at time.Time) (map[topicPartition]offsetBounds, error) {
+	req := &goKafka.ListOffsetsRequest{Topics: make(map[string][]goKafka.OffsetRequest, len(partitions))}
+
+	for topic, ids := range partitions {
+		for _, id := range ids {
+			req.Topics[topic] = append(req.Topics[topic], goKafka.FirstOffsetOf(id), goKafka.LastOffsetOf(id))
+
+			if !at.IsZero() {
+				req.Topics[topic] = append(req.Topics[topic], goKafka.TimeOffsetOf(id, at))
+			}
+		}
+	}
+
+	resp, err := a.cl.ListOffsets(ctx, req)
+	if err != nil {
+		return nil, wrapError(ctx, err, "list offsets")
+	}
+
+	bounds := make(map[topicPartition]offsetBounds)
+
+	for topic, ps := range resp.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, wrapError(ctx, p.Error, "list offsets of topic %s partition %d", topic, p.Partition)
+			}
+
+			b := offsetBounds{first: p.FirstOffset, last: p.LastOffset, at: -1}
+			for offset := range p.Offsets {
+				b.at = offset
+			}
+
+			bounds[topicPartition{topic: topic, partition: p.Partition}] = b
+		}
+	}
+
+	return bounds, nil
+}
+
+// selectPartitions returns the selected partitions of the topic or all of them if none is selected
+func selectPartitions(ctx context.Context, topic string, all, selected []int) ([]int, error) {
+	if len(selected) == 0 {
+		return all, nil
+	}
+
+	exists := make(map[int]struct{}, len(all))
+	for _, id := range all {
+		exists[id] = struct{}{}
+	}
+
+	for _, id := range selected {
+		if _, ok := exists[id]; !ok {
+			return nil, cerror.NewF(ctx, cerror.KindKafkaUnknown,
+				"[admin] topic %s has no partition %d", topic, id).LogError()
+		}
+	}
+
+	return selected, nil
+}
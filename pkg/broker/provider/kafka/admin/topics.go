@@ -0,0 +1,402 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"sort"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+// configSourceTopic is a source of configs set on the topic
+const configSourceTopic = 1
+
+// TopicSpec is a desired state of a topic
+type TopicSpec struct {
+	Name string
+	// Partitions and ReplicationFactor are set by the broker defaults if they are zero
+	Partitions        int
+	ReplicationFactor int
+	// Configs are topic configs, e.g. retention.ms or cleanup.policy
+	Configs map[string]string
+}
+
+func (s TopicSpec) topicConfig() goKafka.TopicConfig {
+	tc := goKafka.TopicConfig{
+		Topic:             s.Name,
+		NumPartitions:     -1,
+		ReplicationFactor: -1,
+	}
+
+	if s.Partitions > 0 {
+		tc.NumPartitions = s.Partitions
+	}
+
+	if s.ReplicationFactor > 0 {
+		tc.ReplicationFactor = s.ReplicationFactor
+	}
+
+	for _, name := range sortedKeys(s.Configs) {
+		tc.ConfigEntries = append(tc.ConfigEntries, goKafka.ConfigEntry{ConfigName: name, ConfigValue: s.Configs[name]})
+	}
+
+	return tc
+}
+
+type TopicInfo struct {
+	Name              string
+	Internal          bool
+	ReplicationFactor int
+	Partitions        []PartitionInfo
+	// Configs are effective configs of the topic including broker defaults
+	Configs map[string]string
+	// Overrides are configs set on the topic
+	Overrides map[string]string
+}
+
+// PartitionInfo is a partition of a topic. Brokers are referenced by their IDs.
+type PartitionInfo struct {
+	ID              int
+	Leader          int
+	Replicas        []int
+	ISR             []int
+	OfflineReplicas []int
+}
+
+// CreateTopics creates the topics. Errors of topics are joined.
+func (a *Admin) CreateTopics(ctx context.Context, specs ...TopicSpec) error {
+	req := &goKafka.CreateTopicsRequest{Topics: make([]goKafka.TopicConfig, len(specs))}
+	for i, s := range specs {
+		req.Topics[i] = s.topicConfig()
+	}
+
+	resp, err := a.cl.CreateTopics(ctx, req)
+	if err != nil {
+		return wrapError(ctx, err, "CreateTopics")
+	}
+
+	return wrapError(ctx, joinTopicErrors(resp.Errors), "CreateTopics")
+}
+
+// DeleteTopics deletes the topics. Errors of topics are joined.
+func (a *Admin) DeleteTopics(ctx context.Context, topics ...string) error {
+	resp, err := a.cl.DeleteTopics(ctx, &goKafka.DeleteTopicsRequest{Topics: topics})
+	if err != nil {
+		return wrapError(ctx, err, "DeleteTopics")
+	}
+
+	return wrapError(ctx, joinTopicErrors(resp.Errors), "DeleteTopics")
+}
+
+// ListTopics returns sorted names of the topics of the cluster
+func (a *Admin) ListTopics(ctx context.Context) ([]string, error) {
+	resp, err := a.cl.Metadata(ctx, &goKafka.MetadataRequest{})
+	if err != nil {
+		return nil, wrapError(ctx, err, "ListTopics")
+	}
+
+	topics := make([]string, 0, len(resp.Topics))
+	for _, t := range resp.Topics {
+		topics = append(topics, t.Name)
+	}
+
+	sort.Strings(topics)
+
+	return topics, nil
+}
+
+// DescribeTopics returns partitions and configs of the topics sorted by name. All topics are described if none is given.
+func (a *Admin) DescribeTopics(ctx context.Context, topics ...string) ([]TopicInfo, error) {
+	resp, err := a.cl.Metadata(ctx, &goKafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, wrapError(ctx, err, "DescribeTopics")
+	}
+
+	infos := make([]TopicInfo, 0, len(resp.Topics))
+	resources := make([]goKafka.DescribeConfigRequestResource, 0, len(resp.Topics))
+
+	for _, t := range resp.Topics {
+		if t.Error != nil {
+			return nil, wrapError(ctx, t.Error, "DescribeTopics topic: %s", t.Name)
+		}
+
+		infos = append(infos, newTopicInfo(t))
+		resources = append(resources, goKafka.DescribeConfigRequestResource{
+			ResourceType: goKafka.ResourceTypeTopic,
+			ResourceName: t.Name,
+		})
+	}
+
+	if len(infos) == 0 {
+		return infos, nil
+	}
+
+	configs, err := a.cl.DescribeConfigs(ctx, &goKafka.DescribeConfigsRequest{Resources: resources})
+	if err != nil {
+		return nil, wrapError(ctx, err, "DescribeTopics")
+	}
+
+	byName := make(map[string]*TopicInfo, len(infos))
+	for i := range infos {
+		byName[infos[i].Name] = &infos[i]
+	}
+
+	for _, r := range configs.Resources {
+		if r.Error != nil {
+			return nil, wrapError(ctx, r.Error, "DescribeTopics configs of topic: %s", r.ResourceName)
+		}
+
+		info, ok := byName[r.ResourceName]
+		if !ok {
+			continue
+		}
+
+		for _, e := range r.ConfigEntries {
+			info.Configs[e.ConfigName] = e.ConfigValue
+
+			if e.ConfigSource == configSourceTopic {
+				info.Overrides[e.ConfigName] = e.ConfigValue
+			}
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos, nil
+}
+
+// AlterTopicConfigs sets the configs of the topic. Other configs are kept.
+// A config with an empty value is deleted, so the broker default is used.
+func (a *Admin) AlterTopicConfigs(ctx context.Context, topic string, configs map[string]string) error {
+	res := goKafka.IncrementalAlterConfigsRequestResource{
+		ResourceType: goKafka.ResourceTypeTopic,
+		ResourceName: topic,
+	}
+
+	for _, name := range sortedKeys(configs) {
+		op := goKafka.ConfigOperationSet
+		if configs[name] == "" {
+			op = goKafka.ConfigOperationDelete
+		}
+
+		res.Configs = append(res.Configs, goKafka.IncrementalAlterConfigsRequestConfig{
+			Name:            name,
+			Value:           configs[name],
+			ConfigOperation: op,
+		})
+	}
+
+	resp, err := a.cl.IncrementalAlterConfigs(ctx, &goKafka.IncrementalAlterConfigsRequest{
+		Resources: []goKafka.IncrementalAlterConfigsRequestResource{res},
+	})
+	if err != nil {
+		return wrapError(ctx, err, "AlterTopicConfigs topic: %s", topic)
+	}
+
+	for _, r := range resp.Resources {
+		if r.Error != nil {
+			return wrapError(ctx, r.Error, "AlterTopicConfigs topic: %s", topic)
+		}
+	}
+
+	return nil
+}
+
+// AddPartitions increases the count of partitions of the topic to the given one
+func (a *Admin) AddPartitions(ctx context.Context, topic string, partitions int) error {
+	resp, err := a.cl.CreatePartitions(ctx, &goKafka.CreatePartitionsRequest{
+		Topics: []goKafka.TopicPartitionsConfig{{Name: topic, Count: int32(partitions)}},
+	})
+	if err != nil {
+		return wrapError(ctx, err, "AddPartitions topic: %s", topic)
+	}
+
+	return wrapError(ctx, joinTopicErrors(resp.Errors), "AddPartitions")
+}
+
+// EnsureTopics brings the topics to the specs. Missing topics are created. Existing topics get partitions
+// up to the count of the spec and configs of the spec that differ. Partitions can't be removed and
+// the replication factor isn't changed, so such differences are errors.
+// Topics are processed independently and their errors are joined. Running it again with the same specs is a no-op,
+// so services run it at startup.
+func (a *Admin) EnsureTopics(ctx context.Context, specs ...TopicSpec) error {
+	existing, err := a.ListTopics(ctx)
+	if err != nil {
+		return err
+	}
+
+	exists := make(map[string]struct{}, len(existing))
+	for _, t := range existing {
+		exists[t] = struct{}{}
+	}
+
+	var (
+		missing []TopicSpec
+		present []string
+		errs    []error
+	)
+
+	for _, s := range specs {
+		if _, ok := exists[s.Name]; ok {
+			present = append(present, s.Name)
+		} else {
+			missing = append(missing, s)
+		}
+	}
+
+	if len(missing) > 0 {
+		log.InfoF(ctx, "[admin] create topics %v", specNames(missing))
+
+		if err := a.CreateTopics(ctx, missing...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(present) == 0 {
+		return joinErrors(ctx, errs)
+	}
+
+	infos, err := a.DescribeTopics(ctx, present...)
+	if err != nil {
+		return joinErrors(ctx, append(errs, err))
+	}
+
+	byName := make(map[string]TopicInfo, len(infos))
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+
+	for _, s := range specs {
+		if info, ok := byName[s.Name]; ok {
+			errs = append(errs, a.ensureTopic(ctx, s, info)...)
+		}
+	}
+
+	return joinErrors(ctx, errs)
+}
+
+func (a *Admin) ensureTopic(ctx context.Context, s TopicSpec, info TopicInfo) []error {
+	var errs []error
+
+	if s.ReplicationFactor > 0 && s.ReplicationFactor != info.ReplicationFactor {
+		errs = append(errs, fmt.Errorf("topic %s has replication factor %d instead of %d",
+			s.Name, info.ReplicationFactor, s.ReplicationFactor))
+	}
+
+	switch {
+	case s.Partitions > len(info.Partitions):
+		log.InfoF(ctx, "[admin] add partitions of topic %s: %d -> %d", s.Name, len(info.Partitions), s.Partitions)
+
+		if err := a.AddPartitions(ctx, s.Name, s.Partitions); err != nil {
+			errs = append(errs, err)
+		}
+	case s.Partitions > 0 && s.Partitions < len(info.Partitions):
+		errs = append(errs, fmt.Errorf("topic %s has %d partitions instead of %d. partitions can't be removed",
+			s.Name, len(info.Partitions), s.Partitions))
+	}
+
+	changed := make(map[string]string)
+
+	for name, value := range s.Configs {
+		if current, ok := info.Configs[name]; !ok || current != value {
+			changed[name] = value
+		}
+	}
+
+	if len(changed) > 0 {
+		log.InfoF(ctx, "[admin] alter configs of topic %s: %v", s.Name, changed)
+
+		if err := a.AlterTopicConfigs(ctx, s.Name, changed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func newTopicInfo(t goKafka.Topic) TopicInfo {
+	info := TopicInfo{
+		Name:       t.Name,
+		Internal:   t.Internal,
+		Partitions: make([]PartitionInfo, len(t.Partitions)),
+		Configs:    make(map[string]string),
+		Overrides:  make(map[string]string),
+	}
+
+	for i, p := range t.Partitions {
+		info.Partitions[i] = PartitionInfo{
+			ID:              p.ID,
+			Leader:          p.Leader.ID,
+			Replicas:        brokerIDs(p.Replicas),
+			ISR:             brokerIDs(p.Isr),
+			OfflineReplicas: brokerIDs(p.OfflineReplicas),
+		}
+	}
+
+	sort.Slice(info.Partitions, func(i, j int) bool {
+		return info.Partitions[i].ID < info.Partitions[j].ID
+	})
+
+	if len(info.Partitions) > 0 {
+		info.ReplicationFactor = len(info.Partitions[0].Replicas)
+	}
+
+	return info
+}
+
+func brokerIDs(brokers []goKafka.Broker) []int {
+	ids := make([]int, len(brokers))
+	for i, b := range brokers {
+		ids[i] = b.ID
+	}
+
+	return ids
+}
+
+// joinTopicErrors joins errors of topics sorted by topic
+func joinTopicErrors(errs map[string]error) error {
+	var joined []error
+
+	for _, topic := range sortedKeys(errs) {
+		if errs[topic] != nil {
+			joined = append(joined, fmt.Errorf("topic %s: %w", topic, errs[topic]))
+		}
+	}
+
+	return errors.Join(joined...)
+}
+
+// joinErrors returns a logged error joining the errors
+func joinErrors(ctx context.Context, errs []error) error {
+	err := errors.Join(errs...)
+	if err == nil {
+		return nil
+	}
+
+	return cerror.NewF(ctx, cerror.KafkaToKind(err), "[admin] EnsureTopics. %s", err.Error()).LogError()
+}
+
+func specNames(specs []TopicSpec) []string {
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
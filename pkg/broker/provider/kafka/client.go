@@ -6,13 +6,15 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"kafka-polygon/pkg/broker/codec"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/cerror"
-	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/log"
 	"kafka-polygon/pkg/log/logger"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -38,6 +40,7 @@ const (
 	defaultTransportMetadataTTL    = 6 * time.Second
 	defaultTransportDialTimeout    = 5 * time.Second
 	defaultTransportIdleTimeout    = 30 * time.Second
+	defaultHealthCheckInterval     = 5 * time.Second
 
 	consumerMinBytesDefValue       = 10e3 // 10KB
 	consumerMaxBytesDefValue       = 10e6 // 10MB
@@ -49,6 +52,7 @@ const (
 	consumerSessionTimeout         = 30 * time.Second
 	consumerRebalanceTimeout       = 30 * time.Second
 	consumerJoinGroupBackoff       = 5 * time.Second
+	consumerTopicWatchInterval     = 30 * time.Second
 
 	producerBatchTimeout = 1 * time.Second
 	producerWriteTimeout = 10 * time.Second
@@ -65,6 +69,24 @@ func (hm HandelFn) Handle(ctx context.Context, m *goKafka.Message) (event.BaseEv
 	return hm(ctx, m)
 }
 
+// TopicHandlers dispatches messages of a subscription to handlers of their topics.
+// Messages of retry topics are dispatched to the handler of their original topic.
+type TopicHandlers map[string]MessageHandler
+
+func (th TopicHandlers) Handle(ctx context.Context, m *goKafka.Message) (event.BaseEvent, error) {
+	topic := m.Topic
+	if original := ParseRetryInfo(m).OriginalTopic; original != "" {
+		topic = original
+	}
+
+	h, ok := th[topic]
+	if !ok {
+		return nil, cerror.NewF(ctx, cerror.KindKafkaOther, "no handler of topic %s", topic).LogError()
+	}
+
+	return h.Handle(ctx, m)
+}
+
 type kafkaLogger struct {
 	ctx   context.Context
 	level logger.Level
@@ -76,8 +98,17 @@ func (l *kafkaLogger) Printf(msg string, args ...interface{}) {
 
 type KClient interface {
 	ListenTopic(ctx context.Context, topic string, handler MessageHandler) chan error
+	ListenTopics(ctx context.Context, sub Subscription, handler MessageHandler) chan error
 	GetIsTopicExists(ctx context.Context, topic string) (bool, error)
 	SendMessage(ctx context.Context, topic string, e event.BaseEvent) error
+	SendMessages(ctx context.Context, topic string, events []event.BaseEvent) error
+	SetRebalanceHandler(h RebalanceHandler)
+	// Pause stops handling messages of the topic without leaving the consumer group until Resume
+	Pause(topic string)
+	Resume(topic string)
+	Paused(topic string) bool
+	// Shutdown stops listeners gracefully until ctx is done. Stop waits for them without a deadline.
+	Shutdown(ctx context.Context) error
 	Stop()
 }
 
@@ -97,13 +128,17 @@ type SASL struct {
 }
 
 type Consumer struct {
-	GroupID                string
-	MinBytes               int
-	MaxBytes               int
-	MaxWait                time.Duration
-	ReadLagInterval        time.Duration
-	CommitOnError          bool
-	ListenTopics           []string
+	GroupID         string
+	MinBytes        int
+	MaxBytes        int
+	MaxWait         time.Duration
+	ReadLagInterval time.Duration
+	CommitOnError   bool
+	// ListenTopics and ListenPattern are the subscription returned by Subscription
+	ListenTopics  []string
+	ListenPattern string
+	// TopicWatchInterval is an interval of checking topics matching the pattern of a subscription
+	TopicWatchInterval     time.Duration
 	QueueCapacity          int
 	ReadBatchTimeout       time.Duration
 	HeartbeatInterval      time.Duration
@@ -114,6 +149,10 @@ type Consumer struct {
 	ReadBackoffMin         time.Duration
 	ReadBackoffMax         time.Duration
 	MaxAttempts            int
+	// Workers is a number of handlers running concurrently for each assigned partition.
+	// Messages with the same key are handled in order by the same worker.
+	// Partitions are always handled concurrently, messages of a partition are handled one by one if Workers <= 1.
+	Workers int
 }
 
 func (c *Consumer) initDefault() {
@@ -157,6 +196,10 @@ func (c *Consumer) initDefault() {
 		c.JoinGroupBackoff = consumerJoinGroupBackoff
 	}
 
+	if c.TopicWatchInterval.Seconds() == 0 {
+		c.TopicWatchInterval = consumerTopicWatchInterval
+	}
+
 	if c.ReadBackoffMin.Milliseconds() == 0 {
 		c.ReadBackoffMin = defaultMinBackoff
 	}
@@ -230,6 +273,18 @@ type Config struct {
 	AllowAutoTopicCreation     bool
 	Consumer                   Consumer
 	Producer                   Producer
+	// Retry routes messages failed in handler to retry and dead-letter topics
+	Retry Retry
+	// EventLease is used to claim consumed events in the store before handling
+	EventLease provider.LeaseSettings
+	// Codec encodes published events and decodes consumed ones. Events encode themselves to JSON if it's nil
+	Codec codec.Codec
+	// HealthCheck is called when a handler fails. While it fails, messages of all topics aren't handled
+	// and the failed message is handled again after the check passes, so an outage of a dependency,
+	// e.g. the event store, doesn't fail messages one by one
+	HealthCheck provider.HealthCheck
+	// HealthCheckInterval is a pause between checks while consuming is paused by HealthCheck
+	HealthCheckInterval time.Duration
 }
 
 func (c *Config) defaults() {
@@ -242,14 +297,49 @@ func (c *Config) defaults() {
 	if c.RerunDelay.Seconds() == 0 {
 		c.RerunDelay = defaultRerunDelay
 	}
+
+	if c.HealthCheckInterval.Milliseconds() == 0 {
+		c.HealthCheckInterval = defaultHealthCheckInterval
+	}
 }
 
 type Client struct {
 	errCntMu            sync.Mutex
 	cfg                 *Config
 	failedMessagesCount int
-	wg                  sync.WaitGroup
-	stop                bool
+	// wg counts running listeners. stopCh is closed by Shutdown, listeners aren't started after that
+	wg        sync.WaitGroup
+	stopMx    sync.Mutex
+	stopCh    chan struct{}
+	inflight  *inflightMessages
+	pauses    *pauses
+	rebalance RebalanceHandler
+	// wr is shared by all sends of the client. It is created on the first send and closed in Stop
+	wrMx sync.Mutex
+	wr   messageWriter
+	// the factories are replaced by FakeBroker and in tests to run the client without a cluster
+	groupFactory  func(ctx context.Context, topics []string) (consumerGroup, error)
+	readerFactory func(ctx context.Context, topic string, pa goKafka.PartitionAssignment) (partitionReader, error)
+	writerFactory func(ctx context.Context) messageWriter
+	topicChecker  func(ctx context.Context, topic string) (bool, error)
+	topicLister   func(ctx context.Context) ([]string, error)
+}
+
+// messageWriter writes messages to kafka and releases its connections on Close
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...goKafka.Message) error
+	Close() error
+}
+
+type kafkaWriter struct {
+	*goKafka.Writer
+	tr *goKafka.Transport
+}
+
+func (w *kafkaWriter) Close() error {
+	defer w.tr.CloseIdleConnections()
+
+	return w.Writer.Close()
 }
 
 func NewClient(cfg *Config) KClient {
@@ -259,159 +349,223 @@ func NewClient(cfg *Config) KClient {
 
 	cfg.defaults()
 
-	return &Client{
-		cfg: cfg,
+	c := &Client{
+		cfg:      cfg,
+		stopCh:   make(chan struct{}),
+		inflight: newInflightMessages(),
+		pauses:   newPauses(),
 	}
+	c.groupFactory = c.newConsumerGroup
+	c.readerFactory = c.newPartitionReader
+	c.writerFactory = c.newMessageWriter
+	c.topicChecker = c.readIsTopicExists
+	c.topicLister = c.readTopics
+
+	return c
 }
 
+// ListenTopic joins the consumer group and handles messages of the topic partitions assigned to the client.
+// Messages are committed synchronously after they are handled.
 func (c *Client) ListenTopic(ctx context.Context, topic string, handler MessageHandler) chan error {
-	errCh := make(chan error)
-	reader := c.newReader(ctx, topic)
+	return c.ListenTopics(ctx, Subscription{Topics: []string{topic}}, handler)
+}
 
-	c.wg.Add(1)
+// ListenTopics joins the consumer group once with all topics of the subscription
+// and handles messages of the partitions assigned to the client. The handler gets messages of all the topics,
+// so it dispatches them by Message.Topic, e.g. with TopicHandlers.
+// Nothing is listened after the client is stopped.
+func (c *Client) ListenTopics(ctx context.Context, sub Subscription, handler MessageHandler) chan error {
+	// the error is buffered, so the listener is drained even if nobody waits for it
+	errCh := make(chan error, 1)
+
+	if !c.startListener() {
+		log.DebugF(ctx, "client is stopped. subscription %s isn't listened", sub)
+
+		return errCh
+	}
 
 	go func() {
 		defer c.wg.Done()
 
-		defer func() {
-			log.DebugF(ctx, "reader for topic %s stopped and close", reader.Config().Topic)
+		if err := c.listenGroup(ctx, sub, handler); err != nil {
+			errCh <- err
+		}
+	}()
 
-			if err := reader.Close(); err != nil {
-				_ = cerror.NewF(ctx,
-					cerror.KafkaToKind(err),
-					"[kafka] listenTopicByReaderConfig reader.Close error. %s", err.Error()).
-					LogError()
-			}
-		}()
+	return errCh
+}
 
-		log.DebugF(ctx, "start listening kafka topic [%s]", reader.Config().Topic)
+// SetRebalanceHandler sets a handler notified about partitions assigned to and revoked from the client.
+// It must be set before ListenTopic is called.
+func (c *Client) SetRebalanceHandler(h RebalanceHandler) {
+	c.rebalance = h
+}
 
-		for {
-			if c.stop {
-				break
-			}
+func (c *Client) GetIsTopicExists(ctx context.Context, topic string) (bool, error) {
+	return c.topicChecker(ctx, topic)
+}
 
-			msg, err := reader.FetchMessage(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					log.DebugF(ctx,
-						"failed fetch message from kafka for topic: %v. %s",
-						reader.Config().Topic, err.Error())
+func (c *Client) readIsTopicExists(ctx context.Context, topic string) (bool, error) {
+	topics, err := c.readTopics(ctx)
+	if err != nil {
+		return false, err
+	}
 
-					break
-				}
+	for _, t := range topics {
+		if t == topic {
+			return true, nil
+		}
+	}
 
-				cErr := cerror.NewF(
-					ctx,
-					cerror.KafkaToKind(err),
-					"failed fetch message from kafka for topic: %v. %s", reader.Config().Topic, err.Error()).
-					LogError()
+	return false, nil
+}
 
-				errCh <- cErr
+func (c *Client) SendMessage(ctx context.Context, topic string, e event.BaseEvent) error {
+	msg, err := c.newMessage(ctx, topic, e)
+	if err != nil {
+		return err
+	}
 
-				break
-			}
+	return c.sendRetry(ctx, topic, []goKafka.Message{msg}, true)
+}
 
-			log.DebugF(ctx,
-				"consume message from kafka topic: %s. partition: %d. offset: %d. key: %s.",
-				msg.Topic,
-				msg.Partition,
-				msg.Offset,
-				string(msg.Key))
+// SendMessages publishes the events in one batch. Messages failed with a temporary error are written again.
+// If some events are not published, *provider.BatchError with an error of each event is returned.
+func (c *Client) SendMessages(ctx context.Context, topic string, events []event.BaseEvent) error {
+	msgs := make([]goKafka.Message, len(events))
+	be := provider.NewBatchError(len(msgs))
+	pending := make([]int, 0, len(msgs))
 
-			e, err := handler.Handle(ctx, &msg)
-			if err != nil {
-				errCnt := c.incErrCnt()
-				if !c.cfg.Consumer.CommitOnError || c.cfg.CommitOnErrorMessagesCount < errCnt {
-					cErr := cerror.NewF(
-						ctx,
-						cerror.KafkaToKind(err),
-						"failed process message in handler for topic: %v. key = %s. %s",
-						msg.Topic, string(msg.Key), err.Error()).
-						LogError()
-					errCh <- cErr
-
-					break
-				}
-
-				log.DebugF(ctx,
-					"message was processed with error and skipped. total count of skipped messages: %v. allowed count: %v. key = %s",
-					errCnt,
-					c.cfg.CommitOnErrorMessagesCount,
-					string(msg.Key))
-			}
+	for i, e := range events {
+		msg, err := c.newMessage(ctx, topic, e)
+		if err != nil {
+			be.Errs[i] = err
 
-			ctxWithValues := context.WithValue(ctx, consts.HeaderXRequestID, e.GetHeader().RequestID) //nolint:staticcheck
+			continue
+		}
 
-			err = reader.CommitMessages(context.Background(), msg)
-			if err != nil {
-				cErr := cerror.NewF(
-					ctxWithValues,
-					cerror.KafkaToKind(err),
-					"failed to commit message. topic: %s. partition %d. offset: %d. key: %s. value: %s. %s",
-					msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err.Error()).
-					LogError()
-				errCh <- cErr
-
-				break
-			}
+		msgs[i] = msg
+		pending = append(pending, i)
+	}
+
+	encoded := pending
+
+	for attempt := 0; len(pending) > 0 && attempt <= c.cfg.Producer.MaxRetry; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.cfg.Producer.MaxAttemptsDelay)
 		}
-	}()
 
-	return errCh
-}
+		batch := make([]goKafka.Message, len(pending))
+		for i, idx := range pending {
+			batch[i] = msgs[idx]
+		}
 
-func (c *Client) GetIsTopicExists(ctx context.Context, topic string) (bool, error) {
-	conn, err := c.getConnection(ctx)
-	if err != nil {
-		return false, err
+		err := c.writer().WriteMessages(context.Background(), batch...)
+
+		var failed []int
+
+		for i, idx := range pending {
+			be.Errs[idx] = writeError(err, i)
+			if be.Errs[idx] != nil && c.isTemporaryWriteError(be.Errs[idx]) {
+				failed = append(failed, idx)
+			}
+		}
+
+		pending = failed
 	}
 
-	defer func() {
-		errConn := conn.Close()
-		if errConn != nil {
-			_ = cerror.NewF(ctx,
-				cerror.KafkaToKind(err),
-				"[kafka] GetIsTopicExists wr.Close error: %s", errConn.Error()).
+	for _, i := range encoded {
+		if err := be.Errs[i]; err != nil {
+			be.Errs[i] = cerror.NewF(ctx, cerror.KafkaToKind(err),
+				"sendMessages topic: %s. key: %s. %s", topic, string(msgs[i].Key), err.Error()).
 				LogError()
 		}
-	}()
+	}
 
-	partitions, err := conn.ReadPartitions()
-	if err != nil {
-		return false, cerror.NewF(ctx,
-			cerror.KafkaToKind(err),
-			"[kafka] GetIsTopicExists conn.ReadPartitions error: %s", err.Error()).
-			LogError()
+	return be.ErrOrNil()
+}
+
+func (c *Client) newMessage(ctx context.Context, topic string, e event.BaseEvent) (goKafka.Message, error) {
+	key := provider.EventKey(e)
+	if c.cfg.UseKeyDoubleQuote {
+		key = fmt.Sprintf("%q", key)
 	}
 
-	for i := range partitions {
-		if partitions[i].Topic == topic {
-			return true, nil
+	value := e.ToByte()
+
+	if c.cfg.Codec != nil {
+		var err error
+
+		if value, err = c.cfg.Codec.Encode(ctx, topic, e); err != nil {
+			return goKafka.Message{}, err
 		}
 	}
 
-	return false, nil
+	return goKafka.Message{
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   value,
+		Headers: recordHeaders(ctx, e),
+	}, nil
 }
 
-func (c *Client) SendMessage(ctx context.Context, topic string, e event.BaseEvent) error {
-	key := e.GetID()
-	if c.cfg.UseKeyDoubleQuote {
-		key = fmt.Sprintf("%q", e.GetID())
+// recordHeaders returns request ID, trace context and metadata headers of the event sorted by key
+func recordHeaders(ctx context.Context, e event.BaseEvent) []goKafka.Header {
+	hm := provider.RecordHeaders(ctx, e)
+
+	keys := make([]string, 0, len(hm))
+	for k := range hm {
+		keys = append(keys, k)
 	}
 
-	return c.sendRetry(ctx, topic, []goKafka.Message{
-		{
-			Topic: topic,
-			Key:   []byte(key),
-			Value: e.ToByte(),
-		},
-	}, true)
+	sort.Strings(keys)
+
+	headers := make([]goKafka.Header, len(keys))
+	for i, k := range keys {
+		headers[i] = goKafka.Header{Key: k, Value: []byte(hm[k])}
+	}
+
+	return headers
 }
 
-func (c *Client) Stop() {
-	c.stop = true
-	c.wg.Wait()
+// HeadersMap returns headers of a message by key. The last value is kept for repeated keys.
+func HeadersMap(headers []goKafka.Header) map[string]string {
+	hm := make(map[string]string, len(headers))
+	for _, h := range headers {
+		hm[h.Key] = string(h.Value)
+	}
+
+	return hm
+}
+
+// writer returns the writer shared by all sends of the client
+func (c *Client) writer() messageWriter {
+	c.wrMx.Lock()
+	defer c.wrMx.Unlock()
+
+	if c.wr == nil {
+		c.wr = c.writerFactory(context.Background())
+	}
+
+	return c.wr
+}
+
+func (c *Client) closeWriter() {
+	c.wrMx.Lock()
+	defer c.wrMx.Unlock()
+
+	if c.wr == nil {
+		return
+	}
+
+	if err := c.wr.Close(); err != nil {
+		_ = cerror.NewF(context.Background(),
+			cerror.KafkaToKind(err),
+			"[kafka] closeWriter wr.Close error: %s", err.Error()).
+			LogError()
+	}
+
+	c.wr = nil
 }
 
 func (c *Client) getConnection(ctx context.Context) (*goKafka.Conn, error) {
@@ -464,38 +618,38 @@ func (c *Client) newDialer(ctx context.Context) *goKafka.Dialer {
 	}
 }
 
-func (c *Client) newReader(ctx context.Context, topic string) *goKafka.Reader {
+// newReader creates a reader of the partition. Partitions are assigned to the client by its consumer group.
+func (c *Client) newReader(ctx context.Context, topic string, partition int) *goKafka.Reader {
 	var l goKafka.Logger
 	if c.cfg.LoggerEnabled {
 		l = &kafkaLogger{ctx: ctx, level: logger.LevelTrace}
 	}
 
 	return goKafka.NewReader(goKafka.ReaderConfig{
-		Logger:                 l,
-		ErrorLogger:            &kafkaLogger{ctx: ctx, level: logger.LevelError},
-		Brokers:                c.cfg.Brokers,
-		Topic:                  topic,
-		GroupID:                c.cfg.Consumer.GroupID,
-		MinBytes:               c.cfg.Consumer.MinBytes,
-		MaxBytes:               c.cfg.Consumer.MaxBytes,
-		MaxWait:                c.cfg.Consumer.MaxWait,
-		ReadLagInterval:        c.cfg.Consumer.ReadLagInterval,
-		QueueCapacity:          c.cfg.Consumer.QueueCapacity,
-		ReadBatchTimeout:       c.cfg.Consumer.ReadBatchTimeout,
-		HeartbeatInterval:      c.cfg.Consumer.HeartbeatInterval,
-		PartitionWatchInterval: c.cfg.Consumer.PartitionWatchInterval,
-		SessionTimeout:         c.cfg.Consumer.SessionTimeout,
-		RebalanceTimeout:       c.cfg.Consumer.RebalanceTimeout,
-		JoinGroupBackoff:       c.cfg.Consumer.JoinGroupBackoff,
-		ReadBackoffMin:         c.cfg.Consumer.ReadBackoffMin,
-		ReadBackoffMax:         c.cfg.Consumer.ReadBackoffMax,
-		MaxAttempts:            c.cfg.Consumer.MaxAttempts,
-		Dialer:                 c.newDialer(ctx),
-		RetentionTime:          oneDayTime,
-		IsolationLevel:         goKafka.ReadCommitted,
+		Logger:           l,
+		ErrorLogger:      &kafkaLogger{ctx: ctx, level: logger.LevelError},
+		Brokers:          c.cfg.Brokers,
+		Topic:            topic,
+		Partition:        partition,
+		MinBytes:         c.cfg.Consumer.MinBytes,
+		MaxBytes:         c.cfg.Consumer.MaxBytes,
+		MaxWait:          c.cfg.Consumer.MaxWait,
+		ReadLagInterval:  c.cfg.Consumer.ReadLagInterval,
+		QueueCapacity:    c.cfg.Consumer.QueueCapacity,
+		ReadBatchTimeout: c.cfg.Consumer.ReadBatchTimeout,
+		ReadBackoffMin:   c.cfg.Consumer.ReadBackoffMin,
+		ReadBackoffMax:   c.cfg.Consumer.ReadBackoffMax,
+		MaxAttempts:      c.cfg.Consumer.MaxAttempts,
+		Dialer:           c.newDialer(ctx),
+		IsolationLevel:   goKafka.ReadCommitted,
 	})
 }
 
+// NewTransport returns a transport to the brokers of the config with its TLS and SASL settings
+func NewTransport(ctx context.Context, cfg *Config) *goKafka.Transport {
+	return (&Client{cfg: cfg}).getTransport(ctx)
+}
+
 func (c *Client) getTransport(ctx context.Context) *goKafka.Transport {
 	return &goKafka.Transport{
 		Dial: (&net.Dialer{
@@ -511,12 +665,21 @@ func (c *Client) getTransport(ctx context.Context) *goKafka.Transport {
 	}
 }
 
-func (c *Client) newWriter(ctx context.Context, tr *goKafka.Transport) *goKafka.Writer {
-	b := goKafka.Balancer(&goKafka.Murmur2Balancer{})
+func (c *Client) newMessageWriter(ctx context.Context) messageWriter {
+	tr := c.getTransport(ctx)
+
+	return &kafkaWriter{Writer: c.newWriter(ctx, tr), tr: tr}
+}
+
+func (c *Client) balancer() goKafka.Balancer {
 	if c.cfg.Producer.Balancer != nil {
-		b = c.cfg.Producer.Balancer
+		return c.cfg.Producer.Balancer
 	}
 
+	return &goKafka.Murmur2Balancer{}
+}
+
+func (c *Client) newWriter(ctx context.Context, tr *goKafka.Transport) *goKafka.Writer {
 	var l goKafka.Logger
 	if c.cfg.LoggerEnabled {
 		l = &kafkaLogger{ctx: ctx, level: logger.LevelDebug}
@@ -525,7 +688,7 @@ func (c *Client) newWriter(ctx context.Context, tr *goKafka.Transport) *goKafka.
 	return &goKafka.Writer{
 		Logger:                 l,
 		ErrorLogger:            &kafkaLogger{ctx: ctx, level: logger.LevelError},
-		Balancer:               b,
+		Balancer:               c.balancer(),
 		Addr:                   goKafka.TCP(c.cfg.Brokers...),
 		MaxAttempts:            c.cfg.Producer.MaxAttempts,
 		BatchSize:              c.cfg.Producer.WriterBatchSize,
@@ -550,18 +713,7 @@ func (c *Client) incErrCnt() int {
 }
 
 func (c *Client) sendRetry(ctx context.Context, topic string, msgs []goKafka.Message, retry bool) error {
-	tr := c.getTransport(context.Background())
-	defer tr.CloseIdleConnections()
-
-	wr := c.newWriter(ctx, tr)
-	defer func() {
-		errWR := wr.Close()
-		if errWR != nil {
-			_ = cerror.NewF(ctx,
-				cerror.KafkaToKind(errWR),
-				"sendMessage topic: %s. %s", topic, errWR.Error()).LogError()
-		}
-	}()
+	wr := c.writer()
 
 	err := wr.WriteMessages(context.Background(), msgs...)
 	if err != nil {
@@ -583,7 +735,7 @@ func (c *Client) sendRetry(ctx context.Context, topic string, msgs []goKafka.Mes
 }
 
 func (c *Client) processRetry(ctx context.Context,
-	wr *goKafka.Writer, topic string, msgs []goKafka.Message) error {
+	wr messageWriter, topic string, msgs []goKafka.Message) error {
 	var errWR error
 	for i := 0; i < c.cfg.Producer.MaxRetry; i++ {
 		errWR = wr.WriteMessages(ctx, msgs...)
@@ -610,6 +762,32 @@ func (c *Client) processRetry(ctx context.Context,
 	return nil
 }
 
+// isTemporaryWriteError reports whether writing of a message may succeed on the next attempt
+func (c *Client) isTemporaryWriteError(err error) bool {
+	var kErr goKafka.Error
+	if errors.As(err, &kErr) && kErr.Temporary() {
+		return true
+	}
+
+	return errors.Is(err, goKafka.LeaderNotAvailable) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		c.isCheckRetry(err)
+}
+
+// writeError returns an error of the i-th message of a batch written by goKafka.Writer
+func writeError(err error, i int) error {
+	if err == nil {
+		return nil
+	}
+
+	var we goKafka.WriteErrors
+	if errors.As(err, &we) && i < len(we) {
+		return we[i]
+	}
+
+	return err
+}
+
 func (c *Client) isCheckRetry(errObj interface{}) bool {
 	switch errWR := errObj.(type) {
 	case goKafka.WriteErrors:
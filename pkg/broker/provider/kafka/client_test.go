@@ -2,7 +2,9 @@ package kafka_test
 
 import (
 	"context"
+	"errors"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/broker/provider/kafka"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/converto"
@@ -191,6 +193,30 @@ func (s *kafkaTestSuite) TestKafkaNotValidTopic() {
 	s.Equal(cerror.KindKafkaUnknown.String(), cerror.ErrKind(err).String())
 }
 
+func (s *kafkaTestSuite) TestKafkaSendMessages() {
+	kc := kafka.NewClient(&kafka.Config{
+		Brokers:                s.brokers,
+		AllowAutoTopicCreation: true,
+	})
+	defer kc.Stop()
+
+	events := []event.BaseEvent{
+		&event.WorkflowData{ID: "test-batch-id-1"},
+		&event.WorkflowData{ID: "test-batch-id-2"},
+	}
+
+	err := kc.SendMessages(bgCtx, s.defTopic, events)
+	s.NoError(err)
+
+	err = kc.SendMessages(bgCtx, "not-valid-topic", events)
+	s.Error(err)
+
+	var be *provider.BatchError
+	s.True(errors.As(err, &be))
+	s.Equal(2, be.Failed())
+	s.Equal(cerror.KindKafkaUnknown.String(), cerror.ErrKind(be.Errs[0]).String())
+}
+
 func (s *kafkaTestSuite) checkErrKind(source cerror.Kind, list []cerror.Kind) bool {
 	for _, item := range list {
 		if item == source {
@@ -0,0 +1,634 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+const defaultFakeBrokerPartitions = 1
+
+// FakeBroker is an in-memory stand-in of a kafka cluster for tests.
+// Clients created by NewClient run the same consumer group, commit and retry logic as with a real cluster,
+// so duplicates caused by rebalances are reproduced deterministically and without docker.
+//
+// Groups follow the eager rebalance protocol. When a member joins or leaves the group or Rebalance is called,
+// generations of all members end and the next generation starts after the members finished their in-flight messages.
+// A member not finished within its Consumer.RebalanceTimeout is left out of the next generation:
+// its commits are rejected and its partitions are consumed again from the committed offsets by other members.
+// The member joins the group again when it is finished.
+//
+// Members of a group sharing generations are the members subscribed to the same topics.
+// Committed offsets are shared by all members of the group, so a member subscribed to other topics
+// continues from the offsets committed before.
+//
+// Topics are created by CreateTopic, on the first write of a client with AllowAutoTopicCreation
+// and when a group subscribes to them by name.
+type FakeBroker struct {
+	mx         sync.Mutex
+	partitions int
+	topics     map[string]*memTopic
+	groups     map[memGroupKey]*memGroup
+	offsets    map[memOffsetsKey]map[int]int64
+	memberSeq  int
+}
+
+// NewFakeBroker creates a broker. Topics created automatically have the given count of partitions.
+func NewFakeBroker(partitions int) *FakeBroker {
+	if partitions <= 0 {
+		partitions = defaultFakeBrokerPartitions
+	}
+
+	return &FakeBroker{
+		partitions: partitions,
+		topics:     make(map[string]*memTopic),
+		groups:     make(map[memGroupKey]*memGroup),
+		offsets:    make(map[memOffsetsKey]map[int]int64),
+	}
+}
+
+// NewClient creates a client connected to the broker
+func (fb *FakeBroker) NewClient(cfg *Config) KClient {
+	c := NewClient(cfg).(*Client)
+
+	c.groupFactory = func(_ context.Context, topics []string) (consumerGroup, error) {
+		return fb.newMember(c.cfg.Consumer.GroupID, topics, c.cfg.Consumer.RebalanceTimeout), nil
+	}
+	c.readerFactory = fb.newReader
+	c.writerFactory = func(_ context.Context) messageWriter {
+		return &memWriter{broker: fb, balancer: c.balancer(), autoCreate: c.cfg.AllowAutoTopicCreation}
+	}
+	c.topicChecker = func(_ context.Context, topic string) (bool, error) {
+		fb.mx.Lock()
+		defer fb.mx.Unlock()
+
+		_, ok := fb.topics[topic]
+
+		return ok, nil
+	}
+	c.topicLister = func(_ context.Context) ([]string, error) {
+		return fb.Topics(), nil
+	}
+
+	return c
+}
+
+// CreateTopic creates the topic with the given count of partitions if it doesn't exist
+func (fb *FakeBroker) CreateTopic(topic string, partitions int) {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	fb.topic(topic, partitions)
+}
+
+// Topics returns sorted names of the topics
+func (fb *FakeBroker) Topics() []string {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	topics := make([]string, 0, len(fb.topics))
+	for t := range fb.topics {
+		topics = append(topics, t)
+	}
+
+	sort.Strings(topics)
+
+	return topics
+}
+
+// Messages returns messages written to the partition of the topic
+func (fb *FakeBroker) Messages(topic string, partition int) []goKafka.Message {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	t, ok := fb.topics[topic]
+	if !ok || partition >= len(t.partitions) {
+		return nil
+	}
+
+	return append([]goKafka.Message(nil), t.partitions[partition]...)
+}
+
+// Committed returns the offset the group consumes the partition of the topic from
+func (fb *FakeBroker) Committed(groupID, topic string, partition int) int64 {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	return fb.committed(groupID, topic)[partition]
+}
+
+// Rebalance ends the current generation of the members of the group consuming the topic.
+// It returns when the next generation is assigned to the members.
+func (fb *FakeBroker) Rebalance(groupID, topic string) {
+	for _, g := range fb.groupsOf(groupID, topic) {
+		g.rebalance(nil)
+	}
+}
+
+// Rebalancing reports whether the group consuming the topic waits for its members to finish the current generation
+func (fb *FakeBroker) Rebalancing(groupID, topic string) bool {
+	groups := fb.groupsOf(groupID, topic)
+
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	for _, g := range groups {
+		if g.rebalancing {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topic returns the topic creating it if needed. fb.mx must be held.
+func (fb *FakeBroker) topic(name string, partitions int) *memTopic {
+	t, ok := fb.topics[name]
+	if !ok {
+		t = &memTopic{partitions: make([][]goKafka.Message, partitions), appended: make(chan struct{})}
+		fb.topics[name] = t
+	}
+
+	return t
+}
+
+// group returns members of the group subscribed to the topics creating it if needed. fb.mx must be held.
+func (fb *FakeBroker) group(groupID string, topics []string) *memGroup {
+	key := memGroupKey{groupID: groupID, topics: strings.Join(topics, ",")}
+
+	g, ok := fb.groups[key]
+	if !ok {
+		g = &memGroup{broker: fb, key: key, topics: append([]string(nil), topics...)}
+		fb.groups[key] = g
+	}
+
+	return g
+}
+
+// groupsOf returns members of the group subscribed to the topic grouped by their subscriptions
+func (fb *FakeBroker) groupsOf(groupID, topic string) []*memGroup {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	var groups []*memGroup
+
+	for key, g := range fb.groups {
+		if key.groupID != groupID {
+			continue
+		}
+
+		for _, t := range g.topics {
+			if t == topic {
+				groups = append(groups, g)
+
+				break
+			}
+		}
+	}
+
+	return groups
+}
+
+// committed returns offsets committed by the group for partitions of the topic. fb.mx must be held.
+func (fb *FakeBroker) committed(groupID, topic string) map[int]int64 {
+	key := memOffsetsKey{groupID: groupID, topic: topic}
+
+	offsets, ok := fb.offsets[key]
+	if !ok {
+		offsets = make(map[int]int64)
+		fb.offsets[key] = offsets
+	}
+
+	return offsets
+}
+
+func (fb *FakeBroker) newMember(groupID string, topics []string, rebalanceTimeout time.Duration) *memMember {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	for _, topic := range topics {
+		fb.topic(topic, fb.partitions)
+	}
+
+	fb.memberSeq++
+
+	return &memMember{
+		group:            fb.group(groupID, topics),
+		id:               fmt.Sprintf("member-%d", fb.memberSeq),
+		rebalanceTimeout: rebalanceTimeout,
+		gens:             make(chan *generation, 1),
+		closed:           make(chan struct{}),
+	}
+}
+
+func (fb *FakeBroker) newReader(_ context.Context, topic string, pa goKafka.PartitionAssignment) (partitionReader, error) {
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	t, ok := fb.topics[topic]
+	if !ok || pa.ID >= len(t.partitions) {
+		return nil, goKafka.UnknownTopicOrPartition
+	}
+
+	return &memReader{broker: fb, topic: t, partition: pa.ID, offset: pa.Offset}, nil
+}
+
+type memTopic struct {
+	partitions [][]goKafka.Message
+	// appended is closed and replaced when messages are appended to wake up readers
+	appended chan struct{}
+}
+
+type memWriter struct {
+	broker     *FakeBroker
+	balancer   goKafka.Balancer
+	autoCreate bool
+}
+
+func (w *memWriter) WriteMessages(_ context.Context, msgs ...goKafka.Message) error {
+	w.broker.mx.Lock()
+	defer w.broker.mx.Unlock()
+
+	var (
+		errs    = make(goKafka.WriteErrors, len(msgs))
+		written = make(map[*memTopic]struct{})
+	)
+
+	for i, msg := range msgs {
+		t, ok := w.broker.topics[msg.Topic]
+		if !ok {
+			if !w.autoCreate {
+				errs[i] = goKafka.UnknownTopicOrPartition
+
+				continue
+			}
+
+			t = w.broker.topic(msg.Topic, w.broker.partitions)
+		}
+
+		partitions := make([]int, len(t.partitions))
+		for p := range partitions {
+			partitions[p] = p
+		}
+
+		msg.Partition = w.balancer.Balance(msg, partitions...)
+		msg.Offset = int64(len(t.partitions[msg.Partition]))
+		msg.Time = time.Now()
+		t.partitions[msg.Partition] = append(t.partitions[msg.Partition], msg)
+		written[t] = struct{}{}
+	}
+
+	for t := range written {
+		close(t.appended)
+		t.appended = make(chan struct{})
+	}
+
+	if errs.Count() == 0 {
+		return nil
+	}
+
+	// a writer of a real cluster doesn't wrap the error of a single message
+	if len(msgs) == 1 {
+		return errs[0]
+	}
+
+	return errs
+}
+
+func (w *memWriter) Close() error {
+	return nil
+}
+
+type memReader struct {
+	broker    *FakeBroker
+	topic     *memTopic
+	partition int
+	offset    int64
+}
+
+func (r *memReader) FetchMessage(ctx context.Context) (goKafka.Message, error) {
+	for {
+		r.broker.mx.Lock()
+		msgs := r.topic.partitions[r.partition]
+		appended := r.topic.appended
+		r.broker.mx.Unlock()
+
+		if r.offset < int64(len(msgs)) {
+			msg := msgs[r.offset]
+			msg.HighWaterMark = int64(len(msgs))
+			r.offset++
+
+			return msg, nil
+		}
+
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return goKafka.Message{}, ctx.Err()
+		}
+	}
+}
+
+func (r *memReader) Close() error {
+	return nil
+}
+
+type memGroupKey struct {
+	groupID string
+	// topics are sorted topics of the subscription joined by a comma
+	topics string
+}
+
+type memOffsetsKey struct {
+	groupID string
+	topic   string
+}
+
+// memGroup is members of a consumer group subscribed to the same topics. Its fields are guarded by FakeBroker.mx.
+type memGroup struct {
+	broker *FakeBroker
+	key    memGroupKey
+	topics []string
+	// rebalanceMx serializes rebalances of the group
+	rebalanceMx sync.Mutex
+	members     []*memMember
+	genID       int32
+	current     []*memGeneration
+	rebalancing bool
+}
+
+// rebalance ends the current generation and assigns partitions of each topic to the members by ranges.
+// If the rebalance is requested by a member, it's skipped when the member already has the next generation.
+func (g *memGroup) rebalance(by *memMember) {
+	g.rebalanceMx.Lock()
+	defer g.rebalanceMx.Unlock()
+
+	fb := g.broker
+
+	fb.mx.Lock()
+	if by != nil && by.joined && len(by.gens) > 0 {
+		fb.mx.Unlock()
+
+		return
+	}
+
+	g.rebalancing = true
+	prev := g.current
+	fb.mx.Unlock()
+
+	lagging := g.drain(prev)
+
+	fb.mx.Lock()
+	defer fb.mx.Unlock()
+
+	for _, m := range lagging {
+		g.leave(m)
+	}
+
+	g.genID++
+	g.current = nil
+
+	for i, m := range g.members {
+		assignments := make(map[string][]goKafka.PartitionAssignment, len(g.topics))
+
+		for _, topic := range g.topics {
+			partitions := len(fb.topic(topic, fb.partitions).partitions)
+			committed := fb.committed(g.key.groupID, topic)
+			from := i * partitions / len(g.members)
+			to := (i + 1) * partitions / len(g.members)
+
+			pas := make([]goKafka.PartitionAssignment, 0, to-from)
+			for p := from; p < to; p++ {
+				pas = append(pas, goKafka.PartitionAssignment{ID: p, Offset: committed[p]})
+			}
+
+			assignments[topic] = pas
+		}
+
+		mg := &memGeneration{member: m, id: g.genID, stopped: make(chan struct{})}
+		mg.ctx, mg.end = context.WithCancel(context.Background())
+		g.current = append(g.current, mg)
+
+		select {
+		case <-m.gens:
+		default:
+		}
+
+		m.gens <- &generation{
+			groupGeneration: mg,
+			id:              g.genID,
+			groupID:         g.key.groupID,
+			memberID:        m.id,
+			assignments:     assignments,
+		}
+	}
+
+	g.rebalancing = false
+}
+
+// drain ends the generations and waits until the members finish them.
+// It returns members not finished within the longest rebalance timeout of the members.
+func (g *memGroup) drain(gens []*memGeneration) []*memMember {
+	var (
+		timeout time.Duration
+		waiting []*memGeneration
+	)
+
+	g.broker.mx.Lock()
+	for _, mg := range gens {
+		mg.end()
+
+		if !mg.received {
+			// the member hasn't got the generation yet, so it just gets the next one
+			select {
+			case <-mg.member.gens:
+			default:
+			}
+
+			continue
+		}
+
+		if mg.member.rebalanceTimeout > timeout {
+			timeout = mg.member.rebalanceTimeout
+		}
+
+		waiting = append(waiting, mg)
+	}
+	g.broker.mx.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var (
+		lagging []*memMember
+		expired bool
+	)
+
+	for _, mg := range waiting {
+		if !expired {
+			select {
+			case <-mg.stopped:
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+
+		select {
+		case <-mg.stopped:
+		default:
+			lagging = append(lagging, mg.member)
+		}
+	}
+
+	return lagging
+}
+
+// leave removes the member from the group. fb.mx must be held.
+func (g *memGroup) leave(m *memMember) {
+	for i := range g.members {
+		if g.members[i] == m {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+
+			break
+		}
+	}
+
+	m.joined = false
+}
+
+// memMember is a membership of a client in a group. It joins the group on the first call of Next.
+type memMember struct {
+	group            *memGroup
+	id               string
+	rebalanceTimeout time.Duration
+	gens             chan *generation
+	closed           chan struct{}
+	joined           bool
+	isClosed         bool
+}
+
+func (m *memMember) Next(ctx context.Context) (*generation, error) {
+	fb := m.group.broker
+
+	fb.mx.Lock()
+	if m.isClosed {
+		fb.mx.Unlock()
+
+		return nil, goKafka.ErrGroupClosed
+	}
+
+	// a member without the next generation joins the group again, as its previous generation has ended by itself
+	needRebalance := !m.joined || (!m.group.rebalancing && len(m.gens) == 0)
+	if !m.joined {
+		m.group.members = append(m.group.members, m)
+		m.joined = true
+	}
+	fb.mx.Unlock()
+
+	if needRebalance {
+		m.group.rebalance(m)
+	}
+
+	select {
+	case gen := <-m.gens:
+		fb.mx.Lock()
+		gen.groupGeneration.(*memGeneration).received = true
+		fb.mx.Unlock()
+
+		return gen, nil
+	case <-m.closed:
+		return nil, goKafka.ErrGroupClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *memMember) Close() error {
+	fb := m.group.broker
+
+	fb.mx.Lock()
+	if m.isClosed {
+		fb.mx.Unlock()
+
+		return nil
+	}
+
+	m.isClosed = true
+	close(m.closed)
+
+	joined := m.joined
+	m.group.leave(m)
+	fb.mx.Unlock()
+
+	if joined {
+		m.group.rebalance(nil)
+	}
+
+	return nil
+}
+
+// memGeneration is a generation of a member. Its fields are guarded by FakeBroker.mx.
+type memGeneration struct {
+	member   *memMember
+	id       int32
+	ctx      context.Context
+	end      context.CancelFunc
+	received bool
+	running  int
+	// stopped is closed when all functions of the generation exit
+	stopped chan struct{}
+}
+
+// Start runs the function. The generation of the member ends when any of its functions exits.
+func (mg *memGeneration) Start(fn func(ctx context.Context)) {
+	fb := mg.member.group.broker
+
+	fb.mx.Lock()
+	mg.running++
+	fb.mx.Unlock()
+
+	go func() {
+		fn(mg.ctx)
+		mg.end()
+
+		fb.mx.Lock()
+		defer fb.mx.Unlock()
+
+		mg.running--
+		if mg.running == 0 {
+			select {
+			case <-mg.stopped:
+			default:
+				close(mg.stopped)
+			}
+		}
+	}()
+}
+
+// CommitOffsets rejects commits of generations replaced by the next one
+func (mg *memGeneration) CommitOffsets(offsets map[string]map[int]int64) error {
+	g := mg.member.group
+
+	g.broker.mx.Lock()
+	defer g.broker.mx.Unlock()
+
+	if mg.id != g.genID {
+		return goKafka.IllegalGeneration
+	}
+
+	for _, topic := range g.topics {
+		committed := g.broker.committed(g.key.groupID, topic)
+
+		for p, offset := range offsets[topic] {
+			committed[p] = offset
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,589 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
+	"kafka-polygon/pkg/broker/provider"
+	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/tracing"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	fakeGroup = "group"
+	fakeTopic = "topic"
+)
+
+// memEventStore is an event store counting claims of each event
+type memEventStore struct {
+	mx     sync.Mutex
+	events map[string]store.EventProcessData
+	claims map[string]int
+}
+
+func newMemEventStore() *memEventStore {
+	return &memEventStore{
+		events: make(map[string]store.EventProcessData),
+		claims: make(map[string]int),
+	}
+}
+
+func (ms *memEventStore) GetEventInfoByID(_ context.Context, id string) (store.EventProcessData, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	return ms.events[id], nil
+}
+
+func (ms *memEventStore) PutEventInfo(_ context.Context, id string, data store.EventProcessData) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.events[id] = data
+
+	return nil
+}
+
+func (ms *memEventStore) ClaimEvent(
+	_ context.Context, id, owner string, ttl time.Duration) (store.EventProcessData, bool, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.claims[id]++
+
+	prev := ms.events[id]
+	if !prev.IsClaimable(time.Now()) {
+		return prev, false, nil
+	}
+
+	ms.events[id] = store.EventProcessData{
+		Status:     store.EventStatusProcessing,
+		LeaseUntil: time.Now().Add(ttl),
+		Owner:      owner,
+	}
+
+	return prev, true, nil
+}
+
+func (ms *memEventStore) ReleaseEvent(_ context.Context, id, owner, status string) (bool, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	if current := ms.events[id]; current.Status != store.EventStatusProcessing || current.Owner != owner {
+		return false, nil
+	}
+
+	ms.events[id] = store.EventProcessData{Status: status}
+
+	return true, nil
+}
+
+func (ms *memEventStore) claimCount(id string) int {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	return ms.claims[id]
+}
+
+// handlerCalls counts calls of a workflow handler by event ID.
+// The handler blocks on the event blockID until release is closed.
+type handlerCalls struct {
+	mx      sync.Mutex
+	calls   map[string]int
+	blockID string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newHandlerCalls(blockID string) *handlerCalls {
+	return &handlerCalls{
+		calls:   make(map[string]int),
+		blockID: blockID,
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (hc *handlerCalls) handler() provider.HandlerWorkflow {
+	return func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) error {
+		hc.mx.Lock()
+		hc.calls[e.GetID()]++
+		block := e.GetID() == hc.blockID && hc.calls[e.GetID()] == 1
+		hc.mx.Unlock()
+
+		if block {
+			close(hc.started)
+			<-hc.release
+		}
+
+		return nil
+	}
+}
+
+func (hc *handlerCalls) get() map[string]int {
+	hc.mx.Lock()
+	defer hc.mx.Unlock()
+
+	res := make(map[string]int, len(hc.calls))
+	for id, cnt := range hc.calls {
+		res[id] = cnt
+	}
+
+	return res
+}
+
+func newFakeProvider(fb *pKafka.FakeBroker, s store.Store, rebalanceTimeout time.Duration) *pKafka.Provider {
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer: pKafka.Consumer{
+			GroupID:          fakeGroup,
+			RebalanceTimeout: rebalanceTimeout,
+		},
+		EventLease: provider.LeaseSettings{
+			WaitInterval: 5 * time.Millisecond,
+		},
+	}
+
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+	p.SetStore(s)
+
+	return p
+}
+
+func publishEvents(t *testing.T, p *pKafka.Provider, count int) []string {
+	t.Helper()
+
+	ids := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("event-%d", i)
+		require.NoError(t, p.Publish(bgCtx, fakeTopic, &event.WorkflowData{ID: id}))
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func committedTotal(fb *pKafka.FakeBroker, partitions int) int64 {
+	var total int64
+	for p := 0; p < partitions; p++ {
+		total += fb.Committed(fakeGroup, fakeTopic, p)
+	}
+
+	return total
+}
+
+func assertHandledOnce(t *testing.T, ids []string, hc *handlerCalls) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		return len(hc.get()) == len(ids)
+	}, 5*time.Second, 5*time.Millisecond)
+
+	calls := hc.get()
+	for _, id := range ids {
+		assert.Equal(t, 1, calls[id], "handler calls of %s", id)
+	}
+}
+
+func TestFakeBrokerConsumerJoinsMidBatch(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(2)
+	s := newMemEventStore()
+	p1 := newFakeProvider(fb, s, time.Minute)
+	p2 := newFakeProvider(fb, s, time.Minute)
+	ids := publishEvents(t, p1, 10)
+	hc := newHandlerCalls(string(fb.Messages(fakeTopic, 0)[0].Key))
+
+	var assigned sync.Map
+
+	p2.SetRebalanceHandler(pKafka.RebalanceFuncs{
+		Assign: func(_ context.Context, a pKafka.Assignment) {
+			assigned.Store(a.GenerationID, a.Partitions)
+		},
+	})
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p1.Sync(ctx, fakeTopic, hc.handler())
+	<-hc.started
+
+	// the second consumer joins while the first one handles a message of the batch
+	p2.Sync(ctx, fakeTopic, hc.handler())
+
+	assert.Eventually(t, func() bool {
+		return fb.Rebalancing(fakeGroup, fakeTopic)
+	}, time.Second, time.Millisecond)
+
+	close(hc.release)
+
+	assertHandledOnce(t, ids, hc)
+
+	assert.Eventually(t, func() bool {
+		return committedTotal(fb, 2) == int64(len(ids))
+	}, time.Second, 5*time.Millisecond)
+
+	partitions, ok := assigned.Load(int32(2))
+	assert.True(t, ok)
+	assert.Equal(t, []int{1}, partitions)
+}
+
+func TestFakeBrokerEvictedConsumerWithoutDuplicates(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	s := newMemEventStore()
+	p1 := newFakeProvider(fb, s, 20*time.Millisecond)
+	p2 := newFakeProvider(fb, s, 20*time.Millisecond)
+	ids := publishEvents(t, p1, 3)
+	hc := newHandlerCalls(ids[0])
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p1.Sync(ctx, fakeTopic, hc.handler())
+	<-hc.started
+
+	// the first consumer doesn't finish the message in the rebalance timeout,
+	// so the message is delivered again to the second one
+	p2.Sync(ctx, fakeTopic, hc.handler())
+
+	assert.Eventually(t, func() bool {
+		return s.claimCount(ids[0]) > 1
+	}, time.Second, time.Millisecond)
+
+	close(hc.release)
+
+	assertHandledOnce(t, ids, hc)
+
+	assert.Eventually(t, func() bool {
+		return committedTotal(fb, 1) == int64(len(ids))
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFakeBrokerEvictedConsumerRedeliversMessage(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	newClient := func() pKafka.KClient {
+		return fb.NewClient(&pKafka.Config{
+			AllowAutoTopicCreation: true,
+			Consumer:               pKafka.Consumer{GroupID: fakeGroup, RebalanceTimeout: 20 * time.Millisecond},
+		})
+	}
+
+	c1, c2 := newClient(), newClient()
+	require.NoError(t, c1.SendMessage(bgCtx, fakeTopic, &event.WorkflowData{ID: "event-0"}))
+
+	var (
+		mx         sync.Mutex
+		deliveries []string
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := pKafka.HandelFn(func(_ context.Context, m *kafka.Message) (event.BaseEvent, error) {
+		mx.Lock()
+		deliveries = append(deliveries, string(m.Key))
+		first := len(deliveries) == 1
+		mx.Unlock()
+
+		if first {
+			close(started)
+			<-release
+		}
+
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	c1.ListenTopic(ctx, fakeTopic, handler)
+	<-started
+
+	c2.ListenTopic(ctx, fakeTopic, handler)
+
+	// without the event store the message is handled by both consumers
+	assert.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+
+		return len(deliveries) == 2
+	}, time.Second, time.Millisecond)
+
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return fb.Committed(fakeGroup, fakeTopic, 0) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, []string{"event-0", "event-0"}, deliveries)
+}
+
+func TestFakeBrokerSendMessages(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(2)
+	fb.CreateTopic(fakeTopic, 1)
+
+	c := fb.NewClient(&pKafka.Config{})
+	events := []event.BaseEvent{&event.WorkflowData{ID: "event-0"}, &event.WorkflowData{ID: "event-1"}}
+
+	require.NoError(t, c.SendMessages(bgCtx, fakeTopic, events))
+
+	msgs := fb.Messages(fakeTopic, 0)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "event-1", string(msgs[1].Key))
+	assert.Equal(t, int64(1), msgs[1].Offset)
+
+	exists, err := c.GetIsTopicExists(bgCtx, "unknown-topic")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	err = c.SendMessages(bgCtx, "unknown-topic", events)
+
+	var be *provider.BatchError
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, 2, be.Failed())
+}
+
+type sdkProvider struct {
+	tp *tracesdk.TracerProvider
+}
+
+func (sp *sdkProvider) Use(_ *tracing.JaegerOptions) {}
+
+func (sp *sdkProvider) HasSdkTracerProvider() bool {
+	return true
+}
+
+func (sp *sdkProvider) GetSdkTracerProvider() *tracesdk.TracerProvider {
+	return sp.tp
+}
+
+func TestFakeBrokerPropagatesHeaders(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	sr := tracetest.NewSpanRecorder()
+	tr := tracing.New(&sdkProvider{tp: tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(sr))})
+
+	producer := newFakeProvider(fb, nil, time.Minute)
+	producer.SetTracing(tr)
+
+	consumer := newFakeProvider(fb, nil, time.Minute)
+	consumer.SetTracing(tr)
+
+	reqCtx := context.WithValue(bgCtx, consts.HeaderXRequestID, "request-id") //nolint:staticcheck
+	require.NoError(t, producer.Publish(reqCtx, fakeTopic, &event.WorkflowData{ID: "event-0"}))
+
+	headers := pKafka.HeadersMap(fb.Messages(fakeTopic, 0)[0].Headers)
+	assert.Equal(t, "request-id", headers[provider.HeaderRequestID])
+	assert.NotEmpty(t, headers["traceparent"])
+
+	handled := make(chan interface{}, 1)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	consumer.Sync(ctx, fakeTopic, provider.HandlerWorkflow(
+		func(hCtx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+			handled <- hCtx.Value(consts.HeaderXRequestID)
+
+			return nil
+		}))
+
+	select {
+	case reqID := <-handled:
+		assert.Equal(t, "request-id", reqID)
+	case <-time.After(time.Second):
+		t.Fatal("message isn't handled")
+	}
+
+	var producerSpan, consumerSpan tracesdk.ReadOnlySpan
+
+	assert.Eventually(t, func() bool {
+		for _, s := range sr.Ended() {
+			switch s.InstrumentationScope().Name {
+			case pKafka.TraceKafkaProducer:
+				producerSpan = s
+			case pKafka.TraceKafkaConsumer:
+				consumerSpan = s
+			}
+		}
+
+		return producerSpan != nil && consumerSpan != nil
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, producerSpan.SpanContext().TraceID(), consumerSpan.Parent().TraceID())
+	assert.Equal(t, producerSpan.SpanContext().SpanID(), consumerSpan.Parent().SpanID())
+	assert.True(t, consumerSpan.Parent().IsRemote())
+}
+
+func TestFakeBrokerCodec(t *testing.T) {
+	t.Parallel()
+
+	reg, err := codec.NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	require.NoError(t, err)
+
+	id, err := reg.Register(bgCtx, codec.Subject(fakeTopic), codec.Schema{Type: codec.SchemaTypeJSON, Schema: "{}"})
+	require.NoError(t, err)
+
+	fb := pKafka.NewFakeBroker(1)
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer:               pKafka.Consumer{GroupID: fakeGroup},
+		Codec:                  codec.NewJSON(reg),
+	}
+
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+
+	require.NoError(t, p.Publish(bgCtx, fakeTopic, &event.WorkflowData{ID: "event-0"}))
+
+	value := fb.Messages(fakeTopic, 0)[0].Value
+	assert.True(t, codec.IsWireFormat(value))
+	assert.Equal(t, byte(id), value[4])
+
+	handled := make(chan string, 1)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p.Sync(ctx, fakeTopic, provider.HandlerWorkflow(
+		func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) error {
+			handled <- e.GetID()
+
+			return nil
+		}))
+
+	select {
+	case id := <-handled:
+		assert.Equal(t, "event-0", id)
+	case <-time.After(time.Second):
+		t.Fatal("message isn't handled")
+	}
+
+	// events failed to encode aren't sent
+	err = p.PublishBatch(bgCtx, "unknown-subject", []event.BaseEvent{&event.WorkflowData{ID: "event-1"}})
+
+	var be *provider.BatchError
+	require.True(t, errors.As(err, &be))
+	assert.Equal(t, 1, be.Failed())
+}
+
+func TestFakeBrokerRouter(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer:               pKafka.Consumer{GroupID: fakeGroup},
+	}
+
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+
+	require.NoError(t, p.Publish(bgCtx, fakeTopic, &event.WorkflowData{ID: "workflow-0"}))
+	require.NoError(t, p.Publish(bgCtx, fakeTopic, &event.NotificationData{ID: "notification-0"}))
+
+	handled := make(chan string, 2)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p.Sync(ctx, fakeTopic, provider.NewRouter().
+		Handle(provider.HandlerWorkflow(func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) error {
+			handled <- "workflow:" + e.GetID()
+
+			return nil
+		})).
+		Handle(provider.HandlerNotification(func(_ context.Context, e event.NotificationEvent, _ store.EventProcessData) error {
+			handled <- "notification:" + e.GetID()
+
+			return nil
+		})))
+
+	for _, exp := range []string{"workflow:workflow-0", "notification:notification-0"} {
+		select {
+		case got := <-handled:
+			assert.Equal(t, exp, got)
+		case <-time.After(time.Second):
+			t.Fatalf("message %s isn't handled", exp)
+		}
+	}
+}
+
+func TestFakeBrokerMetrics(t *testing.T) {
+	t.Parallel()
+
+	const topic = "metrics-topic"
+
+	fb := pKafka.NewFakeBroker(1)
+	p := newFakeProvider(fb, newMemEventStore(), 0)
+
+	// metrics are global, so only their changes are checked
+	published := metrics.MessagesPublished.Value(pKafka.BrokerKafkaProvider, topic)
+	consumed := metrics.MessagesConsumed.Value(pKafka.BrokerKafkaProvider, topic)
+	observed := metrics.HandlerDuration.Count(pKafka.BrokerKafkaProvider, topic)
+	failed := metrics.HandlerErrors.Value(pKafka.BrokerKafkaProvider, topic)
+	skipped := metrics.DuplicatesSkipped.Value(topic)
+
+	// the last event is a duplicate of the first one
+	for _, id := range []string{"metrics-0", "metrics-1", "metrics-0"} {
+		require.NoError(t, p.Publish(bgCtx, topic, &event.WorkflowData{ID: id}))
+	}
+
+	assert.Equal(t, published+3, metrics.MessagesPublished.Value(pKafka.BrokerKafkaProvider, topic))
+
+	hc := newHandlerCalls("metrics-1")
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p.Sync(ctx, topic, hc.handler())
+
+	select {
+	case <-hc.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler isn't called")
+	}
+
+	// the first message is committed, the blocked one and the duplicate are behind
+	assert.Equal(t, float64(2), metrics.ConsumerLag.Value(fakeGroup, topic, "0"))
+
+	close(hc.release)
+
+	assert.Eventually(t, func() bool {
+		return metrics.MessagesConsumed.Value(pKafka.BrokerKafkaProvider, topic) == consumed+3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		return metrics.ConsumerLag.Value(fakeGroup, topic, "0") == 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, observed+3, metrics.HandlerDuration.Count(pKafka.BrokerKafkaProvider, topic))
+	assert.Equal(t, failed, metrics.HandlerErrors.Value(pKafka.BrokerKafkaProvider, topic))
+	assert.Equal(t, skipped+1, metrics.DuplicatesSkipped.Value(topic))
+	assert.Equal(t, map[string]int{"metrics-0": 1, "metrics-1": 1}, hc.get())
+}
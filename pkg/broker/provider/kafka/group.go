@@ -0,0 +1,510 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/log/logger"
+	"sync"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+// consumerGroup is a membership of the client in a consumer group
+type consumerGroup interface {
+	// Next blocks until the next generation of the group is joined
+	Next(ctx context.Context) (*generation, error)
+	Close() error
+}
+
+// groupGeneration runs functions bound to a generation and commits offsets in it.
+// A generation ends on a rebalance or when any function started in it exits.
+type groupGeneration interface {
+	Start(fn func(ctx context.Context))
+	CommitOffsets(offsets map[string]map[int]int64) error
+}
+
+type generation struct {
+	groupGeneration
+	id          int32
+	groupID     string
+	memberID    string
+	assignments map[string][]goKafka.PartitionAssignment
+}
+
+type partitionReader interface {
+	FetchMessage(ctx context.Context) (goKafka.Message, error)
+	Close() error
+}
+
+type kafkaConsumerGroup struct {
+	cg *goKafka.ConsumerGroup
+}
+
+func (g *kafkaConsumerGroup) Next(ctx context.Context) (*generation, error) {
+	gen, err := g.cg.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &generation{
+		groupGeneration: gen,
+		id:              gen.ID,
+		groupID:         gen.GroupID,
+		memberID:        gen.MemberID,
+		assignments:     gen.Assignments,
+	}, nil
+}
+
+func (g *kafkaConsumerGroup) Close() error {
+	return g.cg.Close()
+}
+
+// listenGroup joins the consumer group with the topics of the subscription and handles messages of the partitions
+// assigned to the client generation by generation until the context is canceled, the client is stopped or a message fails.
+// When topics matching the pattern of the subscription change, the group is joined again with the new topics.
+func (c *Client) listenGroup(ctx context.Context, sub Subscription, handler MessageHandler) error {
+	s, err := c.newSubscriber(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	topics, err := s.topics(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	stopCtx, cancel := withCancelOn(ctx, c.stopCh)
+	defer cancel()
+
+	for stopCtx.Err() == nil {
+		if len(topics) == 0 {
+			log.DebugF(ctx, "no topics of subscription %s. wait for them", sub)
+
+			s.watch(stopCtx, c, topics, func(next []string) {
+				topics = next
+			})
+
+			continue
+		}
+
+		next, err := c.listenTopics(ctx, s, topics, handler)
+		if err != nil || next == nil {
+			return err
+		}
+
+		topics = next
+	}
+
+	return nil
+}
+
+// listenTopics is a membership of the client in the consumer group with the topics.
+// It returns new topics of the subscription if the group is left because topics matching its pattern changed.
+func (c *Client) listenTopics(ctx context.Context, s *subscriber, topics []string, handler MessageHandler) ([]string, error) {
+	group, err := c.groupFactory(ctx, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	var closeOnce sync.Once
+
+	closeGroup := func() {
+		closeOnce.Do(func() {
+			log.DebugF(ctx, "consumer group for topics %v stopped and close", topics)
+
+			if err := group.Close(); err != nil {
+				_ = cerror.NewF(ctx,
+					cerror.KafkaToKind(err),
+					"[kafka] listenGroup group.Close error. %s", err.Error()).
+					LogError()
+			}
+		})
+	}
+
+	defer closeGroup()
+
+	var (
+		changedMx sync.Mutex
+		changed   []string
+	)
+
+	// the group is closed to be joined again with the new topics
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	watchDone := make(chan struct{})
+
+	go func() {
+		defer close(watchDone)
+
+		s.watch(watchCtx, c, topics, func(next []string) {
+			changedMx.Lock()
+			changed = next
+			changedMx.Unlock()
+
+			closeGroup()
+		})
+	}()
+
+	defer func() {
+		cancelWatch()
+		<-watchDone
+	}()
+
+	log.DebugF(ctx, "start listening kafka topics %v", topics)
+
+	// joining the next generation is interrupted by Shutdown
+	joinCtx, cancelJoin := withCancelOn(ctx, c.stopCh)
+	defer cancelJoin()
+
+	for !c.stopped() {
+		gen, err := group.Next(joinCtx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, goKafka.ErrGroupClosed) {
+				log.DebugF(ctx, "failed to join consumer group for topics: %v. %s", topics, err.Error())
+
+				break
+			}
+
+			// the group joins the next generation by itself after a backoff
+			_ = cerror.NewF(ctx,
+				cerror.KafkaToKind(err),
+				"failed to join consumer group for topics: %v. %s", topics, err.Error()).
+				LogError()
+
+			continue
+		}
+
+		if err := c.runGeneration(ctx, gen, topics, handler); err != nil {
+			return nil, err
+		}
+	}
+
+	changedMx.Lock()
+	defer changedMx.Unlock()
+
+	return changed, nil
+}
+
+// runGeneration handles messages of the partitions of the topics assigned to the client in the generation.
+// It returns when the generation ends: on a rebalance, on stop or on a failed message.
+// The partitions are reported as revoked after their in-flight messages are finished and committed.
+// On stop the generation is kept until all partitions commit their in-flight messages,
+// as a generation ends when any of its functions exits and commits of an ended generation are rejected.
+func (c *Client) runGeneration(ctx context.Context, gen *generation, topics []string, handler MessageHandler) error {
+	for _, topic := range topics {
+		a := gen.assignment(topic)
+
+		log.InfoF(ctx, "kafka group %s generation %d. member %s is assigned partitions %v of topic %s",
+			a.GroupID, a.GenerationID, a.MemberID, a.Partitions, a.Topic)
+
+		if c.rebalance != nil {
+			c.rebalance.OnAssign(ctx, a)
+		}
+	}
+
+	var (
+		wg         sync.WaitGroup
+		partitions sync.WaitGroup
+		errMx      sync.Mutex
+		genErr     error
+	)
+
+	drained := make(chan struct{})
+
+	// waitDrained blocks a stopped client until all partitions are drained or the generation ends
+	waitDrained := func(genCtx context.Context) {
+		select {
+		case <-drained:
+		case <-genCtx.Done():
+		}
+	}
+
+	// the generation must end when the listener is canceled, even if no partitions are assigned
+	wg.Add(1)
+	gen.Start(func(genCtx context.Context) {
+		defer wg.Done()
+
+		select {
+		case <-genCtx.Done():
+		case <-ctx.Done():
+		case <-c.stopCh:
+			waitDrained(genCtx)
+		}
+	})
+
+	for _, topic := range topics {
+		topic := topic
+
+		for _, pa := range gen.assignments[topic] {
+			pa := pa
+
+			wg.Add(1)
+			partitions.Add(1)
+			gen.Start(func(genCtx context.Context) {
+				defer wg.Done()
+
+				err := c.listenPartition(ctx, genCtx, gen, topic, pa, handler)
+
+				partitions.Done()
+
+				if err == nil {
+					if c.stopped() {
+						waitDrained(genCtx)
+					}
+
+					return
+				}
+
+				errMx.Lock()
+				defer errMx.Unlock()
+
+				if genErr == nil {
+					genErr = err
+				}
+			})
+		}
+	}
+
+	go func() {
+		partitions.Wait()
+		close(drained)
+	}()
+
+	wg.Wait()
+
+	for _, topic := range topics {
+		a := gen.assignment(topic)
+
+		log.InfoF(ctx, "kafka group %s generation %d. member %s is revoked partitions %v of topic %s",
+			a.GroupID, a.GenerationID, a.MemberID, a.Partitions, a.Topic)
+
+		for _, p := range a.Partitions {
+			metrics.DeleteConsumerLag(a.GroupID, a.Topic, p)
+		}
+
+		if c.rebalance != nil {
+			c.rebalance.OnRevoke(ctx, a)
+		}
+	}
+
+	return genErr
+}
+
+// listenPartition handles messages of the partition until the generation ends.
+// A message fetched before the end of the generation is finished and committed,
+// so it isn't handled again by the next owner of the partition.
+// Messages fetched after the end are left to the next owner.
+func (c *Client) listenPartition(ctx, genCtx context.Context, gen *generation,
+	topic string, pa goKafka.PartitionAssignment, handler MessageHandler) error {
+	reader, err := c.readerFactory(ctx, topic, pa)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		log.DebugF(ctx, "reader for topic %s partition %d stopped and close", topic, pa.ID)
+
+		if err := reader.Close(); err != nil {
+			_ = cerror.NewF(ctx,
+				cerror.KafkaToKind(err),
+				"[kafka] listenPartition reader.Close error. %s", err.Error()).
+				LogError()
+		}
+	}()
+
+	// fetching is interrupted when the generation ends or the client is stopped, handlers get ctx
+	fetchCtx, cancel := withCancelOn(ctx, genCtx.Done(), c.stopCh)
+	defer cancel()
+
+	if c.cfg.Consumer.Workers > 1 {
+		return c.consumeByKeys(ctx, genCtx, fetchCtx, gen, topic, reader, handler)
+	}
+
+	for !c.stopped() {
+		msg, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				log.DebugF(ctx,
+					"stop fetching messages from kafka for topic: %v. partition: %d. %s", topic, pa.ID, err.Error())
+
+				return nil
+			}
+
+			return cerror.NewF(
+				ctx,
+				cerror.KafkaToKind(err),
+				"failed fetch message from kafka for topic: %v. %s", topic, err.Error()).
+				LogError()
+		}
+
+		if genCtx.Err() != nil || c.stopped() {
+			log.DebugF(ctx,
+				"partition revoked or client stopped. message is left to the next owner. "+
+					"topic: %s. partition: %d. offset: %d. key: %s.",
+				msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+			return nil
+		}
+
+		log.DebugF(ctx,
+			"consume message from kafka topic: %s. partition: %d. offset: %d. key: %s.",
+			msg.Topic,
+			msg.Partition,
+			msg.Offset,
+			string(msg.Key))
+
+		if err := waitRetryDelay(fetchCtx, &msg); err != nil {
+			log.DebugF(ctx, "retry delay of message for topic: %v interrupted. %s", msg.Topic, err.Error())
+
+			return nil
+		}
+
+		c.inflight.add(&msg)
+
+		e, handled, err := c.handleResumed(ctx, fetchCtx, &msg, handler)
+		if err != nil || !handled {
+			c.inflight.done(&msg)
+
+			return err
+		}
+
+		err = c.commitMessage(ctx, genCtx, gen, &msg, e, msg.Offset+1)
+
+		c.inflight.done(&msg)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleMessage calls the handler. A returned error means the message must not be committed.
+func (c *Client) handleMessage(ctx context.Context, msg *goKafka.Message, handler MessageHandler) (event.BaseEvent, error) {
+	e, err := handler.Handle(ctx, msg)
+	if err == nil {
+		return e, nil
+	}
+
+	if !c.healthy(ctx) {
+		return e, errUnhealthy
+	}
+
+	routed, rErr := c.routeFailedMessage(ctx, msg, err)
+	if rErr != nil {
+		return e, rErr
+	}
+
+	if routed {
+		return e, nil
+	}
+
+	errCnt := c.incErrCnt()
+	if !c.cfg.Consumer.CommitOnError || c.cfg.CommitOnErrorMessagesCount < errCnt {
+		return e, cerror.NewF(
+			ctx,
+			cerror.KafkaToKind(err),
+			"failed process message in handler for topic: %v. key = %s. %s",
+			msg.Topic, string(msg.Key), err.Error()).
+			LogError()
+	}
+
+	log.DebugF(ctx,
+		"message was processed with error and skipped. total count of skipped messages: %v. allowed count: %v. key = %s",
+		errCnt,
+		c.cfg.CommitOnErrorMessagesCount,
+		string(msg.Key))
+
+	return e, nil
+}
+
+// commitMessage synchronously commits the offset reached after the message is handled.
+// A commit rejected because the generation has ended isn't an error:
+// the message is consumed again by the next owner and skipped as a duplicate by the event store.
+func (c *Client) commitMessage(ctx, genCtx context.Context, gen *generation,
+	msg *goKafka.Message, e event.BaseEvent, offset int64) error {
+	ctxWithValues := ctx
+	if e != nil {
+		ctxWithValues = context.WithValue(ctx, consts.HeaderXRequestID, e.GetHeader().RequestID) //nolint:staticcheck
+	}
+
+	err := gen.CommitOffsets(map[string]map[int]int64{msg.Topic: {msg.Partition: offset}})
+	if err == nil {
+		metrics.SetConsumerLag(gen.groupID, msg.Topic, msg.Partition, msg.HighWaterMark, offset)
+
+		return nil
+	}
+
+	metrics.CommitFailures.Inc(BrokerKafkaProvider, msg.Topic)
+
+	if genCtx.Err() != nil {
+		_ = cerror.NewF(
+			ctxWithValues,
+			cerror.KafkaToKind(err),
+			"failed to commit message of revoked partition. topic: %s. partition %d. offset: %d. key: %s. %s",
+			msg.Topic, msg.Partition, msg.Offset, string(msg.Key), err.Error()).
+			LogWarn()
+
+		return nil
+	}
+
+	return cerror.NewF(
+		ctxWithValues,
+		cerror.KafkaToKind(err),
+		"failed to commit message. topic: %s. partition %d. offset: %d. key: %s. value: %s. %s",
+		msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err.Error()).
+		LogError()
+}
+
+func (c *Client) newConsumerGroup(ctx context.Context, topics []string) (consumerGroup, error) {
+	var l goKafka.Logger
+	if c.cfg.LoggerEnabled {
+		l = &kafkaLogger{ctx: ctx, level: logger.LevelTrace}
+	}
+
+	cg, err := goKafka.NewConsumerGroup(goKafka.ConsumerGroupConfig{
+		ID:                     c.cfg.Consumer.GroupID,
+		Brokers:                c.cfg.Brokers,
+		Dialer:                 c.newDialer(ctx),
+		Topics:                 topics,
+		HeartbeatInterval:      c.cfg.Consumer.HeartbeatInterval,
+		PartitionWatchInterval: c.cfg.Consumer.PartitionWatchInterval,
+		SessionTimeout:         c.cfg.Consumer.SessionTimeout,
+		RebalanceTimeout:       c.cfg.Consumer.RebalanceTimeout,
+		JoinGroupBackoff:       c.cfg.Consumer.JoinGroupBackoff,
+		RetentionTime:          oneDayTime,
+		StartOffset:            goKafka.FirstOffset,
+		Logger:                 l,
+		ErrorLogger:            &kafkaLogger{ctx: ctx, level: logger.LevelError},
+	})
+	if err != nil {
+		return nil, cerror.NewF(ctx,
+			cerror.KafkaToKind(err),
+			"[kafka] newConsumerGroup error for topics: %v. %s", topics, err.Error()).
+			LogError()
+	}
+
+	return &kafkaConsumerGroup{cg: cg}, nil
+}
+
+func (c *Client) newPartitionReader(
+	ctx context.Context, topic string, pa goKafka.PartitionAssignment) (partitionReader, error) {
+	reader := c.newReader(ctx, topic, pa.ID)
+
+	if err := reader.SetOffset(pa.Offset); err != nil {
+		_ = reader.Close()
+
+		return nil, cerror.NewF(ctx,
+			cerror.KafkaToKind(err),
+			"[kafka] newPartitionReader reader.SetOffset error. topic: %s. partition: %d. offset: %d. %s",
+			topic, pa.ID, pa.Offset, err.Error()).
+			LogError()
+	}
+
+	return reader, nil
+}
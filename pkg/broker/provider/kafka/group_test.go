@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"sync"
+	"testing"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+	"github.com/tj/assert"
+)
+
+var errRebalanceInProgress = errors.New("rebalance in progress")
+
+// fakeCluster is a single partition topic with a group coordinator that records the history of the group
+type fakeCluster struct {
+	mx        sync.Mutex
+	topic     string
+	msgs      []goKafka.Message
+	committed int64
+	history   []string
+	gens      chan *generation
+	// rejectEnded makes commits of ended generations fail as on a real coordinator after the rebalance
+	rejectEnded bool
+}
+
+func newFakeCluster(topic string, keys ...string) *fakeCluster {
+	fc := &fakeCluster{topic: topic, gens: make(chan *generation, 1)}
+
+	for i, k := range keys {
+		fc.msgs = append(fc.msgs, goKafka.Message{Topic: topic, Offset: int64(i), Key: []byte(k)})
+	}
+
+	return fc
+}
+
+func (fc *fakeCluster) record(format string, args ...interface{}) {
+	fc.mx.Lock()
+	defer fc.mx.Unlock()
+
+	fc.history = append(fc.history, fmt.Sprintf(format, args...))
+}
+
+func (fc *fakeCluster) getHistory() []string {
+	fc.mx.Lock()
+	defer fc.mx.Unlock()
+
+	return append([]string(nil), fc.history...)
+}
+
+// join starts a generation assigning the partition from the committed offset
+func (fc *fakeCluster) join(id int32) *fakeGeneration {
+	fc.mx.Lock()
+	offset := fc.committed
+	fc.mx.Unlock()
+
+	fg := &fakeGeneration{cluster: fc}
+	fg.ctx, fg.end = context.WithCancel(context.Background())
+
+	fc.gens <- &generation{
+		groupGeneration: fg,
+		id:              id,
+		groupID:         "group",
+		memberID:        "member",
+		assignments:     map[string][]goKafka.PartitionAssignment{fc.topic: {{ID: 0, Offset: offset}}},
+	}
+
+	return fg
+}
+
+func (fc *fakeCluster) newGroup(_ context.Context, _ []string) (consumerGroup, error) {
+	return fc, nil
+}
+
+func (fc *fakeCluster) newReader(_ context.Context, _ string, pa goKafka.PartitionAssignment) (partitionReader, error) {
+	return &fakeReader{cluster: fc, pos: pa.Offset}, nil
+}
+
+func (fc *fakeCluster) Next(ctx context.Context) (*generation, error) {
+	select {
+	case gen := <-fc.gens:
+		return gen, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (fc *fakeCluster) Close() error {
+	return nil
+}
+
+type fakeGeneration struct {
+	cluster *fakeCluster
+	ctx     context.Context
+	end     context.CancelFunc
+}
+
+func (fg *fakeGeneration) Start(fn func(ctx context.Context)) {
+	go func() {
+		fn(fg.ctx)
+		fg.end()
+	}()
+}
+
+func (fg *fakeGeneration) CommitOffsets(offsets map[string]map[int]int64) error {
+	if fg.cluster.rejectEnded && fg.ctx.Err() != nil {
+		return errRebalanceInProgress
+	}
+
+	fg.cluster.mx.Lock()
+	fg.cluster.committed = offsets[fg.cluster.topic][0]
+	fg.cluster.mx.Unlock()
+
+	fg.cluster.record("commit %d", offsets[fg.cluster.topic][0])
+
+	return nil
+}
+
+type fakeReader struct {
+	cluster *fakeCluster
+	pos     int64
+}
+
+func (fr *fakeReader) FetchMessage(ctx context.Context) (goKafka.Message, error) {
+	if int(fr.pos) < len(fr.cluster.msgs) {
+		msg := fr.cluster.msgs[fr.pos]
+		fr.pos++
+
+		return msg, nil
+	}
+
+	<-ctx.Done()
+
+	return goKafka.Message{}, ctx.Err()
+}
+
+func (fr *fakeReader) Close() error {
+	return nil
+}
+
+func newFakeClient(fc *fakeCluster) *Client {
+	c := NewClient(&Config{Consumer: Consumer{GroupID: "group"}}).(*Client)
+	c.groupFactory = fc.newGroup
+	c.readerFactory = fc.newReader
+	c.SetRebalanceHandler(RebalanceFuncs{
+		Assign: func(_ context.Context, a Assignment) {
+			fc.record("assign %d %v", a.GenerationID, a.Partitions)
+		},
+		Revoke: func(_ context.Context, a Assignment) {
+			fc.record("revoke %d %v", a.GenerationID, a.Partitions)
+		},
+	})
+
+	return c
+}
+
+func TestRevokeFinishesInFlightMessage(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster("topic", "k0", "k1")
+	c := newFakeClient(fc)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := HandelFn(func(_ context.Context, m *goKafka.Message) (event.BaseEvent, error) {
+		fc.record("handle %s", string(m.Key))
+
+		if m.Offset == 0 {
+			close(started)
+			<-release
+		}
+
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := c.ListenTopic(ctx, "topic", handler)
+
+	gen1 := fc.join(1)
+
+	// the partition is revoked while the first message is handled
+	<-started
+	gen1.end()
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return len(fc.getHistory()) == 4
+	}, time.Second, 5*time.Millisecond)
+
+	// the next owner continues from the committed offset
+	fc.join(2)
+
+	assert.Eventually(t, func() bool {
+		return len(fc.getHistory()) == 7
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	c.wg.Wait()
+
+	assert.Equal(t, []string{
+		"assign 1 [0]",
+		"handle k0",
+		"commit 1",
+		"revoke 1 [0]",
+		"assign 2 [0]",
+		"handle k1",
+		"commit 2",
+		"revoke 2 [0]",
+	}, fc.getHistory())
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected listener error: %s", err)
+	default:
+	}
+}
+
+func TestCommitRejectedAfterRevokeIsNotError(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster("topic", "k0")
+	fc.rejectEnded = true
+	c := newFakeClient(fc)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := HandelFn(func(_ context.Context, m *goKafka.Message) (event.BaseEvent, error) {
+		fc.record("handle %s", string(m.Key))
+
+		if len(fc.getHistory()) == 2 {
+			close(started)
+			<-release
+		}
+
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := c.ListenTopic(ctx, "topic", handler)
+
+	gen1 := fc.join(1)
+
+	<-started
+	gen1.end()
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return len(fc.getHistory()) == 3
+	}, time.Second, 5*time.Millisecond)
+
+	// the listener isn't stopped and the message is consumed again by the next generation
+	fc.join(2)
+
+	assert.Eventually(t, func() bool {
+		return len(fc.getHistory()) == 6
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	c.wg.Wait()
+
+	assert.Equal(t, []string{
+		"assign 1 [0]",
+		"handle k0",
+		"revoke 1 [0]",
+		"assign 2 [0]",
+		"handle k0",
+		"commit 1",
+		"revoke 2 [0]",
+	}, fc.getHistory())
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected listener error: %s", err)
+	default:
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/log"
@@ -175,16 +176,17 @@ func (c *OpenClient) GetIsTopicExists(ctx context.Context, topic string) (bool,
 }
 
 func (c *OpenClient) SendMessage(ctx context.Context, topic string, e event.BaseEvent) error {
-	key := e.GetID()
+	key := provider.EventKey(e)
 	if c.cfg.UseKeyDoubleQuote {
-		key = fmt.Sprintf("%q", e.GetID())
+		key = fmt.Sprintf("%q", key)
 	}
 
 	return c.SendRetry(ctx, topic, []goKafka.Message{
 		{
-			Topic: topic,
-			Key:   []byte(key),
-			Value: e.ToByte(),
+			Topic:   topic,
+			Key:     []byte(key),
+			Value:   e.ToByte(),
+			Headers: recordHeaders(ctx, e),
 		},
 	}, true)
 }
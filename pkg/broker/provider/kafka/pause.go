@@ -0,0 +1,191 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/log"
+	"sync"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+// errUnhealthy is returned by handleMessage when the handler fails while Config.HealthCheck fails too.
+// The message isn't routed or committed, it is handled again when consuming is resumed.
+var errUnhealthy = errors.New("health check failed")
+
+// pauses are topics paused by Pause and a pause of all topics while Config.HealthCheck fails.
+// Both must be lifted to resume a topic, so a recovered health check doesn't resume a topic paused explicitly.
+type pauses struct {
+	mx        sync.Mutex
+	topics    map[string]struct{}
+	unhealthy bool
+	// changed is closed and replaced on every change
+	changed chan struct{}
+}
+
+func newPauses() *pauses {
+	return &pauses{
+		topics:  make(map[string]struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+func (ps *pauses) set(topic string, paused bool) {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+
+	if _, ok := ps.topics[topic]; ok == paused {
+		return
+	}
+
+	if paused {
+		ps.topics[topic] = struct{}{}
+	} else {
+		delete(ps.topics, topic)
+	}
+
+	ps.notify()
+}
+
+// setUnhealthy returns false if the state isn't changed
+func (ps *pauses) setUnhealthy(unhealthy bool) bool {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+
+	if ps.unhealthy == unhealthy {
+		return false
+	}
+
+	ps.unhealthy = unhealthy
+	ps.notify()
+
+	return true
+}
+
+func (ps *pauses) notify() {
+	close(ps.changed)
+	ps.changed = make(chan struct{})
+}
+
+func (ps *pauses) paused(topic string) bool {
+	return ps.wait(topic) != nil
+}
+
+// wait returns nil if the topic isn't paused, otherwise a channel closed when pauses change
+func (ps *pauses) wait(topic string) <-chan struct{} {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+
+	if _, ok := ps.topics[topic]; !ok && !ps.unhealthy {
+		return nil
+	}
+
+	return ps.changed
+}
+
+// Pause stops handling messages of the topic. The client stays in the consumer group and keeps its partitions,
+// messages being handled are finished and committed. A fetched message waits for Resume.
+func (c *Client) Pause(topic string) {
+	c.pauses.set(topic, true)
+}
+
+// Resume continues handling messages of the topic paused by Pause
+func (c *Client) Resume(topic string) {
+	c.pauses.set(topic, false)
+}
+
+// Paused reports whether messages of the topic aren't handled, by Pause or because Config.HealthCheck fails
+func (c *Client) Paused(topic string) bool {
+	return c.pauses.paused(topic)
+}
+
+// waitResumed blocks while the topic is paused. It returns an error if ctx is done before.
+func (c *Client) waitResumed(ctx context.Context, topic string) error {
+	for {
+		changed := c.pauses.wait(topic)
+		if changed == nil {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// healthy calls Config.HealthCheck. If it fails, all topics are paused until the check passes again.
+func (c *Client) healthy(ctx context.Context) bool {
+	if c.cfg.HealthCheck == nil {
+		return true
+	}
+
+	err := c.cfg.HealthCheck(ctx)
+	if err == nil {
+		return true
+	}
+
+	if c.pauses.setUnhealthy(true) {
+		log.InfoF(ctx, "[kafka] health check failed, consuming is paused. %s", err.Error())
+
+		go c.watchHealth(ctx)
+	}
+
+	return false
+}
+
+// watchHealth calls Config.HealthCheck every Config.HealthCheckInterval until it passes or the client is stopped
+func (c *Client) watchHealth(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.stopCh:
+			return
+		}
+
+		// checks aren't bound to ctx of the listener which paused consuming, it may be done before the recovery
+		checkCtx, cancel := context.WithTimeout(context.Background(), c.cfg.HealthCheckInterval)
+		err := c.cfg.HealthCheck(checkCtx)
+
+		cancel()
+
+		if err != nil {
+			log.DebugF(ctx, "[kafka] health check failed, consuming is still paused. %s", err.Error())
+
+			continue
+		}
+
+		if c.pauses.setUnhealthy(false) {
+			log.InfoF(ctx, "[kafka] health check passed, consuming is resumed")
+		}
+
+		return
+	}
+}
+
+// handleResumed waits while the topic of the message is paused and handles it. If the handler fails
+// while Config.HealthCheck fails too, the message is handled again when consuming is resumed.
+// It returns false if waitCtx is done while the topic is paused, then the message is left to the next owner.
+func (c *Client) handleResumed(ctx, waitCtx context.Context, msg *goKafka.Message,
+	handler MessageHandler) (event.BaseEvent, bool, error) {
+	for {
+		if err := c.waitResumed(waitCtx, msg.Topic); err != nil {
+			log.DebugF(ctx,
+				"topic paused, message is left to the next owner. topic: %s. partition: %d. offset: %d. %s",
+				msg.Topic, msg.Partition, msg.Offset, err.Error())
+
+			return nil, false, nil
+		}
+
+		e, err := c.handleMessage(ctx, msg, handler)
+		if !errors.Is(err, errUnhealthy) {
+			return e, true, err
+		}
+	}
+}
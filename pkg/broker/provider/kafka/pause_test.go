@@ -0,0 +1,118 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestPauseResume(t *testing.T) {
+	t.Parallel()
+
+	const topic = "pause-resume"
+
+	fb := pKafka.NewFakeBroker(1)
+	p := newFakeProvider(fb, newMemEventStore(), 0)
+	publishTo(t, p, topic, "pause-0")
+
+	p.Pause(bgCtx, topic)
+	assert.True(t, p.Paused(topic))
+
+	hc := newHandlerCalls("")
+	p.Sync(bgCtx, topic, hc.handler())
+
+	defer p.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, hc.get())
+	assert.Equal(t, int64(0), fb.Committed(fakeGroup, topic, 0))
+
+	p.Resume(bgCtx, topic)
+	assert.False(t, p.Paused(topic))
+
+	assert.Eventually(t, func() bool {
+		return fb.Committed(fakeGroup, topic, 0) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]int{"pause-0": 1}, hc.get())
+}
+
+func TestPauseOnFailedHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	const topic = "pause-health"
+
+	var healthy atomic.Bool
+
+	healthy.Store(true)
+
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer:               pKafka.Consumer{GroupID: fakeGroup},
+		EventLease:             provider.LeaseSettings{WaitInterval: 5 * time.Millisecond},
+		HealthCheck: func(_ context.Context) error {
+			if healthy.Load() {
+				return nil
+			}
+
+			return errors.New("store is down")
+		},
+		HealthCheckInterval: 5 * time.Millisecond,
+	}
+
+	fb := pKafka.NewFakeBroker(1)
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+	p.SetStore(newMemEventStore())
+	publishTo(t, p, topic, "health-0")
+
+	var (
+		mx    sync.Mutex
+		calls int
+	)
+
+	p.Sync(bgCtx, topic, provider.HandlerWorkflow(
+		func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+			mx.Lock()
+			defer mx.Unlock()
+
+			calls++
+			if calls == 1 {
+				healthy.Store(false)
+
+				return errors.New("connection refused")
+			}
+
+			return nil
+		}))
+
+	defer p.Stop()
+
+	// the failed message isn't committed or skipped while the health check fails
+	assert.Eventually(t, func() bool {
+		return p.Paused(topic)
+	}, 5*time.Second, 5*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(0), fb.Committed(fakeGroup, topic, 0))
+
+	healthy.Store(true)
+
+	assert.Eventually(t, func() bool {
+		return fb.Committed(fakeGroup, topic, 0) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.False(t, p.Paused(topic))
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,226 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"sync"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+// offsetTracker tracks messages of a partition handled out of order.
+// The commit offset follows the last message up to which all fetched messages are handled,
+// so a message is never committed before an earlier one is finished.
+type offsetTracker struct {
+	mx      sync.Mutex
+	pending []int64
+	handled map[int64]struct{}
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{handled: make(map[int64]struct{})}
+}
+
+// fetched registers the offset of a fetched message. Offsets must be registered in the fetch order.
+func (t *offsetTracker) fetched(offset int64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.pending = append(t.pending, offset)
+}
+
+// handle marks the offset as handled. It returns the offset to commit if the contiguous handled range grew.
+func (t *offsetTracker) handle(offset int64) (int64, bool) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.handled[offset] = struct{}{}
+
+	var (
+		commit   int64
+		advanced bool
+	)
+
+	for len(t.pending) > 0 {
+		if _, ok := t.handled[t.pending[0]]; !ok {
+			break
+		}
+
+		delete(t.handled, t.pending[0])
+		commit = t.pending[0] + 1
+		advanced = true
+		t.pending = t.pending[1:]
+	}
+
+	return commit, advanced
+}
+
+// consumeByKeys handles messages of the partition by a pool of workers.
+// Messages are distributed by key, so messages with the same key are handled in order by one worker.
+// As event IDs are used as keys, copies of an event are never handled concurrently
+// and don't compete for the lease in the event store.
+// When the generation ends or a message fails, queued messages are left unhandled
+// and the listener returns after in-flight messages are finished and committed.
+func (c *Client) consumeByKeys(ctx, genCtx, fetchCtx context.Context, gen *generation,
+	topic string, reader partitionReader, handler MessageHandler) error {
+	poolCtx, cancel := context.WithCancel(fetchCtx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		commitMx  sync.Mutex
+		committed int64
+		failMx    sync.Mutex
+		failErr   error
+	)
+
+	fail := func(err error) {
+		failMx.Lock()
+		defer failMx.Unlock()
+
+		if failErr == nil {
+			failErr = err
+		}
+
+		cancel()
+	}
+
+	commit := func(msg *goKafka.Message, e event.BaseEvent, offset int64) error {
+		commitMx.Lock()
+		defer commitMx.Unlock()
+
+		if offset <= committed {
+			return nil
+		}
+
+		if err := c.commitMessage(ctx, genCtx, gen, msg, e, offset); err != nil {
+			return err
+		}
+
+		committed = offset
+
+		return nil
+	}
+
+	tracker := newOffsetTracker()
+	workers := make([]chan *goKafka.Message, c.cfg.Consumer.Workers)
+
+	for i := range workers {
+		queue := make(chan *goKafka.Message, 1)
+		workers[i] = queue
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for msg := range queue {
+				if poolCtx.Err() != nil {
+					continue
+				}
+
+				c.inflight.add(msg)
+				c.handleQueued(ctx, poolCtx, msg, handler, tracker, commit, fail)
+				c.inflight.done(msg)
+			}
+		}()
+	}
+
+	c.dispatchByKeys(ctx, genCtx, poolCtx, topic, reader, tracker, workers, fail)
+
+	for _, queue := range workers {
+		close(queue)
+	}
+
+	wg.Wait()
+
+	return failErr
+}
+
+// handleQueued handles the message of a worker and commits the offset up to which all fetched messages are handled
+func (c *Client) handleQueued(ctx, poolCtx context.Context, msg *goKafka.Message, handler MessageHandler,
+	tracker *offsetTracker, commit func(msg *goKafka.Message, e event.BaseEvent, offset int64) error, fail func(err error)) {
+	e, handled, err := c.handleResumed(ctx, poolCtx, msg, handler)
+	if err != nil {
+		fail(err)
+
+		return
+	}
+
+	if !handled {
+		return
+	}
+
+	offset, ok := tracker.handle(msg.Offset)
+	if !ok {
+		return
+	}
+
+	if err := commit(msg, e, offset); err != nil {
+		fail(err)
+	}
+}
+
+// dispatchByKeys fetches messages of the partition and passes them to the workers until the pool is canceled
+func (c *Client) dispatchByKeys(ctx, genCtx, poolCtx context.Context, topic string, reader partitionReader,
+	tracker *offsetTracker, workers []chan *goKafka.Message, fail func(err error)) {
+	for !c.stopped() {
+		msg, err := reader.FetchMessage(poolCtx)
+		if err != nil {
+			if poolCtx.Err() == nil {
+				fail(cerror.NewF(
+					ctx,
+					cerror.KafkaToKind(err),
+					"failed fetch message from kafka for topic: %v. %s", topic, err.Error()).
+					LogError())
+			}
+
+			return
+		}
+
+		if genCtx.Err() != nil || c.stopped() {
+			log.DebugF(ctx,
+				"partition revoked or client stopped. message is left to the next owner. "+
+					"topic: %s. partition: %d. offset: %d. key: %s.",
+				msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+			return
+		}
+
+		log.DebugF(ctx,
+			"consume message from kafka topic: %s. partition: %d. offset: %d. key: %s.",
+			msg.Topic,
+			msg.Partition,
+			msg.Offset,
+			string(msg.Key))
+
+		if err := waitRetryDelay(poolCtx, &msg); err != nil {
+			log.DebugF(ctx, "retry delay of message for topic: %v interrupted. %s", msg.Topic, err.Error())
+
+			return
+		}
+
+		tracker.fetched(msg.Offset)
+
+		select {
+		case workers[workerIndex(&msg, len(workers))] <- &msg:
+		case <-poolCtx.Done():
+			return
+		}
+	}
+}
+
+// workerIndex returns a worker of the message by its key. Messages without key are distributed by offset.
+func workerIndex(msg *goKafka.Message, workers int) int {
+	if len(msg.Key) == 0 {
+		return int(msg.Offset % int64(workers))
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+
+	return int(h.Sum32() % uint32(workers))
+}
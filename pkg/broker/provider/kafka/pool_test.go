@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"sync"
+	"testing"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+	"github.com/tj/assert"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	t.Parallel()
+
+	tr := newOffsetTracker()
+
+	// offsets of a compacted partition aren't contiguous
+	for _, o := range []int64{3, 5, 6, 9} {
+		tr.fetched(o)
+	}
+
+	_, ok := tr.handle(5)
+	assert.False(t, ok)
+
+	offset, ok := tr.handle(3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(6), offset)
+
+	_, ok = tr.handle(9)
+	assert.False(t, ok)
+
+	offset, ok = tr.handle(6)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), offset)
+}
+
+func TestWorkersKeepKeyOrderAndCommitContiguousOffsets(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster("topic", "a", "b", "a", "b", "c")
+	c := newFakeClient(fc)
+	c.cfg.Consumer.Workers = 4
+
+	var (
+		mx    sync.Mutex
+		byKey = make(map[string][]int64)
+	)
+
+	release := make(chan struct{})
+	handler := HandelFn(func(_ context.Context, m *goKafka.Message) (event.BaseEvent, error) {
+		if m.Offset == 0 {
+			<-release
+		}
+
+		mx.Lock()
+		defer mx.Unlock()
+
+		byKey[string(m.Key)] = append(byKey[string(m.Key)], m.Offset)
+
+		return nil, nil
+	})
+
+	handledKeys := func(key string) int {
+		mx.Lock()
+		defer mx.Unlock()
+
+		return len(byKey[key])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = c.ListenTopic(ctx, "topic", handler)
+
+	fc.join(1)
+
+	// other keys aren't blocked by the slow handler of the key a
+	assert.Eventually(t, func() bool {
+		return handledKeys("b") == 2 && handledKeys("c") == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, handledKeys("a"))
+
+	// nothing is committed until the first message is handled
+	assert.Equal(t, []string{"assign 1 [0]"}, fc.getHistory())
+
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		fc.mx.Lock()
+		defer fc.mx.Unlock()
+
+		return fc.committed == 5
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	c.wg.Wait()
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	assert.Equal(t, []int64{0, 2}, byKey["a"])
+	assert.Equal(t, []int64{1, 3}, byKey["b"])
+	assert.Equal(t, []int64{4}, byKey["c"])
+}
+
+func TestWorkersFailedMessageIsNotCommitted(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeCluster("topic", "a", "b", "c")
+	c := newFakeClient(fc)
+	c.cfg.Consumer.Workers = 4
+
+	errHandler := errors.New("handler error")
+	handler := HandelFn(func(_ context.Context, m *goKafka.Message) (event.BaseEvent, error) {
+		if m.Offset == 1 {
+			return nil, errHandler
+		}
+
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := c.ListenTopic(ctx, "topic", handler)
+
+	fc.join(1)
+
+	select {
+	case err := <-errCh:
+		assert.Contains(t, err.Error(), errHandler.Error())
+	case <-time.After(time.Second):
+		t.Fatal("listener error expected")
+	}
+
+	c.wg.Wait()
+
+	fc.mx.Lock()
+	defer fc.mx.Unlock()
+
+	// the message after the failed one may be handled, but the commit never passes the failed message
+	assert.LessOrEqual(t, fc.committed, int64(1))
+}
@@ -5,24 +5,26 @@ import (
 	"errors"
 	"fmt"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/broker/store"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/converto"
-	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/log"
 	"kafka-polygon/pkg/tracing"
+	"regexp"
+	"sync"
 	"time"
 
 	goKafka "github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 )
 
 const (
 	BrokerKafkaProvider = "kafka"
 	TraceKafkaProducer  = "_kafka_producer"
 	TraceKafkaConsumer  = "_kafka_consumer"
+	TraceKafkaRebalance = "_kafka_rebalance"
 )
 
 var (
@@ -30,25 +32,41 @@ var (
 )
 
 type Provider struct {
-	cfgCl   *Config
-	enabled bool
-	cl      KClient
-	store   store.Store
-	trace   tracing.Tracer
+	cfgCl     *Config
+	enabled   bool
+	cl        KClient
+	store     store.Store
+	trace     tracing.Tracer
+	rebalance RebalanceHandler
+	// stopCh is closed by Shutdown, so stopped listeners aren't re-run
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 func NewKafkaProvider(cfg *Config) *Provider {
-	cl := NewClient(cfg)
+	if cfg == nil {
+		cfg = &Config{}
+	}
 
-	return &Provider{
+	p := &Provider{
 		cfgCl:   cfg,
 		enabled: true,
-		cl:      cl,
+		stopCh:  make(chan struct{}),
 	}
+	p.SetClient(NewClient(cfg))
+
+	return p
 }
 
 func (p *Provider) SetClient(cl KClient) {
 	p.cl = cl
+	p.cl.SetRebalanceHandler(p.rebalanceHandler())
+}
+
+// SetRebalanceHandler sets a handler notified about partitions assigned to and revoked from the consumer.
+// It must be set before Sync is called.
+func (p *Provider) SetRebalanceHandler(h RebalanceHandler) {
+	p.rebalance = h
 }
 
 func (p *Provider) SetEnabled(enable bool) {
@@ -67,108 +85,268 @@ func (p *Provider) GetIsTopicExists(ctx context.Context, topic string) (bool, er
 	return p.cl.GetIsTopicExists(ctx, topic)
 }
 
+// Publish sends the event. The producer span is started before sending,
+// so its context is written to the record headers and consumer spans are its children.
 func (p *Provider) Publish(ctx context.Context, topic string, e event.BaseEvent) error {
-	err := p.cl.SendMessage(ctx, topic, e)
+	spanCtx, endTrace := provider.StartEventTrace(ctx, p.trace, TraceKafkaProducer, topic)
+
+	err := p.cl.SendMessage(spanCtx, topic, e)
+
+	endTrace(e, err)
+	metrics.ObservePublish(BrokerKafkaProvider, topic, 1, err)
+
+	return err
+}
+
+// PublishBatch publishes the events in one batch. Each event is traced with its own result.
+func (p *Provider) PublishBatch(ctx context.Context, topic string, events []event.BaseEvent) error {
+	err := p.cl.SendMessages(ctx, topic, events)
 
-	p.syncTrace(ctx, TraceKafkaProducer, topic, e, err)
+	var be *provider.BatchError
+
+	isBatchErr := errors.As(err, &be)
+
+	for i, e := range events {
+		eErr := err
+		if isBatchErr && i < len(be.Errs) {
+			eErr = be.Errs[i]
+		}
+
+		p.syncTrace(ctx, TraceKafkaProducer, topic, e, eErr)
+		metrics.ObservePublish(BrokerKafkaProvider, topic, 1, eErr)
+	}
 
 	return err
 }
 
 func (p *Provider) Sync(ctx context.Context, topic string, fn provider.HandlerFn) {
 	if p.enabled {
-		mHandler := p.getHandler(ctx, topic, fn)
-		errCh := p.cl.ListenTopic(ctx, topic, mHandler)
+		p.listen(ctx, topic, fn)
 
-		go p.processListenerErrors(ctx, topic, mHandler, errCh)
+		// failed messages of the topic are handled again from its retry topics
+		for _, retryTopic := range p.cfgCl.Retry.Topics(topic) {
+			p.listen(ctx, retryTopic, fn)
+		}
 	}
 }
 
-func (p *Provider) Stop() {
-	if p.enabled {
-		p.cl.Stop()
+func (p *Provider) listen(ctx context.Context, topic string, fn provider.HandlerFn) {
+	mHandler := p.getHandler(fn)
+	listen := func() chan error {
+		return p.cl.ListenTopic(ctx, topic, mHandler)
+	}
 
-		if p.trace != nil {
-			_ = p.trace.Shutdown()
+	go p.processListenerErrors(ctx, topic, listen, listen())
+}
+
+// SyncTopics handles messages of all topics of the subscription, including topics matched by its pattern,
+// and their retry topics by one member of the consumer group. fn gets messages of all the topics,
+// so it's usually provider.Router routing them by event type or topic.
+func (p *Provider) SyncTopics(ctx context.Context, sub Subscription, fn provider.HandlerFn) {
+	if !p.enabled {
+		return
+	}
+
+	// retry topics are resolved for every topic, so topics matched by the pattern later get them too
+	sub.Retry = true
+
+	mHandler := p.getHandler(fn)
+	listen := func() chan error {
+		return p.cl.ListenTopics(ctx, sub, mHandler)
+	}
+
+	go p.processListenerErrors(ctx, sub.String(), listen, listen())
+}
+
+// SyncSubscription handles messages of the subscription configured by Consumer.ListenTopics
+// and Consumer.ListenPattern by SyncTopics. An error is returned if the subscription is empty or invalid.
+func (p *Provider) SyncSubscription(ctx context.Context, fn provider.HandlerFn) error {
+	sub := p.cfgCl.Consumer.Subscription()
+	if len(sub.Topics) == 0 && sub.Pattern == "" {
+		return cerror.NewF(ctx, cerror.KindKafkaOther, "[kafka] no topics of the consumer subscription").LogError()
+	}
+
+	if sub.Pattern != "" {
+		if _, err := regexp.Compile(sub.Pattern); err != nil {
+			return cerror.NewF(ctx,
+				cerror.KindKafkaOther,
+				"[kafka] invalid topic pattern of subscription %s. %s", sub, err.Error()).
+				LogError()
 		}
 	}
+
+	p.SyncTopics(ctx, sub, fn)
+
+	return nil
+}
+
+// Pause stops handling messages of the topic and its retry topics. The consumer stays in the group,
+// so partitions aren't rebalanced, and messages being handled are finished and committed.
+func (p *Provider) Pause(ctx context.Context, topic string) {
+	log.InfoF(ctx, "[kafka] pause consuming of topic %s", topic)
+
+	for _, t := range append([]string{topic}, p.cfgCl.Retry.Topics(topic)...) {
+		p.cl.Pause(t)
+	}
+}
+
+// Resume continues handling messages of the topic and its retry topics paused by Pause
+func (p *Provider) Resume(ctx context.Context, topic string) {
+	log.InfoF(ctx, "[kafka] resume consuming of topic %s", topic)
+
+	for _, t := range append([]string{topic}, p.cfgCl.Retry.Topics(topic)...) {
+		p.cl.Resume(t)
+	}
+}
+
+// Paused reports whether messages of the topic aren't handled, by Pause or because Config.HealthCheck fails
+func (p *Provider) Paused(topic string) bool {
+	return p.cl.Paused(topic)
+}
+
+// Shutdown stops the provider gracefully: listeners aren't re-run anymore, they stop fetching messages,
+// in-flight messages are handled and committed and the writer is closed. If ctx is done before,
+// the returned error lists messages that weren't drained, they are redelivered to the next owner of their partitions.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if !p.enabled {
+		return nil
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+
+	err := p.cl.Shutdown(ctx)
+
+	if p.trace != nil {
+		_ = p.trace.Shutdown()
+	}
+
+	return err
+}
+
+// Stop stops the provider and waits until listeners are drained
+func (p *Provider) Stop() {
+	_ = p.Shutdown(context.Background())
 }
 
 func (p *Provider) GetType() string {
 	return BrokerKafkaProvider
 }
 
-func (p *Provider) getHandler(_ context.Context, topic string, fn provider.HandlerFn) HandelFn {
+func (p *Provider) getHandler(fn provider.HandlerFn) HandelFn {
 	return func(ctx context.Context, m *goKafka.Message) (event.BaseEvent, error) {
 		if m == nil {
 			return nil, cerror.New(ctx, cerror.KindKafkaOther, errKafkaMessageEmpty).LogError()
 		}
 
-		ph := provider.NewHandlerProcessing(p.store)
+		ph := provider.NewHandlerProcessing(p.store).SetLease(p.cfgCl.EventLease).SetCodec(p.cfgCl.Codec)
 
 		em := event.Message{
 			Key:   converto.BytePointer(m.Key),
 			Value: m.Value,
 		}
 
-		e, err := ph.Run(ctx, fn, em)
+		// messages of retry topics are routed as messages of their original topic
+		msgTopic := m.Topic
+		if original := ParseRetryInfo(m).OriginalTopic; original != "" {
+			msgTopic = original
+		}
+
+		ctx = provider.WithTopic(provider.ContextFromHeaders(ctx, HeadersMap(m.Headers)), msgTopic)
+		spanCtx, endTrace := provider.StartEventTrace(ctx, p.trace, TraceKafkaConsumer, m.Topic)
+		start := time.Now()
+
+		e, err := ph.Run(spanCtx, fn, em)
 
-		p.syncTrace(ctx, TraceKafkaConsumer, topic, e, err)
+		endTrace(e, err)
+		metrics.ObserveHandle(BrokerKafkaProvider, m.Topic, start, err)
 
 		return e, err
 	}
 }
 
-func (p *Provider) tryRerunTopicListener(ctx context.Context, topic string, mHandler MessageHandler) {
-	time.Sleep(p.cfgCl.RerunDelay)
+// tryRerunTopicListener re-runs the listener after RerunDelay. It returns false if the provider is stopped before.
+func (p *Provider) tryRerunTopicListener(ctx context.Context, topic string, listen func() chan error) (chan error, bool) {
+	select {
+	case <-time.After(p.cfgCl.RerunDelay):
+	case <-p.stopCh:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+
 	log.DebugF(ctx, "try re-run consumer by topic = %v", topic)
-	p.cl.ListenTopic(ctx, topic, mHandler)
+	errCh := listen()
 	log.DebugF(ctx, "task to re-run consumer by topic = %v started", topic)
+
+	return errCh, true
 }
 
-func (p *Provider) processListenerErrors(ctx context.Context, topic string, mHandler MessageHandler, errCh chan error) {
+func (p *Provider) processListenerErrors(ctx context.Context, topic string, listen func() chan error, errCh chan error) {
 	for {
 		select {
 		case err := <-errCh:
 			_ = cerror.NewF(ctx, cerror.KindKafkaOther, "consumer for topic = %s stopped. %s", topic, err.Error()).LogError()
 
-			p.tryRerunTopicListener(ctx, topic, mHandler)
+			var ok bool
+
+			errCh, ok = p.tryRerunTopicListener(ctx, topic, listen)
+			if !ok {
+				return
+			}
+
+			metrics.ListenerRestarts.Inc(BrokerKafkaProvider, topic)
 
 			continue
+		case <-p.stopCh:
+			return
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (p *Provider) syncTrace(ctx context.Context, compName, operName string, e event.BaseEvent, err error) {
-	if p.trace != nil {
-		p.trace.Trace(compName)
-		tt := p.trace.GetTrace()
-
-		_, span := tt.Start(ctx, operName)
-		defer span.End()
+// rebalanceHandler traces generation changes of the consumer group
+// and passes them to the handler set by SetRebalanceHandler
+func (p *Provider) rebalanceHandler() RebalanceHandler {
+	return RebalanceFuncs{
+		Assign: func(ctx context.Context, a Assignment) {
+			p.traceRebalance(ctx, "assign", a)
+
+			if p.rebalance != nil {
+				p.rebalance.OnAssign(ctx, a)
+			}
+		},
+		Revoke: func(ctx context.Context, a Assignment) {
+			p.traceRebalance(ctx, "revoke", a)
+
+			if p.rebalance != nil {
+				p.rebalance.OnRevoke(ctx, a)
+			}
+		},
+	}
+}
 
-		attrs := make(map[string]string)
+func (p *Provider) traceRebalance(ctx context.Context, operName string, a Assignment) {
+	if p.trace == nil {
+		return
+	}
 
-		if e != nil {
-			attrs["broker.event.id"] = e.GetID()
-			attrs["broker.event.header"] = fmt.Sprintf("%+v", e.GetHeader())
-		}
+	p.trace.Trace(TraceKafkaRebalance)
 
-		if err != nil {
-			attrs["error.message"] = err.Error()
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-		}
+	_, span := p.trace.GetTrace().Start(ctx, fmt.Sprintf("%s %s", operName, a.Topic))
+	defer span.End()
 
-		reqID := ctx.Value(consts.HeaderXRequestID)
-		if reqID != nil {
-			attrs["http.requestID"] = fmt.Sprintf("%s", reqID)
-		}
+	span.SetAttributes(
+		attribute.String("kafka.group.id", a.GroupID),
+		attribute.String("kafka.member.id", a.MemberID),
+		attribute.Int64("kafka.generation.id", int64(a.GenerationID)),
+		attribute.String("kafka.topic", a.Topic),
+		attribute.IntSlice("kafka.partitions", a.Partitions),
+	)
+}
 
-		for key, val := range attrs {
-			span.SetAttributes(attribute.Key(key).String(val))
-		}
-	}
+func (p *Provider) syncTrace(ctx context.Context, compName, operName string, e event.BaseEvent, err error) {
+	provider.TraceEvent(ctx, p.trace, compName, operName, e, err)
 }
@@ -3,8 +3,11 @@ package kafka_test
 import (
 	"context"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
 	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
 	"testing"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/mock"
@@ -36,6 +39,11 @@ func (mk *MockedKafka) ListenTopic(_ context.Context, topic string, handler pKaf
 	return nil
 }
 
+func (mk *MockedKafka) ListenTopics(_ context.Context, sub pKafka.Subscription, handler pKafka.MessageHandler) chan error {
+	_ = mk.Called(sub, handler)
+	return nil
+}
+
 func (mk *MockedKafka) GetIsTopicExists(_ context.Context, topic string) (bool, error) {
 	_ = mk.Called(topic)
 	return true, nil
@@ -46,6 +54,31 @@ func (mk *MockedKafka) SendMessage(ctx context.Context, topic string, e event.Ba
 	return args.Error(0)
 }
 
+func (mk *MockedKafka) SendMessages(ctx context.Context, topic string, events []event.BaseEvent) error {
+	args := mk.Called(ctx, topic, events)
+	return args.Error(0)
+}
+
+func (mk *MockedKafka) SetRebalanceHandler(_ pKafka.RebalanceHandler) {}
+
+func (mk *MockedKafka) Pause(topic string) {
+	_ = mk.Called(topic)
+}
+
+func (mk *MockedKafka) Resume(topic string) {
+	_ = mk.Called(topic)
+}
+
+func (mk *MockedKafka) Paused(topic string) bool {
+	args := mk.Called(topic)
+	return args.Bool(0)
+}
+
+func (mk *MockedKafka) Shutdown(ctx context.Context) error {
+	args := mk.Called(ctx)
+	return args.Error(0)
+}
+
 func (mk *MockedKafka) Stop() {
 	_ = mk.Called()
 }
@@ -89,6 +122,24 @@ func TestKafkaProviderError(t *testing.T) {
 	assert.Equal(t, kafka.BrokerNotAvailable, err)
 }
 
+func TestKafkaProviderPublishBatch(t *testing.T) {
+	t.Parallel()
+
+	events := []event.BaseEvent{&e, &event.WorkflowData{ID: "test-id-2"}}
+	batchErr := &provider.BatchError{Errs: []error{nil, kafka.BrokerNotAvailable}}
+
+	mk := &MockedKafka{}
+	mk.On("SendMessages", bgCtx, "test-topic", events).Return(batchErr)
+
+	kp := pKafka.NewKafkaProvider(nil)
+	kp.SetClient(mk)
+
+	err := kp.PublishBatch(bgCtx, "test-topic", events)
+	require.Error(t, err)
+	assert.Equal(t, batchErr, err)
+	mk.AssertExpectations(t)
+}
+
 func TestKafkaGetIsTopicExists(t *testing.T) {
 	t.Parallel()
 
@@ -104,3 +155,100 @@ func TestKafkaGetIsTopicExists(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, true, check)
 }
+
+func TestKafkaProviderSyncRetryTopics(t *testing.T) {
+	t.Parallel()
+
+	mk := &MockedKafka{}
+	mk.On("ListenTopic", "test-topic", mock.Anything)
+	mk.On("ListenTopic", "test-topic.retry.30s", mock.Anything)
+	mk.On("ListenTopic", "test-topic.retry.5m", mock.Anything)
+
+	kp := pKafka.NewKafkaProvider(&pKafka.Config{
+		Retry: pKafka.Retry{
+			Delays: []time.Duration{30 * time.Second, 5 * time.Minute},
+			DLQ:    true,
+		},
+	})
+	kp.SetClient(mk)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	kp.Sync(ctx, "test-topic", provider.HandlerWorkflow(
+		func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+			return nil
+		}))
+
+	mk.AssertExpectations(t)
+	mk.AssertNumberOfCalls(t, "ListenTopic", 3)
+}
+
+func TestKafkaProviderSyncTopics(t *testing.T) {
+	t.Parallel()
+
+	mk := &MockedKafka{}
+	mk.On("ListenTopics", pKafka.Subscription{
+		Topics:  []string{"orders", "payments"},
+		Pattern: `^audit\.`,
+		Retry:   true,
+	}, mock.Anything)
+
+	kp := pKafka.NewKafkaProvider(&pKafka.Config{
+		Retry: pKafka.Retry{Delays: []time.Duration{30 * time.Second}},
+	})
+	kp.SetClient(mk)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	kp.SyncTopics(ctx, pKafka.Subscription{Topics: []string{"orders", "payments"}, Pattern: `^audit\.`},
+		provider.NewRouter())
+
+	mk.AssertExpectations(t)
+}
+
+func TestKafkaProviderSyncSubscription(t *testing.T) {
+	t.Parallel()
+
+	mk := &MockedKafka{}
+	mk.On("ListenTopics", pKafka.Subscription{
+		Topics:  []string{"orders"},
+		Pattern: `^audit\.`,
+		Retry:   true,
+	}, mock.Anything)
+
+	kp := pKafka.NewKafkaProvider(&pKafka.Config{
+		Consumer: pKafka.Consumer{ListenTopics: []string{"orders"}, ListenPattern: `^audit\.`},
+	})
+	kp.SetClient(mk)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	require.NoError(t, kp.SyncSubscription(ctx, provider.NewRouter()))
+
+	mk.AssertExpectations(t)
+}
+
+func TestKafkaProviderPauseRetryTopics(t *testing.T) {
+	t.Parallel()
+
+	mk := &MockedKafka{}
+	mk.On("Pause", "test-topic")
+	mk.On("Pause", "test-topic.retry.30s")
+	mk.On("Resume", "test-topic")
+	mk.On("Resume", "test-topic.retry.30s")
+	mk.On("Paused", "test-topic").Return(false)
+
+	kp := pKafka.NewKafkaProvider(&pKafka.Config{
+		Retry: pKafka.Retry{Delays: []time.Duration{30 * time.Second}},
+	})
+	kp.SetClient(mk)
+
+	kp.Pause(bgCtx, "test-topic")
+	kp.Resume(bgCtx, "test-topic")
+	assert.False(t, kp.Paused("test-topic"))
+
+	mk.AssertExpectations(t)
+}
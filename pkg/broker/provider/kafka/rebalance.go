@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+)
+
+// Assignment is a set of partitions of a topic owned by a group member in a generation
+type Assignment struct {
+	GroupID      string
+	MemberID     string
+	GenerationID int32
+	Topic        string
+	Partitions   []int
+}
+
+// RebalanceHandler is notified when the consumer gets and loses partitions on a group rebalance.
+// OnAssign is called before the first message of the generation is handled.
+// OnRevoke is called after in-flight messages of the generation are finished and their offsets are committed,
+// so local state of the lost partitions can be dropped.
+type RebalanceHandler interface {
+	OnAssign(ctx context.Context, a Assignment)
+	OnRevoke(ctx context.Context, a Assignment)
+}
+
+// RebalanceFuncs adapts functions to RebalanceHandler. Nil functions are skipped.
+type RebalanceFuncs struct {
+	Assign func(ctx context.Context, a Assignment)
+	Revoke func(ctx context.Context, a Assignment)
+}
+
+func (rf RebalanceFuncs) OnAssign(ctx context.Context, a Assignment) {
+	if rf.Assign != nil {
+		rf.Assign(ctx, a)
+	}
+}
+
+func (rf RebalanceFuncs) OnRevoke(ctx context.Context, a Assignment) {
+	if rf.Revoke != nil {
+		rf.Revoke(ctx, a)
+	}
+}
+
+// assignment returns partitions of the topic assigned to the member in the generation
+func (g *generation) assignment(topic string) Assignment {
+	partitions := make([]int, 0, len(g.assignments[topic]))
+	for _, pa := range g.assignments[topic] {
+		partitions = append(partitions, pa.ID)
+	}
+
+	sort.Ints(partitions)
+
+	return Assignment{
+		GroupID:      g.groupID,
+		MemberID:     g.memberID,
+		GenerationID: g.id,
+		Topic:        topic,
+		Partitions:   partitions,
+	}
+}
@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"strconv"
+	"strings"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderRetryAttempt      = "x-retry-attempt"
+	HeaderRetryReason       = "x-retry-reason"
+	HeaderRetryNotBefore    = "x-retry-not-before"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+
+	retryTopicSuffix = ".retry."
+	dlqTopicSuffix   = ".dlq"
+)
+
+// Retry routes messages failed in handler to retry topics and a dead-letter topic,
+// so a poison message doesn't stop the listener of its partition.
+type Retry struct {
+	// Delays are tiers of retry topics. A message failed in a topic is sent to <topic>.retry.<Delays[0]>,
+	// failed there - to <topic>.retry.<Delays[1]> and so on. For example, topic.retry.30s and topic.retry.5m.
+	// Retry topics are consumed by the provider with the handler of the original topic.
+	Delays []time.Duration
+	// DLQ enables routing of messages failed in all tiers to <topic>.dlq.
+	// If DLQ is disabled, a message failed in the last tier is processed as without retry.
+	DLQ bool
+}
+
+func (r Retry) Enabled() bool {
+	return len(r.Delays) > 0 || r.DLQ
+}
+
+// Topics returns retry topics of the topic in the order of tiers
+func (r Retry) Topics(topic string) []string {
+	topics := make([]string, len(r.Delays))
+
+	for i, d := range r.Delays {
+		topics[i] = RetryTopic(topic, d)
+	}
+
+	return topics
+}
+
+// Next returns a message for the next tier of the failed message. The message is sent to a retry topic
+// of the next tier or to the dead-letter topic. False is returned if there is no next tier.
+func (r Retry) Next(msg *goKafka.Message, reason error, now time.Time) (goKafka.Message, bool) {
+	info := ParseRetryInfo(msg)
+	if info.OriginalTopic == "" {
+		info.OriginalTopic = msg.Topic
+		info.OriginalPartition = msg.Partition
+		info.OriginalOffset = msg.Offset
+	}
+
+	info.Attempt++
+	info.Reason = reason.Error()
+	info.NotBefore = time.Time{}
+
+	var topic string
+
+	switch tier := info.Attempt - 1; {
+	case tier < len(r.Delays):
+		topic = RetryTopic(info.OriginalTopic, r.Delays[tier])
+		info.NotBefore = now.Add(r.Delays[tier])
+	case r.DLQ:
+		topic = DLQTopic(info.OriginalTopic)
+	default:
+		return goKafka.Message{}, false
+	}
+
+	return goKafka.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: info.headers(msg.Headers),
+	}, true
+}
+
+// RetryTopic returns a name of the retry topic, e.g. topic.retry.30s
+func RetryTopic(topic string, delay time.Duration) string {
+	return topic + retryTopicSuffix + formatDelay(delay)
+}
+
+// isRetryTopic reports whether the topic is named as a retry topic of another topic
+func isRetryTopic(topic string) bool {
+	return strings.Contains(topic, retryTopicSuffix)
+}
+
+// DLQTopic returns a name of the dead-letter topic, e.g. topic.dlq
+func DLQTopic(topic string) string {
+	return topic + dlqTopicSuffix
+}
+
+// RetryInfo is a retry state of a message stored in its headers
+type RetryInfo struct {
+	// Attempt is a count of failed attempts to handle the message
+	Attempt int
+	// Reason is an error of the last failed attempt
+	Reason            string
+	OriginalTopic     string
+	OriginalPartition int
+	OriginalOffset    int64
+	// NotBefore is a moment before which the message must not be handled
+	NotBefore time.Time
+}
+
+// ParseRetryInfo reads retry headers of the message. Zero values are returned for absent headers.
+func ParseRetryInfo(msg *goKafka.Message) RetryInfo {
+	var info RetryInfo
+
+	for _, h := range msg.Headers {
+		v := string(h.Value)
+
+		switch h.Key {
+		case HeaderRetryAttempt:
+			info.Attempt, _ = strconv.Atoi(v)
+		case HeaderRetryReason:
+			info.Reason = v
+		case HeaderOriginalTopic:
+			info.OriginalTopic = v
+		case HeaderOriginalPartition:
+			info.OriginalPartition, _ = strconv.Atoi(v)
+		case HeaderOriginalOffset:
+			info.OriginalOffset, _ = strconv.ParseInt(v, 10, 64)
+		case HeaderRetryNotBefore:
+			if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+				info.NotBefore = time.UnixMilli(ms)
+			}
+		}
+	}
+
+	return info
+}
+
+// headers replaces retry headers in the given headers with the current state
+func (ri RetryInfo) headers(src []goKafka.Header) []goKafka.Header {
+	headers := make([]goKafka.Header, 0, len(src)+6)
+
+	for _, h := range src {
+		if !isRetryHeader(h.Key) {
+			headers = append(headers, h)
+		}
+	}
+
+	headers = append(headers,
+		goKafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(ri.Attempt))},
+		goKafka.Header{Key: HeaderRetryReason, Value: []byte(ri.Reason)},
+		goKafka.Header{Key: HeaderOriginalTopic, Value: []byte(ri.OriginalTopic)},
+		goKafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(ri.OriginalPartition))},
+		goKafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(ri.OriginalOffset, 10))},
+	)
+
+	if !ri.NotBefore.IsZero() {
+		headers = append(headers, goKafka.Header{
+			Key:   HeaderRetryNotBefore,
+			Value: []byte(strconv.FormatInt(ri.NotBefore.UnixMilli(), 10)),
+		})
+	}
+
+	return headers
+}
+
+func isRetryHeader(key string) bool {
+	switch key {
+	case HeaderRetryAttempt, HeaderRetryReason, HeaderRetryNotBefore,
+		HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatDelay formats the delay with the largest whole unit: 30s, 5m, 1h
+func formatDelay(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	default:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+}
+
+// routeFailedMessage sends the failed message to the next retry tier.
+// It returns false if retry is disabled or all tiers are passed.
+func (c *Client) routeFailedMessage(ctx context.Context, msg *goKafka.Message, reason error) (bool, error) {
+	if !c.cfg.Retry.Enabled() {
+		return false, nil
+	}
+
+	next, ok := c.cfg.Retry.Next(msg, reason, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	if err := c.sendRetry(ctx, next.Topic, []goKafka.Message{next}, true); err != nil {
+		return false, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"failed to route message to %s. topic: %s. partition %d. offset: %d. key: %s. %s",
+			next.Topic, msg.Topic, msg.Partition, msg.Offset, string(msg.Key), err.Error()).LogError()
+	}
+
+	log.DebugF(ctx, "failed message routed to %s. topic: %s. partition %d. offset: %d. key: %s.",
+		next.Topic, msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+	return true, nil
+}
+
+// waitRetryDelay blocks until the moment from the retry header of the message
+func waitRetryDelay(ctx context.Context, msg *goKafka.Message) error {
+	notBefore := ParseRetryInfo(msg).NotBefore
+	if notBefore.IsZero() {
+		return nil
+	}
+
+	delay := time.Until(notBefore)
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
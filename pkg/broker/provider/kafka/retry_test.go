@@ -0,0 +1,98 @@
+package kafka_test
+
+import (
+	"errors"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"testing"
+	"time"
+
+	goKafka "github.com/segmentio/kafka-go"
+	"github.com/tj/assert"
+)
+
+func TestRetryTopics(t *testing.T) {
+	t.Parallel()
+
+	r := kafka.Retry{Delays: []time.Duration{30 * time.Second, 5 * time.Minute, 2 * time.Hour, 1500 * time.Millisecond}}
+
+	assert.True(t, r.Enabled())
+	assert.False(t, kafka.Retry{}.Enabled())
+	assert.Equal(t, []string{
+		"topic.retry.30s",
+		"topic.retry.5m",
+		"topic.retry.2h",
+		"topic.retry.1500ms",
+	}, r.Topics("topic"))
+	assert.Equal(t, "topic.dlq", kafka.DLQTopic("topic"))
+}
+
+func TestRetryNext(t *testing.T) {
+	t.Parallel()
+
+	r := kafka.Retry{Delays: []time.Duration{30 * time.Second, 5 * time.Minute}, DLQ: true}
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	msg := &goKafka.Message{
+		Topic:     "topic",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("key"),
+		Value:     []byte("value"),
+		Headers:   []goKafka.Header{{Key: "custom", Value: []byte("v")}},
+	}
+
+	next, ok := r.Next(msg, errors.New("first failure"), now)
+	assert.True(t, ok)
+	assert.Equal(t, "topic.retry.30s", next.Topic)
+	assert.Equal(t, msg.Key, next.Key)
+	assert.Equal(t, msg.Value, next.Value)
+	assert.Equal(t, goKafka.Header{Key: "custom", Value: []byte("v")}, next.Headers[0])
+
+	info := kafka.ParseRetryInfo(&next)
+	assert.Equal(t, kafka.RetryInfo{
+		Attempt:           1,
+		Reason:            "first failure",
+		OriginalTopic:     "topic",
+		OriginalPartition: 3,
+		OriginalOffset:    42,
+		NotBefore:         now.Add(30 * time.Second).Local(),
+	}, info)
+
+	// consumed from the retry topic the message keeps its original position
+	next.Partition = 0
+	next.Offset = 7
+
+	next, ok = r.Next(&next, errors.New("second failure"), now)
+	assert.True(t, ok)
+	assert.Equal(t, "topic.retry.5m", next.Topic)
+
+	info = kafka.ParseRetryInfo(&next)
+	assert.Equal(t, 2, info.Attempt)
+	assert.Equal(t, "second failure", info.Reason)
+	assert.Equal(t, 3, info.OriginalPartition)
+	assert.Equal(t, int64(42), info.OriginalOffset)
+	assert.Len(t, next.Headers, 7)
+
+	next, ok = r.Next(&next, errors.New("third failure"), now)
+	assert.True(t, ok)
+	assert.Equal(t, "topic.dlq", next.Topic)
+
+	info = kafka.ParseRetryInfo(&next)
+	assert.Equal(t, 3, info.Attempt)
+	assert.Equal(t, "third failure", info.Reason)
+	assert.True(t, info.NotBefore.IsZero())
+}
+
+func TestRetryNextWithoutDLQ(t *testing.T) {
+	t.Parallel()
+
+	r := kafka.Retry{Delays: []time.Duration{time.Minute}}
+	msg := &goKafka.Message{Topic: "topic"}
+
+	next, ok := r.Next(msg, errors.New("failure"), time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, "topic.retry.1m", next.Topic)
+
+	_, ok = r.Next(&next, errors.New("failure"), time.Now())
+	assert.False(t, ok)
+}
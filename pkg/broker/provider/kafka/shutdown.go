@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/cerror"
+	"sort"
+	"strings"
+	"sync"
+
+	goKafka "github.com/segmentio/kafka-go"
+)
+
+// Shutdown stops the client gracefully. Listeners stop fetching messages, in-flight messages are handled
+// and committed in their generation, consumer groups are left and the writer is closed.
+// Messages fetched but not passed to handlers yet are left uncommitted to the next owner of their partitions.
+// If ctx is done before listeners are drained, the writer is closed anyway
+// and an error listing messages still handled is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.stopMx.Lock()
+	select {
+	case <-c.stopCh:
+	default:
+		close(c.stopCh)
+	}
+	c.stopMx.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		c.wg.Wait()
+		close(drained)
+	}()
+
+	defer c.closeWriter()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	return cerror.NewF(ctx,
+		cerror.KindKafkaOther,
+		"[kafka] shutdown is not completed. %s. messages not drained: %s", ctx.Err().Error(), c.inflight).
+		LogError()
+}
+
+// Stop stops the client and waits until listeners are drained
+func (c *Client) Stop() {
+	_ = c.Shutdown(context.Background())
+}
+
+// stopped reports whether Stop or Shutdown has been called
+func (c *Client) stopped() bool {
+	select {
+	case <-c.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// startListener registers a listener drained by Shutdown. It returns false if the client is stopped.
+func (c *Client) startListener() bool {
+	c.stopMx.Lock()
+	defer c.stopMx.Unlock()
+
+	if c.stopped() {
+		return false
+	}
+
+	c.wg.Add(1)
+
+	return true
+}
+
+// withCancelOn returns a context with values of ctx that is also canceled when any of the channels is closed
+func withCancelOn(ctx context.Context, done ...<-chan struct{}) (context.Context, context.CancelFunc) {
+	cctx, cancel := context.WithCancel(ctx)
+
+	for _, d := range done {
+		d := d
+
+		go func() {
+			select {
+			case <-d:
+				cancel()
+			case <-cctx.Done():
+			}
+		}()
+	}
+
+	return cctx, cancel
+}
+
+type messageKey struct {
+	topic     string
+	partition int
+	offset    int64
+}
+
+// inflightMessages are messages passed to handlers and not committed yet
+type inflightMessages struct {
+	mx   sync.Mutex
+	msgs map[messageKey]struct{}
+}
+
+func newInflightMessages() *inflightMessages {
+	return &inflightMessages{msgs: make(map[messageKey]struct{})}
+}
+
+func (im *inflightMessages) add(msg *goKafka.Message) {
+	im.mx.Lock()
+	defer im.mx.Unlock()
+
+	im.msgs[messageKey{topic: msg.Topic, partition: msg.Partition, offset: msg.Offset}] = struct{}{}
+}
+
+func (im *inflightMessages) done(msg *goKafka.Message) {
+	im.mx.Lock()
+	defer im.mx.Unlock()
+
+	delete(im.msgs, messageKey{topic: msg.Topic, partition: msg.Partition, offset: msg.Offset})
+}
+
+func (im *inflightMessages) keys() []messageKey {
+	im.mx.Lock()
+	defer im.mx.Unlock()
+
+	keys := make([]messageKey, 0, len(im.msgs))
+	for k := range im.msgs {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].topic != keys[j].topic {
+			return keys[i].topic < keys[j].topic
+		}
+
+		if keys[i].partition != keys[j].partition {
+			return keys[i].partition < keys[j].partition
+		}
+
+		return keys[i].offset < keys[j].offset
+	})
+
+	return keys
+}
+
+func (im *inflightMessages) String() string {
+	keys := im.keys()
+	if len(keys) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("topic: %s. partition: %d. offset: %d", k.topic, k.partition, k.offset)
+	}
+
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,127 @@
+package kafka_test
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func publishTo(t *testing.T, p *pKafka.Provider, topic string, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		require.NoError(t, p.Publish(bgCtx, topic, &event.WorkflowData{ID: id}))
+	}
+}
+
+func TestShutdownDrainsInFlightMessage(t *testing.T) {
+	t.Parallel()
+
+	const topic = "shutdown-drain"
+
+	fb := pKafka.NewFakeBroker(1)
+	p := newFakeProvider(fb, newMemEventStore(), 0)
+	publishTo(t, p, topic, "drain-0", "drain-1")
+
+	hc := newHandlerCalls("drain-0")
+	p.Sync(bgCtx, topic, hc.handler())
+
+	select {
+	case <-hc.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler isn't called")
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(bgCtx, 5*time.Second)
+		defer cancel()
+
+		done <- p.Shutdown(ctx)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("shutdown returned before the in-flight message is handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(hc.release)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown isn't completed")
+	}
+
+	// the in-flight message is committed, the next one is left to the next owner
+	assert.Equal(t, int64(1), fb.Committed(fakeGroup, topic, 0))
+	assert.Equal(t, map[string]int{"drain-0": 1}, hc.get())
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	t.Parallel()
+
+	const topic = "shutdown-deadline"
+
+	fb := pKafka.NewFakeBroker(1)
+	p := newFakeProvider(fb, newMemEventStore(), 0)
+	publishTo(t, p, topic, "deadline-0")
+
+	hc := newHandlerCalls("deadline-0")
+	p.Sync(bgCtx, topic, hc.handler())
+
+	select {
+	case <-hc.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler isn't called")
+	}
+
+	ctx, cancel := context.WithTimeout(bgCtx, 50*time.Millisecond)
+	defer cancel()
+
+	err := p.Shutdown(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "topic: shutdown-deadline. partition: 0. offset: 0")
+
+	close(hc.release)
+
+	// the listener is drained after the deadline, so the message is still committed
+	assert.Eventually(t, func() bool {
+		return fb.Committed(fakeGroup, topic, 0) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestShutdownStopsListeners(t *testing.T) {
+	t.Parallel()
+
+	const topic = "shutdown-listeners"
+
+	fb := pKafka.NewFakeBroker(1)
+	p := newFakeProvider(fb, newMemEventStore(), 0)
+	publishTo(t, p, topic, "listeners-0")
+
+	require.NoError(t, p.Shutdown(bgCtx))
+
+	hc := newHandlerCalls("")
+	p.Sync(bgCtx, topic, hc.handler())
+
+	cl := fb.NewClient(&pKafka.Config{Consumer: pKafka.Consumer{GroupID: fakeGroup}})
+	require.NoError(t, cl.Shutdown(bgCtx))
+
+	select {
+	case err := <-cl.ListenTopic(bgCtx, topic, pKafka.HandelFn(nil)):
+		t.Fatalf("stopped client listens the topic: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Empty(t, hc.get())
+	assert.Equal(t, int64(0), fb.Committed(fakeGroup, topic, 0))
+}
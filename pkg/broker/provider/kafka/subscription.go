@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"context"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Subscription is a set of topics consumed by one member of the consumer group,
+// so a client with many topics joins the group once and has one rebalance for all of them.
+type Subscription struct {
+	Topics []string
+	// Pattern subscribes to all topics matching the regular expression in addition to Topics.
+	// Topics created later are picked up within Consumer.TopicWatchInterval by joining the group again.
+	// Retry and dead-letter topics are never matched.
+	Pattern string
+	// Retry adds retry topics (see Config.Retry) of every topic of the subscription, including topics matched by Pattern
+	Retry bool
+}
+
+// Subscription returns the subscription set by ListenTopics and ListenPattern
+func (c *Consumer) Subscription() Subscription {
+	return Subscription{
+		Topics:  c.ListenTopics,
+		Pattern: c.ListenPattern,
+	}
+}
+
+func (s Subscription) String() string {
+	if s.Pattern == "" {
+		return strings.Join(s.Topics, ",")
+	}
+
+	if len(s.Topics) == 0 {
+		return "/" + s.Pattern + "/"
+	}
+
+	return strings.Join(s.Topics, ",") + ",/" + s.Pattern + "/"
+}
+
+// subscriber resolves topics of the subscription
+type subscriber struct {
+	sub     Subscription
+	pattern *regexp.Regexp
+}
+
+func (c *Client) newSubscriber(ctx context.Context, sub Subscription) (*subscriber, error) {
+	s := &subscriber{sub: sub}
+
+	if sub.Pattern != "" {
+		pattern, err := regexp.Compile(sub.Pattern)
+		if err != nil {
+			return nil, cerror.NewF(ctx,
+				cerror.KindKafkaOther,
+				"[kafka] invalid topic pattern of subscription %s. %s", sub, err.Error()).
+				LogError()
+		}
+
+		s.pattern = pattern
+	}
+
+	if len(sub.Topics) == 0 && s.pattern == nil {
+		return nil, cerror.NewF(ctx, cerror.KindKafkaOther, "[kafka] subscription without topics").LogError()
+	}
+
+	return s, nil
+}
+
+// topics returns sorted unique topics of the subscription existing at the moment and their retry topics
+func (s *subscriber) topics(ctx context.Context, c *Client) ([]string, error) {
+	set := make(map[string]struct{}, len(s.sub.Topics))
+	for _, t := range s.sub.Topics {
+		set[t] = struct{}{}
+	}
+
+	if s.pattern != nil {
+		existing, err := c.topicLister(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range existing {
+			if s.pattern.MatchString(t) && !isRetryTopic(t) && !strings.HasSuffix(t, dlqTopicSuffix) {
+				set[t] = struct{}{}
+			}
+		}
+	}
+
+	topics := make([]string, 0, len(set))
+	for t := range set {
+		topics = append(topics, t)
+	}
+
+	if s.sub.Retry {
+		for _, t := range topics {
+			for _, retryTopic := range c.cfg.Retry.Topics(t) {
+				set[retryTopic] = struct{}{}
+			}
+		}
+
+		topics = topics[:0]
+		for t := range set {
+			topics = append(topics, t)
+		}
+	}
+
+	sort.Strings(topics)
+
+	return topics, nil
+}
+
+// watch checks topics of the pattern every Consumer.TopicWatchInterval until ctx is done.
+// changed is called once with the new topics if they differ from the current ones.
+func (s *subscriber) watch(ctx context.Context, c *Client, current []string, changed func(topics []string)) {
+	if s.pattern == nil {
+		return
+	}
+
+	ticker := time.NewTicker(c.cfg.Consumer.TopicWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		topics, err := s.topics(ctx, c)
+		if err != nil {
+			log.DebugF(ctx, "failed to read topics of subscription %s. %s", s.sub, err.Error())
+
+			continue
+		}
+
+		if !equalTopics(topics, current) {
+			log.InfoF(ctx, "topics of subscription %s changed: %v -> %v", s.sub, current, topics)
+			changed(topics)
+
+			return
+		}
+	}
+}
+
+func equalTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readTopics returns names of all topics of the cluster
+func (c *Client) readTopics(ctx context.Context) ([]string, error) {
+	conn, err := c.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		errConn := conn.Close()
+		if errConn != nil {
+			_ = cerror.NewF(ctx,
+				cerror.KafkaToKind(errConn),
+				"[kafka] readTopics conn.Close error: %s", errConn.Error()).
+				LogError()
+		}
+	}()
+
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return nil, cerror.NewF(ctx,
+			cerror.KafkaToKind(err),
+			"[kafka] readTopics conn.ReadPartitions error: %s", err.Error()).
+			LogError()
+	}
+
+	seen := make(map[string]struct{})
+	topics := make([]string, 0)
+
+	for i := range partitions {
+		if _, ok := seen[partitions[i].Topic]; ok {
+			continue
+		}
+
+		seen[partitions[i].Topic] = struct{}{}
+		topics = append(topics, partitions[i].Topic)
+	}
+
+	return topics, nil
+}
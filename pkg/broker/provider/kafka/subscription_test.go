@@ -0,0 +1,292 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	pKafka "kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+// topicCalls records keys of handled messages by topic
+type topicCalls struct {
+	mx    sync.Mutex
+	calls map[string][]string
+}
+
+func newTopicCalls() *topicCalls {
+	return &topicCalls{calls: make(map[string][]string)}
+}
+
+func (tc *topicCalls) handler() pKafka.HandelFn {
+	return func(_ context.Context, m *kafka.Message) (event.BaseEvent, error) {
+		tc.mx.Lock()
+		defer tc.mx.Unlock()
+
+		tc.calls[m.Topic] = append(tc.calls[m.Topic], string(m.Key))
+
+		return nil, nil
+	}
+}
+
+func (tc *topicCalls) get(topic string) []string {
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+
+	return append([]string(nil), tc.calls[topic]...)
+}
+
+func TestListenTopicsJoinsGroupOnce(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(2)
+	c := fb.NewClient(&pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer:               pKafka.Consumer{GroupID: fakeGroup},
+	})
+
+	var (
+		mx       sync.Mutex
+		assigned []pKafka.Assignment
+	)
+
+	c.SetRebalanceHandler(pKafka.RebalanceFuncs{
+		Assign: func(_ context.Context, a pKafka.Assignment) {
+			mx.Lock()
+			defer mx.Unlock()
+
+			assigned = append(assigned, a)
+		},
+	})
+
+	require.NoError(t, c.SendMessage(bgCtx, "orders", &event.WorkflowData{ID: "order-0"}))
+	require.NoError(t, c.SendMessage(bgCtx, "payments", &event.WorkflowData{ID: "payment-0"}))
+
+	orders, payments := newTopicCalls(), newTopicCalls()
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	c.ListenTopics(ctx, pKafka.Subscription{Topics: []string{"payments", "orders"}}, pKafka.TopicHandlers{
+		"orders":   orders.handler(),
+		"payments": payments.handler(),
+	})
+
+	assert.Eventually(t, func() bool {
+		return len(orders.get("orders")) == 1 && len(payments.get("payments")) == 1
+	}, time.Second, time.Millisecond)
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	require.Len(t, assigned, 2)
+	assert.Equal(t, "orders", assigned[0].Topic)
+	assert.Equal(t, "payments", assigned[1].Topic)
+	assert.Equal(t, assigned[0].MemberID, assigned[1].MemberID)
+	assert.Equal(t, assigned[0].GenerationID, assigned[1].GenerationID)
+	assert.Equal(t, []int{0, 1}, assigned[1].Partitions)
+}
+
+func TestListenTopicsPattern(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	fb.CreateTopic("orders.created", 1)
+	fb.CreateTopic("orders.dlq", 1)
+	fb.CreateTopic("payments", 1)
+
+	c := fb.NewClient(&pKafka.Config{
+		Consumer: pKafka.Consumer{GroupID: fakeGroup, TopicWatchInterval: 10 * time.Millisecond},
+	})
+
+	for _, topic := range []string{"orders.created", "orders.dlq", "payments"} {
+		require.NoError(t, c.SendMessage(bgCtx, topic, &event.WorkflowData{ID: topic + "-0"}))
+	}
+
+	tc := newTopicCalls()
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	c.ListenTopics(ctx, pKafka.Subscription{Pattern: `^orders\.`}, tc.handler())
+
+	assert.Eventually(t, func() bool {
+		return len(tc.get("orders.created")) == 1
+	}, time.Second, time.Millisecond)
+
+	// a new topic is picked up by joining the group again
+	fb.CreateTopic("orders.cancelled", 1)
+	require.NoError(t, c.SendMessage(bgCtx, "orders.cancelled", &event.WorkflowData{ID: "orders.cancelled-0"}))
+
+	assert.Eventually(t, func() bool {
+		return len(tc.get("orders.cancelled")) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		return fb.Committed(fakeGroup, "orders.cancelled", 0) == 1
+	}, time.Second, time.Millisecond)
+
+	// offsets committed before joining again aren't consumed twice
+	assert.Equal(t, []string{"orders.created-0"}, tc.get("orders.created"))
+	assert.Equal(t, int64(1), fb.Committed(fakeGroup, "orders.created", 0))
+	assert.Empty(t, tc.get("orders.dlq"))
+	assert.Empty(t, tc.get("payments"))
+}
+
+func TestListenTopicsInvalidSubscription(t *testing.T) {
+	t.Parallel()
+
+	c := pKafka.NewFakeBroker(1).NewClient(&pKafka.Config{Consumer: pKafka.Consumer{GroupID: fakeGroup}})
+
+	for _, sub := range []pKafka.Subscription{{}, {Pattern: "orders.("}} {
+		select {
+		case err := <-c.ListenTopics(bgCtx, sub, newTopicCalls().handler()):
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatalf("subscription %s is accepted", sub)
+		}
+	}
+}
+
+func TestTopicHandlersUnknownTopic(t *testing.T) {
+	t.Parallel()
+
+	th := pKafka.TopicHandlers{"orders": newTopicCalls().handler()}
+
+	_, err := th.Handle(bgCtx, &kafka.Message{Topic: "payments"})
+	assert.Error(t, err)
+
+	// messages of retry topics are handled by the handler of the original topic
+	_, err = th.Handle(bgCtx, &kafka.Message{
+		Topic:   "orders.retry.30s",
+		Headers: []kafka.Header{{Key: pKafka.HeaderOriginalTopic, Value: []byte("orders")}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestFakeBrokerSyncTopics(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer:               pKafka.Consumer{GroupID: fakeGroup},
+		Retry:                  pKafka.Retry{Delays: []time.Duration{time.Millisecond}},
+	}
+
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+
+	require.NoError(t, p.Publish(bgCtx, "orders", &event.WorkflowData{ID: "order-0"}))
+	require.NoError(t, p.Publish(bgCtx, "payments", &event.WorkflowData{ID: "payment-0"}))
+
+	handled := make(chan string, 3)
+	handler := func(prefix string, fail bool) provider.HandlerWorkflow {
+		var once sync.Once
+
+		return func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) (err error) {
+			handled <- prefix + e.GetID()
+
+			if fail {
+				once.Do(func() {
+					err = errors.New("payment failed")
+				})
+			}
+
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	p.SyncTopics(ctx, pKafka.Subscription{Topics: []string{"orders", "payments"}}, provider.NewRouter().
+		HandleTopic("orders", handler("orders:", false)).
+		HandleTopic("payments", handler("payments:", true)))
+
+	var got []string
+
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-handled:
+			got = append(got, id)
+		case <-time.After(time.Second):
+			t.Fatalf("messages aren't handled: %v", got)
+		}
+	}
+
+	// the failed payment is handled again from the retry topic of the subscription
+	assert.ElementsMatch(t, []string{"orders:order-0", "payments:payment-0", "payments:payment-0"}, got)
+}
+
+func TestFakeBrokerSyncSubscriptionPattern(t *testing.T) {
+	t.Parallel()
+
+	fb := pKafka.NewFakeBroker(1)
+	fb.CreateTopic("orders.created", 1)
+
+	cfg := &pKafka.Config{
+		AllowAutoTopicCreation: true,
+		Consumer: pKafka.Consumer{
+			GroupID:            fakeGroup,
+			ListenPattern:      `^orders\.`,
+			TopicWatchInterval: 10 * time.Millisecond,
+		},
+		Retry: pKafka.Retry{Delays: []time.Duration{time.Millisecond}},
+	}
+
+	p := pKafka.NewKafkaProvider(cfg)
+	p.SetClient(fb.NewClient(cfg))
+
+	require.NoError(t, p.Publish(bgCtx, "orders.created", &event.WorkflowData{ID: "order-0"}))
+
+	handled := make(chan string, 2)
+
+	var once sync.Once
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	require.NoError(t, p.SyncSubscription(ctx, provider.HandlerWorkflow(
+		func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) (err error) {
+			handled <- e.GetID()
+
+			once.Do(func() {
+				err = errors.New("order failed")
+			})
+
+			return err
+		})))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-handled:
+			assert.Equal(t, "order-0", id)
+		case <-time.After(time.Second):
+			t.Fatal("the failed order isn't handled again from the retry topic of the matched topic")
+		}
+	}
+
+	// the retry topic matches the pattern, but isn't subscribed as a topic with retry topics of its own
+	assert.NotContains(t, fb.Topics(), "orders.created.retry.1ms.retry.1ms")
+}
+
+func TestSyncSubscriptionInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, consumer := range []pKafka.Consumer{{GroupID: fakeGroup}, {GroupID: fakeGroup, ListenPattern: "orders.("}} {
+		cfg := &pKafka.Config{Consumer: consumer}
+		p := pKafka.NewKafkaProvider(cfg)
+		p.SetClient(pKafka.NewFakeBroker(1).NewClient(cfg))
+
+		assert.Error(t, p.SyncSubscription(bgCtx, provider.NewRouter()))
+	}
+}
@@ -0,0 +1,21 @@
+package provider
+
+import "kafka-polygon/pkg/broker/event"
+
+// EventKeyer is an event with a business key, e.g. an ID of the workflow.
+// Producers use it as the message key, so events with the same key are kept in order.
+type EventKeyer interface {
+	EventKey() string
+}
+
+// EventKey returns the message key of the event. It's the event ID
+// if the event doesn't implement EventKeyer or its key is empty.
+func EventKey(e event.BaseEvent) string {
+	if ek, ok := e.(EventKeyer); ok {
+		if key := ek.EventKey(); key != "" {
+			return key
+		}
+	}
+
+	return e.GetID()
+}
@@ -2,11 +2,14 @@ package provider
 
 import (
 	"context"
+	"kafka-polygon/pkg/broker/codec"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/broker/store"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/tracing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 )
@@ -22,16 +25,107 @@ type Provider interface {
 	Stop()
 }
 
+// Shutdowner is implemented by providers stopped gracefully: they stop fetching messages and drain in-flight ones
+// until ctx is done. Other providers are stopped by Stop.
+type Shutdowner interface {
+	// Shutdown returns an error describing messages that weren't drained before ctx is done
+	Shutdown(ctx context.Context) error
+}
+
+// Pauser is implemented by providers able to pause consuming of a topic without leaving the consumer group,
+// so partitions aren't rebalanced while the topic is paused. Messages being handled are finished.
+type Pauser interface {
+	Pause(ctx context.Context, topic string)
+	Resume(ctx context.Context, topic string)
+	Paused(topic string) bool
+}
+
+// SubscriptionSyncer is implemented by providers handling messages of several topics configured in the provider,
+// e.g. by a list and a pattern, by one consumer. fn gets messages of all the topics, so it's usually Router.
+type SubscriptionSyncer interface {
+	// SyncSubscription returns an error if the configured subscription is empty or invalid
+	SyncSubscription(ctx context.Context, fn HandlerFn) error
+}
+
+// HealthCheck reports an error while a dependency of handlers is unavailable, e.g. the event store is down
+type HealthCheck func(ctx context.Context) error
+
+// StoreHealthCheck pings the store if it implements store.Pinger. Other stores are always healthy.
+func StoreHealthCheck(s store.Store) HealthCheck {
+	return func(ctx context.Context) error {
+		if p, ok := s.(store.Pinger); ok {
+			return p.Ping(ctx)
+		}
+
+		return nil
+	}
+}
+
+const (
+	defaultLeaseTTL          = 1 * time.Minute
+	defaultLeaseWaitInterval = 1 * time.Second
+	defaultLeaseWaitTimeout  = 30 * time.Second
+)
+
+// LeaseSettings controls how an event is claimed in the store before its handler is called.
+type LeaseSettings struct {
+	// TTL is a time during which other consumers can't take over the claimed event.
+	// If a handler runs longer, another consumer may take the event over and the status of the first run
+	// isn't saved: its writes are rolled back if the store is a TxRunner.
+	TTL time.Duration
+	// WaitInterval is a pause between claim attempts while the event is processed by another consumer
+	WaitInterval time.Duration
+	// WaitTimeout is a maximum time to wait for another consumer to release the event
+	WaitTimeout time.Duration
+}
+
+func (ls *LeaseSettings) initDefault() {
+	if ls.TTL.Milliseconds() == 0 {
+		ls.TTL = defaultLeaseTTL
+	}
+
+	if ls.WaitInterval.Milliseconds() == 0 {
+		ls.WaitInterval = defaultLeaseWaitInterval
+	}
+
+	if ls.WaitTimeout.Milliseconds() == 0 {
+		ls.WaitTimeout = defaultLeaseWaitTimeout
+	}
+}
+
 type HandlerProcessing struct {
 	store store.Store
+	lease LeaseSettings
+	codec codec.Codec
 }
 
 func NewHandlerProcessing(s store.Store) *HandlerProcessing {
-	return &HandlerProcessing{
+	hp := &HandlerProcessing{
 		store: s,
 	}
+	hp.lease.initDefault()
+
+	return hp
+}
+
+// SetLease sets lease settings. Empty values are replaced with defaults.
+func (hp *HandlerProcessing) SetLease(ls LeaseSettings) *HandlerProcessing {
+	ls.initDefault()
+	hp.lease = ls
+
+	return hp
 }
 
+// SetCodec sets a codec decoding messages. Events decode themselves if it's nil.
+func (hp *HandlerProcessing) SetCodec(c codec.Codec) *HandlerProcessing {
+	hp.codec = c
+
+	return hp
+}
+
+// Run decodes the message, claims the event in the store and calls the handler.
+// Events already handled are skipped before the handler is called, so handlers never get EventStatusHandled.
+// The final status is saved only while the lease is held, see LeaseSettings.TTL.
 func (hp *HandlerProcessing) Run(ctx context.Context, fn interface{}, msg event.Message) (event.BaseEvent, error) {
 	f, ok := fn.(HandlerFn)
 	if !ok {
@@ -39,10 +133,14 @@ func (hp *HandlerProcessing) Run(ctx context.Context, fn interface{}, msg event.
 	}
 
 	e := f.GetEventData(ctx)
+	if e == nil {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "no event of the handler %T. event type: %s. topic: %s",
+			fn, EventTypeFromContext(ctx), TopicFromContext(ctx)).LogError()
+	}
 
-	err := e.Unmarshal(msg)
+	err := hp.unmarshal(ctx, msg, e)
 	if err != nil {
-		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+		return nil, err
 	}
 
 	ctx = hp.ctxWithRequestID(ctx, e.GetHeader().RequestID)
@@ -50,49 +148,146 @@ func (hp *HandlerProcessing) Run(ctx context.Context, fn interface{}, msg event.
 	var (
 		eventData store.EventProcessData
 		sErr      error
+		owner     = uuid.NewV4().String()
 	)
 
 	if hp.store != nil {
-		eventData, sErr = hp.store.GetEventInfoByID(ctx, e.GetID())
+		var claimed bool
+
+		eventData, claimed, sErr = hp.claimEvent(ctx, e.GetID(), owner)
 		if sErr != nil {
-			if !cerror.IsNotExist(sErr) {
-				return nil, sErr
+			return nil, sErr
+		}
+
+		if !claimed {
+			_ = cerror.NewF(ctx, cerror.KindExist,
+				"skipped duplicate event. event_id=%s. event_status=%s", e.GetID(), eventData.Status).LogWarn()
+			metrics.DuplicatesSkipped.Inc(TopicFromContext(ctx))
+
+			return e, nil
+		}
+	}
+
+	var statusSaved, leaseLost bool
+
+	if txr, ok := hp.store.(store.TxRunner); ok {
+		// handled status is committed together with handler's writes,
+		// which are rolled back if the lease has been taken over by another consumer
+		err = txr.RunInTx(ctx, func(txCtx context.Context) error {
+			if fnErr := f.CallFn(txCtx, e, eventData); fnErr != nil {
+				return fnErr
 			}
 
-			eventData.Status = store.EventStatusNew
+			released, rErr := hp.store.ReleaseEvent(txCtx, e.GetID(), owner, store.EventStatusHandled)
+			if rErr != nil {
+				return rErr
+			}
 
-			sErr = hp.store.PutEventInfo(ctx, e.GetID(), eventData)
-			if sErr != nil {
-				return nil, sErr
+			if !released {
+				leaseLost = true
+				return hp.leaseLostError(ctx, e.GetID(), store.EventStatusHandled)
 			}
-		}
+
+			return nil
+		})
+		statusSaved = err == nil
+	} else {
+		err = f.CallFn(ctx, e, eventData)
 	}
 
 	newStatus := store.EventStatusHandled
-
-	err = f.CallFn(ctx, e, eventData)
 	if err != nil {
 		newStatus = store.EventStatusHandledWithError
 	}
 
-	if hp.store != nil && eventData.Status != newStatus {
-		eventData.Status = newStatus
-
-		sErr = hp.store.PutEventInfo(ctx, e.GetID(), eventData)
+	if hp.store != nil && !statusSaved && !leaseLost {
+		released, sErr := hp.store.ReleaseEvent(ctx, e.GetID(), owner, newStatus)
 		if sErr != nil {
 			_ = cerror.NewF(ctx, cerror.KindInternal,
 				"couldn't update event status. event_id=%s. old_status=%s. new_status=%s. error=%s",
-				e.GetID(), eventData.Status, newStatus, sErr.Error()).LogError()
+				e.GetID(), store.EventStatusProcessing, newStatus, sErr.Error()).LogError()
+		} else if !released {
+			_ = hp.leaseLostError(ctx, e.GetID(), newStatus)
 		}
 	}
 
 	return e, err
 }
 
+// leaseLostError reports that the status of the event isn't saved
+// because its lease has been taken over by another consumer
+func (hp *HandlerProcessing) leaseLostError(ctx context.Context, id, status string) error {
+	return cerror.NewF(ctx, cerror.KindConflict,
+		"event lease was taken over by another consumer, status isn't saved. event_id=%s. status=%s",
+		id, status).LogWarn()
+}
+
+// unmarshal decodes the message by the codec or by the event itself
+func (hp *HandlerProcessing) unmarshal(ctx context.Context, msg event.Message, e event.BaseEvent) error {
+	if hp.codec != nil {
+		return hp.codec.Decode(ctx, msg, e)
+	}
+
+	if err := e.Unmarshal(msg); err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return nil
+}
+
+// claimEvent tries to claim the event in the store.
+// If the event is being processed by another consumer it waits until the lease is released or expired.
+// The returned bool is false only when the event has already been handled and must be skipped.
+// The returned event data is the data before the claim, so a handler can distinguish retries.
+func (hp *HandlerProcessing) claimEvent(ctx context.Context, id, owner string) (store.EventProcessData, bool, error) {
+	deadline := time.Now().Add(hp.lease.WaitTimeout)
+
+	for {
+		eventData, claimed, err := hp.store.ClaimEvent(ctx, id, owner, hp.lease.TTL)
+		if err != nil {
+			return eventData, false, err
+		}
+
+		if claimed {
+			if eventData.Status == "" {
+				eventData.Status = store.EventStatusNew
+			}
+
+			return eventData, true, nil
+		}
+
+		if eventData.Status == store.EventStatusHandled {
+			return eventData, false, nil
+		}
+
+		if time.Now().After(deadline) {
+			return eventData, false, cerror.NewF(ctx, cerror.KindConflict,
+				"event is being processed by another consumer. event_id=%s. lease_until=%s",
+				id, eventData.LeaseUntil.Format(time.RFC3339Nano)).LogError()
+		}
+
+		wait := hp.lease.WaitInterval
+		if untilExpired := time.Until(eventData.LeaseUntil); untilExpired > 0 && untilExpired < wait {
+			wait = untilExpired
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return eventData, false, cerror.New(ctx, cerror.KindInternal, ctx.Err()).LogError()
+		}
+	}
+}
+
 // ctxWithRequestID creates a new context from a given context
 // and adds requestID value to it.
-// If requestID is empty it will generate a new one.
+// If requestID is empty the request ID of the context is used, e.g. read from record headers,
+// and if there is none it will generate a new one.
 func (hp *HandlerProcessing) ctxWithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		requestID = requestIDFromContext(ctx)
+	}
+
 	if requestID == "" {
 		requestID = uuid.NewV4().String()
 	}
@@ -3,10 +3,13 @@ package provider_test
 import (
 	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/broker/codec"
 	"kafka-polygon/pkg/broker/event"
 	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 )
@@ -29,6 +32,90 @@ func (ms *mockedStore) PutEventInfo(_ context.Context, id string, _ store.EventP
 	return ms.expErr
 }
 
+func (ms *mockedStore) ClaimEvent(
+	_ context.Context, id, _ string, _ time.Duration) (store.EventProcessData, bool, error) {
+	assert.Equal(ms.t, e.ID, id)
+
+	return store.EventProcessData{Status: store.EventStatusNew}, true, ms.expErr
+}
+
+func (ms *mockedStore) ReleaseEvent(_ context.Context, id, _, _ string) (bool, error) {
+	assert.Equal(ms.t, e.ID, id)
+
+	return true, ms.expErr
+}
+
+type claimStore struct {
+	mockedStore
+	claimEventFunc func(attempt int) (store.EventProcessData, bool, error)
+	attempts       int
+	owner          string
+	takenOver      bool
+	putData        []store.EventProcessData
+}
+
+func (cs *claimStore) PutEventInfo(_ context.Context, _ string, data store.EventProcessData) error {
+	cs.putData = append(cs.putData, data)
+
+	return nil
+}
+
+func (cs *claimStore) ClaimEvent(
+	_ context.Context, _, owner string, _ time.Duration) (store.EventProcessData, bool, error) {
+	cs.attempts++
+
+	prev, claimed, err := cs.claimEventFunc(cs.attempts)
+	if claimed {
+		cs.owner = owner
+	}
+
+	return prev, claimed, err
+}
+
+func (cs *claimStore) ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error) {
+	if cs.takenOver || owner != cs.owner {
+		return false, nil
+	}
+
+	return true, cs.PutEventInfo(ctx, id, store.EventProcessData{Status: status})
+}
+
+type txKey struct{}
+
+type txClaimStore struct {
+	claimStore
+	committed bool
+	txPutData []store.EventProcessData
+}
+
+func (ts *txClaimStore) PutEventInfo(ctx context.Context, id string, data store.EventProcessData) error {
+	if ctx.Value(txKey{}) != nil {
+		ts.txPutData = append(ts.txPutData, data)
+		return nil
+	}
+
+	return ts.claimStore.PutEventInfo(ctx, id, data)
+}
+
+func (ts *txClaimStore) ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error) {
+	if ts.takenOver || owner != ts.owner {
+		return false, nil
+	}
+
+	return true, ts.PutEventInfo(ctx, id, store.EventProcessData{Status: status})
+}
+
+func (ts *txClaimStore) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		ts.txPutData = nil
+		return err
+	}
+
+	ts.committed = true
+
+	return nil
+}
+
 var (
 	buff buffWriter
 	e    = event.WorkflowData{
@@ -97,3 +184,215 @@ func TestHandlerProcessingError(t *testing.T) {
 		assert.Equal(t, errEmpty.Error(), err.Error())
 	}
 }
+
+func TestHandlerProcessingSkipHandled(t *testing.T) {
+	t.Parallel()
+
+	cs := &claimStore{
+		claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+			return store.EventProcessData{Status: store.EventStatusHandled}, false, nil
+		},
+	}
+	isCalled := false
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		isCalled = true
+		return nil
+	})
+
+	_, err := provider.NewHandlerProcessing(cs).Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.NoError(t, err)
+	assert.False(t, isCalled)
+	assert.Equal(t, 1, cs.attempts)
+	assert.Empty(t, cs.putData)
+}
+
+func TestHandlerProcessingTakeOverExpiredLease(t *testing.T) {
+	t.Parallel()
+
+	cs := &claimStore{
+		claimEventFunc: func(attempt int) (store.EventProcessData, bool, error) {
+			if attempt < 3 {
+				return store.EventProcessData{
+					Status:     store.EventStatusProcessing,
+					LeaseUntil: time.Now().Add(time.Minute),
+				}, false, nil
+			}
+
+			return store.EventProcessData{Status: store.EventStatusProcessing}, true, nil
+		},
+	}
+
+	var actData store.EventProcessData
+
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, ed store.EventProcessData) error {
+		actData = ed
+		return nil
+	})
+
+	hp := provider.NewHandlerProcessing(cs).SetLease(provider.LeaseSettings{
+		WaitInterval: time.Millisecond,
+	})
+
+	_, err := hp.Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cs.attempts)
+	assert.Equal(t, store.EventStatusProcessing, actData.Status)
+	assert.Equal(t, []store.EventProcessData{{Status: store.EventStatusHandled}}, cs.putData)
+}
+
+func TestHandlerProcessingLeaseWaitTimeout(t *testing.T) {
+	t.Parallel()
+
+	cs := &claimStore{
+		claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+			return store.EventProcessData{
+				Status:     store.EventStatusProcessing,
+				LeaseUntil: time.Now().Add(time.Minute),
+			}, false, nil
+		},
+	}
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		t.Fatal("handler must not be called")
+		return nil
+	})
+
+	hp := provider.NewHandlerProcessing(cs).SetLease(provider.LeaseSettings{
+		WaitInterval: time.Millisecond,
+		WaitTimeout:  5 * time.Millisecond,
+	})
+
+	_, err := hp.Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.Error(t, err)
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(err))
+}
+
+func TestHandlerProcessingTxStore(t *testing.T) {
+	t.Parallel()
+
+	ts := &txClaimStore{
+		claimStore: claimStore{
+			claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+				return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+			},
+		},
+	}
+	inTx := false
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		inTx = ctx.Value(txKey{}) != nil
+		return nil
+	})
+
+	_, err := provider.NewHandlerProcessing(ts).Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.NoError(t, err)
+	assert.True(t, inTx)
+	assert.True(t, ts.committed)
+	assert.Equal(t, []store.EventProcessData{{Status: store.EventStatusHandled}}, ts.txPutData)
+	assert.Empty(t, ts.putData)
+}
+
+func TestHandlerProcessingTxStoreRollback(t *testing.T) {
+	t.Parallel()
+
+	ts := &txClaimStore{
+		claimStore: claimStore{
+			claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+				return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+			},
+		},
+	}
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		return errEmpty
+	})
+
+	_, err := provider.NewHandlerProcessing(ts).Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.Error(t, err)
+	assert.Equal(t, errEmpty.Error(), err.Error())
+	assert.False(t, ts.committed)
+	assert.Empty(t, ts.txPutData)
+	assert.Equal(t, []store.EventProcessData{{Status: store.EventStatusHandledWithError}}, ts.putData)
+}
+
+func TestHandlerProcessingLeaseTakenOver(t *testing.T) {
+	t.Parallel()
+
+	cs := &claimStore{
+		claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+			return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+		},
+		takenOver: true,
+	}
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		return errEmpty
+	})
+
+	// the status of another consumer isn't overwritten
+	_, err := provider.NewHandlerProcessing(cs).Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.Error(t, err)
+	assert.Equal(t, errEmpty.Error(), err.Error())
+	assert.Empty(t, cs.putData)
+}
+
+func TestHandlerProcessingTxStoreLeaseTakenOver(t *testing.T) {
+	t.Parallel()
+
+	ts := &txClaimStore{
+		claimStore: claimStore{
+			claimEventFunc: func(_ int) (store.EventProcessData, bool, error) {
+				return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+			},
+			takenOver: true,
+		},
+	}
+	fn := provider.HandlerWorkflow(func(ctx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		return nil
+	})
+
+	// handler's writes are rolled back
+	_, err := provider.NewHandlerProcessing(ts).Run(_bgCtx, fn, event.Message{Value: e.ToByte()})
+	assert.Error(t, err)
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(err))
+	assert.False(t, ts.committed)
+	assert.Empty(t, ts.txPutData)
+	assert.Empty(t, ts.putData)
+}
+
+func TestHandlerProcessingCodec(t *testing.T) {
+	t.Parallel()
+
+	var got string
+
+	fn := provider.HandlerWorkflow(func(_ context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		got = we.GetID()
+
+		return nil
+	})
+
+	// a value in the wire format can't be unmarshaled by the event itself
+	value := append([]byte{0, 0, 0, 0, 1}, (&event.WorkflowData{ID: "framed"}).ToByte()...)
+
+	_, err := provider.NewHandlerProcessing(nil).Run(_bgCtx, fn, event.Message{Value: value})
+	assert.Error(t, err)
+
+	_, err = provider.NewHandlerProcessing(nil).SetCodec(codec.NewJSON(nil)).Run(_bgCtx, fn, event.Message{Value: value})
+	assert.NoError(t, err)
+	assert.Equal(t, "framed", got)
+}
+
+type pingedStore struct {
+	mockedStore
+	pingErr error
+}
+
+func (ps *pingedStore) Ping(_ context.Context) error {
+	return ps.pingErr
+}
+
+func TestStoreHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, provider.StoreHealthCheck(&mockedStore{t: t})(_bgCtx))
+	assert.NoError(t, provider.StoreHealthCheck(&pingedStore{mockedStore: mockedStore{t: t}})(_bgCtx))
+
+	pingErr := cerror.NewF(_bgCtx, cerror.KindDBOther, "connection refused")
+	assert.Equal(t, pingErr, provider.StoreHealthCheck(&pingedStore{mockedStore: mockedStore{t: t}, pingErr: pingErr})(_bgCtx))
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
+)
+
+type (
+	topicKey     struct{}
+	eventTypeKey struct{}
+)
+
+// EventTyper is an event with a name of its type written to the event type header, e.g. event.TypeWorkflow.
+// Names are a part of the contract between producers and consumers, so they don't depend on names of Go types.
+type EventTyper interface {
+	EventType() string
+}
+
+// EventType returns a name of the event type or an empty string if the event doesn't implement EventTyper
+func EventType(e event.BaseEvent) string {
+	if et, ok := e.(EventTyper); ok {
+		return et.EventType()
+	}
+
+	return ""
+}
+
+// WithTopic returns ctx with the topic of the consumed message
+func WithTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, topicKey{}, topic)
+}
+
+// TopicFromContext returns the topic of the consumed message
+func TopicFromContext(ctx context.Context) string {
+	topic, _ := ctx.Value(topicKey{}).(string)
+
+	return topic
+}
+
+// EventTypeFromContext returns the event type read from record headers of the consumed message
+func EventTypeFromContext(ctx context.Context) string {
+	eventType, _ := ctx.Value(eventTypeKey{}).(string)
+
+	return eventType
+}
+
+// Router routes messages to handlers of their event types, so one Watch call handles several event types.
+// A message is routed by its event type header first and by its topic if there is no handler of the type.
+// A message without a route isn't handled and fails with an error.
+type Router struct {
+	byType  map[string]HandlerFn
+	byTopic map[string]HandlerFn
+}
+
+func NewRouter() *Router {
+	return &Router{
+		byType:  make(map[string]HandlerFn),
+		byTopic: make(map[string]HandlerFn),
+	}
+}
+
+// Handle routes messages of the event type of the handler to it.
+// It panics if the event of the handler doesn't implement EventTyper, use HandleType for such events.
+func (r *Router) Handle(h HandlerFn) *Router {
+	e := h.GetEventData(context.Background())
+
+	eventType := EventType(e)
+	if eventType == "" {
+		panic(fmt.Sprintf("event %T of the handler has no event type", e))
+	}
+
+	return r.HandleType(eventType, h)
+}
+
+// HandleType routes messages of the event type to the handler
+func (r *Router) HandleType(eventType string, h HandlerFn) *Router {
+	r.byType[eventType] = h
+
+	return r
+}
+
+// HandleTopic routes messages of the topic without a handler of their event type to the handler
+func (r *Router) HandleTopic(topic string, h HandlerFn) *Router {
+	r.byTopic[topic] = h
+
+	return r
+}
+
+// GetEventData returns an event of the handler of the message or nil if there is no route
+func (r *Router) GetEventData(ctx context.Context) event.BaseEvent {
+	h, ok := r.route(ctx)
+	if !ok {
+		return nil
+	}
+
+	return h.GetEventData(ctx)
+}
+
+func (r *Router) CallFn(reqCtx context.Context, e interface{}, eventData store.EventProcessData) error {
+	h, ok := r.route(reqCtx)
+	if !ok {
+		return cerror.NewF(reqCtx, cerror.KindInternal, "no handler of event type %s of topic %s",
+			EventTypeFromContext(reqCtx), TopicFromContext(reqCtx)).LogError()
+	}
+
+	return h.CallFn(reqCtx, e, eventData)
+}
+
+func (r *Router) route(ctx context.Context) (HandlerFn, bool) {
+	if h, ok := r.byType[EventTypeFromContext(ctx)]; ok {
+		return h, true
+	}
+
+	h, ok := r.byTopic[TopicFromContext(ctx)]
+
+	return h, ok
+}
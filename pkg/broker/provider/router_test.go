@@ -0,0 +1,121 @@
+package provider_test
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/store"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func newTestRouter(handled *[]string) *provider.Router {
+	return provider.NewRouter().
+		Handle(provider.HandlerWorkflow(func(_ context.Context, e event.WorkflowEvent, _ store.EventProcessData) error {
+			*handled = append(*handled, "workflow:"+e.GetID())
+
+			return nil
+		})).
+		Handle(provider.HandlerNotification(func(_ context.Context, e event.NotificationEvent, _ store.EventProcessData) error {
+			*handled = append(*handled, "notification:"+e.GetID())
+
+			return nil
+		})).
+		HandleTopic("minio", provider.HandlerMinio(func(_ context.Context, e event.MinioEvent, _ store.EventProcessData) error {
+			*handled = append(*handled, "minio:"+e.GetID())
+
+			return nil
+		}))
+}
+
+// untypedEvent is an event without EventTyper
+type untypedEvent struct {
+	event.BaseEvent
+}
+
+func TestEventType(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, event.TypeWorkflow, provider.EventType(&event.WorkflowData{}))
+	assert.Equal(t, event.TypeNotification, provider.EventType(&event.NotificationData{}))
+	assert.Equal(t, event.TypeWorkflow, provider.RecordHeaders(_bgCtx, &event.WorkflowData{})[provider.HeaderEventType])
+
+	untyped := &untypedEvent{BaseEvent: &event.WorkflowData{}}
+	assert.Equal(t, "", provider.EventType(untyped))
+	assert.NotContains(t, provider.RecordHeaders(_bgCtx, untyped), provider.HeaderEventType)
+}
+
+func TestRouterHandleType(t *testing.T) {
+	t.Parallel()
+
+	var handled []string
+
+	h := provider.Handle(func(_ context.Context) *untypedEvent {
+		return &untypedEvent{BaseEvent: &event.WorkflowData{}}
+	}, func(_ context.Context, e *untypedEvent, _ store.EventProcessData) error {
+		handled = append(handled, "untyped:"+e.GetID())
+
+		return nil
+	})
+
+	// events without EventTyper are routed by registered names only
+	assert.Panics(t, func() { provider.NewRouter().Handle(h) })
+
+	r := provider.NewRouter().HandleType("untyped", h)
+	ctx := provider.ContextFromHeaders(_bgCtx, map[string]string{provider.HeaderEventType: "untyped"})
+
+	_, err := provider.NewHandlerProcessing(nil).Run(ctx, r, event.Message{Value: []byte(`{"id":"untyped-id"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"untyped:untyped-id"}, handled)
+}
+
+func TestRouterByEventType(t *testing.T) {
+	t.Parallel()
+
+	var handled []string
+
+	r := newTestRouter(&handled)
+	hp := provider.NewHandlerProcessing(nil)
+
+	for _, e := range []event.BaseEvent{
+		&event.WorkflowData{ID: "wf-id"},
+		&event.NotificationData{ID: "notify-id"},
+	} {
+		ctx := provider.WithTopic(provider.ContextFromHeaders(_bgCtx, provider.RecordHeaders(_bgCtx, e)), "events")
+
+		_, err := hp.Run(ctx, r, event.Message{Value: e.ToByte()})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"workflow:wf-id", "notification:notify-id"}, handled)
+}
+
+func TestRouterByTopic(t *testing.T) {
+	t.Parallel()
+
+	var handled []string
+
+	r := newTestRouter(&handled)
+	e := &event.MinioData{Key: "bucket/key"}
+
+	_, err := provider.NewHandlerProcessing(nil).Run(provider.WithTopic(_bgCtx, "minio"), r, event.Message{Value: e.ToByte()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"minio:" + e.GetID()}, handled)
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	t.Parallel()
+
+	var handled []string
+
+	r := newTestRouter(&handled)
+	e := &event.MinioData{Key: "bucket/key"}
+
+	_, err := provider.NewHandlerProcessing(nil).Run(provider.WithTopic(_bgCtx, "unknown"), r, event.Message{Value: e.ToByte()})
+	require.Error(t, err)
+	assert.Empty(t, handled)
+
+	assert.Error(t, r.CallFn(_bgCtx, e, store.EventProcessData{}))
+}
@@ -0,0 +1,395 @@
+package sarama
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"kafka-polygon/pkg/broker/codec"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/cerror"
+	"os"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	securityProtocolSCRAM    = "SCRAM"
+	securityProtocolPlain    = "PLAIN"
+	saslSCRAMAlgorithmSHA512 = "SCRAM-SHA-512"
+
+	offsetOldest = "oldest"
+	offsetNewest = "newest"
+
+	rebalanceStrategyRange      = "range"
+	rebalanceStrategyRoundRobin = "roundrobin"
+	rebalanceStrategySticky     = "sticky"
+
+	defaultVersion    = "2.8.0"
+	defaultRerunDelay = 30 * time.Second
+
+	consumerSessionTimeout    = 30 * time.Second
+	consumerHeartbeatInterval = 3 * time.Second
+	consumerRebalanceTimeout  = 30 * time.Second
+
+	producerMaxRetry     = 10
+	producerRetryBackoff = 100 * time.Millisecond
+	producerTimeout      = 10 * time.Second
+
+	transactionIDPrefix     = "kafka-polygon"
+	transactionTimeout      = 1 * time.Minute
+	transactionRetryMax     = 50
+	transactionRetryBackoff = 100 * time.Millisecond
+)
+
+type Consumer struct {
+	GroupID string
+	// InitialOffset is used if the group has no committed offset: oldest or newest
+	InitialOffset string
+	// RebalanceStrategy is one of: sticky, roundrobin, range
+	RebalanceStrategy string
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+	RebalanceTimeout  time.Duration
+	CommitOnError     bool
+	// MinBytes, MaxBytes and MaxWait tune fetch requests. Sarama defaults are used for zero values
+	MinBytes int
+	MaxBytes int
+	MaxWait  time.Duration
+}
+
+func (c *Consumer) initDefault() {
+	if c.InitialOffset == "" {
+		c.InitialOffset = offsetOldest
+	}
+
+	if c.RebalanceStrategy == "" {
+		c.RebalanceStrategy = rebalanceStrategySticky
+	}
+
+	if c.SessionTimeout.Seconds() == 0 {
+		c.SessionTimeout = consumerSessionTimeout
+	}
+
+	if c.HeartbeatInterval.Seconds() == 0 {
+		c.HeartbeatInterval = consumerHeartbeatInterval
+	}
+
+	if c.RebalanceTimeout.Seconds() == 0 {
+		c.RebalanceTimeout = consumerRebalanceTimeout
+	}
+}
+
+type Producer struct {
+	MaxRetry     int
+	RetryBackoff time.Duration
+	Timeout      time.Duration
+	// RequiredAcks is one of: none, one, all. It's ignored by transactional producers which always wait for all
+	RequiredAcks kafka.RequiredAcks
+	// FlushMessages and FlushFrequency tune batching. Sarama defaults are used for zero values
+	FlushMessages  int
+	FlushFrequency time.Duration
+	// Idempotent makes the broker drop duplicates written by retries of the producer. All acks are required then
+	Idempotent bool
+}
+
+func (p *Producer) initDefault() {
+	if p.MaxRetry == 0 {
+		p.MaxRetry = producerMaxRetry
+	}
+
+	if p.RetryBackoff.Milliseconds() == 0 {
+		p.RetryBackoff = producerRetryBackoff
+	}
+
+	if p.Timeout.Seconds() == 0 {
+		p.Timeout = producerTimeout
+	}
+}
+
+type Transaction struct {
+	// IDPrefix is a prefix of transactional ids. Every consumed partition gets its own producer
+	// with id <IDPrefix>-<topic>-<partition>, so a zombie instance which lost the partition
+	// is fenced by the broker. IDPrefix must be the same for all instances of the service.
+	IDPrefix     string
+	Timeout      time.Duration
+	RetryMax     int
+	RetryBackoff time.Duration
+}
+
+func (t *Transaction) initDefault() {
+	if t.IDPrefix == "" {
+		t.IDPrefix = transactionIDPrefix
+	}
+
+	if t.Timeout.Seconds() == 0 {
+		t.Timeout = transactionTimeout
+	}
+
+	if t.RetryMax == 0 {
+		t.RetryMax = transactionRetryMax
+	}
+
+	if t.RetryBackoff.Milliseconds() == 0 {
+		t.RetryBackoff = transactionRetryBackoff
+	}
+}
+
+type Config struct {
+	Brokers                    []string
+	Version                    string
+	ClientID                   string
+	LoggerEnabled              bool
+	CommitOnErrorMessagesCount int
+	RerunDelay                 time.Duration
+	TLS                        kafka.TLS
+	SASL                       kafka.SASL
+	UseKeyDoubleQuote          bool
+	Consumer                   Consumer
+	Producer                   Producer
+	Transaction                Transaction
+	// EventLease is used to claim consumed events in the store before handling
+	EventLease provider.LeaseSettings
+	// Codec encodes published events and decodes consumed ones. Events encode themselves to JSON if it's nil
+	Codec codec.Codec
+}
+
+// configFromKafka maps the config of the segmentio provider onto the sarama config,
+// so the same config is accepted by both providers
+func configFromKafka(kc *kafka.Config) *Config {
+	if kc == nil {
+		return &Config{}
+	}
+
+	return &Config{
+		Brokers:                    kc.Brokers,
+		LoggerEnabled:              kc.LoggerEnabled,
+		CommitOnErrorMessagesCount: kc.CommitOnErrorMessagesCount,
+		RerunDelay:                 kc.RerunDelay,
+		TLS:                        kc.TLS,
+		SASL:                       kc.SASL,
+		UseKeyDoubleQuote:          kc.UseKeyDoubleQuote,
+		Consumer: Consumer{
+			GroupID:           kc.Consumer.GroupID,
+			SessionTimeout:    kc.Consumer.SessionTimeout,
+			HeartbeatInterval: kc.Consumer.HeartbeatInterval,
+			RebalanceTimeout:  kc.Consumer.RebalanceTimeout,
+			CommitOnError:     kc.Consumer.CommitOnError,
+			MinBytes:          kc.Consumer.MinBytes,
+			MaxBytes:          kc.Consumer.MaxBytes,
+			MaxWait:           kc.Consumer.MaxWait,
+		},
+		Producer: Producer{
+			MaxRetry:       kc.Producer.MaxRetry,
+			RetryBackoff:   kc.Producer.MaxAttemptsDelay,
+			Timeout:        kc.Producer.WriteTimeout,
+			RequiredAcks:   kc.Producer.RequiredAcks,
+			FlushMessages:  kc.Producer.WriterBatchSize,
+			FlushFrequency: kc.Producer.BatchTimeout,
+		},
+		EventLease: kc.EventLease,
+		Codec:      kc.Codec,
+	}
+}
+
+func (c *Config) defaults() {
+	c.Consumer.initDefault()
+	c.Producer.initDefault()
+	c.Transaction.initDefault()
+
+	if c.Version == "" {
+		c.Version = defaultVersion
+	}
+
+	if c.RerunDelay.Seconds() == 0 {
+		c.RerunDelay = defaultRerunDelay
+	}
+}
+
+// newSaramaConfig returns a sarama config. If txID is not empty the producer is transactional.
+func (c *Config) newSaramaConfig(ctx context.Context, txID string) (*goSarama.Config, error) {
+	version, err := goSarama.ParseKafkaVersion(c.Version)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindKafkaOther, "[sarama] parse kafka version %s. %s", c.Version, err.Error()).
+			LogError()
+	}
+
+	cfg := goSarama.NewConfig()
+	cfg.Version = version
+
+	if c.ClientID != "" {
+		cfg.ClientID = c.ClientID
+	}
+
+	cfg.Consumer.Group.Session.Timeout = c.Consumer.SessionTimeout
+	cfg.Consumer.Group.Heartbeat.Interval = c.Consumer.HeartbeatInterval
+	cfg.Consumer.Group.Rebalance.Timeout = c.Consumer.RebalanceTimeout
+	cfg.Consumer.Group.Rebalance.GroupStrategies = []goSarama.BalanceStrategy{c.balanceStrategy()}
+	cfg.Consumer.Offsets.Initial = goSarama.OffsetOldest
+
+	if c.Consumer.InitialOffset == offsetNewest {
+		cfg.Consumer.Offsets.Initial = goSarama.OffsetNewest
+	}
+
+	// offsets are committed explicitly after the message is handled
+	cfg.Consumer.Offsets.AutoCommit.Enable = false
+	cfg.Consumer.IsolationLevel = goSarama.ReadCommitted
+
+	if c.Consumer.MinBytes > 0 {
+		cfg.Consumer.Fetch.Min = int32(c.Consumer.MinBytes)
+	}
+
+	if c.Consumer.MaxBytes > 0 {
+		cfg.Consumer.Fetch.Max = int32(c.Consumer.MaxBytes)
+	}
+
+	if c.Consumer.MaxWait > 0 {
+		cfg.Consumer.MaxWaitTime = c.Consumer.MaxWait
+	}
+
+	cfg.Producer.Retry.Max = c.Producer.MaxRetry
+	cfg.Producer.Retry.Backoff = c.Producer.RetryBackoff
+	cfg.Producer.Timeout = c.Producer.Timeout
+	cfg.Producer.RequiredAcks = c.requiredAcks()
+	cfg.Producer.Flush.Messages = c.Producer.FlushMessages
+	cfg.Producer.Flush.Frequency = c.Producer.FlushFrequency
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+
+	if c.Producer.Idempotent {
+		cfg.Producer.RequiredAcks = goSarama.WaitForAll
+		cfg.Producer.Idempotent = true
+		cfg.Net.MaxOpenRequests = 1
+	}
+
+	if txID != "" {
+		cfg.Producer.RequiredAcks = goSarama.WaitForAll
+		cfg.Producer.Idempotent = true
+		cfg.Producer.Transaction.ID = txID
+		cfg.Producer.Transaction.Timeout = c.Transaction.Timeout
+		cfg.Producer.Transaction.Retry.Max = c.Transaction.RetryMax
+		cfg.Producer.Transaction.Retry.Backoff = c.Transaction.RetryBackoff
+		cfg.Net.MaxOpenRequests = 1
+	}
+
+	if err := c.setTLS(cfg); err != nil {
+		return nil, cerror.NewF(ctx, cerror.KindKafkaOther, "[sarama] TLS config. %s", err.Error()).LogError()
+	}
+
+	c.setSASL(cfg)
+
+	return cfg, nil
+}
+
+func (c *Config) requiredAcks() goSarama.RequiredAcks {
+	switch c.Producer.RequiredAcks {
+	case "none":
+		return goSarama.NoResponse
+	case "one":
+		return goSarama.WaitForLocal
+	default:
+		return goSarama.WaitForAll
+	}
+}
+
+func (c *Config) balanceStrategy() goSarama.BalanceStrategy {
+	switch c.Consumer.RebalanceStrategy {
+	case rebalanceStrategyRange:
+		return goSarama.BalanceStrategyRange
+	case rebalanceStrategyRoundRobin:
+		return goSarama.BalanceStrategyRoundRobin
+	default:
+		return goSarama.BalanceStrategySticky
+	}
+}
+
+func (c *Config) setTLS(cfg *goSarama.Config) error {
+	if !c.TLS.Enabled {
+		return nil
+	}
+
+	cfgTLS := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if c.TLS.ClientCertFile != "" && c.TLS.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.ClientCertFile, c.TLS.ClientKeyFile)
+		if err != nil {
+			return err
+		}
+
+		cfgTLS.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.TLS.RootCACertFile != "" {
+		caCert, err := os.ReadFile(c.TLS.RootCACertFile)
+		if err != nil {
+			return err
+		}
+
+		caCertPool := x509.NewCertPool()
+		caCertPool.AppendCertsFromPEM(caCert)
+
+		cfgTLS.RootCAs = caCertPool
+	}
+
+	cfg.Net.TLS.Enable = true
+	cfg.Net.TLS.Config = cfgTLS
+
+	return nil
+}
+
+func (c *Config) setSASL(cfg *goSarama.Config) {
+	switch c.SASL.SecurityProtocol {
+	case securityProtocolPlain:
+		cfg.Net.SASL.Mechanism = goSarama.SASLTypePlaintext
+	case securityProtocolSCRAM:
+		if c.SASL.Algorithm == saslSCRAMAlgorithmSHA512 {
+			cfg.Net.SASL.Mechanism = goSarama.SASLTypeSCRAMSHA512
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() goSarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: sha512.New}
+			}
+		} else {
+			cfg.Net.SASL.Mechanism = goSarama.SASLTypeSCRAMSHA256
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() goSarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: sha256.New}
+			}
+		}
+	default:
+		return
+	}
+
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.User = c.SASL.Username
+	cfg.Net.SASL.Password = c.SASL.Password
+}
+
+// scramClient implements sarama.SCRAMClient
+type scramClient struct {
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func (sc *scramClient) Begin(userName, password, authzID string) error {
+	client, err := sc.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+
+	sc.ClientConversation = client.NewConversation()
+
+	return nil
+}
+
+func (sc *scramClient) Step(challenge string) (string, error) {
+	return sc.ClientConversation.Step(challenge)
+}
+
+func (sc *scramClient) Done() bool {
+	return sc.ClientConversation.Done()
+}
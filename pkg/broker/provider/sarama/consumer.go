@@ -0,0 +1,175 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/log"
+	"sync"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+)
+
+// ConsumerGroupFactory creates a consumer group client
+type ConsumerGroupFactory func(ctx context.Context) (goSarama.ConsumerGroup, error)
+
+// consumerGroups runs consumer groups of a provider and closes them on stop
+type consumerGroups struct {
+	rerunDelay       time.Duration
+	newConsumerGroup ConsumerGroupFactory
+	mx               sync.Mutex
+	groups           []goSarama.ConsumerGroup
+	wg               sync.WaitGroup
+}
+
+// run consumes the topic in a separate goroutine
+func (cg *consumerGroups) run(ctx context.Context, topic string, h goSarama.ConsumerGroupHandler) {
+	cg.wg.Add(1)
+
+	go func() {
+		defer cg.wg.Done()
+
+		cg.consume(ctx, topic, h)
+	}()
+}
+
+// stop closes all groups and waits for their consumers to finish
+func (cg *consumerGroups) stop() {
+	cg.mx.Lock()
+	groups := cg.groups
+	cg.groups = nil
+	cg.mx.Unlock()
+
+	for _, g := range groups {
+		if err := g.Close(); err != nil {
+			_ = cerror.NewF(context.Background(), cerror.KafkaToKind(err),
+				"[sarama] consumer group close. %s", err.Error()).LogError()
+		}
+	}
+
+	cg.wg.Wait()
+}
+
+// consume joins the group and consumes the topic until the group is closed or ctx is done
+func (cg *consumerGroups) consume(ctx context.Context, topic string, h goSarama.ConsumerGroupHandler) {
+	var group goSarama.ConsumerGroup
+
+	for group == nil {
+		g, err := cg.newConsumerGroup(ctx)
+		if err == nil {
+			group = g
+			break
+		}
+
+		if !cg.waitRerun(ctx) {
+			return
+		}
+	}
+
+	cg.mx.Lock()
+	cg.groups = append(cg.groups, group)
+	cg.mx.Unlock()
+
+	log.DebugF(ctx, "[sarama] start listening kafka topic [%s]", topic)
+
+	for {
+		err := group.Consume(ctx, []string{topic}, h)
+		if errors.Is(err, goSarama.ErrClosedConsumerGroup) || ctx.Err() != nil {
+			log.DebugF(ctx, "[sarama] consumer for topic %s stopped", topic)
+			return
+		}
+
+		if err != nil {
+			_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+				"[sarama] consumer for topic = %s stopped. %s", topic, err.Error()).LogError()
+
+			if !cg.waitRerun(ctx) {
+				return
+			}
+		}
+	}
+}
+
+func (cg *consumerGroups) waitRerun(ctx context.Context) bool {
+	select {
+	case <-time.After(cg.rerunDelay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryInPlace calls process until it succeeds or the session is ended, waiting delay between the calls.
+// A failed message is retried within the claim, so the partition isn't consumed past it
+// and doesn't wait for the next rebalance. It returns false if the session is ended before the message is processed.
+func retryInPlace(s goSarama.ConsumerGroupSession, delay time.Duration, process func() error) bool {
+	for process() != nil {
+		select {
+		case <-time.After(delay):
+		case <-s.Context().Done():
+			return false
+		}
+	}
+
+	return true
+}
+
+func logSessionSetup(ctx context.Context, s goSarama.ConsumerGroupSession) {
+	log.DebugF(ctx, "[sarama] session started. member: %s. generation: %d. claims: %v",
+		s.MemberID(), s.GenerationID(), s.Claims())
+}
+
+func logSessionCleanup(ctx context.Context, s goSarama.ConsumerGroupSession) {
+	log.DebugF(ctx, "[sarama] session finished. member: %s. generation: %d", s.MemberID(), s.GenerationID())
+}
+
+// topicExists checks the topic in the cluster metadata
+func topicExists(ctx context.Context, c *Config, topic string) (bool, error) {
+	cfg, err := c.newSaramaConfig(ctx, "")
+	if err != nil {
+		return false, err
+	}
+
+	cl, err := goSarama.NewClient(c.Brokers, cfg)
+	if err != nil {
+		return false, cerror.NewF(ctx, cerror.KafkaToKind(err), "[sarama] GetIsTopicExists new client. %s", err.Error()).
+			LogError()
+	}
+
+	defer func() {
+		if errCl := cl.Close(); errCl != nil {
+			_ = cerror.NewF(ctx, cerror.KafkaToKind(errCl), "[sarama] GetIsTopicExists client.Close. %s", errCl.Error()).
+				LogError()
+		}
+	}()
+
+	topics, err := cl.Topics()
+	if err != nil {
+		return false, cerror.NewF(ctx, cerror.KafkaToKind(err), "[sarama] GetIsTopicExists client.Topics. %s", err.Error()).
+			LogError()
+	}
+
+	for i := range topics {
+		if topics[i] == topic {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func newConsumerGroup(ctx context.Context, c *Config) (goSarama.ConsumerGroup, error) {
+	cfg, err := c.newSaramaConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := goSarama.NewConsumerGroup(c.Brokers, c.Consumer.GroupID, cfg)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] new consumer group %s. %s", c.Consumer.GroupID, err.Error()).LogError()
+	}
+
+	return g, nil
+}
@@ -0,0 +1,68 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"sort"
+
+	goSarama "github.com/Shopify/sarama"
+)
+
+// newProducerMessage returns the message of the event encoded by the codec of the config
+// with request ID, trace context and metadata headers
+func newProducerMessage(ctx context.Context, cfg *Config, topic string, e event.BaseEvent) (*goSarama.ProducerMessage, error) {
+	key := provider.EventKey(e)
+	if cfg.UseKeyDoubleQuote {
+		key = fmt.Sprintf("%q", key)
+	}
+
+	value := e.ToByte()
+
+	if cfg.Codec != nil {
+		var err error
+
+		if value, err = cfg.Codec.Encode(ctx, topic, e); err != nil {
+			return nil, err
+		}
+	}
+
+	return &goSarama.ProducerMessage{
+		Topic:   topic,
+		Key:     goSarama.StringEncoder(key),
+		Value:   goSarama.ByteEncoder(value),
+		Headers: recordHeaders(ctx, e),
+	}, nil
+}
+
+// recordHeaders returns request ID, trace context and metadata headers of the event sorted by key
+func recordHeaders(ctx context.Context, e event.BaseEvent) []goSarama.RecordHeader {
+	hm := provider.RecordHeaders(ctx, e)
+
+	keys := make([]string, 0, len(hm))
+	for k := range hm {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	headers := make([]goSarama.RecordHeader, len(keys))
+	for i, k := range keys {
+		headers[i] = goSarama.RecordHeader{Key: []byte(k), Value: []byte(hm[k])}
+	}
+
+	return headers
+}
+
+// messageContext returns ctx with the topic of the consumed message and the values of its headers
+func messageContext(ctx context.Context, msg *goSarama.ConsumerMessage) context.Context {
+	hm := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if h != nil {
+			hm[string(h.Key)] = string(h.Value)
+		}
+	}
+
+	return provider.WithTopic(provider.ContextFromHeaders(ctx, hm), msg.Topic)
+}
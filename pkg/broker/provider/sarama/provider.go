@@ -0,0 +1,274 @@
+package sarama
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/converto"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/tracing"
+	"sync"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+)
+
+const (
+	BrokerSaramaProvider = "sarama"
+)
+
+// Provider is an at-least-once provider based on sarama.
+// It accepts the config of the segmentio provider, so services switch libraries by changing the constructor.
+// The offset of a consumed message is committed after its handler succeeded,
+// a failed message is consumed again after Config.RerunDelay in the same session.
+type Provider struct {
+	cfg                 *Config
+	enabled             bool
+	store               store.Store
+	trace               tracing.Tracer
+	newProducer         ProducerFactory
+	groups              consumerGroups
+	mx                  sync.Mutex
+	producer            goSarama.SyncProducer
+	errCntMu            sync.Mutex
+	failedMessagesCount int
+}
+
+func NewProvider(kc *kafka.Config) *Provider {
+	return NewProviderWithConfig(configFromKafka(kc))
+}
+
+// NewProviderWithConfig creates a provider with sarama specific settings
+func NewProviderWithConfig(cfg *Config) *Provider {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	cfg.defaults()
+
+	p := &Provider{
+		cfg:     cfg,
+		enabled: true,
+	}
+	p.newProducer = p.defaultProducer
+	p.groups.rerunDelay = cfg.RerunDelay
+	p.groups.newConsumerGroup = func(ctx context.Context) (goSarama.ConsumerGroup, error) {
+		return newConsumerGroup(ctx, cfg)
+	}
+
+	return p
+}
+
+// SetProducerFactory sets a producer factory. The producer is created on the first Publish
+// and txID passed to the factory is always empty.
+func (p *Provider) SetProducerFactory(f ProducerFactory) {
+	p.newProducer = f
+}
+
+func (p *Provider) SetConsumerGroupFactory(f ConsumerGroupFactory) {
+	p.groups.newConsumerGroup = f
+}
+
+func (p *Provider) SetEnabled(enable bool) {
+	p.enabled = enable
+}
+
+func (p *Provider) SetStore(s store.Store) {
+	p.store = s
+}
+
+func (p *Provider) SetTracing(t tracing.Tracer) {
+	p.trace = t
+}
+
+func (p *Provider) GetType() string {
+	return BrokerSaramaProvider
+}
+
+func (p *Provider) GetIsTopicExists(ctx context.Context, topic string) (bool, error) {
+	return topicExists(ctx, p.cfg, topic)
+}
+
+func (p *Provider) Publish(ctx context.Context, topic string, e event.BaseEvent) error {
+	err := p.send(ctx, topic, e)
+
+	provider.TraceEvent(ctx, p.trace, TraceSaramaProducer, topic, e, err)
+	metrics.ObservePublish(BrokerSaramaProvider, topic, 1, err)
+
+	return err
+}
+
+func (p *Provider) Sync(ctx context.Context, topic string, fn provider.HandlerFn) {
+	if !p.enabled {
+		return
+	}
+
+	p.groups.run(ctx, topic, &providerGroupHandler{ctx: ctx, p: p, fn: fn})
+}
+
+func (p *Provider) Stop() {
+	if !p.enabled {
+		return
+	}
+
+	p.groups.stop()
+
+	p.mx.Lock()
+	if p.producer != nil {
+		if err := p.producer.Close(); err != nil {
+			_ = cerror.NewF(context.Background(), cerror.KafkaToKind(err),
+				"[sarama] close producer. %s", err.Error()).LogError()
+		}
+
+		p.producer = nil
+	}
+	p.mx.Unlock()
+
+	if p.trace != nil {
+		_ = p.trace.Shutdown()
+	}
+}
+
+// ConsumerGroupHandler returns a handler which processes claimed messages and commits their offsets
+func (p *Provider) ConsumerGroupHandler(ctx context.Context, fn provider.HandlerFn) goSarama.ConsumerGroupHandler {
+	return &providerGroupHandler{ctx: ctx, p: p, fn: fn}
+}
+
+func (p *Provider) send(ctx context.Context, topic string, e event.BaseEvent) error {
+	producer, err := p.getProducer(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg, err := newProducerMessage(ctx, p.cfg, topic, e)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err = producer.SendMessage(msg); err != nil {
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] sendMessage topic: %s. %s", topic, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// processMessage handles the message. Its offset can be committed if no error is returned.
+func (p *Provider) processMessage(ctx context.Context, msg *goSarama.ConsumerMessage, fn provider.HandlerFn) error {
+	log.DebugF(ctx,
+		"[sarama] consume message from kafka topic: %s. partition: %d. offset: %d. key: %s.",
+		msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+	start := time.Now()
+	ctx = messageContext(ctx, msg)
+	e, err := provider.NewHandlerProcessing(p.store).SetLease(p.cfg.EventLease).SetCodec(p.cfg.Codec).
+		Run(ctx, fn, event.Message{
+			Key:   converto.BytePointer(msg.Key),
+			Value: msg.Value,
+		})
+
+	provider.TraceEvent(ctx, p.trace, TraceSaramaConsumer, msg.Topic, e, err)
+	metrics.ObserveHandle(BrokerSaramaProvider, msg.Topic, start, err)
+
+	if err == nil {
+		return nil
+	}
+
+	errCnt := p.incErrCnt()
+	if !p.cfg.Consumer.CommitOnError || p.cfg.CommitOnErrorMessagesCount < errCnt {
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] failed process message in handler for topic: %s. partition: %d. offset: %d. %s",
+			msg.Topic, msg.Partition, msg.Offset, err.Error()).LogError()
+	}
+
+	log.DebugF(ctx,
+		"[sarama] message was processed with error and skipped. total count of skipped messages: %v. allowed count: %v. key = %s",
+		errCnt, p.cfg.CommitOnErrorMessagesCount, string(msg.Key))
+
+	return nil
+}
+
+func (p *Provider) incErrCnt() int {
+	p.errCntMu.Lock()
+	defer p.errCntMu.Unlock()
+	p.failedMessagesCount++
+
+	return p.failedMessagesCount
+}
+
+func (p *Provider) getProducer(ctx context.Context) (goSarama.SyncProducer, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.producer != nil {
+		return p.producer, nil
+	}
+
+	pr, err := p.newProducer(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	p.producer = pr
+
+	return pr, nil
+}
+
+func (p *Provider) defaultProducer(ctx context.Context, _ string) (goSarama.SyncProducer, error) {
+	cfg, err := p.cfg.newSaramaConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := goSarama.NewSyncProducer(p.cfg.Brokers, cfg)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] new producer. %s", err.Error()).LogError()
+	}
+
+	return pr, nil
+}
+
+// providerGroupHandler implements sarama.ConsumerGroupHandler
+type providerGroupHandler struct {
+	ctx context.Context
+	p   *Provider
+	fn  provider.HandlerFn
+}
+
+func (h *providerGroupHandler) Setup(s goSarama.ConsumerGroupSession) error {
+	logSessionSetup(h.ctx, s)
+
+	return nil
+}
+
+func (h *providerGroupHandler) Cleanup(s goSarama.ConsumerGroupSession) error {
+	logSessionCleanup(h.ctx, s)
+
+	return nil
+}
+
+func (h *providerGroupHandler) ConsumeClaim(s goSarama.ConsumerGroupSession, claim goSarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			// the offset isn't committed until the message is processed
+			if !retryInPlace(s, h.p.cfg.RerunDelay, func() error { return h.p.processMessage(h.ctx, msg, h.fn) }) {
+				return nil
+			}
+
+			s.MarkMessage(msg, "")
+			s.Commit()
+		case <-s.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package sarama_test
+
+import (
+	"context"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/provider/kafka"
+	pSarama "kafka-polygon/pkg/broker/provider/sarama"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cmd/metadata"
+	"kafka-polygon/pkg/http/consts"
+	"testing"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+	"github.com/tj/assert"
+)
+
+type commitSession struct {
+	session
+	marked  []*goSarama.ConsumerMessage
+	commits int
+}
+
+func (s *commitSession) MarkMessage(msg *goSarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg)
+}
+
+func (s *commitSession) Commit() {
+	s.commits++
+}
+
+// consumeAll consumes the messages in the session with ctx, which ends the session when it's done
+func consumeAll(
+	ctx context.Context, h goSarama.ConsumerGroupHandler, msgs ...*goSarama.ConsumerMessage) (*commitSession, error) {
+	c := &claim{msgs: make(chan *goSarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		c.msgs <- msg
+	}
+
+	close(c.msgs)
+
+	s := &commitSession{session: session{ctx: ctx}}
+
+	return s, h.ConsumeClaim(s, c)
+}
+
+func TestProviderType(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(nil)
+	assert.Equal(t, pSarama.BrokerSaramaProvider, p.GetType())
+}
+
+func TestProviderCommitAfterHandler(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{Consumer: kafka.Consumer{GroupID: "group"}})
+
+	var handled []string
+
+	fn := provider.HandlerWorkflow(func(_ context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		handled = append(handled, we.GetID())
+		return nil
+	})
+
+	second := e
+	second.ID = "test-id-2"
+
+	msgs := []*goSarama.ConsumerMessage{
+		{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()},
+		{Topic: "topic", Partition: 1, Offset: 11, Value: second.ToByte()},
+	}
+
+	s, err := consumeAll(bgCtx, p.ConsumerGroupHandler(bgCtx, fn), msgs...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-id", "test-id-2"}, handled)
+	assert.Equal(t, msgs, s.marked)
+	assert.Equal(t, 2, s.commits)
+}
+
+func TestProviderHandlerErrorNotCommitted(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{RerunDelay: time.Millisecond, Consumer: kafka.Consumer{GroupID: "group"}})
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	calls := 0
+	fn := provider.HandlerWorkflow(func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		// the partition is revoked while the message is retried
+		if calls++; calls == 2 {
+			cancel()
+		}
+
+		return errHandler
+	})
+
+	msg := &goSarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()}
+
+	s, err := consumeAll(ctx, p.ConsumerGroupHandler(bgCtx, fn), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, s.marked)
+	assert.Equal(t, 0, s.commits)
+}
+
+func TestProviderRetryFailedMessage(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{RerunDelay: time.Millisecond, Consumer: kafka.Consumer{GroupID: "group"}})
+
+	var handled []string
+
+	fn := provider.HandlerWorkflow(func(_ context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		handled = append(handled, we.GetID())
+		if len(handled) == 1 {
+			return errHandler
+		}
+
+		return nil
+	})
+
+	second := e
+	second.ID = "test-id-2"
+
+	msgs := []*goSarama.ConsumerMessage{
+		{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()},
+		{Topic: "topic", Partition: 1, Offset: 11, Value: second.ToByte()},
+	}
+
+	// the failed message is consumed again in the same session before the next one
+	s, err := consumeAll(bgCtx, p.ConsumerGroupHandler(bgCtx, fn), msgs...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-id", "test-id", "test-id-2"}, handled)
+	assert.Equal(t, msgs, s.marked)
+	assert.Equal(t, 2, s.commits)
+}
+
+func TestProviderCommitOnError(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{
+		CommitOnErrorMessagesCount: 1,
+		RerunDelay:                 time.Millisecond,
+		Consumer:                   kafka.Consumer{GroupID: "group", CommitOnError: true},
+	})
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	calls := 0
+	fn := provider.HandlerWorkflow(func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		if calls++; calls == 3 {
+			cancel()
+		}
+
+		return errHandler
+	})
+
+	msgs := []*goSarama.ConsumerMessage{
+		{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()},
+		{Topic: "topic", Partition: 1, Offset: 11, Value: e.ToByte()},
+	}
+
+	// the first failed message is skipped, the second one exceeds the allowed count and is retried
+	s, err := consumeAll(ctx, p.ConsumerGroupHandler(bgCtx, fn), msgs...)
+	assert.NoError(t, err)
+	assert.Equal(t, msgs[:1], s.marked)
+	assert.Equal(t, 1, s.commits)
+}
+
+func TestProviderPublish(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{UseKeyDoubleQuote: true})
+
+	var created []*txProducer
+
+	p.SetProducerFactory(func(_ context.Context, txID string) (goSarama.SyncProducer, error) {
+		tp := &txProducer{txID: txID}
+		created = append(created, tp)
+
+		return tp, nil
+	})
+
+	assert.NoError(t, p.Publish(bgCtx, "topic", &e))
+	assert.NoError(t, p.Publish(bgCtx, "topic", &e))
+
+	// the producer is long-lived and isn't transactional
+	assert.Len(t, created, 1)
+	assert.Equal(t, "", created[0].txID)
+	assert.Equal(t, []string{"send", "send"}, created[0].calls)
+	// the workflow ID is the message key
+	assert.Equal(t, goSarama.StringEncoder(`"test-wf-id"`), created[0].sent[0].Key)
+	assert.Equal(t, goSarama.ByteEncoder(e.ToByte()), created[0].sent[0].Value)
+
+	p.Stop()
+}
+
+func TestProviderPropagatesHeaders(t *testing.T) {
+	t.Parallel()
+
+	p := pSarama.NewProvider(&kafka.Config{Consumer: kafka.Consumer{GroupID: "group"}})
+
+	tp := &txProducer{}
+	p.SetProducerFactory(func(_ context.Context, _ string) (goSarama.SyncProducer, error) {
+		return tp, nil
+	})
+
+	pe := e
+	pe.Header.RequestID = "request-id"
+	pe.Metadata = metadata.Meta{Module: "module", Version: "0.0.1"}
+
+	assert.NoError(t, p.Publish(bgCtx, "topic", &pe))
+	assert.Equal(t, []goSarama.RecordHeader{
+		{Key: []byte(provider.HeaderEventType), Value: []byte("WorkflowData")},
+		{Key: []byte(provider.HeaderModule), Value: []byte("module")},
+		{Key: []byte(provider.HeaderRequestID), Value: []byte("request-id")},
+		{Key: []byte(provider.HeaderVersion), Value: []byte("0.0.1")},
+	}, tp.sent[0].Headers)
+
+	headers := make([]*goSarama.RecordHeader, len(tp.sent[0].Headers))
+	for i := range tp.sent[0].Headers {
+		headers[i] = &tp.sent[0].Headers[i]
+	}
+
+	var ctx context.Context
+
+	fn := provider.HandlerWorkflow(func(hCtx context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		ctx = hCtx
+		return nil
+	})
+
+	msg := &goSarama.ConsumerMessage{Topic: "topic", Value: pe.ToByte(), Headers: headers}
+	_, err := consumeAll(bgCtx, p.ConsumerGroupHandler(bgCtx, fn), msg)
+	assert.NoError(t, err)
+
+	meta, ok := provider.ProducerMeta(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.Meta{Module: "module", Version: "0.0.1"}, meta)
+	assert.Equal(t, "request-id", ctx.Value(consts.HeaderXRequestID))
+	assert.Equal(t, "WorkflowData", provider.EventTypeFromContext(ctx))
+	assert.Equal(t, "topic", provider.TopicFromContext(ctx))
+}
@@ -0,0 +1,393 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/metrics"
+	"kafka-polygon/pkg/broker/provider"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/converto"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/tracing"
+	"sync"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	BrokerSaramaTxProvider = "sarama_tx"
+	TraceSaramaProducer    = "_sarama_producer"
+	TraceSaramaConsumer    = "_sarama_consumer"
+)
+
+// ProducerFactory creates a producer. It is transactional if txID is not empty
+type ProducerFactory func(ctx context.Context, txID string) (goSarama.SyncProducer, error)
+
+type txnKey struct{}
+
+// transaction is an open transaction of a consumed message
+type transaction struct {
+	producer goSarama.SyncProducer
+}
+
+// TxProvider is an exactly-once consume-transform-produce provider.
+// Every consumed message is handled in a Kafka transaction: events published with the context
+// passed to the handler and the offset of the consumed message are committed atomically.
+// If the handler fails, the transaction is aborted and the message is consumed again after Config.RerunDelay
+// in the same session, until it's processed or the partition is revoked.
+// Consumers of the published events must use read_committed isolation level.
+type TxProvider struct {
+	cfg         *Config
+	enabled     bool
+	store       store.Store
+	trace       tracing.Tracer
+	newProducer ProducerFactory
+	groups      consumerGroups
+	publishTxID string
+	mx          sync.Mutex
+	publishMx   sync.Mutex
+	producers   map[string]goSarama.SyncProducer
+}
+
+func NewTxProvider(cfg *Config) *TxProvider {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	cfg.defaults()
+
+	p := &TxProvider{
+		cfg:       cfg,
+		enabled:   true,
+		producers: make(map[string]goSarama.SyncProducer),
+		// events published outside of handlers don't need fencing,
+		// so the id is unique to not fence other instances
+		publishTxID: fmt.Sprintf("%s-publish-%s", cfg.Transaction.IDPrefix, uuid.NewV4().String()),
+	}
+	p.newProducer = p.defaultProducer
+	p.groups.rerunDelay = cfg.RerunDelay
+	p.groups.newConsumerGroup = func(ctx context.Context) (goSarama.ConsumerGroup, error) {
+		return newConsumerGroup(ctx, cfg)
+	}
+
+	return p
+}
+
+func (p *TxProvider) SetProducerFactory(f ProducerFactory) {
+	p.newProducer = f
+}
+
+func (p *TxProvider) SetConsumerGroupFactory(f ConsumerGroupFactory) {
+	p.groups.newConsumerGroup = f
+}
+
+func (p *TxProvider) SetEnabled(enable bool) {
+	p.enabled = enable
+}
+
+func (p *TxProvider) SetStore(s store.Store) {
+	p.store = s
+}
+
+func (p *TxProvider) SetTracing(t tracing.Tracer) {
+	p.trace = t
+}
+
+func (p *TxProvider) GetType() string {
+	return BrokerSaramaTxProvider
+}
+
+func (p *TxProvider) GetIsTopicExists(ctx context.Context, topic string) (bool, error) {
+	return topicExists(ctx, p.cfg, topic)
+}
+
+// Publish sends the event. If ctx is passed to a handler by this provider, the event is sent
+// in the transaction of the consumed message. Otherwise it's sent in its own transaction.
+func (p *TxProvider) Publish(ctx context.Context, topic string, e event.BaseEvent) error {
+	msg, err := newProducerMessage(ctx, p.cfg, topic, e)
+	if err == nil {
+		err = p.send(ctx, msg)
+	}
+
+	provider.TraceEvent(ctx, p.trace, TraceSaramaProducer, topic, e, err)
+	metrics.ObservePublish(BrokerSaramaTxProvider, topic, 1, err)
+
+	return err
+}
+
+// send sends the message in the transaction of the consumed message if ctx carries it, otherwise in its own transaction
+func (p *TxProvider) send(ctx context.Context, msg *goSarama.ProducerMessage) error {
+	tx, ok := ctx.Value(txnKey{}).(*transaction)
+	if !ok {
+		return p.publishInTxn(ctx, msg)
+	}
+
+	if _, _, err := tx.producer.SendMessage(msg); err != nil {
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] send message in transaction. topic: %s. %s", msg.Topic, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+func (p *TxProvider) Sync(ctx context.Context, topic string, fn provider.HandlerFn) {
+	if !p.enabled {
+		return
+	}
+
+	p.groups.run(ctx, topic, &consumerGroupHandler{ctx: ctx, p: p, fn: fn})
+}
+
+func (p *TxProvider) Stop() {
+	if !p.enabled {
+		return
+	}
+
+	p.groups.stop()
+
+	p.mx.Lock()
+	for txID := range p.producers {
+		p.closeProducer(context.Background(), txID)
+	}
+	p.mx.Unlock()
+
+	if p.trace != nil {
+		_ = p.trace.Shutdown()
+	}
+}
+
+// ConsumerGroupHandler returns a handler which processes claimed messages in transactions
+func (p *TxProvider) ConsumerGroupHandler(ctx context.Context, fn provider.HandlerFn) goSarama.ConsumerGroupHandler {
+	return &consumerGroupHandler{ctx: ctx, p: p, fn: fn}
+}
+
+// processMessage handles the message in a transaction and commits its offset within it
+func (p *TxProvider) processMessage(
+	ctx context.Context, txID string, msg *goSarama.ConsumerMessage, fn provider.HandlerFn) error {
+	log.DebugF(ctx,
+		"[sarama] consume message from kafka topic: %s. partition: %d. offset: %d. key: %s.",
+		msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+	producer, err := p.producer(ctx, txID)
+	if err != nil {
+		return err
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		p.dropProducer(ctx, txID)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] begin transaction %s. %s", txID, err.Error()).LogError()
+	}
+
+	start := time.Now()
+	txCtx := messageContext(context.WithValue(ctx, txnKey{}, &transaction{producer: producer}), msg)
+
+	e, err := provider.NewHandlerProcessing(p.store).SetLease(p.cfg.EventLease).SetCodec(p.cfg.Codec).
+		Run(txCtx, fn, event.Message{
+			Key:   converto.BytePointer(msg.Key),
+			Value: msg.Value,
+		})
+
+	provider.TraceEvent(txCtx, p.trace, TraceSaramaConsumer, msg.Topic, e, err)
+	metrics.ObserveHandle(BrokerSaramaTxProvider, msg.Topic, start, err)
+
+	if err != nil && !p.cfg.Consumer.CommitOnError {
+		p.abortTxn(ctx, txID, producer)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] failed process message in handler for topic: %s. partition: %d. offset: %d. %s",
+			msg.Topic, msg.Partition, msg.Offset, err.Error()).LogError()
+	}
+
+	if err := producer.AddMessageToTxn(msg, p.cfg.Consumer.GroupID, nil); err != nil {
+		p.abortHandledTxn(ctx, txID, producer, e)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] add offset to transaction. topic: %s. partition: %d. offset: %d. %s",
+			msg.Topic, msg.Partition, msg.Offset, err.Error()).LogError()
+	}
+
+	if err := producer.CommitTxn(); err != nil {
+		p.abortHandledTxn(ctx, txID, producer, e)
+		metrics.CommitFailures.Inc(BrokerSaramaTxProvider, msg.Topic)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] commit transaction. topic: %s. partition: %d. offset: %d. %s",
+			msg.Topic, msg.Partition, msg.Offset, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// abortHandledTxn aborts the transaction of a successfully handled event.
+// The event status is reset, so the redelivered event isn't skipped as a duplicate.
+func (p *TxProvider) abortHandledTxn(ctx context.Context, txID string, producer goSarama.SyncProducer, e event.BaseEvent) {
+	p.abortTxn(ctx, txID, producer)
+
+	if p.store == nil || e == nil {
+		return
+	}
+
+	if err := p.store.PutEventInfo(ctx, e.GetID(), store.EventProcessData{
+		Status: store.EventStatusHandledWithError,
+	}); err != nil {
+		_ = cerror.NewF(ctx, cerror.KindInternal,
+			"[sarama] reset status of the aborted event. event_id=%s. %s", e.GetID(), err.Error()).LogError()
+	}
+}
+
+func (p *TxProvider) publishInTxn(ctx context.Context, msg *goSarama.ProducerMessage) error {
+	p.publishMx.Lock()
+	defer p.publishMx.Unlock()
+
+	producer, err := p.producer(ctx, p.publishTxID)
+	if err != nil {
+		return err
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		p.dropProducer(ctx, p.publishTxID)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] begin transaction %s. %s", p.publishTxID, err.Error()).LogError()
+	}
+
+	if _, _, err := producer.SendMessage(msg); err != nil {
+		p.abortTxn(ctx, p.publishTxID, producer)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] send message. topic: %s. %s", msg.Topic, err.Error()).LogError()
+	}
+
+	if err := producer.CommitTxn(); err != nil {
+		p.abortTxn(ctx, p.publishTxID, producer)
+
+		return cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] commit transaction. topic: %s. %s", msg.Topic, err.Error()).LogError()
+	}
+
+	return nil
+}
+
+// abortTxn aborts the current transaction. A producer in fatal state is closed and recreated on the next use.
+func (p *TxProvider) abortTxn(ctx context.Context, txID string, producer goSarama.SyncProducer) {
+	if producer.TxnStatus()&goSarama.ProducerTxnFlagFatalError != 0 {
+		p.dropProducer(ctx, txID)
+		return
+	}
+
+	if err := producer.AbortTxn(); err != nil {
+		_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] abort transaction %s. %s", txID, err.Error()).LogError()
+
+		p.dropProducer(ctx, txID)
+	}
+}
+
+// producer returns a producer for the transactional id creating it if needed
+func (p *TxProvider) producer(ctx context.Context, txID string) (goSarama.SyncProducer, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if pr, ok := p.producers[txID]; ok {
+		return pr, nil
+	}
+
+	pr, err := p.newProducer(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.producers[txID] = pr
+
+	return pr, nil
+}
+
+func (p *TxProvider) dropProducer(ctx context.Context, txID string) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.closeProducer(ctx, txID)
+}
+
+// closeProducer must be called under p.mx lock
+func (p *TxProvider) closeProducer(ctx context.Context, txID string) {
+	pr, ok := p.producers[txID]
+	if !ok {
+		return
+	}
+
+	delete(p.producers, txID)
+
+	if err := pr.Close(); err != nil {
+		_ = cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] close producer %s. %s", txID, err.Error()).LogError()
+	}
+}
+
+func (p *TxProvider) defaultProducer(ctx context.Context, txID string) (goSarama.SyncProducer, error) {
+	cfg, err := p.cfg.newSaramaConfig(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := goSarama.NewSyncProducer(p.cfg.Brokers, cfg)
+	if err != nil {
+		return nil, cerror.NewF(ctx, cerror.KafkaToKind(err),
+			"[sarama] new transactional producer %s. %s", txID, err.Error()).LogError()
+	}
+
+	return pr, nil
+}
+
+func (p *TxProvider) txID(topic string, partition int32) string {
+	return fmt.Sprintf("%s-%s-%d", p.cfg.Transaction.IDPrefix, topic, partition)
+}
+
+// consumerGroupHandler implements sarama.ConsumerGroupHandler
+type consumerGroupHandler struct {
+	ctx context.Context
+	p   *TxProvider
+	fn  provider.HandlerFn
+}
+
+func (h *consumerGroupHandler) Setup(s goSarama.ConsumerGroupSession) error {
+	logSessionSetup(h.ctx, s)
+
+	return nil
+}
+
+func (h *consumerGroupHandler) Cleanup(s goSarama.ConsumerGroupSession) error {
+	logSessionCleanup(h.ctx, s)
+
+	return nil
+}
+
+func (h *consumerGroupHandler) ConsumeClaim(s goSarama.ConsumerGroupSession, claim goSarama.ConsumerGroupClaim) error {
+	txID := h.p.txID(claim.Topic(), claim.Partition())
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			// session context is cancelled on rebalance, the message is processed
+			// with the listener context and its transaction is fenced if the partition is lost
+			processed := retryInPlace(s, h.p.cfg.RerunDelay, func() error {
+				return h.p.processMessage(h.ctx, txID, msg, h.fn)
+			})
+			if !processed {
+				return nil
+			}
+		case <-s.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package sarama_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/broker/provider"
+	pSarama "kafka-polygon/pkg/broker/provider/sarama"
+	"kafka-polygon/pkg/broker/store"
+	"sync"
+	"testing"
+	"time"
+
+	goSarama "github.com/Shopify/sarama"
+	"github.com/tj/assert"
+)
+
+var (
+	bgCtx      = context.Background()
+	errHandler = errors.New("handler error")
+	errCommit  = errors.New("commit error")
+	e          = event.WorkflowData{
+		ID: "test-id",
+		Workflow: event.Workflow{
+			ID:     "test-wf-id",
+			Schema: "test-type",
+			Step:   "test-task",
+		},
+	}
+)
+
+type txProducer struct {
+	goSarama.SyncProducer
+	mx        sync.Mutex
+	txID      string
+	calls     []string
+	sent      []*goSarama.ProducerMessage
+	offsets   []*goSarama.ConsumerMessage
+	groupID   string
+	commitErr error
+}
+
+func (tp *txProducer) call(name string) {
+	tp.mx.Lock()
+	defer tp.mx.Unlock()
+
+	tp.calls = append(tp.calls, name)
+}
+
+func (tp *txProducer) BeginTxn() error {
+	tp.call("begin")
+	return nil
+}
+
+func (tp *txProducer) CommitTxn() error {
+	tp.call("commit")
+	return tp.commitErr
+}
+
+func (tp *txProducer) AbortTxn() error {
+	tp.call("abort")
+	return nil
+}
+
+func (tp *txProducer) TxnStatus() goSarama.ProducerTxnStatusFlag {
+	return goSarama.ProducerTxnFlagInTransaction
+}
+
+func (tp *txProducer) SendMessage(msg *goSarama.ProducerMessage) (int32, int64, error) {
+	tp.call("send")
+	tp.sent = append(tp.sent, msg)
+
+	return 0, 0, nil
+}
+
+func (tp *txProducer) AddMessageToTxn(msg *goSarama.ConsumerMessage, groupID string, _ *string) error {
+	tp.call("offset")
+	tp.offsets = append(tp.offsets, msg)
+	tp.groupID = groupID
+
+	return nil
+}
+
+func (tp *txProducer) Close() error {
+	return nil
+}
+
+type session struct {
+	goSarama.ConsumerGroupSession
+	ctx context.Context
+}
+
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+type claim struct {
+	goSarama.ConsumerGroupClaim
+	msgs chan *goSarama.ConsumerMessage
+}
+
+func (c *claim) Topic() string {
+	return "topic"
+}
+
+func (c *claim) Partition() int32 {
+	return 1
+}
+
+func (c *claim) Messages() <-chan *goSarama.ConsumerMessage {
+	return c.msgs
+}
+
+type statusStore struct {
+	mx     sync.Mutex
+	status map[string]string
+}
+
+func (ss *statusStore) GetEventInfoByID(_ context.Context, id string) (store.EventProcessData, error) {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	return store.EventProcessData{Status: ss.status[id]}, nil
+}
+
+func (ss *statusStore) PutEventInfo(_ context.Context, id string, data store.EventProcessData) error {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	ss.status[id] = data.Status
+
+	return nil
+}
+
+func (ss *statusStore) ClaimEvent(
+	_ context.Context, id, _ string, _ time.Duration) (store.EventProcessData, bool, error) {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	prev := store.EventProcessData{Status: ss.status[id]}
+	if !prev.IsClaimable(time.Now()) {
+		return prev, false, nil
+	}
+
+	ss.status[id] = store.EventStatusProcessing
+
+	return prev, true, nil
+}
+
+func (ss *statusStore) ReleaseEvent(_ context.Context, id, _, status string) (bool, error) {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	ss.status[id] = status
+
+	return true, nil
+}
+
+func newTestProvider(producers map[string]*txProducer) *pSarama.TxProvider {
+	p := pSarama.NewTxProvider(&pSarama.Config{
+		RerunDelay: time.Millisecond,
+		Consumer:   pSarama.Consumer{GroupID: "group"},
+	})
+
+	var mx sync.Mutex
+
+	p.SetProducerFactory(func(_ context.Context, txID string) (goSarama.SyncProducer, error) {
+		mx.Lock()
+		defer mx.Unlock()
+
+		tp := &txProducer{txID: txID}
+		producers[txID] = tp
+
+		return tp, nil
+	})
+
+	return p
+}
+
+// consumeOne consumes the message in the session with ctx, which ends the session when it's done
+func consumeOne(ctx context.Context, h goSarama.ConsumerGroupHandler, msg *goSarama.ConsumerMessage) error {
+	c := &claim{msgs: make(chan *goSarama.ConsumerMessage, 1)}
+	c.msgs <- msg
+	close(c.msgs)
+
+	return h.ConsumeClaim(&session{ctx: ctx}, c)
+}
+
+func TestTxProviderConsumeTransformProduce(t *testing.T) {
+	t.Parallel()
+
+	producers := make(map[string]*txProducer)
+	p := newTestProvider(producers)
+
+	fn := provider.HandlerWorkflow(func(ctx context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		return p.Publish(ctx, "next-topic", &event.WorkflowData{ID: "next-" + we.GetID()})
+	})
+
+	msg := &goSarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()}
+	err := consumeOne(bgCtx, p.ConsumerGroupHandler(bgCtx, fn), msg)
+	assert.NoError(t, err)
+
+	assert.Len(t, producers, 1)
+
+	tp, ok := producers["kafka-polygon-topic-1"]
+	assert.True(t, ok)
+	assert.Equal(t, []string{"begin", "send", "offset", "commit"}, tp.calls)
+	assert.Equal(t, "next-topic", tp.sent[0].Topic)
+	assert.Equal(t, goSarama.StringEncoder("next-test-id"), tp.sent[0].Key)
+	assert.Equal(t, []*goSarama.ConsumerMessage{msg}, tp.offsets)
+	assert.Equal(t, "group", tp.groupID)
+}
+
+func TestTxProviderAbortOnHandlerError(t *testing.T) {
+	t.Parallel()
+
+	producers := make(map[string]*txProducer)
+	p := newTestProvider(producers)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	calls := 0
+	fn := provider.HandlerWorkflow(func(ctx context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		assert.NoError(t, p.Publish(ctx, "next-topic", &event.WorkflowData{ID: "next-" + we.GetID()}))
+
+		// the partition is revoked while the message is retried
+		if calls++; calls == 2 {
+			cancel()
+		}
+
+		return errHandler
+	})
+
+	msg := &goSarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()}
+	err := consumeOne(ctx, p.ConsumerGroupHandler(bgCtx, fn), msg)
+	assert.NoError(t, err)
+
+	tp := producers["kafka-polygon-topic-1"]
+	assert.Equal(t, []string{"begin", "send", "abort", "begin", "send", "abort"}, tp.calls)
+	assert.Empty(t, tp.offsets)
+}
+
+func TestTxProviderRetryFailedMessage(t *testing.T) {
+	t.Parallel()
+
+	producers := make(map[string]*txProducer)
+	p := newTestProvider(producers)
+
+	var handled []string
+
+	fn := provider.HandlerWorkflow(func(_ context.Context, we event.WorkflowEvent, _ store.EventProcessData) error {
+		handled = append(handled, we.GetID())
+		if len(handled) == 1 {
+			return errHandler
+		}
+
+		return nil
+	})
+
+	second := e
+	second.ID = "test-id-2"
+
+	msgs := []*goSarama.ConsumerMessage{
+		{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()},
+		{Topic: "topic", Partition: 1, Offset: 11, Value: second.ToByte()},
+	}
+
+	c := &claim{msgs: make(chan *goSarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		c.msgs <- msg
+	}
+
+	close(c.msgs)
+
+	// the failed message is consumed again in the same session before the next one
+	err := p.ConsumerGroupHandler(bgCtx, fn).ConsumeClaim(&session{ctx: bgCtx}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-id", "test-id", "test-id-2"}, handled)
+
+	tp := producers["kafka-polygon-topic-1"]
+	assert.Equal(t, []string{"begin", "abort", "begin", "offset", "commit", "begin", "offset", "commit"}, tp.calls)
+	assert.Equal(t, msgs, tp.offsets)
+}
+
+func TestTxProviderCommitErrorResetsStatus(t *testing.T) {
+	t.Parallel()
+
+	producers := make(map[string]*txProducer)
+	p := newTestProvider(producers)
+	p.SetProducerFactory(func(_ context.Context, txID string) (goSarama.SyncProducer, error) {
+		tp := &txProducer{txID: txID, commitErr: errCommit}
+		producers[txID] = tp
+
+		return tp, nil
+	})
+
+	ss := &statusStore{status: make(map[string]string)}
+	p.SetStore(ss)
+
+	ctx, cancel := context.WithCancel(bgCtx)
+	defer cancel()
+
+	calls := 0
+	fn := provider.HandlerWorkflow(func(_ context.Context, _ event.WorkflowEvent, _ store.EventProcessData) error {
+		if calls++; calls == 2 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	msg := &goSarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 10, Value: e.ToByte()}
+
+	// the retried message is handled again instead of being skipped as a duplicate
+	err := consumeOne(ctx, p.ConsumerGroupHandler(bgCtx, fn), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, store.EventStatusHandledWithError, ss.status[e.ID])
+	assert.Equal(t,
+		[]string{"begin", "offset", "commit", "abort", "begin", "offset", "commit", "abort"},
+		producers["kafka-polygon-topic-1"].calls)
+}
+
+func TestTxProviderPublishOutsideHandler(t *testing.T) {
+	t.Parallel()
+
+	producers := make(map[string]*txProducer)
+	p := newTestProvider(producers)
+
+	err := p.Publish(bgCtx, "topic", &e)
+	assert.NoError(t, err)
+	assert.Len(t, producers, 1)
+
+	for txID, tp := range producers {
+		assert.Contains(t, txID, "kafka-polygon-publish-")
+		assert.Equal(t, []string{"begin", "send", "commit"}, tp.calls)
+		assert.Equal(t, goSarama.ByteEncoder(e.ToByte()), tp.sent[0].Value)
+	}
+}
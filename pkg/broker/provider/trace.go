@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// TraceEvent records a span with event and error attributes. It does nothing if t is nil.
+func TraceEvent(ctx context.Context, t tracing.Tracer, compName, operName string, e event.BaseEvent, err error) {
+	_, end := StartEventTrace(ctx, t, compName, operName)
+	end(e, err)
+}
+
+// StartEventTrace starts a span and returns the context of the span and a func ending it
+// with event and error attributes. The context is propagated in record headers of published events,
+// so spans of consumers are children of the span. ctx is returned as is if t is nil.
+func StartEventTrace(ctx context.Context, t tracing.Tracer, compName, operName string) (
+	context.Context, func(e event.BaseEvent, err error)) {
+	if t == nil {
+		return ctx, func(event.BaseEvent, error) {}
+	}
+
+	t.Trace(compName)
+	tt := t.GetTrace()
+
+	spanCtx, span := tt.Start(ctx, operName)
+
+	return spanCtx, func(e event.BaseEvent, err error) {
+		defer span.End()
+
+		attrs := make(map[string]string)
+
+		if e != nil {
+			attrs["broker.event.id"] = e.GetID()
+			attrs["broker.event.header"] = fmt.Sprintf("%+v", e.GetHeader())
+		}
+
+		if err != nil {
+			attrs["error.message"] = err.Error()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		reqID := spanCtx.Value(consts.HeaderXRequestID)
+		if reqID != nil {
+			attrs["http.requestID"] = fmt.Sprintf("%s", reqID)
+		}
+
+		for key, val := range attrs {
+			span.SetAttributes(attribute.Key(key).String(val))
+		}
+	}
+}
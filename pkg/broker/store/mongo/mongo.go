@@ -4,9 +4,11 @@ import (
 	"context"
 	"kafka-polygon/pkg/broker/store"
 	"kafka-polygon/pkg/cerror"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const defCollBroker = "broker"
@@ -23,7 +25,10 @@ type Store struct {
 	cl       *mongo.Client
 }
 
-var _ store.Store = (*Store)(nil)
+var (
+	_ store.Store  = (*Store)(nil)
+	_ store.Pinger = (*Store)(nil)
+)
 
 func NewStore(client *mongo.Client, settings Settings) *Store {
 	if settings.CollectionName == "" {
@@ -51,18 +56,36 @@ func (s *Store) GetEventInfoByID(ctx context.Context, id string) (store.EventPro
 	return dst, nil
 }
 
+// Ping checks the connection to mongo
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.cl.Ping(ctx, nil); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
 func (s *Store) PutEventInfo(ctx context.Context, id string, data store.EventProcessData) error {
 	var dst *store.EventProcessData
 
 	err := s.getCollection().
-		FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"status": data.Status}}).
+		FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"status":      data.Status,
+			"lease_until": data.LeaseUntil,
+			"owner":       data.Owner,
+		}}).
 		Decode(&dst)
 	if err != nil && err != mongo.ErrNoDocuments {
 		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
 	}
 
 	if dst == nil {
-		_, err = s.getCollection().InsertOne(ctx, bson.M{"_id": id, "status": data.Status})
+		_, err = s.getCollection().InsertOne(ctx, bson.M{
+			"_id":         id,
+			"status":      data.Status,
+			"lease_until": data.LeaseUntil,
+			"owner":       data.Owner,
+		})
 		if err != nil {
 			return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
 		}
@@ -71,6 +94,71 @@ func (s *Store) PutEventInfo(ctx context.Context, id string, data store.EventPro
 	return nil
 }
 
+// ClaimEvent claims the event with a conditional upsert.
+// The filter matches only a claimable document, so if the document exists but can't be claimed,
+// the upsert fails with a duplicate key error and the current event data is returned.
+func (s *Store) ClaimEvent(
+	ctx context.Context, id, owner string, ttl time.Duration) (store.EventProcessData, bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$in": bson.A{store.EventStatusNew, store.EventStatusHandledWithError}}},
+			bson.M{"status": store.EventStatusProcessing, "lease_until": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":      store.EventStatusProcessing,
+		"lease_until": now.Add(ttl),
+		"owner":       owner,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	var prev store.EventProcessData
+
+	err := s.getCollection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&prev)
+	if err == nil {
+		return prev, true, nil
+	}
+
+	if err == mongo.ErrNoDocuments {
+		return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return store.EventProcessData{}, false, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	current, err := s.GetEventInfoByID(ctx, id)
+	if err != nil {
+		if cerror.IsNotExist(err) {
+			// the document was removed after the failed upsert
+			return s.ClaimEvent(ctx, id, owner, ttl)
+		}
+
+		return store.EventProcessData{}, false, err
+	}
+
+	return current, false, nil
+}
+
+// ReleaseEvent sets the status with an update filtered by the owner of the lease,
+// so the status isn't overwritten after the lease is taken over by another consumer.
+func (s *Store) ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error) {
+	filter := bson.M{"_id": id, "status": store.EventStatusProcessing, "owner": owner}
+	update := bson.M{
+		"$set":   bson.M{"status": status},
+		"$unset": bson.M{"lease_until": "", "owner": ""},
+	}
+
+	res, err := s.getCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return res.MatchedCount == 1, nil
+}
+
 func (s *Store) getCollection() *mongo.Collection {
 	return s.cl.Database(s.settings.DatabaseName).Collection(s.settings.CollectionName)
 }
@@ -6,6 +6,7 @@ import (
 	storeMongo "kafka-polygon/pkg/broker/store/mongo"
 	"kafka-polygon/pkg/cerror"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -87,3 +88,118 @@ func TestPutEventInfo(t *testing.T) {
 		assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
 	})
 }
+
+func TestClaimEvent(t *testing.T) {
+	t.Parallel()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("claimed new event", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: nil},
+		})
+
+		s := storeMongo.NewStore(mt.Client, storeMongo.Settings{
+			DatabaseName:   "test-db",
+			CollectionName: "test-collection",
+		})
+
+		prev, claimed, err := s.ClaimEvent(_bgCtx, "test-id", "owner-1", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		assert.Equal(t, store.EventStatusNew, prev.Status)
+	})
+
+	mt.Run("claimed event handled with error", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{{Key: "status", Value: store.EventStatusHandledWithError}}},
+		})
+
+		s := storeMongo.NewStore(mt.Client, storeMongo.Settings{
+			DatabaseName:   "test-db",
+			CollectionName: "test-collection",
+		})
+
+		prev, claimed, err := s.ClaimEvent(_bgCtx, "test-id", "owner-1", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		assert.Equal(t, store.EventStatusHandledWithError, prev.Status)
+	})
+
+	mt.Run("not claimable event", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{
+				Code:    11000,
+				Message: "E11000 duplicate key error",
+			}),
+			mtest.CreateCursorResponse(
+				1,
+				"test-db.test-collection",
+				mtest.FirstBatch,
+				bson.D{{Key: "status", Value: store.EventStatusHandled}}))
+
+		s := storeMongo.NewStore(mt.Client, storeMongo.Settings{
+			DatabaseName:   "test-db",
+			CollectionName: "test-collection",
+		})
+
+		current, claimed, err := s.ClaimEvent(_bgCtx, "test-id", "owner-1", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+		assert.Equal(t, store.EventStatusHandled, current.Status)
+	})
+
+	mt.Run("error", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+		s := storeMongo.NewStore(mt.Client, storeMongo.Settings{
+			DatabaseName:   "test-db",
+			CollectionName: "test-collection",
+		})
+
+		_, _, err := s.ClaimEvent(_bgCtx, "test-id", "owner-1", time.Minute)
+		assert.Error(t, err)
+		assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
+	})
+}
+
+func TestReleaseEvent(t *testing.T) {
+	t.Parallel()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	settings := storeMongo.Settings{
+		DatabaseName:   "test-db",
+		CollectionName: "test-collection",
+	}
+
+	mt.Run("released by owner", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+		s := storeMongo.NewStore(mt.Client, settings)
+
+		released, err := s.ReleaseEvent(_bgCtx, "test-id", "owner-1", store.EventStatusHandled)
+		assert.NoError(t, err)
+		assert.True(t, released)
+	})
+
+	mt.Run("lease taken over", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+		s := storeMongo.NewStore(mt.Client, settings)
+
+		released, err := s.ReleaseEvent(_bgCtx, "test-id", "owner-1", store.EventStatusHandled)
+		assert.NoError(t, err)
+		assert.False(t, released)
+	})
+
+	mt.Run("error", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+		s := storeMongo.NewStore(mt.Client, settings)
+
+		_, err := s.ReleaseEvent(_bgCtx, "test-id", "owner-1", store.EventStatusHandled)
+		assert.Error(t, err)
+		assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
+	})
+}
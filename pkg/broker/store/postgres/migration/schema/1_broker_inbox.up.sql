@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS public.broker_inbox (
+	id VARCHAR(100) PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	updated_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	status VARCHAR(50) NOT NULL,
+	lease_until TIMESTAMP NULL
+);
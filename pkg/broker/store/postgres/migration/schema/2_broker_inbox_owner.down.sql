@@ -0,0 +1 @@
+ALTER TABLE public.broker_inbox DROP COLUMN IF EXISTS owner;
\ No newline at end of file
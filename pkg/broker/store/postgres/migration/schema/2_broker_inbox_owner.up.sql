@@ -0,0 +1 @@
+ALTER TABLE public.broker_inbox ADD COLUMN IF NOT EXISTS owner VARCHAR(100) NULL;
\ No newline at end of file
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
+	pgBun "kafka-polygon/pkg/db/postgres/bun"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Store is an inbox store. Unlike redis and mongo stores it is able to save
+// event status in the same transaction with handler's own writes (see RunInTx).
+type Store struct {
+	db *bun.DB
+}
+
+var (
+	_ store.Store    = (*Store)(nil)
+	_ store.TxRunner = (*Store)(nil)
+	_ store.Pinger   = (*Store)(nil)
+)
+
+func NewStore(db *bun.DB) *Store {
+	return &Store{db: db}
+}
+
+type brokerInbox struct {
+	bun.BaseModel `bun:"table:broker_inbox"`
+	ID            string     `bun:"id,pk"`
+	CreatedAt     time.Time  `bun:"created_at"`
+	UpdatedAt     time.Time  `bun:"updated_at"`
+	Status        string     `bun:"status"`
+	LeaseUntil    *time.Time `bun:"lease_until"`
+	Owner         *string    `bun:"owner"`
+}
+
+func (bi *brokerInbox) toEventProcessData() store.EventProcessData {
+	data := store.EventProcessData{Status: bi.Status}
+	if bi.LeaseUntil != nil {
+		data.LeaseUntil = *bi.LeaseUntil
+	}
+
+	if bi.Owner != nil {
+		data.Owner = *bi.Owner
+	}
+
+	return data
+}
+
+func (s *Store) GetEventInfoByID(ctx context.Context, id string) (store.EventProcessData, error) {
+	dst := &brokerInbox{ID: id}
+
+	err := pgBun.IDBFromContext(ctx, s.db).NewSelect().Model(dst).WherePK().Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return store.EventProcessData{}, cerror.New(ctx, cerror.KindNotExist, err) //nolint:cerrl
+		}
+
+		return store.EventProcessData{}, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return dst.toEventProcessData(), nil
+}
+
+// Ping checks the connection to the database
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
+// PutEventInfo saves event data. If ctx carries a transaction, the data is saved within it.
+func (s *Store) PutEventInfo(ctx context.Context, id string, data store.EventProcessData) error {
+	now := time.Now().UTC()
+	dst := &brokerInbox{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    data.Status,
+	}
+
+	if !data.LeaseUntil.IsZero() {
+		dst.LeaseUntil = &data.LeaseUntil
+	}
+
+	if data.Owner != "" {
+		dst.Owner = &data.Owner
+	}
+
+	_, err := pgBun.IDBFromContext(ctx, s.db).NewInsert().
+		Model(dst).
+		On("CONFLICT (id) DO UPDATE").
+		Set("status=EXCLUDED.status,lease_until=EXCLUDED.lease_until,owner=EXCLUDED.owner,updated_at=EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return cerror.NewF(ctx, cerror.DBToKind(err), "put broker inbox event %s --> %+v", id, err).LogError()
+	}
+
+	return nil
+}
+
+// ClaimEvent locks the inbox row (or inserts a new one) in a separate transaction
+// which is committed right away, so other consumers see the lease immediately.
+func (s *Store) ClaimEvent(
+	ctx context.Context, id, owner string, ttl time.Duration) (store.EventProcessData, bool, error) {
+	var (
+		prev    store.EventProcessData
+		claimed bool
+	)
+
+	now := time.Now().UTC()
+	leaseUntil := now.Add(ttl)
+
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		current := &brokerInbox{ID: id}
+
+		err := tx.NewSelect().Model(current).WherePK().For("UPDATE").Scan(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			res, err := tx.NewInsert().
+				Model(&brokerInbox{
+					ID:         id,
+					CreatedAt:  now,
+					UpdatedAt:  now,
+					Status:     store.EventStatusProcessing,
+					LeaseUntil: &leaseUntil,
+					Owner:      &owner,
+				}).
+				On("CONFLICT (id) DO NOTHING").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			if n, _ := res.RowsAffected(); n == 1 {
+				prev = store.EventProcessData{Status: store.EventStatusNew}
+				claimed = true
+
+				return nil
+			}
+
+			// the row was inserted by another consumer concurrently
+			if err := tx.NewSelect().Model(current).WherePK().Scan(ctx); err != nil {
+				return err
+			}
+
+			prev = current.toEventProcessData()
+
+			return nil
+		}
+
+		prev = current.toEventProcessData()
+		if !prev.IsClaimable(now) {
+			return nil
+		}
+
+		_, err = tx.NewUpdate().
+			Model(&brokerInbox{
+				ID:         id,
+				UpdatedAt:  now,
+				Status:     store.EventStatusProcessing,
+				LeaseUntil: &leaseUntil,
+				Owner:      &owner,
+			}).
+			Column("status", "lease_until", "owner", "updated_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		claimed = true
+
+		return nil
+	})
+	if err != nil {
+		return store.EventProcessData{}, false, cerror.NewF(ctx,
+			cerror.DBToKind(err), "claim broker inbox event %s --> %+v", id, err).LogError()
+	}
+
+	return prev, claimed, nil
+}
+
+// ReleaseEvent updates the row only while it's claimed by the owner.
+// If ctx carries a transaction, the update is made within it, so handler's writes are rolled back
+// together with the status when the lease has been taken over.
+func (s *Store) ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error) {
+	res, err := pgBun.IDBFromContext(ctx, s.db).NewUpdate().
+		Model(&brokerInbox{ID: id, UpdatedAt: time.Now().UTC(), Status: status}).
+		Column("status", "lease_until", "owner", "updated_at").
+		WherePK().
+		Where("status = ?", store.EventStatusProcessing).
+		Where("owner = ?", owner).
+		Exec(ctx)
+	if err != nil {
+		return false, cerror.NewF(ctx, cerror.DBToKind(err), "release broker inbox event %s --> %+v", id, err).LogError()
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return n == 1, nil
+}
+
+// RunInTx calls fn within a transaction. The transaction is available in fn's context,
+// use pgBun.IDBFromContext or pgBun.TxFromContext to make handler's writes in it.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (s *Store) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(pgBun.ContextWithTx(ctx, tx))
+	})
+}
@@ -0,0 +1,204 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-polygon/pkg/broker/store"
+	pgStore "kafka-polygon/pkg/broker/store/postgres"
+	"kafka-polygon/pkg/broker/store/postgres/migration/schema"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/db/postgres"
+	"kafka-polygon/pkg/db/postgres/bun"
+	"kafka-polygon/pkg/env"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/testutil"
+	"testing"
+	"time"
+
+	bindata "github.com/golang-migrate/migrate/v4/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	migrationVersion uint = 2
+)
+
+var (
+	_bgCtx     = context.Background()
+	errHandler = errors.New("handler error")
+)
+
+type storeTestSuite struct {
+	suite.Suite
+	pgCont *testutil.DockerPGContainer
+	db     *sqlx.DB
+	store  *pgStore.Store
+	pgConn *bun.Connection
+	pgCfg  *env.Postgres
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(storeTestSuite))
+}
+
+func (ts *storeTestSuite) SetupSuite() {
+	dbName := "broker_inbox_test"
+	pgCont := testutil.NewDockerUtilInstance().
+		InitPG().
+		CreatePostgresContainerDatabase(dbName).
+		ConnectPostgresDB(dbName).
+		RunDBMigrations(_bgCtx, dbName, testutil.DockerMigrateConfig{
+			Name:     postgres.MigrateNameBrokerInbox,
+			Version:  migrationVersion,
+			Resource: bindata.Resource(schema.AssetNames(), schema.Asset),
+		})
+	ts.pgCont = pgCont
+	ts.db = pgCont.GetDBInfoByName(dbName).DBClient
+
+	postgresCfg := pgCont.GetPostgresEnvConfig()
+	postgresCfg.DBName = dbName
+	ts.pgCfg = &postgresCfg
+
+	ts.pgConn = bun.NewConnection(&postgresCfg)
+	err := ts.pgConn.Connect()
+
+	if err != nil {
+		_ = cerror.New(_bgCtx, cerror.KindInternal, err).LogError()
+		return
+	}
+
+	ts.store = pgStore.NewStore(ts.pgConn.DB())
+}
+
+func (ts *storeTestSuite) TearDownTest() {
+	ts.deleteAllRecordsByTableName("broker_inbox")
+}
+
+func (ts *storeTestSuite) TearDownSuite() {
+	_ = ts.pgConn.Close()
+	_ = ts.db.Close()
+	_ = ts.pgCont.CloseDBConnectionByName(ts.pgCfg.DBName)
+}
+
+func (ts *storeTestSuite) TestPutGetEventInfo() {
+	_, err := ts.store.GetEventInfoByID(_bgCtx, "id")
+	ts.True(cerror.IsNotExist(err))
+
+	err = ts.store.PutEventInfo(_bgCtx, "id", store.EventProcessData{Status: store.EventStatusNew})
+	ts.NoError(err)
+
+	err = ts.store.PutEventInfo(_bgCtx, "id", store.EventProcessData{Status: store.EventStatusHandled})
+	ts.NoError(err)
+
+	data, err := ts.store.GetEventInfoByID(_bgCtx, "id")
+	ts.NoError(err)
+	ts.Equal(store.EventStatusHandled, data.Status)
+}
+
+func (ts *storeTestSuite) TestClaimEvent() {
+	prev, claimed, err := ts.store.ClaimEvent(_bgCtx, "id", "owner-1", time.Minute)
+	ts.NoError(err)
+	ts.True(claimed)
+	ts.Equal(store.EventStatusNew, prev.Status)
+
+	prev, claimed, err = ts.store.ClaimEvent(_bgCtx, "id", "owner-2", time.Minute)
+	ts.NoError(err)
+	ts.False(claimed)
+	ts.Equal(store.EventStatusProcessing, prev.Status)
+	ts.True(prev.LeaseUntil.After(time.Now().UTC()))
+
+	err = ts.store.PutEventInfo(_bgCtx, "id", store.EventProcessData{Status: store.EventStatusHandled})
+	ts.NoError(err)
+
+	prev, claimed, err = ts.store.ClaimEvent(_bgCtx, "id", "owner-1", time.Minute)
+	ts.NoError(err)
+	ts.False(claimed)
+	ts.Equal(store.EventStatusHandled, prev.Status)
+}
+
+func (ts *storeTestSuite) TestClaimEventExpiredLease() {
+	_, claimed, err := ts.store.ClaimEvent(_bgCtx, "id", "owner-1", time.Millisecond)
+	ts.NoError(err)
+	ts.True(claimed)
+
+	time.Sleep(10 * time.Millisecond)
+
+	prev, claimed, err := ts.store.ClaimEvent(_bgCtx, "id", "owner-1", time.Minute)
+	ts.NoError(err)
+	ts.True(claimed)
+	ts.Equal(store.EventStatusProcessing, prev.Status)
+}
+
+func (ts *storeTestSuite) TestReleaseEvent() {
+	_, claimed, err := ts.store.ClaimEvent(_bgCtx, "id", "owner-1", time.Millisecond)
+	ts.NoError(err)
+	ts.True(claimed)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the expired lease is taken over, so the first owner can't save its status
+	_, claimed, err = ts.store.ClaimEvent(_bgCtx, "id", "owner-2", time.Minute)
+	ts.NoError(err)
+	ts.True(claimed)
+
+	released, err := ts.store.ReleaseEvent(_bgCtx, "id", "owner-1", store.EventStatusHandledWithError)
+	ts.NoError(err)
+	ts.False(released)
+
+	// the status is rolled back together with the transaction
+	err = ts.store.RunInTx(_bgCtx, func(ctx context.Context) error {
+		released, err := ts.store.ReleaseEvent(ctx, "id", "owner-2", store.EventStatusHandled)
+		ts.NoError(err)
+		ts.True(released)
+
+		return errHandler
+	})
+	ts.ErrorIs(err, errHandler)
+
+	released, err = ts.store.ReleaseEvent(_bgCtx, "id", "owner-2", store.EventStatusHandled)
+	ts.NoError(err)
+	ts.True(released)
+
+	data, err := ts.store.GetEventInfoByID(_bgCtx, "id")
+	ts.NoError(err)
+	ts.Equal(store.EventProcessData{Status: store.EventStatusHandled}, data)
+}
+
+func (ts *storeTestSuite) TestRunInTx() {
+	err := ts.store.RunInTx(_bgCtx, func(ctx context.Context) error {
+		_, ok := bun.TxFromContext(ctx)
+		ts.True(ok)
+
+		return ts.store.PutEventInfo(ctx, "committed", store.EventProcessData{Status: store.EventStatusHandled})
+	})
+	ts.NoError(err)
+
+	data, err := ts.store.GetEventInfoByID(_bgCtx, "committed")
+	ts.NoError(err)
+	ts.Equal(store.EventStatusHandled, data.Status)
+
+	err = ts.store.RunInTx(_bgCtx, func(ctx context.Context) error {
+		if err := ts.store.PutEventInfo(ctx, "rolled-back", store.EventProcessData{Status: store.EventStatusHandled}); err != nil {
+			return err
+		}
+
+		return errHandler
+	})
+	ts.ErrorIs(err, errHandler)
+
+	_, err = ts.store.GetEventInfoByID(_bgCtx, "rolled-back")
+	ts.True(cerror.IsNotExist(err))
+}
+
+func (ts *storeTestSuite) deleteAllRecordsByTableName(tableName string) {
+	if _, err := ts.db.Exec(fmt.Sprintf("DELETE FROM %v;", tableName)); err != nil {
+		_ = cerror.NewF(_bgCtx, cerror.KindInternal, "error delete from tables: %v", err).LogError()
+	}
+}
+
+func (ts *storeTestSuite) TestPing() {
+	ts.NoError(ts.store.Ping(_bgCtx))
+}
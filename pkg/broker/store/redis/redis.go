@@ -5,11 +5,29 @@ import (
 	"fmt"
 	"kafka-polygon/pkg/broker/store"
 	"kafka-polygon/pkg/cerror"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const leaseSeparator = "|"
+
+// compareAndSetScript sets KEYS[1] to ARGV[2] only if its current value equals ARGV[1].
+// ARGV[3] is a key ttl in milliseconds, 0 means no expiration.
+var compareAndSetScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
 type Settings struct {
 	KeyPrefix string
 	TTL       time.Duration
@@ -20,7 +38,10 @@ type Store struct {
 	settings Settings
 }
 
-var _ store.Store = (*Store)(nil)
+var (
+	_ store.Store  = (*Store)(nil)
+	_ store.Pinger = (*Store)(nil)
+)
 
 func NewStore(rc *redis.Client, settings Settings) *Store {
 	return &Store{
@@ -41,13 +62,22 @@ func (s *Store) GetEventInfoByID(ctx context.Context, id string) (store.EventPro
 		return store.EventProcessData{}, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
 	}
 
-	return store.EventProcessData{Status: val}, nil
+	return decodeEventData(val), nil
+}
+
+// Ping checks the connection to redis
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.rc.Ping(ctx).Err(); err != nil {
+		return cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	return nil
 }
 
 func (s *Store) PutEventInfo(ctx context.Context, id string, data store.EventProcessData) error {
 	key := s.getKey(id)
 
-	err := s.rc.Set(ctx, key, []byte(data.Status), s.settings.TTL).Err()
+	err := s.rc.Set(ctx, key, []byte(encodeEventData(data)), s.settings.TTL).Err()
 	if err != nil {
 		return cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
 	}
@@ -55,6 +85,78 @@ func (s *Store) PutEventInfo(ctx context.Context, id string, data store.EventPro
 	return nil
 }
 
+// ClaimEvent claims the event with SETNX if it doesn't exist yet.
+// Otherwise, it replaces a claimable value with compare-and-set,
+// so only one of the concurrent consumers gets the claim.
+func (s *Store) ClaimEvent(
+	ctx context.Context, id, owner string, ttl time.Duration) (store.EventProcessData, bool, error) {
+	key := s.getKey(id)
+	now := time.Now().UTC()
+	claimedVal := encodeEventData(store.EventProcessData{
+		Status:     store.EventStatusProcessing,
+		LeaseUntil: now.Add(ttl),
+		Owner:      owner,
+	})
+
+	ok, err := s.rc.SetNX(ctx, key, claimedVal, s.settings.TTL).Result()
+	if err != nil {
+		return store.EventProcessData{}, false, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	if ok {
+		return store.EventProcessData{Status: store.EventStatusNew}, true, nil
+	}
+
+	val, err := s.rc.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// the key has expired between SETNX and GET
+			return s.ClaimEvent(ctx, id, owner, ttl)
+		}
+
+		return store.EventProcessData{}, false, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	current := decodeEventData(val)
+	if !current.IsClaimable(now) {
+		return current, false, nil
+	}
+
+	res, err := compareAndSetScript.Run(ctx, s.rc, []string{key}, val, claimedVal, s.settings.TTL.Milliseconds()).Int()
+	if err != nil {
+		return store.EventProcessData{}, false, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	return current, res == 1, nil
+}
+
+// ReleaseEvent replaces the value claimed by the owner with compare-and-set,
+// so the status isn't overwritten after the lease is taken over by another consumer.
+func (s *Store) ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error) {
+	key := s.getKey(id)
+
+	val, err := s.rc.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+
+		return false, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	current := decodeEventData(val)
+	if current.Status != store.EventStatusProcessing || current.Owner != owner {
+		return false, nil
+	}
+
+	res, err := compareAndSetScript.Run(ctx, s.rc, []string{key}, val, status, s.settings.TTL.Milliseconds()).Int()
+	if err != nil {
+		return false, cerror.New(ctx, cerror.RedisToKind(err), err).LogError()
+	}
+
+	return res == 1, nil
+}
+
 func (s *Store) DeleteEventInfoByID(ctx context.Context, id string) error {
 	key := s.getKey(id)
 
@@ -74,3 +176,30 @@ func (s *Store) getKey(id string) string {
 
 	return key
 }
+
+// encodeEventData stores the lease deadline and its owner next to the processing status
+// as "processing|<unix milliseconds>|<owner>". Other statuses are stored as is.
+func encodeEventData(data store.EventProcessData) string {
+	if data.Status != store.EventStatusProcessing {
+		return data.Status
+	}
+
+	return data.Status + leaseSeparator + strconv.FormatInt(data.LeaseUntil.UnixMilli(), 10) +
+		leaseSeparator + data.Owner
+}
+
+func decodeEventData(val string) store.EventProcessData {
+	status, lease, found := strings.Cut(val, leaseSeparator)
+	if !found {
+		return store.EventProcessData{Status: val}
+	}
+
+	lease, owner, _ := strings.Cut(lease, leaseSeparator)
+	data := store.EventProcessData{Status: status, Owner: owner}
+
+	if ms, err := strconv.ParseInt(lease, 10, 64); err == nil {
+		data.LeaseUntil = time.UnixMilli(ms).UTC()
+	}
+
+	return data
+}
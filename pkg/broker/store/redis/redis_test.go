@@ -9,6 +9,7 @@ import (
 	"kafka-polygon/pkg/log"
 	"kafka-polygon/pkg/testutil"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	uuid "github.com/satori/go.uuid"
@@ -83,3 +84,82 @@ func (s *storeTestSuite) TestPutEventInfo() {
 	s.NoError(err)
 	s.Equal(expectedData, data)
 }
+
+func (s *storeTestSuite) TestClaimEvent() {
+	uID := uuid.NewV4()
+
+	prev, claimed, err := s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Minute)
+	s.NoError(err)
+	s.True(claimed)
+	s.Equal(store.EventStatusNew, prev.Status)
+
+	// the lease is still valid, so the second consumer can't claim the event
+	current, claimed, err := s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Minute)
+	s.NoError(err)
+	s.False(claimed)
+	s.Equal(store.EventStatusProcessing, current.Status)
+	s.True(current.LeaseUntil.After(time.Now()))
+
+	err = s.st.PutEventInfo(_bgCtx, uID.String(), store.EventProcessData{Status: store.EventStatusHandled})
+	s.NoError(err)
+
+	current, claimed, err = s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Minute)
+	s.NoError(err)
+	s.False(claimed)
+	s.Equal(store.EventProcessData{Status: store.EventStatusHandled}, current)
+}
+
+func (s *storeTestSuite) TestClaimEventExpiredLease() {
+	uID := uuid.NewV4()
+
+	_, claimed, err := s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Millisecond)
+	s.NoError(err)
+	s.True(claimed)
+
+	time.Sleep(5 * time.Millisecond)
+
+	prev, claimed, err := s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Minute)
+	s.NoError(err)
+	s.True(claimed)
+	s.Equal(store.EventStatusProcessing, prev.Status)
+
+	data, err := s.st.GetEventInfoByID(_bgCtx, uID.String())
+	s.NoError(err)
+	s.Equal(store.EventStatusProcessing, data.Status)
+	s.True(data.LeaseUntil.After(time.Now()))
+}
+
+func (s *storeTestSuite) TestReleaseEvent() {
+	uID := uuid.NewV4()
+
+	released, err := s.st.ReleaseEvent(_bgCtx, uID.String(), "owner-1", store.EventStatusHandled)
+	s.NoError(err)
+	s.False(released)
+
+	_, claimed, err := s.st.ClaimEvent(_bgCtx, uID.String(), "owner-1", time.Millisecond)
+	s.NoError(err)
+	s.True(claimed)
+
+	time.Sleep(5 * time.Millisecond)
+
+	// the expired lease is taken over, so the first owner can't save its status
+	_, claimed, err = s.st.ClaimEvent(_bgCtx, uID.String(), "owner-2", time.Minute)
+	s.NoError(err)
+	s.True(claimed)
+
+	released, err = s.st.ReleaseEvent(_bgCtx, uID.String(), "owner-1", store.EventStatusHandledWithError)
+	s.NoError(err)
+	s.False(released)
+
+	released, err = s.st.ReleaseEvent(_bgCtx, uID.String(), "owner-2", store.EventStatusHandled)
+	s.NoError(err)
+	s.True(released)
+
+	data, err := s.st.GetEventInfoByID(_bgCtx, uID.String())
+	s.NoError(err)
+	s.Equal(store.EventProcessData{Status: store.EventStatusHandled}, data)
+}
+
+func (s *storeTestSuite) TestPing() {
+	s.NoError(s.st.Ping(_bgCtx))
+}
@@ -1,18 +1,65 @@
 package store
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 const (
 	EventStatusNew              = "new"
+	EventStatusProcessing       = "processing"
 	EventStatusHandled          = "handled"
 	EventStatusHandledWithError = "handled_with_error"
 )
 
 type EventProcessData struct {
 	Status string `bson:"status" json:"status"`
+	// LeaseUntil is set only for EventStatusProcessing.
+	// After this moment the event can be claimed by another consumer.
+	LeaseUntil time.Time `bson:"lease_until,omitempty" json:"lease_until,omitempty"`
+	// Owner is a token of the consumer holding the lease. It's set only for EventStatusProcessing.
+	Owner string `bson:"owner,omitempty" json:"owner,omitempty"`
+}
+
+// IsLeaseExpired reports whether the event is in processing status
+// and its lease is already expired at the given moment.
+func (d EventProcessData) IsLeaseExpired(now time.Time) bool {
+	return d.Status == EventStatusProcessing && !d.LeaseUntil.After(now)
+}
+
+// IsClaimable reports whether the event can be claimed for processing at the given moment.
+func (d EventProcessData) IsClaimable(now time.Time) bool {
+	switch d.Status {
+	case "", EventStatusNew, EventStatusHandledWithError:
+		return true
+	case EventStatusProcessing:
+		return d.IsLeaseExpired(now)
+	default:
+		return false
+	}
 }
 
 type Store interface {
 	GetEventInfoByID(ctx context.Context, id string) (EventProcessData, error)
 	PutEventInfo(ctx context.Context, id string, data EventProcessData) error
+	// ClaimEvent atomically sets EventStatusProcessing with a lease for the given ttl held by the owner
+	// if the event doesn't exist or is claimable (see EventProcessData.IsClaimable).
+	// It returns the event data as it was before the call and whether the claim succeeded.
+	ClaimEvent(ctx context.Context, id, owner string, ttl time.Duration) (EventProcessData, bool, error)
+	// ReleaseEvent atomically sets the final status of the event only if it's still claimed by the owner.
+	// It returns false if the lease has been taken over by another consumer.
+	ReleaseEvent(ctx context.Context, id, owner, status string) (bool, error)
+}
+
+// TxRunner is implemented by stores that are able to save event data
+// in the same transaction with handler's own writes.
+type TxRunner interface {
+	// RunInTx calls fn with a context that carries the transaction.
+	// Store methods called with this context join the transaction.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Pinger is implemented by stores able to check their connection, e.g. to pause consuming while the store is down.
+type Pinger interface {
+	Ping(ctx context.Context) error
 }
@@ -65,6 +65,16 @@ func CmdConsumer(r Runner) *cobra.Command {
 	}
 }
 
+func CmdHarness(r Runner) *cobra.Command {
+	return &cobra.Command{
+		Use:   "harness",
+		Short: "measure duplicates and losses of kafka client libraries",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return r(cmd.Context())
+		},
+	}
+}
+
 func LoadFlagEnv(fs *pflag.FlagSet, dst *string) {
 	fs.StringVarP(dst, "env", "e", "", "Path to env file")
 }
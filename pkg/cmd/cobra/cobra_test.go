@@ -125,6 +125,7 @@ func TestCommands(t *testing.T) {
 			"run":      true,
 			"workflow": true,
 			"cron":     true,
+			"harness":  true,
 		}
 	)
 
@@ -134,6 +135,7 @@ func TestCommands(t *testing.T) {
 	rootCmd.AddCommand(pkgcobra.CmdRunService(emptyRunner))
 	rootCmd.AddCommand(pkgcobra.CmdWorkflowWorker(emptyRunner))
 	rootCmd.AddCommand(pkgcobra.CmdRunCron(emptyRunner))
+	rootCmd.AddCommand(pkgcobra.CmdHarness(emptyRunner))
 
 	for _, command := range rootCmd.Commands() {
 		t.Logf("Use: %s\n", command.Use)
@@ -0,0 +1,29 @@
+package bun
+
+import (
+	"context"
+	"kafka-polygon/pkg/db/postgres"
+
+	"github.com/uptrace/bun"
+)
+
+// ContextWithTx returns a copy of ctx that carries the given transaction.
+func ContextWithTx(ctx context.Context, tx bun.Tx) context.Context {
+	return context.WithValue(ctx, postgres.TxContextKey, tx)
+}
+
+// TxFromContext returns a transaction stored in ctx by ContextWithTx.
+func TxFromContext(ctx context.Context) (bun.Tx, bool) {
+	tx, ok := ctx.Value(postgres.TxContextKey).(bun.Tx)
+	return tx, ok
+}
+
+// IDBFromContext returns a transaction from ctx if it exists, otherwise the given db.
+// Use it in repositories, so their queries join the caller's transaction.
+func IDBFromContext(ctx context.Context, db *bun.DB) bun.IDB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+
+	return db
+}
@@ -8,6 +8,8 @@ const (
 
 	TxContextKey key = "DB_CONTEXT_TRANSACTION_KEY"
 
-	MigrateNameWorkflow = "migrate_workflow"
-	MigrateName         = "migrate"
+	MigrateNameWorkflow     = "migrate_workflow"
+	MigrateNameBrokerInbox  = "migrate_broker_inbox"
+	MigrateNameBrokerOutbox = "migrate_broker_outbox"
+	MigrateName             = "migrate"
 )
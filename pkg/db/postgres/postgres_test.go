@@ -47,9 +47,11 @@ func (s *dbTestSuite) TearDownSuite() {
 
 func (s *dbTestSuite) TestConst() {
 	sConst := map[string]string{
-		string(postgres.TxContextKey): "DB_CONTEXT_TRANSACTION_KEY",
-		postgres.MigrateNameWorkflow:  "migrate_workflow",
-		postgres.MigrateName:          "migrate",
+		string(postgres.TxContextKey):    "DB_CONTEXT_TRANSACTION_KEY",
+		postgres.MigrateNameWorkflow:     "migrate_workflow",
+		postgres.MigrateNameBrokerInbox:  "migrate_broker_inbox",
+		postgres.MigrateNameBrokerOutbox: "migrate_broker_outbox",
+		postgres.MigrateName:             "migrate",
 	}
 
 	for actual, expected := range sConst {
@@ -3,6 +3,7 @@ package fiber
 import (
 	"context"
 	"fmt"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/env"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/log"
@@ -108,6 +109,19 @@ func (s *Server) WithGetSpecRoute(customHandlers ...fiber.Handler) *Server {
 	return s
 }
 
+// WithMetricsRoute adds /metrics route exposing broker metrics in the Prometheus format.
+// Uses default handler if no custom handlers will be passed
+func (s *Server) WithMetricsRoute(customHandlers ...fiber.Handler) *Server {
+	h := []fiber.Handler{DefaultMetricsHandler(metrics.DefaultRegistry)}
+	if len(customHandlers) > 0 {
+		h = customHandlers
+	}
+
+	s.Fiber().Add(fiber.MethodGet, "/metrics", h...)
+
+	return s
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.Fiber().Config().ReadTimeout == 0 {
 		return nil
@@ -156,3 +170,14 @@ func DefaultGetSpecHandler(s *openapi3.T) fiber.Handler {
 		return ctx.Status(fiber.StatusOK).Send(b)
 	}
 }
+
+// DefaultMetricsHandler returns default handler for /metrics route writing metrics of the registry
+func DefaultMetricsHandler(r *metrics.Registry) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderContentType, metrics.ContentType)
+
+		_, err := r.WriteTo(ctx)
+
+		return err
+	}
+}
@@ -2,6 +2,8 @@ package fiber_test
 
 import (
 	"context"
+	"io"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/env"
 	"kafka-polygon/pkg/http/consts"
 	httpFiber "kafka-polygon/pkg/http/fiber"
@@ -165,3 +167,27 @@ func TestCloseOnShutdown(t *testing.T) {
 		utils.AssertEqual(t, true, server.Shutdown(context.Background()) == nil)
 	})
 }
+
+func TestWithMetricsRoute(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.NewCounter("test_total", "Test counter.", "topic").Inc("topic-1")
+
+	server := httpFiber.NewServer(&httpFiber.ServerConfig{
+		Service: env.Service{Name: "test-metrics"},
+		Server:  env.HTTPServer{Port: "8005"},
+	}).WithMetricsRoute(httpFiber.DefaultMetricsHandler(reg))
+
+	resp, err := server.Fiber().Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, metrics.ContentType, resp.Header.Get(fiber.HeaderContentType))
+	assert.Contains(t, string(body), `test_total{topic="topic-1"} 1`)
+}
@@ -3,6 +3,7 @@ package gin
 import (
 	"context"
 	"fmt"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/env"
 	"kafka-polygon/pkg/http/consts"
@@ -106,6 +107,19 @@ func (s *Server) WithHealthCheckRoute(customHandlers ...gin.HandlerFunc) *Server
 	return s
 }
 
+// WithMetricsRoute adds /metrics route exposing broker metrics in the Prometheus format.
+// Uses default handler if no custom handlers will be passed
+func (s *Server) WithMetricsRoute(customHandlers ...gin.HandlerFunc) *Server {
+	h := []gin.HandlerFunc{DefaultMetricsHandler(metrics.DefaultRegistry)}
+	if len(customHandlers) > 0 {
+		h = customHandlers
+	}
+
+	s.Gin().Handle(http.MethodGet, "/metrics", h...)
+
+	return s
+}
+
 func (s *Server) SetTracing(provider tracing.Provider) {
 	s.Gin().Use(middlewareTracing.New(tracing.New(provider)))
 }
@@ -128,6 +142,11 @@ func DefaultHealthCheckHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, "")
 }
 
+// DefaultMetricsHandler returns default handler for /metrics route writing metrics of the registry
+func DefaultMetricsHandler(r *metrics.Registry) gin.HandlerFunc {
+	return gin.WrapH(r.Handler())
+}
+
 // WithGetSpecRoute adds /spec route that returns service specification
 func (s *Server) WithGetSpecRoute(customHandlers ...gin.HandlerFunc) *Server {
 	h := []gin.HandlerFunc{DefaultGetSpecHandler(s.cfg.Swagger)}
@@ -3,6 +3,7 @@ package gin_test
 import (
 	"context"
 	"errors"
+	"kafka-polygon/pkg/broker/metrics"
 	"kafka-polygon/pkg/env"
 	"kafka-polygon/pkg/http/consts"
 	httpGin "kafka-polygon/pkg/http/gin"
@@ -264,3 +265,25 @@ func TestHandlerFunc(t *testing.T) {
 		assert.Equal(t, "{\"error\":{\"message\":\"handler error\",\"type\":\"other_error\",\"group\":\"http\"}}", w.Body.String())
 	})
 }
+
+func TestWithMetricsRoute(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	reg.NewCounter("test_total", "Test counter.", "topic").Inc("topic-1")
+
+	server := httpGin.NewServer(&httpGin.ServerConfig{
+		Service: env.Service{Name: "test-metrics"},
+		Server:  env.HTTPServer{Port: "8005"},
+	}).WithMetricsRoute(httpGin.DefaultMetricsHandler(reg))
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	require.NoError(t, err)
+
+	server.Gin().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `test_total{topic="topic-1"} 1`)
+}
@@ -2,8 +2,10 @@ package entity
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"kafka-polygon/pkg/cerror"
+	"strings"
 )
 
 type WorkflowSchemaName string
@@ -17,19 +19,30 @@ func PointerWorkflowSchemaName(s string) *WorkflowSchemaName {
 	return &sn
 }
 
-// WorkflowSchema represents a sequence of logical units(steps)
+// WorkflowSchema represents a graph of logical units(steps) starting from the first step.
+// Steps are linear unless they are branch steps choosing one of the next steps
+// or parallel steps running several branches at once.
 type WorkflowSchema struct {
 	name  WorkflowSchemaName
 	steps []WorkflowSchemaStep
+	// branchOf is a parallel step of each step of its branches
+	branchOf map[WorkflowSchemaStepName]*WorkflowSchemaParallelStep
+	// joinOf is a parallel step of each join step
+	joinOf map[WorkflowSchemaStepName]*WorkflowSchemaParallelStep
 }
 
 // NewWorkflowSchema creates a new workflow schema with given name and set of steps.
 // Execute steps validation and returns error if something is wrong.
 // It is the only way to initialize workflow with steps, so later all the steps
-// can be considered to be valid (e.g. at least one step, no duplicates, ...)
+// can be considered to be valid (e.g. at least one step, no duplicates, no cycles, ...)
 func NewWorkflowSchema(
 	ctx context.Context, name WorkflowSchemaName, steps ...WorkflowSchemaStep) (*WorkflowSchema, error) {
-	w := &WorkflowSchema{name: name, steps: steps}
+	w := &WorkflowSchema{
+		name:     name,
+		steps:    steps,
+		branchOf: make(map[WorkflowSchemaStepName]*WorkflowSchemaParallelStep),
+		joinOf:   make(map[WorkflowSchemaStepName]*WorkflowSchemaParallelStep),
+	}
 
 	if err := w.validate(ctx); err != nil {
 		return nil, err
@@ -45,35 +58,171 @@ func (w *WorkflowSchema) Name() WorkflowSchemaName {
 // Step finds workflow's schema step by name.
 // If step is not found, the second returned parameter will be nil.
 func (w *WorkflowSchema) Step(sn WorkflowSchemaStepName) (WorkflowSchemaStep, bool) {
-	for _, s := range w.steps {
-		if s.Name() == sn {
-			return s, true
-		}
+	if i := w.index(sn); i >= 0 {
+		return w.steps[i], true
 	}
 
 	return nil, false
 }
 
-// NextStep returns the next step after step with given name.
+// NextStep returns the next step after a simple step with given name.
 // The second returned parameter indicates whether the next step exists.
+// Next steps of branch and parallel steps depend on the worker's output, use NextSteps for them.
 func (w *WorkflowSchema) NextStep(current WorkflowSchemaStepName) (WorkflowSchemaStep, bool) {
-	for i := range w.steps {
-		if w.steps[i].Name() == current {
-			if i < len(w.steps)-1 {
-				return w.steps[i+1], true
-			}
+	i := w.index(current)
+	if i < 0 {
+		return nil, false
+	}
+
+	if _, ok := w.steps[i].(*WorkflowSchemaSimpleStep); !ok {
+		return nil, false
+	}
+
+	next := w.successors(i)
+	if len(next) == 0 {
+		return nil, false
+	}
+
+	return w.Step(next[0])
+}
 
-			return nil, false
+// NextSteps returns steps to run after the step with given name produced the output.
+// It's a step chosen by a branch step, all branches of a parallel step or the next step of a simple step.
+// No steps are returned after the last step of the workflow.
+func (w *WorkflowSchema) NextSteps(
+	ctx context.Context, current WorkflowSchemaStepName, output json.RawMessage) ([]WorkflowSchemaStep, error) {
+	i := w.index(current)
+	if i < 0 {
+		return nil, cerror.NewF(
+			ctx, cerror.KindNotExist, "step [%s] doesn't exist in the workflow schema [%s]", current, w.name).
+			LogError()
+	}
+
+	names := w.successors(i)
+
+	if bs, ok := w.steps[i].(*WorkflowSchemaBranchStep); ok {
+		chosen, err := bs.choose(ctx, output)
+		if err != nil {
+			return nil, err
 		}
+
+		if !containsStepName(names, chosen) {
+			return nil, cerror.NewF(ctx, cerror.KindInternal,
+				"step [%s] chose [%s] which is not its branch in the workflow schema [%s]", current, chosen, w.name).
+				LogError()
+		}
+
+		names = []WorkflowSchemaStepName{chosen}
 	}
 
-	return nil, false
+	steps := make([]WorkflowSchemaStep, 0, len(names))
+
+	for _, sn := range names {
+		s, _ := w.Step(sn)
+		steps = append(steps, s)
+	}
+
+	return steps, nil
 }
 
 func (w *WorkflowSchema) FirstStep() WorkflowSchemaStep {
 	return w.steps[0]
 }
 
+// JoinOf returns a parallel step which branches are joined by the step with given name.
+// The second returned parameter indicates whether the step is a join step.
+func (w *WorkflowSchema) JoinOf(sn WorkflowSchemaStepName) (*WorkflowSchemaParallelStep, bool) {
+	ps, ok := w.joinOf[sn]
+	return ps, ok
+}
+
+// Concurrent reports whether other steps of the same workflow may run at the same time as the step,
+// i.e. the step is a parallel step or belongs to one of its branches.
+func (w *WorkflowSchema) Concurrent(sn WorkflowSchemaStepName) bool {
+	if _, ok := w.branchOf[sn]; ok {
+		return true
+	}
+
+	s, ok := w.Step(sn)
+	if !ok {
+		return false
+	}
+
+	_, ok = s.(*WorkflowSchemaParallelStep)
+
+	return ok
+}
+
+// Compensation returns the compensation of the step with given name.
+// The second returned parameter is false if the step doesn't exist or has no compensation.
+func (w *WorkflowSchema) Compensation(sn WorkflowSchemaStepName) (*WorkflowSchemaCompensation, bool) {
+	s, ok := w.Step(sn)
+	if !ok {
+		return nil, false
+	}
+
+	return compensationOf(s)
+}
+
+func compensationOf(s WorkflowSchemaStep) (*WorkflowSchemaCompensation, bool) {
+	cs, ok := s.(WorkflowSchemaCompensableStep)
+	if !ok || cs.Compensation() == nil {
+		return nil, false
+	}
+
+	return cs.Compensation(), true
+}
+
+// RetryPolicy returns the retry policy of the step with given name.
+// The second returned parameter is false if the step doesn't exist or has no retry policy.
+func (w *WorkflowSchema) RetryPolicy(sn WorkflowSchemaStepName) (*WorkflowSchemaRetryPolicy, bool) {
+	s, ok := w.Step(sn)
+	if !ok {
+		return nil, false
+	}
+
+	rs, ok := s.(WorkflowSchemaRetryableStep)
+	if !ok || rs.RetryPolicy() == nil {
+		return nil, false
+	}
+
+	return rs.RetryPolicy(), true
+}
+
+func (w *WorkflowSchema) index(sn WorkflowSchemaStepName) int {
+	for i, s := range w.steps {
+		if s.Name() == sn {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// successors returns names of all steps which may follow the step with given index
+func (w *WorkflowSchema) successors(i int) []WorkflowSchemaStepName {
+	switch s := w.steps[i].(type) {
+	case *WorkflowSchemaBranchStep:
+		return s.Branches()
+	case *WorkflowSchemaParallelStep:
+		return s.Branches()
+	case *WorkflowSchemaSimpleStep:
+		if s.next != nil {
+			return []WorkflowSchemaStepName{*s.next}
+		}
+
+		if s.final {
+			return nil
+		}
+	}
+
+	if i < len(w.steps)-1 {
+		return []WorkflowSchemaStepName{w.steps[i+1].Name()}
+	}
+
+	return nil
+}
+
 // Validate checks whether the current workflow's schema is valid
 func (w *WorkflowSchema) validate(ctx context.Context) error {
 	errs := make(map[string]string)
@@ -124,6 +273,27 @@ func (w *WorkflowSchema) validate(ctx context.Context) error {
 		if s.Worker() == nil {
 			errs[fmt.Sprintf("steps[%d].worker", i)] = "step worker is empty"
 		}
+
+		if c, ok := compensationOf(s); ok {
+			if c.Topic() == "" {
+				errs[fmt.Sprintf("steps[%d].compensation.topic", i)] = "step compensation topic is empty"
+			}
+
+			if c.Worker() == nil {
+				errs[fmt.Sprintf("steps[%d].compensation.worker", i)] = "step compensation worker is empty"
+			}
+		}
+
+		if rs, ok := s.(WorkflowSchemaRetryableStep); ok && rs.RetryPolicy() != nil {
+			validateRetryPolicy(i, rs.RetryPolicy(), errs)
+		}
+
+		w.validateTransitions(i, errs)
+	}
+
+	// the graph is checked only for steps which are valid themselves
+	if len(errs) == 0 {
+		w.validateGraph(errs)
 	}
 
 	if len(errs) > 0 {
@@ -132,3 +302,250 @@ func (w *WorkflowSchema) validate(ctx context.Context) error {
 
 	return nil
 }
+
+// validateRetryPolicy checks settings of the retry policy of the step with given index
+func validateRetryPolicy(i int, p *WorkflowSchemaRetryPolicy, errs map[string]string) {
+	if p.MaxAttempts < 1 {
+		errs[fmt.Sprintf("steps[%d].retry.max_attempts", i)] = "step retry max attempts must be positive"
+	}
+
+	if p.Backoff < 0 || p.MaxBackoff < 0 {
+		errs[fmt.Sprintf("steps[%d].retry.backoff", i)] = "step retry backoff is negative"
+	}
+
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		errs[fmt.Sprintf("steps[%d].retry.multiplier", i)] = "step retry multiplier is less than 1"
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs[fmt.Sprintf("steps[%d].retry.jitter", i)] = "step retry jitter must be between 0 and 1"
+	}
+}
+
+// validateTransitions checks that steps referenced by the step with given index exist
+func (w *WorkflowSchema) validateTransitions(i int, errs map[string]string) {
+	checkExists := func(key string, sn WorkflowSchemaStepName) {
+		if w.index(sn) < 0 {
+			errs[fmt.Sprintf("steps[%d].%s", i, key)] = fmt.Sprintf("%s step doesn't exist", sn)
+		}
+	}
+
+	switch s := w.steps[i].(type) {
+	case *WorkflowSchemaSimpleStep:
+		if s.next != nil {
+			checkExists("next", *s.next)
+		}
+	case *WorkflowSchemaBranchStep:
+		if s.choose == nil {
+			errs[fmt.Sprintf("steps[%d].chooser", i)] = "step chooser is empty"
+		}
+
+		if len(s.branches) == 0 {
+			errs[fmt.Sprintf("steps[%d].branches", i)] = "step has no branches"
+		}
+
+		for _, b := range s.branches {
+			checkExists("branches", b)
+		}
+	case *WorkflowSchemaParallelStep:
+		if len(s.branches) == 0 {
+			errs[fmt.Sprintf("steps[%d].branches", i)] = "step has no branches"
+		}
+
+		for _, b := range s.branches {
+			checkExists("branches", b)
+
+			if b == s.join {
+				errs[fmt.Sprintf("steps[%d].branches", i)] = fmt.Sprintf("%s join step is a branch", b)
+			}
+		}
+
+		checkExists("join", s.join)
+	}
+}
+
+// validateGraph checks that all steps are reachable from the first step, there are no cycles
+// and branches of parallel steps reach their join steps independently
+func (w *WorkflowSchema) validateGraph(errs map[string]string) {
+	if cycle := w.findCycle(); len(cycle) > 0 {
+		errs["steps"] = fmt.Sprintf("workflow has a cycle: %s", joinStepNames(cycle, " -> "))
+
+		return
+	}
+
+	reached := w.reachable(0, "")
+
+	for i, s := range w.steps {
+		if !reached[s.Name()] {
+			errs[fmt.Sprintf("steps[%d]", i)] = fmt.Sprintf("%s step is unreachable from the first step", s.Name())
+		}
+	}
+
+	for i, s := range w.steps {
+		if ps, ok := s.(*WorkflowSchemaParallelStep); ok {
+			w.validateParallel(i, ps, errs)
+		}
+	}
+}
+
+func (w *WorkflowSchema) validateParallel(i int, ps *WorkflowSchemaParallelStep, errs map[string]string) {
+	key := fmt.Sprintf("steps[%d].branches", i)
+
+	if other, ok := w.joinOf[ps.join]; ok {
+		errs[fmt.Sprintf("steps[%d].join", i)] = fmt.Sprintf("%s step already joins %s step", ps.join, other.name)
+
+		return
+	}
+
+	w.joinOf[ps.join] = ps
+	members := make(map[WorkflowSchemaStepName]WorkflowSchemaStepName)
+
+	for _, b := range ps.branches {
+		for sn := range w.reachable(w.index(b), ps.join) {
+			if sn == ps.join {
+				continue
+			}
+
+			if other, ok := members[sn]; ok && other != b {
+				errs[key] = fmt.Sprintf("%s step belongs to both %s and %s branches", sn, other, b)
+
+				return
+			}
+
+			members[sn] = b
+
+			if next := w.successors(w.index(sn)); len(next) == 0 {
+				errs[key] = fmt.Sprintf("%s branch completes the workflow before %s join step", b, ps.join)
+
+				return
+			}
+		}
+	}
+
+	// the join step is reached only from the branches
+	for j := range w.steps {
+		sn := w.steps[j].Name()
+
+		if _, ok := members[sn]; ok {
+			continue
+		}
+
+		if containsStepName(w.successors(j), ps.join) {
+			errs[fmt.Sprintf("steps[%d].join", i)] = fmt.Sprintf(
+				"%s join step is reached by %s step which is not a branch of %s step", ps.join, sn, ps.name)
+
+			return
+		}
+	}
+
+	for sn := range members {
+		w.branchOf[sn] = ps
+	}
+}
+
+// reachable returns names of steps reachable from the step with given index including itself.
+// Steps after the stop step aren't visited.
+func (w *WorkflowSchema) reachable(from int, stop WorkflowSchemaStepName) map[WorkflowSchemaStepName]bool {
+	reached := make(map[WorkflowSchemaStepName]bool)
+	queue := []int{from}
+
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+
+		sn := w.steps[i].Name()
+		if reached[sn] {
+			continue
+		}
+
+		reached[sn] = true
+
+		if sn == stop {
+			continue
+		}
+
+		for _, next := range w.successors(i) {
+			queue = append(queue, w.index(next))
+		}
+	}
+
+	return reached
+}
+
+// findCycle returns names of steps forming a cycle or nil if the graph is acyclic
+func (w *WorkflowSchema) findCycle() []WorkflowSchemaStepName {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(w.steps))
+	path := make([]int, 0, len(w.steps))
+
+	var visit func(i int) []WorkflowSchemaStepName
+
+	visit = func(i int) []WorkflowSchemaStepName {
+		state[i] = visiting
+		path = append(path, i)
+
+		for _, next := range w.successors(i) {
+			j := w.index(next)
+
+			switch state[j] {
+			case visiting:
+				cycle := make([]WorkflowSchemaStepName, 0, len(path)+1)
+
+				for k := len(path) - 1; k >= 0; k-- {
+					if path[k] == j {
+						for _, p := range path[k:] {
+							cycle = append(cycle, w.steps[p].Name())
+						}
+
+						break
+					}
+				}
+
+				return append(cycle, next)
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		state[i] = visited
+		path = path[:len(path)-1]
+
+		return nil
+	}
+
+	for i := range w.steps {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsStepName(names []WorkflowSchemaStepName, sn WorkflowSchemaStepName) bool {
+	for _, n := range names {
+		if n == sn {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinStepNames(names []WorkflowSchemaStepName, sep string) string {
+	s := make([]string, len(names))
+	for i, n := range names {
+		s[i] = n.String()
+	}
+
+	return strings.Join(s, sep)
+}
@@ -0,0 +1,233 @@
+package entity_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-polygon/pkg/workflow/entity"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func chooseByOutput(_ context.Context, output json.RawMessage) (entity.WorkflowSchemaStepName, error) {
+	var res struct {
+		Next string `json:"next"`
+	}
+
+	if err := json.Unmarshal(output, &res); err != nil {
+		return "", err
+	}
+
+	return entity.WorkflowSchemaStepName(res.Next), nil
+}
+
+func simpleStep(name string) *entity.WorkflowSchemaSimpleStep {
+	return entity.NewWorkflowSchemaSimpleStep(
+		entity.WorkflowSchemaStepName(name), entity.WorkflowSchemaStepTopic("topic-"+name), new(stepWorkerTest))
+}
+
+// dagSchema: check -> (approve | reject), approve -> split -> (ship, bill -> invoice) -> notify, reject is final
+func dagSchema(t *testing.T) *entity.WorkflowSchema {
+	t.Helper()
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "dag",
+		entity.NewWorkflowSchemaBranchStep("check", "topic-check", new(stepWorkerTest), chooseByOutput,
+			"approve", "reject"),
+		simpleStep("reject").SetFinal(),
+		simpleStep("approve").SetNext("split"),
+		entity.NewWorkflowSchemaParallelStep("split", "topic-split", new(stepWorkerTest), "notify", "ship", "bill"),
+		simpleStep("ship").SetNext("notify"),
+		simpleStep("bill"),
+		simpleStep("invoice").SetNext("notify"),
+		simpleStep("notify"),
+	)
+	require.NoError(t, err)
+
+	return schema
+}
+
+func TestWorkflowSchemaNextSteps(t *testing.T) {
+	schema := dagSchema(t)
+
+	stepNames := func(steps []entity.WorkflowSchemaStep) []entity.WorkflowSchemaStepName {
+		names := make([]entity.WorkflowSchemaStepName, len(steps))
+		for i, s := range steps {
+			names[i] = s.Name()
+		}
+
+		return names
+	}
+
+	next, err := schema.NextSteps(_bgCtx, "check", []byte(`{"next":"reject"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"reject"}, stepNames(next))
+
+	_, err = schema.NextSteps(_bgCtx, "check", []byte(`{"next":"split"}`))
+	assert.Equal(t, "step [check] chose [split] which is not its branch in the workflow schema [dag]", err.Error())
+
+	_, err = schema.NextSteps(_bgCtx, "check", []byte(`{`))
+	assert.Error(t, err)
+
+	next, err = schema.NextSteps(_bgCtx, "reject", nil)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+
+	next, err = schema.NextSteps(_bgCtx, "approve", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"split"}, stepNames(next))
+
+	next, err = schema.NextSteps(_bgCtx, "split", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"ship", "bill"}, stepNames(next))
+
+	next, err = schema.NextSteps(_bgCtx, "bill", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"invoice"}, stepNames(next))
+
+	_, err = schema.NextSteps(_bgCtx, "unknown", nil)
+	assert.Equal(t, "step [unknown] doesn't exist in the workflow schema [dag]", err.Error())
+
+	// next steps of branch and parallel steps depend on the output
+	_, ok := schema.NextStep("check")
+	assert.False(t, ok)
+
+	actNext, ok := schema.NextStep("ship")
+	assert.True(t, ok)
+	assert.Equal(t, entity.WorkflowSchemaStepName("notify"), actNext.Name())
+
+	_, ok = schema.NextStep("reject")
+	assert.False(t, ok)
+}
+
+func TestWorkflowSchemaJoin(t *testing.T) {
+	schema := dagSchema(t)
+
+	ps, ok := schema.JoinOf("notify")
+	assert.True(t, ok)
+	assert.Equal(t, entity.WorkflowSchemaStepName("split"), ps.Name())
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"ship", "bill"}, ps.Branches())
+
+	_, ok = schema.JoinOf("invoice")
+	assert.False(t, ok)
+
+	for sn, exp := range map[entity.WorkflowSchemaStepName]bool{
+		"check":   false,
+		"approve": false,
+		"split":   true,
+		"ship":    true,
+		"bill":    true,
+		"invoice": true,
+		"notify":  false,
+	} {
+		assert.Equal(t, exp, schema.Concurrent(sn), sn)
+	}
+}
+
+func TestNewWorkflowSchemaGraphValidation(t *testing.T) {
+	worker := new(stepWorkerTest)
+
+	cases := []struct {
+		name   string
+		steps  []entity.WorkflowSchemaStep
+		expErr map[string]string
+	}{
+		{
+			name: "unknown steps",
+			steps: []entity.WorkflowSchemaStep{
+				entity.NewWorkflowSchemaBranchStep("step1", "topic1", worker, nil, "step4"),
+				simpleStep("step2").SetNext("step5"),
+				entity.NewWorkflowSchemaParallelStep("step3", "topic3", worker, "step6"),
+			},
+			expErr: map[string]string{
+				"steps[0].chooser":  "step chooser is empty",
+				"steps[0].branches": "step4 step doesn't exist",
+				"steps[1].next":     "step5 step doesn't exist",
+				"steps[2].branches": "step has no branches",
+				"steps[2].join":     "step6 step doesn't exist",
+			},
+		},
+		{
+			name: "cycle",
+			steps: []entity.WorkflowSchemaStep{
+				simpleStep("step1"),
+				simpleStep("step2"),
+				simpleStep("step3").SetNext("step2"),
+			},
+			expErr: map[string]string{
+				"steps": "workflow has a cycle: step2 -> step3 -> step2",
+			},
+		},
+		{
+			name: "unreachable",
+			steps: []entity.WorkflowSchemaStep{
+				simpleStep("step1").SetNext("step3"),
+				simpleStep("step2"),
+				simpleStep("step3"),
+			},
+			expErr: map[string]string{
+				"steps[1]": "step2 step is unreachable from the first step",
+			},
+		},
+		{
+			name: "branch doesn't reach join",
+			steps: []entity.WorkflowSchemaStep{
+				entity.NewWorkflowSchemaParallelStep("step1", "topic1", worker, "step4", "step2", "step3"),
+				simpleStep("step2").SetNext("step4"),
+				simpleStep("step3").SetFinal(),
+				simpleStep("step4"),
+			},
+			expErr: map[string]string{
+				"steps[0].branches": "step3 branch completes the workflow before step4 join step",
+			},
+		},
+		{
+			name: "branches overlap",
+			steps: []entity.WorkflowSchemaStep{
+				entity.NewWorkflowSchemaParallelStep("step1", "topic1", worker, "step5", "step2", "step3"),
+				simpleStep("step2").SetNext("step4"),
+				simpleStep("step3").SetNext("step4"),
+				simpleStep("step4"),
+				simpleStep("step5"),
+			},
+			expErr: map[string]string{
+				"steps[0].branches": "step4 step belongs to both step2 and step3 branches",
+			},
+		},
+		{
+			name: "join reached outside branches",
+			steps: []entity.WorkflowSchemaStep{
+				entity.NewWorkflowSchemaBranchStep("step1", "topic1", worker, chooseByOutput, "step2", "step4"),
+				entity.NewWorkflowSchemaParallelStep("step2", "topic2", worker, "step4", "step3"),
+				simpleStep("step3"),
+				simpleStep("step4"),
+			},
+			expErr: map[string]string{
+				"steps[1].join": "step4 join step is reached by step1 step which is not a branch of step2 step",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		_, err := entity.NewWorkflowSchema(_bgCtx, "schema1", c.steps...)
+		require.Error(t, err, c.name)
+		assertMultiValidationError(t, c.expErr, err)
+	}
+}
+
+func TestWorkflowSchemaChooserError(t *testing.T) {
+	chooseErr := errors.New("choose error")
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "schema1",
+		entity.NewWorkflowSchemaBranchStep("step1", "topic1", new(stepWorkerTest),
+			func(_ context.Context, _ json.RawMessage) (entity.WorkflowSchemaStepName, error) {
+				return "", chooseErr
+			}, "step2"),
+		simpleStep("step2"),
+	)
+	require.NoError(t, err)
+
+	_, err = schema.NextSteps(_bgCtx, "step1", nil)
+	require.ErrorIs(t, err, chooseErr)
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"math/rand"
+	"time"
+)
+
+const defaultRetryMultiplier = 2
+
+// WorkflowSchemaRetryPolicy sets how many times and how often a step failed in its worker is run again.
+// Delays grow exponentially: Backoff, Backoff*Multiplier, Backoff*Multiplier^2, ... up to MaxBackoff.
+type WorkflowSchemaRetryPolicy struct {
+	// MaxAttempts is a number of runs of the step including the first one
+	MaxAttempts int
+	// Backoff is a delay before the second run
+	Backoff time.Duration
+	// MaxBackoff limits delays if it isn't zero
+	MaxBackoff time.Duration
+	// Multiplier of the delay for every next run, 2 if zero
+	Multiplier float64
+	// Jitter is a fraction of the delay it's randomly changed by in both directions, e.g. 0.2 for ±20%
+	Jitter float64
+}
+
+// Delay returns a delay before the next run of the step after the given attempt, attempts start from 1
+func (p *WorkflowSchemaRetryPolicy) Delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	d := float64(p.Backoff)
+
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || d < float64(p.MaxBackoff)); i++ {
+		d *= multiplier
+	}
+
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+
+	return time.Duration(d)
+}
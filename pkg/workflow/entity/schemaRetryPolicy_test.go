@@ -0,0 +1,29 @@
+package entity_test
+
+import (
+	"kafka-polygon/pkg/workflow/entity"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestWorkflowSchemaRetryPolicyDelay(t *testing.T) {
+	p := entity.WorkflowSchemaRetryPolicy{MaxAttempts: 10, Backoff: time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 8*time.Second, p.Delay(4))
+
+	p.Multiplier = 3
+	p.MaxBackoff = 5 * time.Second
+	assert.Equal(t, 3*time.Second, p.Delay(2))
+	assert.Equal(t, 5*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(100))
+
+	p.Jitter = 0.2
+
+	for i := 0; i < 100; i++ {
+		d := p.Delay(1)
+		assert.True(t, d >= 800*time.Millisecond && d <= 1200*time.Millisecond, d)
+	}
+}
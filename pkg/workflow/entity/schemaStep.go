@@ -45,3 +45,47 @@ type WorkflowSchemaStep interface {
 	// Business logic handler
 	Worker() WorkflowSchemaStepWorker
 }
+
+// WorkflowSchemaCompensation undoes side effects of a completed step when a later step of the workflow fails.
+// Its worker receives the payload the step was run with, its output is ignored.
+type WorkflowSchemaCompensation struct {
+	topic  WorkflowSchemaStepTopic
+	worker WorkflowSchemaStepWorker
+}
+
+// Message queue topic name with compensation events. It may be shared by compensations of several steps
+func (w *WorkflowSchemaCompensation) Topic() WorkflowSchemaStepTopic {
+	return w.topic
+}
+
+func (w *WorkflowSchemaCompensation) Worker() WorkflowSchemaStepWorker {
+	return w.worker
+}
+
+// WorkflowSchemaCompensableStep may be implemented by a step to declare its compensation.
+type WorkflowSchemaCompensableStep interface {
+	WorkflowSchemaStep
+	// Compensation returns nil if the step has no compensation
+	Compensation() *WorkflowSchemaCompensation
+}
+
+// WorkflowSchemaRetryableStep may be implemented by a step to declare its retry policy.
+type WorkflowSchemaRetryableStep interface {
+	WorkflowSchemaStep
+	// RetryPolicy returns nil if the step has no retry policy
+	RetryPolicy() *WorkflowSchemaRetryPolicy
+}
+
+// stepOptions are optional settings of all kinds of steps
+type stepOptions struct {
+	compensation *WorkflowSchemaCompensation
+	retryPolicy  *WorkflowSchemaRetryPolicy
+}
+
+func (o *stepOptions) Compensation() *WorkflowSchemaCompensation {
+	return o.compensation
+}
+
+func (o *stepOptions) RetryPolicy() *WorkflowSchemaRetryPolicy {
+	return o.retryPolicy
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// WorkflowSchemaBranchChooser chooses the next step of a branch step by the output of its worker
+type WorkflowSchemaBranchChooser func(ctx context.Context, output json.RawMessage) (WorkflowSchemaStepName, error)
+
+// WorkflowSchemaBranchStep represents a conditional workflow's schema step.
+// After its worker is done, one of its branches is chosen by the worker's output and gets it as a payload.
+type WorkflowSchemaBranchStep struct {
+	name     WorkflowSchemaStepName
+	topic    WorkflowSchemaStepTopic
+	worker   WorkflowSchemaStepWorker
+	choose   WorkflowSchemaBranchChooser
+	branches []WorkflowSchemaStepName
+	stepOptions
+}
+
+var (
+	_ WorkflowSchemaCompensableStep = (*WorkflowSchemaBranchStep)(nil)
+	_ WorkflowSchemaRetryableStep   = (*WorkflowSchemaBranchStep)(nil)
+)
+
+func (w *WorkflowSchemaBranchStep) Name() WorkflowSchemaStepName {
+	return w.name
+}
+
+func (w *WorkflowSchemaBranchStep) Topic() WorkflowSchemaStepTopic {
+	return w.topic
+}
+
+func (w *WorkflowSchemaBranchStep) Worker() WorkflowSchemaStepWorker {
+	return w.worker
+}
+
+// SetCompensation sets the worker undoing the step when a later step of the workflow fails
+func (w *WorkflowSchemaBranchStep) SetCompensation(
+	st WorkflowSchemaStepTopic, cw WorkflowSchemaStepWorker) *WorkflowSchemaBranchStep {
+	w.compensation = &WorkflowSchemaCompensation{topic: st, worker: cw}
+
+	return w
+}
+
+// SetRetryPolicy makes the step run again after the worker fails with a retried error (see ProcessingError)
+func (w *WorkflowSchemaBranchStep) SetRetryPolicy(p WorkflowSchemaRetryPolicy) *WorkflowSchemaBranchStep {
+	w.retryPolicy = &p
+
+	return w
+}
+
+// Branches returns names of steps which may be chosen as the next step
+func (w *WorkflowSchemaBranchStep) Branches() []WorkflowSchemaStepName {
+	return w.branches
+}
+
+// NewWorkflowSchemaBranchStep creates a step which is followed by one of the branches chosen by choose
+func NewWorkflowSchemaBranchStep(
+	sn WorkflowSchemaStepName,
+	st WorkflowSchemaStepTopic,
+	w WorkflowSchemaStepWorker,
+	choose WorkflowSchemaBranchChooser,
+	branches ...WorkflowSchemaStepName) *WorkflowSchemaBranchStep {
+	return &WorkflowSchemaBranchStep{
+		name:     sn,
+		topic:    st,
+		worker:   w,
+		choose:   choose,
+		branches: branches,
+	}
+}
@@ -0,0 +1,22 @@
+package entity_test
+
+import (
+	"kafka-polygon/pkg/workflow/entity"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestNewWorkflowSchemaBranchStep(t *testing.T) {
+	name := entity.WorkflowSchemaStepName("step1")
+	topic := entity.WorkflowSchemaStepTopic("topic1")
+	worker := new(stepWorkerTest)
+
+	actStep := entity.NewWorkflowSchemaBranchStep(name, topic, worker, chooseByOutput, "step2", "step3")
+
+	assert.NotNil(t, actStep)
+	assert.Equal(t, name, actStep.Name())
+	assert.Equal(t, topic, actStep.Topic())
+	assert.Equal(t, worker, actStep.Worker())
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"step2", "step3"}, actStep.Branches())
+}
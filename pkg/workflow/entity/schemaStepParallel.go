@@ -0,0 +1,73 @@
+package entity
+
+// WorkflowSchemaParallelStep represents a fan-out workflow's schema step.
+// After its worker is done, all its branches are started in parallel with the worker's output as a payload.
+// Each branch is a sequence of steps ending with the join step. The join step is started once
+// all the branches are done. Its payload is a JSON object with outputs of the last steps of the branches
+// by their names.
+type WorkflowSchemaParallelStep struct {
+	name     WorkflowSchemaStepName
+	topic    WorkflowSchemaStepTopic
+	worker   WorkflowSchemaStepWorker
+	join     WorkflowSchemaStepName
+	branches []WorkflowSchemaStepName
+	stepOptions
+}
+
+var (
+	_ WorkflowSchemaCompensableStep = (*WorkflowSchemaParallelStep)(nil)
+	_ WorkflowSchemaRetryableStep   = (*WorkflowSchemaParallelStep)(nil)
+)
+
+func (w *WorkflowSchemaParallelStep) Name() WorkflowSchemaStepName {
+	return w.name
+}
+
+func (w *WorkflowSchemaParallelStep) Topic() WorkflowSchemaStepTopic {
+	return w.topic
+}
+
+func (w *WorkflowSchemaParallelStep) Worker() WorkflowSchemaStepWorker {
+	return w.worker
+}
+
+// SetCompensation sets the worker undoing the step when a later step of the workflow fails
+func (w *WorkflowSchemaParallelStep) SetCompensation(
+	st WorkflowSchemaStepTopic, cw WorkflowSchemaStepWorker) *WorkflowSchemaParallelStep {
+	w.compensation = &WorkflowSchemaCompensation{topic: st, worker: cw}
+
+	return w
+}
+
+// SetRetryPolicy makes the step run again after the worker fails with a retried error (see ProcessingError)
+func (w *WorkflowSchemaParallelStep) SetRetryPolicy(p WorkflowSchemaRetryPolicy) *WorkflowSchemaParallelStep {
+	w.retryPolicy = &p
+
+	return w
+}
+
+// Branches returns names of the first steps of the parallel branches
+func (w *WorkflowSchemaParallelStep) Branches() []WorkflowSchemaStepName {
+	return w.branches
+}
+
+// Join returns name of the step which is started when all the branches are done
+func (w *WorkflowSchemaParallelStep) Join() WorkflowSchemaStepName {
+	return w.join
+}
+
+// NewWorkflowSchemaParallelStep creates a step which starts the branches in parallel and joins them in the join step
+func NewWorkflowSchemaParallelStep(
+	sn WorkflowSchemaStepName,
+	st WorkflowSchemaStepTopic,
+	w WorkflowSchemaStepWorker,
+	join WorkflowSchemaStepName,
+	branches ...WorkflowSchemaStepName) *WorkflowSchemaParallelStep {
+	return &WorkflowSchemaParallelStep{
+		name:     sn,
+		topic:    st,
+		worker:   w,
+		join:     join,
+		branches: branches,
+	}
+}
@@ -1,13 +1,21 @@
 package entity
 
-// WorkflowSchemaSimpleStep represents a simple linear workflow's schema step.
+// WorkflowSchemaSimpleStep represents a simple workflow's schema step.
+// It is followed by the next step of the schema unless another next step is set by SetNext
+// or the step is the last one of its branch (see SetFinal).
 type WorkflowSchemaSimpleStep struct {
 	name   WorkflowSchemaStepName
 	topic  WorkflowSchemaStepTopic
 	worker WorkflowSchemaStepWorker
+	next   *WorkflowSchemaStepName
+	final  bool
+	stepOptions
 }
 
-var _ WorkflowSchemaStep = (*WorkflowSchemaSimpleStep)(nil)
+var (
+	_ WorkflowSchemaCompensableStep = (*WorkflowSchemaSimpleStep)(nil)
+	_ WorkflowSchemaRetryableStep   = (*WorkflowSchemaSimpleStep)(nil)
+)
 
 func (w *WorkflowSchemaSimpleStep) Name() WorkflowSchemaStepName {
 	return w.name
@@ -21,6 +29,38 @@ func (w *WorkflowSchemaSimpleStep) Worker() WorkflowSchemaStepWorker {
 	return w.worker
 }
 
+// SetCompensation sets the worker undoing the step when a later step of the workflow fails
+func (w *WorkflowSchemaSimpleStep) SetCompensation(
+	st WorkflowSchemaStepTopic, cw WorkflowSchemaStepWorker) *WorkflowSchemaSimpleStep {
+	w.compensation = &WorkflowSchemaCompensation{topic: st, worker: cw}
+
+	return w
+}
+
+// SetRetryPolicy makes the step run again after the worker fails with a retried error (see ProcessingError)
+func (w *WorkflowSchemaSimpleStep) SetRetryPolicy(p WorkflowSchemaRetryPolicy) *WorkflowSchemaSimpleStep {
+	w.retryPolicy = &p
+
+	return w
+}
+
+// SetNext sets the step that follows this step instead of the next step of the schema,
+// e.g. to continue a branch after a conditional step or to reach a join step.
+func (w *WorkflowSchemaSimpleStep) SetNext(sn WorkflowSchemaStepName) *WorkflowSchemaSimpleStep {
+	w.next = &sn
+	w.final = false
+
+	return w
+}
+
+// SetFinal makes the step complete the workflow even if it isn't the last step of the schema
+func (w *WorkflowSchemaSimpleStep) SetFinal() *WorkflowSchemaSimpleStep {
+	w.next = nil
+	w.final = true
+
+	return w
+}
+
 func NewWorkflowSchemaSimpleStep(
 	sn WorkflowSchemaStepName, st WorkflowSchemaStepTopic, w WorkflowSchemaStepWorker) *WorkflowSchemaSimpleStep {
 	return &WorkflowSchemaSimpleStep{
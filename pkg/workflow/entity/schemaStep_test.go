@@ -3,6 +3,7 @@ package entity_test
 import (
 	"kafka-polygon/pkg/workflow/entity"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 )
@@ -26,3 +27,83 @@ func TestPointerWorkflowSchemaStepTopic(t *testing.T) {
 	s := entity.WorkflowSchemaStepTopic("hello")
 	assert.Equal(t, &s, entity.PointerWorkflowSchemaStepTopic(s.String()))
 }
+
+func TestWorkflowSchemaCompensation(t *testing.T) {
+	compensation := new(stepWorkerTest)
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "saga",
+		simpleStep("reserve").SetCompensation("topic-compensation", compensation),
+		entity.NewWorkflowSchemaBranchStep("check", "topic-check", new(stepWorkerTest), chooseByOutput, "bill").
+			SetCompensation("topic-compensation", compensation),
+		simpleStep("bill"),
+	)
+	assert.NoError(t, err)
+
+	actCompensation, ok := schema.Compensation("reserve")
+	assert.True(t, ok)
+	assert.Equal(t, entity.WorkflowSchemaStepTopic("topic-compensation"), actCompensation.Topic())
+	assert.Equal(t, compensation, actCompensation.Worker())
+
+	_, ok = schema.Compensation("check")
+	assert.True(t, ok)
+
+	_, ok = schema.Compensation("bill")
+	assert.False(t, ok)
+
+	_, ok = schema.Compensation("unknown")
+	assert.False(t, ok)
+
+	// compensation without topic and worker
+	_, err = entity.NewWorkflowSchema(_bgCtx, "saga",
+		simpleStep("reserve"),
+		entity.NewWorkflowSchemaParallelStep("split", "topic-split", new(stepWorkerTest), "notify", "ship").
+			SetCompensation("", nil),
+		simpleStep("ship").SetNext("notify"),
+		simpleStep("notify"),
+	)
+	assert.Error(t, err)
+	assertMultiValidationError(t, map[string]string{
+		"steps[1].compensation.topic":  "step compensation topic is empty",
+		"steps[1].compensation.worker": "step compensation worker is empty",
+	}, err)
+}
+
+func TestWorkflowSchemaRetryPolicy(t *testing.T) {
+	policy := entity.WorkflowSchemaRetryPolicy{MaxAttempts: 3, Backoff: time.Second}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "retry",
+		simpleStep("fetch").SetRetryPolicy(policy),
+		entity.NewWorkflowSchemaBranchStep("check", "topic-check", new(stepWorkerTest), chooseByOutput, "bill").
+			SetRetryPolicy(policy),
+		simpleStep("bill"),
+	)
+	assert.NoError(t, err)
+
+	actPolicy, ok := schema.RetryPolicy("fetch")
+	assert.True(t, ok)
+	assert.Equal(t, policy, *actPolicy)
+
+	_, ok = schema.RetryPolicy("check")
+	assert.True(t, ok)
+
+	_, ok = schema.RetryPolicy("bill")
+	assert.False(t, ok)
+
+	_, ok = schema.RetryPolicy("unknown")
+	assert.False(t, ok)
+
+	_, err = entity.NewWorkflowSchema(_bgCtx, "retry",
+		simpleStep("fetch"),
+		entity.NewWorkflowSchemaParallelStep("split", "topic-split", new(stepWorkerTest), "notify", "ship").
+			SetRetryPolicy(entity.WorkflowSchemaRetryPolicy{Backoff: -time.Second, Multiplier: 0.5, Jitter: 2}),
+		simpleStep("ship").SetNext("notify"),
+		simpleStep("notify"),
+	)
+	assert.Error(t, err)
+	assertMultiValidationError(t, map[string]string{
+		"steps[1].retry.max_attempts": "step retry max attempts must be positive",
+		"steps[1].retry.backoff":      "step retry backoff is negative",
+		"steps[1].retry.multiplier":   "step retry multiplier is less than 1",
+		"steps[1].retry.jitter":       "step retry jitter must be between 0 and 1",
+	}, err)
+}
@@ -11,6 +11,14 @@ const (
 	WorkflowStatusInProgress WorkflowStatus = "IN_PROGRESS"
 	WorkflowStatusFailed     WorkflowStatus = "FAILED"
 	WorkflowStatusSuccess    WorkflowStatus = "SUCCESS"
+	// WorkflowStatusCompensating is set for a failed workflow while its completed steps are being compensated
+	WorkflowStatusCompensating WorkflowStatus = "COMPENSATING"
+	// WorkflowStatusCompensated is set for a failed workflow when all its completed steps are compensated
+	WorkflowStatusCompensated WorkflowStatus = "COMPENSATED"
+	// WorkflowStatusCancelled is set for a workflow stopped for good, its events are skipped
+	WorkflowStatusCancelled WorkflowStatus = "CANCELLED"
+	// WorkflowStatusPaused is set for a workflow which events are parked until it's resumed
+	WorkflowStatusPaused WorkflowStatus = "PAUSED"
 )
 
 type WorkflowStatus string
@@ -66,6 +74,29 @@ type WorkflowStep struct {
 	Name      WorkflowSchemaStepName `bson:"name" json:"name"`
 	Data      json.RawMessage        `bson:"data" json:"data"`
 	Metadata  WorkflowStepMetadata   `bson:"metadata" json:"metadata"`
+	// From is set for an input of a join step: Data is the output of the last step of a parallel branch
+	From WorkflowSchemaStepName `bson:"from,omitempty" json:"from,omitempty"`
+	// Compensated is set for a record of the step compensation completed after the workflow failure
+	Compensated bool `bson:"compensated,omitempty" json:"compensated,omitempty"`
+	// Attempt is a number of the run of a step with a retry policy starting from 1
+	Attempt int `bson:"attempt,omitempty" json:"attempt,omitempty"`
+}
+
+// IsJoinInput reports whether the step is an output of a parallel branch waiting for the join step
+func (w *WorkflowStep) IsJoinInput() bool {
+	return w.From != ""
+}
+
+// LastRunStep returns the last step run by the workflow.
+// Inputs of join steps saved by parallel branches and records of compensations are skipped
+func LastRunStep(steps []*WorkflowStep) *WorkflowStep {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if !steps[i].IsJoinInput() && !steps[i].Compensated {
+			return steps[i]
+		}
+	}
+
+	return nil
 }
 
 type WorkflowStepMetadata struct {
@@ -1,7 +1,9 @@
 package entity
 
 import (
+	"context"
 	"encoding/json"
+	"kafka-polygon/pkg/http/consts"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -10,8 +12,24 @@ import (
 const (
 	WorkflowHistoryTypeRestart     WorkflowHistoryType = "RESTART"
 	WorkflowHistoryTypeRestartFrom WorkflowHistoryType = "RESTART_FROM"
+	WorkflowHistoryTypeCancel      WorkflowHistoryType = "CANCEL"
+	WorkflowHistoryTypePause       WorkflowHistoryType = "PAUSE"
+	WorkflowHistoryTypeResume      WorkflowHistoryType = "RESUME"
+	// WorkflowHistoryTypeRun is a transition of a workflow to IN_PROGRESS except resuming, e.g. a restarted one
+	WorkflowHistoryTypeRun WorkflowHistoryType = "RUN"
+	// WorkflowHistoryTypeComplete is a transition of a workflow to SUCCESS
+	WorkflowHistoryTypeComplete WorkflowHistoryType = "COMPLETE"
+	// WorkflowHistoryTypeCompensate is a transition of a failed workflow to COMPENSATING
+	WorkflowHistoryTypeCompensate WorkflowHistoryType = "COMPENSATE"
+	// WorkflowHistoryTypeCompensated is a transition of a workflow to COMPENSATED
+	WorkflowHistoryTypeCompensated WorkflowHistoryType = "COMPENSATED"
+	// WorkflowHistoryTypeFail is a transition of a workflow to FAILED
+	WorkflowHistoryTypeFail WorkflowHistoryType = "FAIL"
 )
 
+// emptyHistoryInput is saved for a workflow without input, as the input of history can't be null
+var emptyHistoryInput = json.RawMessage(`{}`)
+
 type WorkflowHistoryType string
 
 func (w WorkflowHistoryType) String() string {
@@ -37,3 +55,68 @@ type WorkflowHistory struct {
 	WorkflowErrorKind *WorkflowErrorKind     `bson:"workflow_error_kind" json:"workflow_error_kind" bun:"workflow_error_kind"`
 	RequestID         *string                `bson:"request_id" json:"request_id" bun:"request_id"`
 }
+
+// TransitionHistoryType returns the type of history of the transition of a workflow between the statuses
+func TransitionHistoryType(from, to WorkflowStatus) WorkflowHistoryType {
+	switch to {
+	case WorkflowStatusPaused:
+		return WorkflowHistoryTypePause
+	case WorkflowStatusCancelled:
+		return WorkflowHistoryTypeCancel
+	case WorkflowStatusInProgress:
+		if from == WorkflowStatusPaused {
+			return WorkflowHistoryTypeResume
+		}
+
+		return WorkflowHistoryTypeRun
+	case WorkflowStatusSuccess:
+		return WorkflowHistoryTypeComplete
+	case WorkflowStatusCompensating:
+		return WorkflowHistoryTypeCompensate
+	case WorkflowStatusCompensated:
+		return WorkflowHistoryTypeCompensated
+	}
+
+	return WorkflowHistoryTypeFail
+}
+
+// NewTransitionHistory returns the history of the transition of the workflow from its current status to the given one.
+// The input of the history is the payload of the last run step.
+func NewTransitionHistory(ctx context.Context, id ID, w *Workflow, status WorkflowStatus) *WorkflowHistory {
+	var stepName WorkflowSchemaStepName
+
+	payload := w.Input
+
+	if lastStep := LastRunStep(w.Steps); lastStep != nil {
+		stepName = lastStep.Name
+		payload = lastStep.Data
+	}
+
+	if payload == nil {
+		payload = emptyHistoryInput
+	}
+
+	return &WorkflowHistory{
+		ID:                id,
+		CreatedAt:         time.Now().UTC(),
+		Type:              TransitionHistoryType(w.Status, status),
+		Input:             payload,
+		InputPrevious:     payload,
+		StepName:          stepName,
+		WorkflowID:        w.ID,
+		WorkflowStatus:    w.Status,
+		WorkflowError:     w.Error,
+		WorkflowErrorKind: w.ErrorKind,
+		RequestID:         RequestIDFromContext(ctx),
+	}
+}
+
+// RequestIDFromContext extracts request id value from a given context.
+// If there is no requestID in context, nil is returned.
+func RequestIDFromContext(ctx context.Context) *string {
+	if s, ok := ctx.Value(consts.HeaderXRequestID).(string); ok && s != "" {
+		return &s
+	}
+
+	return nil
+}
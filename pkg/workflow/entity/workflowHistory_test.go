@@ -1,6 +1,9 @@
 package entity_test
 
 import (
+	"context"
+	"kafka-polygon/pkg/converto"
+	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/workflow/entity"
 	"testing"
 
@@ -16,3 +19,62 @@ func TestPointerWorkflowHistoryType(t *testing.T) {
 	s := entity.WorkflowHistoryType("hello")
 	assert.Equal(t, &s, entity.PointerWorkflowHistoryType(s.String()))
 }
+
+func TestNewTransitionHistory(t *testing.T) {
+	t.Parallel()
+
+	w := &entity.Workflow{
+		ID:     entity.ID("123"),
+		Status: entity.WorkflowStatusPaused,
+		Input:  []byte(`{"input":1}`),
+		Steps: []*entity.WorkflowStep{
+			{Name: "step1", Data: []byte(`{"step":1}`)},
+			{Name: "step2", Data: []byte(`{"step":2}`)},
+			{Name: "step3", Data: []byte(`{"step":3}`), From: "step2"},
+		},
+	}
+
+	ctx := context.WithValue(_bgCtx, consts.HeaderXRequestID, "request-id") //nolint:staticcheck
+
+	wh := entity.NewTransitionHistory(ctx, "h1", w, entity.WorkflowStatusInProgress)
+	assert.False(t, wh.CreatedAt.IsZero())
+	assert.Equal(t, &entity.WorkflowHistory{
+		ID:             "h1",
+		CreatedAt:      wh.CreatedAt,
+		Type:           entity.WorkflowHistoryTypeResume,
+		Input:          []byte(`{"step":2}`),
+		InputPrevious:  []byte(`{"step":2}`),
+		StepName:       "step2",
+		WorkflowID:     w.ID,
+		WorkflowStatus: entity.WorkflowStatusPaused,
+		RequestID:      converto.StringPointer("request-id"),
+	}, wh)
+
+	// the workflow without run steps has its input
+	w.Steps = nil
+	wh = entity.NewTransitionHistory(_bgCtx, "h2", w, entity.WorkflowStatusCancelled)
+	assert.Equal(t, entity.WorkflowHistoryTypeCancel, wh.Type)
+	assert.Equal(t, w.Input, wh.Input)
+	assert.Nil(t, wh.RequestID)
+}
+
+func TestTransitionHistoryType(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		from, to entity.WorkflowStatus
+		exp      entity.WorkflowHistoryType
+	}{
+		{from: entity.WorkflowStatusInProgress, to: entity.WorkflowStatusPaused, exp: entity.WorkflowHistoryTypePause},
+		{from: entity.WorkflowStatusPaused, to: entity.WorkflowStatusInProgress, exp: entity.WorkflowHistoryTypeResume},
+		{from: entity.WorkflowStatusFailed, to: entity.WorkflowStatusInProgress, exp: entity.WorkflowHistoryTypeRun},
+		{from: entity.WorkflowStatusFailed, to: entity.WorkflowStatusCancelled, exp: entity.WorkflowHistoryTypeCancel},
+		{from: entity.WorkflowStatusInProgress, to: entity.WorkflowStatusSuccess, exp: entity.WorkflowHistoryTypeComplete},
+		{from: entity.WorkflowStatusFailed, to: entity.WorkflowStatusCompensating, exp: entity.WorkflowHistoryTypeCompensate},
+		{from: entity.WorkflowStatusCompensating, to: entity.WorkflowStatusCompensated,
+			exp: entity.WorkflowHistoryTypeCompensated},
+		{from: entity.WorkflowStatusInProgress, to: entity.WorkflowStatusFailed, exp: entity.WorkflowHistoryTypeFail},
+	} {
+		assert.Equal(t, tc.exp, entity.TransitionHistoryType(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WorkflowScheduledEvent is a workflow event kept in the store until it's sent to the queue.
+// A parked event of a paused workflow is sent when the workflow is resumed instead of its SendAt time.
+type WorkflowScheduledEvent struct {
+	bun.BaseModel `bun:"table:workflow_schedule"`
+	ID            ID              `bson:"_id" json:"id" bun:"id,pk"`
+	CreatedAt     time.Time       `bson:"created_at,omitempty" json:"created_at,omitempty" bun:"created_at"`
+	WorkflowID    ID              `bson:"workflow_id" json:"workflow_id" bun:"workflow_id"`
+	Topic         string          `bson:"topic" json:"topic" bun:"topic"`
+	Event         json.RawMessage `bson:"event" json:"event" bun:"event,type:jsonb"`
+	SendAt        time.Time       `bson:"send_at" json:"send_at" bun:"send_at"`
+	Parked        bool            `bson:"parked" json:"parked" bun:"parked"`
+}
@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	WorkflowStepOutcomeSuccess WorkflowStepOutcome = "SUCCESS"
+	WorkflowStepOutcomeFailed  WorkflowStepOutcome = "FAILED"
+	// WorkflowStepOutcomeRetried is set for a failed run of the step which is going to be run again
+	WorkflowStepOutcomeRetried WorkflowStepOutcome = "RETRIED"
+)
+
+type WorkflowStepOutcome string
+
+func (w WorkflowStepOutcome) String() string {
+	return string(w)
+}
+
+// WorkflowStepAttempt is a record of a single run of the step worker.
+// Unlike WorkflowStep records, which keep the last run of the step, attempts aren't replaced on retries.
+// Compensation is set for a run of the step compensation worker, OutputSize is a size of the worker output in bytes.
+type WorkflowStepAttempt struct {
+	bun.BaseModel `bun:"table:workflow_step_attempt"`
+	ID            ID                     `bson:"_id" json:"id" bun:"id,pk"`
+	WorkflowID    ID                     `bson:"workflow_id" json:"workflow_id" bun:"workflow_id"`
+	StepName      WorkflowSchemaStepName `bson:"step_name" json:"step_name" bun:"step_name"`
+	Attempt       int                    `bson:"attempt" json:"attempt" bun:"attempt"`
+	Compensation  bool                   `bson:"compensation" json:"compensation" bun:"compensation"`
+	EventID       string                 `bson:"event_id" json:"event_id" bun:"event_id"`
+	StartedAt     time.Time              `bson:"started_at" json:"started_at" bun:"started_at"`
+	FinishedAt    time.Time              `bson:"finished_at" json:"finished_at" bun:"finished_at"`
+	Outcome       WorkflowStepOutcome    `bson:"outcome" json:"outcome" bun:"outcome"`
+	Error         *WorkflowErrorMsg      `bson:"error" json:"error" bun:"error"`
+	ErrorKind     *WorkflowErrorKind     `bson:"error_kind" json:"error_kind" bun:"error_kind"`
+	OutputSize    int                    `bson:"output_size" json:"output_size" bun:"output_size"`
+	RequestID     *string                `bson:"request_id" json:"request_id" bun:"request_id"`
+}
+
+// Duration returns how long the worker was running
+func (w *WorkflowStepAttempt) Duration() time.Duration {
+	return w.FinishedAt.Sub(w.StartedAt)
+}
@@ -0,0 +1,20 @@
+package entity_test
+
+import (
+	"kafka-polygon/pkg/workflow/entity"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestWorkflowStepOutcomeString(t *testing.T) {
+	s := "hello"
+	assert.Equal(t, s, entity.WorkflowStepOutcome(s).String())
+}
+
+func TestWorkflowStepAttemptDuration(t *testing.T) {
+	now := time.Now()
+	a := &entity.WorkflowStepAttempt{StartedAt: now, FinishedAt: now.Add(time.Second)}
+	assert.Equal(t, time.Second, a.Duration())
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"sort"
+	"time"
+)
+
+// WorkflowTimeline is everything that happened to a workflow: runs of its steps and operations on it
+type WorkflowTimeline struct {
+	Workflow *Workflow                `json:"workflow"`
+	Entries  []*WorkflowTimelineEntry `json:"entries"`
+}
+
+// WorkflowTimelineEntry is either a step attempt or a history record
+type WorkflowTimelineEntry struct {
+	Time        time.Time            `json:"time"`
+	StepAttempt *WorkflowStepAttempt `json:"step_attempt,omitempty"`
+	History     *WorkflowHistory     `json:"history,omitempty"`
+}
+
+// NewWorkflowTimeline merges step attempts and history records of the workflow in chronological order.
+// Step attempts are placed by their start time.
+func NewWorkflowTimeline(w *Workflow, attempts []*WorkflowStepAttempt, history []*WorkflowHistory) *WorkflowTimeline {
+	entries := make([]*WorkflowTimelineEntry, 0, len(attempts)+len(history))
+
+	for _, a := range attempts {
+		entries = append(entries, &WorkflowTimelineEntry{Time: a.StartedAt, StepAttempt: a})
+	}
+
+	for _, h := range history {
+		entries = append(entries, &WorkflowTimelineEntry{Time: h.CreatedAt, History: h})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return &WorkflowTimeline{Workflow: w, Entries: entries}
+}
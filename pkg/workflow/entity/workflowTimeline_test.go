@@ -0,0 +1,33 @@
+package entity_test
+
+import (
+	"kafka-polygon/pkg/workflow/entity"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestNewWorkflowTimeline(t *testing.T) {
+	now := time.Now()
+	w := &entity.Workflow{ID: "123"}
+
+	first := &entity.WorkflowStepAttempt{ID: "1", StartedAt: now}
+	retried := &entity.WorkflowStepAttempt{ID: "2", StartedAt: now.Add(2 * time.Second)}
+	paused := &entity.WorkflowHistory{ID: "3", CreatedAt: now.Add(time.Second)}
+	resumed := &entity.WorkflowHistory{ID: "4", CreatedAt: now.Add(3 * time.Second)}
+
+	timeline := entity.NewWorkflowTimeline(w, []*entity.WorkflowStepAttempt{first, retried},
+		[]*entity.WorkflowHistory{paused, resumed})
+
+	assert.Equal(t, w, timeline.Workflow)
+	assert.Equal(t, []*entity.WorkflowTimelineEntry{
+		{Time: first.StartedAt, StepAttempt: first},
+		{Time: paused.CreatedAt, History: paused},
+		{Time: retried.StartedAt, StepAttempt: retried},
+		{Time: resumed.CreatedAt, History: resumed},
+	}, timeline.Entries)
+
+	timeline = entity.NewWorkflowTimeline(w, nil, nil)
+	assert.Equal(t, 0, len(timeline.Entries))
+}
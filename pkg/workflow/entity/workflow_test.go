@@ -36,3 +36,8 @@ func TestPointerWorkflowErrorKind(t *testing.T) {
 	s := entity.WorkflowErrorKind("hello")
 	assert.Equal(t, &s, entity.PointerWorkflowErrorKind(s.String()))
 }
+
+func TestWorkflowStepIsJoinInput(t *testing.T) {
+	assert.False(t, (&entity.WorkflowStep{Name: "step1"}).IsJoinInput())
+	assert.True(t, (&entity.WorkflowStep{Name: "step1", From: "step2"}).IsJoinInput())
+}
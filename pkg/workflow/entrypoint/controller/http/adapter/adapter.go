@@ -14,4 +14,8 @@ type UseCase interface {
 		ctx context.Context, workflowID entity.ID, payload json.RawMessage) error
 	RestartWorkflowFrom(
 		ctx context.Context, workflowID entity.ID, from entity.WorkflowSchemaStepName, payload json.RawMessage) error
+	CancelWorkflow(ctx context.Context, workflowID entity.ID) error
+	PauseWorkflow(ctx context.Context, workflowID entity.ID) error
+	ResumeWorkflow(ctx context.Context, workflowID entity.ID) error
+	WorkflowTimeline(ctx context.Context, workflowID entity.ID) (*entity.WorkflowTimeline, error)
 }
@@ -47,6 +47,34 @@ func (a *Adapter) RegisterWorkflowRoutes(ctx context.Context, opts http.Option)
 		}
 	})
 
+	prefixRouter.Handle(http.MethodCancelWorkflow, http.RouteCancelWorkflow, func(c *gin.Context) {
+		if err := a.CancelWorkflow(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	prefixRouter.Handle(http.MethodPauseWorkflow, http.RoutePauseWorkflow, func(c *gin.Context) {
+		if err := a.PauseWorkflow(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	prefixRouter.Handle(http.MethodResumeWorkflow, http.RouteResumeWorkflow, func(c *gin.Context) {
+		if err := a.ResumeWorkflow(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
+	prefixRouter.Handle(http.MethodWorkflowTimeline, http.RouteWorkflowTimeline, func(c *gin.Context) {
+		if err := a.WorkflowTimeline(c); err != nil {
+			cerror.LogHTTPHandlerErrorCtx(c, err)
+			util.AbortWithError(c, err)
+		}
+	})
+
 	return nil
 }
 
@@ -102,3 +130,44 @@ func (a *Adapter) RestartWorkflowFrom(ctx *gin.Context) error {
 
 	return ctx.Err()
 }
+
+func (a *Adapter) CancelWorkflow(ctx *gin.Context) error {
+	if err := a.uc.CancelWorkflow(ctx, entity.ID(ctx.Param(http.QueryParamID))); err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusCancelWorkflow, "")
+
+	return ctx.Err()
+}
+
+func (a *Adapter) PauseWorkflow(ctx *gin.Context) error {
+	if err := a.uc.PauseWorkflow(ctx, entity.ID(ctx.Param(http.QueryParamID))); err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusPauseWorkflow, "")
+
+	return ctx.Err()
+}
+
+func (a *Adapter) ResumeWorkflow(ctx *gin.Context) error {
+	if err := a.uc.ResumeWorkflow(ctx, entity.ID(ctx.Param(http.QueryParamID))); err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusResumeWorkflow, "")
+
+	return ctx.Err()
+}
+
+func (a *Adapter) WorkflowTimeline(ctx *gin.Context) error {
+	timeline, err := a.uc.WorkflowTimeline(ctx, entity.ID(ctx.Param(http.QueryParamID)))
+	if err != nil {
+		return err
+	}
+
+	ctx.JSON(http.SuccessStatusWorkflowTimeline, &http.TimelineResponse{Data: timeline})
+
+	return ctx.Err()
+}
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 )
@@ -29,6 +30,10 @@ type mockUseCase struct {
 		ctx context.Context, workflowID entity.ID, payload json.RawMessage) error
 	restartWorkflowFromFunc func(
 		ctx context.Context, workflowID entity.ID, from entity.WorkflowSchemaStepName, payload json.RawMessage) error
+	cancelWorkflowFunc   func(ctx context.Context, workflowID entity.ID) error
+	pauseWorkflowFunc    func(ctx context.Context, workflowID entity.ID) error
+	resumeWorkflowFunc   func(ctx context.Context, workflowID entity.ID) error
+	workflowTimelineFunc func(ctx context.Context, workflowID entity.ID) (*entity.WorkflowTimeline, error)
 }
 
 func (m *mockUseCase) SearchWorkflows(
@@ -45,6 +50,22 @@ func (m *mockUseCase) RestartWorkflowFrom(
 	return m.restartWorkflowFromFunc(ctx, workflowID, from, payload)
 }
 
+func (m *mockUseCase) CancelWorkflow(ctx context.Context, workflowID entity.ID) error {
+	return m.cancelWorkflowFunc(ctx, workflowID)
+}
+
+func (m *mockUseCase) PauseWorkflow(ctx context.Context, workflowID entity.ID) error {
+	return m.pauseWorkflowFunc(ctx, workflowID)
+}
+
+func (m *mockUseCase) ResumeWorkflow(ctx context.Context, workflowID entity.ID) error {
+	return m.resumeWorkflowFunc(ctx, workflowID)
+}
+
+func (m *mockUseCase) WorkflowTimeline(ctx context.Context, workflowID entity.ID) (*entity.WorkflowTimeline, error) {
+	return m.workflowTimelineFunc(ctx, workflowID)
+}
+
 func TestSearchWorkflows(t *testing.T) {
 	expSearchParams := entity.SearchWorkflowParams{
 		ID:     entity.PointerID("123"),
@@ -156,6 +177,80 @@ func TestRestartWorkflowFrom(t *testing.T) {
 	assert.True(t, isCalled)
 }
 
+func TestWorkflowTransitions(t *testing.T) {
+	expWorkflowID := entity.ID("123")
+	called := make([]string, 0)
+
+	transition := func(name string) func(ctx context.Context, workflowID entity.ID) error {
+		return func(ctx context.Context, workflowID entity.ID) error {
+			called = append(called, name)
+			assert.Equal(t, expWorkflowID, workflowID)
+
+			return nil
+		}
+	}
+
+	uc := &mockUseCase{
+		cancelWorkflowFunc: transition("cancel"),
+		pauseWorkflowFunc:  transition("pause"),
+		resumeWorkflowFunc: transition("resume"),
+	}
+	srv := newServer(uc)
+
+	for _, name := range []string{"pause", "resume", "cancel"} {
+		testByModel(t, srv, &testModel{
+			method:       http.MethodPost,
+			route:        fmt.Sprintf("/workflows/%s/%s", name, expWorkflowID),
+			req:          nil,
+			dst:          nil,
+			expectedCode: http.StatusOK,
+		})
+	}
+
+	assert.Equal(t, []string{"pause", "resume", "cancel"}, called)
+}
+
+func TestWorkflowTimeline(t *testing.T) {
+	expWorkflowID := entity.ID("123")
+	expTimeline := &entity.WorkflowTimeline{
+		Workflow: &entity.Workflow{ID: expWorkflowID, Status: entity.WorkflowStatusSuccess, Input: []byte("{}")},
+		Entries: []*entity.WorkflowTimelineEntry{{
+			Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			StepAttempt: &entity.WorkflowStepAttempt{
+				ID:         "1",
+				WorkflowID: expWorkflowID,
+				StepName:   "step1",
+				Attempt:    1,
+				StartedAt:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+				FinishedAt: time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC),
+				Outcome:    entity.WorkflowStepOutcomeSuccess,
+			},
+		}},
+	}
+
+	uc := &mockUseCase{
+		workflowTimelineFunc: func(ctx context.Context, workflowID entity.ID) (*entity.WorkflowTimeline, error) {
+			assert.Equal(t, expWorkflowID, workflowID)
+
+			return expTimeline, nil
+		},
+	}
+
+	tm := &testModel{
+		method:       http.MethodGet,
+		route:        fmt.Sprintf("/workflows/%s/timeline", expWorkflowID),
+		req:          nil,
+		dst:          new(controllerHTTP.TimelineResponse),
+		expectedCode: http.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*controllerHTTP.TimelineResponse)
+			assert.True(t, ok)
+			assert.Equal(t, expTimeline, body.Data)
+		}}
+
+	testByModel(t, newServer(uc), tm)
+}
+
 type testModel struct {
 	method       string
 	route        string
@@ -11,16 +11,28 @@ const (
 	RouteSearchWorkflows     = "/"
 	RouteRestartWorkflow     = "/restart/:id"
 	RouteRestartWorkflowFrom = "/restart/from/:id"
+	RouteCancelWorkflow      = "/cancel/:id"
+	RoutePauseWorkflow       = "/pause/:id"
+	RouteResumeWorkflow      = "/resume/:id"
+	RouteWorkflowTimeline    = "/:id/timeline"
 
 	MethodSearchWorkflows     = http.MethodGet
 	MethodRestartWorkflow     = http.MethodPost
 	MethodRestartWorkflowFrom = http.MethodPost
+	MethodCancelWorkflow      = http.MethodPost
+	MethodPauseWorkflow       = http.MethodPost
+	MethodResumeWorkflow      = http.MethodPost
+	MethodWorkflowTimeline    = http.MethodGet
 
 	QueryParamID = "id"
 
 	SuccessStatusSearchWorkflows     = http.StatusOK
 	SuccessStatusRestartWorkflow     = http.StatusOK
 	SuccessStatusRestartWorkflowFrom = http.StatusOK
+	SuccessStatusCancelWorkflow      = http.StatusOK
+	SuccessStatusPauseWorkflow       = http.StatusOK
+	SuccessStatusResumeWorkflow      = http.StatusOK
+	SuccessStatusWorkflowTimeline    = http.StatusOK
 )
 
 type RestartWorkflowRequest struct {
@@ -37,6 +49,10 @@ type SearchResponse struct {
 	Paging entity.Paging      `json:"paging"`
 }
 
+type TimelineResponse struct {
+	Data *entity.WorkflowTimeline `json:"data"`
+}
+
 type ServerAdapter interface {
 	RegisterWorkflowRoutes(ctx context.Context, opts Option) error
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"kafka-polygon/pkg/cerror"
-	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/workflow/entity"
 	"time"
 )
@@ -23,6 +22,9 @@ type Orchestrator interface {
 		schemaName entity.WorkflowSchemaName,
 		stepName entity.WorkflowSchemaStepName,
 		payload json.RawMessage) (entity.ID, error)
+	Pause(ctx context.Context, workflowID entity.ID) error
+	Resume(ctx context.Context, workflowID entity.ID) error
+	Cancel(ctx context.Context, workflowID entity.ID) error
 }
 
 type Store interface {
@@ -30,6 +32,8 @@ type Store interface {
 	GetWorkflowByID(ctx context.Context, id entity.ID) (*entity.Workflow, error)
 	SearchWorkflows(ctx context.Context, params entity.SearchWorkflowParams) (*entity.SearchWorkflowResult, error)
 	CreateWorkflowHistory(ctx context.Context, wh *entity.WorkflowHistory) error
+	GetWorkflowHistory(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error)
+	GetStepAttempts(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error)
 }
 
 type UseCase struct {
@@ -59,12 +63,18 @@ func (uc *UseCase) RestartWorkflow(
 	}
 
 	if workflowRecord.Status != entity.WorkflowStatusFailed {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "workflow is not in %s status", entity.WorkflowStatusFailed).
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow is not in %s status", entity.WorkflowStatusFailed).
 			LogError()
 	}
 
-	if workflowRecord.Input == nil &&
-		(len(workflowRecord.Steps) == 0 || workflowRecord.Steps[len(workflowRecord.Steps)-1].Data == nil) {
+	// the failed step can't be run again when steps before it are undone
+	if len(workflowRecord.Steps) > 0 && workflowRecord.Steps[len(workflowRecord.Steps)-1].Compensated {
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow is partially compensated").LogError()
+	}
+
+	lastStep := entity.LastRunStep(workflowRecord.Steps)
+
+	if workflowRecord.Input == nil && (lastStep == nil || lastStep.Data == nil) {
 		return cerror.NewF(ctx, cerror.KindConflict, "both workflow input and last step data are empty").LogError()
 	}
 
@@ -77,8 +87,7 @@ func (uc *UseCase) RestartWorkflow(
 	newPayload := workflowRecord.Input
 	oldPayload := workflowRecord.Input
 
-	if len(workflowRecord.Steps) > 0 {
-		lastStep := workflowRecord.Steps[len(workflowRecord.Steps)-1]
+	if lastStep != nil {
 		stepName = lastStep.Name
 		oldPayload = lastStep.Data
 		newPayload = lastStep.Data
@@ -104,7 +113,7 @@ func (uc *UseCase) RestartWorkflow(
 		WorkflowStatus:    workflowRecord.Status,
 		WorkflowError:     workflowRecord.Error,
 		WorkflowErrorKind: workflowRecord.ErrorKind,
-		RequestID:         requestIDFromCtx(ctx),
+		RequestID:         entity.RequestIDFromContext(ctx),
 	})
 	if err != nil {
 		_ = cerror.NewF(
@@ -131,14 +140,14 @@ func (uc *UseCase) RestartWorkflowFrom(
 	}
 
 	if workflowRecord.Status != entity.WorkflowStatusSuccess {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "workflow is not in %s status", entity.WorkflowStatusSuccess).
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow is not in %s status", entity.WorkflowStatusSuccess).
 			LogError()
 	}
 
 	oldPayload := workflowRecord.Input
 
 	for _, s := range workflowRecord.Steps {
-		if s.Name == from {
+		if s.Name == from && !s.IsJoinInput() && !s.Compensated {
 			oldPayload = s.Data
 		}
 	}
@@ -159,7 +168,7 @@ func (uc *UseCase) RestartWorkflowFrom(
 		WorkflowStatus:    workflowRecord.Status,
 		WorkflowError:     workflowRecord.Error,
 		WorkflowErrorKind: workflowRecord.ErrorKind,
-		RequestID:         requestIDFromCtx(ctx),
+		RequestID:         entity.RequestIDFromContext(ctx),
 	})
 
 	if err != nil {
@@ -171,12 +180,71 @@ func (uc *UseCase) RestartWorkflowFrom(
 	return nil
 }
 
-// requestIDFromCtx extracts request id value from a given context.
-// If there is no requestID in context, nil is returned.
-func requestIDFromCtx(ctx context.Context) *string {
-	if s, ok := ctx.Value(consts.HeaderXRequestID).(string); ok && s != "" {
-		return &s
+// PauseWorkflow pauses the workflow which is in progress.
+// Its next steps aren't run until it's resumed with ResumeWorkflow.
+func (uc *UseCase) PauseWorkflow(ctx context.Context, workflowID entity.ID) error {
+	workflowRecord, err := uc.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if workflowRecord.Status != entity.WorkflowStatusInProgress {
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow is not in %s status", entity.WorkflowStatusInProgress).
+			LogError()
+	}
+
+	return uc.orchestrator.Pause(ctx, workflowID)
+}
+
+// ResumeWorkflow continues the paused workflow from the steps it was paused before
+func (uc *UseCase) ResumeWorkflow(ctx context.Context, workflowID entity.ID) error {
+	workflowRecord, err := uc.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+
+	if workflowRecord.Status != entity.WorkflowStatusPaused {
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow is not in %s status", entity.WorkflowStatusPaused).
+			LogError()
+	}
+
+	return uc.orchestrator.Resume(ctx, workflowID)
+}
+
+// CancelWorkflow stops the workflow for good.
+// Only workflows which are in progress, paused or failed can be cancelled.
+func (uc *UseCase) CancelWorkflow(ctx context.Context, workflowID entity.ID) error {
+	workflowRecord, err := uc.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+
+	switch workflowRecord.Status {
+	case entity.WorkflowStatusInProgress, entity.WorkflowStatusPaused, entity.WorkflowStatusFailed:
+	default:
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow in %s status can't be cancelled", workflowRecord.Status).
+			LogError()
+	}
+
+	return uc.orchestrator.Cancel(ctx, workflowID)
+}
+
+// WorkflowTimeline returns the workflow with its step attempts and history records in the order they happened
+func (uc *UseCase) WorkflowTimeline(ctx context.Context, workflowID entity.ID) (*entity.WorkflowTimeline, error) {
+	workflowRecord, err := uc.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := uc.store.GetStepAttempts(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := uc.store.GetWorkflowHistory(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewWorkflowTimeline(workflowRecord, attempts, history), nil
 }
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/converto"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/workflow/entity"
 	"kafka-polygon/pkg/workflow/entrypoint/usecase"
 	"testing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/tj/assert"
@@ -67,6 +69,15 @@ func TestRestartWorkflow(t *testing.T) {
 	// set valid input
 	expGetWorkflowByIDResult.Input = []byte("input data")
 
+	// workflow must not be partially compensated
+	expGetWorkflowByIDResult.Steps = []*entity.WorkflowStep{
+		{Name: "step1", Data: []byte("step1 data")},
+		{Name: "step2", Data: []byte("step2 data")},
+		{Name: "step1", Data: []byte("step1 data"), Compensated: true},
+	}
+	actErr = uc.RestartWorkflow(_bgCtx, expGetWorkflowByIDResult.ID, nil)
+	assert.Equal(t, "workflow is partially compensated", actErr.Error())
+
 	// workflow is restarted with expected params
 	expGetWorkflowByIDResult.SchemaName = entity.WorkflowSchemaName("schema")
 	expGetWorkflowByIDResult.Steps = []*entity.WorkflowStep{
@@ -207,6 +218,146 @@ func TestRestartWorkflowFrom(t *testing.T) {
 	_ = uc.RestartWorkflowFrom(_bgCtxWithReqID, expGetWorkflowByIDResult.ID, expFrom, expPayload)
 }
 
+func TestWorkflowTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		call        func(uc *usecase.UseCase, id entity.ID) error
+		status      entity.WorkflowStatus
+		validStatus entity.WorkflowStatus
+		expErr      string
+	}{
+		{
+			name:        "pause",
+			call:        func(uc *usecase.UseCase, id entity.ID) error { return uc.PauseWorkflow(_bgCtxWithReqID, id) },
+			status:      entity.WorkflowStatusPaused,
+			validStatus: entity.WorkflowStatusInProgress,
+			expErr:      "workflow is not in IN_PROGRESS status",
+		},
+		{
+			name:        "resume",
+			call:        func(uc *usecase.UseCase, id entity.ID) error { return uc.ResumeWorkflow(_bgCtxWithReqID, id) },
+			status:      entity.WorkflowStatusSuccess,
+			validStatus: entity.WorkflowStatusPaused,
+			expErr:      "workflow is not in PAUSED status",
+		},
+		{
+			name:        "cancel",
+			call:        func(uc *usecase.UseCase, id entity.ID) error { return uc.CancelWorkflow(_bgCtxWithReqID, id) },
+			status:      entity.WorkflowStatusSuccess,
+			validStatus: entity.WorkflowStatusFailed,
+			expErr:      "workflow in SUCCESS status can't be cancelled",
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			workflowRecord := &entity.Workflow{ID: entity.ID("123"), Status: tc.status}
+
+			var transitions int
+
+			transition := func(ctx context.Context, workflowID entity.ID) error {
+				assert.Equal(t, workflowRecord.ID, workflowID)
+				transitions++
+
+				return nil
+			}
+			orchestrator := &mockOrchestrator{pauseFunc: transition, resumeFunc: transition, cancelFunc: transition}
+			// the transition is saved to the history by the store changing the status
+			store := &mockStore{
+				getWorkflowByIDFunc: func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+					return workflowRecord, nil
+				},
+			}
+			uc := usecase.New(orchestrator, store)
+
+			// workflow must be in appropriate status
+			actErr := tc.call(uc, workflowRecord.ID)
+			assert.Error(t, actErr)
+			assert.Equal(t, tc.expErr, actErr.Error())
+			assert.Equal(t, cerror.KindConflict, cerror.ErrKind(actErr))
+			assert.Equal(t, 0, transitions)
+
+			workflowRecord.Status = tc.validStatus
+			assert.NoError(t, tc.call(uc, workflowRecord.ID))
+			assert.Equal(t, 1, transitions)
+
+			// the workflow changed concurrently isn't transited by the orchestrator
+			conflict := func(ctx context.Context, workflowID entity.ID) error {
+				return cerror.NewF(ctx, cerror.KindConflict, "workflow isn't in %s status", tc.validStatus)
+			}
+			orchestrator.pauseFunc, orchestrator.resumeFunc, orchestrator.cancelFunc = conflict, conflict, conflict
+
+			actErr = tc.call(uc, workflowRecord.ID)
+			assert.Equal(t, cerror.KindConflict, cerror.ErrKind(actErr))
+		})
+	}
+
+	// workflows being compensated and cancelled ones can't be cancelled
+	for status, valid := range map[entity.WorkflowStatus]bool{
+		entity.WorkflowStatusInProgress:   true,
+		entity.WorkflowStatusPaused:       true,
+		entity.WorkflowStatusCancelled:    false,
+		entity.WorkflowStatusCompensating: false,
+	} {
+		store := &mockStore{
+			newIDFunc: func() entity.ID { return "1" },
+			getWorkflowByIDFunc: func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+				return &entity.Workflow{ID: id, Status: status}, nil
+			},
+			createWorkflowHistoryFunc: func(ctx context.Context, wh *entity.WorkflowHistory) error { return nil },
+		}
+		orchestrator := &mockOrchestrator{cancelFunc: func(ctx context.Context, workflowID entity.ID) error { return nil }}
+
+		err := usecase.New(orchestrator, store).CancelWorkflow(_bgCtx, "123")
+		assert.Equal(t, valid, err == nil, status)
+	}
+}
+
+func TestWorkflowTimeline(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	workflowRecord := &entity.Workflow{ID: entity.ID("123"), Status: entity.WorkflowStatusInProgress}
+	attempts := []*entity.WorkflowStepAttempt{
+		{ID: "a1", WorkflowID: workflowRecord.ID, StepName: "step1", Attempt: 1, StartedAt: start},
+		{ID: "a2", WorkflowID: workflowRecord.ID, StepName: "step1", Attempt: 2, StartedAt: start.Add(2 * time.Second)},
+	}
+	history := []*entity.WorkflowHistory{
+		{ID: "h1", WorkflowID: workflowRecord.ID, Type: entity.WorkflowHistoryTypePause, CreatedAt: start.Add(time.Second)},
+	}
+
+	store := &mockStore{
+		getWorkflowByIDFunc: func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+			if id != workflowRecord.ID {
+				return nil, fmt.Errorf("not found")
+			}
+
+			return workflowRecord, nil
+		},
+		getStepAttemptsFunc: func(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error) {
+			assert.Equal(t, workflowRecord.ID, workflowID)
+			return attempts, nil
+		},
+		getWorkflowHistoryFunc: func(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error) {
+			assert.Equal(t, workflowRecord.ID, workflowID)
+			return history, nil
+		},
+	}
+	uc := usecase.New(&mockOrchestrator{}, store)
+
+	_, err := uc.WorkflowTimeline(_bgCtx, "unknown")
+	assert.Error(t, err)
+
+	timeline, err := uc.WorkflowTimeline(_bgCtx, workflowRecord.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, workflowRecord, timeline.Workflow)
+	assert.Equal(t, []*entity.WorkflowTimelineEntry{
+		{Time: start, StepAttempt: attempts[0]},
+		{Time: start.Add(time.Second), History: history[0]},
+		{Time: start.Add(2 * time.Second), StepAttempt: attempts[1]},
+	}, timeline.Entries)
+}
+
 type mockOrchestrator struct {
 	workflowSchemaFunc func(ctx context.Context, wsn entity.WorkflowSchemaName) (*entity.WorkflowSchema, error)
 	startFromFunc      func(
@@ -221,6 +372,9 @@ type mockOrchestrator struct {
 		schemaName entity.WorkflowSchemaName,
 		stepName entity.WorkflowSchemaStepName,
 		payload json.RawMessage) (entity.ID, error)
+	pauseFunc  func(ctx context.Context, workflowID entity.ID) error
+	resumeFunc func(ctx context.Context, workflowID entity.ID) error
+	cancelFunc func(ctx context.Context, workflowID entity.ID) error
 }
 
 func (m *mockOrchestrator) WorkflowSchema(ctx context.Context, wsn entity.WorkflowSchemaName) (*entity.WorkflowSchema, error) {
@@ -245,11 +399,25 @@ func (m *mockOrchestrator) RestartFrom(
 	return m.restartFromFunc(ctx, workflowID, schemaName, stepName, payload)
 }
 
+func (m *mockOrchestrator) Pause(ctx context.Context, workflowID entity.ID) error {
+	return m.pauseFunc(ctx, workflowID)
+}
+
+func (m *mockOrchestrator) Resume(ctx context.Context, workflowID entity.ID) error {
+	return m.resumeFunc(ctx, workflowID)
+}
+
+func (m *mockOrchestrator) Cancel(ctx context.Context, workflowID entity.ID) error {
+	return m.cancelFunc(ctx, workflowID)
+}
+
 type mockStore struct {
 	newIDFunc                 func() entity.ID
 	getWorkflowByIDFunc       func(ctx context.Context, id entity.ID) (*entity.Workflow, error)
 	searchWorkflowsFunc       func(ctx context.Context, params entity.SearchWorkflowParams) (*entity.SearchWorkflowResult, error)
 	createWorkflowHistoryFunc func(ctx context.Context, wh *entity.WorkflowHistory) error
+	getWorkflowHistoryFunc    func(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error)
+	getStepAttemptsFunc       func(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error)
 }
 
 func (m *mockStore) NewID() entity.ID {
@@ -268,6 +436,14 @@ func (m *mockStore) CreateWorkflowHistory(ctx context.Context, wh *entity.Workfl
 	return m.createWorkflowHistoryFunc(ctx, wh)
 }
 
+func (m *mockStore) GetWorkflowHistory(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error) {
+	return m.getWorkflowHistoryFunc(ctx, workflowID)
+}
+
+func (m *mockStore) GetStepAttempts(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error) {
+	return m.getStepAttemptsFunc(ctx, workflowID)
+}
+
 type stepWorkerTest struct{}
 
 func (s *stepWorkerTest) Run(ctx context.Context, e event.WorkflowEvent) (json.RawMessage, error) {
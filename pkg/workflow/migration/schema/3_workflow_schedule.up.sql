@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS workflow_schedule (
+    id UUID PRIMARY KEY,
+    created_at timestamp NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    workflow_id UUID NOT NULL references workflow(id),
+    topic TEXT NOT NULL,
+    event JSONB NOT NULL,
+    send_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS workflow_schedule_send_at_idx ON workflow_schedule (send_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS workflow_schedule_workflow_id_idx;
+
+ALTER TABLE workflow_schedule DROP COLUMN IF EXISTS parked;
@@ -0,0 +1,3 @@
+ALTER TABLE workflow_schedule ADD COLUMN IF NOT EXISTS parked BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS workflow_schedule_workflow_id_idx ON workflow_schedule (workflow_id) WHERE parked;
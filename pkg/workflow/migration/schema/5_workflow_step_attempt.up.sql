@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS workflow_step_attempt (
+    id UUID PRIMARY KEY,
+    workflow_id UUID NOT NULL references workflow(id),
+    step_name varchar(50) NOT NULL,
+    attempt INT NOT NULL DEFAULT 1,
+    compensation BOOLEAN NOT NULL DEFAULT false,
+    event_id varchar(50) NOT NULL DEFAULT '',
+    started_at timestamp NOT NULL,
+    finished_at timestamp NOT NULL,
+    outcome varchar(50) NOT NULL,
+    error TEXT NULL,
+    error_kind varchar(50) NULL,
+    output_size INT NOT NULL DEFAULT 0,
+    request_id varchar(50) NULL
+);
+
+CREATE INDEX IF NOT EXISTS workflow_step_attempt_workflow_id_idx ON workflow_step_attempt (workflow_id, started_at);
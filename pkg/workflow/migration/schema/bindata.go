@@ -5,6 +5,12 @@
 // pkg/workflow/migration/schema/1_workflow.up.sql
 // pkg/workflow/migration/schema/2_workflow_history.down.sql
 // pkg/workflow/migration/schema/2_workflow_history.up.sql
+// pkg/workflow/migration/schema/3_workflow_schedule.down.sql
+// pkg/workflow/migration/schema/3_workflow_schedule.up.sql
+// pkg/workflow/migration/schema/4_workflow_schedule_parked.down.sql
+// pkg/workflow/migration/schema/4_workflow_schedule_parked.up.sql
+// pkg/workflow/migration/schema/5_workflow_step_attempt.down.sql
+// pkg/workflow/migration/schema/5_workflow_step_attempt.up.sql
 package schema
 
 import (
@@ -161,6 +167,126 @@ func _2_workflow_historyUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __3_workflow_scheduleDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\x28\x4d\xca\xc9\x4c\xd6\x2b\xcf\x2f\xca\x4e\xcb\xc9\x2f\x8f\x2f\x4e\xce\x48\x4d\x29\xcd\x49\xb5\xe6\x02\x00\xb9\x77\xce\x48\x2f\x00\x00\x00")
+
+func _3_workflow_scheduleDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__3_workflow_scheduleDownSql,
+		"3_workflow_schedule.down.sql",
+	)
+}
+
+func _3_workflow_scheduleDownSql() (*asset, error) {
+	bytes, err := _3_workflow_scheduleDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "3_workflow_schedule.down.sql", size: 47, mode: os.FileMode(420), modTime: time.Unix(1792228161, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __3_workflow_scheduleUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x85\x90\x41\x4f\x83\x40\x14\x84\xef\xfc\x8a\xb9\x15\x12\xff\x81\xa7\x2d\xbc\x26\xab\x74\xd7\xc0\xdb\x48\xbd\x10\x02\xaf\x91\xd8\x42\x03\xab\xf5\xe7\x4b\x0a\x72\x30\x1a\xdf\x75\xde\x7c\x33\x99\x38\x23\xc5\x04\x56\xdb\x94\xa0\x77\x30\x96\x41\x85\xce\x39\xc7\xb5\x1f\xde\x8e\xa7\xfe\x5a\x8e\xf5\xab\x34\xef\x27\x41\x18\x60\xba\xb6\x81\x73\x3a\xc1\x53\xa6\xf7\x2a\x3b\xe0\x91\x0e\x77\x37\xa1\x1e\xa4\xf2\xd2\x94\x95\x87\x6f\xcf\x32\xfa\xea\x7c\xb9\x01\x8d\x4b\x53\x24\xb4\x53\x2e\x65\x84\xc6\x3e\x87\x11\x14\x83\xf5\x9e\xf0\x62\x0d\x61\xe3\x38\xde\x44\x33\x65\x8d\xfd\xce\x59\x09\x83\x1c\x65\x90\xae\x96\x71\x7d\x0a\xdb\x66\xb1\xf9\xfe\xd2\xd6\x60\x2a\x78\x35\xcc\x82\x7c\x48\xe7\xf1\x90\x5b\xb3\xfd\xa1\x8c\xd2\xfd\x51\x36\x88\xee\x83\x20\x9e\xa7\xd1\x26\xa1\xe2\xbf\x69\xca\x85\x35\x95\xfe\x84\x35\xbf\x6d\xb7\x7c\x4c\xe4\x2f\x33\xb9\xf4\xb5\x74\x01\x00\x00")
+
+func _3_workflow_scheduleUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__3_workflow_scheduleUpSql,
+		"3_workflow_schedule.up.sql",
+	)
+}
+
+func _3_workflow_scheduleUpSql() (*asset, error) {
+	bytes, err := _3_workflow_scheduleUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "3_workflow_schedule.up.sql", size: 372, mode: os.FileMode(420), modTime: time.Unix(1792228161, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __4_workflow_schedule_parkedDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\xcf\x2f\xca\x4e\xcb\xc9\x2f\x8f\x2f\x4e\xce\x48\x4d\x29\xcd\x49\x8d\x87\x8b\x64\xa6\x00\x51\x85\x35\x17\x97\xa3\x4f\x88\x6b\x90\x42\x88\xa3\x93\x8f\x2b\xa6\x7a\x05\x17\x90\xb1\xce\xfe\x3e\xa1\xbe\x7e\x48\xe6\x16\x24\x16\x65\xa7\xa6\x58\x73\x01\x00\x0b\x3c\x0d\xe9\x75\x00\x00\x00")
+
+func _4_workflow_schedule_parkedDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__4_workflow_schedule_parkedDownSql,
+		"4_workflow_schedule_parked.down.sql",
+	)
+}
+
+func _4_workflow_schedule_parkedDownSql() (*asset, error) {
+	bytes, err := _4_workflow_schedule_parkedDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "4_workflow_schedule_parked.down.sql", size: 117, mode: os.FileMode(420), modTime: time.Unix(1792229895, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __4_workflow_schedule_parkedUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6d\x8e\xc1\x0a\xc2\x30\x18\x83\xef\x7d\x8a\x1c\xf5\x19\x76\xea\xd6\x7f\x58\xf8\x6d\xa1\x6b\x71\xb7\x31\x6c\x87\xb2\xc2\x64\x43\xe6\xe3\x2b\x22\x22\x2a\xe4\x94\x84\xe4\x93\xec\xc9\xc1\xcb\x92\x09\xeb\x34\x8f\x43\x9e\xd6\x6e\x39\x9e\x52\xbc\xe6\x04\xa9\x14\x2a\xcb\x61\x6f\xa0\x6b\x18\xeb\x41\xad\x6e\x7c\x83\x4b\x3f\x8f\x29\xa2\xb4\x96\x49\x9a\x67\x62\x02\x33\x14\xd5\x32\xb0\xc7\xd0\xe7\x25\x15\x42\x54\x8e\xa4\x27\x68\xa3\xa8\xfd\x9a\xf8\x79\xeb\xde\xce\x39\x3e\x74\x83\x35\x7f\x90\x36\x1f\xad\x2d\x0e\x3b\x72\xf4\xa2\x29\xc4\x1d\x20\x02\x57\xbb\xcd\x00\x00\x00")
+
+func _4_workflow_schedule_parkedUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__4_workflow_schedule_parkedUpSql,
+		"4_workflow_schedule_parked.up.sql",
+	)
+}
+
+func _4_workflow_schedule_parkedUpSql() (*asset, error) {
+	bytes, err := _4_workflow_schedule_parkedUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "4_workflow_schedule_parked.up.sql", size: 205, mode: os.FileMode(420), modTime: time.Unix(1792229895, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __5_workflow_step_attemptDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\x28\x4d\xca\xc9\x4c\xd6\x2b\xcf\x2f\xca\x4e\xcb\xc9\x2f\x8f\x2f\x2e\x49\x2d\x88\x4f\x2c\x29\x49\xcd\x2d\x28\xb1\xe6\x02\x00\xe4\x29\x7a\x43\x33\x00\x00\x00")
+
+func _5_workflow_step_attemptDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__5_workflow_step_attemptDownSql,
+		"5_workflow_step_attempt.down.sql",
+	)
+}
+
+func _5_workflow_step_attemptDownSql() (*asset, error) {
+	bytes, err := _5_workflow_step_attemptDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "5_workflow_step_attempt.down.sql", size: 51, mode: os.FileMode(420), modTime: time.Unix(1792230148, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __5_workflow_step_attemptUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x92\xb1\x6e\x83\x30\x10\x86\x77\x9e\xe2\xb6\x80\x94\x21\x1d\x3a\x75\x22\x89\x23\xa1\x52\x53\x11\x23\x91\x09\x59\x70\x28\x56\x82\xa1\xb6\x49\xaa\x3e\x7d\x9d\x20\x28\x0a\x49\x54\xcb\x8b\xed\xef\xbf\xfb\xef\x7c\xab\x98\xf8\x8c\x00\xf3\x97\x21\x81\x60\x03\x34\x62\x40\xd2\x60\xcb\xb6\x70\xae\xd5\xa1\x3c\xd6\xe7\x4c\x1b\x6c\x32\x6e\x0c\x56\x8d\x01\xd7\x01\xbb\x44\x01\x49\x12\xac\xe1\x33\x0e\x3e\xfc\x78\x07\xef\x64\x37\xbf\x3e\x0c\xa2\x9e\xb8\x04\xa4\x49\x18\x82\xc2\x12\x15\xca\x1c\xf5\x00\xb9\xa2\xf0\x3a\xd9\x35\x85\xe4\x15\xc2\x89\xab\x7c\xcf\x95\xfb\xba\xf0\x06\x6d\xc7\xf4\x0e\x02\xca\xfe\xa2\xae\xc9\xc6\x4f\x42\x06\x2f\x1d\x93\xd7\x55\x83\x52\x73\x23\x6a\x09\xcb\x28\x0a\x89\x4f\xa7\x70\xc9\x8f\x1a\x3b\x01\x9e\x50\x9a\x8b\xd9\x7b\x79\x07\xc1\x6c\xd6\xdb\xe4\xca\x60\x61\x9b\x01\x46\x54\x68\x8f\x55\x73\xe3\xb2\x14\x52\xe8\xfd\x73\xa6\x6e\x8d\x35\xfa\xac\x56\x54\xaa\x56\xc0\x48\x3a\xb9\xcc\x0e\x42\xde\xb8\x1d\xc7\x6d\x5a\x93\x69\xf1\x83\xf7\xbb\xb4\xe8\x38\x85\x5f\xad\x35\x36\x29\xdb\xa2\x8e\xf7\xe6\x38\xab\x6e\x28\x02\xba\x26\xe9\x7f\x86\x22\x1b\xfd\xba\xdd\xdf\x10\xd1\x47\xd3\x33\x22\xe7\xa3\x76\xda\xac\xbf\x74\x24\xa8\x8d\x8a\x02\x00\x00")
+
+func _5_workflow_step_attemptUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__5_workflow_step_attemptUpSql,
+		"5_workflow_step_attempt.up.sql",
+	)
+}
+
+func _5_workflow_step_attemptUpSql() (*asset, error) {
+	bytes, err := _5_workflow_step_attemptUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "5_workflow_step_attempt.up.sql", size: 650, mode: os.FileMode(420), modTime: time.Unix(1792230144, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -213,10 +339,16 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"1_workflow.down.sql":         _1_workflowDownSql,
-	"1_workflow.up.sql":           _1_workflowUpSql,
-	"2_workflow_history.down.sql": _2_workflow_historyDownSql,
-	"2_workflow_history.up.sql":   _2_workflow_historyUpSql,
+	"1_workflow.down.sql":                 _1_workflowDownSql,
+	"1_workflow.up.sql":                   _1_workflowUpSql,
+	"2_workflow_history.down.sql":         _2_workflow_historyDownSql,
+	"2_workflow_history.up.sql":           _2_workflow_historyUpSql,
+	"3_workflow_schedule.down.sql":        _3_workflow_scheduleDownSql,
+	"3_workflow_schedule.up.sql":          _3_workflow_scheduleUpSql,
+	"4_workflow_schedule_parked.down.sql": _4_workflow_schedule_parkedDownSql,
+	"4_workflow_schedule_parked.up.sql":   _4_workflow_schedule_parkedUpSql,
+	"5_workflow_step_attempt.down.sql":    _5_workflow_step_attemptDownSql,
+	"5_workflow_step_attempt.up.sql":      _5_workflow_step_attemptUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -262,10 +394,16 @@ type bintree struct {
 }
 
 var _bintree = &bintree{nil, map[string]*bintree{
-	"1_workflow.down.sql":         &bintree{_1_workflowDownSql, map[string]*bintree{}},
-	"1_workflow.up.sql":           &bintree{_1_workflowUpSql, map[string]*bintree{}},
-	"2_workflow_history.down.sql": &bintree{_2_workflow_historyDownSql, map[string]*bintree{}},
-	"2_workflow_history.up.sql":   &bintree{_2_workflow_historyUpSql, map[string]*bintree{}},
+	"1_workflow.down.sql":                 &bintree{_1_workflowDownSql, map[string]*bintree{}},
+	"1_workflow.up.sql":                   &bintree{_1_workflowUpSql, map[string]*bintree{}},
+	"2_workflow_history.down.sql":         &bintree{_2_workflow_historyDownSql, map[string]*bintree{}},
+	"2_workflow_history.up.sql":           &bintree{_2_workflow_historyUpSql, map[string]*bintree{}},
+	"3_workflow_schedule.down.sql":        &bintree{_3_workflow_scheduleDownSql, map[string]*bintree{}},
+	"3_workflow_schedule.up.sql":          &bintree{_3_workflow_scheduleUpSql, map[string]*bintree{}},
+	"4_workflow_schedule_parked.down.sql": &bintree{_4_workflow_schedule_parkedDownSql, map[string]*bintree{}},
+	"4_workflow_schedule_parked.up.sql":   &bintree{_4_workflow_schedule_parkedUpSql, map[string]*bintree{}},
+	"5_workflow_step_attempt.down.sql":    &bintree{_5_workflow_step_attemptDownSql, map[string]*bintree{}},
+	"5_workflow_step_attempt.up.sql":      &bintree{_5_workflow_step_attemptUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory
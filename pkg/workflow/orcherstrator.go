@@ -7,6 +7,7 @@ import (
 	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/broker/store"
 	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/converto"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/workflow/entity"
 	"kafka-polygon/pkg/workflow/entrypoint/usecase"
@@ -31,12 +32,46 @@ type Store interface {
 	CreateWorkflow(ctx context.Context, w *entity.Workflow) error
 	// SetWorkflowStatus sets status to existing workflow
 	SetWorkflowStatus(ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus) error
+	// SetWorkflowStatusIf sets status to existing workflow only if it's in one of expected statuses
+	// and saves the transition to the workflow history (see entity.NewTransitionHistory) along with it.
+	// KindConflict error is returned if the workflow is in another status
+	SetWorkflowStatusIf(
+		ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus, expected ...entity.WorkflowStatus) error
 	// UpdateWorkflowForce sets values from params to workflow record. All values from params must be set, even nils
 	UpdateWorkflowForce(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error
 	// UpdateWorkflowNotNil sets values from params to workflow record. Only not nil values should be set
 	UpdateWorkflowNotNil(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowNotNilParams) error
 	// PutWorkflowSteps fully replace existing workflow steps
 	PutWorkflowSteps(ctx context.Context, workflowID entity.ID, steps []*entity.WorkflowStep) error
+	// AppendWorkflowStep atomically appends the step to workflow steps and returns all the steps after the append.
+	// Unlike PutWorkflowSteps it keeps steps appended concurrently by parallel branches
+	AppendWorkflowStep(ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error)
+}
+
+// TxRunner may be implemented by Store to run a function in a transaction.
+// Used together with a TxQueueBroker writing to a transactional outbox
+// it makes store changes and sent events consistent.
+type TxRunner interface {
+	// RunInTx calls fn with a context that carries the transaction.
+	// The transaction is committed if fn returns nil and rolled back otherwise.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxQueueBroker may be implemented by QueueBroker that saves events within the transaction carried by ctx
+// instead of publishing them, e.g. to a transactional outbox. Store changes and sent events are run
+// in a transaction only if the store is a TxRunner and the broker is transactional, a broker publishing
+// events right away can't take back an event sent in a rolled back transaction.
+type TxQueueBroker interface {
+	QueueBroker
+	// Transactional reports whether Send joins the transaction carried by ctx
+	Transactional() bool
+}
+
+// StepAttemptStore may be implemented by Store to keep a record of every run of step workers.
+// Records are saved after the worker returns, a failure to save one doesn't affect the workflow.
+type StepAttemptStore interface {
+	// CreateStepAttempt creates a new step attempt record
+	CreateStepAttempt(ctx context.Context, a *entity.WorkflowStepAttempt) error
 }
 
 // ProcessingError is an error that occurred during workflow processing.
@@ -55,6 +90,7 @@ type Orchestrator struct {
 	store           Store
 	workflowSchemas []*entity.WorkflowSchema
 	noRetryOnError  bool
+	scheduler       EventScheduler
 }
 
 var _ usecase.Orchestrator = (*Orchestrator)(nil)
@@ -74,6 +110,12 @@ func (o *Orchestrator) SetNoRetryOnError(v bool) {
 	o.noRetryOnError = v
 }
 
+// SetScheduler sets the scheduler of delayed runs of steps with a retry policy.
+// Without a scheduler retried errors are returned to the queue broker to redeliver the event.
+func (o *Orchestrator) SetScheduler(s EventScheduler) {
+	o.scheduler = s
+}
+
 // AddWorkflowSchema adds a given workflow schema to the list.
 // Method calls Validate on the given workflow schema.
 // In such a way all workflow schemas in o.workflowSchemas can be assumed to be valid in the future.
@@ -213,14 +255,67 @@ func (o *Orchestrator) RestartFrom(
 	})
 }
 
+// Pause pauses the workflow. Events of its steps received while it's paused are parked until it's resumed,
+// workers which are already running aren't interrupted. The scheduler of the orchestrator must implement EventParker.
+func (o *Orchestrator) Pause(ctx context.Context, workflowID entity.ID) error {
+	if _, ok := o.scheduler.(EventParker); !ok {
+		return cerror.NewF(ctx, cerror.KindInternal,
+			"workflow can't be paused without a scheduler parking its events. workflow_id=%s", workflowID).
+			LogError()
+	}
+
+	return o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusPaused, entity.WorkflowStatusInProgress)
+}
+
+// Resume continues the paused workflow by sending its parked events to the queue.
+// Parked events which couldn't be sent right away are sent by the scheduler with due events.
+// The workflow whose last step was completed while it was paused is completed.
+func (o *Orchestrator) Resume(ctx context.Context, workflowID entity.ID) error {
+	err := o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusInProgress, entity.WorkflowStatusPaused)
+	if err != nil {
+		return err
+	}
+
+	sent, err := o.sendParked(ctx, workflowID)
+	if err != nil {
+		// the workflow is resumed already, its parked events are due for the scheduler
+		return nil //nolint:nilerr
+	}
+
+	if sent > 0 {
+		return nil
+	}
+
+	return o.completeFinished(ctx, workflowID)
+}
+
+// Cancel stops the workflow for good. Its events are skipped and parked ones are deleted.
+// Only workflows which are in progress, paused or failed can be cancelled.
+func (o *Orchestrator) Cancel(ctx context.Context, workflowID entity.ID) error {
+	if err := o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusCancelled,
+		entity.WorkflowStatusInProgress, entity.WorkflowStatusPaused, entity.WorkflowStatusFailed); err != nil {
+		return err
+	}
+
+	parker, ok := o.scheduler.(EventParker)
+	if !ok {
+		return nil
+	}
+
+	return parker.DeleteParked(ctx, workflowID)
+}
+
 // handleWorkflowEvent handles queue topic messages.
 // Each message intends to execute some workflow step with some payload.
 //
 //nolint:gocyclo
 func (o *Orchestrator) handleWorkflowEvent(ctx context.Context, e event.WorkflowEvent, eventData store.EventProcessData) error {
+	// handled events are skipped by provider.HandlerProcessing before the handler is called,
+	// it's a guard for the handler called directly
 	if eventData.Status == store.EventStatusHandled {
 		_ = cerror.NewF(ctx, cerror.KindExist,
 			"skipped duplicate workflow event. event_id=%s. event_status=%s", e.GetID(), eventData.Status).LogWarn()
+
 		return nil
 	}
 
@@ -272,8 +367,38 @@ func (o *Orchestrator) handleWorkflowEvent(ctx context.Context, e event.Workflow
 		return entity.NewProcessingError(err).SetRetry(true)
 	}
 
+	if eventWorkflow.Compensation {
+		return o.handleCompensationEvent(ctx, workflow, schema, stepName, e)
+	}
+
+	// steps still running in parallel branches of the failed workflow aren't continued while it's compensated
+	if workflow.Status == entity.WorkflowStatusCompensating {
+		_ = cerror.NewF(ctx, cerror.KindConflict,
+			"skipped workflow event of the workflow being compensated. event_id=%s. workflow_id=%s", e.GetID(), workflowID).
+			LogWarn()
+
+		return nil
+	}
+
+	switch workflow.Status {
+	case entity.WorkflowStatusCancelled:
+		_ = cerror.NewF(ctx, cerror.KindConflict,
+			"skipped workflow event of the cancelled workflow. event_id=%s. workflow_id=%s", e.GetID(), workflowID).
+			LogWarn()
+
+		return nil
+	case entity.WorkflowStatusPaused:
+		return o.park(ctx, workflowID, schemaStep, e)
+	}
+
 	if workflow.Status != entity.WorkflowStatusInProgress {
-		if err := o.store.SetWorkflowStatus(ctx, workflowID, entity.WorkflowStatusInProgress); err != nil {
+		err := o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusInProgress, workflow.Status)
+		if err != nil {
+			// the workflow changed by another transition is checked again when the event is redelivered
+			if cerror.ErrKind(err) == cerror.KindConflict {
+				return entity.NewProcessingError(err).SetRetry(true)
+			}
+
 			if saveErr := o.saveWorkflowError(ctx, workflowID, err); saveErr != nil {
 				_ = cerror.NewF(ctx, cerror.KindInternal,
 					"couldn't save set workflow status error. workflow=%s step=%s workflow_id=%s. error=%s",
@@ -285,18 +410,38 @@ func (o *Orchestrator) handleWorkflowEvent(ctx context.Context, e event.Workflow
 		}
 	}
 
-	if err := o.appendWorkflowStep(ctx, e, workflow); err != nil {
-		if saveErr := o.saveWorkflowError(ctx, workflowID, err); saveErr != nil {
-			_ = cerror.NewF(ctx, cerror.KindInternal,
-				"couldn't save append workflow step error. workflow=%s step=%s workflow_id=%s. error=%s",
-				schemaName, stepName, workflowID, saveErr.Error()).
-				LogError()
+	attempt := o.newStepAttempt(ctx, e)
+
+	output, completed, err := o.processWorkflowEvent(ctx, workflow, schema, schemaStep, e)
+	attempt.OutputSize = len(output)
+
+	if err != nil {
+		// the failed step is recorded to restart the workflow from it. The record of the completed worker
+		// is rolled back together with next steps by a transactional store, otherwise it's already saved
+		if _, isTx := o.txRunner(); !completed || isTx {
+			if appendErr := o.appendWorkflowStep(ctx, e, workflow, schema); appendErr != nil {
+				_ = cerror.NewF(ctx, cerror.KindInternal,
+					"couldn't append failed workflow step. workflow=%s step=%s workflow_id=%s. error=%s",
+					schemaName, stepName, workflowID, appendErr.Error()).
+					LogError()
+			}
 		}
 
-		return entity.NewProcessingError(err).SetRetry(true)
-	}
+		if !completed && o.retryable(err) {
+			scheduled, retryErr := o.retryLater(ctx, workflow, schema, stepName, e, err)
+			if scheduled {
+				o.saveStepAttempt(ctx, attempt, err, true)
+
+				return nil
+			}
+
+			if retryErr != nil {
+				err = retryErr
+			}
+		}
+
+		o.saveStepAttempt(ctx, attempt, err, o.retryable(err))
 
-	if err := o.processWorkflowEvent(ctx, workflow, schema, schemaStep, e); err != nil {
 		if saveErr := o.saveWorkflowError(ctx, workflowID, err); saveErr != nil {
 			_ = cerror.NewF(ctx, cerror.KindInternal,
 				"couldn't save workflow event processing failed info. workflow=%s step=%s workflow_id=%s. error=%s",
@@ -304,12 +449,243 @@ func (o *Orchestrator) handleWorkflowEvent(ctx context.Context, e event.Workflow
 				LogError()
 		}
 
+		// the step is retried, so the workflow isn't failed yet
+		if !o.retryable(err) {
+			o.compensate(ctx, workflow, schema, stepName, completed)
+		}
+
+		return err
+	}
+
+	o.saveStepAttempt(ctx, attempt, nil, false)
+
+	return nil
+}
+
+// park saves the event of the paused workflow to be sent again when the workflow is resumed.
+// The workflow may be resumed before the event is parked, then parked events are sent right away.
+func (o *Orchestrator) park(
+	ctx context.Context, workflowID entity.ID, step entity.WorkflowSchemaStep, e event.WorkflowEvent) error {
+	parker, ok := o.scheduler.(EventParker)
+	if !ok {
+		err := cerror.NewF(ctx, cerror.KindInternal,
+			"event of the paused workflow can't be parked without a scheduler. event_id=%s. workflow_id=%s",
+			e.GetID(), workflowID).
+			LogError()
+
+		return entity.NewProcessingError(err).SetRetry(true)
+	}
+
+	// the received event is handled, so the parked one gets a new id not to be skipped as a duplicate
+	parked := &event.WorkflowData{
+		ID:       uuid.NewV4().String(),
+		Workflow: e.GetWorkflow(),
+	}
+
+	if err := parker.Park(ctx, step.Topic().String(), parked); err != nil {
+		return entity.NewProcessingError(err).SetRetry(true)
+	}
+
+	workflow, err := o.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		// the event stays parked until the workflow is resumed
+		_ = cerror.NewF(ctx, cerror.KindInternal,
+			"couldn't check status of the workflow after parking its event. event_id=%s. workflow_id=%s. error=%s",
+			e.GetID(), workflowID, err.Error()).
+			LogError()
+
+		return nil
+	}
+
+	if workflow.Status != entity.WorkflowStatusPaused {
+		_, err := o.sendParked(ctx, workflowID)
+
 		return err
 	}
 
 	return nil
 }
 
+// sendParked sends parked events of the workflow to the queue if the scheduler parks them.
+// It returns the number of sent events.
+func (o *Orchestrator) sendParked(ctx context.Context, workflowID entity.ID) (int, error) {
+	parker, ok := o.scheduler.(EventParker)
+	if !ok {
+		return 0, nil
+	}
+
+	sent, err := parker.SendParked(ctx, workflowID)
+	if err != nil {
+		return sent, cerror.NewF(ctx, cerror.KindInternal,
+			"parked events were not sent. workflow_id=%s. error=%s", workflowID, err.Error()).
+			LogError()
+	}
+
+	return sent, nil
+}
+
+// completeFinished sets SUCCESS status to the resumed workflow if its last recorded step is the last step
+// of the schema. The status of the workflow isn't changed when its last step is completed while it's paused.
+func (o *Orchestrator) completeFinished(ctx context.Context, workflowID entity.ID) error {
+	workflow, err := o.store.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+
+	if len(workflow.Steps) == 0 {
+		return nil
+	}
+
+	last := workflow.Steps[len(workflow.Steps)-1]
+	if last.IsJoinInput() || last.Compensated {
+		return nil
+	}
+
+	schema, err := o.WorkflowSchema(ctx, workflow.SchemaName)
+	if err != nil {
+		return err
+	}
+
+	// a branch step always chooses one of its branches
+	step, ok := schema.Step(last.Name)
+	if _, isBranch := step.(*entity.WorkflowSchemaBranchStep); !ok || isBranch {
+		return nil
+	}
+
+	if next, err := schema.NextSteps(ctx, last.Name, nil); err != nil || len(next) > 0 {
+		return err
+	}
+
+	err = o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusSuccess, entity.WorkflowStatusInProgress)
+	if cerror.ErrKind(err) == cerror.KindConflict {
+		// the workflow is already paused or cancelled again
+		return nil
+	}
+
+	return err
+}
+
+// newStepAttempt starts a record of the run of the event step
+func (o *Orchestrator) newStepAttempt(ctx context.Context, e event.WorkflowEvent) *entity.WorkflowStepAttempt {
+	eventWorkflow := e.GetWorkflow()
+
+	requestID := requestIDFromCtx(ctx)
+	if requestID == nil && e.GetHeader().RequestID != "" {
+		requestID = converto.StringPointer(e.GetHeader().RequestID)
+	}
+
+	return &entity.WorkflowStepAttempt{
+		WorkflowID:   entity.ID(eventWorkflow.ID),
+		StepName:     entity.WorkflowSchemaStepName(eventWorkflow.Step),
+		Attempt:      stepAttempt(eventWorkflow.Attempt),
+		Compensation: eventWorkflow.Compensation,
+		EventID:      e.GetID(),
+		StartedAt:    time.Now().UTC(),
+		RequestID:    requestID,
+	}
+}
+
+// saveStepAttempt finishes the record of the step run with the error the run ended with and saves it,
+// if the store keeps step attempts. Failures to save the record are only logged.
+func (o *Orchestrator) saveStepAttempt(ctx context.Context, a *entity.WorkflowStepAttempt, err error, retried bool) {
+	as, ok := o.store.(StepAttemptStore)
+	if !ok {
+		return
+	}
+
+	a.ID = o.store.NewID()
+	a.FinishedAt = time.Now().UTC()
+	a.Outcome = entity.WorkflowStepOutcomeSuccess
+
+	if err != nil {
+		a.Outcome = entity.WorkflowStepOutcomeFailed
+		if retried {
+			a.Outcome = entity.WorkflowStepOutcomeRetried
+		}
+
+		a.Error = entity.PointerWorkflowErrorMsg(err.Error())
+		a.ErrorKind = entity.PointerWorkflowErrorKind(cerror.ErrKind(err).String())
+	}
+
+	if saveErr := as.CreateStepAttempt(ctx, a); saveErr != nil {
+		_ = cerror.NewF(ctx, cerror.KindInternal,
+			"couldn't save step attempt. step=%s workflow_id=%s event_id=%s. error=%s",
+			a.StepName, a.WorkflowID, a.EventID, saveErr.Error()).
+			LogError()
+	}
+}
+
+// retryLater schedules the next run of the step failed with a retried error according to its retry policy.
+// The first returned parameter is false if the step has no retry policy or the orchestrator has no scheduler,
+// then the event is redelivered by the queue broker. The error is returned when attempts of the step run out.
+// If the scheduler fails, the next run is sent to the queue right away not to lose its attempt number,
+// and if it isn't sent either, the retried scheduling error is returned to redeliver the event with its attempt.
+func (o *Orchestrator) retryLater(
+	ctx context.Context,
+	workflow *entity.Workflow,
+	schema *entity.WorkflowSchema,
+	stepName entity.WorkflowSchemaStepName,
+	e event.WorkflowEvent,
+	stepErr error) (bool, error) {
+	policy, ok := schema.RetryPolicy(stepName)
+	if !ok || o.scheduler == nil {
+		return false, nil
+	}
+
+	eventWorkflow := e.GetWorkflow()
+	attempt := stepAttempt(eventWorkflow.Attempt)
+
+	if attempt >= policy.MaxAttempts {
+		return false, cerror.NewF(ctx, cerror.ErrKind(stepErr),
+			"step failed after %d attempts. workflow=%s step=%s workflow_id=%s. error=%s",
+			attempt, schema.Name(), stepName, workflow.ID, stepErr.Error()).
+			LogError()
+	}
+
+	step, _ := schema.Step(stepName)
+	retryEvent := &event.WorkflowData{
+		ID: uuid.NewV4().String(),
+		Workflow: event.Workflow{
+			ID:          eventWorkflow.ID,
+			Schema:      eventWorkflow.Schema,
+			Step:        eventWorkflow.Step,
+			StepPayload: eventWorkflow.StepPayload,
+			Attempt:     attempt + 1,
+		},
+	}
+
+	at := time.Now().Add(policy.Delay(attempt))
+	schedErr := o.scheduler.SendAt(ctx, step.Topic().String(), retryEvent, at)
+	if schedErr == nil {
+		return true, nil
+	}
+
+	_ = cerror.NewF(ctx, cerror.KindInternal,
+		"step retry was not scheduled, it's sent without delay. workflow=%s step=%s workflow_id=%s attempt=%d. error=%s",
+		schema.Name(), stepName, workflow.ID, attempt+1, schedErr.Error()).
+		LogError()
+
+	if err := o.queueBroker.Send(ctx, step.Topic().String(), retryEvent); err != nil {
+		err = cerror.NewF(ctx, cerror.KindInternal,
+			"step retry was not sent. workflow=%s step=%s workflow_id=%s attempt=%d. error=%s",
+			schema.Name(), stepName, workflow.ID, attempt+1, err.Error()).
+			LogError()
+
+		return false, entity.NewProcessingError(err).SetRetry(true)
+	}
+
+	return true, nil
+}
+
+// stepAttempt returns the number of the run of a step from the event attempt, which is zero for the first run
+func stepAttempt(attempt int) int {
+	if attempt < 1 {
+		return 1
+	}
+
+	return attempt
+}
+
 func (o *Orchestrator) saveWorkflowError(ctx context.Context, wfID entity.ID, err error) error {
 	return o.store.UpdateWorkflowForce(ctx, wfID, entity.UpdateWorkflowForceParams{
 		Status:    entity.WorkflowStatusFailed,
@@ -333,14 +709,21 @@ func (o *Orchestrator) decorateQHandlerWithErrRetry(f provider.HandlerWorkflow)
 			return nil
 		}
 
-		if perr, ok := err.(ProcessingError); ok && perr.Retry() && !o.noRetryOnError {
-			return perr.OriginalError()
+		if o.retryable(err) {
+			return err.(ProcessingError).OriginalError()
 		}
 
 		return nil
 	}
 }
 
+// retryable reports whether the event handled with the error is going to be handled again
+func (o *Orchestrator) retryable(err error) bool {
+	perr, ok := err.(ProcessingError)
+
+	return ok && perr.Retry() && !o.noRetryOnError
+}
+
 // QueueEventHandler returns functions that handles workflow queue events.
 func (o *Orchestrator) QueueEventHandler() provider.HandlerWorkflow {
 	return o.decorateQHandlerWithErrRetry(o.handleWorkflowEvent)
@@ -373,22 +756,6 @@ func (o *Orchestrator) runWorkflow(ctx context.Context, param *runWorkflowParam)
 		workflowID = *param.workflowID
 	}
 
-	if isNewWorkflow {
-		w := &entity.Workflow{
-			ID:         workflowID,
-			CreatedAt:  now,
-			UpdatedAt:  now,
-			ParentID:   param.parentWorkflowID,
-			SchemaName: param.workflowSchema.Name(),
-			Status:     entity.WorkflowStatusInProgress,
-			Input:      param.payload,
-			RequestID:  requestIDFromCtx(ctx),
-		}
-		if err := o.store.CreateWorkflow(ctx, w); err != nil {
-			return "", err
-		}
-	}
-
 	e := &event.WorkflowData{
 		ID: uuid.NewV4().String(),
 		Workflow: event.Workflow{
@@ -399,128 +766,565 @@ func (o *Orchestrator) runWorkflow(ctx context.Context, param *runWorkflowParam)
 		},
 	}
 
-	if err := o.queueBroker.Send(ctx, param.workflowSchemaStep.Topic().String(), e); err != nil {
-		_ = cerror.NewF(ctx, cerror.KindInternal,
-			"workflow start event was not sent. workflow=%s step=%s workflow_id=%s. error=%s",
-			param.workflowSchema.Name(), param.workflowSchemaStep.Name(), e.Workflow.ID, err.Error()).
-			LogError()
-
-		if saveErr := o.store.UpdateWorkflowForce(ctx, workflowID, entity.UpdateWorkflowForceParams{
-			Status:    entity.WorkflowStatusFailed,
-			Error:     entity.PointerWorkflowErrorMsg(err.Error()),
-			ErrorKind: entity.PointerWorkflowErrorKind(cerror.ErrKind(err).String()),
-		}); saveErr != nil {
-			_ = cerror.NewF(ctx, cerror.KindInternal,
-				"couldn't save workflow start event failed info. workflow=%s step=%s workflow_id=%s. error=%s",
-				param.workflowSchema.Name(), param.workflowSchemaStep.Name(), e.Workflow.ID, saveErr.Error()).
-				LogError()
+	var sendErr error
+
+	err := o.runInTx(ctx, func(ctx context.Context) error {
+		if isNewWorkflow {
+			w := &entity.Workflow{
+				ID:         workflowID,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+				ParentID:   param.parentWorkflowID,
+				SchemaName: param.workflowSchema.Name(),
+				Status:     entity.WorkflowStatusInProgress,
+				Input:      param.payload,
+				RequestID:  requestIDFromCtx(ctx),
+			}
+			if err := o.store.CreateWorkflow(ctx, w); err != nil {
+				return err
+			}
 		}
 
+		sendErr = o.queueBroker.Send(ctx, param.workflowSchemaStep.Topic().String(), e)
+
+		return sendErr
+	})
+	if err == nil {
+		return workflowID, nil
+	}
+
+	if sendErr == nil {
 		return "", err
 	}
 
-	return workflowID, nil
+	_ = cerror.NewF(ctx, cerror.KindInternal,
+		"workflow start event was not sent. workflow=%s step=%s workflow_id=%s. error=%s",
+		param.workflowSchema.Name(), param.workflowSchemaStep.Name(), e.Workflow.ID, sendErr.Error()).
+		LogError()
+
+	// a new workflow created in the rolled back transaction doesn't exist
+	if _, isTx := o.txRunner(); isTx && isNewWorkflow {
+		return "", sendErr
+	}
+
+	if saveErr := o.store.UpdateWorkflowForce(ctx, workflowID, entity.UpdateWorkflowForceParams{
+		Status:    entity.WorkflowStatusFailed,
+		Error:     entity.PointerWorkflowErrorMsg(sendErr.Error()),
+		ErrorKind: entity.PointerWorkflowErrorKind(cerror.ErrKind(sendErr).String()),
+	}); saveErr != nil {
+		_ = cerror.NewF(ctx, cerror.KindInternal,
+			"couldn't save workflow start event failed info. workflow=%s step=%s workflow_id=%s. error=%s",
+			param.workflowSchema.Name(), param.workflowSchemaStep.Name(), e.Workflow.ID, saveErr.Error()).
+			LogError()
+	}
+
+	return "", sendErr
 }
 
 // appendWorkflowStep appends workflow step to existing workflow based on received event
-// and saves to store
+// and saves to store. Steps running concurrently with other steps of the workflow are appended atomically.
 func (o *Orchestrator) appendWorkflowStep(
-	ctx context.Context, e event.WorkflowEvent, workflow *entity.Workflow) error {
+	ctx context.Context, e event.WorkflowEvent, workflow *entity.Workflow, schema *entity.WorkflowSchema) error {
 	eventWorkflow := e.GetWorkflow()
+	step := &entity.WorkflowStep{
+		CreatedAt: time.Now().UTC(),
+		Name:      entity.WorkflowSchemaStepName(eventWorkflow.Step),
+		Data:      eventWorkflow.StepPayload,
+		Metadata: entity.WorkflowStepMetadata{
+			Version: e.GetMeta().Version,
+		},
+		Attempt: eventWorkflow.Attempt,
+	}
+
+	if _, ok := schema.RetryPolicy(step.Name); ok {
+		step.Attempt = stepAttempt(eventWorkflow.Attempt)
+	}
+
+	if schema.Concurrent(step.Name) {
+		steps, err := o.store.AppendWorkflowStep(ctx, workflow.ID, step)
+		if err != nil {
+			return err
+		}
+
+		workflow.Steps = steps
+
+		return nil
+	}
 
 	if len(workflow.Steps) > 0 {
 		// if step is already present in db replace it with new one (for retries with new inputs)
-		eventStep := entity.WorkflowSchemaStepName(eventWorkflow.Step)
-		if currStep := workflow.Steps[len(workflow.Steps)-1]; currStep.Name == eventStep {
+		if currStep := workflow.Steps[len(workflow.Steps)-1]; currStep.Name == step.Name && !currStep.IsJoinInput() {
 			workflow.Steps = workflow.Steps[:len(workflow.Steps)-1]
 		}
 	}
 
-	workflow.Steps = append(workflow.Steps, &entity.WorkflowStep{
-		CreatedAt: time.Now().UTC(),
-		Name:      entity.WorkflowSchemaStepName(eventWorkflow.Step),
-		Data:      eventWorkflow.StepPayload,
-		Metadata: entity.WorkflowStepMetadata{
-			Version: e.GetMeta().Version,
-		},
-	})
+	workflow.Steps = append(workflow.Steps, step)
 
 	return o.store.PutWorkflowSteps(ctx, workflow.ID, workflow.Steps)
 }
 
 // processWorkflowEvent extracts and runs step's underlying worker(business logic executor).
-// If worker returns no error, next step (if it exists) is pushed to the queue.
+// If worker returns no error, the step is recorded and next steps (if they exist) are pushed to the queue:
+// the step chosen by a branch step, all branches of a parallel step or the next step of a simple step.
+// The workflow is completed when there are no next steps.
+// The step record, join inputs, the workflow status and next step events are saved in one store transaction,
+// if the store supports it, so the workflow doesn't move on without its next steps.
+// The first returned parameter is the worker output, the second one indicates whether the worker is completed
+// even if an error is returned.
 func (o *Orchestrator) processWorkflowEvent(
 	ctx context.Context,
 	workflow *entity.Workflow,
 	schema *entity.WorkflowSchema,
 	step entity.WorkflowSchemaStep,
-	e event.WorkflowEvent) error {
+	e event.WorkflowEvent) (json.RawMessage, bool, error) {
 	eventWorkflow := e.GetWorkflow()
 	workflowID := entity.ID(eventWorkflow.ID)
 
 	nextPayload, err := step.Worker().Run(ctx, e)
 	if err != nil {
-		return err
+		return nextPayload, false, err
 	}
 
 	// subsequent errors shouldn't be retried to avoid business logic call duplication.
 	// so don't wrap in NewProcessingError(err).SetRetry(true)
 
-	nextStep, nextExists := schema.NextStep(step.Name())
-	if !nextExists {
-		err = o.store.SetWorkflowStatus(ctx, workflowID, entity.WorkflowStatusSuccess)
-		if err != nil {
-			return cerror.NewF(ctx, cerror.KindInternal,
-				"workflow_id=%s was completed but failed to update it's status in DB: %s", workflowID, err.Error()).
-				LogError()
+	nextSteps, err := schema.NextSteps(ctx, step.Name(), nextPayload)
+	if err != nil {
+		return nextPayload, true, err
+	}
+
+	err = o.runInTx(ctx, func(ctx context.Context) error {
+		if err := o.appendWorkflowStep(ctx, e, workflow, schema); err != nil {
+			return err
+		}
+
+		if len(nextSteps) == 0 {
+			err := o.store.SetWorkflowStatusIf(ctx, workflowID, entity.WorkflowStatusSuccess, entity.WorkflowStatusInProgress)
+			if cerror.ErrKind(err) == cerror.KindConflict {
+				// the workflow paused while its last step was run is completed when it's resumed,
+				// the cancelled one stays cancelled
+				_ = cerror.NewF(ctx, cerror.KindConflict,
+					"workflow_id=%s was completed after it was paused or cancelled, its status isn't changed", workflowID).
+					LogWarn()
+
+				return nil
+			}
+
+			if err != nil {
+				return cerror.NewF(ctx, cerror.KindInternal,
+					"workflow_id=%s was completed but failed to update it's status in DB: %s", workflowID, err.Error()).
+					LogError()
+			}
+
+			return nil
+		}
+
+		for _, nextStep := range nextSteps {
+			if err := o.sendNextStep(ctx, workflow, schema, step, nextStep, nextPayload); err != nil {
+				return err
+			}
 		}
 
 		return nil
+	})
+
+	return nextPayload, true, err
+}
+
+// sendNextStep pushes the next step with the output of the step to the queue.
+// The output of the last step of a parallel branch is saved as an input of the join step,
+// which is pushed only when inputs of all the branches are saved.
+func (o *Orchestrator) sendNextStep(
+	ctx context.Context,
+	workflow *entity.Workflow,
+	schema *entity.WorkflowSchema,
+	step, nextStep entity.WorkflowSchemaStep,
+	payload json.RawMessage) error {
+	if ps, ok := schema.JoinOf(nextStep.Name()); ok {
+		joinPayload, ready, err := o.joinInput(ctx, workflow.ID, ps, step.Name(), payload)
+		if err != nil || !ready {
+			return err
+		}
+
+		payload = joinPayload
 	}
 
 	nextStepEvent := &event.WorkflowData{
 		ID: uuid.NewV4().String(),
 		Workflow: event.Workflow{
-			ID:          workflowID.String(),
+			ID:          workflow.ID.String(),
 			Schema:      schema.Name().String(),
 			Step:        nextStep.Name().String(),
-			StepPayload: nextPayload,
+			StepPayload: payload,
 		},
 	}
 
-	if brokerErr := o.queueBroker.Send(ctx, nextStep.Topic().String(), nextStepEvent); brokerErr != nil {
-		err = cerror.NewF(ctx, cerror.KindInternal,
-			"next step was not sent. workflow=%s step=%s workflow_id=%s. error=%s",
-			eventWorkflow.Schema, eventWorkflow.Step, eventWorkflow.ID, brokerErr.Error()).
+	brokerErr := o.queueBroker.Send(ctx, nextStep.Topic().String(), nextStepEvent)
+	if brokerErr == nil {
+		return nil
+	}
+
+	err := cerror.NewF(ctx, cerror.KindInternal,
+		"next step was not sent. workflow=%s step=%s workflow_id=%s. error=%s",
+		schema.Name(), step.Name(), workflow.ID, brokerErr.Error()).
+		LogError()
+
+	// a transactional store rolls the step back together with the unsent event,
+	// then the step is recorded as failed to restart the workflow from it
+	if _, isTx := o.txRunner(); isTx {
+		return err
+	}
+
+	// save next step data to db to have an opportunity
+	// to restart the workflow from the next step later.
+	unsent := &entity.WorkflowStep{
+		CreatedAt: time.Now().UTC(),
+		Name:      entity.WorkflowSchemaStepName(nextStepEvent.Workflow.Step),
+		Data:      nextStepEvent.Workflow.StepPayload,
+		Metadata: entity.WorkflowStepMetadata{
+			Version: nextStepEvent.Metadata.Version,
+		},
+	}
+
+	var saveErr error
+
+	if schema.Concurrent(step.Name()) {
+		_, saveErr = o.store.AppendWorkflowStep(ctx, workflow.ID, unsent)
+	} else {
+		workflow.Steps = append(workflow.Steps, unsent)
+
+		saveErr = o.store.UpdateWorkflowNotNil(ctx, workflow.ID, entity.UpdateWorkflowNotNilParams{
+			Steps: workflow.Steps,
+		})
+	}
+
+	if saveErr != nil {
+		_ = cerror.NewF(ctx, cerror.KindInternal,
+			"unable to save next step data for the unsent next step. workflow_id=%s. error=%s",
+			workflow.ID, saveErr.Error()).
+			LogError()
+	}
+
+	return err
+}
+
+// joinInput saves the output of the last step of a parallel branch as an input of the join step.
+// The second returned parameter indicates whether the output completes inputs of all the branches
+// since the last run of the parallel step, then the first one is the join step payload: outputs by step names.
+// As inputs are appended atomically, only one of the branches completes them.
+func (o *Orchestrator) joinInput(
+	ctx context.Context,
+	workflowID entity.ID,
+	ps *entity.WorkflowSchemaParallelStep,
+	from entity.WorkflowSchemaStepName,
+	output json.RawMessage) (json.RawMessage, bool, error) {
+	steps, err := o.store.AppendWorkflowStep(ctx, workflowID, &entity.WorkflowStep{
+		CreatedAt: time.Now().UTC(),
+		Name:      ps.Join(),
+		Data:      output,
+		From:      from,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	inputs := joinInputs(steps, ps)
+	if len(inputs) < len(ps.Branches()) || len(steps) == 0 || len(joinInputs(steps[:len(steps)-1], ps)) == len(inputs) {
+		return nil, false, nil
+	}
+
+	payload, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, false, cerror.NewF(ctx, cerror.KindInternal,
+			"join step payload. workflow_id=%s step=%s. error=%s", workflowID, ps.Join(), err.Error()).
+			LogError()
+	}
+
+	return payload, true, nil
+}
+
+// joinInputs returns inputs of the join step of the parallel step saved since its last run by names of the steps
+func joinInputs(
+	steps []*entity.WorkflowStep, ps *entity.WorkflowSchemaParallelStep) map[entity.WorkflowSchemaStepName]json.RawMessage {
+	inputs := make(map[entity.WorkflowSchemaStepName]json.RawMessage)
+
+	for _, s := range steps {
+		switch {
+		case s.Name == ps.Name() && !s.IsJoinInput():
+			inputs = make(map[entity.WorkflowSchemaStepName]json.RawMessage)
+		case s.Name == ps.Join() && s.IsJoinInput():
+			inputs[s.From] = s.Data
+		}
+	}
+
+	return inputs
+}
+
+// compensate starts compensations of the failed workflow's steps, if any of them has a compensation.
+// Steps recorded before the failed step are compensated in reverse order, the failed step itself
+// only if its worker is completed. The workflow stays FAILED if there is nothing to compensate.
+func (o *Orchestrator) compensate(
+	ctx context.Context,
+	workflow *entity.Workflow,
+	schema *entity.WorkflowSchema,
+	failed entity.WorkflowSchemaStepName,
+	completed bool) {
+	from := lastRunStepIndex(workflow.Steps, failed, len(workflow.Steps))
+	if from < 0 {
+		return
+	}
+
+	if completed {
+		from++
+	}
+
+	step := compensableStep(schema, workflow.Steps, from, nil)
+	if step == nil {
+		return
+	}
+
+	// the workflow stays FAILED if the compensation isn't sent
+	err := o.runInTx(ctx, func(ctx context.Context) error {
+		err := o.store.SetWorkflowStatusIf(ctx, workflow.ID, entity.WorkflowStatusCompensating, entity.WorkflowStatusFailed)
+		if cerror.ErrKind(err) == cerror.KindConflict {
+			return err
+		}
+
+		if err != nil {
+			return cerror.NewF(ctx, cerror.KindInternal,
+				"couldn't start compensation. workflow=%s step=%s workflow_id=%s. error=%s",
+				schema.Name(), failed, workflow.ID, err.Error()).
+				LogError()
+		}
+
+		return o.sendCompensation(ctx, workflow.ID, schema, step)
+	})
+	// the workflow which isn't FAILED anymore, e.g. cancelled, isn't compensated
+	if err != nil && cerror.ErrKind(err) != cerror.KindConflict {
+		if saveErr := o.saveWorkflowError(ctx, workflow.ID, err); saveErr != nil {
+			_ = cerror.NewF(ctx, cerror.KindInternal,
+				"couldn't save compensation failed info. workflow=%s step=%s workflow_id=%s. error=%s",
+				schema.Name(), step.Name, workflow.ID, saveErr.Error()).
+				LogError()
+		}
+	}
+}
+
+// handleCompensationEvent runs the compensation worker of the step and pushes the compensation
+// of the previous completed step to the queue. The workflow is COMPENSATED when there are no steps left.
+// A compensation failed without retry leaves the workflow FAILED, its redelivery continues the compensation.
+//
+//nolint:gocyclo
+func (o *Orchestrator) handleCompensationEvent(
+	ctx context.Context,
+	workflow *entity.Workflow,
+	schema *entity.WorkflowSchema,
+	stepName entity.WorkflowSchemaStepName,
+	e event.WorkflowEvent) error {
+	if workflow.Status != entity.WorkflowStatusCompensating && workflow.Status != entity.WorkflowStatusFailed {
+		_ = cerror.NewF(ctx, cerror.KindConflict,
+			"skipped compensation event of the workflow which isn't compensated. event_id=%s. workflow_id=%s. status=%s",
+			e.GetID(), workflow.ID, workflow.Status).
+			LogWarn()
+
+		return nil
+	}
+
+	saveErr := func(err error) {
+		if saveErr := o.saveWorkflowError(ctx, workflow.ID, err); saveErr != nil {
+			_ = cerror.NewF(ctx, cerror.KindInternal,
+				"couldn't save compensation failed info. workflow=%s step=%s workflow_id=%s. error=%s",
+				schema.Name(), stepName, workflow.ID, saveErr.Error()).
+				LogError()
+		}
+	}
+
+	compensation, ok := schema.Compensation(stepName)
+	if !ok {
+		err := cerror.NewF(ctx, cerror.KindNotExist,
+			"step [%s] has no compensation in the workflow schema [%s]", stepName, schema.Name()).
 			LogError()
+		saveErr(err)
 
-		// save next step data to db to have an opportunity
-		// to restart the workflow from the next step later
-		workflow.Steps = append(workflow.Steps, &entity.WorkflowStep{
-			CreatedAt: time.Now().UTC(),
-			Name:      entity.WorkflowSchemaStepName(nextStepEvent.Workflow.Step),
-			Data:      nextStepEvent.Workflow.StepPayload,
+		return err
+	}
+
+	if workflow.Status != entity.WorkflowStatusCompensating {
+		err := o.store.SetWorkflowStatusIf(ctx, workflow.ID, entity.WorkflowStatusCompensating, workflow.Status)
+		if cerror.ErrKind(err) == cerror.KindConflict {
+			_ = cerror.NewF(ctx, cerror.KindConflict,
+				"skipped compensation event of the workflow which status is changed. event_id=%s. workflow_id=%s",
+				e.GetID(), workflow.ID).
+				LogWarn()
+
+			return nil
+		}
+
+		if err != nil {
+			saveErr(err)
+
+			return entity.NewProcessingError(err).SetRetry(true)
+		}
+	}
+
+	attempt := o.newStepAttempt(ctx, e)
+
+	output, err := compensation.Worker().Run(ctx, e)
+	attempt.OutputSize = len(output)
+	o.saveStepAttempt(ctx, attempt, err, o.retryable(err))
+
+	if err != nil {
+		if !o.retryable(err) {
+			saveErr(err)
+		}
+
+		return err
+	}
+
+	// the compensation record, the workflow status and the next compensation are saved in one store transaction
+	err = o.runInTx(ctx, func(ctx context.Context) error {
+		steps, err := o.store.AppendWorkflowStep(ctx, workflow.ID, &entity.WorkflowStep{
+			CreatedAt:   time.Now().UTC(),
+			Name:        stepName,
+			Data:        e.GetWorkflow().StepPayload,
+			Compensated: true,
 			Metadata: entity.WorkflowStepMetadata{
-				Version: nextStepEvent.Metadata.Version,
+				Version: e.GetMeta().Version,
 			},
 		})
+		if err != nil {
+			return entity.NewProcessingError(err).SetRetry(true)
+		}
 
-		if saveErr := o.store.UpdateWorkflowNotNil(ctx, workflowID, entity.UpdateWorkflowNotNilParams{
-			Steps: workflow.Steps,
-		}); saveErr != nil {
-			_ = cerror.NewF(ctx, cerror.KindInternal,
-				"unable to save next step data for the unsent next step. workflow_id=%s. error=%s",
-				workflowID, saveErr.Error()).
-				LogError()
+		// compensations recorded after the last run step are done by the current failure
+		compensated := make(map[entity.WorkflowSchemaStepName]bool)
+		end := len(steps)
+
+		for ; end > 0 && (steps[end-1].Compensated || steps[end-1].IsJoinInput()); end-- {
+			if steps[end-1].Compensated {
+				compensated[steps[end-1].Name] = true
+			}
+		}
+
+		next := compensableStep(schema, steps, lastRunStepIndex(steps, stepName, end), compensated)
+		if next == nil {
+			err := o.store.SetWorkflowStatusIf(
+				ctx, workflow.ID, entity.WorkflowStatusCompensated, entity.WorkflowStatusCompensating)
+			if cerror.ErrKind(err) == cerror.KindConflict {
+				return err
+			}
+
+			if err != nil {
+				return cerror.NewF(ctx, cerror.KindInternal,
+					"workflow_id=%s was compensated but failed to update it's status in DB: %s", workflow.ID, err.Error()).
+					LogError()
+			}
+
+			return nil
 		}
 
+		return o.sendCompensation(ctx, workflow.ID, schema, next)
+	})
+	// the compensation of the workflow which status is changed concurrently is rolled back and not continued
+	if cerror.ErrKind(err) == cerror.KindConflict {
+		return nil
+	}
+
+	if err != nil {
+		saveErr(err)
+
 		return err
 	}
 
 	return nil
 }
 
+// sendCompensation pushes the compensation of the step with the payload the step was run with to the queue
+func (o *Orchestrator) sendCompensation(
+	ctx context.Context, workflowID entity.ID, schema *entity.WorkflowSchema, step *entity.WorkflowStep) error {
+	compensation, _ := schema.Compensation(step.Name)
+	e := &event.WorkflowData{
+		ID: uuid.NewV4().String(),
+		Workflow: event.Workflow{
+			ID:           workflowID.String(),
+			Schema:       schema.Name().String(),
+			Step:         step.Name.String(),
+			StepPayload:  step.Data,
+			Compensation: true,
+		},
+	}
+
+	if err := o.queueBroker.Send(ctx, compensation.Topic().String(), e); err != nil {
+		return cerror.NewF(ctx, cerror.KindInternal,
+			"compensation was not sent. workflow=%s step=%s workflow_id=%s. error=%s",
+			schema.Name(), step.Name, workflowID, err.Error()).
+			LogError()
+	}
+
+	return nil
+}
+
+// lastRunStepIndex returns index of the last record of the step run before the given index, -1 if there is none
+func lastRunStepIndex(steps []*entity.WorkflowStep, sn entity.WorkflowSchemaStepName, before int) int {
+	for i := before - 1; i >= 0; i-- {
+		if steps[i].Name == sn && !steps[i].IsJoinInput() && !steps[i].Compensated {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// compensableStep returns the last step run before the given index which has a compensation
+// and isn't in compensated. Steps recorded before a compensation record are left to the failure
+// they were compensated for, so nil is returned when it is reached.
+func compensableStep(
+	schema *entity.WorkflowSchema,
+	steps []*entity.WorkflowStep,
+	before int,
+	compensated map[entity.WorkflowSchemaStepName]bool) *entity.WorkflowStep {
+	for i := before - 1; i >= 0; i-- {
+		s := steps[i]
+
+		switch {
+		case s.Compensated:
+			return nil
+		case s.IsJoinInput() || compensated[s.Name]:
+			continue
+		}
+
+		if _, ok := schema.Compensation(s.Name); ok {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// txRunner returns the store as TxRunner if both the store and the queue broker support transactions
+func (o *Orchestrator) txRunner() (TxRunner, bool) {
+	txr, ok := o.store.(TxRunner)
+	if !ok {
+		return nil, false
+	}
+
+	qb, ok := o.queueBroker.(TxQueueBroker)
+	if !ok || !qb.Transactional() {
+		return nil, false
+	}
+
+	return txr, true
+}
+
+// runInTx calls fn in a store transaction if both the store and the queue broker support it, otherwise just calls fn
+func (o *Orchestrator) runInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txr, ok := o.txRunner(); ok {
+		return txr.RunInTx(ctx, fn)
+	}
+
+	return fn(ctx)
+}
+
 // requestIDFromCtx extracts request id value from a given context.
 // If there is no requestID in context, nil is returned.
 func requestIDFromCtx(ctx context.Context) *string {
@@ -3,15 +3,18 @@ package workflow_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"kafka-polygon/pkg/broker/event"
 	pkgStore "kafka-polygon/pkg/broker/store"
+	"kafka-polygon/pkg/cerror"
 	"kafka-polygon/pkg/cmd/metadata"
 	"kafka-polygon/pkg/converto"
 	"kafka-polygon/pkg/http/consts"
 	"kafka-polygon/pkg/workflow"
 	"kafka-polygon/pkg/workflow/entity"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 )
@@ -169,6 +172,149 @@ func TestRestartFrom(t *testing.T) {
 	})
 }
 
+func TestStartInTx(t *testing.T) {
+	schema, _, _ := defSchema(t)
+	errSend := errors.New("send error")
+
+	for _, sendErr := range []error{nil, errSend} {
+		isForceUpdated := false
+		store := &mockTxStore{
+			mockStore: mockStore{
+				newIDFunc: func() entity.ID {
+					return "123"
+				},
+				createWorkflowFunc: func(ctx context.Context, _ *entity.Workflow) error {
+					assert.NotNil(t, ctx.Value(txKey{}))
+					return nil
+				},
+				updateWorkflowForceFunc: func(_ context.Context, _ entity.ID, _ entity.UpdateWorkflowForceParams) error {
+					isForceUpdated = true
+					return nil
+				},
+			},
+		}
+		broker := &mockTxQueueBroker{mockQueueBroker{
+			sendFunc: func(ctx context.Context, _ string, _ event.BaseEvent) error {
+				assert.NotNil(t, ctx.Value(txKey{}))
+				return sendErr
+			},
+		}}
+
+		o := workflow.NewOrchestrator(broker, store)
+		err := o.AddWorkflowSchema(_bgCtx, schema)
+		assert.NoError(t, err)
+
+		_, err = o.Start(_bgCtxWithReqID, schema.Name(), []byte("{}"))
+		assert.Equal(t, sendErr, err)
+		assert.Equal(t, sendErr == nil, store.committed)
+		assert.Equal(t, sendErr != nil, store.rolledBack)
+		// the workflow is rolled back together with the unsent event, so there is nothing to mark as failed
+		assert.False(t, isForceUpdated)
+	}
+}
+
+func TestStartTxStoreWithDirectBroker(t *testing.T) {
+	schema, _, _ := defSchema(t)
+	errSend := errors.New("send error")
+
+	var forceUpdated *entity.UpdateWorkflowForceParams
+
+	store := &mockTxStore{
+		mockStore: mockStore{
+			newIDFunc: func() entity.ID {
+				return "123"
+			},
+			createWorkflowFunc: func(ctx context.Context, _ *entity.Workflow) error {
+				assert.Nil(t, ctx.Value(txKey{}))
+				return nil
+			},
+			updateWorkflowForceFunc: func(_ context.Context, _ entity.ID, params entity.UpdateWorkflowForceParams) error {
+				forceUpdated = &params
+				return nil
+			},
+		},
+	}
+	// the broker publishes events right away, so they aren't sent in a transaction
+	broker := &mockQueueBroker{
+		sendFunc: func(ctx context.Context, _ string, _ event.BaseEvent) error {
+			assert.Nil(t, ctx.Value(txKey{}))
+			return errSend
+		},
+	}
+
+	o := workflow.NewOrchestrator(broker, store)
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	_, err := o.Start(_bgCtxWithReqID, schema.Name(), []byte("{}"))
+	assert.Equal(t, errSend, err)
+	assert.False(t, store.committed)
+	assert.False(t, store.rolledBack)
+	// the saved workflow is marked as failed to restart it later
+	assert.NotNil(t, forceUpdated)
+	assert.Equal(t, entity.WorkflowStatusFailed, forceUpdated.Status)
+}
+
+func TestQueueEventHandlerInTx(t *testing.T) {
+	schema, _, _ := defSchema(t)
+	errSend := errors.New("send error")
+
+	for _, sendErr := range []error{nil, errSend} {
+		var stepsInTx []bool
+
+		isUpdateNotNilCalled := false
+		store := &mockTxStore{
+			mockStore: mockStore{
+				getWorkflowByIDFunc: func(_ context.Context, id entity.ID) (*entity.Workflow, error) {
+					return &entity.Workflow{ID: id, Status: entity.WorkflowStatusInProgress}, nil
+				},
+				putWorkflowStepsFunc: func(ctx context.Context, _ entity.ID, _ []*entity.WorkflowStep) error {
+					stepsInTx = append(stepsInTx, ctx.Value(txKey{}) != nil)
+					return nil
+				},
+				updateWorkflowNotNilFunc: func(_ context.Context, _ entity.ID, _ entity.UpdateWorkflowNotNilParams) error {
+					isUpdateNotNilCalled = true
+					return nil
+				},
+				updateWorkflowForceFunc: func(_ context.Context, _ entity.ID, _ entity.UpdateWorkflowForceParams) error {
+					return nil
+				},
+			},
+		}
+		broker := &mockTxQueueBroker{mockQueueBroker{
+			sendFunc: func(ctx context.Context, _ string, _ event.BaseEvent) error {
+				assert.NotNil(t, ctx.Value(txKey{}))
+				return sendErr
+			},
+		}}
+
+		o := workflow.NewOrchestrator(broker, store)
+		assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+		err := o.QueueEventHandler()(_bgCtx, &event.WorkflowData{
+			Workflow: event.Workflow{
+				ID:          "wf-123",
+				Schema:      schema.Name().String(),
+				Step:        schema.FirstStep().Name().String(),
+				StepPayload: []byte("{}"),
+			},
+		}, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew})
+
+		// the failed workflow isn't retried
+		assert.NoError(t, err)
+		assert.Equal(t, sendErr == nil, store.committed)
+		assert.Equal(t, sendErr != nil, store.rolledBack)
+
+		if sendErr == nil {
+			assert.Equal(t, []bool{true}, stepsInTx)
+			continue
+		}
+
+		// the step rolled back with the unsent event is recorded as failed instead of the unsent next step
+		assert.Equal(t, []bool{true, false}, stepsInTx)
+		assert.False(t, isUpdateNotNilCalled)
+	}
+}
+
 func testWorkflowRunWithNotExistingSchema(
 	t *testing.T,
 	caller func(*workflow.Orchestrator, entity.WorkflowSchemaName) error) {
@@ -511,6 +657,749 @@ func TestQueueEventHandler(t *testing.T) {
 	})
 }
 
+func TestQueueEventHandlerGraph(t *testing.T) {
+	wfID := entity.ID("123")
+
+	handle := func(t *testing.T, o *workflow.Orchestrator, schema *entity.WorkflowSchema, step string) {
+		t.Helper()
+
+		err := o.QueueEventHandler()(_bgCtx, &event.WorkflowData{
+			Workflow: event.Workflow{
+				ID:          wfID.String(),
+				Schema:      schema.Name().String(),
+				Step:        step,
+				StepPayload: []byte("{}"),
+			},
+		}, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew})
+		assert.NoError(t, err)
+	}
+
+	newOrchestrator := func(t *testing.T, schema *entity.WorkflowSchema) (
+		*workflow.Orchestrator, *mockStore, map[string][]*event.WorkflowData) {
+		t.Helper()
+
+		var steps []*entity.WorkflowStep
+
+		store := new(mockStore)
+		store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+			return &entity.Workflow{ID: wfID, Status: entity.WorkflowStatusInProgress, Steps: steps}, nil
+		}
+		store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+			steps = s
+			return nil
+		}
+		store.appendWorkflowStepFunc = func(
+			ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+			steps = append(steps, step)
+			return steps, nil
+		}
+
+		sent := make(map[string][]*event.WorkflowData)
+		broker := new(mockQueueBroker)
+		broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+			sent[topic] = append(sent[topic], e.(*event.WorkflowData))
+			return nil
+		}
+
+		o := workflow.NewOrchestrator(broker, store)
+		assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+		return o, store, sent
+	}
+
+	t.Run("branch step sends the chosen step", func(t *testing.T) {
+		schema := graphSchema(t, `{"next": "reject"}`)
+		o, _, sent := newOrchestrator(t, schema)
+
+		handle(t, o, schema, "check")
+
+		assert.Equal(t, 1, len(sent))
+		assert.Equal(t, 1, len(sent["topic-reject"]))
+		assert.Equal(t, "reject", sent["topic-reject"][0].Workflow.Step)
+		assert.Equal(t, json.RawMessage(`{"next": "reject"}`), sent["topic-reject"][0].Workflow.StepPayload)
+	})
+
+	t.Run("parallel step sends all the branches", func(t *testing.T) {
+		schema := graphSchema(t, `{"n": 1}`)
+		o, _, sent := newOrchestrator(t, schema)
+
+		handle(t, o, schema, "split")
+
+		assert.Equal(t, 2, len(sent))
+		assert.Equal(t, "ship", sent["topic-ship"][0].Workflow.Step)
+		assert.Equal(t, "bill", sent["topic-bill"][0].Workflow.Step)
+	})
+
+	t.Run("join step is sent once when all the branches are completed", func(t *testing.T) {
+		schema := graphSchema(t, `{"n": 1}`)
+		o, store, sent := newOrchestrator(t, schema)
+
+		isCompleted := false
+		store.setWorkflowStatusFunc = func(ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus) error {
+			isCompleted = status == entity.WorkflowStatusSuccess
+			return nil
+		}
+
+		handle(t, o, schema, "split")
+		handle(t, o, schema, "ship")
+		assert.Empty(t, sent["topic-notify"])
+
+		handle(t, o, schema, "bill")
+		assert.Equal(t, 1, len(sent["topic-notify"]))
+
+		var payload map[string]json.RawMessage
+		assert.NoError(t, json.Unmarshal(sent["topic-notify"][0].Workflow.StepPayload, &payload))
+		assert.Equal(t, map[string]json.RawMessage{
+			"ship": json.RawMessage(`{"n":1}`),
+			"bill": json.RawMessage(`{"n":1}`),
+		}, payload)
+
+		handle(t, o, schema, "notify")
+		assert.True(t, isCompleted)
+
+		// inputs of the previous run aren't joined when the parallel step is run again
+		handle(t, o, schema, "split")
+		handle(t, o, schema, "bill")
+		assert.Equal(t, 1, len(sent["topic-notify"]))
+
+		handle(t, o, schema, "ship")
+		assert.Equal(t, 2, len(sent["topic-notify"]))
+	})
+}
+
+func TestQueueEventHandlerCompensation(t *testing.T) {
+	wfID := entity.ID("123")
+	compensations := new(stepWorkerTest)
+	failure := &failingWorker{err: errors.New("payment declined")}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "saga",
+		entity.NewWorkflowSchemaSimpleStep("reserve", "topic-reserve", outputWorker(`{"n":1}`)).
+			SetCompensation("topic-compensation", compensations),
+		entity.NewWorkflowSchemaSimpleStep("notify", "topic-notify", outputWorker(`{"n":2}`)),
+		entity.NewWorkflowSchemaSimpleStep("charge", "topic-charge", outputWorker(`{"n":3}`)).
+			SetCompensation("topic-compensation", compensations),
+		entity.NewWorkflowSchemaSimpleStep("ship", "topic-ship", failure),
+	)
+	assert.NoError(t, err)
+
+	var (
+		steps  []*entity.WorkflowStep
+		status = entity.WorkflowStatusInProgress
+		sent   []*event.WorkflowData
+		topics []string
+	)
+
+	store := new(mockStore)
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: status, Steps: steps}, nil
+	}
+	store.setWorkflowStatusFunc = func(ctx context.Context, workflowID entity.ID, s entity.WorkflowStatus) error {
+		status = s
+		return nil
+	}
+	store.updateWorkflowForceFunc = func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
+		status = params.Status
+		return nil
+	}
+	store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+		steps = s
+		return nil
+	}
+	store.appendWorkflowStepFunc = func(
+		ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+		steps = append(steps, step)
+		return steps, nil
+	}
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		sent = append(sent, e.(*event.WorkflowData))
+		topics = append(topics, topic)
+
+		return nil
+	}
+
+	o := workflow.NewOrchestrator(broker, store)
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	handle := func(e *event.WorkflowData) {
+		t.Helper()
+
+		assert.NoError(t, o.QueueEventHandler()(_bgCtx, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	}
+	lastSent := func() *event.WorkflowData {
+		return sent[len(sent)-1]
+	}
+
+	handle(&event.WorkflowData{
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "saga", Step: "reserve", StepPayload: []byte(`{"n":0}`)},
+	})
+	handle(lastSent())
+	handle(lastSent())
+
+	// the step is retried, so nothing is compensated yet
+	failure.err = entity.NewProcessingError(errors.New("timeout")).SetRetry(true)
+	assert.Error(t, o.QueueEventHandler()(_bgCtx, lastSent(), pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	assert.Equal(t, 3, len(sent))
+	assert.Equal(t, entity.WorkflowStatusFailed, status)
+
+	failure.err = errors.New("payment declined")
+	handle(lastSent())
+	assert.Equal(t, entity.WorkflowStatusCompensating, status)
+	assert.Equal(t, 4, len(sent))
+	assert.Equal(t, "topic-compensation", topics[3])
+	assert.Equal(t, event.Workflow{
+		ID:           wfID.String(),
+		Schema:       "saga",
+		Step:         "charge",
+		StepPayload:  json.RawMessage(`{"n":2}`),
+		Compensation: true,
+	}, lastSent().Workflow)
+
+	// steps of the workflow being compensated are skipped
+	handle(&event.WorkflowData{
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "saga", Step: "ship", StepPayload: []byte(`{}`)},
+	})
+	assert.Equal(t, 4, len(sent))
+
+	// notify has no compensation
+	handle(lastSent())
+	assert.Equal(t, 1, compensations.runCount)
+	assert.Equal(t, 5, len(sent))
+	assert.Equal(t, event.Workflow{
+		ID:           wfID.String(),
+		Schema:       "saga",
+		Step:         "reserve",
+		StepPayload:  json.RawMessage(`{"n":0}`),
+		Compensation: true,
+	}, lastSent().Workflow)
+
+	handle(lastSent())
+	assert.Equal(t, 2, compensations.runCount)
+	assert.Equal(t, 5, len(sent))
+	assert.Equal(t, entity.WorkflowStatusCompensated, status)
+
+	compensated := make([]entity.WorkflowSchemaStepName, 0)
+
+	for _, s := range steps {
+		if s.Compensated {
+			compensated = append(compensated, s.Name)
+		}
+	}
+
+	assert.Equal(t, []entity.WorkflowSchemaStepName{"charge", "reserve"}, compensated)
+}
+
+func TestQueueEventHandlerRetry(t *testing.T) {
+	wfID := entity.ID("123")
+	compensations := new(stepWorkerTest)
+	failure := &failingWorker{err: entity.NewProcessingError(errors.New("timeout")).SetRetry(true)}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "retry",
+		entity.NewWorkflowSchemaSimpleStep("reserve", "topic-reserve", outputWorker(`{"n":1}`)).
+			SetCompensation("topic-compensation", compensations),
+		entity.NewWorkflowSchemaSimpleStep("fetch", "topic-fetch", failure).
+			SetRetryPolicy(entity.WorkflowSchemaRetryPolicy{MaxAttempts: 3, Backoff: time.Minute}),
+	)
+	assert.NoError(t, err)
+
+	var (
+		steps  []*entity.WorkflowStep
+		status = entity.WorkflowStatusInProgress
+		sent   []*event.WorkflowData
+	)
+
+	store := new(mockStore)
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: status, Steps: steps}, nil
+	}
+	store.setWorkflowStatusFunc = func(ctx context.Context, workflowID entity.ID, s entity.WorkflowStatus) error {
+		status = s
+		return nil
+	}
+	store.updateWorkflowForceFunc = func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
+		status = params.Status
+		return nil
+	}
+	store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+		steps = s
+		return nil
+	}
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		sent = append(sent, e.(*event.WorkflowData))
+		return nil
+	}
+
+	scheduler := new(mockEventScheduler)
+
+	o := workflow.NewOrchestrator(broker, store)
+	o.SetScheduler(scheduler)
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	handle := func(e *event.WorkflowData) {
+		t.Helper()
+
+		assert.NoError(t, o.QueueEventHandler()(_bgCtx, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	}
+
+	handle(&event.WorkflowData{
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "retry", Step: "reserve", StepPayload: []byte(`{"n":0}`)},
+	})
+	assert.Equal(t, 1, len(sent))
+
+	// failed runs are scheduled with growing delays, the workflow isn't failed
+	handle(sent[0])
+
+	for attempt := 2; attempt <= 3; attempt++ {
+		assert.Equal(t, attempt-1, len(scheduler.events))
+		assert.Equal(t, entity.WorkflowStatusInProgress, status)
+
+		scheduled := scheduler.events[attempt-2]
+		assert.Equal(t, "topic-fetch", scheduler.topics[attempt-2])
+		assert.Equal(t, attempt, scheduled.Workflow.Attempt)
+		assert.Equal(t, json.RawMessage(`{"n":1}`), scheduled.Workflow.StepPayload)
+		assert.NotEqual(t, sent[0].ID, scheduled.ID)
+
+		delay := time.Until(scheduler.times[attempt-2])
+		assert.True(t, delay > time.Duration(attempt-2)*time.Minute && delay <= time.Duration(attempt-1)*time.Minute)
+
+		handle(scheduled)
+		assert.Equal(t, 2, len(steps))
+		assert.Equal(t, attempt, steps[1].Attempt)
+	}
+
+	// attempts run out
+	assert.Equal(t, 2, len(scheduler.events))
+	assert.Equal(t, entity.WorkflowStatusCompensating, status)
+	assert.Equal(t, 2, len(sent))
+	assert.True(t, sent[1].Workflow.Compensation)
+	assert.Equal(t, "reserve", sent[1].Workflow.Step)
+	assert.Equal(t, 0, steps[0].Attempt)
+}
+
+func TestQueueEventHandlerRetryNotScheduled(t *testing.T) {
+	wfID := entity.ID("123")
+	failure := &failingWorker{err: entity.NewProcessingError(errors.New("timeout")).SetRetry(true)}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "retry",
+		entity.NewWorkflowSchemaSimpleStep("fetch", "topic-fetch", failure).
+			SetRetryPolicy(entity.WorkflowSchemaRetryPolicy{MaxAttempts: 3, Backoff: time.Minute}),
+	)
+	assert.NoError(t, err)
+
+	store := new(mockStore)
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: entity.WorkflowStatusInProgress}, nil
+	}
+	store.updateWorkflowForceFunc = func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
+		return nil
+	}
+	store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+		return nil
+	}
+
+	var (
+		sent    []*event.WorkflowData
+		sendErr error
+	)
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		if sendErr != nil {
+			return sendErr
+		}
+
+		assert.Equal(t, "topic-fetch", topic)
+		sent = append(sent, e.(*event.WorkflowData))
+
+		return nil
+	}
+
+	o := workflow.NewOrchestrator(broker, store)
+	o.SetScheduler(&mockEventScheduler{err: errors.New("scheduler is down")})
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	e := &event.WorkflowData{
+		ID:       "e1",
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "retry", Step: "fetch", StepPayload: []byte(`{}`), Attempt: 2},
+	}
+
+	// the next run is sent without delay keeping its attempt number
+	assert.NoError(t, o.QueueEventHandler()(_bgCtx, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	assert.Equal(t, 1, len(sent))
+	assert.Equal(t, 3, sent[0].Workflow.Attempt)
+	assert.NotEqual(t, e.ID, sent[0].ID)
+
+	// the event is redelivered with its attempt number if the next run isn't sent either
+	sendErr = errors.New("broker is down")
+	err = o.QueueEventHandler()(_bgCtx, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "step retry was not sent")
+	assert.Equal(t, 1, len(sent))
+}
+
+func TestQueueEventHandlerStepAttempts(t *testing.T) {
+	wfID := entity.ID("123")
+	failure := &failingWorker{err: entity.NewProcessingError(errors.New("timeout")).SetRetry(true)}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "attempts",
+		entity.NewWorkflowSchemaSimpleStep("reserve", "topic-reserve", outputWorker(`{"n":1}`)).
+			SetCompensation("topic-compensation", outputWorker(`{}`)),
+		entity.NewWorkflowSchemaSimpleStep("fetch", "topic-fetch", failure).
+			SetRetryPolicy(entity.WorkflowSchemaRetryPolicy{MaxAttempts: 2, Backoff: time.Minute}),
+	)
+	assert.NoError(t, err)
+
+	var (
+		steps  []*entity.WorkflowStep
+		status = entity.WorkflowStatusInProgress
+		sent   []*event.WorkflowData
+		ids    int
+	)
+
+	store := new(mockAttemptStore)
+	store.newIDFunc = func() entity.ID {
+		ids++
+		return entity.ID(fmt.Sprint(ids))
+	}
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: status, Steps: steps}, nil
+	}
+	store.setWorkflowStatusFunc = func(ctx context.Context, workflowID entity.ID, s entity.WorkflowStatus) error {
+		status = s
+		return nil
+	}
+	store.updateWorkflowForceFunc = func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
+		status = params.Status
+		return nil
+	}
+	store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+		steps = s
+		return nil
+	}
+	store.appendWorkflowStepFunc = func(
+		ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+		steps = append(steps, step)
+		return steps, nil
+	}
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		sent = append(sent, e.(*event.WorkflowData))
+		return nil
+	}
+
+	scheduler := new(mockEventScheduler)
+
+	o := workflow.NewOrchestrator(broker, store)
+	o.SetScheduler(scheduler)
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	handle := func(e *event.WorkflowData) {
+		t.Helper()
+
+		assert.NoError(t, o.QueueEventHandler()(_bgCtxWithReqID, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	}
+
+	handle(&event.WorkflowData{
+		ID:       "e1",
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "attempts", Step: "reserve", StepPayload: []byte(`{"n":0}`)},
+	})
+	handle(sent[0])
+	handle(scheduler.events[0])
+	assert.Equal(t, entity.WorkflowStatusCompensating, status)
+	handle(sent[1])
+
+	assert.Equal(t, 4, len(store.attempts))
+
+	for i, exp := range []struct {
+		step         entity.WorkflowSchemaStepName
+		attempt      int
+		compensation bool
+		outcome      entity.WorkflowStepOutcome
+		outputSize   int
+	}{
+		{step: "reserve", attempt: 1, outcome: entity.WorkflowStepOutcomeSuccess, outputSize: len(`{"n":1}`)},
+		{step: "fetch", attempt: 1, outcome: entity.WorkflowStepOutcomeRetried},
+		{step: "fetch", attempt: 2, outcome: entity.WorkflowStepOutcomeFailed},
+		{step: "reserve", attempt: 1, compensation: true, outcome: entity.WorkflowStepOutcomeSuccess, outputSize: 2},
+	} {
+		a := store.attempts[i]
+		assert.Equal(t, entity.ID(fmt.Sprint(i+1)), a.ID)
+		assert.Equal(t, wfID, a.WorkflowID)
+		assert.Equal(t, exp.step, a.StepName)
+		assert.Equal(t, exp.attempt, a.Attempt)
+		assert.Equal(t, exp.compensation, a.Compensation)
+		assert.Equal(t, exp.outcome, a.Outcome)
+		assert.Equal(t, exp.outputSize, a.OutputSize)
+		assert.Equal(t, converto.StringPointer(_reqID), a.RequestID)
+		assert.False(t, a.FinishedAt.Before(a.StartedAt))
+
+		if exp.outcome == entity.WorkflowStepOutcomeSuccess {
+			assert.Nil(t, a.Error)
+			assert.Nil(t, a.ErrorKind)
+		} else {
+			assert.Contains(t, a.Error.String(), "timeout")
+			assert.NotNil(t, a.ErrorKind)
+		}
+	}
+
+	assert.Equal(t, "e1", store.attempts[0].EventID)
+	assert.Equal(t, scheduler.events[0].ID, store.attempts[2].EventID)
+}
+
+func TestPauseResumeCancel(t *testing.T) {
+	wfID := entity.ID("123")
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "pause",
+		entity.NewWorkflowSchemaSimpleStep("reserve", "topic-reserve", outputWorker(`{"n":1}`)),
+		entity.NewWorkflowSchemaSimpleStep("charge", "topic-charge", outputWorker(`{"n":2}`)),
+		entity.NewWorkflowSchemaSimpleStep("ship", "topic-ship", outputWorker(`{"n":3}`)),
+	)
+	assert.NoError(t, err)
+
+	var (
+		steps  []*entity.WorkflowStep
+		status = entity.WorkflowStatusInProgress
+		sent   []*event.WorkflowData
+	)
+
+	store := new(mockStore)
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: status, Steps: steps}, nil
+	}
+	store.setWorkflowStatusIfFunc = conditionalStatus(&status)
+	store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+		steps = s
+		return nil
+	}
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		sent = append(sent, e.(*event.WorkflowData))
+		return nil
+	}
+
+	handle := func(o *workflow.Orchestrator, e *event.WorkflowData) {
+		t.Helper()
+
+		assert.NoError(t, o.QueueEventHandler()(_bgCtx, e, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+	}
+
+	// events can't be parked without a parker
+	o := workflow.NewOrchestrator(broker, store)
+	o.SetScheduler(new(mockEventScheduler))
+	assert.Error(t, o.Pause(_bgCtx, wfID))
+	assert.Equal(t, entity.WorkflowStatusInProgress, status)
+
+	parker := &mockEventParker{broker: broker}
+	o.SetScheduler(parker)
+	assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+	handle(o, &event.WorkflowData{
+		Workflow: event.Workflow{ID: wfID.String(), Schema: "pause", Step: "reserve", StepPayload: []byte(`{"n":0}`)},
+	})
+	assert.Equal(t, 1, len(sent))
+
+	// the next step of the paused workflow is parked instead of run
+	assert.NoError(t, o.Pause(_bgCtx, wfID))
+	assert.Equal(t, entity.WorkflowStatusPaused, status)
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(o.Pause(_bgCtx, wfID)))
+
+	handle(o, sent[0])
+	assert.Equal(t, 1, len(sent))
+	assert.Equal(t, 1, len(steps))
+	assert.Equal(t, 1, len(parker.parked))
+	assert.Equal(t, "topic-charge", parker.topics[0])
+	assert.Equal(t, sent[0].Workflow, parker.parked[0].Workflow)
+	assert.NotEqual(t, sent[0].ID, parker.parked[0].ID)
+
+	assert.NoError(t, o.Resume(_bgCtx, wfID))
+	assert.Equal(t, entity.WorkflowStatusInProgress, status)
+	assert.Equal(t, 0, len(parker.parked))
+	assert.Equal(t, 2, len(sent))
+	assert.Equal(t, "charge", sent[1].Workflow.Step)
+
+	// the workflow resumed while the event is parked
+	assert.NoError(t, o.Pause(_bgCtx, wfID))
+
+	parker.onPark = func() {
+		status = entity.WorkflowStatusInProgress
+	}
+
+	handle(o, sent[1])
+	assert.Equal(t, 0, len(parker.parked))
+	assert.Equal(t, 3, len(sent))
+
+	parker.onPark = nil
+
+	handle(o, sent[2])
+	assert.Equal(t, 2, len(steps))
+	assert.Equal(t, 4, len(sent))
+
+	// events of the cancelled workflow are skipped, parked ones are deleted
+	assert.NoError(t, o.Pause(_bgCtx, wfID))
+	handle(o, sent[3])
+	assert.Equal(t, 1, len(parker.parked))
+
+	assert.NoError(t, o.Cancel(_bgCtx, wfID))
+	assert.Equal(t, entity.WorkflowStatusCancelled, status)
+	assert.Equal(t, 0, len(parker.parked))
+
+	handle(o, sent[3])
+	assert.Equal(t, 2, len(steps))
+	assert.Equal(t, 4, len(sent))
+	assert.Equal(t, entity.WorkflowStatusCancelled, status)
+
+	// the status of the cancelled workflow isn't changed
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(o.Resume(_bgCtx, wfID)))
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(o.Pause(_bgCtx, wfID)))
+	assert.Equal(t, cerror.KindConflict, cerror.ErrKind(o.Cancel(_bgCtx, wfID)))
+	assert.Equal(t, entity.WorkflowStatusCancelled, status)
+}
+
+func TestLastStepCompletedWhilePaused(t *testing.T) {
+	wfID := entity.ID("123")
+
+	for _, tc := range []struct {
+		name      string
+		status    entity.WorkflowStatus
+		expStatus entity.WorkflowStatus
+	}{
+		{name: "paused", status: entity.WorkflowStatusPaused, expStatus: entity.WorkflowStatusSuccess},
+		{name: "cancelled", status: entity.WorkflowStatusCancelled, expStatus: entity.WorkflowStatusCancelled},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				steps  []*entity.WorkflowStep
+				status = entity.WorkflowStatusInProgress
+			)
+
+			// the workflow is paused or cancelled while its last step is run
+			ship := funcWorker(func(_ context.Context, _ event.WorkflowEvent) (json.RawMessage, error) {
+				status = tc.status
+				return json.RawMessage(`{"n":2}`), nil
+			})
+
+			schema, err := entity.NewWorkflowSchema(_bgCtx, "last",
+				entity.NewWorkflowSchemaSimpleStep("reserve", "topic-reserve", outputWorker(`{"n":1}`)),
+				entity.NewWorkflowSchemaSimpleStep("ship", "topic-ship", ship),
+			)
+			assert.NoError(t, err)
+
+			store := new(mockStore)
+			store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+				return &entity.Workflow{ID: wfID, SchemaName: "last", Status: status, Steps: steps}, nil
+			}
+			store.setWorkflowStatusIfFunc = conditionalStatus(&status)
+			store.putWorkflowStepsFunc = func(ctx context.Context, workflowID entity.ID, s []*entity.WorkflowStep) error {
+				steps = s
+				return nil
+			}
+
+			broker := new(mockQueueBroker)
+			o := workflow.NewOrchestrator(broker, store)
+			o.SetScheduler(&mockEventParker{broker: broker})
+			assert.NoError(t, o.AddWorkflowSchema(_bgCtx, schema))
+
+			assert.NoError(t, o.QueueEventHandler()(_bgCtx, &event.WorkflowData{
+				ID:       "e1",
+				Workflow: event.Workflow{ID: wfID.String(), Schema: "last", Step: "ship", StepPayload: []byte(`{"n":1}`)},
+			}, pkgStore.EventProcessData{Status: pkgStore.EventStatusNew}))
+			assert.Equal(t, 1, len(steps))
+			assert.Equal(t, tc.status, status)
+
+			// the paused workflow without parked events is completed when it's resumed
+			err = o.Resume(_bgCtx, wfID)
+			if tc.status == entity.WorkflowStatusCancelled {
+				assert.Equal(t, cerror.KindConflict, cerror.ErrKind(err))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expStatus, status)
+		})
+	}
+}
+
+func TestResumeSendParkedError(t *testing.T) {
+	wfID := entity.ID("123")
+	status := entity.WorkflowStatusPaused
+
+	store := new(mockStore)
+	store.getWorkflowByIDFunc = func(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
+		return &entity.Workflow{ID: wfID, Status: status}, nil
+	}
+	store.setWorkflowStatusIfFunc = conditionalStatus(&status)
+
+	broker := new(mockQueueBroker)
+	broker.sendFunc = func(ctx context.Context, topic string, e event.BaseEvent) error {
+		return errors.New("broker is down")
+	}
+
+	parker := &mockEventParker{broker: broker}
+	assert.NoError(t, parker.Park(_bgCtx, "topic-charge", &event.WorkflowData{ID: "e1"}))
+
+	o := workflow.NewOrchestrator(broker, store)
+	o.SetScheduler(parker)
+
+	// the workflow is resumed, the parked event is left to the scheduler
+	assert.NoError(t, o.Resume(_bgCtx, wfID))
+	assert.Equal(t, entity.WorkflowStatusInProgress, status)
+	assert.Equal(t, 1, len(parker.parked))
+}
+
+// conditionalStatus returns setWorkflowStatusIfFunc of mockStore changing the status only from expected ones
+func conditionalStatus(status *entity.WorkflowStatus) func(
+	ctx context.Context, workflowID entity.ID, s entity.WorkflowStatus, expected ...entity.WorkflowStatus) error {
+	return func(ctx context.Context, workflowID entity.ID, s entity.WorkflowStatus, expected ...entity.WorkflowStatus) error {
+		for _, es := range expected {
+			if es == *status {
+				*status = s
+				return nil
+			}
+		}
+
+		return cerror.NewF(ctx, cerror.KindConflict, "workflow isn't in %v status", expected)
+	}
+}
+
+// graphSchema: check -> (approve | reject), approve -> split -> (ship, bill) -> notify.
+// All the workers return output
+func graphSchema(t *testing.T, output string) *entity.WorkflowSchema {
+	t.Helper()
+
+	w := outputWorker(output)
+	chooser := func(_ context.Context, output json.RawMessage) (entity.WorkflowSchemaStepName, error) {
+		var res struct {
+			Next string `json:"next"`
+		}
+
+		if err := json.Unmarshal(output, &res); err != nil {
+			return "", err
+		}
+
+		return entity.WorkflowSchemaStepName(res.Next), nil
+	}
+
+	schema, err := entity.NewWorkflowSchema(_bgCtx, "graph",
+		entity.NewWorkflowSchemaBranchStep("check", "topic-check", w, chooser, "approve", "reject"),
+		entity.NewWorkflowSchemaSimpleStep("reject", "topic-reject", w).SetFinal(),
+		entity.NewWorkflowSchemaSimpleStep("approve", "topic-approve", w).SetNext("split"),
+		entity.NewWorkflowSchemaParallelStep("split", "topic-split", w, "notify", "ship", "bill"),
+		entity.NewWorkflowSchemaSimpleStep("ship", "topic-ship", w).SetNext("notify"),
+		entity.NewWorkflowSchemaSimpleStep("bill", "topic-bill", w).SetNext("notify"),
+		entity.NewWorkflowSchemaSimpleStep("notify", "topic-notify", w),
+	)
+	assert.NoError(t, err)
+
+	return schema
+}
+
 func defSchema(t *testing.T) (*entity.WorkflowSchema, []entity.WorkflowSchemaStep, *stepWorkerTest) {
 	t.Helper()
 
@@ -684,9 +1573,11 @@ type mockStore struct {
 	getWorkflowByIDFunc      func(ctx context.Context, id entity.ID) (*entity.Workflow, error)
 	createWorkflowFunc       func(ctx context.Context, w *entity.Workflow) error
 	setWorkflowStatusFunc    func(ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus) error
+	setWorkflowStatusIfFunc  func(ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus, expected ...entity.WorkflowStatus) error
 	updateWorkflowForceFunc  func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error
 	updateWorkflowNotNilFunc func(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowNotNilParams) error
 	putWorkflowStepsFunc     func(ctx context.Context, workflowID entity.ID, steps []*entity.WorkflowStep) error
+	appendWorkflowStepFunc   func(ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error)
 }
 
 func (m *mockStore) NewID() entity.ID {
@@ -705,6 +1596,16 @@ func (m *mockStore) SetWorkflowStatus(ctx context.Context, workflowID entity.ID,
 	return m.setWorkflowStatusFunc(ctx, workflowID, status)
 }
 
+// SetWorkflowStatusIf sets the status by setWorkflowStatusFunc unless the condition is checked by setWorkflowStatusIfFunc
+func (m *mockStore) SetWorkflowStatusIf(
+	ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus, expected ...entity.WorkflowStatus) error {
+	if m.setWorkflowStatusIfFunc != nil {
+		return m.setWorkflowStatusIfFunc(ctx, workflowID, status, expected...)
+	}
+
+	return m.setWorkflowStatusFunc(ctx, workflowID, status)
+}
+
 func (m *mockStore) UpdateWorkflowForce(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
 	return m.updateWorkflowForceFunc(ctx, workflowID, params)
 }
@@ -717,6 +1618,41 @@ func (m *mockStore) PutWorkflowSteps(ctx context.Context, workflowID entity.ID,
 	return m.putWorkflowStepsFunc(ctx, workflowID, steps)
 }
 
+func (m *mockStore) AppendWorkflowStep(
+	ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+	return m.appendWorkflowStepFunc(ctx, workflowID, step)
+}
+
+type mockAttemptStore struct {
+	mockStore
+	attempts []*entity.WorkflowStepAttempt
+}
+
+func (m *mockAttemptStore) CreateStepAttempt(_ context.Context, a *entity.WorkflowStepAttempt) error {
+	m.attempts = append(m.attempts, a)
+
+	return nil
+}
+
+type txKey struct{}
+
+type mockTxStore struct {
+	mockStore
+	committed  bool
+	rolledBack bool
+}
+
+func (m *mockTxStore) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		m.rolledBack = true
+		return err
+	}
+
+	m.committed = true
+
+	return nil
+}
+
 type mockQueueBroker struct {
 	sendFunc func(ctx context.Context, topic string, e event.BaseEvent) error
 }
@@ -725,6 +1661,71 @@ func (m *mockQueueBroker) Send(ctx context.Context, topic string, e event.BaseEv
 	return m.sendFunc(ctx, topic, e)
 }
 
+type mockTxQueueBroker struct {
+	mockQueueBroker
+}
+
+func (m *mockTxQueueBroker) Transactional() bool {
+	return true
+}
+
+type mockEventScheduler struct {
+	topics []string
+	events []*event.WorkflowData
+	times  []time.Time
+	err    error
+}
+
+func (m *mockEventScheduler) SendAt(_ context.Context, topic string, e *event.WorkflowData, at time.Time) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.topics = append(m.topics, topic)
+	m.events = append(m.events, e)
+	m.times = append(m.times, at)
+
+	return nil
+}
+
+type mockEventParker struct {
+	mockEventScheduler
+	broker *mockQueueBroker
+	onPark func()
+	topics []string
+	parked []*event.WorkflowData
+}
+
+func (m *mockEventParker) Park(ctx context.Context, topic string, e *event.WorkflowData) error {
+	m.topics = append(m.topics, topic)
+	m.parked = append(m.parked, e)
+
+	if m.onPark != nil {
+		m.onPark()
+	}
+
+	return nil
+}
+
+func (m *mockEventParker) SendParked(ctx context.Context, workflowID entity.ID) (int, error) {
+	for i, e := range m.parked {
+		if err := m.broker.Send(ctx, m.topics[i], e); err != nil {
+			return i, err
+		}
+	}
+
+	n := len(m.parked)
+	m.topics, m.parked = nil, nil
+
+	return n, nil
+}
+
+func (m *mockEventParker) DeleteParked(ctx context.Context, workflowID entity.ID) error {
+	m.topics, m.parked = nil, nil
+
+	return nil
+}
+
 type stepWorkerTest struct {
 	runCount   int
 	lastResult json.RawMessage
@@ -736,3 +1737,23 @@ func (s *stepWorkerTest) Run(_ context.Context, _ event.WorkflowEvent) (json.Raw
 
 	return s.lastResult, nil
 }
+
+type outputWorker json.RawMessage
+
+func (w outputWorker) Run(_ context.Context, _ event.WorkflowEvent) (json.RawMessage, error) {
+	return json.RawMessage(w), nil
+}
+
+type funcWorker func(ctx context.Context, e event.WorkflowEvent) (json.RawMessage, error)
+
+func (w funcWorker) Run(ctx context.Context, e event.WorkflowEvent) (json.RawMessage, error) {
+	return w(ctx, e)
+}
+
+type failingWorker struct {
+	err error
+}
+
+func (w *failingWorker) Run(_ context.Context, _ event.WorkflowEvent) (json.RawMessage, error) {
+	return nil, w.err
+}
@@ -0,0 +1,251 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/cerror"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/log"
+	"kafka-polygon/pkg/workflow/entity"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSchedulerPollInterval = 1 * time.Second
+	defaultSchedulerBatchSize    = 100
+)
+
+// ScheduleStore represents a storage with workflow events sent to the queue later
+type ScheduleStore interface {
+	// ScheduleEvent saves the event to be sent at its SendAt time
+	ScheduleEvent(ctx context.Context, e *entity.WorkflowScheduledEvent) error
+	// GetDueEvents returns events which SendAt time isn't after the given time, the earliest first.
+	// Parked events are returned too once their workflow isn't paused
+	GetDueEvents(ctx context.Context, before time.Time, limit int) ([]*entity.WorkflowScheduledEvent, error)
+	// GetParkedEvents returns parked events of the workflow in the order they were parked
+	GetParkedEvents(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowScheduledEvent, error)
+	// DeleteScheduledEvent deletes the event sent to the queue
+	DeleteScheduledEvent(ctx context.Context, id entity.ID) error
+}
+
+// EventScheduler sends workflow events to the queue at the given time
+type EventScheduler interface {
+	SendAt(ctx context.Context, topic string, e *event.WorkflowData, at time.Time) error
+}
+
+// EventParker may be implemented by EventScheduler to keep events of paused workflows until they are resumed
+type EventParker interface {
+	// Park saves the event to be sent to the topic by SendParked
+	Park(ctx context.Context, topic string, e *event.WorkflowData) error
+	// SendParked sends parked events of the workflow to the queue. It returns the number of sent events.
+	// Parked events of the workflow which isn't paused anymore are also sent with due events,
+	// so they aren't lost if SendParked fails
+	SendParked(ctx context.Context, workflowID entity.ID) (int, error)
+	// DeleteParked deletes parked events of the workflow without sending them
+	DeleteParked(ctx context.Context, workflowID entity.ID) error
+}
+
+type SchedulerSettings struct {
+	// PollInterval is a pause between checks for due events
+	PollInterval time.Duration
+	// BatchSize is a maximum number of events read from the store at once
+	BatchSize int
+}
+
+func (ss *SchedulerSettings) initDefault() {
+	if ss.PollInterval.Milliseconds() == 0 {
+		ss.PollInterval = defaultSchedulerPollInterval
+	}
+
+	if ss.BatchSize == 0 {
+		ss.BatchSize = defaultSchedulerBatchSize
+	}
+}
+
+// Scheduler keeps workflow events in the store until they are due and sends them to the queue.
+// Unlike a redelivered message, a scheduled event doesn't hold back other messages of its partition.
+// Several schedulers may poll the same store: an event sent twice is skipped as a duplicate by its ID.
+// It also keeps events of paused workflows until they are resumed, see EventParker.
+type Scheduler struct {
+	queueBroker QueueBroker
+	store       ScheduleStore
+	settings    SchedulerSettings
+	mx          sync.Mutex
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+var (
+	_ EventScheduler = (*Scheduler)(nil)
+	_ EventParker    = (*Scheduler)(nil)
+)
+
+func NewScheduler(qb QueueBroker, s ScheduleStore, ss SchedulerSettings) *Scheduler {
+	ss.initDefault()
+
+	return &Scheduler{
+		queueBroker: qb,
+		store:       s,
+		settings:    ss,
+	}
+}
+
+// SendAt saves the event to be sent to the topic at the given time
+func (s *Scheduler) SendAt(ctx context.Context, topic string, e *event.WorkflowData, at time.Time) error {
+	return s.save(ctx, topic, e, at, false)
+}
+
+// Park saves the event to be sent to the topic when the workflow is resumed
+func (s *Scheduler) Park(ctx context.Context, topic string, e *event.WorkflowData) error {
+	return s.save(ctx, topic, e, time.Now(), true)
+}
+
+// SendParked sends parked events of the workflow in the order they were parked
+func (s *Scheduler) SendParked(ctx context.Context, workflowID entity.ID) (int, error) {
+	events, err := s.store.GetParkedEvents(ctx, workflowID)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.send(ctx, events)
+}
+
+// DeleteParked deletes parked events of the workflow
+func (s *Scheduler) DeleteParked(ctx context.Context, workflowID entity.ID) error {
+	events, err := s.store.GetParkedEvents(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+
+	for _, se := range events {
+		if err := s.store.DeleteScheduledEvent(ctx, se.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) save(ctx context.Context, topic string, e *event.WorkflowData, at time.Time, parked bool) error {
+	// the event is sent without the caller's context later
+	e.WithHeader(ctx)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return s.store.ScheduleEvent(ctx, &entity.WorkflowScheduledEvent{
+		ID:         entity.ID(e.ID),
+		CreatedAt:  time.Now().UTC(),
+		WorkflowID: entity.ID(e.Workflow.ID),
+		Topic:      topic,
+		Event:      data,
+		SendAt:     at.UTC(),
+		Parked:     parked,
+	})
+}
+
+// Run starts sending due events in background until Stop is called or ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go s.loop(ctx, s.done)
+}
+
+// Stop stops sending and waits for the current batch to be processed
+func (s *Scheduler) Stop() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep sending while batches are full
+			for ctx.Err() == nil {
+				n, err := s.SendDue(ctx)
+				if err != nil || n < s.settings.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// SendDue sends one batch of due events. It returns the number of sent events.
+// An event which isn't sent stays in the store to be sent with the next batch.
+func (s *Scheduler) SendDue(ctx context.Context) (int, error) {
+	events, err := s.store.GetDueEvents(ctx, time.Now().UTC(), s.settings.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent, err := s.send(ctx, events)
+	if err != nil {
+		return sent, err
+	}
+
+	log.DebugF(ctx, "[workflowScheduler] sent %d scheduled events", sent)
+
+	return sent, nil
+}
+
+// send sends the events to the queue and deletes them from the store
+func (s *Scheduler) send(ctx context.Context, events []*entity.WorkflowScheduledEvent) (int, error) {
+	var sent int
+
+	for _, se := range events {
+		e := new(event.WorkflowData)
+		if err := json.Unmarshal(se.Event, e); err != nil {
+			return sent, cerror.NewF(ctx, cerror.KindInternal,
+				"scheduled event with id %s can't be read. error=%s", se.ID, err.Error()).LogError()
+		}
+
+		// the event keeps the request id of the scheduling request
+		sendCtx := ctx
+		if e.Header.RequestID != "" {
+			sendCtx = context.WithValue(ctx, consts.HeaderXRequestID, e.Header.RequestID) //nolint:staticcheck
+		}
+
+		if err := s.queueBroker.Send(sendCtx, se.Topic, e); err != nil {
+			return sent, cerror.NewF(ctx, cerror.KindInternal,
+				"scheduled event was not sent. id=%s topic=%s workflow_id=%s. error=%s",
+				se.ID, se.Topic, se.WorkflowID, err.Error()).LogError()
+		}
+
+		if err := s.store.DeleteScheduledEvent(ctx, se.ID); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
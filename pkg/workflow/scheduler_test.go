@@ -0,0 +1,243 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"kafka-polygon/pkg/broker/event"
+	"kafka-polygon/pkg/http/consts"
+	"kafka-polygon/pkg/workflow"
+	"kafka-polygon/pkg/workflow/entity"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+type memScheduleStore struct {
+	mx     sync.Mutex
+	events map[entity.ID]*entity.WorkflowScheduledEvent
+	// paused workflows keep their parked events
+	paused map[entity.ID]bool
+}
+
+func (ms *memScheduleStore) ScheduleEvent(_ context.Context, e *entity.WorkflowScheduledEvent) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.events[e.ID] = e
+
+	return nil
+}
+
+func (ms *memScheduleStore) GetDueEvents(
+	_ context.Context, before time.Time, limit int) ([]*entity.WorkflowScheduledEvent, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	res := make([]*entity.WorkflowScheduledEvent, 0)
+
+	for _, e := range ms.events {
+		if (!e.Parked || !ms.paused[e.WorkflowID]) && !e.SendAt.After(before) {
+			res = append(res, e)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SendAt.Before(res[j].SendAt) })
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+func (ms *memScheduleStore) GetParkedEvents(
+	_ context.Context, workflowID entity.ID) ([]*entity.WorkflowScheduledEvent, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	res := make([]*entity.WorkflowScheduledEvent, 0)
+
+	for _, e := range ms.events {
+		if e.Parked && e.WorkflowID == workflowID {
+			res = append(res, e)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+
+	return res, nil
+}
+
+func (ms *memScheduleStore) DeleteScheduledEvent(_ context.Context, id entity.ID) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	delete(ms.events, id)
+
+	return nil
+}
+
+func (ms *memScheduleStore) len() int {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	return len(ms.events)
+}
+
+type sentEvent struct {
+	topic string
+	id    string
+	reqID string
+}
+
+type recordingBroker struct {
+	mx   sync.Mutex
+	err  error
+	sent []sentEvent
+}
+
+func (rb *recordingBroker) Send(ctx context.Context, topic string, e event.BaseEvent) error {
+	rb.mx.Lock()
+	defer rb.mx.Unlock()
+
+	if rb.err != nil {
+		return rb.err
+	}
+
+	reqID, _ := ctx.Value(consts.HeaderXRequestID).(string)
+	rb.sent = append(rb.sent, sentEvent{topic: topic, id: e.GetID(), reqID: reqID})
+
+	return nil
+}
+
+func (rb *recordingBroker) sentEvents() []sentEvent {
+	rb.mx.Lock()
+	defer rb.mx.Unlock()
+
+	return append([]sentEvent(nil), rb.sent...)
+}
+
+func TestSchedulerSendDue(t *testing.T) {
+	t.Parallel()
+
+	ms := &memScheduleStore{events: make(map[entity.ID]*entity.WorkflowScheduledEvent)}
+	qb := &recordingBroker{}
+	s := workflow.NewScheduler(qb, ms, workflow.SchedulerSettings{BatchSize: 2})
+	now := time.Now()
+
+	schedule := func(id string, at time.Time) {
+		t.Helper()
+
+		e := &event.WorkflowData{ID: id, Workflow: event.Workflow{ID: "wf-1", Step: "step", Attempt: 2}}
+		assert.NoError(t, s.SendAt(_bgCtxWithReqID, "topic-step", e, at))
+	}
+
+	schedule("later", now.Add(time.Hour))
+	schedule("second", now.Add(-time.Second))
+	schedule("first", now.Add(-time.Minute))
+	schedule("third", now)
+	assert.Equal(t, 4, ms.len())
+	assert.Equal(t, entity.ID("wf-1"), ms.events["first"].WorkflowID)
+
+	n, err := s.SendDue(_bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []sentEvent{
+		{topic: "topic-step", id: "first", reqID: _reqID},
+		{topic: "topic-step", id: "second", reqID: _reqID},
+	}, qb.sentEvents())
+
+	// an unsent event stays in the store
+	qb.err = errors.New("broker is down")
+	n, err = s.SendDue(_bgCtx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 2, ms.len())
+
+	qb.err = nil
+	n, err = s.SendDue(_bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "third", qb.sentEvents()[2].id)
+	assert.Equal(t, 1, ms.len())
+}
+
+func TestSchedulerRun(t *testing.T) {
+	t.Parallel()
+
+	ms := &memScheduleStore{events: make(map[entity.ID]*entity.WorkflowScheduledEvent)}
+	qb := &recordingBroker{}
+	s := workflow.NewScheduler(qb, ms, workflow.SchedulerSettings{PollInterval: 10 * time.Millisecond, BatchSize: 2})
+
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		assert.NoError(t, s.SendAt(_bgCtx, "topic", &event.WorkflowData{ID: id}, time.Now()))
+	}
+
+	s.Run(_bgCtx)
+	defer s.Stop()
+
+	assert.Eventually(t, func() bool {
+		return ms.len() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 5, len(qb.sentEvents()))
+
+	s.Stop()
+	assert.NoError(t, s.SendAt(_bgCtx, "topic", &event.WorkflowData{ID: "6"}, time.Now()))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, ms.len())
+}
+
+func TestSchedulerPark(t *testing.T) {
+	t.Parallel()
+
+	ms := &memScheduleStore{
+		events: make(map[entity.ID]*entity.WorkflowScheduledEvent),
+		paused: map[entity.ID]bool{"wf-1": true, "wf-2": true},
+	}
+	qb := &recordingBroker{}
+	s := workflow.NewScheduler(qb, ms, workflow.SchedulerSettings{})
+
+	park := func(id, workflowID string) {
+		t.Helper()
+
+		e := &event.WorkflowData{ID: id, Workflow: event.Workflow{ID: workflowID, Step: "step"}}
+		assert.NoError(t, s.Park(_bgCtxWithReqID, "topic-step", e))
+	}
+
+	park("1", "wf-1")
+	park("2", "wf-2")
+	park("3", "wf-1")
+	assert.True(t, ms.events["1"].Parked)
+
+	// parked events aren't due
+	n, err := s.SendDue(_bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = s.SendParked(_bgCtx, "wf-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []sentEvent{
+		{topic: "topic-step", id: "1", reqID: _reqID},
+		{topic: "topic-step", id: "3", reqID: _reqID},
+	}, qb.sentEvents())
+	assert.Equal(t, 1, ms.len())
+
+	assert.NoError(t, s.DeleteParked(_bgCtx, "wf-2"))
+	assert.Equal(t, 0, ms.len())
+	assert.Equal(t, 2, len(qb.sentEvents()))
+
+	// parked events of the resumed workflow are due, e.g. if sending them on resume failed
+	park("4", "wf-1")
+	ms.mx.Lock()
+	delete(ms.paused, "wf-1")
+	ms.mx.Unlock()
+
+	n, err = s.SendDue(_bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, ms.len())
+}
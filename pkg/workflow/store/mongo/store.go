@@ -19,25 +19,33 @@ import (
 )
 
 const (
-	_defCollWorkflows       = "workflow"
-	_defCollWorkflowHistory = "workflow_history"
+	_defCollWorkflows        = "workflow"
+	_defCollWorkflowHistory  = "workflow_history"
+	_defCollWorkflowSchedule = "workflow_schedule"
+	_defCollWorkflowAttempts = "workflow_step_attempt"
 )
 
 type Store struct {
 	dbName                      string
 	collName, wfHistoryCollName string
+	wfScheduleCollName          string
+	wfAttemptsCollName          string
 	cl                          *mongo.Client
 }
 
 var _ workflow.Store = (*Store)(nil)
 var _ usecase.Store = (*Store)(nil)
+var _ workflow.ScheduleStore = (*Store)(nil)
+var _ workflow.StepAttemptStore = (*Store)(nil)
 
 func NewStore(client *mongo.Client, dbName string) *Store {
 	return &Store{
-		cl:                client,
-		dbName:            dbName,
-		collName:          _defCollWorkflows,
-		wfHistoryCollName: _defCollWorkflowHistory,
+		cl:                 client,
+		dbName:             dbName,
+		collName:           _defCollWorkflows,
+		wfHistoryCollName:  _defCollWorkflowHistory,
+		wfScheduleCollName: _defCollWorkflowSchedule,
+		wfAttemptsCollName: _defCollWorkflowAttempts,
 	}
 }
 
@@ -153,6 +161,40 @@ func (s *Store) SetWorkflowStatus(ctx context.Context, workflowID entity.ID, sta
 	return nil
 }
 
+// SetWorkflowStatusIf sets the status by an update filtered by expected statuses, so concurrent transitions
+// of the workflow don't override each other. The transition is saved to the workflow history after the update.
+func (s *Store) SetWorkflowStatusIf(
+	ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus, expected ...entity.WorkflowStatus) error {
+	now := time.Now().UTC()
+	updateData := bson.M{
+		"updated_at": now,
+		"status":     status,
+	}
+
+	// the workflow before the update is returned to record the transition from its status
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	filter := bson.M{"_id": workflowID, "status": bson.M{"$in": expected}}
+	workflow := &entity.Workflow{}
+
+	err := s.getCollection().FindOneAndUpdate(ctx, filter, bson.M{"$set": updateData}, opts).Decode(workflow)
+	if err == mongo.ErrNoDocuments {
+		return cerror.NewF(ctx,
+			cerror.KindConflict,
+			"set status %s for workflow with id: %s. workflow isn't in %v status", status, workflowID, expected).LogError()
+	}
+
+	if err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"update workflow with id: %s. err: %+v", workflowID, err).LogError()
+	}
+
+	history := entity.NewTransitionHistory(ctx, s.NewID(), workflow, status)
+	history.CreatedAt = now
+
+	return s.CreateWorkflowHistory(ctx, history)
+}
+
 // UpdateWorkflowForce updates certain task fields.
 // All fields (even empty) from entity.ForceUpdateParams will be saved in db
 func (s *Store) UpdateWorkflowForce(
@@ -249,6 +291,36 @@ func (s *Store) PutWorkflowSteps(ctx context.Context, workflowID entity.ID, step
 	return nil
 }
 
+func (s *Store) AppendWorkflowStep(
+	ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+	fields := bson.A{bson.M{"$set": bson.M{
+		"steps": bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$steps", bson.A{}}},
+			bson.M{"$literal": bson.A{step}},
+		}},
+		"updated_at": time.Now().UTC(),
+	}}}
+
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"steps": 1})
+
+	w := &entity.Workflow{}
+	if err := s.getCollection().FindOneAndUpdate(ctx, bson.M{"_id": workflowID}, fields, opts).Decode(w); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, cerror.NewF(ctx,
+				cerror.KindDBNoRows,
+				"append step for workflow with id: %s. not found", workflowID).LogError()
+		}
+
+		return nil, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"append step for workflow with id: %s. err: %+v", workflowID, err).LogError()
+	}
+
+	return w.Steps, nil
+}
+
 func (s *Store) CreateWorkflowHistory(ctx context.Context, wh *entity.WorkflowHistory) error {
 	if _, err := s.getCollectionHistory().InsertOne(ctx, wh); err != nil {
 		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
@@ -257,6 +329,135 @@ func (s *Store) CreateWorkflowHistory(ctx context.Context, wh *entity.WorkflowHi
 	return nil
 }
 
+func (s *Store) GetWorkflowHistory(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error) {
+	ops := &options.FindOptions{
+		Sort: map[string]int{"created_at": 1},
+	}
+
+	cursor, err := s.getCollectionHistory().Find(ctx, bson.M{"workflow_id": workflowID}, ops)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	history := make([]*entity.WorkflowHistory, 0)
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return history, nil
+}
+
+func (s *Store) CreateStepAttempt(ctx context.Context, a *entity.WorkflowStepAttempt) error {
+	if _, err := s.getCollectionAttempts().InsertOne(ctx, a); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
+func (s *Store) GetStepAttempts(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error) {
+	ops := &options.FindOptions{
+		Sort: map[string]int{"started_at": 1},
+	}
+
+	cursor, err := s.getCollectionAttempts().Find(ctx, bson.M{"workflow_id": workflowID}, ops)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	attempts := make([]*entity.WorkflowStepAttempt, 0)
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return attempts, nil
+}
+
+func (s *Store) ScheduleEvent(ctx context.Context, e *entity.WorkflowScheduledEvent) error {
+	if _, err := s.getCollectionSchedule().InsertOne(ctx, e); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
+func (s *Store) GetDueEvents(ctx context.Context, before time.Time, limit int) ([]*entity.WorkflowScheduledEvent, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"send_at": bson.M{"$lte": before}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         s.collName,
+			"localField":   "workflow_id",
+			"foreignField": "_id",
+			"as":           "workflow",
+		}}},
+		// parked events are kept only while their workflow is paused, e.g. if sending them on resume failed.
+		// events scheduled before parking was added have no parked field
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"parked": bson.M{"$ne": true}},
+			bson.M{"workflow.status": bson.M{"$ne": entity.WorkflowStatusPaused}},
+		}}}},
+		{{Key: "$sort", Value: bson.M{"send_at": 1}}},
+		{{Key: "$limit", Value: int64(limit)}},
+		{{Key: "$project", Value: bson.M{"workflow": 0}}},
+	}
+
+	cursor, err := s.getCollectionSchedule().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	events := make([]*entity.WorkflowScheduledEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return events, nil
+}
+
+func (s *Store) GetParkedEvents(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowScheduledEvent, error) {
+	ops := &options.FindOptions{
+		Sort: map[string]int{"created_at": 1},
+	}
+
+	cursor, err := s.getCollectionSchedule().Find(ctx, bson.M{"workflow_id": workflowID, "parked": true}, ops)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	events := make([]*entity.WorkflowScheduledEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return events, nil
+}
+
+func (s *Store) DeleteScheduledEvent(ctx context.Context, id entity.ID) error {
+	if _, err := s.getCollectionSchedule().DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"delete scheduled event with id: %s. err: %+v", id, err).LogError()
+	}
+
+	return nil
+}
+
 func (s *Store) getCollection() *mongo.Collection {
 	return s.cl.Database(s.dbName).Collection(s.collName)
 }
@@ -264,3 +465,11 @@ func (s *Store) getCollection() *mongo.Collection {
 func (s *Store) getCollectionHistory() *mongo.Collection {
 	return s.cl.Database(s.dbName).Collection(s.wfHistoryCollName)
 }
+
+func (s *Store) getCollectionAttempts() *mongo.Collection {
+	return s.cl.Database(s.dbName).Collection(s.wfAttemptsCollName)
+}
+
+func (s *Store) getCollectionSchedule() *mongo.Collection {
+	return s.cl.Database(s.dbName).Collection(s.wfScheduleCollName)
+}
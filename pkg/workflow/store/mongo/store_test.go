@@ -136,6 +136,32 @@ func TestMongoStore(t *testing.T) {
 			assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
 		})
 
+		mt.Run("append case", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "_id", Value: m.ID},
+				{Key: "steps", Value: bson.A{
+					bson.D{{Key: "name", Value: "step-1"}},
+					bson.D{{Key: "name", Value: "step-2"}, {Key: "from", Value: "step-1"}},
+				}},
+			}}))
+			s := storeMongo.NewStore(mt.Client, dbName)
+			s.SetCollectionName(collName)
+			actSteps, err := s.AppendWorkflowStep(bgCtx, m.ID, &entity.WorkflowStep{Name: "step-2", From: "step-1"})
+			require.NoError(mt, err)
+			assert.Equal(t, 2, len(actSteps))
+			assert.Equal(t, entity.WorkflowSchemaStepName("step-1"), actSteps[1].From)
+		})
+
+		mt.Run("append case error", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+			s := storeMongo.NewStore(mt.Client, dbName)
+			s.SetCollectionName(collName)
+			_, err := s.AppendWorkflowStep(bgCtx, m.ID, &entity.WorkflowStep{Name: "step-2"})
+			require.Error(mt, err)
+			assert.Equal(t, fmt.Sprintf("append step for workflow with id: %s. not found", m.ID), err.Error())
+			assert.Equal(t, cerror.KindDBNoRows, cerror.ErrKind(err))
+		})
+
 		mt.Run("task status", func(mt *mtest.T) {
 			mt.AddMockResponses(modifiedResponse)
 			s := storeMongo.NewStore(mt.Client, dbName)
@@ -170,6 +196,29 @@ func TestMongoStore(t *testing.T) {
 			assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
 		})
 
+		mt.Run("task status if", func(mt *mtest.T) {
+			// the workflow before the update and the saved history
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "_id", Value: m.ID},
+				{Key: "status", Value: entity.WorkflowStatusInProgress},
+			}}), mtest.CreateSuccessResponse())
+			s := storeMongo.NewStore(mt.Client, dbName)
+			s.SetCollectionName(collName)
+			err := s.SetWorkflowStatusIf(bgCtx, m.ID, entity.WorkflowStatusSuccess, entity.WorkflowStatusInProgress)
+			require.NoError(mt, err)
+		})
+
+		mt.Run("task status if conflict", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+			s := storeMongo.NewStore(mt.Client, dbName)
+			s.SetCollectionName(collName)
+			err := s.SetWorkflowStatusIf(bgCtx, m.ID, entity.WorkflowStatusSuccess, entity.WorkflowStatusInProgress)
+			require.Error(mt, err)
+			assert.Equal(t, fmt.Sprintf("set status SUCCESS for workflow with id: %s. workflow isn't in [IN_PROGRESS] status",
+				m.ID), err.Error())
+			assert.Equal(t, cerror.KindConflict, cerror.ErrKind(err))
+		})
+
 		mt.Run("update task", func(mt *mtest.T) {
 			mt.AddMockResponses(modifiedResponse)
 			s := storeMongo.NewStore(mt.Client, dbName)
@@ -345,5 +394,143 @@ func TestMongoStore(t *testing.T) {
 			require.Error(mt, err)
 			assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
 		})
+
+		scheduled := &entity.WorkflowScheduledEvent{
+			ID:         entity.ID(primitive.NewObjectID().Hex()),
+			CreatedAt:  now,
+			WorkflowID: m.ID,
+			Topic:      "test-topic",
+			Event:      []byte(`{"id":"1"}`),
+			SendAt:     now,
+		}
+
+		mt.Run("schedule event", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+			s := storeMongo.NewStore(mt.Client, dbName)
+			err := s.ScheduleEvent(bgCtx, scheduled)
+			require.NoError(mt, err)
+		})
+
+		mt.Run("schedule event error", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateWriteConcernErrorResponse(mtest.WriteConcernError{
+				Name:    "ScheduleEventErr",
+				Code:    1000,
+				Message: "not schedule event",
+			}))
+			s := storeMongo.NewStore(mt.Client, dbName)
+			err := s.ScheduleEvent(bgCtx, scheduled)
+			require.Error(mt, err)
+			assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
+		})
+
+		mt.Run("due events", func(mt *mtest.T) {
+			ns := "test-db.workflow_schedule"
+			find := mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: scheduled.ID},
+				{Key: "workflow_id", Value: scheduled.WorkflowID},
+				{Key: "topic", Value: scheduled.Topic},
+				{Key: "event", Value: []byte(scheduled.Event)},
+			})
+			mt.AddMockResponses(find)
+			s := storeMongo.NewStore(mt.Client, dbName)
+			events, err := s.GetDueEvents(bgCtx, now, 10)
+			require.NoError(mt, err)
+			require.Len(mt, events, 1)
+			assert.Equal(t, scheduled.ID, events[0].ID)
+			assert.Equal(t, scheduled.Topic, events[0].Topic)
+			assert.Equal(t, scheduled.Event, events[0].Event)
+		})
+
+		mt.Run("parked events", func(mt *mtest.T) {
+			ns := "test-db.workflow_schedule"
+			find := mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: scheduled.ID},
+				{Key: "workflow_id", Value: scheduled.WorkflowID},
+				{Key: "topic", Value: scheduled.Topic},
+				{Key: "event", Value: []byte(scheduled.Event)},
+				{Key: "parked", Value: true},
+			})
+			mt.AddMockResponses(find)
+			s := storeMongo.NewStore(mt.Client, dbName)
+			events, err := s.GetParkedEvents(bgCtx, m.ID)
+			require.NoError(mt, err)
+			require.Len(mt, events, 1)
+			assert.Equal(t, scheduled.ID, events[0].ID)
+			assert.True(t, events[0].Parked)
+		})
+
+		mt.Run("delete scheduled event", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+			s := storeMongo.NewStore(mt.Client, dbName)
+			err := s.DeleteScheduledEvent(bgCtx, scheduled.ID)
+			require.NoError(mt, err)
+		})
+
+		attempt := &entity.WorkflowStepAttempt{
+			ID:         entity.ID(primitive.NewObjectID().Hex()),
+			WorkflowID: m.ID,
+			StepName:   "test-step-name",
+			Attempt:    1,
+			EventID:    "test-event-id",
+			StartedAt:  now,
+			FinishedAt: now,
+			Outcome:    entity.WorkflowStepOutcomeSuccess,
+			OutputSize: 2,
+		}
+
+		mt.Run("create step attempt", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+			s := storeMongo.NewStore(mt.Client, dbName)
+			err := s.CreateStepAttempt(bgCtx, attempt)
+			require.NoError(mt, err)
+		})
+
+		mt.Run("create step attempt error", func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateWriteConcernErrorResponse(mtest.WriteConcernError{
+				Name:    "CreateStepAttemptErr",
+				Code:    1000,
+				Message: "not create step attempt",
+			}))
+			s := storeMongo.NewStore(mt.Client, dbName)
+			err := s.CreateStepAttempt(bgCtx, attempt)
+			require.Error(mt, err)
+			assert.Equal(t, cerror.KindDBOther, cerror.ErrKind(err))
+		})
+
+		mt.Run("step attempts", func(mt *mtest.T) {
+			ns := "test-db.workflow_step_attempt"
+			find := mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: attempt.ID},
+				{Key: "workflow_id", Value: attempt.WorkflowID},
+				{Key: "step_name", Value: attempt.StepName},
+				{Key: "attempt", Value: attempt.Attempt},
+				{Key: "outcome", Value: attempt.Outcome},
+				{Key: "output_size", Value: attempt.OutputSize},
+			})
+			mt.AddMockResponses(find)
+			s := storeMongo.NewStore(mt.Client, dbName)
+			attempts, err := s.GetStepAttempts(bgCtx, m.ID)
+			require.NoError(mt, err)
+			require.Len(mt, attempts, 1)
+			assert.Equal(t, attempt.ID, attempts[0].ID)
+			assert.Equal(t, attempt.StepName, attempts[0].StepName)
+			assert.Equal(t, attempt.Outcome, attempts[0].Outcome)
+			assert.Equal(t, attempt.OutputSize, attempts[0].OutputSize)
+		})
+
+		mt.Run("workflow history", func(mt *mtest.T) {
+			ns := "test-db.workflow_history"
+			find := mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: m.ID},
+				{Key: "type", Value: entity.WorkflowHistoryTypePause},
+				{Key: "workflow_id", Value: m.ID},
+			})
+			mt.AddMockResponses(find)
+			s := storeMongo.NewStore(mt.Client, dbName)
+			history, err := s.GetWorkflowHistory(bgCtx, m.ID)
+			require.NoError(mt, err)
+			require.Len(mt, history, 1)
+			assert.Equal(t, entity.WorkflowHistoryTypePause, history[0].Type)
+		})
 	})
 }
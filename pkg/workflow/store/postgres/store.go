@@ -3,8 +3,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"kafka-polygon/pkg/cerror"
+	pgBun "kafka-polygon/pkg/db/postgres/bun"
 	"kafka-polygon/pkg/workflow"
 	"kafka-polygon/pkg/workflow/entity"
 	"kafka-polygon/pkg/workflow/entrypoint/usecase"
@@ -21,12 +23,33 @@ type Store struct {
 }
 
 var _ workflow.Store = (*Store)(nil)
+var _ workflow.TxRunner = (*Store)(nil)
 var _ usecase.Store = (*Store)(nil)
+var _ workflow.ScheduleStore = (*Store)(nil)
+var _ workflow.StepAttemptStore = (*Store)(nil)
 
 func NewStore(db *bun.DB) *Store {
 	return &Store{db: db}
 }
 
+// RunInTx calls fn within a transaction which is available in fn's context.
+// Store methods called with this context join the transaction.
+// If ctx already carries a transaction, fn joins it.
+func (s *Store) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := pgBun.TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(pgBun.ContextWithTx(ctx, tx))
+	})
+}
+
+// idb returns a transaction from ctx if it exists, otherwise the store db
+func (s *Store) idb(ctx context.Context) bun.IDB {
+	return pgBun.IDBFromContext(ctx, s.db)
+}
+
 func (s *Store) Type() string {
 	return store.StoreTypePostgres
 }
@@ -37,7 +60,7 @@ func (s *Store) NewID() entity.ID {
 
 func (s *Store) GetWorkflowByID(ctx context.Context, id entity.ID) (*entity.Workflow, error) {
 	dst := &entity.Workflow{ID: id}
-	if err := s.db.NewSelect().Model(dst).WherePK().Scan(ctx); err != nil {
+	if err := s.idb(ctx).NewSelect().Model(dst).WherePK().Scan(ctx); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, cerror.NewF(ctx,
 				cerror.KindDBNoRows,
@@ -54,7 +77,7 @@ func (s *Store) GetWorkflowByID(ctx context.Context, id entity.ID) (*entity.Work
 
 func (s *Store) SearchWorkflows(ctx context.Context, params entity.SearchWorkflowParams) (*entity.SearchWorkflowResult, error) {
 	dst := make([]*entity.Workflow, 0)
-	q := s.db.NewSelect().Model(&dst)
+	q := s.idb(ctx).NewSelect().Model(&dst)
 
 	if params.ID != nil {
 		q.Where("id=?", params.ID.String())
@@ -89,7 +112,7 @@ func (s *Store) SearchWorkflows(ctx context.Context, params entity.SearchWorkflo
 }
 
 func (s *Store) CreateWorkflow(ctx context.Context, w *entity.Workflow) error {
-	if _, err := s.db.NewInsert().Model(w).Exec(ctx); err != nil {
+	if _, err := s.idb(ctx).NewInsert().Model(w).Exec(ctx); err != nil {
 		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
 	}
 
@@ -97,7 +120,7 @@ func (s *Store) CreateWorkflow(ctx context.Context, w *entity.Workflow) error {
 }
 
 func (s *Store) SetWorkflowStatus(ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus) error {
-	if _, err := s.db.
+	if _, err := s.idb(ctx).
 		NewUpdate().
 		Model(&entity.Workflow{
 			ID:        workflowID,
@@ -115,10 +138,58 @@ func (s *Store) SetWorkflowStatus(ctx context.Context, workflowID entity.ID, sta
 	return nil
 }
 
+// SetWorkflowStatusIf sets the status by a conditional update, so concurrent transitions of the workflow
+// don't override each other. The transition is saved to the workflow history in the same transaction.
+func (s *Store) SetWorkflowStatusIf(
+	ctx context.Context, workflowID entity.ID, status entity.WorkflowStatus, expected ...entity.WorkflowStatus) error {
+	return s.RunInTx(ctx, func(ctx context.Context) error {
+		// the row is locked until the transaction ends, so the status can't be changed after it's checked
+		workflow := &entity.Workflow{ID: workflowID}
+
+		err := s.idb(ctx).
+			NewSelect().
+			Model(workflow).
+			WherePK().
+			Where("status IN (?)", bun.In(expected)).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return cerror.NewF(ctx,
+				cerror.KindConflict,
+				"set status %s for workflow with id: %s. workflow isn't in %v status", status, workflowID, expected).LogError()
+		}
+
+		if err != nil {
+			return cerror.NewF(ctx,
+				cerror.DBToKind(err),
+				"set status for workflow with id: %s. err: %+v", workflowID, err).LogError()
+		}
+
+		history := entity.NewTransitionHistory(ctx, s.NewID(), workflow, status)
+
+		if _, err := s.idb(ctx).
+			NewUpdate().
+			Model(&entity.Workflow{
+				ID:        workflowID,
+				Status:    status,
+				UpdatedAt: history.CreatedAt,
+			}).
+			Column("status", "updated_at").
+			WherePK().
+			Exec(ctx); err != nil {
+			return cerror.NewF(ctx,
+				cerror.DBToKind(err),
+				"set status for workflow with id: %s. err: %+v", workflowID, err).LogError()
+		}
+
+		return s.CreateWorkflowHistory(ctx, history)
+	})
+}
+
 // UpdateWorkflowForce updates certain workflow fields.
 // All fields (even empty) from entity.UpdateWorkflowForceParams will be saved in db.
 func (s *Store) UpdateWorkflowForce(ctx context.Context, workflowID entity.ID, params entity.UpdateWorkflowForceParams) error {
-	if _, err := s.db.
+	if _, err := s.idb(ctx).
 		NewUpdate().
 		Model(&entity.Workflow{
 			ID:        workflowID,
@@ -153,7 +224,7 @@ func (s *Store) UpdateWorkflowNotNil(ctx context.Context, workflowID entity.ID,
 		updateModel.Status = *params.Status
 	}
 
-	if _, err := s.db.
+	if _, err := s.idb(ctx).
 		NewUpdate().
 		Model(updateModel).
 		Column("status", "steps", "error", "error_kind", "updated_at").
@@ -169,7 +240,7 @@ func (s *Store) UpdateWorkflowNotNil(ctx context.Context, workflowID entity.ID,
 }
 
 func (s *Store) PutWorkflowSteps(ctx context.Context, workflowID entity.ID, steps []*entity.WorkflowStep) error {
-	if _, err := s.db.
+	if _, err := s.idb(ctx).
 		NewUpdate().
 		Model(&entity.Workflow{
 			ID:        workflowID,
@@ -187,10 +258,132 @@ func (s *Store) PutWorkflowSteps(ctx context.Context, workflowID entity.ID, step
 	return nil
 }
 
+func (s *Store) AppendWorkflowStep(
+	ctx context.Context, workflowID entity.ID, step *entity.WorkflowStep) ([]*entity.WorkflowStep, error) {
+	appended, err := json.Marshal([]*entity.WorkflowStep{step})
+	if err != nil {
+		return nil, cerror.NewF(ctx,
+			cerror.KindInternal,
+			"append step for workflow with id: %s. err: %+v", workflowID, err).LogError()
+	}
+
+	dst := &entity.Workflow{ID: workflowID}
+	if err := s.idb(ctx).
+		NewUpdate().
+		Model(dst).
+		Set("steps = COALESCE(steps, '[]'::jsonb) || ?::jsonb", string(appended)).
+		Set("updated_at = ?", time.Now().UTC()).
+		WherePK().
+		Returning("steps").
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, cerror.NewF(ctx,
+				cerror.KindDBNoRows,
+				"append step for workflow with id: %s. not found", workflowID).LogError()
+		}
+
+		return nil, cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"append step for workflow with id: %s. err: %+v", workflowID, err).LogError()
+	}
+
+	return dst.Steps, nil
+}
+
 func (s *Store) CreateWorkflowHistory(ctx context.Context, wh *entity.WorkflowHistory) error {
-	if _, err := s.db.NewInsert().Model(wh).Exec(ctx); err != nil {
+	if _, err := s.idb(ctx).NewInsert().Model(wh).Exec(ctx); err != nil {
 		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
 	}
 
 	return nil
 }
+
+func (s *Store) GetWorkflowHistory(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowHistory, error) {
+	dst := make([]*entity.WorkflowHistory, 0)
+
+	err := s.idb(ctx).NewSelect().Model(&dst).Where("workflow_id = ?", workflowID).Order("created_at").Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return dst, nil
+}
+
+func (s *Store) CreateStepAttempt(ctx context.Context, a *entity.WorkflowStepAttempt) error {
+	if _, err := s.idb(ctx).NewInsert().Model(a).Exec(ctx); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
+func (s *Store) GetStepAttempts(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowStepAttempt, error) {
+	dst := make([]*entity.WorkflowStepAttempt, 0)
+
+	err := s.idb(ctx).NewSelect().Model(&dst).Where("workflow_id = ?", workflowID).Order("started_at").Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return dst, nil
+}
+
+func (s *Store) ScheduleEvent(ctx context.Context, e *entity.WorkflowScheduledEvent) error {
+	if _, err := s.idb(ctx).NewInsert().Model(e).Exec(ctx); err != nil {
+		return cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return nil
+}
+
+func (s *Store) GetDueEvents(ctx context.Context, before time.Time, limit int) ([]*entity.WorkflowScheduledEvent, error) {
+	dst := make([]*entity.WorkflowScheduledEvent, 0)
+
+	err := s.idb(ctx).NewSelect().
+		Model(&dst).
+		Where("send_at <= ?", before).
+		// parked events are kept only while their workflow is paused, e.g. if sending them on resume failed
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("NOT parked").
+				WhereOr("NOT EXISTS (SELECT 1 FROM workflow AS w WHERE w.id = ?TableAlias.workflow_id AND w.status = ?)",
+					entity.WorkflowStatusPaused)
+		}).
+		Order("send_at").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return dst, nil
+}
+
+func (s *Store) GetParkedEvents(ctx context.Context, workflowID entity.ID) ([]*entity.WorkflowScheduledEvent, error) {
+	dst := make([]*entity.WorkflowScheduledEvent, 0)
+
+	err := s.idb(ctx).NewSelect().
+		Model(&dst).
+		Where("workflow_id = ?", workflowID).
+		Where("parked").
+		Order("created_at").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, cerror.New(ctx, cerror.DBToKind(err), err).LogError()
+	}
+
+	return dst, nil
+}
+
+func (s *Store) DeleteScheduledEvent(ctx context.Context, id entity.ID) error {
+	if _, err := s.idb(ctx).
+		NewDelete().
+		Model((*entity.WorkflowScheduledEvent)(nil)).
+		Where("id = ?", id).
+		Exec(ctx); err != nil {
+		return cerror.NewF(ctx,
+			cerror.DBToKind(err),
+			"delete scheduled event with id: %s. err: %+v", id, err).LogError()
+	}
+
+	return nil
+}
@@ -99,6 +99,44 @@ func (s *storeTestSuite) SetupSuite() {
 		return
 	}
 
+	querySchedule := `CREATE TABLE IF NOT EXISTS workflow_schedule (
+    id UUID PRIMARY KEY,
+    created_at timestamp NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    workflow_id UUID NOT NULL references workflow(id),
+    topic TEXT NOT NULL,
+    event JSONB NOT NULL,
+    send_at timestamp NOT NULL,
+    parked BOOLEAN NOT NULL DEFAULT false
+);`
+
+	_, err = s.pgConn.DB().ExecContext(ctx, querySchedule)
+	if err != nil {
+		s.T().Error(err)
+		return
+	}
+
+	queryAttempt := `CREATE TABLE IF NOT EXISTS workflow_step_attempt (
+    id UUID PRIMARY KEY,
+    workflow_id UUID NOT NULL references workflow(id),
+    step_name varchar(50) NOT NULL,
+    attempt INT NOT NULL DEFAULT 1,
+    compensation BOOLEAN NOT NULL DEFAULT false,
+    event_id varchar(50) NOT NULL DEFAULT '',
+    started_at timestamp NOT NULL,
+    finished_at timestamp NOT NULL,
+    outcome varchar(50) NOT NULL,
+    error TEXT NULL,
+    error_kind varchar(50) NULL,
+    output_size INT NOT NULL DEFAULT 0,
+    request_id varchar(50) NULL
+);`
+
+	_, err = s.pgConn.DB().ExecContext(ctx, queryAttempt)
+	if err != nil {
+		s.T().Error(err)
+		return
+	}
+
 	s.st = postgres.NewStore(s.pgConn.DB())
 }
 
@@ -208,6 +246,41 @@ func (s *storeTestSuite) TestWorkflowStatus() {
 	s.Equal(entity.WorkflowStatusSuccess, data.Status)
 }
 
+func (s *storeTestSuite) TestSetWorkflowStatusIf() {
+	uID := entity.ID(uuid.NewV4().String())
+	now := time.Now().UTC()
+	m := &entity.Workflow{
+		ID:        uID,
+		Status:    entity.WorkflowStatusInProgress,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := s.st.CreateWorkflow(bgCtx, m)
+	s.NoError(err)
+
+	err = s.st.SetWorkflowStatusIf(bgCtx, uID, entity.WorkflowStatusPaused,
+		entity.WorkflowStatusInProgress, entity.WorkflowStatusFailed)
+	s.NoError(err)
+
+	// the paused workflow isn't in progress anymore
+	err = s.st.SetWorkflowStatusIf(bgCtx, uID, entity.WorkflowStatusSuccess, entity.WorkflowStatusInProgress)
+	s.Error(err)
+	s.Equal(cerror.KindConflict, cerror.ErrKind(err))
+
+	data, err := s.st.GetWorkflowByID(bgCtx, uID)
+	s.NoError(err)
+	s.Equal(entity.WorkflowStatusPaused, data.Status)
+
+	// only the applied transition is saved to the history
+	history, err := s.st.GetWorkflowHistory(bgCtx, uID)
+	s.NoError(err)
+	s.Len(history, 1)
+	s.Equal(entity.WorkflowHistoryTypePause, history[0].Type)
+	s.Equal(entity.WorkflowStatusInProgress, history[0].WorkflowStatus)
+	s.JSONEq(`{}`, string(history[0].Input))
+}
+
 func (s *storeTestSuite) TestUpdateWorkflowForce() {
 	uID := entity.ID(uuid.NewV4().String())
 	now := time.Now().UTC()
@@ -337,6 +410,48 @@ func (s *storeTestSuite) TestPutStep() {
 	s.Equal(len(nStep), len(data.Steps))
 }
 
+func (s *storeTestSuite) TestAppendStep() {
+	uID := entity.ID(uuid.NewV4().String())
+	now := time.Now().UTC()
+	m := &entity.Workflow{
+		ID:         uID,
+		Status:     entity.WorkflowStatusInProgress,
+		SchemaName: "test-flow-type",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err := s.st.CreateWorkflow(bgCtx, m)
+	s.NoError(err)
+
+	steps, err := s.st.AppendWorkflowStep(bgCtx, uID, &entity.WorkflowStep{
+		Name:      "test-case-name",
+		Data:      []byte(`{"a":1}`),
+		CreatedAt: now,
+	})
+	s.NoError(err)
+	s.Equal(1, len(steps))
+
+	steps, err = s.st.AppendWorkflowStep(bgCtx, uID, &entity.WorkflowStep{
+		Name:      "test-case-join",
+		From:      "test-case-name",
+		Data:      []byte(`{"b":2}`),
+		CreatedAt: now,
+	})
+	s.NoError(err)
+	s.Equal(2, len(steps))
+	s.Equal(entity.WorkflowSchemaStepName("test-case-join"), steps[1].Name)
+	s.Equal(entity.WorkflowSchemaStepName("test-case-name"), steps[1].From)
+
+	data, err := s.st.GetWorkflowByID(bgCtx, uID)
+	s.NoError(err)
+	s.Equal(2, len(data.Steps))
+
+	_, err = s.st.AppendWorkflowStep(bgCtx, entity.ID(uuid.NewV4().String()), &entity.WorkflowStep{Name: "test-case-name"})
+	s.Error(err)
+	s.Equal(cerror.KindDBNoRows, cerror.ErrKind(err))
+}
+
 func (s *storeTestSuite) TestSearchWorkflows() {
 	uID := entity.ID(uuid.NewV4().String())
 	now := time.Now().UTC()
@@ -421,3 +536,158 @@ func (s *storeTestSuite) TestSaveHistory() {
 	s.Error(err)
 	s.Equal(cerror.KindDBOther, cerror.ErrKind(err))
 }
+
+func (s *storeTestSuite) TestStepAttempts() {
+	wfID := entity.ID(uuid.NewV4().String())
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	err := s.st.CreateWorkflow(bgCtx, &entity.Workflow{
+		ID:         wfID,
+		Status:     entity.WorkflowStatusInProgress,
+		SchemaName: "test-flow-type",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	s.NoError(err)
+
+	attempts, err := s.st.GetStepAttempts(bgCtx, wfID)
+	s.NoError(err)
+	s.Len(attempts, 0)
+
+	retried := &entity.WorkflowStepAttempt{
+		ID:         entity.ID(uuid.NewV4().String()),
+		WorkflowID: wfID,
+		StepName:   "test-step-name",
+		Attempt:    1,
+		EventID:    "event-1",
+		StartedAt:  now,
+		FinishedAt: now.Add(time.Second),
+		Outcome:    entity.WorkflowStepOutcomeRetried,
+		Error:      entity.PointerWorkflowErrorMsg("test-error"),
+		ErrorKind:  entity.PointerWorkflowErrorKind("test-error-kind"),
+		RequestID:  converto.StringPointer("test-request-id"),
+	}
+	succeeded := &entity.WorkflowStepAttempt{
+		ID:         entity.ID(uuid.NewV4().String()),
+		WorkflowID: wfID,
+		StepName:   "test-step-name",
+		Attempt:    2,
+		EventID:    "event-2",
+		StartedAt:  now.Add(time.Minute),
+		FinishedAt: now.Add(time.Minute + time.Second),
+		Outcome:    entity.WorkflowStepOutcomeSuccess,
+		OutputSize: 2,
+	}
+
+	s.NoError(s.st.CreateStepAttempt(bgCtx, succeeded))
+	s.NoError(s.st.CreateStepAttempt(bgCtx, retried))
+
+	err = s.st.CreateStepAttempt(bgCtx, retried)
+	s.Error(err)
+	s.Equal(cerror.KindDBOther, cerror.ErrKind(err))
+
+	attempts, err = s.st.GetStepAttempts(bgCtx, wfID)
+	s.NoError(err)
+	s.Equal([]*entity.WorkflowStepAttempt{retried, succeeded}, attempts)
+
+	s.NoError(s.st.CreateWorkflowHistory(bgCtx, &entity.WorkflowHistory{
+		ID:             entity.ID(uuid.NewV4().String()),
+		Type:           entity.WorkflowHistoryTypePause,
+		StepName:       "test-step-name",
+		WorkflowID:     wfID,
+		WorkflowStatus: entity.WorkflowStatusInProgress,
+		CreatedAt:      now,
+	}))
+
+	history, err := s.st.GetWorkflowHistory(bgCtx, wfID)
+	s.NoError(err)
+	s.Len(history, 1)
+	s.Equal(entity.WorkflowHistoryTypePause, history[0].Type)
+}
+
+func (s *storeTestSuite) TestScheduledEvents() {
+	wfID := entity.ID(uuid.NewV4().String())
+	now := time.Now().UTC()
+
+	err := s.st.CreateWorkflow(bgCtx, &entity.Workflow{
+		ID:         wfID,
+		Status:     entity.WorkflowStatusInProgress,
+		SchemaName: "test-flow-type",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	s.NoError(err)
+
+	schedule := func(sendAt time.Time, parked bool) *entity.WorkflowScheduledEvent {
+		e := &entity.WorkflowScheduledEvent{
+			ID:         entity.ID(uuid.NewV4().String()),
+			CreatedAt:  now,
+			WorkflowID: wfID,
+			Topic:      "test-topic",
+			Event:      []byte(`{"id":"1"}`),
+			SendAt:     sendAt,
+			Parked:     parked,
+		}
+		s.NoError(s.st.ScheduleEvent(bgCtx, e))
+
+		return e
+	}
+
+	later := schedule(now.Add(time.Hour), false)
+	second := schedule(now.Add(-time.Second), false)
+	first := schedule(now.Add(-time.Minute), false)
+
+	err = s.st.ScheduleEvent(bgCtx, first)
+	s.Error(err)
+
+	due, err := s.st.GetDueEvents(bgCtx, now, 10)
+	s.NoError(err)
+	s.Len(due, 2)
+	s.Equal(first.ID, due[0].ID)
+	s.Equal(second.ID, due[1].ID)
+	s.Equal(wfID, due[0].WorkflowID)
+	s.Equal("test-topic", due[0].Topic)
+	s.JSONEq(`{"id":"1"}`, string(due[0].Event))
+
+	due, err = s.st.GetDueEvents(bgCtx, now, 1)
+	s.NoError(err)
+	s.Len(due, 1)
+
+	s.NoError(s.st.DeleteScheduledEvent(bgCtx, first.ID))
+	s.NoError(s.st.DeleteScheduledEvent(bgCtx, second.ID))
+
+	due, err = s.st.GetDueEvents(bgCtx, now, 10)
+	s.NoError(err)
+	s.Len(due, 0)
+
+	due, err = s.st.GetDueEvents(bgCtx, later.SendAt, 10)
+	s.NoError(err)
+	s.Len(due, 1)
+
+	// parked events aren't due while their workflow is paused
+	s.NoError(s.st.SetWorkflowStatus(bgCtx, wfID, entity.WorkflowStatusPaused))
+
+	parked := schedule(now.Add(-time.Hour), true)
+
+	due, err = s.st.GetDueEvents(bgCtx, now, 10)
+	s.NoError(err)
+	s.Len(due, 0)
+
+	parkedEvents, err := s.st.GetParkedEvents(bgCtx, wfID)
+	s.NoError(err)
+	s.Len(parkedEvents, 1)
+	s.Equal(parked.ID, parkedEvents[0].ID)
+	s.True(parkedEvents[0].Parked)
+
+	parkedEvents, err = s.st.GetParkedEvents(bgCtx, entity.ID(uuid.NewV4().String()))
+	s.NoError(err)
+	s.Len(parkedEvents, 0)
+
+	// parked events of the resumed workflow are due
+	s.NoError(s.st.SetWorkflowStatus(bgCtx, wfID, entity.WorkflowStatusInProgress))
+
+	due, err = s.st.GetDueEvents(bgCtx, now, 10)
+	s.NoError(err)
+	s.Len(due, 1)
+	s.Equal(parked.ID, due[0].ID)
+}
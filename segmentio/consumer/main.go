@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"kafka-polygon/pkg/broker"
+	"kafka-polygon/pkg/broker/provider"
 	"kafka-polygon/pkg/broker/provider/kafka"
 	"kafka-polygon/pkg/log"
 	"sync"
@@ -59,14 +60,14 @@ func main() {
 			consumer: consumer,
 			index:    i,
 		})
-		//consumer.Stop()
+		//consumer.Stop(ctx)
 	}
 
 	var wg sync.WaitGroup
 	for _, c := range consumersToStop {
 		wg.Add(1)
 		go func(c *ConsumerData) {
-			c.consumer.Stop()
+			_ = c.consumer.Stop(ctx)
 			wg.Done()
 		}(c)
 	}
@@ -77,9 +78,13 @@ func main() {
 func runConsumer(ctx context.Context, i int) {
 	logger.LogF("Consumer started: %s", time.Now()).InConsole(ctx)
 
-	kafkaC := kafka.NewKafkaProvider(newKafkaProducerConsumerConfig())
-	consumer = broker.New(kafkaC)
 	redis := getRedis()
+	cfg := newKafkaProducerConsumerConfig()
+	// consuming is paused while redis is down instead of failing messages
+	cfg.HealthCheck = provider.StoreHealthCheck(redis)
+
+	kafkaC := kafka.NewKafkaProvider(cfg)
+	consumer = broker.New(kafkaC)
 
 	consumer.SetStore(redis)
 